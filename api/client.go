@@ -1,8 +1,13 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,7 +20,6 @@ import (
 
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/go-rootcerts"
-	"github.com/sethgrid/pester"
 )
 
 const EnvVaultAddress = "VAULT_ADDR"
@@ -39,8 +43,9 @@ type WrappingLookupFunc func(operation, path string) string
 // Config is used to configure the creation of the client.
 type Config struct {
 	// Address is the address of the Vault server. This should be a complete
-	// URL such as "http://vault.example.com". If you need a custom SSL
-	// cert or want to enable insecure mode, you need to specify a custom
+	// URL such as "http://vault.example.com", or the socket of a unix
+	// listener such as "unix:///var/run/vault.sock". If you need a custom
+	// SSL cert or want to enable insecure mode, you need to specify a custom
 	// HttpClient.
 	Address string
 
@@ -51,8 +56,15 @@ type Config struct {
 	redirectSetup sync.Once
 
 	// MaxRetries controls the maximum number of times to retry when a 5xx error
-	// occurs. Set to 0 or less to disable retrying. Defaults to 0.
+	// or a connection error occurs. Requests with methods that are not
+	// idempotent are only retried when they could not be sent. Set to 0 or
+	// less to disable retrying. Defaults to 2.
 	MaxRetries int
+
+	// MinRetryWait and MaxRetryWait bound the exponential backoff between
+	// retries, to which jitter is added. Default to 1 and 30 seconds.
+	MinRetryWait time.Duration
+	MaxRetryWait time.Duration
 }
 
 // TLSConfig contains the parameters needed to configure TLS on the HTTP client
@@ -87,8 +99,11 @@ type TLSConfig struct {
 // setting the `VAULT_ADDR` environment variable.
 func DefaultConfig() *Config {
 	config := &Config{
-		Address:    "https://127.0.0.1:8200",
-		HttpClient: cleanhttp.DefaultClient(),
+		Address:      "https://127.0.0.1:8200",
+		HttpClient:   cleanhttp.DefaultClient(),
+		MaxRetries:   2,
+		MinRetryWait: time.Second,
+		MaxRetryWait: 30 * time.Second,
 	}
 	config.HttpClient.Timeout = time.Second * 60
 	transport := config.HttpClient.Transport.(*http.Transport)
@@ -211,7 +226,7 @@ func (c *Config) ReadEnvironment() error {
 	}
 
 	if envMaxRetries != nil {
-		c.MaxRetries = int(*envMaxRetries) + 1
+		c.MaxRetries = int(*envMaxRetries)
 	}
 
 	return nil
@@ -224,6 +239,7 @@ type Client struct {
 	config             *Config
 	token              string
 	wrappingLookupFunc WrappingLookupFunc
+	ctx                context.Context
 }
 
 // NewClient returns a new client for the given configuration.
@@ -253,6 +269,22 @@ func NewClient(c *Config) (*Client, error) {
 		return nil, err
 	}
 
+	// Addresses such as unix:///var/run/vault.sock connect to a unix
+	// listener, with plain HTTP requests to a placeholder host
+	if u.Scheme == "unix" {
+		socket := u.Path
+		if socket == "" {
+			socket = u.Opaque
+		}
+		tp.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+		u = &url.URL{
+			Scheme: "http",
+			Host:   "localhost",
+		}
+	}
+
 	redirFunc := func() {
 		// Ensure redirects are not automatically followed
 		// Note that this is sane for the API client as it has its own
@@ -303,6 +335,34 @@ func (c *Client) SetMaxRetries(retries int) {
 	c.config.MaxRetries = retries
 }
 
+// SetRetryWait sets the bounds of the backoff between retries
+func (c *Client) SetRetryWait(min, max time.Duration) {
+	c.config.MinRetryWait = min
+	c.config.MaxRetryWait = max
+}
+
+// Context returns the context of the requests of this client, which is
+// context.Background unless set with WithContext.
+func (c *Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a shallow copy of the client whose requests use the
+// given context, so that they can be canceled or given a deadline. Setting
+// the token or the address of the copy does not change the original.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c2 := new(Client)
+	*c2 = *c
+	c2.ctx = ctx
+	return c2
+}
+
 // SetWrappingLookupFunc sets a lookup function that returns desired wrap TTLs
 // for a given operation and path
 func (c *Client) SetWrappingLookupFunc(lookupFunc WrappingLookupFunc) {
@@ -363,22 +423,52 @@ func (c *Client) NewRequest(method, path string) *Request {
 // a Vault server not configured with this client. This is an advanced operation
 // that generally won't need to be called externally.
 func (c *Client) RawRequest(r *Request) (*Response, error) {
+	return c.RawRequestWithContext(c.Context(), r)
+}
+
+// RawRequestWithContext performs the raw request given with the given
+// context, which cancels the request and its retries when done.
+func (c *Client) RawRequestWithContext(ctx context.Context, r *Request) (*Response, error) {
+	// The body is read again on each attempt
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+	}
+
 	redirectCount := 0
+	retryCount := 0
 START:
+	if r.Body != nil {
+		r.Body = bytes.NewReader(body)
+	}
 	req, err := r.ToHTTP()
 	if err != nil {
 		return nil, err
 	}
-
-	client := pester.NewExtendedClient(c.config.HttpClient)
-	client.Backoff = pester.LinearJitterBackoff
-	client.MaxRetries = c.config.MaxRetries
+	req = req.WithContext(ctx)
 
 	var result *Response
-	resp, err := client.Do(req)
+	resp, err := c.config.HttpClient.Do(req)
 	if resp != nil {
 		result = &Response{Response: resp}
 	}
+
+	if retryCount < c.config.MaxRetries && shouldRetry(ctx, r.Method, resp, err) {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		retryCount++
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.retryWait(retryCount)):
+		}
+		goto START
+	}
+
 	if err != nil {
 		if strings.Contains(err.Error(), "tls: oversized") {
 			err = fmt.Errorf(
@@ -412,11 +502,6 @@ START:
 		// Update the request
 		r.URL = respLoc
 
-		// Reset the request body if any
-		if err := r.ResetJSONBody(); err != nil {
-			return result, err
-		}
-
 		// Retry the request
 		redirectCount++
 		goto START
@@ -428,3 +513,58 @@ START:
 
 	return result, nil
 }
+
+// retryWait returns the exponential backoff before the given retry, with up
+// to half of it replaced by jitter
+func (c *Client) retryWait(retry int) time.Duration {
+	min, max := c.config.MinRetryWait, c.config.MaxRetryWait
+	if min <= 0 {
+		min = time.Second
+	}
+	if max < min {
+		max = min
+	}
+
+	wait := max
+	if retry < 32 && min<<uint(retry-1) < max {
+		wait = min << uint(retry-1)
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// shouldRetry returns whether a request with the given method should be sent
+// again after the given response or error. Requests with methods that are
+// not idempotent are only retried when they could not be sent.
+func shouldRetry(ctx context.Context, method string, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return isIdempotent(method) || isDialError(err)
+	}
+
+	return isIdempotent(method) && resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+// isIdempotent returns whether requests with the given method can be sent
+// more than once
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "LIST", "DELETE":
+		return true
+	default:
+		return false
+	}
+}
+
+// isDialError returns whether the error happened while connecting to the
+// server, before the request was sent
+func isDialError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
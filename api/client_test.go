@@ -2,10 +2,16 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -123,6 +129,89 @@ func TestClientRedirect(t *testing.T) {
 	}
 }
 
+func TestClientRetry(t *testing.T) {
+	var requests int32
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if atomic.AddInt32(&requests, 1)%3 != 0 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write(body)
+	}
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetRetryWait(time.Millisecond, 5*time.Millisecond)
+
+	// Idempotent requests are retried with their body
+	r := client.NewRequest("DELETE", "/v1/secret/foo")
+	if err := r.SetJSONBody(map[string]string{"foo": "bar"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp, err := client.RawRequest(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var buf bytes.Buffer
+	io.Copy(&buf, resp.Body)
+	resp.Body.Close()
+	if buf.String() != "{\"foo\":\"bar\"}\n" || atomic.LoadInt32(&requests) != 3 {
+		t.Fatalf("bad: %d %s", requests, buf.String())
+	}
+
+	// Other requests are not
+	if _, err := client.RawRequest(client.NewRequest("PUT", "/v1/secret/foo")); err == nil {
+		t.Fatalf("expected error")
+	}
+	if atomic.LoadInt32(&requests) != 4 {
+		t.Fatalf("bad: %d", requests)
+	}
+
+	// Retries stop at the maximum
+	client.SetMaxRetries(0)
+	if _, err := client.RawRequest(client.NewRequest("GET", "/v1/secret/foo")); err == nil {
+		t.Fatalf("expected error")
+	}
+	if atomic.LoadInt32(&requests) != 5 {
+		t.Fatalf("bad: %d", requests)
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(503)
+	}
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetMaxRetries(100)
+
+	// The retries are canceled with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Logical().WithContext(ctx).Read("secret/foo"); err != context.DeadlineExceeded {
+		t.Fatalf("bad: %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("request not canceled")
+	}
+
+	// The original client is unchanged
+	if client.Context() != context.Background() {
+		t.Fatalf("bad: %v", client.Context())
+	}
+}
+
 func TestClientEnvSettings(t *testing.T) {
 	cwd, _ := os.Getwd()
 	oldCACert := os.Getenv(EnvVaultCACert)
@@ -160,3 +249,40 @@ func TestClientEnvSettings(t *testing.T) {
 		t.Fatalf("bad: %v", tlsConfig.InsecureSkipVerify)
 	}
 }
+
+func TestClientUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-api")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "vault.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.URL.Path))
+	}))
+
+	config := DefaultConfig()
+	config.Address = "unix://" + socket
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resp, err := client.RawRequest(client.NewRequest("GET", "/v1/sys/health"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	io.Copy(&buf, resp.Body)
+	if buf.String() != "/v1/sys/health" {
+		t.Fatalf("Bad: %s", buf.String())
+	}
+}
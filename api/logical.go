@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -43,9 +44,20 @@ func (c *Client) Logical() *Logical {
 	return &Logical{c: c}
 }
 
+// WithContext returns the logical-backend API calls of a copy of the client
+// whose requests use the given context.
+func (c *Logical) WithContext(ctx context.Context) *Logical {
+	return &Logical{c: c.c.WithContext(ctx)}
+}
+
 func (c *Logical) Read(path string) (*Secret, error) {
+	return c.ReadWithContext(c.c.Context(), path)
+}
+
+// ReadWithContext is Read with a context that cancels the request
+func (c *Logical) ReadWithContext(ctx context.Context, path string) (*Secret, error) {
 	r := c.c.NewRequest("GET", "/v1/"+path)
-	resp, err := c.c.RawRequest(r)
+	resp, err := c.c.RawRequestWithContext(ctx, r)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -60,12 +72,17 @@ func (c *Logical) Read(path string) (*Secret, error) {
 }
 
 func (c *Logical) List(path string) (*Secret, error) {
+	return c.ListWithContext(c.c.Context(), path)
+}
+
+// ListWithContext is List with a context that cancels the request
+func (c *Logical) ListWithContext(ctx context.Context, path string) (*Secret, error) {
 	r := c.c.NewRequest("LIST", "/v1/"+path)
 	// Set this for broader compatibility, but we use LIST above to be able to
 	// handle the wrapping lookup function
 	r.Method = "GET"
 	r.Params.Set("list", "true")
-	resp, err := c.c.RawRequest(r)
+	resp, err := c.c.RawRequestWithContext(ctx, r)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -80,12 +97,17 @@ func (c *Logical) List(path string) (*Secret, error) {
 }
 
 func (c *Logical) Write(path string, data map[string]interface{}) (*Secret, error) {
+	return c.WriteWithContext(c.c.Context(), path, data)
+}
+
+// WriteWithContext is Write with a context that cancels the request
+func (c *Logical) WriteWithContext(ctx context.Context, path string, data map[string]interface{}) (*Secret, error) {
 	r := c.c.NewRequest("PUT", "/v1/"+path)
 	if err := r.SetJSONBody(data); err != nil {
 		return nil, err
 	}
 
-	resp, err := c.c.RawRequest(r)
+	resp, err := c.c.RawRequestWithContext(ctx, r)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -101,8 +123,13 @@ func (c *Logical) Write(path string, data map[string]interface{}) (*Secret, erro
 }
 
 func (c *Logical) Delete(path string) (*Secret, error) {
+	return c.DeleteWithContext(c.c.Context(), path)
+}
+
+// DeleteWithContext is Delete with a context that cancels the request
+func (c *Logical) DeleteWithContext(ctx context.Context, path string) (*Secret, error) {
 	r := c.c.NewRequest("DELETE", "/v1/"+path)
-	resp, err := c.c.RawRequest(r)
+	resp, err := c.c.RawRequestWithContext(ctx, r)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -118,6 +145,11 @@ func (c *Logical) Delete(path string) (*Secret, error) {
 }
 
 func (c *Logical) Unwrap(wrappingToken string) (*Secret, error) {
+	return c.UnwrapWithContext(c.c.Context(), wrappingToken)
+}
+
+// UnwrapWithContext is Unwrap with a context that cancels the requests
+func (c *Logical) UnwrapWithContext(ctx context.Context, wrappingToken string) (*Secret, error) {
 	var data map[string]interface{}
 	if wrappingToken != "" {
 		if c.c.Token() == "" {
@@ -134,7 +166,7 @@ func (c *Logical) Unwrap(wrappingToken string) (*Secret, error) {
 		return nil, err
 	}
 
-	resp, err := c.c.RawRequest(r)
+	resp, err := c.c.RawRequestWithContext(ctx, r)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -161,7 +193,7 @@ func (c *Logical) Unwrap(wrappingToken string) (*Secret, error) {
 		c.c.SetToken(wrappingToken)
 	}
 
-	secret, err := c.Read(wrappedResponseLocation)
+	secret, err := c.ReadWithContext(ctx, wrappedResponseLocation)
 	if err != nil {
 		return nil, fmt.Errorf("error reading %s: %s", wrappedResponseLocation, err)
 	}
@@ -37,12 +37,16 @@ type Secret struct {
 
 // SecretWrapInfo contains wrapping information if we have it. If what is
 // contained is an authentication token, the accessor for the token will be
-// available in WrappedAccessor.
+// available in WrappedAccessor. For the requests held by control groups, the
+// accessor of the wrapping token is available in Accessor. CreationPath is
+// the path of the request whose response is wrapped.
 type SecretWrapInfo struct {
 	Token           string    `json:"token"`
 	TTL             int       `json:"ttl"`
 	CreationTime    time.Time `json:"creation_time"`
+	CreationPath    string    `json:"creation_path"`
 	WrappedAccessor string    `json:"wrapped_accessor"`
+	Accessor        string    `json:"accessor"`
 }
 
 // SecretAuth is the structure containing auth information if we have it.
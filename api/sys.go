@@ -1,5 +1,7 @@
 package api
 
+import "context"
+
 // Sys is used to perform system-related operations on Vault.
 type Sys struct {
 	c *Client
@@ -9,3 +11,10 @@ type Sys struct {
 func (c *Client) Sys() *Sys {
 	return &Sys{c: c}
 }
+
+// WithContext returns the sys-related API calls of a copy of the client
+// whose requests use the given context, so that they can be canceled or
+// given a deadline.
+func (c *Sys) WithContext(ctx context.Context) *Sys {
+	return &Sys{c: c.c.WithContext(ctx)}
+}
@@ -0,0 +1,79 @@
+package api
+
+import "time"
+
+// Activity returns the distinct clients of the auth mounts between start and
+// end. Zero times use the defaults of the server.
+func (c *Sys) Activity(start, end time.Time) (*Activity, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/internal/counters/activity")
+	if !start.IsZero() {
+		r.Params.Set("start_time", start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		r.Params.Set("end_time", end.Format(time.RFC3339))
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data *Activity `json:"data"`
+	}
+	err = resp.DecodeJSON(&result)
+	return result.Data, err
+}
+
+func (c *Sys) ActivityConfig() (*ActivityConfig, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/internal/counters/config")
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data *ActivityConfig `json:"data"`
+	}
+	err = resp.DecodeJSON(&result)
+	return result.Data, err
+}
+
+func (c *Sys) ConfigureActivity(config *ActivityConfig) error {
+	r := c.c.NewRequest("PUT", "/v1/sys/internal/counters/config")
+	if err := r.SetJSONBody(config); err != nil {
+		return err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+type Activity struct {
+	StartTime time.Time        `json:"start_time"`
+	EndTime   time.Time        `json:"end_time"`
+	Total     *ActivityCounts  `json:"total"`
+	ByMount   []*MountActivity `json:"by_mount"`
+}
+
+type MountActivity struct {
+	MountPath string          `json:"mount_path"`
+	Counts    *ActivityCounts `json:"counts"`
+}
+
+type ActivityCounts struct {
+	DistinctEntities int `json:"distinct_entities"`
+	NonEntityTokens  int `json:"non_entity_tokens"`
+	Clients          int `json:"clients"`
+}
+
+type ActivityConfig struct {
+	Enabled           bool `json:"enabled"`
+	RetentionDays     int  `json:"retention_days"`
+	DefaultReportDays int  `json:"default_report_days"`
+}
@@ -1,5 +1,7 @@
 package api
 
+import "fmt"
+
 func (c *Sys) Renew(id string, increment int) (*Secret, error) {
 	r := c.c.NewRequest("PUT", "/v1/sys/renew")
 
@@ -46,3 +48,54 @@ func (c *Sys) RevokeForce(id string) error {
 	}
 	return err
 }
+
+// RevokePrefixAsync starts revoking the leases under the prefix in the
+// background, forcing the revocation if force is set, and returns the ID of
+// the job reporting its progress
+func (c *Sys) RevokePrefixAsync(id string, force bool) (string, error) {
+	path := "/v1/sys/revoke-prefix/"
+	if force {
+		path = "/v1/sys/revoke-force/"
+	}
+	r := c.c.NewRequest("PUT", path+id)
+	if err := r.SetJSONBody(map[string]interface{}{"sync": false}); err != nil {
+		return "", err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("no job ID returned")
+	}
+	jobID, _ := secret.Data["job_id"].(string)
+	if jobID == "" {
+		return "", fmt.Errorf("no job ID returned")
+	}
+	return jobID, nil
+}
+
+// RevokeJob returns the status of a revocation job started by
+// RevokePrefixAsync, or nil if the job is unknown
+func (c *Sys) RevokeJob(jobID string) (*Secret, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/revoke-jobs/"+jobID)
+	resp, err := c.c.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSecret(resp.Body)
+}
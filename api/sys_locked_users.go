@@ -0,0 +1,32 @@
+package api
+
+import "fmt"
+
+func (c *Sys) LockedUsers() (map[string]map[string]*LockedUser, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/locked-users")
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data map[string]map[string]*LockedUser `json:"data"`
+	}
+	err = resp.DecodeJSON(&result)
+	return result.Data, err
+}
+
+func (c *Sys) UnlockUser(mount, username string) error {
+	r := c.c.NewRequest("POST", fmt.Sprintf("/v1/sys/locked-users/%s/unlock/%s", mount, username))
+	resp, err := c.c.RawRequest(r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+type LockedUser struct {
+	FailedLoginAttempts int    `json:"failed_login_attempts"`
+	LockedUntil         string `json:"locked_until"`
+}
@@ -24,7 +24,43 @@ func (c *Sys) KeyStatus() (*KeyStatus, error) {
 	return result, err
 }
 
+func (c *Sys) RotateConfig() (*RotateConfig, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/rotate/config")
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data *RotateConfig `json:"data"`
+	}
+	err = resp.DecodeJSON(&result)
+	return result.Data, err
+}
+
+func (c *Sys) ConfigureRotate(config *RotateConfig) error {
+	r := c.c.NewRequest("POST", "/v1/sys/rotate/config")
+	if err := r.SetJSONBody(config); err != nil {
+		return err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
 type KeyStatus struct {
-	Term        int       `json:"term"`
-	InstallTime time.Time `json:"install_time"`
+	Term             int       `json:"term"`
+	InstallTime      time.Time `json:"install_time"`
+	Encryptions      uint64    `json:"encryptions"`
+	NextRotationTime time.Time `json:"next_rotation_time"`
+}
+
+type RotateConfig struct {
+	Enabled       bool   `json:"enabled"`
+	MaxOperations uint64 `json:"max_operations"`
+	Interval      int64  `json:"interval"`
 }
@@ -36,6 +36,24 @@ func (c *Sys) Unseal(shard string) (*SealStatusResponse, error) {
 	return sealStatusRequest(c, r)
 }
 
+// UnsealOpts are the options of an unseal request
+type UnsealOpts struct {
+	Key     string `json:"key"`
+	Reset   bool   `json:"reset"`
+	Migrate bool   `json:"migrate"`
+}
+
+// UnsealWithOptions provides a key part to unseal the Vault, with the
+// migrate option to migrate it from a previous seal
+func (c *Sys) UnsealWithOptions(opts *UnsealOpts) (*SealStatusResponse, error) {
+	r := c.c.NewRequest("PUT", "/v1/sys/unseal")
+	if err := r.SetJSONBody(opts); err != nil {
+		return nil, err
+	}
+
+	return sealStatusRequest(c, r)
+}
+
 func sealStatusRequest(c *Sys, r *Request) (*SealStatusResponse, error) {
 	resp, err := c.c.RawRequest(r)
 	if err != nil {
@@ -54,6 +72,7 @@ type SealStatusResponse struct {
 	N           int    `json:"n"`
 	Progress    int    `json:"progress"`
 	Nonce       string `json:"nonce"`
+	Migration   bool   `json:"migration,omitempty"`
 	Version     string `json:"version"`
 	ClusterName string `json:"cluster_name,omitempty"`
 	ClusterID   string `json:"cluster_id,omitempty"`
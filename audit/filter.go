@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/vault/helper/strutil"
+)
+
+// FilterInput holds the fields of a request the filters are evaluated
+// against
+type FilterInput struct {
+	Path        string
+	MountPath   string
+	Operation   string
+	DisplayName string
+}
+
+// filterFields maps the field names of the expressions to their values
+var filterFields = map[string]func(*FilterInput) string{
+	"path":         func(in *FilterInput) string { return in.Path },
+	"mount_path":   func(in *FilterInput) string { return in.MountPath },
+	"operation":    func(in *FilterInput) string { return in.Operation },
+	"display_name": func(in *FilterInput) string { return in.DisplayName },
+}
+
+// Filter selects the requests an audit backend logs. It is parsed from an
+// expression comparing the fields of the request to quoted values, which may
+// start or end with a '*' wildcard, combined with "and", "or", "not" and
+// parentheses, for example:
+//
+//	path == "sys/*" or (mount_path == "auth/ldap/" and operation != "read")
+type Filter struct {
+	node filterNode
+}
+
+// ParseFilter parses a filter expression
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos].text)
+	}
+	return &Filter{node: node}, nil
+}
+
+// Match returns whether the request is selected by the filter. A nil filter
+// selects every request.
+func (f *Filter) Match(in *FilterInput) bool {
+	if f == nil {
+		return true
+	}
+	return f.node.match(in)
+}
+
+type filterNode interface {
+	match(*FilterInput) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+
+func (n *filterAnd) match(in *FilterInput) bool { return n.left.match(in) && n.right.match(in) }
+
+type filterOr struct{ left, right filterNode }
+
+func (n *filterOr) match(in *FilterInput) bool { return n.left.match(in) || n.right.match(in) }
+
+type filterNot struct{ node filterNode }
+
+func (n *filterNot) match(in *FilterInput) bool { return !n.node.match(in) }
+
+type filterCompare struct {
+	field  func(*FilterInput) string
+	value  string
+	negate bool
+}
+
+func (n *filterCompare) match(in *FilterInput) bool {
+	return strutil.GlobbedStringsMatch(n.value, n.field(in)) != n.negate
+}
+
+type filterTokenType int
+
+const (
+	filterTokenWord filterTokenType = iota
+	filterTokenString
+	filterTokenOperator
+	filterTokenParen
+)
+
+type filterToken struct {
+	typ  filterTokenType
+	text string
+}
+
+// tokenizeFilter splits the expression into words, quoted strings,
+// comparison operators and parentheses
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{filterTokenParen, string(c)})
+			i++
+		case c == '=' || c == '!':
+			if i+1 >= len(expr) || expr[i+1] != '=' {
+				return nil, fmt.Errorf("invalid operator at offset %d in filter", i)
+			}
+			tokens = append(tokens, filterToken{filterTokenOperator, expr[i : i+2]})
+			i += 2
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at offset %d in filter", i)
+			}
+			value, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d in filter: %v", i, err)
+			}
+			tokens = append(tokens, filterToken{filterTokenString, value})
+			i = end + 1
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(expr) && (expr[end] == '_' || unicode.IsLetter(rune(expr[end]))) {
+				end++
+			}
+			tokens = append(tokens, filterToken{filterTokenWord, expr[i:end]})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d in filter", c, i)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	return tokens, nil
+}
+
+// filterParser parses the tokens of an expression, "and" binding tighter
+// than "or"
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// next returns the next token, or an empty one at the end of the expression
+func (p *filterParser) next() filterToken {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *filterParser) keyword(word string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].typ == filterTokenWord &&
+		strings.ToLower(p.tokens[p.pos].text) == word {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.keyword("not") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{node}, nil
+	}
+
+	t := p.next()
+	switch {
+	case t.typ == filterTokenParen && t.text == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.typ != filterTokenParen || t.text != ")" {
+			return nil, fmt.Errorf("missing ')' in filter")
+		}
+		return node, nil
+
+	case t.typ == filterTokenWord:
+		field, ok := filterFields[strings.ToLower(t.text)]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q in filter", t.text)
+		}
+		op := p.next()
+		if op.typ != filterTokenOperator {
+			return nil, fmt.Errorf("expected '==' or '!=' after %q in filter", t.text)
+		}
+		value := p.next()
+		if value.typ != filterTokenString {
+			return nil, fmt.Errorf("expected a quoted value after %q in filter", t.text+" "+op.text)
+		}
+		return &filterCompare{
+			field:  field,
+			value:  value.text,
+			negate: op.text == "!=",
+		}, nil
+
+	case t.text == "":
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	return nil, fmt.Errorf("unexpected %q in filter", t.text)
+}
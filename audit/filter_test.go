@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"testing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	in := &FilterInput{
+		Path:        "auth/ldap/login/armon",
+		MountPath:   "auth/ldap/",
+		Operation:   "update",
+		DisplayName: "ldap-armon",
+	}
+
+	cases := map[string]bool{
+		`path == "auth/ldap/login/armon"`:                            true,
+		`path == "auth/*"`:                                           true,
+		`path == "*/armon"`:                                          true,
+		`path == "sys/*"`:                                            false,
+		`path != "sys/*"`:                                            true,
+		`mount_path == "auth/ldap/"`:                                 true,
+		`operation == "read"`:                                        false,
+		`display_name == "ldap-*"`:                                   true,
+		`path == "sys/*" or path == "auth/*"`:                        true,
+		`path == "auth/*" and operation == "read"`:                   false,
+		`not operation == "read"`:                                    true,
+		`NOT (path == "sys/*" OR mount_path == "auth/ldap/")`:        false,
+		`path == "sys/*" or path == "auth/*" and operation == "x"`:   false,
+		`(path == "sys/*" or path == "auth/*") and operation != "x"`: true,
+		`display_name == "ldap-armon"`:                               true,
+	}
+	for expr, expected := range cases {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if actual := f.Match(in); actual != expected {
+			t.Fatalf("%s: expected %t", expr, expected)
+		}
+	}
+
+	// A nil filter matches everything
+	var f *Filter
+	if !f.Match(in) {
+		t.Fatalf("nil filter should match")
+	}
+}
+
+func TestParseFilter_invalid(t *testing.T) {
+	cases := []string{
+		``,
+		`path`,
+		`path ==`,
+		`path = "sys/"`,
+		`path == sys`,
+		`path == "sys/`,
+		`namespace == "ns1/"`,
+		`(path == "sys/"`,
+		`path == "sys/")`,
+		`path == "sys/" and`,
+		`path == "sys/" path == "auth/"`,
+		`path == "sys/" && path == "auth/"`,
+	}
+	for _, expr := range cases {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+}
@@ -75,17 +75,21 @@ func (f *AuditFormatter) FormatRequest(
 			}
 		}
 
-		// Cache and restore accessor in the request
+		// Cache and restore accessor and non-HMAC'd data in the request
 		var clientTokenAccessor string
 		if !config.HMACAccessor && req != nil && req.ClientTokenAccessor != "" {
 			clientTokenAccessor = req.ClientTokenAccessor
 		}
+		reqData := nonHMACData(req.Data, req.AuditNonHMACRequestKeys)
 		if err := Hash(config.Salt, req); err != nil {
 			return err
 		}
 		if clientTokenAccessor != "" {
 			req.ClientTokenAccessor = clientTokenAccessor
 		}
+		for k, v := range reqData {
+			req.Data[k] = v
+		}
 	}
 
 	// If auth is nil, make an empty one
@@ -202,36 +206,50 @@ func (f *AuditFormatter) FormatResponse(
 			}
 		}
 
-		// Cache and restore accessor in the request
+		// Cache and restore accessor and non-HMAC'd data in the request
 		var clientTokenAccessor string
 		if !config.HMACAccessor && req != nil && req.ClientTokenAccessor != "" {
 			clientTokenAccessor = req.ClientTokenAccessor
 		}
+		reqData := nonHMACData(req.Data, req.AuditNonHMACRequestKeys)
 		if err := Hash(config.Salt, req); err != nil {
 			return err
 		}
 		if clientTokenAccessor != "" {
 			req.ClientTokenAccessor = clientTokenAccessor
 		}
+		for k, v := range reqData {
+			req.Data[k] = v
+		}
 
-		// Cache and restore accessor in the response
+		// Cache and restore accessor and non-HMAC'd data in the response
 		if resp != nil {
-			var accessor, wrappedAccessor string
+			var accessor, wrappedAccessor, wrappingAccessor string
 			if !config.HMACAccessor && resp != nil && resp.Auth != nil && resp.Auth.Accessor != "" {
 				accessor = resp.Auth.Accessor
 			}
 			if !config.HMACAccessor && resp != nil && resp.WrapInfo != nil && resp.WrapInfo.WrappedAccessor != "" {
 				wrappedAccessor = resp.WrapInfo.WrappedAccessor
 			}
+			if !config.HMACAccessor && resp != nil && resp.WrapInfo != nil && resp.WrapInfo.Accessor != "" {
+				wrappingAccessor = resp.WrapInfo.Accessor
+			}
+			respData := nonHMACData(resp.Data, req.AuditNonHMACResponseKeys)
 			if err := Hash(config.Salt, resp); err != nil {
 				return err
 			}
+			for k, v := range respData {
+				resp.Data[k] = v
+			}
 			if accessor != "" {
 				resp.Auth.Accessor = accessor
 			}
 			if wrappedAccessor != "" {
 				resp.WrapInfo.WrappedAccessor = wrappedAccessor
 			}
+			if wrappingAccessor != "" {
+				resp.WrapInfo.Accessor = wrappingAccessor
+			}
 		}
 	}
 
@@ -276,6 +294,8 @@ func (f *AuditFormatter) FormatResponse(
 			Token:           token,
 			CreationTime:    resp.WrapInfo.CreationTime.Format(time.RFC3339Nano),
 			WrappedAccessor: resp.WrapInfo.WrappedAccessor,
+			Accessor:        resp.WrapInfo.Accessor,
+			CreationPath:    resp.WrapInfo.CreationPath,
 		}
 	}
 
@@ -378,9 +398,23 @@ type AuditResponseWrapInfo struct {
 	Token           string `json:"token"`
 	CreationTime    string `json:"creation_time"`
 	WrappedAccessor string `json:"wrapped_accessor,omitempty"`
+	Accessor        string `json:"accessor,omitempty"`
+	CreationPath    string `json:"creation_path,omitempty"`
 }
 
 // getRemoteAddr safely gets the remote address avoiding a nil pointer
+// nonHMACData returns the values of the given keys of the data, which are
+// logged without hashing
+func nonHMACData(data map[string]interface{}, keys []string) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, k := range keys {
+		if v, ok := data[k]; ok {
+			values[k] = v
+		}
+	}
+	return values
+}
+
 func getRemoteAddr(req *logical.Request) string {
 	if req != nil && req.Connection != nil {
 		return req.Connection.RemoteAddr
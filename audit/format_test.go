@@ -53,3 +53,72 @@ func TestFormatResponseErrors(t *testing.T) {
 		t.Fatal("expected error due to nil writer")
 	}
 }
+
+// captureFormatWriter records the entries written to it
+type captureFormatWriter struct {
+	req  *AuditRequestEntry
+	resp *AuditResponseEntry
+}
+
+func (c *captureFormatWriter) WriteRequest(_ io.Writer, entry *AuditRequestEntry) error {
+	c.req = entry
+	return nil
+}
+
+func (c *captureFormatWriter) WriteResponse(_ io.Writer, entry *AuditResponseEntry) error {
+	c.resp = entry
+	return nil
+}
+
+func TestFormatNonHMACKeys(t *testing.T) {
+	salter, _ := salt.NewSalt(nil, nil)
+	config := FormatterConfig{
+		Salt: salter,
+	}
+	writer := &captureFormatWriter{}
+	formatter := AuditFormatter{
+		AuditFormatWriter: writer,
+	}
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "pki/issue/web",
+		Data: map[string]interface{}{
+			"common_name": "www.example.com",
+			"ttl":         "24h",
+		},
+		AuditNonHMACRequestKeys:  []string{"ttl", "missing"},
+		AuditNonHMACResponseKeys: []string{"serial_number"},
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"serial_number": "1f:2e",
+			"private_key":   "secret",
+		},
+	}
+
+	if err := formatter.FormatRequest(ioutil.Discard, config, nil, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := writer.req.Request.Data
+	if data["ttl"] != "24h" || data["common_name"] == "www.example.com" || len(data) != 2 {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	if err := formatter.FormatResponse(ioutil.Discard, config, nil, req, resp, nil); err != nil {
+		t.Fatal(err)
+	}
+	data = writer.resp.Request.Data
+	if data["ttl"] != "24h" || data["common_name"] == "www.example.com" {
+		t.Fatalf("bad: %#v", data)
+	}
+	data = writer.resp.Response.Data
+	if data["serial_number"] != "1f:2e" || data["private_key"] == "secret" {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	// The data of the request and response are untouched
+	if req.Data["common_name"] != "www.example.com" || resp.Data["private_key"] != "secret" {
+		t.Fatalf("bad: %#v %#v", req.Data, resp.Data)
+	}
+}
@@ -94,6 +94,10 @@ func Hash(salter *salt.Salt, raw interface{}) error {
 		if s.WrappedAccessor != "" {
 			s.WrappedAccessor = fn(s.WrappedAccessor)
 		}
+
+		if s.Accessor != "" {
+			s.Accessor = fn(s.Accessor)
+		}
 	}
 
 	return nil
@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/builtin/audit/webhook"
+)
+
+const (
+	// The media types of the v2 API of the Kafka REST proxy, with JSON
+	// values
+	recordsContentType = "application/vnd.kafka.json.v2+json"
+	offsetsContentType = "application/vnd.kafka.v2+json"
+)
+
+// Factory creates the audit backend publishing the entries to a Kafka topic
+// through a Kafka REST proxy. It buffers and batches the entries as the
+// webhook audit backend, and takes the same options.
+func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
+	address, err := webhook.ParseAddress(conf.Config, "address")
+	if err != nil {
+		return nil, err
+	}
+
+	topic, ok := conf.Config["topic"]
+	if !ok || topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+
+	return webhook.NewBackend(conf, &publisher{
+		url: strings.TrimSuffix(address, "/") + "/topics/" + url.PathEscape(topic),
+	})
+}
+
+// publisher produces the entries as the records of the topic
+type publisher struct {
+	url string
+}
+
+// record is a record of the topic. The entries are keyed by the ID of their
+// request, so that the request and response entries share a partition and
+// stay in order.
+type record struct {
+	Key   string          `json:"key,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+// produceResponse is the result of producing the records, which may each
+// fail
+type produceResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (p *publisher) Publish(client *http.Client, entries [][]byte) error {
+	records := make([]*record, 0, len(entries))
+	for _, entry := range entries {
+		var e struct {
+			Request struct {
+				ID string `json:"id"`
+			} `json:"request"`
+		}
+		if err := json.Unmarshal(entry, &e); err != nil {
+			return fmt.Errorf("invalid audit entry: %v", err)
+		}
+		records = append(records, &record{
+			Key:   e.Request.ID,
+			Value: json.RawMessage(bytes.TrimSpace(entry)),
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"records": records,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", recordsContentType)
+	req.Header.Set("Accept", offsetsContentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	// The whole batch is published again if any record failed, since the
+	// records are delivered at least once
+	var result produceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("error producing record: %d %s", *offset.ErrorCode, offset.Error)
+		}
+	}
+	return nil
+}
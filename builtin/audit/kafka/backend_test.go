@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/builtin/audit/webhook"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestKafka_publish(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_kafka")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A REST proxy failing the first record once
+	var l sync.Mutex
+	var keys []string
+	failed := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		l.Lock()
+		defer l.Unlock()
+
+		if req.URL.Path != "/topics/vault-audit" || req.Header.Get("Content-Type") != recordsContentType {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body struct {
+			Records []struct {
+				Key   string `json:"key"`
+				Value struct {
+					Type string `json:"type"`
+				} `json:"value"`
+			} `json:"records"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", offsetsContentType)
+		if !failed {
+			failed = true
+			fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"timeout"}]}`)
+			return
+		}
+		for _, r := range body.Records {
+			if r.Value.Type != "request" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			keys = append(keys, r.Key)
+		}
+		fmt.Fprintf(w, `{"offsets":[{"partition":0,"offset":%d,"error_code":null,"error":null}]}`, len(keys))
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	salter, _ := salt.NewSalt(nil, nil)
+	b, err := Factory(&audit.BackendConfig{
+		Salt: salter,
+		Config: map[string]string{
+			"address":        server.URL,
+			"topic":          "vault-audit",
+			"ca_cert":        caPath,
+			"batch_interval": "10ms",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &logical.Request{
+		ID:        "req-1",
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(nil, req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch is published again after the failed record
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		l.Lock()
+		n := len(keys)
+		l.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := b.(*webhook.Backend).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if len(keys) != 1 || keys[0] != "req-1" {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
+
+func TestKafka_invalidConfig(t *testing.T) {
+	salter, _ := salt.NewSalt(nil, nil)
+	cases := map[string]map[string]string{
+		"no topic":     {"address": "https://127.0.0.1:8082"},
+		"http address": {"address": "http://127.0.0.1:8082", "topic": "vault-audit"},
+	}
+	for name, config := range cases {
+		if _, err := Factory(&audit.BackendConfig{Salt: salter, Config: config}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-rootcerts"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// The defaults of the batching options
+	defaultBatchSize      = 100
+	defaultBatchInterval  = time.Second
+	defaultBufferSize     = 10000
+	defaultRequestTimeout = 10 * time.Second
+
+	// The bounds of the wait between the attempts to deliver a batch
+	minRetryWait = time.Second
+	maxRetryWait = time.Minute
+)
+
+// Publisher delivers batches of audit entries. Each entry is a JSON object
+// followed by a newline.
+type Publisher interface {
+	Publish(client *http.Client, entries [][]byte) error
+}
+
+func Factory(conf *audit.BackendConfig) (audit.Backend, error) {
+	address, err := ParseAddress(conf.Config, "address")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBackend(conf, &webhookPublisher{
+		address: address,
+	})
+}
+
+// ParseAddress returns the HTTPS URL of the option
+func ParseAddress(config map[string]string, option string) (string, error) {
+	address, ok := config[option]
+	if !ok {
+		return "", fmt.Errorf("%s is required", option)
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %v", option, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("%s must be an https URL", option)
+	}
+	return address, nil
+}
+
+// NewBackend returns a backend which buffers the entries and delivers them in
+// batches with the publisher. It parses the formatting, batching and TLS
+// options of the configuration.
+func NewBackend(conf *audit.BackendConfig, publisher Publisher) (*Backend, error) {
+	if conf.Salt == nil {
+		return nil, fmt.Errorf("nil salt passed in")
+	}
+
+	// Check if hashing of accessor is disabled
+	hmacAccessor := true
+	if hmacAccessorRaw, ok := conf.Config["hmac_accessor"]; ok {
+		value, err := strconv.ParseBool(hmacAccessorRaw)
+		if err != nil {
+			return nil, err
+		}
+		hmacAccessor = value
+	}
+
+	// Check if raw logging is enabled
+	logRaw := false
+	if raw, ok := conf.Config["log_raw"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		logRaw = b
+	}
+
+	b := &Backend{
+		publisher: publisher,
+		formatConfig: audit.FormatterConfig{
+			Raw:          logRaw,
+			Salt:         conf.Salt,
+			HMACAccessor: hmacAccessor,
+		},
+		config:         conf.Config,
+		batchSize:      defaultBatchSize,
+		batchInterval:  defaultBatchInterval,
+		bufferSize:     defaultBufferSize,
+		requestTimeout: defaultRequestTimeout,
+		notifyCh:       make(chan struct{}, 1),
+		doneCh:         make(chan struct{}),
+	}
+	b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{}
+
+	for option, value := range map[string]*int{
+		"batch_size":  &b.batchSize,
+		"buffer_size": &b.bufferSize,
+	} {
+		raw, ok := conf.Config[option]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s must be a positive integer", option)
+		}
+		*value = n
+	}
+	if b.bufferSize < b.batchSize {
+		return nil, fmt.Errorf("buffer_size cannot be less than batch_size")
+	}
+
+	for option, value := range map[string]*time.Duration{
+		"batch_interval":  &b.batchInterval,
+		"request_timeout": &b.requestTimeout,
+	} {
+		raw, ok := conf.Config[option]
+		if !ok {
+			continue
+		}
+		d, err := parseutil.ParseDurationSecond(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s must be a positive duration", option)
+		}
+		*value = d
+	}
+
+	client, err := b.newClient()
+	if err != nil {
+		return nil, err
+	}
+	b.client = client
+
+	b.wg.Add(1)
+	go b.run()
+
+	return b, nil
+}
+
+// Backend is the audit backend delivering the entries over HTTPS. The entries
+// are accepted once they are buffered, and logging fails only when the buffer
+// is full because the entries cannot be delivered.
+type Backend struct {
+	publisher Publisher
+
+	formatter    audit.AuditFormatter
+	formatConfig audit.FormatterConfig
+
+	config         map[string]string
+	batchSize      int
+	batchInterval  time.Duration
+	bufferSize     int
+	requestTimeout time.Duration
+
+	l      sync.Mutex
+	client *http.Client
+	buffer [][]byte
+
+	notifyCh chan struct{}
+	doneCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newClient creates a client with the TLS options of the configuration
+func (b *Backend) newClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: b.config["tls_server_name"],
+	}
+	if err := rootcerts.ConfigureTLS(tlsConfig, &rootcerts.Config{
+		CAFile: b.config["ca_cert"],
+		CAPath: b.config["ca_path"],
+	}); err != nil {
+		return nil, fmt.Errorf("error loading CA certificates: %v", err)
+	}
+
+	if raw, ok := b.config["tls_skip_verify"]; ok {
+		skipVerify, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls_skip_verify: %v", err)
+		}
+		tlsConfig.InsecureSkipVerify = skipVerify
+	}
+
+	certFile, keyFile := b.config["client_cert"], b.config["client_key"]
+	switch {
+	case certFile != "" && keyFile != "":
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case certFile != "" || keyFile != "":
+		return nil, fmt.Errorf("client_cert and client_key must be set together")
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{
+		Transport: transport,
+		Timeout:   b.requestTimeout,
+	}, nil
+}
+
+func (b *Backend) GetHash(data string) string {
+	return audit.HashString(b.formatConfig.Salt, data)
+}
+
+func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request, outerErr error) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, b.formatConfig, auth, req, outerErr); err != nil {
+		return err
+	}
+	return b.enqueue(buf.Bytes())
+}
+
+func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request,
+	resp *logical.Response, outerErr error) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, b.formatConfig, auth, req, resp, outerErr); err != nil {
+		return err
+	}
+	return b.enqueue(buf.Bytes())
+}
+
+// enqueue buffers the entry, and wakes up the delivery once a batch is full
+func (b *Backend) enqueue(entry []byte) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if len(b.buffer) >= b.bufferSize {
+		return fmt.Errorf("audit buffer is full, %d entries are waiting to be delivered", len(b.buffer))
+	}
+	b.buffer = append(b.buffer, entry)
+
+	if len(b.buffer) >= b.batchSize {
+		select {
+		case b.notifyCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// run delivers the buffered entries once a batch is full or at the batch
+// interval, waiting longer between the attempts while the delivery fails
+func (b *Backend) run() {
+	defer b.wg.Done()
+
+	wait := b.batchInterval
+	retryWait := minRetryWait
+	notifyCh := b.notifyCh
+	for {
+		select {
+		case <-b.doneCh:
+			return
+		case <-notifyCh:
+		case <-time.After(wait):
+		}
+
+		wait = b.batchInterval
+		notifyCh = b.notifyCh
+		for {
+			n, err := b.deliver()
+			if err != nil {
+				// Full batches don't cut the wait short while failing
+				wait = retryWait
+				notifyCh = nil
+				retryWait *= 2
+				if retryWait > maxRetryWait {
+					retryWait = maxRetryWait
+				}
+				break
+			}
+			retryWait = minRetryWait
+
+			// Deliver the full batches right away
+			if n < b.batchSize || b.buffered() < b.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliver publishes the oldest batch of entries, which are removed from the
+// buffer once they are delivered. It returns the number of entries
+// delivered.
+func (b *Backend) deliver() (int, error) {
+	b.l.Lock()
+	n := len(b.buffer)
+	if n > b.batchSize {
+		n = b.batchSize
+	}
+	batch := b.buffer[:n:n]
+	client := b.client
+	b.l.Unlock()
+
+	if n == 0 {
+		return 0, nil
+	}
+	if err := b.publisher.Publish(client, batch); err != nil {
+		return 0, err
+	}
+
+	b.l.Lock()
+	b.buffer = b.buffer[n:]
+	b.l.Unlock()
+	return n, nil
+}
+
+// buffered returns the number of entries waiting to be delivered
+func (b *Backend) buffered() int {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return len(b.buffer)
+}
+
+// Reload creates a new client, loading the certificates again
+func (b *Backend) Reload() error {
+	client, err := b.newClient()
+	if err != nil {
+		return err
+	}
+
+	b.l.Lock()
+	old := b.client
+	b.client = client
+	b.l.Unlock()
+
+	if transport, ok := old.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Close stops the delivery after a last attempt to deliver the buffered
+// entries
+func (b *Backend) Close() error {
+	close(b.doneCh)
+	b.wg.Wait()
+
+	for {
+		n, err := b.deliver()
+		if err != nil {
+			return fmt.Errorf("%d audit entries were not delivered: %v", b.buffered(), err)
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+// webhookPublisher POSTs the entries to the address, one per line
+type webhookPublisher struct {
+	address string
+}
+
+func (p *webhookPublisher) Publish(client *http.Client, entries [][]byte) error {
+	resp, err := client.Post(p.address, "application/x-ndjson", bytes.NewReader(bytes.Join(entries, nil)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testReceiver records the entries POSTed to it, failing the first requests
+type testReceiver struct {
+	l        sync.Mutex
+	failures int
+	batches  [][]string
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var batch []string
+	scanner := bufio.NewScanner(req.Body)
+	for scanner.Scan() {
+		var entry struct {
+			Request struct {
+				ID string `json:"id"`
+			} `json:"request"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batch = append(batch, entry.Request.ID)
+	}
+	r.batches = append(r.batches, batch)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *testReceiver) received() [][]string {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return append([][]string{}, r.batches...)
+}
+
+// testServer starts a TLS server and returns the configuration trusting its
+// certificate
+func testServer(t *testing.T, handler http.Handler, dir string) (*httptest.Server, map[string]string) {
+	server := httptest.NewTLSServer(handler)
+
+	caPath := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]})
+	if err := ioutil.WriteFile(caPath, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return server, map[string]string{
+		"address": server.URL,
+		"ca_cert": caPath,
+	}
+}
+
+func testBackend(t *testing.T, config map[string]string) *Backend {
+	salter, _ := salt.NewSalt(nil, nil)
+	b, err := Factory(&audit.BackendConfig{
+		Salt:   salter,
+		Config: config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*Backend)
+}
+
+func logRequests(t *testing.T, b *Backend, ids ...string) {
+	for _, id := range ids {
+		req := &logical.Request{
+			ID:        id,
+			Operation: logical.ReadOperation,
+			Path:      "secret/foo",
+		}
+		if err := b.LogRequest(nil, req, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWebhook_batches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	receiver := &testReceiver{}
+	server, config := testServer(t, receiver, dir)
+	defer server.Close()
+
+	// Full batches are delivered right away, and the rest at the interval
+	config["batch_size"] = "2"
+	config["batch_interval"] = "1h"
+	b := testBackend(t, config)
+	logRequests(t, b, "a", "b", "c")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(receiver.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := receiver.received()
+	if len(batches) != 2 || len(batches[0]) != 2 || batches[0][0] != "a" || batches[0][1] != "b" ||
+		len(batches[1]) != 1 || batches[1][0] != "c" {
+		t.Fatalf("bad: %#v", batches)
+	}
+}
+
+func TestWebhook_retry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	receiver := &testReceiver{failures: 1}
+	server, config := testServer(t, receiver, dir)
+	defer server.Close()
+
+	config["batch_interval"] = "10ms"
+	b := testBackend(t, config)
+	defer b.Close()
+	logRequests(t, b, "a")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(receiver.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	batches := receiver.received()
+	if len(batches) != 1 || len(batches[0]) != 1 || batches[0][0] != "a" {
+		t.Fatalf("bad: %#v", batches)
+	}
+}
+
+func TestWebhook_bufferFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	receiver := &testReceiver{failures: 1000}
+	server, config := testServer(t, receiver, dir)
+	defer server.Close()
+
+	config["batch_size"] = "2"
+	config["buffer_size"] = "2"
+	b := testBackend(t, config)
+	logRequests(t, b, "a", "b")
+
+	req := &logical.Request{
+		ID:        "c",
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(nil, req, nil); err == nil {
+		t.Fatalf("expected error with a full buffer")
+	}
+	if err := b.Close(); err == nil {
+		t.Fatalf("expected error for undelivered entries")
+	}
+}
+
+func TestWebhook_clientCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A self-signed client certificate, trusted by the server
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "vault"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	if err := ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	receiver := &testReceiver{}
+	server := httptest.NewUnstartedServer(receiver)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]string{
+		"address": server.URL,
+		"ca_cert": caPath,
+	}
+
+	// Without the client certificate the handshake fails
+	b := testBackend(t, config)
+	logRequests(t, b, "a")
+	if err := b.Close(); err == nil {
+		t.Fatalf("expected error without client certificate")
+	}
+
+	config["client_cert"] = certPath
+	config["client_key"] = keyPath
+	b = testBackend(t, config)
+	logRequests(t, b, "b")
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	batches := receiver.received()
+	if len(batches) != 1 || len(batches[0]) != 1 || batches[0][0] != "b" {
+		t.Fatalf("bad: %#v", batches)
+	}
+}
+
+func TestWebhook_invalidConfig(t *testing.T) {
+	salter, _ := salt.NewSalt(nil, nil)
+	cases := map[string]map[string]string{
+		"no address":   {},
+		"http address": {"address": "http://127.0.0.1:8080/audit"},
+		"bad batch":    {"address": "https://127.0.0.1:8080/audit", "batch_size": "0"},
+		"small buffer": {"address": "https://127.0.0.1:8080/audit", "batch_size": "10", "buffer_size": "5"},
+		"bad interval": {"address": "https://127.0.0.1:8080/audit", "batch_interval": "soon"},
+		"no key":       {"address": "https://127.0.0.1:8080/audit", "client_cert": "/tmp/cert.pem"},
+	}
+	for name, config := range cases {
+		if _, err := Factory(&audit.BackendConfig{Salt: salter, Config: config}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -563,6 +563,7 @@ func (b *backend) pathRoleSecretIDList(req *logical.Request, data *framework.Fie
 	}
 
 	var listItems []string
+	keyInfo := map[string]interface{}{}
 	for _, secretIDHMAC := range secretIDHMACs {
 		// For sanity
 		if secretIDHMAC == "" {
@@ -594,10 +595,21 @@ func (b *backend) pathRoleSecretIDList(req *logical.Request, data *framework.Fie
 			return nil, err
 		}
 		listItems = append(listItems, result.SecretIDAccessor)
+		keyInfo[result.SecretIDAccessor] = map[string]interface{}{
+			"secret_id_num_uses": result.SecretIDNumUses,
+			"creation_time":      result.CreationTime.Format(time.RFC3339Nano),
+			"expiration_time":    result.ExpirationTime.Format(time.RFC3339Nano),
+			"metadata":           result.Metadata,
+			"cidr_list":          result.CIDRList,
+		}
 		secretIDLock.RUnlock()
 	}
 
-	return logical.ListResponse(listItems), nil
+	resp := logical.ListResponse(listItems)
+	if len(listItems) != 0 {
+		resp.Data["key_info"] = keyInfo
+	}
+	return resp, nil
 }
 
 // validateRoleConstraints checks if the role has at least one constraint
@@ -2100,7 +2112,11 @@ this endpoint.`,
 just this role and none else. The properties of this SecretID will be
 based on the options set on the role. It will expire after a period
 defined by the 'secret_id_ttl' option on the role and/or the backend
-mount's maximum TTL value.`,
+mount's maximum TTL value.
+
+Listing this endpoint returns the accessors of the SecretIDs of the role.
+The 'key_info' field maps them to the remaining number of uses, the creation
+and expiration times, the metadata and the CIDR blocks of the SecretIDs.`,
 	},
 	"role-custom-secret-id": {
 		"Assign a SecretID of choice against the role.",
@@ -489,6 +489,83 @@ func TestAppRoleRoleListSecretID(t *testing.T) {
 	}
 }
 
+func TestAppRole_RoleSecretIDListKeyInfo(t *testing.T) {
+	var resp *logical.Response
+	var err error
+	b, storage := createBackendWithStorage(t)
+
+	createRole(t, b, storage, "role1", "a,b")
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Storage:   storage,
+		Path:      "role/role1/role-id",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	roleID := resp.Data["role_id"]
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Path:      "role/role1/secret-id",
+		Data: map[string]interface{}{
+			"metadata":  `{"env": "test"}`,
+			"cidr_list": "127.0.0.1/32",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	secretID := resp.Data["secret_id"]
+	accessor := resp.Data["secret_id_accessor"].(string)
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Path:      "login",
+		Data: map[string]interface{}{
+			"role_id":   roleID,
+			"secret_id": secretID,
+		},
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth == nil || resp.Auth.Metadata["env"] != "test" {
+		t.Fatalf("bad: expected the metadata of the SecretID on the token: resp:%#v", resp)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Storage:   storage,
+		Path:      "role/role1/secret-id",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	keyInfo := resp.Data["key_info"].(map[string]interface{})
+	info, ok := keyInfo[accessor].(map[string]interface{})
+	if !ok {
+		t.Fatalf("bad: key_info of the SecretID missing: %#v", keyInfo)
+	}
+	if info["secret_id_num_uses"] != 9 {
+		t.Fatalf("bad: secret_id_num_uses: expected:9 actual:%v", info["secret_id_num_uses"])
+	}
+	if info["metadata"].(map[string]string)["env"] != "test" {
+		t.Fatalf("bad: metadata: %#v", info["metadata"])
+	}
+	if cidrs := info["cidr_list"].([]string); len(cidrs) != 1 || cidrs[0] != "127.0.0.1/32" {
+		t.Fatalf("bad: cidr_list: %#v", cidrs)
+	}
+	if info["expiration_time"] == "" {
+		t.Fatal("bad: expiration_time missing")
+	}
+}
+
 func TestAppRole_RoleList(t *testing.T) {
 	var resp *logical.Response
 	var err error
@@ -1389,3 +1389,22 @@ func TestBackend_pathStsConfig(t *testing.T) {
 		t.Fatalf("no entries should be present")
 	}
 }
+
+func TestBackend_stsEndpoint(t *testing.T) {
+	cases := []struct {
+		config   *clientConfig
+		region   string
+		expected string
+	}{
+		{nil, "us-west-2", ""},
+		{&clientConfig{}, "us-west-2", ""},
+		{&clientConfig{STSRegionalEndpoints: true}, "us-west-2", "https://sts.us-west-2.amazonaws.com"},
+		{&clientConfig{STSRegionalEndpoints: true}, "cn-north-1", "https://sts.cn-north-1.amazonaws.com.cn"},
+		{&clientConfig{STSEndpoint: "https://sts.example.com", STSRegionalEndpoints: true}, "us-west-2", "https://sts.example.com"},
+	}
+	for _, c := range cases {
+		if actual := stsEndpoint(c.config, c.region); actual != c.expected {
+			t.Fatalf("bad: config: %#v region: %s expected: %q actual: %q", c.config, c.region, c.expected, actual)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package awsec2
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
@@ -73,7 +74,17 @@ func (b *backend) getStsClientConfig(s logical.Storage, region string, stsRole s
 	if config == nil {
 		return nil, fmt.Errorf("could not compile valid credentials through the default provider chain")
 	}
-	assumedCredentials := stscreds.NewCredentials(session.New(config), stsRole)
+	clientConfig, err := b.nonLockedClientConfigEntry(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// The endpoint configured for the EC2 API calls does not apply to the
+	// STS API calls
+	stsConfig := config.Copy(&aws.Config{
+		Endpoint: aws.String(stsEndpoint(clientConfig, region)),
+	})
+	assumedCredentials := stscreds.NewCredentials(session.New(stsConfig), stsRole)
 	// Test that we actually have permissions to assume the role
 	if _, err = assumedCredentials.Get(); err != nil {
 		return nil, err
@@ -84,6 +95,24 @@ func (b *backend) getStsClientConfig(s logical.Storage, region string, stsRole s
 	return config, nil
 }
 
+// stsEndpoint returns the endpoint of the STS API calls made for the region,
+// or an empty string for the default global endpoint.
+func stsEndpoint(config *clientConfig, region string) string {
+	switch {
+	case config == nil:
+		return ""
+	case config.STSEndpoint != "":
+		return config.STSEndpoint
+	case config.STSRegionalEndpoints && region != "":
+		if strings.HasPrefix(region, "cn-") {
+			return fmt.Sprintf("https://sts.%s.amazonaws.com.cn", region)
+		}
+		return fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+	default:
+		return ""
+	}
+}
+
 // flushCachedEC2Clients deletes all the cached ec2 client objects from the backend.
 // If the client credentials configuration is deleted or updated in the backend, all
 // the cached EC2 client objects will be flushed. Config mutex lock should be
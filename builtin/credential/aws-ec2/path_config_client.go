@@ -27,6 +27,18 @@ func pathConfigClient(b *backend) *framework.Path {
 				Default:     "",
 				Description: "URL to override the default generated endpoint for making AWS EC2 API calls.",
 			},
+
+			"sts_endpoint": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "",
+				Description: "URL to override the default generated endpoint for making AWS STS API calls.",
+			},
+
+			"sts_regional_endpoints": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: "If set, the AWS STS API calls are made to the regional endpoint of the region of the instance instead of the global endpoint. Ignored if 'sts_endpoint' is set.",
+			},
 		},
 
 		ExistenceCheck: b.pathConfigClientExistenceCheck,
@@ -162,6 +174,26 @@ func (b *backend) pathConfigClientCreateUpdate(
 		configEntry.Endpoint = data.Get("endpoint").(string)
 	}
 
+	stsEndpointStr, ok := data.GetOk("sts_endpoint")
+	if ok {
+		if configEntry.STSEndpoint != stsEndpointStr.(string) {
+			changedCreds = true
+			configEntry.STSEndpoint = stsEndpointStr.(string)
+		}
+	} else if req.Operation == logical.CreateOperation {
+		configEntry.STSEndpoint = data.Get("sts_endpoint").(string)
+	}
+
+	stsRegionalEndpointsBool, ok := data.GetOk("sts_regional_endpoints")
+	if ok {
+		if configEntry.STSRegionalEndpoints != stsRegionalEndpointsBool.(bool) {
+			changedCreds = true
+			configEntry.STSRegionalEndpoints = stsRegionalEndpointsBool.(bool)
+		}
+	} else if req.Operation == logical.CreateOperation {
+		configEntry.STSRegionalEndpoints = data.Get("sts_regional_endpoints").(bool)
+	}
+
 	// Since this endpoint supports both create operation and update operation,
 	// the error checks for access_key and secret_key not being set are not present.
 	// This allows calling this endpoint multiple times to provide the values.
@@ -190,6 +222,9 @@ type clientConfig struct {
 	AccessKey string `json:"access_key" structs:"access_key" mapstructure:"access_key"`
 	SecretKey string `json:"secret_key" structs:"secret_key" mapstructure:"secret_key"`
 	Endpoint  string `json:"endpoint" structs:"endpoint" mapstructure:"endpoint"`
+
+	STSEndpoint          string `json:"sts_endpoint" structs:"sts_endpoint" mapstructure:"sts_endpoint"`
+	STSRegionalEndpoints bool   `json:"sts_regional_endpoints" structs:"sts_regional_endpoints" mapstructure:"sts_regional_endpoints"`
 }
 
 const pathConfigClientHelpSyn = `
@@ -204,4 +239,9 @@ has permission to make the following API queries:
 
 * ec2:DescribeInstances
 * iam:GetInstanceProfile (if IAM Role binding is used)
+
+The credentials of the roles assumed to query other accounts (see 'config/sts')
+are requested from the global AWS STS endpoint, unless 'sts_endpoint' is set,
+or 'sts_regional_endpoints' is set to use the STS endpoint of the region of the
+instance performing the login.
 `
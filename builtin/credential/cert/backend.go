@@ -42,6 +42,9 @@ func Backend() *backend {
 	}
 
 	b.crlUpdateMutex = &sync.RWMutex{}
+	b.revocationCacheMutex = &sync.Mutex{}
+	b.ocspCache = map[string]*cachedOCSPStatus{}
+	b.crlCache = map[string]*fetchedCRL{}
 
 	return &b
 }
@@ -52,6 +55,11 @@ type backend struct {
 
 	crls           map[string]CRLInfo
 	crlUpdateMutex *sync.RWMutex
+
+	// OCSP responses and CRLs fetched from distribution points
+	ocspCache            map[string]*cachedOCSPStatus
+	crlCache             map[string]*fetchedCRL
+	revocationCacheMutex *sync.Mutex
 }
 
 func (b *backend) invalidate(key string) {
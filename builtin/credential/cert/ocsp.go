@@ -0,0 +1,100 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspStatus is the status of a certificate returned by an OCSP responder
+type ocspStatus struct {
+	Revoked    bool
+	NextUpdate time.Time
+}
+
+// ocspClient queries OCSP responders
+var ocspClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// queryOCSP returns the status of the certificate from the OCSP responder
+func queryOCSP(server string, cert, issuer *x509.Certificate) (*ocspStatus, error) {
+	reqBytes, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ocspClient.Post(server, "application/ocsp-request", bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from OCSP responder %s", resp.StatusCode, server)
+	}
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseOCSPResponse(respBytes, cert, issuer)
+}
+
+// parseOCSPResponse verifies the OCSP response and returns the status of the
+// certificate it is for
+func parseOCSPResponse(respBytes []byte, cert, issuer *x509.Certificate) (*ocspStatus, error) {
+	// The signature is checked against the issuer, or against a responder
+	// certificate embedded in the response, which must be issued by the
+	// issuer
+	resp, err := ocsp.ParseResponseForCert(respBytes, cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCSP response: %s", err)
+	}
+	if resp.Certificate != nil && !resp.Certificate.Equal(issuer) &&
+		!hasExtKeyUsage(resp.Certificate, x509.ExtKeyUsageOCSPSigning) {
+		return nil, errors.New("OCSP responder certificate not allowed to sign OCSP responses")
+	}
+	if resp.SerialNumber == nil || resp.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		return nil, errors.New("OCSP response does not contain the certificate")
+	}
+
+	now := time.Now()
+	switch {
+	case resp.ThisUpdate.After(now.Add(time.Minute)):
+		return nil, errors.New("OCSP response not yet valid")
+	case !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now.Add(-time.Minute)):
+		return nil, errors.New("OCSP response expired")
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return &ocspStatus{NextUpdate: resp.NextUpdate}, nil
+	case ocsp.Revoked:
+		return &ocspStatus{Revoked: true, NextUpdate: resp.NextUpdate}, nil
+	default:
+		return nil, errors.New("OCSP responder does not know the certificate")
+	}
+}
+
+// hasExtKeyUsage returns whether the certificate has the extended key usage
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// ocspCacheKey returns the key of the status of the certificate in the cache
+func ocspCacheKey(cert, issuer *x509.Certificate) string {
+	hash := sha1.Sum(issuer.RawSubjectPublicKeyInfo)
+	return fmt.Sprintf("%x/%s", hash, cert.SerialNumber)
+}
@@ -61,6 +61,31 @@ seconds. Defaults to system/backend default TTL.`,
 				Description: `TTL for tokens issued by this backend.
 Defaults to system/backend default TTL time.`,
 			},
+
+			"ocsp_enabled": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to check the status of the client
+certificates with OCSP. Defaults to false.`,
+			},
+
+			"ocsp_servers_override": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the OCSP responders to
+query instead of the ones of the client certificates.`,
+			},
+
+			"ocsp_fail_open": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to allow the client certificates when
+no OCSP responder gives their status. Defaults to false.`,
+			},
+
+			"fetch_crl_distribution_points": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to fetch the CRLs of the distribution
+points of the client certificates, and reject the revoked ones. Defaults
+to false.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -125,10 +150,14 @@ func (b *backend) pathCertRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"certificate":  cert.Certificate,
-			"display_name": cert.DisplayName,
-			"policies":     strings.Join(cert.Policies, ","),
-			"ttl":          duration / time.Second,
+			"certificate":                   cert.Certificate,
+			"display_name":                  cert.DisplayName,
+			"policies":                      strings.Join(cert.Policies, ","),
+			"ttl":                           duration / time.Second,
+			"ocsp_enabled":                  cert.OCSPEnabled,
+			"ocsp_servers_override":         strings.Join(cert.OCSPServersOverride, ","),
+			"ocsp_fail_open":                cert.OCSPFailOpen,
+			"fetch_crl_distribution_points": cert.FetchCRLDistributionPoints,
 		},
 	}, nil
 }
@@ -169,6 +198,15 @@ func (b *backend) pathCertWrite(
 		Certificate: certificate,
 		DisplayName: displayName,
 		Policies:    policies,
+
+		OCSPEnabled:                d.Get("ocsp_enabled").(bool),
+		OCSPFailOpen:               d.Get("ocsp_fail_open").(bool),
+		FetchCRLDistributionPoints: d.Get("fetch_crl_distribution_points").(bool),
+	}
+	for _, server := range strings.Split(d.Get("ocsp_servers_override").(string), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			certEntry.OCSPServersOverride = append(certEntry.OCSPServersOverride, server)
+		}
 	}
 
 	// Parse the lease duration or default to backend/system default
@@ -201,6 +239,11 @@ type CertEntry struct {
 	DisplayName string
 	Policies    []string
 	TTL         time.Duration
+
+	OCSPEnabled                bool
+	OCSPServersOverride        []string
+	OCSPFailOpen               bool
+	FetchCRLDistributionPoints bool
 }
 
 const pathCertHelpSyn = `
@@ -211,6 +254,10 @@ const pathCertHelpDesc = `
 This endpoint allows you to create, read, update, and delete trusted certificates
 that are allowed to authenticate.
 
+The status of the client certificates can be checked at login with OCSP, and
+with the CRLs of their distribution points. The responses and the CRLs are
+cached until their next update.
+
 Deleting a certificate will not revoke auth for prior authenticated connections.
 To do this, do a revoke on "login". If you don't need to revoke login immediately,
 then the next renew will cause the lease to expire.
@@ -155,6 +155,11 @@ func (b *backend) verifyCredentials(req *logical.Request) (*ParsedCert, *logical
 	if len(trustedNonCAs) != 0 {
 		policy := b.matchNonCAPolicy(connState.PeerCertificates[0], trustedNonCAs)
 		if policy != nil && !b.checkForChainInCRLs(policy.Certificates) {
+			clientCert := connState.PeerCertificates[0]
+			issuer := issuerOf(clientCert, connState.PeerCertificates[1:])
+			if err := b.checkRevocation(policy.Entry, clientCert, issuer); err != nil {
+				return nil, logical.ErrorResponse(err.Error()), nil
+			}
 			return policy, nil, nil
 		}
 	}
@@ -178,7 +183,15 @@ func (b *backend) verifyCredentials(req *logical.Request) (*ParsedCert, *logical
 	}
 
 	// Match the trusted chain with the policy
-	return b.matchPolicy(trustedChains, trusted), nil, nil
+	matched := b.matchPolicy(trustedChains, trusted)
+	if matched != nil {
+		clientCert := trustedChains[0][0]
+		issuer := issuerOf(clientCert, trustedChains[0][1:])
+		if err := b.checkRevocation(matched.Entry, clientCert, issuer); err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+	}
+	return matched, nil, nil
 }
 
 // matchNonCAPolicy is used to match the client cert with the registered non-CA
@@ -0,0 +1,183 @@
+package cert
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// revocationCacheTTL is how long OCSP responses and CRLs without next
+	// update time are cached
+	revocationCacheTTL = 5 * time.Minute
+
+	// maxCRLSize is the maximum size of the fetched CRLs
+	maxCRLSize = 16 * 1024 * 1024
+)
+
+// fetchedCRL is a CRL fetched from a distribution point
+type fetchedCRL struct {
+	Serials    map[string]bool
+	Expiration time.Time
+}
+
+// cachedOCSPStatus is the status of a certificate returned by an OCSP
+// responder
+type cachedOCSPStatus struct {
+	Revoked    bool
+	Expiration time.Time
+}
+
+// crlClient fetches CRLs from distribution points
+var crlClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// checkRevocation checks the revocation status of the client certificate
+// with the OCSP responders and the CRL distribution points, as configured in
+// the entry of the trusted certificate. The issuer may be nil if it is
+// unknown.
+func (b *backend) checkRevocation(entry *CertEntry, cert, issuer *x509.Certificate) error {
+	if entry.OCSPEnabled {
+		revoked, err := b.ocspRevoked(entry, cert, issuer)
+		switch {
+		case err != nil && entry.OCSPFailOpen:
+			b.Logger().Warn("cert: failed to check OCSP status, allowing certificate", "name", entry.Name, "error", err)
+		case err != nil:
+			return fmt.Errorf("failed to check OCSP status: %s", err)
+		case revoked:
+			return errors.New("certificate revoked according to OCSP")
+		}
+	}
+
+	if entry.FetchCRLDistributionPoints {
+		for _, url := range cert.CRLDistributionPoints {
+			if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+				continue
+			}
+			if issuer == nil {
+				return errors.New("issuer of the certificate unknown, the CRL cannot be verified")
+			}
+			crl, err := b.fetchCRL(url, issuer)
+			if err != nil {
+				return fmt.Errorf("failed to fetch CRL: %s", err)
+			}
+			if crl.Serials[cert.SerialNumber.String()] {
+				return errors.New("certificate revoked according to CRL distribution point")
+			}
+		}
+	}
+
+	return nil
+}
+
+// ocspRevoked returns whether the certificate is revoked according to its
+// OCSP responders, which are queried in turn until one answers
+func (b *backend) ocspRevoked(entry *CertEntry, cert, issuer *x509.Certificate) (bool, error) {
+	if issuer == nil {
+		return false, errors.New("issuer of the certificate unknown")
+	}
+
+	key := ocspCacheKey(cert, issuer)
+	b.revocationCacheMutex.Lock()
+	cached, ok := b.ocspCache[key]
+	b.revocationCacheMutex.Unlock()
+	if ok && time.Now().Before(cached.Expiration) {
+		return cached.Revoked, nil
+	}
+
+	servers := entry.OCSPServersOverride
+	if len(servers) == 0 {
+		servers = cert.OCSPServer
+	}
+	if len(servers) == 0 {
+		return false, errors.New("no OCSP responder for the certificate")
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		status, err := queryOCSP(server, cert, issuer)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %s", server, err)
+			continue
+		}
+
+		expiration := status.NextUpdate
+		if expiration.IsZero() {
+			expiration = time.Now().Add(revocationCacheTTL)
+		}
+		b.revocationCacheMutex.Lock()
+		b.ocspCache[key] = &cachedOCSPStatus{
+			Revoked:    status.Revoked,
+			Expiration: expiration,
+		}
+		b.revocationCacheMutex.Unlock()
+
+		return status.Revoked, nil
+	}
+	return false, lastErr
+}
+
+// fetchCRL returns the CRL of the distribution point, fetching it if it is
+// not cached or needs to be updated
+func (b *backend) fetchCRL(url string, issuer *x509.Certificate) (*fetchedCRL, error) {
+	b.revocationCacheMutex.Lock()
+	cached, ok := b.crlCache[url]
+	b.revocationCacheMutex.Unlock()
+	if ok && time.Now().Before(cached.Expiration) {
+		return cached, nil
+	}
+
+	resp, err := crlClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxCRLSize))
+	if err != nil {
+		return nil, err
+	}
+
+	certList, err := x509.ParseCRL(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CRL from %s: %s", url, err)
+	}
+	if err := issuer.CheckCRLSignature(certList); err != nil {
+		return nil, fmt.Errorf("invalid signature of CRL from %s: %s", url, err)
+	}
+
+	crl := &fetchedCRL{
+		Serials:    map[string]bool{},
+		Expiration: certList.TBSCertList.NextUpdate,
+	}
+	if crl.Expiration.IsZero() {
+		crl.Expiration = time.Now().Add(revocationCacheTTL)
+	}
+	for _, revoked := range certList.TBSCertList.RevokedCertificates {
+		crl.Serials[revoked.SerialNumber.String()] = true
+	}
+
+	b.revocationCacheMutex.Lock()
+	b.crlCache[url] = crl
+	b.revocationCacheMutex.Unlock()
+
+	return crl, nil
+}
+
+// issuerOf returns the certificate of the chain which issued the certificate,
+// or nil
+func issuerOf(cert *x509.Certificate, chain []*x509.Certificate) *x509.Certificate {
+	for _, c := range chain {
+		if !c.Equal(cert) && cert.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testRevocationServer is a fake OCSP responder and CRL distribution point of
+// a CA
+type testRevocationServer struct {
+	*httptest.Server
+
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+
+	sync.Mutex
+	revoked       map[string]bool
+	ocspRequests  int
+	crlRequests   int
+	ocspResponder bool
+}
+
+func newTestRevocationServer(t *testing.T) *testRevocationServer {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testRevocationServer{
+		caCert:        caCert,
+		caKey:         caKey,
+		revoked:       map[string]bool{},
+		ocspResponder: true,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocsp", s.handleOCSP(t))
+	mux.HandleFunc("/crl", s.handleCRL(t))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// issue returns a client certificate issued by the CA
+func (s *testRevocationServer) issue(t *testing.T, serial int64) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		OCSPServer:            []string{s.URL + "/ocsp"},
+		CRLDistributionPoints: []string{s.URL + "/crl"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.caCert, key.Public(), s.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func (s *testRevocationServer) revoke(serial int64) {
+	s.Lock()
+	defer s.Unlock()
+	s.revoked[big.NewInt(serial).String()] = true
+}
+
+func (s *testRevocationServer) handleOCSP(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		defer s.Unlock()
+		s.ocspRequests++
+		if !s.ocspResponder {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+			NextUpdate:   now.Add(time.Hour),
+		}
+		if s.revoked[req.SerialNumber.String()] {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = now.Add(-time.Minute)
+		}
+		resp, err := ocsp.CreateResponse(s.caCert, s.caCert, template, s.caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}
+}
+
+func (s *testRevocationServer) handleCRL(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		defer s.Unlock()
+		s.crlRequests++
+
+		var revoked []pkix.RevokedCertificate
+		for serial := range s.revoked {
+			n, _ := new(big.Int).SetString(serial, 10)
+			revoked = append(revoked, pkix.RevokedCertificate{
+				SerialNumber:   n,
+				RevocationTime: time.Now().Add(-time.Minute),
+			})
+		}
+		crl, err := s.caCert.CreateCRL(rand.Reader, s.caKey, revoked, time.Now(), time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(crl)
+	}
+}
+
+func testRevocationBackend(t *testing.T, s *testRevocationServer, data map[string]interface{}) (logical.Backend, logical.Storage) {
+	b := testFactory(t)
+	storage := &logical.InmemStorage{}
+
+	data["certificate"] = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.caCert.Raw}))
+	data["policies"] = "foo"
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "certs/ca",
+		Storage:   storage,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v", err, resp)
+	}
+	return b, storage
+}
+
+func testRevocationLogin(t *testing.T, b logical.Backend, storage logical.Storage, cert *x509.Certificate, valid bool) {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   storage,
+		Connection: &logical.Connection{
+			ConnState: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid && (resp == nil || resp.IsError() || resp.Auth == nil) {
+		t.Fatalf("serial %s: expected login, got %#v", cert.SerialNumber, resp)
+	}
+	if !valid && (resp == nil || !resp.IsError()) {
+		t.Fatalf("serial %s: expected error, got %#v", cert.SerialNumber, resp)
+	}
+}
+
+func TestBackend_OCSP(t *testing.T) {
+	s := newTestRevocationServer(t)
+	defer s.Close()
+	good := s.issue(t, 10)
+	revoked := s.issue(t, 11)
+	s.revoke(11)
+
+	b, storage := testRevocationBackend(t, s, map[string]interface{}{
+		"ocsp_enabled": true,
+	})
+	testRevocationLogin(t, b, storage, good, true)
+	testRevocationLogin(t, b, storage, revoked, false)
+
+	// The responses are cached until their next update
+	s.revoke(10)
+	testRevocationLogin(t, b, storage, good, true)
+	if s.ocspRequests != 2 {
+		t.Fatalf("expected 2 OCSP requests, got %d", s.ocspRequests)
+	}
+
+	// The revocation status cannot be checked without a responder
+	s.ocspResponder = false
+	unknown := s.issue(t, 12)
+	testRevocationLogin(t, b, storage, unknown, false)
+
+	b, storage = testRevocationBackend(t, s, map[string]interface{}{
+		"ocsp_enabled":   true,
+		"ocsp_fail_open": true,
+	})
+	testRevocationLogin(t, b, storage, unknown, true)
+	testRevocationLogin(t, b, storage, revoked, true)
+
+	b, storage = testRevocationBackend(t, s, map[string]interface{}{
+		"ocsp_enabled":          true,
+		"ocsp_servers_override": "http://127.0.0.1:0/ocsp",
+	})
+	testRevocationLogin(t, b, storage, unknown, false)
+}
+
+func TestBackend_CRLDistributionPoints(t *testing.T) {
+	s := newTestRevocationServer(t)
+	defer s.Close()
+	good := s.issue(t, 10)
+	revoked := s.issue(t, 11)
+	s.revoke(11)
+
+	b, storage := testRevocationBackend(t, s, map[string]interface{}{})
+	testRevocationLogin(t, b, storage, revoked, true)
+	if s.crlRequests != 0 {
+		t.Fatalf("expected no CRL requests, got %d", s.crlRequests)
+	}
+
+	b, storage = testRevocationBackend(t, s, map[string]interface{}{
+		"fetch_crl_distribution_points": true,
+	})
+	testRevocationLogin(t, b, storage, good, true)
+	testRevocationLogin(t, b, storage, revoked, false)
+	if s.crlRequests != 1 {
+		t.Fatalf("expected 1 CRL request, got %d", s.crlRequests)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "certs/ca",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.Data["fetch_crl_distribution_points"] != true {
+		t.Fatalf("err:%s resp:%#v", err, resp)
+	}
+}
@@ -1,8 +1,12 @@
 package github
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -200,3 +204,120 @@ func testAccLogin(t *testing.T, policies []string) logicaltest.TestStep {
 		Check: logicaltest.TestCheckAuth(policies),
 	}
 }
+
+// testGitHubServer is a fake GitHub API returning its organizations and teams
+// one per page
+func testGitHubServer(t *testing.T) *httptest.Server {
+	pages := map[string][]interface{}{
+		"/user/orgs": {
+			map[string]interface{}{"login": "other-org", "id": 2},
+			map[string]interface{}{"login": "vault-org", "id": 1},
+		},
+		"/user/teams": {
+			map[string]interface{}{"name": "Team One", "slug": "team-one", "organization": map[string]interface{}{"id": 1}},
+			map[string]interface{}{"name": "Other", "slug": "other", "organization": map[string]interface{}{"id": 2}},
+			map[string]interface{}{"name": "Admins", "slug": "admins", "organization": map[string]interface{}{"id": 1}},
+		},
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/user" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"login": "octocat", "id": 10})
+			return
+		}
+		items, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page := 1
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		if page < len(items) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d>; rel="next"`, server.URL, r.URL.Path, page+1))
+		}
+		json.NewEncoder(w).Encode(items[page-1 : page])
+	}))
+	return server
+}
+
+func TestBackend_loginPaginationAndOrganizationID(t *testing.T) {
+	server := testGitHubServer(t)
+	defer server.Close()
+
+	b, err := Factory(&logical.BackendConfig{
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour,
+			MaxLeaseTTLVal:     time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := &logical.InmemStorage{}
+
+	write := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	write("map/teams/team-one", map[string]interface{}{"value": "one"})
+	write("map/teams/admins", map[string]interface{}{"value": "admin"})
+	write("map/teams/other", map[string]interface{}{"value": "other"})
+
+	login := func() *logical.Response {
+		return write("login", map[string]interface{}{"token": "token"})
+	}
+
+	write("config", map[string]interface{}{
+		"organization": "vault-org",
+		"base_url":     server.URL + "/",
+	})
+	resp := login()
+	if resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	policies := resp.Auth.Policies
+	sort.Strings(policies)
+	if strings.Join(policies, ",") != "admin,one" {
+		t.Fatalf("bad: policies: %v", policies)
+	}
+
+	// The organization is matched by ID, whatever its name
+	write("config", map[string]interface{}{
+		"organization":    "renamed-org",
+		"organization_id": 1,
+		"base_url":        server.URL + "/",
+	})
+	resp = login()
+	if resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	write("config", map[string]interface{}{
+		"organization":    "vault-org",
+		"organization_id": 3,
+		"base_url":        server.URL + "/",
+	})
+	resp = login()
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+
+	resp = write("config", map[string]interface{}{
+		"organization":    "vault-org",
+		"organization_id": -1,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
@@ -19,6 +19,14 @@ func pathConfig(b *backend) *framework.Path {
 				Description: "The organization users must be part of",
 			},
 
+			"organization_id": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The ID of the organization users must be
+part of. If set, the organization is matched by ID instead
+of by name, so that it cannot be replaced by another
+organization taking its name after a rename.`,
+			},
+
 			"base_url": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `The API endpoint to use. Useful if you
@@ -45,6 +53,10 @@ API-compatible authentication server.`,
 func (b *backend) pathConfigWrite(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	organization := data.Get("organization").(string)
+	organizationID := data.Get("organization_id").(int)
+	if organizationID < 0 {
+		return logical.ErrorResponse("organization_id cannot be negative"), nil
+	}
 	baseURL := data.Get("base_url").(string)
 	if len(baseURL) != 0 {
 		_, err := url.Parse(baseURL)
@@ -78,6 +90,7 @@ func (b *backend) pathConfigWrite(
 
 	entry, err := logical.StorageEntryJSON("config", config{
 		Organization:	organization,
+		OrganizationID:	int64(organizationID),
 		BaseURL: 	baseURL,
 		TTL:     	ttl,
 		MaxTTL:  	maxTTL,
@@ -131,8 +144,9 @@ func (b *backend) Config(s logical.Storage) (*config, error) {
 }
 
 type config struct {
-	Organization string        `json:"organization" structs:"organization" mapstructure:"organization"`
-	BaseURL      string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
-	TTL          time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
-	MaxTTL       time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
+	Organization   string        `json:"organization" structs:"organization" mapstructure:"organization"`
+	OrganizationID int64         `json:"organization_id" structs:"organization_id" mapstructure:"organization_id"`
+	BaseURL        string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
+	TTL            time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
+	MaxTTL         time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
 }
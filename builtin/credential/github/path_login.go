@@ -153,6 +153,15 @@ func (b *backend) verifyCredentials(req *logical.Request, token string) (*verify
 	}
 
 	for _, o := range allOrgs {
+		// If the ID of the organization is pinned, a renamed organization
+		// is still matched, and another one taking its name is not
+		if config.OrganizationID != 0 {
+			if o.ID != nil && int64(*o.ID) == config.OrganizationID {
+				org = o
+				break
+			}
+			continue
+		}
 		if strings.ToLower(*o.Login) == strings.ToLower(config.Organization) {
 			org = o
 			break
@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend().Setup(conf)
+}
+
+func Backend() *backend {
+	var b backend
+	b.Backend = &framework.Backend{
+		Help: backendHelp,
+
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"login",
+			},
+		},
+
+		Paths: []*framework.Path{
+			pathConfig(&b),
+			pathListRoles(&b),
+			pathRoles(&b),
+			pathLogin(&b),
+		},
+
+		AuthRenew: b.pathLoginRenew,
+	}
+
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+}
+
+const backendHelp = `
+The Kubernetes credential backend allows workloads running in Kubernetes to
+authenticate with the tokens of their service accounts, such as projected
+service account tokens.
+
+The tokens are validated by the Kubernetes API server configured with the
+"config" endpoint, through the TokenReview API. Roles bind the names and the
+namespaces of the service accounts, and the audience of the tokens, to
+policies.
+`
@@ -0,0 +1,205 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+)
+
+// testTokens are the tokens known to the fake Kubernetes API server, with
+// their usernames and audiences
+var testTokens = map[string]struct {
+	username  string
+	audiences []string
+}{
+	"reviewer-token": {"system:serviceaccount:kube-system:vault", []string{"https://kubernetes.default.svc"}},
+	"app-token":      {"system:serviceaccount:apps:frontend", []string{"https://kubernetes.default.svc"}},
+	"projected":      {"system:serviceaccount:apps:frontend", []string{"vault"}},
+	"user-token":     {"jane", []string{"https://kubernetes.default.svc"}},
+}
+
+// serveTokenReview is a fake of the TokenReview API of a Kubernetes API
+// server
+func serveTokenReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.URL.Path != "/apis/authentication.k8s.io/v1/tokenreviews" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer reviewer-token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "forbidden",
+		})
+		return
+	}
+
+	var review struct {
+		Spec struct {
+			Token     string   `json:"token"`
+			Audiences []string `json:"audiences"`
+		} `json:"spec"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	status := map[string]interface{}{
+		"authenticated": false,
+	}
+	if token, ok := testTokens[review.Spec.Token]; ok {
+		authenticated := len(review.Spec.Audiences) == 0
+		for _, aud := range token.audiences {
+			for _, expected := range review.Spec.Audiences {
+				authenticated = authenticated || aud == expected
+			}
+		}
+		if authenticated {
+			status = map[string]interface{}{
+				"authenticated": true,
+				"audiences":     token.audiences,
+				"user": map[string]interface{}{
+					"username": token.username,
+					"uid":      "uid-" + review.Spec.Token,
+				},
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+	})
+}
+
+func TestBackend_login(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	ts := httptest.NewServer(http.HandlerFunc(serveTokenReview))
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, map[string]interface{}{
+				"kubernetes_host":    ts.URL,
+				"token_reviewer_jwt": "reviewer-token",
+			}),
+			testAccStepRole(t, "frontend", map[string]interface{}{
+				"bound_service_account_names":      "frontend",
+				"bound_service_account_namespaces": "apps",
+				"policies":                         "frontend",
+			}),
+			testAccStepRole(t, "projected", map[string]interface{}{
+				"bound_service_account_names":      "*",
+				"bound_service_account_namespaces": "apps",
+				"audience":                         "vault",
+			}),
+			testAccStepLogin(t, "frontend", "app-token"),
+			testAccStepLogin(t, "frontend", "projected"),
+			testAccStepLoginInvalid(t, "frontend", "unknown"),
+			testAccStepLoginInvalid(t, "frontend", "reviewer-token"),
+			testAccStepLoginInvalid(t, "frontend", "user-token"),
+			testAccStepLogin(t, "projected", "projected"),
+			testAccStepLoginInvalid(t, "projected", "app-token"),
+			testAccStepLoginInvalid(t, "unknown", "app-token"),
+		},
+	})
+}
+
+func TestBackend_loginWithoutReviewer(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	ts := httptest.NewServer(http.HandlerFunc(serveTokenReview))
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, map[string]interface{}{
+				"kubernetes_host": ts.URL,
+			}),
+			testAccStepRole(t, "vault", map[string]interface{}{
+				"bound_service_account_names":      "vault",
+				"bound_service_account_namespaces": "*",
+			}),
+			// Only the reviewer token is allowed to create token reviews, so
+			// it can log in with itself
+			logicaltest.TestStep{
+				Operation:       logical.UpdateOperation,
+				Path:            "login",
+				Unauthenticated: true,
+				Data: map[string]interface{}{
+					"role": "vault",
+					"jwt":  "reviewer-token",
+				},
+				Check: logicaltest.TestCheckAuthDisplayName("kube-system-vault"),
+			},
+			testAccStepLoginInvalid(t, "vault", "app-token"),
+		},
+	})
+}
+
+func testAccStepConfig(t *testing.T, data map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data:      data,
+	}
+}
+
+func testAccStepRole(t *testing.T, name string, data map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "role/" + name,
+		Data:      data,
+	}
+}
+
+// testAccStepLogin logs in as the frontend service account of the apps
+// namespace
+func testAccStepLogin(t *testing.T, role, jwt string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation:       logical.UpdateOperation,
+		Path:            "login",
+		Unauthenticated: true,
+		Data: map[string]interface{}{
+			"role": role,
+			"jwt":  jwt,
+		},
+		Check: func(resp *logical.Response) error {
+			if resp == nil || resp.Auth == nil {
+				return fmt.Errorf("%s/%s: bad: %#v", role, jwt, resp)
+			}
+			if resp.Auth.Metadata["service_account_namespace"] != "apps" ||
+				resp.Auth.Metadata["service_account_name"] != "frontend" ||
+				resp.Auth.Metadata["service_account_uid"] != "uid-"+jwt ||
+				resp.Auth.DisplayName != "mnt-apps-frontend" {
+				return fmt.Errorf("%s/%s: bad: %#v", role, jwt, resp.Auth)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepLoginInvalid(t *testing.T, role, jwt string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation:       logical.UpdateOperation,
+		Path:            "login",
+		Unauthenticated: true,
+		Data: map[string]interface{}{
+			"role": role,
+			"jwt":  jwt,
+		},
+		ErrorOk: true,
+		Check: func(resp *logical.Response) error {
+			if resp == nil || !resp.IsError() {
+				return fmt.Errorf("%s/%s: expected error, got %#v", role, jwt, resp)
+			}
+			return nil
+		},
+	}
+}
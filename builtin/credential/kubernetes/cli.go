@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// defaultTokenPath is the path of the token of the service account mounted in
+// the pods
+const defaultTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+type CLIHandler struct{}
+
+func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (string, error) {
+	mount, ok := m["mount"]
+	if !ok {
+		mount = "kubernetes"
+	}
+
+	role, ok := m["role"]
+	if !ok {
+		return "", fmt.Errorf("'role' var must be set")
+	}
+
+	jwt, ok := m["jwt"]
+	if !ok {
+		path, ok := m["jwt_path"]
+		if !ok {
+			path = defaultTokenPath
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading the service account token: %s", err)
+		}
+		jwt = strings.TrimSpace(string(contents))
+	}
+
+	path := fmt.Sprintf("auth/%s/login", mount)
+	secret, err := c.Logical().Write(path, map[string]interface{}{
+		"role": role,
+		"jwt":  jwt,
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", fmt.Errorf("empty response from credential provider")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
+
+func (h *CLIHandler) Help() string {
+	help := `
+The Kubernetes credential provider allows you to authenticate with the token
+of a Kubernetes service account. By default, the token of the service account
+of the pod is read from
+"/var/run/secrets/kubernetes.io/serviceaccount/token".
+
+    Example: vault auth -method=kubernetes role=<role>
+
+Key/Value Pairs:
+
+    mount=kubernetes     The mountpoint for the Kubernetes credential
+                         provider. Defaults to "kubernetes"
+
+    role=<role>          The role to log in with.
+
+    jwt=<token>          The service account token.
+
+    jwt_path=<path>      The path of a file containing the service account
+                         token, such as a projected service account token.
+	`
+
+	return strings.TrimSpace(help)
+}
@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `config`,
+		Fields: map[string]*framework.FieldSchema{
+			"kubernetes_host": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "URL of the Kubernetes API server.",
+			},
+			"kubernetes_ca_cert": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM-encoded CA certificate used to verify the certificate of the Kubernetes API server.",
+			},
+			"token_reviewer_jwt": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Token of a service account allowed to create token reviews.
+Defaults to the token being reviewed.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+// Config returns the configuration for this backend.
+func (b *backend) Config(s logical.Storage) (*kubeConfig, error) {
+	entry, err := s.Get("config")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result kubeConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathConfigRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	// The reviewer token is not returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"kubernetes_host":    config.Host,
+			"kubernetes_ca_cert": config.CACert,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &kubeConfig{
+		Host:          d.Get("kubernetes_host").(string),
+		CACert:        d.Get("kubernetes_ca_cert").(string),
+		ReviewerToken: d.Get("token_reviewer_jwt").(string),
+	}
+
+	if config.Host == "" {
+		return logical.ErrorResponse("missing kubernetes_host"), nil
+	}
+	if _, err := newTokenReviewer(config); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// kubeConfig contains the information required to validate tokens with the
+// Kubernetes API server
+type kubeConfig struct {
+	Host          string `json:"kubernetes_host"`
+	CACert        string `json:"kubernetes_ca_cert"`
+	ReviewerToken string `json:"token_reviewer_jwt"`
+}
+
+const pathConfigHelpSyn = `
+Configure the Kubernetes API server used to validate the tokens.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures the Kubernetes API server validating the service
+account tokens. The "kubernetes_host" parameter is the URL of the API server,
+and "kubernetes_ca_cert" the PEM-encoded CA certificate used to verify it.
+
+The "token_reviewer_jwt" parameter is the token of a service account allowed
+to create token reviews, which is granted by the "system:auth-delegator"
+cluster role. If it is not set, the tokens being validated are used to
+create their own token reviews, which their service accounts must then be
+allowed to do.
+`
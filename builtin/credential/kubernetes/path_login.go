@@ -0,0 +1,132 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathLogin(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `login`,
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role to log in with.",
+			},
+			"jwt": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Token of the service account.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathLogin,
+		},
+
+		HelpSynopsis:    pathLoginHelpSyn,
+		HelpDescription: pathLoginHelpDesc,
+	}
+}
+
+func (b *backend) pathLogin(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("role").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+	token := d.Get("jwt").(string)
+	if token == "" {
+		return logical.ErrorResponse("missing jwt"), nil
+	}
+
+	config, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("Kubernetes backend not configured"), nil
+	}
+	role, err := b.Role(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid role name %q", roleName)), nil
+	}
+
+	reviewer, err := newTokenReviewer(config)
+	if err != nil {
+		return nil, err
+	}
+	reviewerToken := config.ReviewerToken
+	if reviewerToken == "" {
+		reviewerToken = token
+	}
+	var audiences []string
+	if role.Audience != "" {
+		audiences = []string{role.Audience}
+	}
+
+	sa, err := reviewer.Review(reviewerToken, token, audiences)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if !role.allows(sa) {
+		return logical.ErrorResponse(fmt.Sprintf("service account %s/%s is not authorized for role %q", sa.Namespace, sa.Name, roleName)), nil
+	}
+
+	return &logical.Response{
+		Auth: &logical.Auth{
+			InternalData: map[string]interface{}{
+				"role": roleName,
+			},
+			Policies: role.Policies,
+			Metadata: map[string]string{
+				"role":                      roleName,
+				"service_account_name":      sa.Name,
+				"service_account_namespace": sa.Namespace,
+				"service_account_uid":       sa.UID,
+			},
+			DisplayName: sa.Namespace + "-" + sa.Name,
+			LeaseOptions: logical.LeaseOptions{
+				TTL:       role.TTL,
+				Renewable: true,
+			},
+		},
+	}, nil
+}
+
+// The service account token is not kept, so renewals only check that the
+// role still exists
+func (b *backend) pathLoginRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.Auth == nil {
+		return nil, fmt.Errorf("request auth was nil")
+	}
+
+	roleName, ok := req.Auth.InternalData["role"].(string)
+	if !ok || roleName == "" {
+		return nil, fmt.Errorf("failed to fetch role during renewal")
+	}
+	role, err := b.Role(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %q does not exist during renewal", roleName)
+	}
+
+	return framework.LeaseExtend(role.TTL, role.MaxTTL, b.System())(req, d)
+}
+
+const pathLoginHelpSyn = `
+Log in with the token of a Kubernetes service account.
+`
+
+const pathLoginHelpDesc = `
+This endpoint validates the "jwt" token of a service account with the
+TokenReview API of the Kubernetes API server, and issues a Vault token with
+the policies of the "role" role if the role allows the service account.
+`
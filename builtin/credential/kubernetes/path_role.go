@@ -0,0 +1,200 @@
+package kubernetes
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/?$",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+			"bound_service_account_names": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the names of the service accounts
+allowed to log in. "*" allows all the names.`,
+			},
+			"bound_service_account_namespaces": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the namespaces of the service
+accounts allowed to log in. "*" allows all the namespaces.`,
+			},
+			"audience": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Audience the tokens must have. If not set, the audience is not checked.",
+			},
+			"policies": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of the policies of the tokens.",
+			},
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "TTL of the tokens. Defaults to the system default.",
+			},
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum TTL of the tokens. Defaults to the system maximum.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleUpdate,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+// Role reads the role from the storage
+func (b *backend) Role(s logical.Storage, n string) (*roleEntry, error) {
+	entry, err := s.Get("role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathRoleDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return nil, req.Storage.Delete("role/" + d.Get("name").(string))
+}
+
+func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := b.Role(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"bound_service_account_names":      role.ServiceAccountNames,
+			"bound_service_account_namespaces": role.ServiceAccountNamespaces,
+			"audience":                         role.Audience,
+			"policies":                         role.Policies,
+			"ttl":                              role.TTL / time.Second,
+			"max_ttl":                          role.MaxTTL / time.Second,
+		},
+	}, nil
+}
+
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(roles), nil
+}
+
+func (b *backend) pathRoleUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	role := &roleEntry{
+		ServiceAccountNames:      parseList(d.Get("bound_service_account_names").(string)),
+		ServiceAccountNamespaces: parseList(d.Get("bound_service_account_namespaces").(string)),
+		Audience:                 d.Get("audience").(string),
+		Policies:                 policyutil.ParsePolicies(d.Get("policies").(string)),
+		TTL:                      time.Duration(d.Get("ttl").(int)) * time.Second,
+		MaxTTL:                   time.Duration(d.Get("max_ttl").(int)) * time.Second,
+	}
+
+	switch {
+	case len(role.ServiceAccountNames) == 0:
+		return logical.ErrorResponse("missing bound_service_account_names"), nil
+	case len(role.ServiceAccountNamespaces) == 0:
+		return logical.ErrorResponse("missing bound_service_account_namespaces"), nil
+	case strutil.StrListContains(role.ServiceAccountNames, "*") && strutil.StrListContains(role.ServiceAccountNamespaces, "*"):
+		return logical.ErrorResponse("bound_service_account_names and bound_service_account_namespaces cannot both be \"*\""), nil
+	case role.MaxTTL > 0 && role.TTL > role.MaxTTL:
+		return logical.ErrorResponse("ttl should not be greater than max_ttl"), nil
+	}
+	if _, _, err := b.SanitizeTTL(role.TTL, role.MaxTTL); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("role/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// parseList parses a comma-separated list, keeping the case of the elements
+func parseList(s string) []string {
+	var result []string
+	for _, elem := range strutil.ParseStringSlice(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			result = append(result, elem)
+		}
+	}
+	return result
+}
+
+// roleEntry binds service accounts to policies
+type roleEntry struct {
+	ServiceAccountNames      []string      `json:"bound_service_account_names"`
+	ServiceAccountNamespaces []string      `json:"bound_service_account_namespaces"`
+	Audience                 string        `json:"audience"`
+	Policies                 []string      `json:"policies"`
+	TTL                      time.Duration `json:"ttl"`
+	MaxTTL                   time.Duration `json:"max_ttl"`
+}
+
+// allows returns whether the service account can log in with the role
+func (r *roleEntry) allows(sa *serviceAccount) bool {
+	return (strutil.StrListContains(r.ServiceAccountNames, "*") || strutil.StrListContains(r.ServiceAccountNames, sa.Name)) &&
+		(strutil.StrListContains(r.ServiceAccountNamespaces, "*") || strutil.StrListContains(r.ServiceAccountNamespaces, sa.Namespace))
+}
+
+const pathRoleHelpSyn = `
+Manage the roles service accounts log in with.
+`
+
+const pathRoleHelpDesc = `
+This endpoint manages the roles service accounts log in with. A service
+account can log in with a role if its name is one of the
+"bound_service_account_names" names and its namespace one of the
+"bound_service_account_namespaces" namespaces. Either list, but not both, can
+be "*" to allow all the names or namespaces.
+
+If "audience" is set, the tokens must have this audience, as projected service
+account tokens can be given. Tokens of the default service account secrets
+have the audience of the API server only.
+`
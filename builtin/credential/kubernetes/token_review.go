@@ -0,0 +1,148 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/strutil"
+)
+
+// serviceAccountPrefix is the prefix of the usernames of service accounts
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// tokenReviewer validates tokens with the TokenReview API of a Kubernetes
+// API server
+type tokenReviewer struct {
+	host       string
+	httpClient *http.Client
+}
+
+// serviceAccount is the service account of a validated token
+type serviceAccount struct {
+	Name      string
+	Namespace string
+	UID       string
+}
+
+func newTokenReviewer(config *kubeConfig) (*tokenReviewer, error) {
+	transport := cleanhttp.DefaultTransport()
+	if config.CACert != "" {
+		caPool := x509.NewCertPool()
+		if ok := caPool.AppendCertsFromPEM([]byte(config.CACert)); !ok {
+			return nil, fmt.Errorf("could not parse the CA certificate")
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: caPool,
+		}
+	}
+
+	return &tokenReviewer{
+		host: strings.TrimSuffix(config.Host, "/"),
+		httpClient: &http.Client{
+			Transport: transport,
+		},
+	}, nil
+}
+
+// Review validates the token, authenticating to the API server with the
+// reviewer token. If audiences are given, the token must have one of them.
+func (r *tokenReviewer) Review(reviewerToken, token string, audiences []string) (*serviceAccount, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "authentication.k8s.io/v1",
+		"kind":       "TokenReview",
+		"spec": map[string]interface{}{
+			"token":     token,
+			"audiences": audiences,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", r.host+"/apis/authentication.k8s.io/v1/tokenreviews", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+reviewerToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &status)
+		return nil, fmt.Errorf("unexpected status code %d from the TokenReview API: %s", resp.StatusCode, status.Message)
+	}
+
+	var review struct {
+		Status struct {
+			Authenticated bool     `json:"authenticated"`
+			Audiences     []string `json:"audiences"`
+			Error         string   `json:"error"`
+			User          struct {
+				Username string `json:"username"`
+				UID      string `json:"uid"`
+			} `json:"user"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(respBody, &review); err != nil {
+		return nil, err
+	}
+
+	status := review.Status
+	if !status.Authenticated {
+		if status.Error != "" {
+			return nil, fmt.Errorf("token not authenticated: %s", status.Error)
+		}
+		return nil, fmt.Errorf("token not authenticated")
+	}
+
+	// API servers not supporting audiences ignore them, so the audiences
+	// of the review are checked as well
+	if len(audiences) > 0 {
+		found := false
+		for _, aud := range status.Audiences {
+			if strutil.StrListContains(audiences, aud) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("token audiences %q do not include %q", status.Audiences, audiences)
+		}
+	}
+
+	// The username of a service account is
+	// "system:serviceaccount:<namespace>:<name>"
+	if !strings.HasPrefix(status.User.Username, serviceAccountPrefix) {
+		return nil, fmt.Errorf("token does not belong to a service account")
+	}
+	split := strings.Split(strings.TrimPrefix(status.User.Username, serviceAccountPrefix), ":")
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return nil, fmt.Errorf("invalid service account username %q", status.User.Username)
+	}
+
+	return &serviceAccount{
+		Namespace: split[0],
+		Name:      split[1],
+		UID:       status.User.UID,
+	}, nil
+}
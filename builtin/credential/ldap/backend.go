@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"text/template"
+	"time"
 
 	"github.com/go-ldap/ldap"
 	"github.com/hashicorp/vault/helper/mfa"
@@ -83,7 +84,14 @@ func EscapeLDAPValue(input string) string {
 	return input
 }
 
-func (b *backend) Login(req *logical.Request, username string, password string) ([]string, *logical.Response, error) {
+// loginResult holds the policies and the token parameters of a login
+type loginResult struct {
+	Policies []string
+	TTL      time.Duration
+	MaxTTL   time.Duration
+}
+
+func (b *backend) Login(req *logical.Request, username string, password string) (*loginResult, *logical.Response, error) {
 
 	cfg, err := b.Config(req)
 	if err != nil {
@@ -157,15 +165,38 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 	// Merge local and LDAP groups
 	allGroups = append(allGroups, ldapGroups...)
 
-	// Retrieve policies
+	// Retrieve policies and token parameters. The most restrictive TTLs of
+	// the groups apply.
+	result := &loginResult{}
 	var policies []string
 	for _, groupName := range allGroups {
 		group, err := b.Group(req.Storage, groupName)
-		if err == nil && group != nil {
-			policies = append(policies, group.Policies...)
+		if err != nil || group == nil {
+			continue
+		}
+		if !group.allowsConnection(req.Connection) {
+			if b.Logger().IsDebug() {
+				b.Logger().Debug("auth/ldap: ignoring group due to CIDR restrictions", "group", groupName)
+			}
+			continue
+		}
+		policies = append(policies, group.Policies...)
+		if group.TTL > 0 && (result.TTL == 0 || group.TTL < result.TTL) {
+			result.TTL = group.TTL
+		}
+		if group.MaxTTL > 0 && (result.MaxTTL == 0 || group.MaxTTL < result.MaxTTL) {
+			result.MaxTTL = group.MaxTTL
 		}
 	}
-	if user !=nil && user.Policies != nil {
+	if result.MaxTTL > 0 {
+		if result.TTL == 0 {
+			result.TTL = b.System().DefaultLeaseTTL()
+		}
+		if result.TTL > result.MaxTTL {
+			result.TTL = result.MaxTTL
+		}
+	}
+	if user != nil && user.Policies != nil {
 		policies = append(policies, user.Policies...)
 	}
 	// Policies from each group may overlap
@@ -181,7 +212,8 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 		return nil, ldapResponse, nil
 	}
 
-	return policies, ldapResponse, nil
+	result.Policies = policies
+	return result, ldapResponse, nil
 }
 
 /*
@@ -342,14 +374,23 @@ func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, u
 		b.Logger().Debug("auth/ldap: Searching", "groupdn", cfg.GroupDN, "rendered_query", renderedQuery.String())
 	}
 
-	result, err := c.Search(&ldap.SearchRequest{
+	searchRequest := &ldap.SearchRequest{
 		BaseDN: cfg.GroupDN,
 		Scope:  2, // subtree
 		Filter: renderedQuery.String(),
 		Attributes: []string{
 			cfg.GroupAttr,
 		},
-	})
+	}
+
+	// Search the groups by pages if configured, since the servers return at
+	// most a limited number of entries per search
+	var result *ldap.SearchResult
+	if cfg.MaxPageSize > 0 {
+		result, err = c.SearchWithPaging(searchRequest, uint32(cfg.MaxPageSize))
+	} else {
+		result, err = c.Search(searchRequest)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("LDAP search failed: %v", err)
 	}
@@ -218,6 +218,75 @@ func TestBackend_groupCrud(t *testing.T) {
 	})
 }
 
+func TestBackend_groupTokenParams(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "groups/g1",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"policies":    "foo",
+			"ttl":         "1h",
+			"max_ttl":     "2h",
+			"bound_cidrs": "10.0.0.0/8, 192.168.1.0/24",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "groups/g1",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err:%s resp:%#v", err, resp)
+	}
+	expected := map[string]interface{}{
+		"policies":    "default,foo",
+		"ttl":         time.Duration(3600),
+		"max_ttl":     time.Duration(7200),
+		"bound_cidrs": "10.0.0.0/8,192.168.1.0/24",
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	group, err := b.Group(storage, "g1")
+	if err != nil || group == nil {
+		t.Fatalf("err:%s group:%#v", err, group)
+	}
+	cases := map[string]bool{
+		"10.1.2.3":    true,
+		"192.168.1.5": true,
+		"192.168.2.5": false,
+		"":            false,
+	}
+	for addr, allowed := range cases {
+		if group.allowsConnection(&logical.Connection{RemoteAddr: addr}) != allowed {
+			t.Fatalf("%q: expected %t", addr, allowed)
+		}
+	}
+
+	invalid := []map[string]interface{}{
+		{"ttl": "3h", "max_ttl": "2h"},
+		{"bound_cidrs": "10.0.0.0/33"},
+	}
+	for _, data := range invalid {
+		resp, err = b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "groups/g2",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("%#v: expected error, got err:%s resp:%#v", data, err, resp)
+		}
+	}
+}
+
 /*
  * Test backend configuration defaults are successfully read.
  */
@@ -257,6 +326,10 @@ func TestBackend_configDefaultsAfterUpdate(t *testing.T) {
 						t.Errorf("Default mismatch: userattr. Expected: '%s', received :'%s'", defaultUserAttr, cfg["userattr"])
 					}
 
+					if cfg["max_page_size"] != 0 {
+						t.Errorf("Default mismatch: max_page_size. Expected: 0, received :'%v'", cfg["max_page_size"])
+					}
+
 					defaultDenyNullBind := true
 					if cfg["deny_null_bind"] != defaultDenyNullBind {
 						t.Errorf("Default mismatch: deny_null_bind. Expected: '%s', received :'%s'", defaultDenyNullBind, cfg["deny_null_bind"])
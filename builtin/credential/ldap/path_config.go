@@ -113,6 +113,11 @@ Default: cn`,
 				Default:     true,
 				Description: "Denies an unauthenticated LDAP bind request if the user's password is empty; defaults to true",
 			},
+			"max_page_size": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: "If greater than 0, the group searches request pages of at most this number of entries with the paged results control of RFC 2696; defaults to 0, which disables paging",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -275,6 +280,11 @@ func (b *backend) newConfigEntry(d *framework.FieldData) (*ConfigEntry, error) {
 	if discoverDN {
 		cfg.DiscoverDN = discoverDN
 	}
+	maxPageSize := d.Get("max_page_size").(int)
+	if maxPageSize < 0 {
+		return nil, fmt.Errorf("'max_page_size' cannot be negative")
+	}
+	cfg.MaxPageSize = maxPageSize
 
 	return cfg, nil
 }
@@ -317,6 +327,7 @@ type ConfigEntry struct {
 	DiscoverDN    bool   `json:"discoverdn" structs:"discoverdn" mapstructure:"discoverdn"`
 	TLSMinVersion string `json:"tls_min_version" structs:"tls_min_version" mapstructure:"tls_min_version"`
 	TLSMaxVersion string `json:"tls_max_version" structs:"tls_max_version" mapstructure:"tls_max_version"`
+	MaxPageSize   int    `json:"max_page_size" structs:"max_page_size" mapstructure:"max_page_size"`
 }
 
 func (c *ConfigEntry) GetTLSConfig(host string) (*tls.Config, error) {
@@ -451,6 +462,11 @@ case, an unencrypted connection will be made with a default port of 389, unless
 the "starttls" parameter is set to true, in which case TLS will be used. In the
 latter case, a SSL connection will be established with a default port of 636.
 
+When the groups of the users are more numerous than the size limit of the
+searches of the LDAP server, such as the 1000 entries of Active Directory,
+set "max_page_size" to search them by pages with the paged results control of
+RFC 2696.
+
 ## A NOTE ON ESCAPING
 
 It is up to the administrator to provide properly escaped DNs. This includes
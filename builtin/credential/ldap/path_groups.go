@@ -2,7 +2,9 @@ package ldap
 
 import (
 	"strings"
+	"time"
 
+	"github.com/hashicorp/vault/helper/cidrutil"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -34,6 +36,21 @@ func pathGroups(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Comma-separated list of policies associated to the group.",
 			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "TTL of the tokens of the members of the group. The lowest TTL of the groups of a user applies.",
+			},
+
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum TTL of the tokens of the members of the group. The lowest maximum TTL of the groups of a user applies.",
+			},
+
+			"bound_cidrs": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of CIDR blocks. If set, the group only applies to logins from these blocks.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -86,17 +103,38 @@ func (b *backend) pathGroupRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"policies": strings.Join(group.Policies, ","),
+			"policies":    strings.Join(group.Policies, ","),
+			"ttl":         group.TTL / time.Second,
+			"max_ttl":     group.MaxTTL / time.Second,
+			"bound_cidrs": strings.Join(group.BoundCIDRs, ","),
 		},
 	}, nil
 }
 
 func (b *backend) pathGroupWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	// Store it
-	entry, err := logical.StorageEntryJSON("group/"+d.Get("name").(string), &GroupEntry{
+	group := &GroupEntry{
 		Policies: policyutil.ParsePolicies(d.Get("policies").(string)),
-	})
+		TTL:      time.Duration(d.Get("ttl").(int)) * time.Second,
+		MaxTTL:   time.Duration(d.Get("max_ttl").(int)) * time.Second,
+	}
+	if group.MaxTTL > 0 && group.TTL > group.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+	for _, cidr := range strings.Split(d.Get("bound_cidrs").(string), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			group.BoundCIDRs = append(group.BoundCIDRs, cidr)
+		}
+	}
+	if len(group.BoundCIDRs) > 0 {
+		valid, err := cidrutil.ValidateCIDRListSlice(group.BoundCIDRs)
+		if err != nil || !valid {
+			return logical.ErrorResponse("invalid bound_cidrs"), nil
+		}
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("group/"+d.Get("name").(string), group)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +155,23 @@ func (b *backend) pathGroupList(
 }
 
 type GroupEntry struct {
-	Policies []string
+	Policies   []string
+	TTL        time.Duration
+	MaxTTL     time.Duration
+	BoundCIDRs []string
+}
+
+// allowsConnection returns whether the group applies to logins from the
+// connection, according to its CIDR restrictions
+func (g *GroupEntry) allowsConnection(conn *logical.Connection) bool {
+	if len(g.BoundCIDRs) == 0 {
+		return true
+	}
+	if conn == nil || conn.RemoteAddr == "" {
+		return false
+	}
+	belongs, err := cidrutil.IPBelongsToCIDRBlocksSlice(conn.RemoteAddr, g.BoundCIDRs)
+	return err == nil && belongs
 }
 
 const pathGroupHelpSyn = `
@@ -129,6 +183,10 @@ This endpoint allows you to create, read, update, and delete configuration
 for LDAP groups that are allowed to authenticate, and associate policies to
 them.
 
+The tokens of the members of a group can be given a TTL and a maximum TTL,
+the lowest of the groups of a user applying. A group can also be restricted
+to the logins from a list of CIDR blocks.
+
 Deleting a group will not revoke auth for prior authenticated users in that
 group. To do this, do a revoke on "login/<username>" for
 the usernames you want revoked.
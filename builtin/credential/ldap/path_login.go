@@ -39,7 +39,7 @@ func (b *backend) pathLogin(
 	username := d.Get("username").(string)
 	password := d.Get("password").(string)
 
-	policies, resp, err := b.Login(req, username, password)
+	result, resp, err := b.Login(req, username, password)
 	// Handle an internal error
 	if err != nil {
 		return nil, err
@@ -53,6 +53,7 @@ func (b *backend) pathLogin(
 		resp = &logical.Response{}
 	}
 
+	policies := result.Policies
 	sort.Strings(policies)
 
 	resp.Auth = &logical.Auth{
@@ -66,6 +67,7 @@ func (b *backend) pathLogin(
 		},
 		DisplayName: username,
 		LeaseOptions: logical.LeaseOptions{
+			TTL:       result.TTL,
 			Renewable: true,
 		},
 	}
@@ -78,16 +80,16 @@ func (b *backend) pathLoginRenew(
 	username := req.Auth.Metadata["username"]
 	password := req.Auth.InternalData["password"].(string)
 
-	loginPolicies, resp, err := b.Login(req, username, password)
-	if len(loginPolicies) == 0 {
+	result, resp, err := b.Login(req, username, password)
+	if result == nil || len(result.Policies) == 0 {
 		return resp, err
 	}
 
-	if !policyutil.EquivalentPolicies(loginPolicies, req.Auth.Policies) {
+	if !policyutil.EquivalentPolicies(result.Policies, req.Auth.Policies) {
 		return nil, fmt.Errorf("policies have changed, not renewing")
 	}
 
-	return framework.LeaseExtend(0, 0, b.System())(req, d)
+	return framework.LeaseExtend(result.TTL, result.MaxTTL, b.System())(req, d)
 }
 
 const pathLoginSyn = `
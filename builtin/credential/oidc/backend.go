@@ -0,0 +1,163 @@
+package oidc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// stateTTL is the time a user has to complete the authorization flow of the
+// OIDC provider
+const stateTTL = 5 * time.Minute
+
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend().Setup(conf)
+}
+
+func Backend() *backend {
+	var b backend
+	b.states = make(map[string]*oidcState)
+	b.GroupMap = &framework.PolicyMap{
+		PathMap: framework.PathMap{
+			Name: "groups",
+		},
+	}
+
+	b.Backend = &framework.Backend{
+		Help: backendHelp,
+
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"oidc/auth_url",
+				"oidc/callback",
+			},
+		},
+
+		Paths: append([]*framework.Path{
+			pathConfig(&b),
+			pathListRoles(&b),
+			pathRoles(&b),
+			pathAuthURL(&b),
+			pathCallback(&b),
+		}, b.GroupMap.Paths()...),
+
+		AuthRenew:  b.pathLoginRenew,
+		Invalidate: b.invalidate,
+	}
+
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	// GroupMap maps the groups of the users to policies
+	GroupMap *framework.PolicyMap
+
+	// provider is the OIDC provider of the configuration, discovered on
+	// first use
+	provider *provider
+	lock     sync.Mutex
+
+	// states are the pending authorization requests, by state parameter
+	states     map[string]*oidcState
+	statesLock sync.Mutex
+}
+
+// oidcState is a pending authorization request
+type oidcState struct {
+	role        string
+	redirectURI string
+	nonce       string
+	expiration  time.Time
+}
+
+// Provider returns the OIDC provider of the configuration
+func (b *backend) Provider(s logical.Storage) (*provider, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.provider != nil {
+		return b.provider, nil
+	}
+
+	config, err := b.Config(s)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errNotConfigured
+	}
+
+	p, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	b.provider = p
+
+	return p, nil
+}
+
+// resetProvider forces the provider to be discovered again on next use
+func (b *backend) resetProvider() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.provider = nil
+}
+
+func (b *backend) invalidate(key string) {
+	switch key {
+	case "config":
+		b.resetProvider()
+	}
+}
+
+// putState records a pending authorization request, and forgets the expired
+// ones
+func (b *backend) putState(id string, state *oidcState) {
+	b.statesLock.Lock()
+	defer b.statesLock.Unlock()
+
+	now := time.Now()
+	for k, v := range b.states {
+		if now.After(v.expiration) {
+			delete(b.states, k)
+		}
+	}
+	b.states[id] = state
+}
+
+// takeState returns and forgets a pending authorization request. It returns
+// nil if there is no such request or if it has expired.
+func (b *backend) takeState(id string) *oidcState {
+	b.statesLock.Lock()
+	defer b.statesLock.Unlock()
+
+	state, ok := b.states[id]
+	if !ok {
+		return nil
+	}
+	delete(b.states, id)
+	if time.Now().After(state.expiration) {
+		return nil
+	}
+
+	return state
+}
+
+const backendHelp = `
+The OIDC credential backend allows authentication with an OpenID Connect
+provider, through the authorization code flow in the browser of the user.
+
+After configuring the provider with the "config" endpoint, roles define the
+redirect URIs allowed for the flow, and how the claims of the ID tokens map
+to the name, metadata and policies of the Vault tokens. The "map/groups/"
+paths map the groups of the users, read from a claim of the ID tokens, to
+policies.
+
+The CLI handles the flow with a local listener receiving the callback of the
+provider: "vault auth -method=oidc role=<role>".
+`
@@ -0,0 +1,276 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+)
+
+// testProvider is a fake OIDC provider issuing ID tokens for a single
+// authorization code
+type testProvider struct {
+	url   string
+	key   *rsa.PrivateKey
+	code  string
+	nonce string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testProvider{
+		key:  key,
+		code: "test-code",
+	}
+}
+
+func (p *testProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 p.url,
+			"authorization_endpoint": p.url + "/auth",
+			"token_endpoint":         p.url + "/token",
+			"jwks_uri":               p.url + "/keys",
+		})
+	case "/keys":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]interface{}{
+				{
+					"kty": "RSA",
+					"kid": "test-key",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+				},
+			},
+		})
+	case "/token":
+		if r.FormValue("code") != p.code {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid_grant",
+			})
+			return
+		}
+
+		claims := jws.Claims{}
+		claims.SetIssuer(p.url)
+		claims.SetAudience("vault")
+		claims.SetSubject("1234")
+		claims.SetExpiration(time.Now().Add(time.Minute))
+		claims.Set("nonce", p.nonce)
+		claims.Set("email", "jane@example.com")
+		claims.Set("email_verified", true)
+		claims.Set("groups", []string{"admins", "users"})
+		token := jws.NewJWT(claims, crypto.SigningMethodRS256)
+		token.(jws.JWS).Protected().Set("kid", "test-key")
+		idToken, err := token.Serialize(p.key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     string(idToken),
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestBackend_login(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	provider := newTestProvider(t)
+	ts := httptest.NewServer(provider)
+	defer ts.Close()
+	provider.url = ts.URL
+
+	var state string
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, map[string]interface{}{
+				"oidc_discovery_url": provider.url,
+				"oidc_client_id":     "vault",
+				"oidc_client_secret": "secret",
+				"default_role":       "dev",
+			}),
+			testAccStepRole(t, "dev", map[string]interface{}{
+				"allowed_redirect_uris": "http://localhost:8250/oidc/callback",
+				"user_claim":            "email",
+				"groups_claim":          "groups",
+				"claim_mappings":        "sub=subject,email_verified=verified",
+				"policies":              "dev",
+			}),
+			testAccStepGroup(t, "admins", "admin"),
+			// The redirect URI must be allowed by the role
+			testAccStepAuthURL(t, "", "http://attacker.example.com/oidc/callback", nil),
+			testAccStepAuthURL(t, "", "http://localhost:8250/oidc/callback", func(authURL *url.URL) error {
+				query := authURL.Query()
+				if authURL.Path != "/auth" || query.Get("client_id") != "vault" || query.Get("scope") != "openid" ||
+					query.Get("redirect_uri") != "http://localhost:8250/oidc/callback" {
+					return fmt.Errorf("bad: %s", authURL)
+				}
+				state = query.Get("state")
+				provider.nonce = query.Get("nonce")
+				return nil
+			}),
+			testAccStepCallback(t, &state, func(resp *logical.Response) error {
+				if resp == nil || resp.IsError() || resp.Auth == nil {
+					return fmt.Errorf("bad: %#v", resp)
+				}
+				if resp.Auth.DisplayName != "mnt-jane@example.com" {
+					return fmt.Errorf("bad: %#v", resp.Auth)
+				}
+				if !reflect.DeepEqual(resp.Auth.Policies, []string{"admin", "default", "dev"}) {
+					return fmt.Errorf("bad: %#v", resp.Auth.Policies)
+				}
+				expected := map[string]string{
+					"role":     "dev",
+					"username": "jane@example.com",
+					"subject":  "1234",
+					"verified": "true",
+					"groups":   "admins,users",
+				}
+				if !reflect.DeepEqual(resp.Auth.Metadata, expected) {
+					return fmt.Errorf("bad: %#v", resp.Auth.Metadata)
+				}
+				return nil
+			}),
+			// The state can't be reused
+			testAccStepCallback(t, &state, testAccCheckError),
+		},
+	})
+}
+
+func TestBackend_loginBadNonce(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	provider := newTestProvider(t)
+	ts := httptest.NewServer(provider)
+	defer ts.Close()
+	provider.url = ts.URL
+	provider.nonce = "other"
+
+	var state string
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, map[string]interface{}{
+				"oidc_discovery_url": provider.url,
+				"oidc_client_id":     "vault",
+			}),
+			testAccStepRole(t, "dev", map[string]interface{}{
+				"allowed_redirect_uris": "http://localhost:8250/oidc/callback",
+			}),
+			testAccStepAuthURL(t, "dev", "http://localhost:8250/oidc/callback", func(authURL *url.URL) error {
+				state = authURL.Query().Get("state")
+				return nil
+			}),
+			testAccStepCallback(t, &state, testAccCheckError),
+		},
+	})
+}
+
+func testAccCheckError(resp *logical.Response) error {
+	if resp == nil || !resp.IsError() {
+		return fmt.Errorf("expected error, got %#v", resp)
+	}
+	return nil
+}
+
+func testAccStepConfig(t *testing.T, data map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data:      data,
+	}
+}
+
+func testAccStepRole(t *testing.T, name string, data map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "role/" + name,
+		Data:      data,
+	}
+}
+
+func testAccStepGroup(t *testing.T, group, policies string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "map/groups/" + group,
+		Data: map[string]interface{}{
+			"value": policies,
+		},
+	}
+}
+
+// testAccStepAuthURL requests an authorization URL, which is passed to check,
+// or expects an error if check is nil
+func testAccStepAuthURL(t *testing.T, role, redirectURI string, check func(*url.URL) error) logicaltest.TestStep {
+	data := map[string]interface{}{
+		"redirect_uri": redirectURI,
+	}
+	if role != "" {
+		data["role"] = role
+	}
+	return logicaltest.TestStep{
+		Operation:       logical.UpdateOperation,
+		Path:            "oidc/auth_url",
+		Data:            data,
+		Unauthenticated: true,
+		ErrorOk:         check == nil,
+		Check: func(resp *logical.Response) error {
+			if check == nil {
+				return testAccCheckError(resp)
+			}
+			if resp == nil || resp.IsError() {
+				return fmt.Errorf("bad: %#v", resp)
+			}
+			authURL, err := url.Parse(resp.Data["auth_url"].(string))
+			if err != nil {
+				return err
+			}
+			return check(authURL)
+		},
+	}
+}
+
+// testAccStepCallback completes the login with the state returned by an
+// earlier step
+func testAccStepCallback(t *testing.T, state *string, check logicaltest.TestCheckFunc) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation:       logical.UpdateOperation,
+		Path:            "oidc/callback",
+		Unauthenticated: true,
+		ErrorOk:         true,
+		PreFlight: func(req *logical.Request) error {
+			req.Data = map[string]interface{}{
+				"state": *state,
+				"code":  "test-code",
+			}
+			return nil
+		},
+		Check: check,
+	}
+}
@@ -0,0 +1,156 @@
+package oidc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// callbackTimeout is the time the CLI waits for the callback of the OIDC
+// provider
+const callbackTimeout = 2 * time.Minute
+
+type CLIHandler struct{}
+
+type callbackResult struct {
+	token string
+	err   error
+}
+
+func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (string, error) {
+	mount, ok := m["mount"]
+	if !ok {
+		mount = "oidc"
+	}
+	listenAddress, ok := m["listenaddress"]
+	if !ok {
+		listenAddress = "localhost"
+	}
+	port, ok := m["port"]
+	if !ok {
+		port = "8250"
+	}
+	redirectURI := fmt.Sprintf("http://%s:%s/oidc/callback", listenAddress, port)
+
+	secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/oidc/auth_url", mount), map[string]interface{}{
+		"role":         m["role"],
+		"redirect_uri": redirectURI,
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", fmt.Errorf("empty response from credential provider")
+	}
+	authURL, _ := secret.Data["auth_url"].(string)
+	if authURL == "" {
+		return "", fmt.Errorf("no authorization URL returned by the credential provider")
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(listenAddress, port))
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+
+	// The provider redirects the browser to the listener, which completes
+	// the login with the state and the code
+	resultCh := make(chan callbackResult, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oidc/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if errCode := query.Get("error"); errCode != "" {
+			fmt.Fprintf(w, callbackPage, "Vault login failed. You can close this window.")
+			resultCh <- callbackResult{err: fmt.Errorf("OIDC provider error: %s %s", errCode, query.Get("error_description"))}
+			return
+		}
+
+		secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/oidc/callback", mount), map[string]interface{}{
+			"state": query.Get("state"),
+			"code":  query.Get("code"),
+		})
+		switch {
+		case err != nil:
+		case secret == nil || secret.Auth == nil:
+			err = fmt.Errorf("empty response from credential provider")
+		}
+		if err != nil {
+			fmt.Fprintf(w, callbackPage, "Vault login failed. You can close this window.")
+			resultCh <- callbackResult{err: err}
+			return
+		}
+
+		fmt.Fprintf(w, callbackPage, "Vault login succeeded. You can close this window.")
+		resultCh <- callbackResult{token: secret.Auth.ClientToken}
+	})
+	go http.Serve(listener, mux)
+
+	fmt.Fprintf(os.Stderr, "Complete the login via your OIDC provider. Launching browser to:\n\n    %s\n\n", authURL)
+	if m["skip_browser"] != "true" {
+		if err := openURL(authURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening the browser, open the URL manually: %s\n\n", err)
+		}
+	}
+
+	select {
+	case result := <-resultCh:
+		return result.token, result.err
+	case <-time.After(callbackTimeout):
+		return "", fmt.Errorf("timed out waiting for the response from the OIDC provider")
+	}
+}
+
+// openURL opens the URL in the default browser
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+const callbackPage = `<!DOCTYPE html>
+<html><head><title>Vault</title></head><body><p>%s</p></body></html>
+`
+
+func (h *CLIHandler) Help() string {
+	help := `
+The OIDC credential provider allows you to authenticate with an OpenID Connect
+provider in your browser. The CLI opens the authorization URL of the provider
+and listens for its callback on a local port, which must be allowed by the
+role as "http://localhost:8250/oidc/callback" with the default values.
+
+    Example: vault auth -method=oidc role=<role>
+
+Key/Value Pairs:
+
+    mount=oidc              The mountpoint for the OIDC credential provider.
+                            Defaults to "oidc"
+
+    role=<role>             The role to authenticate against. Defaults to
+                            the default role of the configuration.
+
+    listenaddress=<addr>    The address the callback listener binds to.
+                            Defaults to "localhost"
+
+    port=<port>             The port the callback listener binds to.
+                            Defaults to "8250"
+
+    skip_browser=true       Print the authorization URL without opening
+                            the browser.
+	`
+
+	return strings.TrimSpace(help)
+}
@@ -0,0 +1,143 @@
+package oidc
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `config`,
+		Fields: map[string]*framework.FieldSchema{
+			"oidc_discovery_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Issuer URL of the OIDC provider, used to discover its endpoints.",
+			},
+			"oidc_discovery_ca_pem": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM-encoded CA certificate used to verify the certificates of the OIDC provider.",
+			},
+			"oidc_client_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Client ID of Vault at the OIDC provider.",
+			},
+			"oidc_client_secret": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Client secret of Vault at the OIDC provider.",
+			},
+			"default_role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Role used when no role is given at login.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+// Config returns the configuration for this backend.
+func (b *backend) Config(s logical.Storage) (*oidcConfig, error) {
+	entry, err := s.Get("config")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result oidcConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathConfigRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	// The client secret is not returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"oidc_discovery_url":    config.DiscoveryURL,
+			"oidc_discovery_ca_pem": config.DiscoveryCAPEM,
+			"oidc_client_id":        config.ClientID,
+			"default_role":          config.DefaultRole,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &oidcConfig{
+		DiscoveryURL:   d.Get("oidc_discovery_url").(string),
+		DiscoveryCAPEM: d.Get("oidc_discovery_ca_pem").(string),
+		ClientID:       d.Get("oidc_client_id").(string),
+		ClientSecret:   d.Get("oidc_client_secret").(string),
+		DefaultRole:    d.Get("default_role").(string),
+	}
+
+	switch {
+	case config.DiscoveryURL == "":
+		return logical.ErrorResponse("missing oidc_discovery_url"), nil
+	case config.ClientID == "":
+		return logical.ErrorResponse("missing oidc_client_id"), nil
+	}
+
+	// Verify that the provider can be discovered
+	if _, err := newProvider(config); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("error checking oidc_discovery_url: %s", err)), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	b.resetProvider()
+
+	return nil, nil
+}
+
+// oidcConfig is the configuration of the OIDC provider
+type oidcConfig struct {
+	DiscoveryURL   string `json:"oidc_discovery_url"`
+	DiscoveryCAPEM string `json:"oidc_discovery_ca_pem"`
+	ClientID       string `json:"oidc_client_id"`
+	ClientSecret   string `json:"oidc_client_secret"`
+	DefaultRole    string `json:"default_role"`
+}
+
+const pathConfigHelpSyn = `
+Configure the OIDC provider used for authentication.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures the OpenID Connect provider the users authenticate
+with. The "oidc_discovery_url" parameter is the issuer URL of the provider,
+whose endpoints and signing keys are discovered from the
+"/.well-known/openid-configuration" document, and "oidc_discovery_ca_pem" the
+CA certificate used to verify its certificates.
+
+The "oidc_client_id" and "oidc_client_secret" parameters are the credentials
+of the client registered for Vault at the provider. The "default_role"
+parameter is the role used when none is given at login.
+`
@@ -0,0 +1,312 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/oauth2"
+)
+
+func pathAuthURL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `oidc/auth_url`,
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Role to authenticate against. Defaults to the default role of the configuration.",
+			},
+			"redirect_uri": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Redirect URI of the authorization flow, which must be allowed by the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathAuthURL,
+		},
+
+		HelpSynopsis:    pathAuthURLHelpSyn,
+		HelpDescription: pathAuthURLHelpDesc,
+	}
+}
+
+func pathCallback(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `oidc/callback`,
+		Fields: map[string]*framework.FieldSchema{
+			"state": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "State parameter returned by the OIDC provider.",
+			},
+			"code": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Authorization code returned by the OIDC provider.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCallback,
+		},
+
+		HelpSynopsis:    pathCallbackHelpSyn,
+		HelpDescription: pathCallbackHelpDesc,
+	}
+}
+
+// oauth2Config returns the OAuth 2.0 configuration of the authorization flow
+func oauth2Config(config *oidcConfig, p *provider, role *roleEntry, redirectURI string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthURL,
+			TokenURL: p.TokenURL,
+		},
+		RedirectURL: redirectURI,
+		Scopes:      append([]string{"openid"}, role.OIDCScopes...),
+	}
+}
+
+// Returns the URL of the authorization flow at the OIDC provider
+func (b *backend) pathAuthURL(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse(errNotConfigured.Error()), nil
+	}
+
+	roleName := d.Get("role").(string)
+	if roleName == "" {
+		roleName = config.DefaultRole
+	}
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+	role, err := b.Role(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", roleName)), nil
+	}
+
+	redirectURI := d.Get("redirect_uri").(string)
+	if redirectURI == "" {
+		return logical.ErrorResponse("missing redirect_uri"), nil
+	}
+	if !strutil.StrListContains(role.AllowedRedirectURIs, redirectURI) {
+		return logical.ErrorResponse(fmt.Sprintf("redirect_uri %q is not allowed by role %q", redirectURI, roleName)), nil
+	}
+
+	p, err := b.Provider(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	stateID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	b.putState(stateID, &oidcState{
+		role:        roleName,
+		redirectURI: redirectURI,
+		nonce:       nonce,
+		expiration:  time.Now().Add(stateTTL),
+	})
+
+	authURL := oauth2Config(config, p, role, redirectURI).AuthCodeURL(stateID, oauth2.SetAuthURLParam("nonce", nonce))
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"auth_url": authURL,
+		},
+	}, nil
+}
+
+// Exchanges the authorization code for an ID token, and logs the user in
+func (b *backend) pathCallback(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	state := b.takeState(d.Get("state").(string))
+	if state == nil {
+		return logical.ErrorResponse("expired or unknown state"), nil
+	}
+	code := d.Get("code").(string)
+	if code == "" {
+		return logical.ErrorResponse("missing code"), nil
+	}
+
+	config, err := b.Config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse(errNotConfigured.Error()), nil
+	}
+	role, err := b.Role(req.Storage, state.role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", state.role)), nil
+	}
+	p, err := b.Provider(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, p.client)
+	token, err := oauth2Config(config, p, role, state.redirectURI).Exchange(ctx, code)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("error exchanging the authorization code: %s", err)), nil
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return logical.ErrorResponse("no ID token returned by the OIDC provider"), nil
+	}
+
+	audiences := role.BoundAudiences
+	if len(audiences) == 0 {
+		audiences = []string{config.ClientID}
+	}
+	claims, err := p.verifyIDToken(rawIDToken, audiences)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("error validating the ID token: %s", err)), nil
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != state.nonce {
+		return logical.ErrorResponse("invalid ID token nonce"), nil
+	}
+
+	username, ok := claims[role.UserClaim].(string)
+	if !ok || username == "" {
+		return logical.ErrorResponse(fmt.Sprintf("claim %q not found in the ID token", role.UserClaim)), nil
+	}
+
+	metadata := map[string]string{
+		"role":     state.role,
+		"username": username,
+	}
+	for claim, key := range role.ClaimMappings {
+		value, err := claimString(claims[claim])
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("claim %q: %s", claim, err)), nil
+		}
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+
+	var groups []string
+	if role.GroupsClaim != "" {
+		switch v := claims[role.GroupsClaim].(type) {
+		case nil:
+		case string:
+			groups = []string{v}
+		case []interface{}:
+			for _, group := range v {
+				name, ok := group.(string)
+				if !ok {
+					return logical.ErrorResponse(fmt.Sprintf("claim %q is not a list of strings", role.GroupsClaim)), nil
+				}
+				groups = append(groups, name)
+			}
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("claim %q is not a list of strings", role.GroupsClaim)), nil
+		}
+	}
+	groupPolicies, err := b.GroupMap.Policies(req.Storage, groups...)
+	if err != nil {
+		return nil, err
+	}
+	policies := policyutil.SanitizePolicies(append(append([]string{}, role.Policies...), groupPolicies...), true)
+	sort.Strings(policies)
+	if len(groups) > 0 {
+		metadata["groups"] = strings.Join(groups, ",")
+	}
+
+	return &logical.Response{
+		Auth: &logical.Auth{
+			Policies:    policies,
+			Metadata:    metadata,
+			DisplayName: username,
+			LeaseOptions: logical.LeaseOptions{
+				TTL:       role.TTL,
+				Renewable: true,
+			},
+		},
+	}, nil
+}
+
+// claimString returns the value of a claim as metadata
+func claimString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, float64:
+		return fmt.Sprint(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+// The ID token is not kept, so renewals only check that the role still exists
+func (b *backend) pathLoginRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.Auth == nil {
+		return nil, fmt.Errorf("request auth was nil")
+	}
+
+	roleName := req.Auth.Metadata["role"]
+	role, err := b.Role(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %q does not exist during renewal", roleName)
+	}
+
+	return framework.LeaseExtend(role.TTL, role.MaxTTL, b.System())(req, d)
+}
+
+const pathAuthURLHelpSyn = `
+Start the authorization flow of the OIDC provider.
+`
+
+const pathAuthURLHelpDesc = `
+This endpoint returns the URL of the authorization flow at the OIDC provider,
+which the user opens in a browser. The provider then redirects the browser to
+"redirect_uri", which must be allowed by the role, with the "state" and
+"code" parameters to send to the "oidc/callback" endpoint. The flow must be
+completed within five minutes.
+`
+
+const pathCallbackHelpSyn = `
+Complete the authorization flow of the OIDC provider and log in.
+`
+
+const pathCallbackHelpDesc = `
+This endpoint exchanges the authorization code returned by the OIDC provider
+for an ID token, verifies it, and returns a Vault token mapped from its claims
+by the role the flow was started for.
+`
@@ -0,0 +1,248 @@
+package oidc
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/?$",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+			"allowed_redirect_uris": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of the redirect URIs allowed for the authorization flow.",
+			},
+			"bound_audiences": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the audiences the ID tokens must
+have one of. Defaults to the client ID of Vault.`,
+			},
+			"user_claim": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "sub",
+				Description: "Claim used as the name of the user.",
+			},
+			"groups_claim": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Claim listing the groups of the user, which are mapped to
+policies by the "map/groups/" paths.`,
+			},
+			"claim_mappings": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of "claim=key" pairs, setting the
+metadata key of the token to the value of the claim.`,
+			},
+			"oidc_scopes": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Comma-separated list of the scopes requested in addition to "openid".`,
+			},
+			"policies": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of the policies of the tokens.",
+			},
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "TTL of the tokens. Defaults to the system default.",
+			},
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum TTL of the tokens. Defaults to the system maximum.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleUpdate,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+// Role reads the role from the storage
+func (b *backend) Role(s logical.Storage, n string) (*roleEntry, error) {
+	entry, err := s.Get("role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathRoleDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return nil, req.Storage.Delete("role/" + d.Get("name").(string))
+}
+
+func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := b.Role(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	var claimMappings []string
+	for claim, key := range role.ClaimMappings {
+		claimMappings = append(claimMappings, claim+"="+key)
+	}
+	sort.Strings(claimMappings)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"allowed_redirect_uris": role.AllowedRedirectURIs,
+			"bound_audiences":       role.BoundAudiences,
+			"user_claim":            role.UserClaim,
+			"groups_claim":          role.GroupsClaim,
+			"claim_mappings":        strings.Join(claimMappings, ","),
+			"oidc_scopes":           role.OIDCScopes,
+			"policies":              role.Policies,
+			"ttl":                   role.TTL / time.Second,
+			"max_ttl":               role.MaxTTL / time.Second,
+		},
+	}, nil
+}
+
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(roles), nil
+}
+
+func (b *backend) pathRoleUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	role := &roleEntry{
+		AllowedRedirectURIs: parseList(d.Get("allowed_redirect_uris").(string)),
+		BoundAudiences:      parseList(d.Get("bound_audiences").(string)),
+		UserClaim:           d.Get("user_claim").(string),
+		GroupsClaim:         d.Get("groups_claim").(string),
+		OIDCScopes:          parseList(d.Get("oidc_scopes").(string)),
+		Policies:            policyutil.ParsePolicies(d.Get("policies").(string)),
+		TTL:                 time.Duration(d.Get("ttl").(int)) * time.Second,
+		MaxTTL:              time.Duration(d.Get("max_ttl").(int)) * time.Second,
+	}
+
+	if len(role.AllowedRedirectURIs) == 0 {
+		return logical.ErrorResponse("missing allowed_redirect_uris"), nil
+	}
+	for _, uri := range role.AllowedRedirectURIs {
+		if _, err := url.Parse(uri); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid redirect URI %q: %s", uri, err)), nil
+		}
+	}
+	if role.UserClaim == "" {
+		return logical.ErrorResponse("missing user_claim"), nil
+	}
+
+	role.ClaimMappings = make(map[string]string)
+	keys := make(map[string]bool)
+	for _, mapping := range parseList(d.Get("claim_mappings").(string)) {
+		split := strings.SplitN(mapping, "=", 2)
+		if len(split) != 2 || split[0] == "" || split[1] == "" {
+			return logical.ErrorResponse(fmt.Sprintf("invalid claim mapping %q", mapping)), nil
+		}
+		claim, key := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+		if key == "role" || key == "username" || key == "groups" || keys[key] {
+			return logical.ErrorResponse(fmt.Sprintf("metadata key %q is reserved or mapped more than once", key)), nil
+		}
+		keys[key] = true
+		role.ClaimMappings[claim] = key
+	}
+
+	if role.MaxTTL > 0 && role.TTL > role.MaxTTL {
+		return logical.ErrorResponse("ttl should not be greater than max_ttl"), nil
+	}
+	if _, _, err := b.SanitizeTTL(role.TTL, role.MaxTTL); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("role/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// parseList parses a comma-separated list, keeping the case of the elements
+func parseList(s string) []string {
+	var result []string
+	for _, elem := range strutil.ParseStringSlice(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			result = append(result, elem)
+		}
+	}
+	return result
+}
+
+// roleEntry defines how the ID tokens of the OIDC provider map to Vault
+// tokens
+type roleEntry struct {
+	AllowedRedirectURIs []string          `json:"allowed_redirect_uris"`
+	BoundAudiences      []string          `json:"bound_audiences"`
+	UserClaim           string            `json:"user_claim"`
+	GroupsClaim         string            `json:"groups_claim"`
+	ClaimMappings       map[string]string `json:"claim_mappings"`
+	OIDCScopes          []string          `json:"oidc_scopes"`
+	Policies            []string          `json:"policies"`
+	TTL                 time.Duration     `json:"ttl"`
+	MaxTTL              time.Duration     `json:"max_ttl"`
+}
+
+const pathRoleHelpSyn = `
+Manage the roles users authenticate against.
+`
+
+const pathRoleHelpDesc = `
+This endpoint manages the roles users authenticate against. The
+"allowed_redirect_uris" parameter lists the redirect URIs the authorization
+flow can use, such as "http://localhost:8250/oidc/callback" for the CLI. The
+ID tokens must have one of the "bound_audiences" audiences, the client ID of
+Vault by default.
+
+The "user_claim" claim of the ID token is the name of the user, used as the
+display name of the Vault token and as its "username" metadata. The
+"claim_mappings" parameter copies other claims to the metadata of the token,
+and the groups listed by the "groups_claim" claim are mapped to policies by
+the "map/groups/" paths, in addition to the "policies" of the role.
+`
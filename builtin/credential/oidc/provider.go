@@ -0,0 +1,263 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/strutil"
+)
+
+var errNotConfigured = errors.New("OIDC backend not configured")
+
+// signingMethods are the supported signing algorithms of the ID tokens
+var signingMethods = map[string]crypto.SigningMethod{
+	"RS256": crypto.SigningMethodRS256,
+	"RS384": crypto.SigningMethodRS384,
+	"RS512": crypto.SigningMethodRS512,
+	"PS256": crypto.SigningMethodPS256,
+	"PS384": crypto.SigningMethodPS384,
+	"PS512": crypto.SigningMethodPS512,
+	"ES256": crypto.SigningMethodES256,
+	"ES384": crypto.SigningMethodES384,
+	"ES512": crypto.SigningMethodES512,
+}
+
+// provider is an OIDC provider, described by its discovery document
+type provider struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+
+	client *http.Client
+
+	// keys are the signing keys of the provider, by key ID. They are
+	// fetched again when an ID token is signed with an unknown key.
+	keys     map[string]interface{}
+	keysLock sync.Mutex
+}
+
+// newProvider discovers the OIDC provider of the configuration
+func newProvider(config *oidcConfig) (*provider, error) {
+	client := cleanhttp.DefaultClient()
+	if config.DiscoveryCAPEM != "" {
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM([]byte(config.DiscoveryCAPEM)) {
+			return nil, errors.New("could not parse oidc_discovery_ca_pem")
+		}
+		transport := cleanhttp.DefaultTransport()
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: certPool,
+		}
+		client.Transport = transport
+	}
+
+	p := &provider{
+		client: client,
+	}
+	wellKnown := strings.TrimSuffix(config.DiscoveryURL, "/") + "/.well-known/openid-configuration"
+	if err := p.get(wellKnown, p); err != nil {
+		return nil, fmt.Errorf("failed to discover the OIDC provider: %s", err)
+	}
+
+	switch {
+	case strings.TrimSuffix(p.Issuer, "/") != strings.TrimSuffix(config.DiscoveryURL, "/"):
+		return nil, fmt.Errorf("issuer %q of the OIDC provider does not match oidc_discovery_url", p.Issuer)
+	case p.AuthURL == "" || p.TokenURL == "" || p.JWKSURL == "":
+		return nil, errors.New("discovery document of the OIDC provider is missing endpoints")
+	}
+
+	return p, nil
+}
+
+// get decodes the JSON document at the URL
+func (p *provider) get(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// key returns the signing key of the provider with the ID
+func (p *provider) key(id string) (interface{}, error) {
+	p.keysLock.Lock()
+	defer p.keysLock.Unlock()
+
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+
+	// The keys may have been rotated
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.get(p.JWKSURL, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch the keys of the OIDC provider: %s", err)
+	}
+
+	p.keys = make(map[string]interface{})
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q of the OIDC provider: %s", jwk.KeyID, err)
+		}
+		if key != nil {
+			p.keys[jwk.KeyID] = key
+		}
+	}
+
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return key, nil
+}
+
+// verifyIDToken verifies the signature, the issuer, the audience and the
+// validity period of an ID token, and returns its claims
+func (p *provider) verifyIDToken(rawToken string, audiences []string) (map[string]interface{}, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed ID token header")
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("malformed ID token header")
+	}
+
+	method, ok := signingMethods[header.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Algorithm)
+	}
+	key, err := p.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jws.ParseJWT([]byte(rawToken))
+	if err != nil {
+		return nil, err
+	}
+	if err := token.Validate(key, method); err != nil {
+		return nil, fmt.Errorf("invalid ID token: %s", err)
+	}
+
+	claims := token.Claims()
+	if issuer, _ := claims.Issuer(); issuer != p.Issuer {
+		return nil, fmt.Errorf("invalid issuer %q", issuer)
+	}
+	if _, ok := claims.Expiration(); !ok {
+		return nil, errors.New("ID token has no expiration")
+	}
+	tokenAudiences, _ := claims.Audience()
+	allowed := false
+	for _, aud := range tokenAudiences {
+		if strutil.StrListContains(audiences, aud) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("invalid audience %q", tokenAudiences)
+	}
+
+	return map[string]interface{}(claims), nil
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC keys
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// publicKey returns the public key, or nil if its type is unsupported
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
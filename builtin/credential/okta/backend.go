@@ -2,6 +2,7 @@ package okta
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -19,6 +20,7 @@ func Backend() *backend {
 		PathsSpecial: &logical.Paths{
 			Unauthenticated: []string{
 				"login/*",
+				"verify/*",
 			},
 		},
 
@@ -29,19 +31,31 @@ func Backend() *backend {
 			pathUsersList(&b),
 			pathGroupsList(&b),
 			pathLogin(&b),
+			pathVerify(&b),
 		}),
 
 		AuthRenew: b.pathLoginRenew,
 	}
 
+	b.challenges = make(map[string]int)
+
 	return &b
 }
 
 type backend struct {
 	*framework.Backend
+
+	// challenges maps the nonces of the logins waiting for an Okta Verify
+	// push with number challenge to the number to be chosen by the user
+	challenges     map[string]int
+	challengesLock sync.Mutex
 }
 
-func (b *backend) Login(req *logical.Request, username string, password string) ([]string, *logical.Response, error) {
+// Login authenticates the user with Okta, and returns the policies of the
+// user. If Okta requires a second factor, the user must accept an Okta
+// Verify push, unless verifyMFA is false. The nonce identifies the login in
+// the verify endpoint, where the number challenges of the pushes are shown.
+func (b *backend) Login(req *logical.Request, username, password, nonce string, verifyMFA bool) ([]string, *logical.Response, error) {
 	cfg, err := b.Config(req.Storage)
 	if err != nil {
 		return nil, nil, err
@@ -50,13 +64,30 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 		return nil, logical.ErrorResponse("Okta backend not configured"), nil
 	}
 
-	client := cfg.OktaClient()
-	auth, err := client.Authenticate(username, password)
+	client := cfg.client()
+	auth, err := client.authenticate(username, password)
 	if err != nil {
 		return nil, logical.ErrorResponse(fmt.Sprintf("Okta auth failed: %v", err)), nil
 	}
-	if auth == nil {
-		return nil, logical.ErrorResponse("okta auth backend unexpected failure"), nil
+
+	switch {
+	case auth.Status == authnStatusSuccess:
+	case auth.Status == authnStatusMFARequired && !verifyMFA:
+		// The password was accepted, which is enough to renew the tokens
+	case auth.Status == authnStatusMFARequired:
+		factor := pushFactor(auth)
+		if factor == nil {
+			return nil, logical.ErrorResponse("Okta auth failed: MFA required, but the user has no Okta Verify push factor"), nil
+		}
+		auth, err = client.verifyPush(auth, factor, cfg.pushTimeout(), func(answer int) {
+			b.setChallenge(nonce, answer)
+		})
+		b.deleteChallenge(nonce)
+		if err != nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("Okta auth failed: %v", err)), nil
+		}
+	default:
+		return nil, logical.ErrorResponse(fmt.Sprintf("Okta auth failed: unexpected status %q", auth.Status)), nil
 	}
 
 	oktaGroups, err := b.getOktaGroups(cfg, auth.Embedded.User.ID)
@@ -117,25 +148,41 @@ func (b *backend) Login(req *logical.Request, username string, password string)
 
 func (b *backend) getOktaGroups(cfg *ConfigEntry, userID string) ([]string, error) {
 	if cfg.Token != "" {
-		client := cfg.OktaClient()
-		groups, err := client.Groups(userID)
-		if err != nil {
-			return nil, err
-		}
+		return cfg.client().groups(userID)
+	}
+	return nil, nil
+}
 
-		oktaGroups := make([]string, 0, len(*groups))
-		for _, group := range *groups {
-			oktaGroups = append(oktaGroups, group.Profile.Name)
+// pushFactor returns the Okta Verify push factor of the user, or nil
+func pushFactor(auth *authnResponse) *oktaFactor {
+	for i, factor := range auth.Embedded.Factors {
+		if factor.FactorType == factorTypePush && factor.Provider == factorProviderOkta {
+			return &auth.Embedded.Factors[i]
 		}
-		return oktaGroups, err
 	}
-	return nil, nil
+	return nil
+}
+
+func (b *backend) setChallenge(nonce string, answer int) {
+	if nonce == "" {
+		return
+	}
+	b.challengesLock.Lock()
+	defer b.challengesLock.Unlock()
+	b.challenges[nonce] = answer
+}
+
+func (b *backend) deleteChallenge(nonce string) {
+	b.challengesLock.Lock()
+	defer b.challengesLock.Unlock()
+	delete(b.challenges, nonce)
 }
 
 const backendHelp = `
 The Okta credential provider allows authentication querying,
 checking username and password, and associating policies.  If an api token is configure
-groups are pulled down from Okta.
+groups are pulled down from Okta. If Okta requires a second factor, the user
+must accept an Okta Verify push.
 
 Configuration of the connection is done through the "config" and "policies"
 endpoints by a user with root access. Authentication is then done
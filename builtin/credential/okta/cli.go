@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/api"
 	pwd "github.com/hashicorp/vault/helper/password"
 )
@@ -34,10 +36,34 @@ func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (string, error) {
 		}
 	}
 
+	nonce, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
 	data := map[string]interface{}{
 		"password": password,
+		"nonce":    nonce,
 	}
 
+	// If Okta requires an Okta Verify push with a number challenge, show
+	// the number to choose while the login waits
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Second):
+			}
+			verify, err := c.Logical().Read(fmt.Sprintf("auth/%s/verify/%s", mount, nonce))
+			if err == nil && verify != nil && verify.Data["correct_answer"] != nil {
+				fmt.Fprintf(os.Stderr, "When prompted, choose %v in Okta Verify\n", verify.Data["correct_answer"])
+				return
+			}
+		}
+	}()
+
 	path := fmt.Sprintf("auth/%s/login/%s", mount, username)
 	secret, err := c.Logical().Write(path, data)
 	if err != nil {
@@ -56,7 +82,8 @@ func (h *CLIHandler) Help() string {
 The Okta credential provider allows you to authenticate with Okta.
 To use it, first configure it through the "config" endpoint, and then
 login by specifying username and password. If password is not provided
-on the command line, it will be read from stdin.
+on the command line, it will be read from stdin. If Okta requires a second
+factor, accept the Okta Verify push sent to your device.
 
     Example: vault auth -method=okta username=john
 
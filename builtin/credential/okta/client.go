@@ -0,0 +1,252 @@
+package okta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// The statuses of the Okta authentication transactions, and the results of
+// their factor verifications
+const (
+	authnStatusSuccess       = "SUCCESS"
+	authnStatusMFARequired   = "MFA_REQUIRED"
+	authnStatusMFAChallenge  = "MFA_CHALLENGE"
+	factorResultWaiting      = "WAITING"
+	factorResultRejected     = "REJECTED"
+	factorResultTimeout      = "TIMEOUT"
+	factorTypePush           = "push"
+	factorProviderOkta       = "OKTA"
+	groupsPageLimit          = 200
+	defaultOktaAPIDomain     = "okta.com"
+	defaultOktaPushTimeout   = 60 * time.Second
+	defaultOktaPollingPeriod = 2 * time.Second
+)
+
+// oktaPollingPeriod is how often the result of a push verification is polled
+var oktaPollingPeriod = defaultOktaPollingPeriod
+
+// oktaAPIURL returns the URL of the Okta API of the organization
+var oktaAPIURL = func(org, domain string) string {
+	return fmt.Sprintf("https://%s.%s/api/v1/", org, domain)
+}
+
+// linkNextRegex matches the link to the next page in the Link headers of the
+// paginated Okta responses
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// oktaClient calls the Okta authentication and users APIs
+type oktaClient struct {
+	httpClient *http.Client
+	apiURL     string
+	token      string
+}
+
+// oktaError is an error response of the Okta API
+type oktaError struct {
+	ErrorCode    string `json:"errorCode"`
+	ErrorSummary string `json:"errorSummary"`
+}
+
+func (e *oktaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.ErrorCode, e.ErrorSummary)
+}
+
+type oktaLink struct {
+	Href string `json:"href"`
+}
+
+type oktaFactor struct {
+	ID         string `json:"id"`
+	FactorType string `json:"factorType"`
+	Provider   string `json:"provider"`
+	Links      struct {
+		Verify oktaLink `json:"verify"`
+	} `json:"_links"`
+	Embedded struct {
+		Challenge struct {
+			CorrectAnswer int `json:"correctAnswer"`
+		} `json:"challenge"`
+	} `json:"_embedded"`
+}
+
+// authnResponse is the state of an Okta authentication transaction
+type authnResponse struct {
+	Status       string `json:"status"`
+	StateToken   string `json:"stateToken"`
+	FactorResult string `json:"factorResult"`
+	Embedded     struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		Factors []oktaFactor `json:"factors"`
+		Factor  oktaFactor   `json:"factor"`
+	} `json:"_embedded"`
+	Links struct {
+		Next oktaLink `json:"next"`
+	} `json:"_links"`
+}
+
+type oktaGroup struct {
+	Profile struct {
+		Name string `json:"name"`
+	} `json:"profile"`
+}
+
+// client returns a client of the Okta API of the organization
+func (c *ConfigEntry) client() *oktaClient {
+	domain := c.BaseURL
+	if domain == "" {
+		domain = defaultOktaAPIDomain
+	}
+	return &oktaClient{
+		httpClient: cleanhttp.DefaultClient(),
+		apiURL:     oktaAPIURL(c.Org, domain),
+		token:      c.Token,
+	}
+}
+
+// call sends the request to the Okta API, and decodes the response. It
+// returns the URL of the next page of paginated responses.
+func (c *oktaClient) call(method, url string, body, out interface{}) (string, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = c.apiURL + url
+	}
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return "", err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "SSWS "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oktaErr oktaError
+		if err := json.Unmarshal(respBody, &oktaErr); err != nil || oktaErr.ErrorCode == "" {
+			return "", fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+		}
+		return "", &oktaErr
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return "", err
+	}
+
+	var next string
+	for _, link := range resp.Header["Link"] {
+		if match := linkNextRegex.FindStringSubmatch(link); match != nil {
+			next = match[1]
+		}
+	}
+	return next, nil
+}
+
+// authenticate starts the authentication transaction of the user
+func (c *oktaClient) authenticate(username, password string) (*authnResponse, error) {
+	var resp authnResponse
+	_, err := c.call("POST", "authn", map[string]interface{}{
+		"username": username,
+		"password": password,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// verifyPush sends a push notification to the Okta Verify application of the
+// user, and waits for the user to accept it. If the factor has a number
+// challenge, its correct answer is passed to the challenge function, to be
+// shown to the user.
+func (c *oktaClient) verifyPush(auth *authnResponse, factor *oktaFactor, timeout time.Duration, challenge func(int)) (*authnResponse, error) {
+	request := map[string]interface{}{
+		"stateToken": auth.StateToken,
+	}
+
+	var resp authnResponse
+	if _, err := c.call("POST", factor.Links.Verify.Href, request, &resp); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	challenged := false
+	for resp.Status == authnStatusMFAChallenge {
+		switch resp.FactorResult {
+		case factorResultWaiting:
+		case factorResultRejected:
+			return nil, fmt.Errorf("Okta Verify push rejected")
+		case factorResultTimeout:
+			return nil, fmt.Errorf("Okta Verify push timed out")
+		default:
+			return nil, fmt.Errorf("unexpected Okta Verify push result %q", resp.FactorResult)
+		}
+
+		if answer := resp.Embedded.Factor.Embedded.Challenge.CorrectAnswer; answer != 0 && !challenged && challenge != nil {
+			challenge(answer)
+			challenged = true
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Okta Verify push not accepted within %s", timeout)
+		}
+		time.Sleep(oktaPollingPeriod)
+
+		next := resp.Links.Next.Href
+		if next == "" {
+			next = factor.Links.Verify.Href
+		}
+		resp = authnResponse{}
+		if _, err := c.call("POST", next, request, &resp); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.Status != authnStatusSuccess {
+		return nil, fmt.Errorf("unexpected Okta authentication status %q", resp.Status)
+	}
+	return &resp, nil
+}
+
+// groups returns the names of the groups of the user, following the
+// pagination of the Okta groups API
+func (c *oktaClient) groups(userID string) ([]string, error) {
+	var names []string
+	next := fmt.Sprintf("users/%s/groups?limit=%d", userID, groupsPageLimit)
+	for next != "" {
+		var page []oktaGroup
+		var err error
+		if next, err = c.call("GET", next, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, group := range page {
+			names = append(names, group.Profile.Name)
+		}
+	}
+	return names, nil
+}
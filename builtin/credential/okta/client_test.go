@@ -0,0 +1,231 @@
+package okta
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testOktaServer is a fake Okta API. The user "push" must accept an Okta
+// Verify push with a number challenge, and the groups are returned one per
+// page.
+type testOktaServer struct {
+	*httptest.Server
+
+	sync.Mutex
+	accepted bool
+}
+
+func newTestOktaServer(t *testing.T) *testOktaServer {
+	s := &testOktaServer{}
+	mux := http.NewServeMux()
+	write := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	user := map[string]interface{}{"id": "user1"}
+
+	mux.HandleFunc("/api/v1/authn", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["password"] != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			write(w, map[string]string{"errorCode": "E0000004", "errorSummary": "Authentication failed"})
+			return
+		}
+		if req["username"] != "push" {
+			write(w, map[string]interface{}{
+				"status":    "SUCCESS",
+				"_embedded": map[string]interface{}{"user": user},
+			})
+			return
+		}
+		write(w, map[string]interface{}{
+			"status":     "MFA_REQUIRED",
+			"stateToken": "state",
+			"_embedded": map[string]interface{}{
+				"user": user,
+				"factors": []interface{}{
+					map[string]interface{}{
+						"id":         "sms1",
+						"factorType": "sms",
+						"provider":   "OKTA",
+					},
+					map[string]interface{}{
+						"id":         "push1",
+						"factorType": "push",
+						"provider":   "OKTA",
+						"_links": map[string]interface{}{
+							"verify": map[string]string{"href": s.URL + "/api/v1/authn/factors/push1/verify"},
+						},
+					},
+				},
+			},
+		})
+	})
+
+	mux.HandleFunc("/api/v1/authn/factors/push1/verify", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["stateToken"] != "state" {
+			w.WriteHeader(http.StatusForbidden)
+			write(w, map[string]string{"errorCode": "E0000011", "errorSummary": "Invalid token provided"})
+			return
+		}
+
+		s.Lock()
+		accepted := s.accepted
+		s.Unlock()
+		if accepted {
+			write(w, map[string]interface{}{
+				"status":    "SUCCESS",
+				"_embedded": map[string]interface{}{"user": user},
+			})
+			return
+		}
+		write(w, map[string]interface{}{
+			"status":       "MFA_CHALLENGE",
+			"stateToken":   "state",
+			"factorResult": "WAITING",
+			"_embedded": map[string]interface{}{
+				"factor": map[string]interface{}{
+					"_embedded": map[string]interface{}{
+						"challenge": map[string]int{"correctAnswer": 42},
+					},
+				},
+			},
+			"_links": map[string]interface{}{
+				"next": map[string]string{"href": s.URL + "/api/v1/authn/factors/push1/verify"},
+			},
+		})
+	})
+
+	mux.HandleFunc("/api/v1/users/user1/groups", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "SSWS token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("after") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v1/users/user1/groups?after=group1&limit=200>; rel="next"`, s.URL))
+			write(w, []interface{}{map[string]interface{}{"profile": map[string]string{"name": "group1"}}})
+			return
+		}
+		write(w, []interface{}{map[string]interface{}{"profile": map[string]string{"name": "group2"}}})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func TestBackend_pushAndGroupsPagination(t *testing.T) {
+	s := newTestOktaServer(t)
+	defer s.Close()
+
+	defer func(apiURL func(string, string) string, period time.Duration) {
+		oktaAPIURL = apiURL
+		oktaPollingPeriod = period
+	}(oktaAPIURL, oktaPollingPeriod)
+	oktaAPIURL = func(org, domain string) string {
+		return s.URL + "/api/v1/"
+	}
+	oktaPollingPeriod = 10 * time.Millisecond
+
+	b, err := Factory(&logical.BackendConfig{
+		System: &logical.StaticSystemView{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := &logical.InmemStorage{}
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	handle(logical.CreateOperation, "config", map[string]interface{}{
+		"organization": "test",
+		"token":        "token",
+	})
+	handle(logical.UpdateOperation, "groups/group1", map[string]interface{}{"policies": "policy1"})
+	handle(logical.UpdateOperation, "groups/group2", map[string]interface{}{"policies": "policy2"})
+
+	resp := handle(logical.UpdateOperation, "login/user", map[string]interface{}{"password": "wrong"})
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "E0000004") {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The groups of all the pages are fetched
+	resp = handle(logical.UpdateOperation, "login/user", map[string]interface{}{"password": "password"})
+	if resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if !policyutil.EquivalentPolicies(resp.Auth.Policies, []string{"policy1", "policy2"}) {
+		t.Fatalf("bad: policies: %v", resp.Auth.Policies)
+	}
+
+	// The login waits for the push to be accepted, and the number challenge
+	// is shown by the verify endpoint meanwhile
+	done := make(chan *logical.Response)
+	go func() {
+		resp, _ := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login/push",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password": "password",
+				"nonce":    "nonce1",
+			},
+		})
+		done <- resp
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp := handle(logical.ReadOperation, "verify/nonce1", nil)
+		if resp != nil && resp.Data["correct_answer"] == 42 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("number challenge not shown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Lock()
+	s.accepted = true
+	s.Unlock()
+
+	resp = <-done
+	if resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp := handle(logical.ReadOperation, "verify/nonce1", nil); resp != nil {
+		t.Fatalf("expected the challenge to be deleted, got %#v", resp)
+	}
+
+	// The login fails if the push is not accepted in time
+	s.Lock()
+	s.accepted = false
+	s.Unlock()
+	handle(logical.UpdateOperation, "config", map[string]interface{}{"push_timeout": 1})
+	resp = handle(logical.UpdateOperation, "login/push", map[string]interface{}{"password": "password"})
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "not accepted") {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
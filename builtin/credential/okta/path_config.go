@@ -3,10 +3,10 @@ package okta
 import (
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
-	"github.com/sstarcher/go-okta"
 )
 
 func pathConfig(b *backend) *framework.Path {
@@ -26,6 +26,10 @@ func pathConfig(b *backend) *framework.Path {
 				Description: `The API endpoint to use. Useful if you
 are using Okta development accounts.`,
 			},
+			"push_timeout": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "How long to wait for the user to accept an Okta Verify push. Defaults to 60 seconds.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -73,8 +77,9 @@ func (b *backend) pathConfigRead(
 
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"Org":     cfg.Org,
-			"BaseURL": cfg.BaseURL,
+			"Org":          cfg.Org,
+			"BaseURL":      cfg.BaseURL,
+			"push_timeout": int64(cfg.PushTimeout / time.Second),
 		},
 	}
 
@@ -118,6 +123,13 @@ func (b *backend) pathConfigWrite(
 		cfg.BaseURL = d.Get("base_url").(string)
 	}
 
+	if pushTimeout, ok := d.GetOk("push_timeout"); ok {
+		if pushTimeout.(int) < 0 {
+			return logical.ErrorResponse("push_timeout cannot be negative"), nil
+		}
+		cfg.PushTimeout = time.Duration(pushTimeout.(int)) * time.Second
+	}
+
 	jsonCfg, err := logical.StorageEntryJSON("config", cfg)
 	if err != nil {
 		return nil, err
@@ -139,18 +151,13 @@ func (b *backend) pathConfigExistenceCheck(
 	return cfg != nil, nil
 }
 
-// OktaClient creates a basic okta client connection
-func (c *ConfigEntry) OktaClient() *okta.Client {
-	client := okta.NewClient(c.Org)
-	if c.BaseURL != "" {
-		client.Url = c.BaseURL
+// pushTimeout returns how long to wait for the user to accept an Okta Verify
+// push
+func (c *ConfigEntry) pushTimeout() time.Duration {
+	if c.PushTimeout == 0 {
+		return defaultOktaPushTimeout
 	}
-
-	if c.Token != "" {
-		client.ApiToken = c.Token
-	}
-
-	return client
+	return c.PushTimeout
 }
 
 // ConfigEntry for Okta
@@ -158,6 +165,10 @@ type ConfigEntry struct {
 	Org     string `json:"organization"`
 	Token   string `json:"token"`
 	BaseURL string `json:"base_url"`
+
+	// PushTimeout is how long to wait for the user to accept an Okta
+	// Verify push
+	PushTimeout time.Duration `json:"push_timeout"`
 }
 
 const pathConfigHelp = `
@@ -23,6 +23,13 @@ func pathLogin(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Password for this user.",
 			},
+
+			"nonce": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Random value identifying the login in the 'verify/<nonce>'
+endpoint, which returns the number to choose in Okta Verify if the push
+has a number challenge.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -38,8 +45,9 @@ func (b *backend) pathLogin(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	username := d.Get("username").(string)
 	password := d.Get("password").(string)
+	nonce := d.Get("nonce").(string)
 
-	policies, resp, err := b.Login(req, username, password)
+	policies, resp, err := b.Login(req, username, password, nonce, true)
 	// Handle an internal error
 	if err != nil {
 		return nil, err
@@ -78,7 +86,8 @@ func (b *backend) pathLoginRenew(
 	username := req.Auth.Metadata["username"]
 	password := req.Auth.InternalData["password"].(string)
 
-	loginPolicies, resp, err := b.Login(req, username, password)
+	// The second factor is not required again to renew the token
+	loginPolicies, resp, err := b.Login(req, username, password, "", false)
 	if len(loginPolicies) == 0 {
 		return resp, err
 	}
@@ -90,10 +99,60 @@ func (b *backend) pathLoginRenew(
 	return framework.LeaseExtend(0, 0, b.System())(req, d)
 }
 
+func pathVerify(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `verify/(?P<nonce>.+)`,
+		Fields: map[string]*framework.FieldSchema{
+			"nonce": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Nonce of the login.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathVerify,
+		},
+
+		HelpSynopsis:    pathVerifySyn,
+		HelpDescription: pathVerifyDesc,
+	}
+}
+
+func (b *backend) pathVerify(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	nonce := d.Get("nonce").(string)
+
+	b.challengesLock.Lock()
+	answer, ok := b.challenges[nonce]
+	b.challengesLock.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"correct_answer": answer,
+		},
+	}, nil
+}
+
 const pathLoginSyn = `
 Log in with a username and password.
 `
 
 const pathLoginDesc = `
-This endpoint authenticates using a username and password.
+This endpoint authenticates using a username and password. If Okta
+requires a second factor, the login waits for the user to accept an Okta
+Verify push, within the 'push_timeout' of the configuration.
+`
+
+const pathVerifySyn = `
+Read the number challenge of an Okta Verify push.
+`
+
+const pathVerifyDesc = `
+If Okta requires a second factor, the login waits for the user to accept an
+Okta Verify push. If the push has a number challenge, this endpoint returns
+the number to choose in Okta Verify while the login with the given nonce
+is waiting.
 `
@@ -43,6 +43,36 @@ func pathRoles() *framework.Path {
 				Type:        framework.TypeString,
 				Description: "IAM policy document",
 			},
+
+			"credential_type": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: fmt.Sprintf(`Type of the credentials generated from the role:
+%q, %q or %q. Cannot be combined with arn or policy.`, iamUserCred, assumedRoleCred, federationTokenCred),
+			},
+
+			"policy_arns": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of ARNs of the managed policies
+attached to the IAM users. Only valid with the iam_user credential type.`,
+			},
+
+			"policy_document": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `IAM policy document. It is the inline policy of the
+IAM users, or the policy of the assumed role sessions and federation tokens.`,
+			},
+
+			"role_arn": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `ARN of the IAM role to assume. Required with the
+assumed_role credential type.`,
+			},
+
+			"external_id": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `External ID passed when assuming the role. Only valid
+with the assumed_role credential type.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -85,6 +115,22 @@ func pathRolesRead(
 		return nil, nil
 	}
 
+	role, err := parseRoleEntry(entry.Value)
+	if err != nil {
+		return nil, err
+	}
+	if !role.legacy {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"credential_type": role.CredentialType,
+				"policy_arns":     role.PolicyArns,
+				"policy_document": role.PolicyDocument,
+				"role_arn":        role.RoleArn,
+				"external_id":     role.ExternalID,
+			},
+		}, nil
+	}
+
 	val := string(entry.Value)
 	if strings.HasPrefix(val, "arn:") {
 		return &logical.Response{
@@ -117,6 +163,10 @@ func pathRolesWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	var buf bytes.Buffer
 
+	if _, ok := d.GetOk("credential_type"); ok {
+		return pathRolesWriteCredentialType(req, d)
+	}
+
 	uip, err := useInlinePolicy(d)
 	if err != nil {
 		return nil, err
@@ -149,6 +199,164 @@ func pathRolesWrite(
 	return nil, nil
 }
 
+// pathRolesWriteCredentialType writes a role created with the
+// credential_type parameter
+func pathRolesWriteCredentialType(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if d.Get("policy").(string) != "" || d.Get("arn").(string) != "" {
+		return logical.ErrorResponse("credential_type cannot be combined with policy or arn"), nil
+	}
+
+	role := &awsRoleEntry{
+		CredentialType: d.Get("credential_type").(string),
+		RoleArn:        d.Get("role_arn").(string),
+		ExternalID:     d.Get("external_id").(string),
+	}
+	for _, arn := range strings.Split(d.Get("policy_arns").(string), ",") {
+		if arn = strings.TrimSpace(arn); arn != "" {
+			role.PolicyArns = append(role.PolicyArns, arn)
+		}
+	}
+	if policyDocument := d.Get("policy_document").(string); policyDocument != "" {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(policyDocument)); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error compacting policy_document: %s", err)), nil
+		}
+		role.PolicyDocument = buf.String()
+	}
+
+	if err := role.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("policy/"+d.Get("name").(string), role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const (
+	iamUserCred         = "iam_user"
+	assumedRoleCred     = "assumed_role"
+	federationTokenCred = "federation_token"
+)
+
+// awsRoleEntry is a role created with the credential_type parameter. Roles
+// created with the arn or policy parameters are stored as the bare ARN or
+// policy document instead, and converted by parseRoleEntry.
+type awsRoleEntry struct {
+	CredentialType string   `json:"credential_type"`
+	PolicyArns     []string `json:"policy_arns"`
+	PolicyDocument string   `json:"policy_document"`
+	RoleArn        string   `json:"role_arn"`
+	ExternalID     string   `json:"external_id"`
+
+	// legacy is set for the roles created with the arn or policy parameters
+	legacy bool
+}
+
+func (r *awsRoleEntry) validate() error {
+	switch r.CredentialType {
+	case iamUserCred:
+		if len(r.PolicyArns) == 0 && r.PolicyDocument == "" {
+			return fmt.Errorf("at least one of policy_arns or policy_document is required with the %s credential type", iamUserCred)
+		}
+	case assumedRoleCred:
+		if !strings.HasPrefix(r.RoleArn, "arn:") || !strings.Contains(r.RoleArn, ":role/") {
+			return fmt.Errorf("a valid role_arn is required with the %s credential type", assumedRoleCred)
+		}
+	case federationTokenCred:
+		if r.PolicyDocument == "" {
+			return fmt.Errorf("policy_document is required with the %s credential type", federationTokenCred)
+		}
+	default:
+		return fmt.Errorf("credential_type must be one of %q, %q or %q", iamUserCred, assumedRoleCred, federationTokenCred)
+	}
+
+	// Managed policies can only be attached to IAM users by this version of
+	// the AWS SDK
+	if len(r.PolicyArns) > 0 && r.CredentialType != iamUserCred {
+		return fmt.Errorf("policy_arns is only supported with the %s credential type", iamUserCred)
+	}
+	for _, arn := range r.PolicyArns {
+		if !strings.HasPrefix(arn, "arn:") {
+			return fmt.Errorf("invalid policy ARN %q", arn)
+		}
+	}
+	if r.RoleArn != "" && r.CredentialType != assumedRoleCred {
+		return fmt.Errorf("role_arn is only supported with the %s credential type", assumedRoleCred)
+	}
+	if r.ExternalID != "" && r.CredentialType != assumedRoleCred {
+		return fmt.Errorf("external_id is only supported with the %s credential type", assumedRoleCred)
+	}
+
+	return nil
+}
+
+// allowsCredentialType returns whether credentials of the given type can be
+// generated from the role. Roles created with the policy parameter generate
+// both IAM users and federation tokens.
+func (r *awsRoleEntry) allowsCredentialType(credentialType string) bool {
+	if r.CredentialType == "" {
+		return credentialType == iamUserCred || credentialType == federationTokenCred
+	}
+	return r.CredentialType == credentialType
+}
+
+// roleEntry returns the named role, or nil if it doesn't exist
+func roleEntry(s logical.Storage, name string) (*awsRoleEntry, error) {
+	entry, err := s.Get("policy/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	return parseRoleEntry(entry.Value)
+}
+
+// parseRoleEntry parses a stored role, converting the roles created with the
+// arn or policy parameters
+func parseRoleEntry(value []byte) (*awsRoleEntry, error) {
+	val := string(value)
+	switch {
+	case strings.HasPrefix(val, "arn:") && strings.Contains(val, ":role/"):
+		return &awsRoleEntry{
+			CredentialType: assumedRoleCred,
+			RoleArn:        val,
+			legacy:         true,
+		}, nil
+	case strings.HasPrefix(val, "arn:"):
+		return &awsRoleEntry{
+			CredentialType: iamUserCred,
+			PolicyArns:     []string{val},
+			legacy:         true,
+		}, nil
+	}
+
+	// IAM policy documents never have a credential_type element
+	var fields map[string]interface{}
+	if err := json.Unmarshal(value, &fields); err != nil || fields["credential_type"] == nil {
+		return &awsRoleEntry{
+			PolicyDocument: val,
+			legacy:         true,
+		}, nil
+	}
+
+	var role awsRoleEntry
+	if err := json.Unmarshal(value, &role); err != nil {
+		return nil, fmt.Errorf("error parsing role: %s", err)
+	}
+	return &role, nil
+}
+
 const pathListRolesHelpSyn = `List the existing roles in this backend`
 
 const pathListRolesHelpDesc = `Roles will be listed by the role name.`
@@ -170,5 +378,18 @@ reference (via the arn argument). Inline user policies written are normal
 IAM policies. Vault will not attempt to parse these except to validate
 that they're basic JSON. No validation is performed on arn references.
 
+Alternatively, the "credential_type" argument selects the type of the
+credentials generated from the role, along with the following arguments:
+
+  * "iam_user": IAM users, read from the "creds/" path, with the managed
+    policies of "policy_arns" attached and "policy_document" as inline policy.
+
+  * "assumed_role": sessions of the IAM role "role_arn", read from the "sts/"
+    path. "policy_document" further restricts the permissions of the
+    sessions, and "external_id" is passed to AWS when assuming the role.
+
+  * "federation_token": federation tokens with the permissions of
+    "policy_document", read from the "sts/" path.
+
 To validate the keys, attempt to read an access key after writing the policy.
 `
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 
@@ -62,3 +63,114 @@ func TestBackend_PathListRoles(t *testing.T) {
 		t.Fatalf("failed to list all 10 roles")
 	}
 }
+
+func TestBackend_roleCredentialType(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend()
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"credential_type": "unknown", "policy_document": testPolicy},
+		{"credential_type": "iam_user"},
+		{"credential_type": "iam_user", "policy_arns": "not-an-arn"},
+		{"credential_type": "iam_user", "policy_document": testPolicy, "external_id": "foo"},
+		{"credential_type": "assumed_role", "role_arn": "arn:aws:iam::123456789012:policy/foo"},
+		{"credential_type": "assumed_role", "role_arn": testRoleArn, "policy_arns": testPolicyArn},
+		{"credential_type": "federation_token", "role_arn": testRoleArn},
+		{"credential_type": "federation_token", "policy_document": testPolicy, "arn": testPolicyArn},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/test",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error writing %#v, got err:%v resp:%#v", data, err, resp)
+		}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"credential_type": "assumed_role",
+			"role_arn":        testRoleArn,
+			"external_id":     "foo",
+			"policy_document": `{ "Version": "2012-10-17" }`,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp:%#v\n err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/test",
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp:%#v\n err:%v", resp, err)
+	}
+	expected := map[string]interface{}{
+		"credential_type": "assumed_role",
+		"policy_arns":     []string(nil),
+		"policy_document": `{"Version":"2012-10-17"}`,
+		"role_arn":        testRoleArn,
+		"external_id":     "foo",
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Federation tokens can't be generated from an assumed role
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test",
+		Storage:   config.StorageView,
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err:%v resp:%#v", err, resp)
+	}
+}
+
+func TestParseRoleEntry(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected *awsRoleEntry
+	}{
+		{
+			testRoleArn,
+			&awsRoleEntry{CredentialType: assumedRoleCred, RoleArn: testRoleArn, legacy: true},
+		},
+		{
+			testPolicyArn,
+			&awsRoleEntry{CredentialType: iamUserCred, PolicyArns: []string{testPolicyArn}, legacy: true},
+		},
+		{
+			`{"Version":"2012-10-17"}`,
+			&awsRoleEntry{PolicyDocument: `{"Version":"2012-10-17"}`, legacy: true},
+		},
+		{
+			`{"credential_type":"iam_user","policy_arns":["` + testPolicyArn + `"]}`,
+			&awsRoleEntry{CredentialType: iamUserCred, PolicyArns: []string{testPolicyArn}},
+		},
+	}
+
+	for _, tc := range cases {
+		role, err := parseRoleEntry([]byte(tc.value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(role, tc.expected) {
+			t.Fatalf("bad: %q parsed as %#v", tc.value, role)
+		}
+	}
+}
+
+const testRoleArn = "arn:aws:iam::123456789012:role/test"
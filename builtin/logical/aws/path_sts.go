@@ -2,7 +2,6 @@ package aws
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -44,35 +43,39 @@ func (b *backend) pathSTSRead(
 	policyName := d.Get("name").(string)
 	ttl := int64(d.Get("ttl").(int))
 
-	// Read the policy
-	policy, err := req.Storage.Get("policy/" + policyName)
+	// Read the role
+	role, err := roleEntry(req.Storage, policyName)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving role: %s", err)
 	}
-	if policy == nil {
+	if role == nil {
 		return logical.ErrorResponse(fmt.Sprintf(
 			"Role '%s' not found", policyName)), nil
 	}
-	policyValue := string(policy.Value)
-	if strings.HasPrefix(policyValue, "arn:") {
-		if strings.Contains(policyValue, ":role/") {
-			return b.assumeRole(
-				req.Storage,
-				req.DisplayName, policyName, policyValue,
-				ttl,
-			)
-		} else {
-			return logical.ErrorResponse(
-					"Can't generate STS credentials for a managed policy; use a role to assume or an inline policy instead"),
-				logical.ErrInvalidRequest
-		}
+
+	switch {
+	case role.allowsCredentialType(assumedRoleCred):
+		return b.assumeRole(
+			req.Storage,
+			req.DisplayName, policyName, role,
+			ttl,
+		)
+	case role.allowsCredentialType(federationTokenCred):
+		// Use the helper to create the secret
+		return b.secretTokenCreate(
+			req.Storage,
+			req.DisplayName, policyName, role.PolicyDocument,
+			ttl,
+		)
+	case role.legacy:
+		return logical.ErrorResponse(
+				"Can't generate STS credentials for a managed policy; use a role to assume or an inline policy instead"),
+			logical.ErrInvalidRequest
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+				"Can't generate STS credentials for a role of credential type %s", role.CredentialType)),
+			logical.ErrInvalidRequest
 	}
-	// Use the helper to create the secret
-	return b.secretTokenCreate(
-		req.Storage,
-		req.DisplayName, policyName, policyValue,
-		ttl,
-	)
 }
 
 const pathSTSHelpSyn = `
@@ -33,19 +33,24 @@ func (b *backend) pathUserRead(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	policyName := d.Get("name").(string)
 
-	// Read the policy
-	policy, err := req.Storage.Get("policy/" + policyName)
+	// Read the role
+	role, err := roleEntry(req.Storage, policyName)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving role: %s", err)
 	}
-	if policy == nil {
+	if role == nil {
 		return logical.ErrorResponse(fmt.Sprintf(
 			"Role '%s' not found", policyName)), nil
 	}
+	if !role.allowsCredentialType(iamUserCred) {
+		return logical.ErrorResponse(fmt.Sprintf(
+				"Can't generate IAM users for a role of credential type %s; use the sts path instead", role.CredentialType)),
+			logical.ErrInvalidRequest
+	}
 
 	// Use the helper to create the secret
 	return b.secretAccessKeysCreate(
-		req.Storage, req.DisplayName, policyName, string(policy.Value))
+		req.Storage, req.DisplayName, policyName, role)
 }
 
 func pathUserRollback(req *logical.Request, _kind string, data interface{}) error {
@@ -6,8 +6,6 @@ import (
 	"regexp"
 	"time"
 
-	"strings"
-
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sts"
@@ -110,7 +108,7 @@ func (b *backend) secretTokenCreate(s logical.Storage,
 }
 
 func (b *backend) assumeRole(s logical.Storage,
-	displayName, policyName, policy string,
+	displayName, policyName string, role *awsRoleEntry,
 	lifeTimeInSeconds int64) (*logical.Response, error) {
 	STSClient, err := clientSTS(s)
 	if err != nil {
@@ -119,12 +117,19 @@ func (b *backend) assumeRole(s logical.Storage,
 
 	username, usernameWarning := genUsername(displayName, policyName, "iam_user")
 
-	tokenResp, err := STSClient.AssumeRole(
-		&sts.AssumeRoleInput{
-			RoleSessionName: aws.String(username),
-			RoleArn:         aws.String(policy),
-			DurationSeconds: &lifeTimeInSeconds,
-		})
+	input := &sts.AssumeRoleInput{
+		RoleSessionName: aws.String(username),
+		RoleArn:         aws.String(role.RoleArn),
+		DurationSeconds: &lifeTimeInSeconds,
+	}
+	if role.PolicyDocument != "" {
+		input.Policy = aws.String(role.PolicyDocument)
+	}
+	if role.ExternalID != "" {
+		input.ExternalId = aws.String(role.ExternalID)
+	}
+
+	tokenResp, err := STSClient.AssumeRole(input)
 
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -137,7 +142,7 @@ func (b *backend) assumeRole(s logical.Storage,
 		"security_token": *tokenResp.Credentials.SessionToken,
 	}, map[string]interface{}{
 		"username": username,
-		"policy":   policy,
+		"policy":   role.RoleArn,
 		"is_sts":   true,
 	})
 
@@ -156,7 +161,7 @@ func (b *backend) assumeRole(s logical.Storage,
 
 func (b *backend) secretAccessKeysCreate(
 	s logical.Storage,
-	displayName, policyName string, policy string) (*logical.Response, error) {
+	displayName, policyName string, role *awsRoleEntry) (*logical.Response, error) {
 	client, err := clientIAM(s)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
@@ -184,23 +189,24 @@ func (b *backend) secretAccessKeysCreate(
 			"Error creating IAM user: %s", err)), nil
 	}
 
-	if strings.HasPrefix(policy, "arn:") {
+	for _, arn := range role.PolicyArns {
 		// Attach existing policy against user
 		_, err = client.AttachUserPolicy(&iam.AttachUserPolicyInput{
 			UserName:  aws.String(username),
-			PolicyArn: aws.String(policy),
+			PolicyArn: aws.String(arn),
 		})
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf(
 				"Error attaching user policy: %s", err)), nil
 		}
+	}
 
-	} else {
+	if role.PolicyDocument != "" {
 		// Add new inline user policy against user
 		_, err = client.PutUserPolicy(&iam.PutUserPolicyInput{
 			UserName:       aws.String(username),
 			PolicyName:     aws.String(policyName),
-			PolicyDocument: aws.String(policy),
+			PolicyDocument: aws.String(role.PolicyDocument),
 		})
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf(
@@ -231,7 +237,7 @@ func (b *backend) secretAccessKeysCreate(
 		"security_token": nil,
 	}, map[string]interface{}{
 		"username": username,
-		"policy":   policy,
+		"policy":   policyName,
 		"is_sts":   false,
 	})
 
@@ -0,0 +1,161 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// Factory creates and configures the backend
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend().Setup(conf)
+}
+
+// Backend creates a new backend with all the paths and secrets belonging to it
+func Backend() *backend {
+	var b backend
+	b.environments = environments
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		PathsSpecial: &logical.Paths{
+			LocalStorage: []string{
+				framework.WALPrefix,
+			},
+		},
+
+		Paths: []*framework.Path{
+			pathConfig(&b),
+			pathConfigLease(&b),
+			pathListRoles(&b),
+			pathRoles(&b),
+			pathCreds(&b),
+		},
+
+		Secrets: []*framework.Secret{
+			secretCreds(&b),
+		},
+
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: 5 * time.Minute,
+
+		Clean: b.resetClient,
+
+		Invalidate: b.invalidate,
+	}
+
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	client *client
+	lock   sync.RWMutex
+
+	// environments are the Azure clouds the backend can be configured for,
+	// replaced by the tests to point at a fake of the Azure APIs
+	environments map[string]azureEnvironment
+}
+
+// Client returns the client of the Azure APIs
+func (b *backend) Client(s logical.Storage) (*client, error) {
+	b.lock.RLock()
+
+	// If we already have a client, return it
+	if b.client != nil {
+		b.lock.RUnlock()
+		return b.client, nil
+	}
+
+	b.lock.RUnlock()
+
+	config, err := b.config(s)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("configure the Azure credentials with config first")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// If the client was created during the lock switch, return it
+	if b.client != nil {
+		return b.client, nil
+	}
+
+	b.client, err = newClient(config, b.environments)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.client, nil
+}
+
+// resetClient forces a new client next time Client() is called.
+func (b *backend) resetClient() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.client = nil
+}
+
+func (b *backend) invalidate(key string) {
+	switch key {
+	case "config":
+		b.resetClient()
+	}
+}
+
+// config returns the configuration, or nil if the backend is not configured
+// yet
+func (b *backend) config(s logical.Storage) (*azureConfig, error) {
+	entry, err := s.Get("config")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config azureConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Lease returns the lease information
+func (b *backend) Lease(s logical.Storage) (*configLease, error) {
+	entry, err := s.Get("config/lease")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result configLease
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+const backendHelp = `
+The Azure backend dynamically generates Azure service principals, or client
+secrets of existing Azure AD applications. The credentials have a configurable
+lease set and are automatically revoked at the end of the lease.
+
+After mounting this backend, the Azure credentials used to manage the service
+principals must be configured with the "config" path and roles must be
+written using the "roles/" endpoints before any credentials can be generated.
+`
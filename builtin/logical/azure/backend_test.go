@@ -0,0 +1,345 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+)
+
+const (
+	testTenantID       = "tenant"
+	testSubscriptionID = "subscription"
+	testRoleID         = "/subscriptions/subscription/providers/Microsoft.Authorization/roleDefinitions/contributor"
+	testScope          = "/subscriptions/subscription/resourceGroups/group"
+)
+
+// testServer is a fake of the Azure login, AD Graph and Resource Manager APIs
+type testServer struct {
+	sync.Mutex
+
+	apps        map[string]*testApp
+	sps         map[string]string
+	assignments map[string]string
+}
+
+type testApp struct {
+	AppID       string
+	Credentials []*passwordCredential
+}
+
+func newTestServer() *testServer {
+	return &testServer{
+		apps:        make(map[string]*testApp),
+		sps:         make(map[string]string),
+		assignments: make(map[string]string),
+	}
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.URL.Path == "/"+testTenantID+"/oauth2/token" {
+		if r.FormValue("client_id") != "admin" || r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token": "token", "expires_in": "3599"}`))
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	graphPrefix := "/" + testTenantID + "/applications"
+	path := r.URL.Path
+	switch {
+	case r.Method == "POST" && path == graphPrefix:
+		var body struct {
+			PasswordCredentials []*passwordCredential `json:"passwordCredentials"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n := len(s.apps)
+		objectID, appID := fmt.Sprintf("object-%d", n), fmt.Sprintf("app-%d", n)
+		s.apps[objectID] = &testApp{
+			AppID:       appID,
+			Credentials: body.PasswordCredentials,
+		}
+		json.NewEncoder(w).Encode(&application{ObjectID: objectID, AppID: appID})
+	case r.Method == "POST" && path == "/"+testTenantID+"/servicePrincipals":
+		var body application
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		spID := "sp-" + body.AppID
+		s.sps[spID] = body.AppID
+		json.NewEncoder(w).Encode(&application{ObjectID: spID})
+	case strings.HasPrefix(path, graphPrefix+"/"):
+		parts := strings.Split(strings.TrimPrefix(path, graphPrefix+"/"), "/")
+		app, ok := s.apps[parts[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch {
+		case r.Method == "GET" && len(parts) == 1:
+			json.NewEncoder(w).Encode(&application{ObjectID: parts[0], AppID: app.AppID})
+		case r.Method == "DELETE" && len(parts) == 1:
+			delete(s.apps, parts[0])
+			delete(s.sps, "sp-"+app.AppID)
+		case r.Method == "GET" && len(parts) == 2:
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": app.Credentials})
+		case r.Method == "PATCH" && len(parts) == 2:
+			var body struct {
+				Value []*passwordCredential `json:"value"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			app.Credentials = body.Value
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case r.Method == "GET" && strings.HasSuffix(path, "/roleDefinitions"):
+		if r.URL.Query().Get("$filter") != "roleName eq 'Contributor'" {
+			w.Write([]byte(`{"value": []}`))
+			return
+		}
+		fmt.Fprintf(w, `{"value": [{"id": %q, "properties": {"roleName": "Contributor"}}]}`, testRoleID)
+	case r.Method == "PUT" && strings.Contains(path, "/roleAssignments/"):
+		var body struct {
+			Properties struct {
+				RoleDefinitionID string `json:"roleDefinitionId"`
+				PrincipalID      string `json:"principalId"`
+			} `json:"properties"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.sps[body.Properties.PrincipalID]; !ok || body.Properties.RoleDefinitionID != testRoleID {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.assignments[path] = body.Properties.PrincipalID
+		fmt.Fprintf(w, `{"id": %q}`, path)
+	case r.Method == "DELETE" && strings.Contains(path, "/roleAssignments/"):
+		if _, ok := s.assignments[path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.assignments, path)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func testBackend(t *testing.T, url string) *backend {
+	b := Backend()
+	b.environments = map[string]azureEnvironment{
+		"TestCloud": {
+			LoginEndpoint:           url + "/",
+			GraphEndpoint:           url + "/",
+			ResourceManagerEndpoint: url + "/",
+		},
+	}
+	if _, err := b.Setup(logical.TestBackendConfig()); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBackend_config(t *testing.T) {
+	ts := httptest.NewServer(newTestServer())
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: testBackend(t, ts.URL),
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t),
+			testAccStepReadConfig(t),
+			testAccStepConfigEnvironment(t, "UnknownCloud", true),
+		},
+	})
+}
+
+func TestBackend_servicePrincipal(t *testing.T) {
+	server := newTestServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: testBackend(t, ts.URL),
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t),
+			testAccStepRole(t, "contributor", map[string]interface{}{
+				"azure_roles": `[{"role_name": "Unknown", "scope": "` + testScope + `"}]`,
+			}, true),
+			testAccStepRole(t, "contributor", map[string]interface{}{
+				"azure_roles": `[{"role_name": "Contributor", "scope": "` + testScope + `"}]`,
+			}, false),
+			testAccStepReadRole(t, "contributor", testRoleID),
+			testAccStepReadCreds(t, "contributor", func(clientID, clientSecret string) error {
+				server.Lock()
+				defer server.Unlock()
+				if clientID != "app-0" || clientSecret == "" {
+					return fmt.Errorf("bad: %q %q", clientID, clientSecret)
+				}
+				if len(server.apps) != 1 || len(server.sps) != 1 || len(server.assignments) != 1 {
+					return fmt.Errorf("bad: %#v", server)
+				}
+				if server.apps["object-0"].Credentials[0].Value != clientSecret {
+					return fmt.Errorf("bad: %#v", server.apps["object-0"])
+				}
+				return nil
+			}),
+		},
+	})
+
+	// Revoking the secret deletes the application and its role assignments
+	if len(server.apps) != 0 || len(server.sps) != 0 || len(server.assignments) != 0 {
+		t.Fatalf("bad: %#v", server)
+	}
+}
+
+func TestBackend_existingApplication(t *testing.T) {
+	server := newTestServer()
+	server.apps["existing"] = &testApp{
+		AppID: "existing-app",
+		Credentials: []*passwordCredential{
+			&passwordCredential{KeyID: "other"},
+		},
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: testBackend(t, ts.URL),
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t),
+			testAccStepRole(t, "existing", map[string]interface{}{
+				"application_object_id": "unknown",
+			}, true),
+			testAccStepRole(t, "existing", map[string]interface{}{
+				"application_object_id": "existing",
+			}, false),
+			testAccStepReadCreds(t, "existing", func(clientID, clientSecret string) error {
+				server.Lock()
+				defer server.Unlock()
+				if clientID != "existing-app" {
+					return fmt.Errorf("bad: %q", clientID)
+				}
+				if credentials := server.apps["existing"].Credentials; len(credentials) != 2 || credentials[1].Value != clientSecret {
+					return fmt.Errorf("bad: %#v", credentials)
+				}
+				return nil
+			}),
+		},
+	})
+
+	// Revoking the secret only removes the password it added
+	if credentials := server.apps["existing"].Credentials; len(credentials) != 1 || credentials[0].KeyID != "other" {
+		t.Fatalf("bad: %#v", credentials)
+	}
+}
+
+func testAccStepConfig(t *testing.T) logicaltest.TestStep {
+	return testAccStepConfigEnvironment(t, "TestCloud", false)
+}
+
+func testAccStepConfigEnvironment(t *testing.T, environment string, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"subscription_id": testSubscriptionID,
+			"tenant_id":       testTenantID,
+			"client_id":       "admin",
+			"client_secret":   "secret",
+			"environment":     environment,
+		},
+		ErrorOk: expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadConfig(t *testing.T) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "config",
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				return fmt.Errorf("missing response")
+			}
+			if resp.Data["tenant_id"] != testTenantID || resp.Data["environment"] != "TestCloud" {
+				return fmt.Errorf("bad: %#v", resp)
+			}
+			if _, ok := resp.Data["client_secret"]; ok {
+				return fmt.Errorf("client secret returned: %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepRole(t *testing.T, name string, data map[string]interface{}, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/" + name,
+		Data:      data,
+		ErrorOk:   expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadRole(t *testing.T, name, roleID string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "roles/" + name,
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				return fmt.Errorf("missing response")
+			}
+			roles, ok := resp.Data["azure_roles"].([]*azureRole)
+			if !ok || len(roles) != 1 || roles[0].RoleID != roleID {
+				return fmt.Errorf("bad: %#v", resp.Data)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadCreds(t *testing.T, name string, check func(clientID, clientSecret string) error) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + name,
+		Check: func(resp *logical.Response) error {
+			clientID, _ := resp.Data["client_id"].(string)
+			clientSecret, _ := resp.Data["client_secret"].(string)
+			return check(clientID, clientSecret)
+		},
+	}
+}
@@ -0,0 +1,410 @@
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+const (
+	graphAPIVersion         = "1.6"
+	authorizationAPIVersion = "2015-07-01"
+)
+
+// azureEnvironment contains the endpoints of an Azure cloud
+type azureEnvironment struct {
+	LoginEndpoint           string
+	GraphEndpoint           string
+	ResourceManagerEndpoint string
+}
+
+// environments are the Azure clouds the backend can be configured for
+var environments = map[string]azureEnvironment{
+	"AzurePublicCloud": {
+		LoginEndpoint:           "https://login.microsoftonline.com/",
+		GraphEndpoint:           "https://graph.windows.net/",
+		ResourceManagerEndpoint: "https://management.azure.com/",
+	},
+	"AzureUSGovernmentCloud": {
+		LoginEndpoint:           "https://login.microsoftonline.us/",
+		GraphEndpoint:           "https://graph.windows.net/",
+		ResourceManagerEndpoint: "https://management.usgovcloudapi.net/",
+	},
+	"AzureChinaCloud": {
+		LoginEndpoint:           "https://login.chinacloudapi.cn/",
+		GraphEndpoint:           "https://graph.chinacloudapi.cn/",
+		ResourceManagerEndpoint: "https://management.chinacloudapi.cn/",
+	},
+	"AzureGermanCloud": {
+		LoginEndpoint:           "https://login.microsoftonline.de/",
+		GraphEndpoint:           "https://graph.cloudapi.de/",
+		ResourceManagerEndpoint: "https://management.microsoftazure.de/",
+	},
+}
+
+// client talks to the Azure AD Graph API to manage the applications and
+// service principals, and to the Azure Resource Manager API to manage their
+// role assignments
+type client struct {
+	config     *azureConfig
+	env        azureEnvironment
+	httpClient *http.Client
+
+	tokens     map[string]*accessToken
+	tokensLock sync.Mutex
+}
+
+type accessToken struct {
+	value   string
+	expires time.Time
+}
+
+// application is an Azure AD application
+type application struct {
+	ObjectID string `json:"objectId,omitempty"`
+	AppID    string `json:"appId,omitempty"`
+}
+
+// passwordCredential is a client secret of an Azure AD application
+type passwordCredential struct {
+	KeyID     string    `json:"keyId"`
+	Value     string    `json:"value,omitempty"`
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+}
+
+// roleDefinition is an Azure role, such as "Contributor"
+type roleDefinition struct {
+	ID         string `json:"id"`
+	Properties struct {
+		RoleName string `json:"roleName"`
+	} `json:"properties"`
+}
+
+func newClient(config *azureConfig, environments map[string]azureEnvironment) (*client, error) {
+	env, ok := environments[config.Environment]
+	if !ok {
+		return nil, fmt.Errorf("unknown Azure environment %q", config.Environment)
+	}
+
+	return &client{
+		config: config,
+		env:    env,
+		httpClient: &http.Client{
+			Transport: cleanhttp.DefaultPooledTransport(),
+		},
+		tokens: make(map[string]*accessToken),
+	}, nil
+}
+
+// CreateApplication creates an application with the given client secret
+func (c *client) CreateApplication(name string, credential *passwordCredential) (*application, error) {
+	var app application
+	err := c.graph("POST", "/applications", map[string]interface{}{
+		"displayName":             name,
+		"homepage":                "https://" + name,
+		"identifierUris":          []string{"https://" + name},
+		"availableToOtherTenants": false,
+		"passwordCredentials":     []*passwordCredential{credential},
+	}, &app)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app, nil
+}
+
+// ReadApplication returns the application, or nil if it doesn't exist
+func (c *client) ReadApplication(objectID string) (*application, error) {
+	var app application
+	if err := c.graph("GET", "/applications/"+url.PathEscape(objectID), nil, &app); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &app, nil
+}
+
+// DeleteApplication deletes the application along with its service principal.
+// Deleting an application that doesn't exist is not an error.
+func (c *client) DeleteApplication(objectID string) error {
+	err := c.graph("DELETE", "/applications/"+url.PathEscape(objectID), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateServicePrincipal creates the service principal of the application and
+// returns its object ID
+func (c *client) CreateServicePrincipal(appID string) (string, error) {
+	var sp struct {
+		ObjectID string `json:"objectId"`
+	}
+	err := c.graph("POST", "/servicePrincipals", map[string]interface{}{
+		"appId":          appID,
+		"accountEnabled": true,
+	}, &sp)
+	if err != nil {
+		return "", err
+	}
+
+	return sp.ObjectID, nil
+}
+
+// AddPasswordCredential adds a client secret to the application
+func (c *client) AddPasswordCredential(objectID string, credential *passwordCredential) error {
+	credentials, err := c.passwordCredentials(objectID)
+	if err != nil {
+		return err
+	}
+
+	return c.updatePasswordCredentials(objectID, append(credentials, credential))
+}
+
+// RemovePasswordCredential removes a client secret from the application.
+// Removing a client secret that doesn't exist is not an error.
+func (c *client) RemovePasswordCredential(objectID, keyID string) error {
+	credentials, err := c.passwordCredentials(objectID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	kept := make([]*passwordCredential, 0, len(credentials))
+	for _, credential := range credentials {
+		if credential.KeyID != keyID {
+			kept = append(kept, credential)
+		}
+	}
+	if len(kept) == len(credentials) {
+		return nil
+	}
+
+	return c.updatePasswordCredentials(objectID, kept)
+}
+
+func (c *client) passwordCredentials(objectID string) ([]*passwordCredential, error) {
+	var result struct {
+		Value []*passwordCredential `json:"value"`
+	}
+	if err := c.graph("GET", "/applications/"+url.PathEscape(objectID)+"/passwordCredentials", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Value, nil
+}
+
+func (c *client) updatePasswordCredentials(objectID string, credentials []*passwordCredential) error {
+	return c.graph("PATCH", "/applications/"+url.PathEscape(objectID)+"/passwordCredentials", map[string]interface{}{
+		"value": credentials,
+	}, nil)
+}
+
+// LookupRoleDefinition returns the ID of the role definition of the given
+// name, available in the scope of the subscription
+func (c *client) LookupRoleDefinition(roleName string) (string, error) {
+	var result struct {
+		Value []*roleDefinition `json:"value"`
+	}
+	path := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions", url.PathEscape(c.config.SubscriptionID))
+	query := url.Values{
+		"$filter": []string{fmt.Sprintf("roleName eq '%s'", roleName)},
+	}
+	if err := c.resourceManager("GET", path, query, nil, &result); err != nil {
+		return "", err
+	}
+
+	switch len(result.Value) {
+	case 0:
+		return "", fmt.Errorf("no role definition named %q", roleName)
+	case 1:
+		return result.Value[0].ID, nil
+	default:
+		return "", fmt.Errorf("several role definitions named %q", roleName)
+	}
+}
+
+// CreateRoleAssignment assigns the role to the principal in the scope and
+// returns the ID of the role assignment
+func (c *client) CreateRoleAssignment(scope, roleDefinitionID, principalID, assignmentName string) (string, error) {
+	var assignment struct {
+		ID string `json:"id"`
+	}
+	path := strings.TrimSuffix(scope, "/") + "/providers/Microsoft.Authorization/roleAssignments/" + url.PathEscape(assignmentName)
+	err := c.resourceManager("PUT", path, nil, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"roleDefinitionId": roleDefinitionID,
+			"principalId":      principalID,
+		},
+	}, &assignment)
+	if err != nil {
+		return "", err
+	}
+
+	return assignment.ID, nil
+}
+
+// DeleteRoleAssignment deletes the role assignment of the given ID. Deleting
+// a role assignment that doesn't exist is not an error.
+func (c *client) DeleteRoleAssignment(id string) error {
+	err := c.resourceManager("DELETE", id, nil, nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *client) graph(method, path string, body, result interface{}) error {
+	query := url.Values{
+		"api-version": []string{graphAPIVersion},
+	}
+	u := c.env.GraphEndpoint + url.PathEscape(c.config.TenantID) + path + "?" + query.Encode()
+
+	return c.do(method, u, c.env.GraphEndpoint, body, result)
+}
+
+func (c *client) resourceManager(method, path string, query url.Values, body, result interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", authorizationAPIVersion)
+	u := strings.TrimSuffix(c.env.ResourceManagerEndpoint, "/") + path + "?" + query.Encode()
+
+	return c.do(method, u, c.env.ResourceManagerEndpoint, body, result)
+}
+
+func (c *client) do(method, u, resource string, body, result interface{}) error {
+	token, err := c.token(resource)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate to Azure: %s", err)
+	}
+
+	var reqBody []byte
+	if body != nil {
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &apiError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+
+	if result != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, result)
+	}
+	return nil
+}
+
+// token returns an access token for the resource, requested with the client
+// credentials grant and cached until shortly before it expires
+func (c *client) token(resource string) (string, error) {
+	c.tokensLock.Lock()
+	defer c.tokensLock.Unlock()
+
+	if token, ok := c.tokens[resource]; ok && time.Now().Before(token.expires) {
+		return token.value, nil
+	}
+
+	u := c.env.LoginEndpoint + url.PathEscape(c.config.TenantID) + "/oauth2/token"
+	resp, err := c.httpClient.PostForm(u, url.Values{
+		"grant_type":    []string{"client_credentials"},
+		"client_id":     []string{c.config.ClientID},
+		"client_secret": []string{c.config.ClientSecret},
+		"resource":      []string{resource},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &apiError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+
+	// expires_in is a string in the responses of the v1 endpoint
+	var result struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   interface{} `json:"expires_in"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", err
+	}
+	expiresIn, err := strconv.Atoi(fmt.Sprint(result.ExpiresIn))
+	if err != nil {
+		return "", fmt.Errorf("invalid expires_in in token response: %v", result.ExpiresIn)
+	}
+
+	c.tokens[resource] = &accessToken{
+		value:   result.AccessToken,
+		expires: time.Now().Add(time.Duration(expiresIn)*time.Second - time.Minute),
+	}
+
+	return result.AccessToken, nil
+}
+
+// apiError is an error response of an Azure API
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// isPrincipalNotFound returns whether the error is caused by a service
+// principal not yet replicated in Azure AD
+func isPrincipalNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusBadRequest && strings.Contains(apiErr.Body, "PrincipalNotFound")
+}
@@ -0,0 +1,138 @@
+package azure
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config",
+		Fields: map[string]*framework.FieldSchema{
+			"subscription_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "ID of the Azure subscription in which the roles are assigned",
+			},
+			"tenant_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "ID of the Azure AD tenant in which the service principals are created",
+			},
+			"client_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Client ID of the service principal used to manage the service principals",
+			},
+			"client_secret": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Client secret of the service principal used to manage the service principals",
+			},
+			"environment": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "AzurePublicCloud",
+				Description: "Azure cloud: AzurePublicCloud, AzureUSGovernmentCloud, AzureChinaCloud or AzureGermanCloud",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigUpdate,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	// The client secret is not returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"subscription_id": config.SubscriptionID,
+			"tenant_id":       config.TenantID,
+			"client_id":       config.ClientID,
+			"environment":     config.Environment,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config := &azureConfig{
+		SubscriptionID: data.Get("subscription_id").(string),
+		TenantID:       data.Get("tenant_id").(string),
+		ClientID:       data.Get("client_id").(string),
+		ClientSecret:   data.Get("client_secret").(string),
+		Environment:    data.Get("environment").(string),
+	}
+
+	switch {
+	case config.SubscriptionID == "":
+		return logical.ErrorResponse("missing subscription_id"), nil
+	case config.TenantID == "":
+		return logical.ErrorResponse("missing tenant_id"), nil
+	case config.ClientID == "":
+		return logical.ErrorResponse("missing client_id"), nil
+	case config.ClientSecret == "":
+		return logical.ErrorResponse("missing client_secret"), nil
+	}
+	if _, ok := b.environments[config.Environment]; !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unknown environment %q", config.Environment)), nil
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	// Reset the client
+	b.resetClient()
+
+	return nil, nil
+}
+
+// azureConfig contains the credentials used to manage the service principals
+type azureConfig struct {
+	// SubscriptionID is the subscription in which the roles are assigned
+	SubscriptionID string `json:"subscription_id"`
+
+	// TenantID is the Azure AD tenant in which the service principals are
+	// created
+	TenantID string `json:"tenant_id"`
+
+	// ClientID and ClientSecret are the credentials of a service principal
+	// allowed to manage applications and role assignments
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+
+	// Environment is the name of the Azure cloud
+	Environment string `json:"environment"`
+}
+
+const pathConfigHelpSyn = `
+Configure the Azure credentials used to manage the service principals.
+`
+
+const pathConfigHelpDesc = `
+This path configures the Azure credentials used by the backend. The
+"client_id" and "client_secret" parameters are the credentials of a service
+principal of the "tenant_id" Azure AD tenant. It must be allowed to manage
+the applications of the tenant, which requires the "Read and write all
+applications" permission of the Azure Active Directory Graph API, and to
+manage the role assignments of the "subscription_id" subscription, for
+example with the "Owner" role.
+
+The "environment" parameter selects the Azure cloud, and defaults to
+"AzurePublicCloud".
+`
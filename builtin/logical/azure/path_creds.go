@@ -0,0 +1,211 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// Newly created service principals take a while to be replicated in Azure
+// AD, during which they can't be assigned roles
+var (
+	assignmentRetries    = 10
+	assignmentRetryDelay = 3 * time.Second
+)
+
+func pathCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCredsRead,
+		},
+
+		HelpSynopsis:    pathCredsReadHelpSyn,
+		HelpDescription: pathCredsReadHelpDesc,
+	}
+}
+
+// Issues the credential based on the role name
+func (b *backend) pathCredsRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	// Get the role
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	// Get the lease information
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	// The client secret expires at the end of the maximum lease, in case it
+	// isn't revoked
+	maxTTL := b.System().MaxLeaseTTL()
+	if lease.MaxTTL > 0 && lease.MaxTTL < maxTTL {
+		maxTTL = lease.MaxTTL
+	}
+	keyID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	credential := &passwordCredential{
+		KeyID:     keyID,
+		Value:     secret,
+		StartDate: now,
+		EndDate:   now.Add(maxTTL),
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	var appID string
+	var internalData map[string]interface{}
+	if role.ApplicationObjectID != "" {
+		appID, internalData, err = b.addClientSecret(client, role, credential)
+	} else {
+		appID, internalData, err = b.createServicePrincipal(req.Storage, client, name, role, credential)
+	}
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	internalData["role"] = name
+
+	// Return the secret
+	resp := b.Secret(SecretCredsType).Response(map[string]interface{}{
+		"client_id":     appID,
+		"client_secret": secret,
+	}, internalData)
+	resp.Secret.TTL = lease.TTL
+
+	return resp, nil
+}
+
+// addClientSecret adds the client secret to the existing application of the
+// role
+func (b *backend) addClientSecret(client *client, role *roleEntry, credential *passwordCredential) (string, map[string]interface{}, error) {
+	app, err := client.ReadApplication(role.ApplicationObjectID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read application: %s", err)
+	}
+	if app == nil {
+		return "", nil, fmt.Errorf("no application with object ID %q", role.ApplicationObjectID)
+	}
+
+	if err := client.AddPasswordCredential(role.ApplicationObjectID, credential); err != nil {
+		return "", nil, fmt.Errorf("failed to add client secret: %s", err)
+	}
+
+	return app.AppID, map[string]interface{}{
+		"application_object_id": role.ApplicationObjectID,
+		"key_id":                credential.KeyID,
+	}, nil
+}
+
+// createServicePrincipal creates an application with the client secret, and
+// its service principal assigned the Azure roles of the role
+func (b *backend) createServicePrincipal(s logical.Storage, client *client, name string, role *roleEntry, credential *passwordCredential) (string, map[string]interface{}, error) {
+	suffix, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", nil, err
+	}
+	app, err := client.CreateApplication(fmt.Sprintf("vault-%s-%s", name, suffix), credential)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create application: %s", err)
+	}
+
+	// The names of the role assignments are chosen upfront so that they can
+	// be rolled back along with the application
+	assignmentNames := make([]string, len(role.AzureRoles))
+	assignmentIDs := make([]string, len(role.AzureRoles))
+	for i, azureRole := range role.AzureRoles {
+		if assignmentNames[i], err = uuid.GenerateUUID(); err != nil {
+			return "", nil, err
+		}
+		assignmentIDs[i] = roleAssignmentID(azureRole.Scope, assignmentNames[i])
+	}
+
+	// Write to the WAL that this application was created, so that it is
+	// rolled back if the service principal can't be set up
+	walID, err := framework.PutWAL(s, "application", &walApplication{
+		ObjectID:          app.ObjectID,
+		RoleAssignmentIDs: assignmentIDs,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("error writing WAL entry: %s", err)
+	}
+
+	spID, err := client.CreateServicePrincipal(app.AppID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create service principal: %s", err)
+	}
+
+	for i, azureRole := range role.AzureRoles {
+		for retry := 0; ; retry++ {
+			_, err = client.CreateRoleAssignment(azureRole.Scope, azureRole.RoleID, spID, assignmentNames[i])
+			if err == nil || !isPrincipalNotFound(err) || retry >= assignmentRetries {
+				break
+			}
+			time.Sleep(assignmentRetryDelay)
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to assign role %s in scope %s: %s", azureRole.RoleID, azureRole.Scope, err)
+		}
+	}
+
+	// Remove the WAL entry, we succeeded! If we fail, we don't return
+	// the secret because it'll get rolled back anyways, so we have to return
+	// an error here.
+	if err := framework.DeleteWAL(s, walID); err != nil {
+		return "", nil, fmt.Errorf("failed to commit WAL entry: %s", err)
+	}
+
+	return app.AppID, map[string]interface{}{
+		"application_object_id": app.ObjectID,
+		"role_assignment_ids":   assignmentIDs,
+	}, nil
+}
+
+func roleAssignmentID(scope, name string) string {
+	return fmt.Sprintf("%s/providers/Microsoft.Authorization/roleAssignments/%s", strings.TrimSuffix(scope, "/"), name)
+}
+
+const pathCredsReadHelpSyn = `
+Request Azure credentials for a certain role.
+`
+
+const pathCredsReadHelpDesc = `
+This path reads Azure credentials for a certain role. Depending on the role,
+a service principal is created along with a new Azure AD application, or a
+client secret is added to an existing application. The credentials will be
+automatically revoked when the lease is up.
+`
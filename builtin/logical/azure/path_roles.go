@@ -0,0 +1,220 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/?$",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+			"azure_roles": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `JSON list of the Azure roles assigned to the service
+principals, with their scopes.`,
+			},
+			"application_object_id": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Object ID of an existing Azure AD application. If set,
+client secrets of this application are generated instead of service principals.`,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleUpdate,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+// Reads the role configuration from the storage
+func (b *backend) Role(s logical.Storage, n string) (*roleEntry, error) {
+	entry, err := s.Get("role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Deletes an existing role
+func (b *backend) pathRoleDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	return nil, req.Storage.Delete("role/" + name)
+}
+
+// Reads an existing role
+func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"azure_roles":           role.AzureRoles,
+			"application_object_id": role.ApplicationObjectID,
+		},
+	}, nil
+}
+
+// Lists all the roles registered with the backend
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(roles), nil
+}
+
+// Registers a new role with the backend
+func (b *backend) pathRoleUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	role := &roleEntry{
+		ApplicationObjectID: d.Get("application_object_id").(string),
+	}
+	if rawRoles := d.Get("azure_roles").(string); rawRoles != "" {
+		if err := json.Unmarshal([]byte(rawRoles), &role.AzureRoles); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to unmarshal azure_roles: %s", err)), nil
+		}
+	}
+
+	switch {
+	case len(role.AzureRoles) == 0 && role.ApplicationObjectID == "":
+		return logical.ErrorResponse("both azure_roles and application_object_id not specified"), nil
+	case len(role.AzureRoles) > 0 && role.ApplicationObjectID != "":
+		return logical.ErrorResponse("azure_roles cannot be combined with application_object_id"), nil
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Make sure the application exists
+	if role.ApplicationObjectID != "" {
+		app, err := client.ReadApplication(role.ApplicationObjectID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read application: %s", err)
+		}
+		if app == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no application with object ID %q", role.ApplicationObjectID)), nil
+		}
+	}
+
+	// Resolve the role definitions given by name
+	for _, azureRole := range role.AzureRoles {
+		switch {
+		case azureRole.Scope == "":
+			return logical.ErrorResponse("missing scope in azure_roles"), nil
+		case azureRole.RoleID != "":
+		case azureRole.RoleName == "":
+			return logical.ErrorResponse("missing role_name or role_id in azure_roles"), nil
+		default:
+			if azureRole.RoleID, err = client.LookupRoleDefinition(azureRole.RoleName); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("failed to look up role %q: %s", azureRole.RoleName, err)), nil
+			}
+		}
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("role/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Role that defines the capabilities of the credentials issued against it
+type roleEntry struct {
+	AzureRoles          []*azureRole `json:"azure_roles"`
+	ApplicationObjectID string       `json:"application_object_id"`
+}
+
+// azureRole is an Azure role assigned to the service principals in a scope
+type azureRole struct {
+	RoleName string `json:"role_name,omitempty"`
+	RoleID   string `json:"role_id"`
+	Scope    string `json:"scope"`
+}
+
+const pathRoleHelpSyn = `
+Manage the roles that can be created with this backend.
+`
+
+const pathRoleHelpDesc = `
+This path lets you manage the roles that can be created with this backend.
+
+The "azure_roles" parameter is the list of Azure roles assigned to the
+service principals created for the role, each in a scope. The roles are given
+by name or by role definition ID, and passed as a string in the form:
+
+[
+	{
+		"role_name": "Contributor",
+		"scope": "/subscriptions/<uuid>/resourceGroups/my-group"
+	},
+	{
+		"role_id": "/subscriptions/<uuid>/providers/Microsoft.Authorization/roleDefinitions/<uuid>",
+		"scope": "/subscriptions/<uuid>"
+	}
+]
+
+Alternatively, the "application_object_id" parameter is the object ID of an
+existing Azure AD application. Instead of creating service principals, the
+backend then adds client secrets to this application, and removes them when
+they are revoked.
+
+Exactly one of the two parameters must be set.
+`
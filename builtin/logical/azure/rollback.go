@@ -0,0 +1,34 @@
+package azure
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+// walApplication is the WAL entry of an application created for a service
+// principal, along with the role assignments of the service principal
+type walApplication struct {
+	ObjectID          string
+	RoleAssignmentIDs []string
+}
+
+func (b *backend) walRollback(req *logical.Request, kind string, data interface{}) error {
+	switch kind {
+	case "application":
+		var entry walApplication
+		if err := mapstructure.Decode(data, &entry); err != nil {
+			return err
+		}
+
+		client, err := b.Client(req.Storage)
+		if err != nil {
+			return err
+		}
+
+		return deleteServicePrincipal(client, entry.ObjectID, entry.RoleAssignmentIDs)
+	default:
+		return fmt.Errorf("unknown type to rollback")
+	}
+}
@@ -0,0 +1,107 @@
+package azure
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// SecretCredsType is the key for this backend's secrets.
+const SecretCredsType = "creds"
+
+func secretCreds(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretCredsType,
+		Fields: map[string]*framework.FieldSchema{
+			"client_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Client ID of the Azure AD application",
+			},
+			"client_secret": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Client secret of the Azure AD application",
+			},
+		},
+		Renew:  b.secretCredsRenew,
+		Revoke: b.secretCredsRevoke,
+	}
+}
+
+// Renew the previously issued secret
+func (b *backend) secretCredsRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the lease information
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	return framework.LeaseExtend(lease.TTL, lease.MaxTTL, b.System())(req, d)
+}
+
+// Revoke the previously issued secret
+func (b *backend) secretCredsRevoke(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the application from the internal data
+	objectIDRaw, ok := req.Secret.InternalData["application_object_id"]
+	if !ok {
+		return nil, fmt.Errorf("secret is missing application_object_id internal data")
+	}
+	objectID, ok := objectIDRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("secret is missing application_object_id internal data")
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the client secret from an existing application
+	if keyIDRaw, ok := req.Secret.InternalData["key_id"]; ok {
+		keyID, ok := keyIDRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("secret has key_id but value could not be understood")
+		}
+		if err := client.RemovePasswordCredential(objectID, keyID); err != nil {
+			return nil, fmt.Errorf("could not remove client secret: %s", err)
+		}
+		return nil, nil
+	}
+
+	// Delete the service principal
+	var assignmentIDs []string
+	switch ids := req.Secret.InternalData["role_assignment_ids"].(type) {
+	case []string:
+		assignmentIDs = ids
+	case []interface{}:
+		for _, id := range ids {
+			assignmentIDs = append(assignmentIDs, fmt.Sprint(id))
+		}
+	}
+	if err := deleteServicePrincipal(client, objectID, assignmentIDs); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// deleteServicePrincipal deletes the role assignments, then the application
+// along with its service principal
+func deleteServicePrincipal(client *client, objectID string, assignmentIDs []string) error {
+	for _, id := range assignmentIDs {
+		if err := client.DeleteRoleAssignment(id); err != nil {
+			return fmt.Errorf("could not delete role assignment %s: %s", id, err)
+		}
+	}
+
+	if err := client.DeleteApplication(objectID); err != nil {
+		return fmt.Errorf("could not delete application: %s", err)
+	}
+
+	return nil
+}
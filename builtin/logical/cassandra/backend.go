@@ -63,6 +63,7 @@ type sessionConfig struct {
 	ProtocolVersion int    `json:"protocol_version" structs:"protocol_version" mapstructure:"protocol_version"`
 	ConnectTimeout  int    `json:"connect_timeout" structs:"connect_timeout" mapstructure:"connect_timeout"`
 	TLSMinVersion   string `json:"tls_min_version" structs:"tls_min_version" mapstructure:"tls_min_version"`
+	PasswordPolicy  string `json:"password_policy" structs:"password_policy" mapstructure:"password_policy"`
 }
 
 // DB returns the database connection.
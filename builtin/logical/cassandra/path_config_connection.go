@@ -73,6 +73,11 @@ take precedence.`,
 				Default:     5,
 				Description: `The connection timeout to use. Defaults to 5.`,
 			},
+
+			"password_policy": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate the passwords of the users. Defaults to random UUIDs.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -133,6 +138,7 @@ func (b *backend) pathConnectionWrite(
 		InsecureTLS:     data.Get("insecure_tls").(bool),
 		ProtocolVersion: data.Get("protocol_version").(int),
 		ConnectTimeout:  data.Get("connect_timeout").(int),
+		PasswordPolicy:  data.Get("password_policy").(string),
 	}
 
 	config.TLSMinVersion = data.Get("tls_min_version").(string)
@@ -150,6 +156,13 @@ func (b *backend) pathConnectionWrite(
 		config.TLS = true
 	}
 
+	// Make sure the password policy exists and can generate passwords
+	if config.PasswordPolicy != "" {
+		if _, err := b.System().GeneratePasswordFromPolicy(config.PasswordPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("Error validating password policy: %s", err)), nil
+		}
+	}
+
 	pemBundle := data.Get("pem_bundle").(string)
 	pemJSON := data.Get("pem_json").(string)
 
@@ -7,7 +7,9 @@ import (
 
 	"github.com/gocql/gocql"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/dbutil"
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -44,14 +46,25 @@ func (b *backend) pathCredsCreateRead(
 		return logical.ErrorResponse(fmt.Sprintf("Unknown role: %s", name)), nil
 	}
 
-	displayName := req.DisplayName
-	userUUID, err := uuid.GenerateUUID()
-	if err != nil {
-		return nil, err
+	var username string
+	if role.UsernameTemplate != "" {
+		username, err = templateutil.RenderUsername(role.UsernameTemplate, templateutil.UsernameData{
+			DisplayName: req.DisplayName,
+			RoleName:    name,
+		}, 0)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		displayName := req.DisplayName
+		userUUID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		username = fmt.Sprintf("vault_%s_%s_%s_%d", name, displayName, userUUID, time.Now().Unix())
+		username = strings.Replace(username, "-", "_", -1)
 	}
-	username := fmt.Sprintf("vault_%s_%s_%s_%d", name, displayName, userUUID, time.Now().Unix())
-	username = strings.Replace(username, "-", "_", -1)
-	password, err := uuid.GenerateUUID()
+	password, err := dbutil.GeneratePassword(req.Storage, b.System())
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +94,7 @@ func (b *backend) pathCredsCreateRead(
 
 		err = session.Query(substQuery(query, map[string]string{
 			"username": username,
-			"password": password,
+			"password": dbutil.EscapeLiteral(password),
 		})).Exec()
 		if err != nil {
 			for _, query := range strutil.ParseArbitraryStringSlice(role.RollbackCQL, ";") {
@@ -92,7 +105,7 @@ func (b *backend) pathCredsCreateRead(
 
 				session.Query(substQuery(query, map[string]string{
 					"username": username,
-					"password": password,
+					"password": dbutil.EscapeLiteral(password),
 				})).Exec()
 			}
 			return nil, err
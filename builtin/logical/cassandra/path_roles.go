@@ -6,6 +6,7 @@ import (
 
 	"github.com/fatih/structs"
 	"github.com/gocql/gocql"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -61,6 +62,12 @@ template values are '{{username}}' and
 				Default:     "Quorum",
 				Description: "The consistency level for the operations; defaults to Quorum.",
 			},
+
+			"username_template": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Template of the usernames of the generated users, in Go
+template syntax. See help for more info.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -138,11 +145,19 @@ func (b *backend) pathRoleCreate(
 			"Error parsing consistency value of %q: %v", consistencyStr, err)), nil
 	}
 
+	usernameTemplate := data.Get("username_template").(string)
+	if usernameTemplate != "" {
+		if err := templateutil.ValidateUsernameTemplate(usernameTemplate); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	entry := &roleEntry{
-		Lease:       lease,
-		CreationCQL: creationCQL,
-		RollbackCQL: rollbackCQL,
-		Consistency: consistencyStr,
+		Lease:            lease,
+		CreationCQL:      creationCQL,
+		RollbackCQL:      rollbackCQL,
+		Consistency:      consistencyStr,
+		UsernameTemplate: usernameTemplate,
 	}
 
 	// Store it
@@ -158,10 +173,11 @@ func (b *backend) pathRoleCreate(
 }
 
 type roleEntry struct {
-	CreationCQL string        `json:"creation_cql" structs:"creation_cql"`
-	Lease       time.Duration `json:"lease" structs:"lease"`
-	RollbackCQL string        `json:"rollback_cql" structs:"rollback_cql"`
-	Consistency string        `json:"consistency" structs:"consistency"`
+	CreationCQL      string        `json:"creation_cql" structs:"creation_cql"`
+	Lease            time.Duration `json:"lease" structs:"lease"`
+	RollbackCQL      string        `json:"rollback_cql" structs:"rollback_cql"`
+	Consistency      string        `json:"consistency" structs:"consistency"`
+	UsernameTemplate string        `json:"username_template" structs:"username_template"`
 }
 
 const pathRoleHelpSyn = `
@@ -195,4 +211,18 @@ instance of Cassandra:
 ` + defaultRollbackCQL + `
 
 "lease" the lease time; if not set the mount/system defaults are used.
+
+The "username_template" parameter customizes the usernames of the generated
+users. It uses the Go template syntax, with the {{.DisplayName}} and
+{{.RoleName}} values, and the following functions:
+
+  random N        N random alphanumeric characters
+  uuid            a random UUID
+  unix_time       the current unix time, in seconds
+  truncate N s    the first N characters of s
+  lowercase s     s in lower case
+  uppercase s     s in upper case
+  replace a b s   s with all the occurrences of a replaced with b
+
+For example: "v_{{.RoleName}}_{{lowercase .DisplayName}}_{{random 8}}".
 `
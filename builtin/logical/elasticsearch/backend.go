@@ -0,0 +1,144 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// Factory creates and configures the backend
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend().Setup(conf)
+}
+
+// Backend creates a new backend with all the paths and secrets belonging to it
+func Backend() *backend {
+	var b backend
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		Paths: []*framework.Path{
+			pathConfigConnection(&b),
+			pathConfigLease(&b),
+			pathListRoles(&b),
+			pathRoles(&b),
+			pathCreds(&b),
+		},
+
+		Secrets: []*framework.Secret{
+			secretCreds(&b),
+		},
+
+		Clean: b.resetClient,
+
+		Invalidate: b.invalidate,
+	}
+
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	client *client
+	lock   sync.RWMutex
+}
+
+// Client returns the client of the Elasticsearch security API
+func (b *backend) Client(s logical.Storage) (*client, error) {
+	b.lock.RLock()
+
+	// If we already have a client, return it
+	if b.client != nil {
+		b.lock.RUnlock()
+		return b.client, nil
+	}
+
+	b.lock.RUnlock()
+
+	connConfig, err := b.connectionConfig(s)
+	if err != nil {
+		return nil, err
+	}
+	if connConfig == nil {
+		return nil, fmt.Errorf("configure the client connection with config/connection first")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// If the client was created during the lock switch, return it
+	if b.client != nil {
+		return b.client, nil
+	}
+
+	b.client, err = newClient(connConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.client, nil
+}
+
+// resetClient forces a connection next time Client() is called.
+func (b *backend) resetClient() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.client = nil
+}
+
+func (b *backend) invalidate(key string) {
+	switch key {
+	case "config/connection":
+		b.resetClient()
+	}
+}
+
+// connectionConfig returns the connection configuration, or nil if the
+// backend is not configured yet
+func (b *backend) connectionConfig(s logical.Storage) (*connectionConfig, error) {
+	entry, err := s.Get("config/connection")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var connConfig connectionConfig
+	if err := entry.DecodeJSON(&connConfig); err != nil {
+		return nil, err
+	}
+
+	return &connConfig, nil
+}
+
+// Lease returns the lease information
+func (b *backend) Lease(s logical.Storage) (*configLease, error) {
+	entry, err := s.Get("config/lease")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result configLease
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+const backendHelp = `
+The Elasticsearch backend dynamically generates users of the native realm of
+Elasticsearch.
+
+After mounting this backend, configure it using the endpoints within
+the "config/" path.
+`
@@ -0,0 +1,279 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+	"github.com/mitchellh/mapstructure"
+)
+
+// testServer is a fake of the security API of Elasticsearch
+type testServer struct {
+	sync.Mutex
+
+	users map[string]*esUser
+	roles map[string]json.RawMessage
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if username, password, ok := r.BasicAuth(); !ok || username != "elastic" || password != "changeme" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var kind, name string
+	switch {
+	case r.URL.Path == "/_xpack/security/_authenticate":
+		w.Write([]byte(`{"username": "elastic"}`))
+		return
+	case strings.HasPrefix(r.URL.Path, "/_xpack/security/user/"):
+		kind, name = "user", strings.TrimPrefix(r.URL.Path, "/_xpack/security/user/")
+	case strings.HasPrefix(r.URL.Path, "/_xpack/security/role/"):
+		kind, name = "role", strings.TrimPrefix(r.URL.Path, "/_xpack/security/role/")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.Method == "PUT" && kind == "user":
+		var user esUser
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.users[name] = &user
+	case r.Method == "PUT" && kind == "role":
+		var definition json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.roles[name] = definition
+	case r.Method == "DELETE" && kind == "user":
+		if _, ok := s.users[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.users, name)
+	case r.Method == "DELETE" && kind == "role":
+		if _, ok := s.roles[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.roles, name)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Write([]byte(`{}`))
+}
+
+func newTestServer() *testServer {
+	return &testServer{
+		users: make(map[string]*esUser),
+		roles: make(map[string]json.RawMessage),
+	}
+}
+
+func TestBackend_config_connection(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	ts := httptest.NewServer(newTestServer())
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfigPassword(t, ts.URL, "wrong", true),
+			testAccStepConfig(t, ts.URL),
+			testAccStepReadConfig(t),
+		},
+	})
+}
+
+func TestBackend_roleCrud(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	ts := httptest.NewServer(newTestServer())
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, ts.URL),
+			testAccStepRole(t, "web", map[string]interface{}{
+				"elasticsearch_role_definition": "not json",
+			}, true),
+			testAccStepRole(t, "web", map[string]interface{}{
+				"elasticsearch_roles": "Monitoring_User, kibana_user",
+			}, false),
+			testAccStepReadRole(t, "web", map[string]interface{}{
+				"elasticsearch_roles":           []string{"Monitoring_User", "kibana_user"},
+				"elasticsearch_role_definition": "",
+			}),
+			testAccStepDeleteRole(t, "web"),
+			testAccStepReadRole(t, "web", nil),
+		},
+	})
+}
+
+func TestBackend_creds(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	server := newTestServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	definition := `{"indices": [{"names": ["logs-*"], "privileges": ["read"]}]}`
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, ts.URL),
+			testAccStepRole(t, "web", map[string]interface{}{
+				"elasticsearch_roles":           "kibana_user",
+				"elasticsearch_role_definition": definition,
+			}, false),
+			testAccStepReadCreds(t, server, "web", definition),
+		},
+	})
+
+	// Revoking the secret deletes the user and its dedicated role
+	if len(server.users) != 0 || len(server.roles) != 0 {
+		t.Fatalf("bad: users: %#v roles: %#v", server.users, server.roles)
+	}
+}
+
+func testAccStepConfig(t *testing.T, url string) logicaltest.TestStep {
+	return testAccStepConfigPassword(t, url, "changeme", false)
+}
+
+func testAccStepConfigPassword(t *testing.T, url, password string, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config/connection",
+		Data: map[string]interface{}{
+			"url":      url,
+			"username": "elastic",
+			"password": password,
+		},
+		ErrorOk: expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadConfig(t *testing.T) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "config/connection",
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				return fmt.Errorf("missing response")
+			}
+			if _, ok := resp.Data["password"]; ok {
+				return fmt.Errorf("password should not be returned: %#v", resp.Data)
+			}
+			if resp.Data["username"] != "elastic" {
+				return fmt.Errorf("bad: %#v", resp.Data)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepRole(t *testing.T, name string, data map[string]interface{}, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/" + name,
+		Data:      data,
+		ErrorOk:   expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepDeleteRole(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.DeleteOperation,
+		Path:      "roles/" + name,
+	}
+}
+
+func testAccStepReadRole(t *testing.T, name string, expected map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "roles/" + name,
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				if expected == nil {
+					return nil
+				}
+				return fmt.Errorf("bad: %#v", resp)
+			}
+			if !reflect.DeepEqual(resp.Data, expected) {
+				return fmt.Errorf("bad: expected: %#v got: %#v", expected, resp.Data)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadCreds(t *testing.T, server *testServer, name, definition string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + name,
+		Check: func(resp *logical.Response) error {
+			var d struct {
+				Username string `mapstructure:"username"`
+				Password string `mapstructure:"password"`
+			}
+			if err := mapstructure.Decode(resp.Data, &d); err != nil {
+				return err
+			}
+			if !strings.HasPrefix(d.Username, "root-") || d.Password == "" {
+				return fmt.Errorf("bad: %#v", resp.Data)
+			}
+
+			server.Lock()
+			defer server.Unlock()
+
+			// The user and its dedicated role are created
+			user, ok := server.users[d.Username]
+			if !ok {
+				return fmt.Errorf("user %q was not created", d.Username)
+			}
+			if user.Password != d.Password || !reflect.DeepEqual(user.Roles, []string{"kibana_user", d.Username}) {
+				return fmt.Errorf("bad: %#v", user)
+			}
+			var expected, actual interface{}
+			if err := json.Unmarshal([]byte(definition), &expected); err != nil {
+				return err
+			}
+			if err := json.Unmarshal(server.roles[d.Username], &actual); err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				return fmt.Errorf("bad: expected: %#v got: %#v", expected, actual)
+			}
+			return nil
+		},
+	}
+}
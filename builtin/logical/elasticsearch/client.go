@@ -0,0 +1,125 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// client talks to the security API of Elasticsearch to manage the users and
+// the roles of the native realm
+type client struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// esUser is the definition of a native realm user
+type esUser struct {
+	Password string                 `json:"password"`
+	Roles    []string               `json:"roles"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+func newClient(config *connectionConfig) (*client, error) {
+	transport := cleanhttp.DefaultPooledTransport()
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureTLS,
+	}
+	if config.CACert != "" {
+		caPool := x509.NewCertPool()
+		if ok := caPool.AppendCertsFromPEM([]byte(config.CACert)); !ok {
+			return nil, fmt.Errorf("could not parse the CA certificate")
+		}
+		tlsConfig.RootCAs = caPool
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &client{
+		baseURL:  strings.TrimSuffix(config.URL, "/"),
+		username: config.Username,
+		password: config.Password,
+		httpClient: &http.Client{
+			Transport: transport,
+		},
+	}, nil
+}
+
+// Authenticate checks that the configured credentials are valid
+func (c *client) Authenticate() error {
+	return c.do("GET", "/_xpack/security/_authenticate", nil)
+}
+
+// CreateRole creates or updates the role with the given definition
+func (c *client) CreateRole(name string, definition json.RawMessage) error {
+	return c.do("PUT", "/_xpack/security/role/"+url.PathEscape(name), definition)
+}
+
+// DeleteRole deletes the role. Deleting a role that doesn't exist is not an
+// error.
+func (c *client) DeleteRole(name string) error {
+	return c.do("DELETE", "/_xpack/security/role/"+url.PathEscape(name), nil)
+}
+
+// CreateUser creates or updates the user
+func (c *client) CreateUser(name string, user *esUser) error {
+	return c.do("PUT", "/_xpack/security/user/"+url.PathEscape(name), user)
+}
+
+// DeleteUser deletes the user. Deleting a user that doesn't exist is not an
+// error.
+func (c *client) DeleteUser(name string) error {
+	return c.do("DELETE", "/_xpack/security/user/"+url.PathEscape(name), nil)
+}
+
+func (c *client) do(method, path string, body interface{}) error {
+	var reqBody []byte
+	switch b := body.(type) {
+	case nil:
+	case json.RawMessage:
+		reqBody = b
+	default:
+		var err error
+		if reqBody, err = json.Marshal(b); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound && method == "DELETE":
+		return nil
+	default:
+		return fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, respBody)
+	}
+}
@@ -0,0 +1,168 @@
+package elasticsearch
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigConnection(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/connection",
+		Fields: map[string]*framework.FieldSchema{
+			"url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "URL of the Elasticsearch HTTP API",
+			},
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of an Elasticsearch user allowed to manage users and roles",
+			},
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password of the provided Elasticsearch user",
+			},
+			"ca_cert": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM-encoded CA certificate used to verify the certificate of Elasticsearch",
+			},
+			"insecure_tls": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, the certificate of Elasticsearch is not verified",
+			},
+			"verify_connection": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Default:     true,
+				Description: `If set, the connection is verified by actually authenticating to Elasticsearch`,
+			},
+			"password_policy": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate the passwords of the users. Defaults to random UUIDs.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConnectionRead,
+			logical.UpdateOperation: b.pathConnectionUpdate,
+		},
+
+		HelpSynopsis:    pathConfigConnectionHelpSyn,
+		HelpDescription: pathConfigConnectionHelpDesc,
+	}
+}
+
+func (b *backend) pathConnectionRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connConfig, err := b.connectionConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if connConfig == nil {
+		return nil, nil
+	}
+
+	// The password is not returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"url":             connConfig.URL,
+			"username":        connConfig.Username,
+			"ca_cert":         connConfig.CACert,
+			"insecure_tls":    connConfig.InsecureTLS,
+			"password_policy": connConfig.PasswordPolicy,
+		},
+	}, nil
+}
+
+func (b *backend) pathConnectionUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connConfig := &connectionConfig{
+		URL:            data.Get("url").(string),
+		Username:       data.Get("username").(string),
+		Password:       data.Get("password").(string),
+		CACert:         data.Get("ca_cert").(string),
+		InsecureTLS:    data.Get("insecure_tls").(bool),
+		PasswordPolicy: data.Get("password_policy").(string),
+	}
+
+	switch {
+	case connConfig.URL == "":
+		return logical.ErrorResponse("missing url"), nil
+	case connConfig.Username == "":
+		return logical.ErrorResponse("missing username"), nil
+	case connConfig.Password == "":
+		return logical.ErrorResponse("missing password"), nil
+	}
+
+	// Make sure the password policy exists and can generate passwords
+	if connConfig.PasswordPolicy != "" {
+		if _, err := b.System().GeneratePasswordFromPolicy(connConfig.PasswordPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to validate the password policy: %s", err)), nil
+		}
+	}
+
+	client, err := newClient(connConfig)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to create client: %s", err)), nil
+	}
+
+	// Don't check the connection if verification is disabled
+	verifyConnection := data.Get("verify_connection").(bool)
+	if verifyConnection {
+		if err := client.Authenticate(); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to validate the connection: %s", err)), nil
+		}
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config/connection", connConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	// Reset the client connection
+	b.resetClient()
+
+	return nil, nil
+}
+
+// connectionConfig contains the information required to make a connection to
+// the Elasticsearch HTTP API
+type connectionConfig struct {
+	// URL of the Elasticsearch HTTP API
+	URL string `json:"url"`
+
+	// Username of a user allowed to manage users and roles
+	Username string `json:"username"`
+
+	// Password for the Username
+	Password string `json:"password"`
+
+	// CACert is the PEM-encoded CA certificate used to verify Elasticsearch
+	CACert string `json:"ca_cert"`
+
+	// InsecureTLS disables the verification of the certificate
+	InsecureTLS bool `json:"insecure_tls"`
+
+	// PasswordPolicy is the name of the password policy used to generate the
+	// passwords of the users
+	PasswordPolicy string `json:"password_policy"`
+}
+
+const pathConfigConnectionHelpSyn = `
+Configure the URL, username, and password to talk to the Elasticsearch HTTP API.
+`
+
+const pathConfigConnectionHelpDesc = `
+This path configures the connection properties used to connect to the
+Elasticsearch HTTP API. The "url" parameter is the address of the API, for
+example "https://localhost:9200". The "username" and "password" parameters are
+the credentials of a user allowed to manage the users and the roles of the
+native realm, such as a user with the "superuser" role.
+
+The "ca_cert" parameter is the PEM-encoded CA certificate used to verify the
+certificate of Elasticsearch, and "insecure_tls" disables this verification.
+The "verify_connection" parameter is a boolean that is used to verify whether
+the provided credentials are valid.
+`
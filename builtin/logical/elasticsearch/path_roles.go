@@ -0,0 +1,186 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/?$",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+			"elasticsearch_roles": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of existing Elasticsearch roles granted to the users.",
+			},
+			"elasticsearch_role_definition": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `JSON definition of an Elasticsearch role created
+along with each user and granted to it.`,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleUpdate,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+// Reads the role configuration from the storage
+func (b *backend) Role(s logical.Storage, n string) (*roleEntry, error) {
+	entry, err := s.Get("role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Deletes an existing role
+func (b *backend) pathRoleDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	return nil, req.Storage.Delete("role/" + name)
+}
+
+// Reads an existing role
+func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"elasticsearch_roles":           role.ElasticsearchRoles,
+			"elasticsearch_role_definition": role.ElasticsearchRoleDefinition,
+		},
+	}, nil
+}
+
+// Lists all the roles registered with the backend
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(roles), nil
+}
+
+// Registers a new role with the backend
+func (b *backend) pathRoleUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	// Elasticsearch role names are case sensitive, so they are kept as is
+	var esRoles []string
+	for _, esRole := range strutil.ParseStringSlice(d.Get("elasticsearch_roles").(string), ",") {
+		if esRole = strings.TrimSpace(esRole); esRole != "" {
+			esRoles = append(esRoles, esRole)
+		}
+	}
+	rawDefinition := d.Get("elasticsearch_role_definition").(string)
+
+	if len(esRoles) == 0 && rawDefinition == "" {
+		return logical.ErrorResponse("both elasticsearch_roles and elasticsearch_role_definition not specified"), nil
+	}
+
+	if rawDefinition != "" {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(rawDefinition), &parsed); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to unmarshal elasticsearch_role_definition: %s", err)), nil
+		}
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("role/"+name, &roleEntry{
+		ElasticsearchRoles:          esRoles,
+		ElasticsearchRoleDefinition: rawDefinition,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Role that defines the capabilities of the credentials issued against it
+type roleEntry struct {
+	ElasticsearchRoles          []string `json:"elasticsearch_roles"`
+	ElasticsearchRoleDefinition string   `json:"elasticsearch_role_definition"`
+}
+
+const pathRoleHelpSyn = `
+Manage the roles that can be created with this backend.
+`
+
+const pathRoleHelpDesc = `
+This path lets you manage the roles that can be created with this backend.
+
+The "elasticsearch_roles" parameter is a comma-separated list of existing
+Elasticsearch roles granted to the users. The "elasticsearch_role_definition"
+parameter is the JSON definition of an Elasticsearch role created along with
+each user, granted to it, and deleted when the user is revoked. It is passed
+as a string in the form:
+
+{
+	"cluster": ["monitor"],
+	"indices": [
+		{
+			"names": ["logs-*"],
+			"privileges": ["read"]
+		}
+	]
+}
+
+At least one of the two parameters must be set.
+`
@@ -0,0 +1,74 @@
+package elasticsearch
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// SecretCredsType is the key for this backend's secrets.
+const SecretCredsType = "creds"
+
+func secretCreds(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretCredsType,
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Elasticsearch username",
+			},
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password for the Elasticsearch username",
+			},
+		},
+		Renew:  b.secretCredsRenew,
+		Revoke: b.secretCredsRevoke,
+	}
+}
+
+// Renew the previously issued secret
+func (b *backend) secretCredsRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the lease information
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	return framework.LeaseExtend(lease.TTL, lease.MaxTTL, b.System())(req, d)
+}
+
+// Revoke the previously issued secret
+func (b *backend) secretCredsRevoke(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the username from the internal data
+	usernameRaw, ok := req.Secret.InternalData["username"]
+	if !ok {
+		return nil, fmt.Errorf("secret is missing username internal data")
+	}
+	username := usernameRaw.(string)
+
+	// Get our connection
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.DeleteUser(username); err != nil {
+		return nil, fmt.Errorf("could not delete user: %s", err)
+	}
+
+	// Delete the role dedicated to the user, if any
+	if esRoleRaw, ok := req.Secret.InternalData["elasticsearch_role"]; ok {
+		if err := client.DeleteRole(esRoleRaw.(string)); err != nil {
+			return nil, fmt.Errorf("could not delete role: %s", err)
+		}
+	}
+
+	return nil, nil
+}
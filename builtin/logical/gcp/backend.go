@@ -0,0 +1,156 @@
+package gcp
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// Factory creates and configures the backend
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend().Setup(conf)
+}
+
+// Backend creates a new backend with all the paths and secrets belonging to it
+func Backend() *backend {
+	var b backend
+	b.iamEndpoint = defaultIAMEndpoint
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		Paths: []*framework.Path{
+			pathConfig(&b),
+			pathConfigLease(&b),
+			pathListRolesets(&b),
+			pathRolesets(&b),
+			pathRolesetRotateKey(&b),
+			pathToken(&b),
+			pathKey(&b),
+		},
+
+		Secrets: []*framework.Secret{
+			secretServiceAccountKey(&b),
+		},
+
+		Clean: b.resetClient,
+
+		Invalidate: b.invalidate,
+	}
+
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	client *client
+	lock   sync.RWMutex
+
+	// rolesetLock serializes the changes of the keys of the rolesets
+	rolesetLock sync.Mutex
+
+	// iamEndpoint is the base URL of the IAM API, replaced by the tests to
+	// point at a fake of the API
+	iamEndpoint string
+}
+
+// Client returns the client of the IAM API
+func (b *backend) Client(s logical.Storage) (*client, error) {
+	b.lock.RLock()
+
+	// If we already have a client, return it
+	if b.client != nil {
+		b.lock.RUnlock()
+		return b.client, nil
+	}
+
+	b.lock.RUnlock()
+
+	config, err := b.config(s)
+	if err != nil {
+		return nil, err
+	}
+	// Without configuration, the application default credentials are used
+	if config == nil {
+		config = &gcpConfig{}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// If the client was created during the lock switch, return it
+	if b.client != nil {
+		return b.client, nil
+	}
+
+	b.client, err = newClient(config.Credentials, b.iamEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.client, nil
+}
+
+// resetClient forces a new client next time Client() is called.
+func (b *backend) resetClient() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.client = nil
+}
+
+func (b *backend) invalidate(key string) {
+	switch key {
+	case "config":
+		b.resetClient()
+	}
+}
+
+// config returns the configuration, or nil if the backend is not configured
+// yet
+func (b *backend) config(s logical.Storage) (*gcpConfig, error) {
+	entry, err := s.Get("config")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config gcpConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Lease returns the lease information
+func (b *backend) Lease(s logical.Storage) (*configLease, error) {
+	entry, err := s.Get("config/lease")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result configLease
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+const backendHelp = `
+The GCP backend generates OAuth2 access tokens and keys of Google Cloud
+service accounts. The service account keys have a configurable lease set and
+are automatically deleted at the end of the lease.
+
+After mounting this backend, the credentials used to manage the keys can be
+configured with the "config" path, and rolesets must be written using the
+"rolesets/" endpoints before any credentials can be generated.
+`
@@ -0,0 +1,322 @@
+package gcp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+)
+
+const (
+	testAdminEmail = "vault@project.iam.gserviceaccount.com"
+	testEmail      = "app@project.iam.gserviceaccount.com"
+)
+
+// testServer is a fake of the Google OAuth2 token endpoint and of the IAM API
+type testServer struct {
+	sync.Mutex
+
+	url        string
+	privateKey string
+	keys       map[string]string
+	lastKey    int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.URL.Path == "/token" {
+		// Issue a token for the service account of the JWT, without
+		// verifying its signature
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if len(parts) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var claims struct {
+			Iss   string `json:"iss"`
+			Scope string `json:"scope"`
+		}
+		if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": %q, "token_type": "Bearer", "expires_in": 3600}`, claims.Iss+" "+claims.Scope)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+testAdminEmail+" "+cloudPlatformScope {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	accountPath := "/v1/projects/-/serviceAccounts/" + testEmail
+	switch {
+	case r.Method == "GET" && r.URL.Path == accountPath:
+		fmt.Fprintf(w, `{"email": %q}`, testEmail)
+	case r.Method == "POST" && r.URL.Path == accountPath+"/keys":
+		s.lastKey++
+		name := fmt.Sprintf("projects/-/serviceAccounts/%s/keys/%d", testEmail, s.lastKey)
+		s.keys[name] = testEmail
+		credentials := s.credentials(testEmail)
+		json.NewEncoder(w).Encode(&serviceAccountKey{
+			Name:           name,
+			PrivateKeyData: base64.StdEncoding.EncodeToString([]byte(credentials)),
+			PrivateKeyType: "TYPE_GOOGLE_CREDENTIALS_FILE",
+			KeyAlgorithm:   "KEY_ALG_RSA_2048",
+		})
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, accountPath+"/keys/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/")
+		if _, ok := s.keys[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.keys, name)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// credentials returns a JSON credentials file of the service account
+func (s *testServer) credentials(email string) string {
+	credentials, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   email,
+		"private_key_id": "key",
+		"private_key":    s.privateKey,
+		"token_uri":      s.url + "/token",
+	})
+	return string(credentials)
+}
+
+func newTestServer(t *testing.T) (*testServer, *httptest.Server) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testServer{
+		privateKey: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		})),
+		keys: make(map[string]string),
+	}
+	ts := httptest.NewServer(server)
+	server.url = ts.URL
+	return server, ts
+}
+
+func testBackend(t *testing.T, url string) *backend {
+	b := Backend()
+	b.iamEndpoint = url + "/"
+	if _, err := b.Setup(logical.TestBackendConfig()); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBackend_accessToken(t *testing.T) {
+	server, ts := newTestServer(t)
+	defer ts.Close()
+
+	scope := "https://www.googleapis.com/auth/devstorage.read_only"
+	var keyName string
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: testBackend(t, ts.URL),
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, server),
+			testAccStepRoleset(t, "reader", map[string]interface{}{
+				"service_account_email": testEmail,
+			}, true),
+			testAccStepRoleset(t, "reader", map[string]interface{}{
+				"service_account_email": testEmail,
+				"token_scopes":          scope,
+			}, false),
+			testAccStepCheckKeys(t, server, 1),
+			testAccStepReadRoleset(t, server, "reader", &keyName, false),
+			// Rewriting the roleset keeps its key
+			testAccStepRoleset(t, "reader", map[string]interface{}{
+				"service_account_email": testEmail,
+				"token_scopes":          scope,
+			}, false),
+			testAccStepReadRoleset(t, server, "reader", &keyName, false),
+			testAccStepReadToken(t, "reader", testEmail+" "+scope),
+			// Rotating the key deletes the previous one
+			testAccStepRotateKey(t, "reader"),
+			testAccStepCheckKeys(t, server, 1),
+			testAccStepReadRoleset(t, server, "reader", &keyName, true),
+			testAccStepReadToken(t, "reader", testEmail+" "+scope),
+			// Keys can't be generated from an access token roleset
+			testAccStepReadKey(t, server, "reader", true),
+			// Deleting the roleset deletes its key
+			testAccStepDeleteRoleset(t, "reader"),
+			testAccStepCheckKeys(t, server, 0),
+		},
+	})
+}
+
+func TestBackend_serviceAccountKey(t *testing.T) {
+	server, ts := newTestServer(t)
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: testBackend(t, ts.URL),
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, server),
+			testAccStepRoleset(t, "unknown", map[string]interface{}{
+				"service_account_email": "unknown@project.iam.gserviceaccount.com",
+				"secret_type":           "service_account_key",
+			}, true),
+			testAccStepRoleset(t, "keys", map[string]interface{}{
+				"service_account_email": testEmail,
+				"secret_type":           "service_account_key",
+			}, false),
+			testAccStepCheckKeys(t, server, 0),
+			testAccStepReadKey(t, server, "keys", false),
+		},
+	})
+
+	// Revoking the secret deletes the key
+	if len(server.keys) != 0 {
+		t.Fatalf("bad: %#v", server.keys)
+	}
+}
+
+func testAccStepConfig(t *testing.T, server *testServer) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"credentials": server.credentials(testAdminEmail),
+		},
+	}
+}
+
+func testAccStepRoleset(t *testing.T, name string, data map[string]interface{}, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "rolesets/" + name,
+		Data:      data,
+		ErrorOk:   expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+// testAccStepReadRoleset checks that the token key of the roleset is held
+// by the server, and whether it changed since the key seen by the previous
+// step reading the roleset
+func testAccStepReadRoleset(t *testing.T, server *testServer, name string, keyName *string, rotated bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "rolesets/" + name,
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				return fmt.Errorf("missing response")
+			}
+			name, _ := resp.Data["token_key_name"].(string)
+			if *keyName != "" && (name != *keyName) != rotated {
+				return fmt.Errorf("bad: previous key %q, got %q", *keyName, name)
+			}
+			*keyName = name
+
+			server.Lock()
+			defer server.Unlock()
+			if _, ok := server.keys[name]; !ok {
+				return fmt.Errorf("bad: %#v", server.keys)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepRotateKey(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "rolesets/" + name + "/rotate-key",
+	}
+}
+
+func testAccStepDeleteRoleset(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.DeleteOperation,
+		Path:      "rolesets/" + name,
+	}
+}
+
+// testAccStepCheckKeys reads the config and checks the number of keys the
+// server holds
+func testAccStepCheckKeys(t *testing.T, server *testServer, n int) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "config",
+		Check: func(resp *logical.Response) error {
+			server.Lock()
+			defer server.Unlock()
+			if len(server.keys) != n {
+				return fmt.Errorf("bad: %#v", server.keys)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadToken(t *testing.T, name, token string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "token/" + name,
+		Check: func(resp *logical.Response) error {
+			if resp == nil || resp.Data["token"] != token || resp.Secret != nil {
+				return fmt.Errorf("bad: %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadKey(t *testing.T, server *testServer, name string, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "key/" + name,
+		ErrorOk:   expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError {
+				if resp == nil || !resp.IsError() {
+					return fmt.Errorf("expected error, got %#v", resp)
+				}
+				return nil
+			}
+
+			credentials, err := base64.StdEncoding.DecodeString(resp.Data["private_key_data"].(string))
+			if err != nil {
+				return err
+			}
+			server.Lock()
+			defer server.Unlock()
+			if !strings.Contains(string(credentials), testEmail) || len(server.keys) != 1 {
+				return fmt.Errorf("bad: %#v", resp)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,162 @@
+package gcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// defaultIAMEndpoint is the base URL of the Google Cloud IAM API
+	defaultIAMEndpoint = "https://iam.googleapis.com/"
+
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// client talks to the Google Cloud IAM API to manage the keys of the service
+// accounts
+type client struct {
+	httpClient *http.Client
+	endpoint   string
+}
+
+// serviceAccountKey is a key of a service account. PrivateKeyData is only set
+// when the key is created.
+type serviceAccountKey struct {
+	Name            string `json:"name"`
+	PrivateKeyData  string `json:"privateKeyData,omitempty"`
+	PrivateKeyType  string `json:"privateKeyType,omitempty"`
+	KeyAlgorithm    string `json:"keyAlgorithm,omitempty"`
+	ValidAfterTime  string `json:"validAfterTime,omitempty"`
+	ValidBeforeTime string `json:"validBeforeTime,omitempty"`
+}
+
+// newClient returns a client authenticated with the JSON credentials of a
+// service account, or with the application default credentials if none are
+// given, talking to the IAM API at the given endpoint
+func newClient(credentials, endpoint string) (*client, error) {
+	var httpClient *http.Client
+	if credentials == "" {
+		var err error
+		httpClient, err = google.DefaultClient(oauth2.NoContext, cloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the application default credentials: %s", err)
+		}
+	} else {
+		jwtConfig, err := google.JWTConfigFromJSON([]byte(credentials), cloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse credentials: %s", err)
+		}
+		httpClient = jwtConfig.Client(oauth2.NoContext)
+	}
+
+	return &client{
+		httpClient: httpClient,
+		endpoint:   endpoint,
+	}, nil
+}
+
+// ServiceAccountExists returns whether the service account exists
+func (c *client) ServiceAccountExists(email string) (bool, error) {
+	err := c.do("GET", serviceAccountPath(email), nil, nil)
+	switch {
+	case err == nil:
+		return true, nil
+	case isNotFound(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// CreateKey creates a key of the service account, with its private key data
+// in the format of a JSON credentials file
+func (c *client) CreateKey(email string) (*serviceAccountKey, error) {
+	var key serviceAccountKey
+	err := c.do("POST", serviceAccountPath(email)+"/keys", map[string]interface{}{
+		"keyAlgorithm":   "KEY_ALG_RSA_2048",
+		"privateKeyType": "TYPE_GOOGLE_CREDENTIALS_FILE",
+	}, &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// DeleteKey deletes the key of the given resource name. Deleting a key that
+// doesn't exist is not an error.
+func (c *client) DeleteKey(name string) error {
+	err := c.do("DELETE", "v1/"+name, nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func serviceAccountPath(email string) string {
+	return "v1/projects/-/serviceAccounts/" + url.PathEscape(email)
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.endpoint+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &apiError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+
+	if result != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, result)
+	}
+	return nil
+}
+
+// apiError is an error response of the IAM API
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
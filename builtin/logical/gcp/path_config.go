@@ -0,0 +1,101 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config",
+		Fields: map[string]*framework.FieldSchema{
+			"credentials": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `JSON credentials file of the service account used to
+manage the keys. Defaults to the application default credentials.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigUpdate,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	// The credentials are not returned, only the service account they belong
+	// to
+	var credentials struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if config.Credentials != "" {
+		if err := json.Unmarshal([]byte(config.Credentials), &credentials); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"client_email": credentials.ClientEmail,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config := &gcpConfig{
+		Credentials: data.Get("credentials").(string),
+	}
+
+	// Make sure the credentials can be used
+	if _, err := newClient(config.Credentials, b.iamEndpoint); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid credentials: %s", err)), nil
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	// Reset the client
+	b.resetClient()
+
+	return nil, nil
+}
+
+// gcpConfig contains the credentials used to manage the keys
+type gcpConfig struct {
+	// Credentials is the JSON credentials file of a service account. If
+	// empty, the application default credentials are used.
+	Credentials string `json:"credentials"`
+}
+
+const pathConfigHelpSyn = `
+Configure the credentials used to manage the service account keys.
+`
+
+const pathConfigHelpDesc = `
+This path configures the credentials used by the backend. The "credentials"
+parameter is the JSON credentials file of a service account allowed to
+manage the keys of the service accounts of the rolesets, for example with the
+"Service Account Key Admin" role. If it is not set, the application default
+credentials of the Vault server are used.
+`
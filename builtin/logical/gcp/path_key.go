@@ -0,0 +1,88 @@
+package gcp
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "key/" + framework.GenericNameRegex("roleset"),
+		Fields: map[string]*framework.FieldSchema{
+			"roleset": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the roleset.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathKeyRead,
+		},
+
+		HelpSynopsis:    pathKeyHelpSyn,
+		HelpDescription: pathKeyHelpDesc,
+	}
+}
+
+// Creates a key of the service account of the roleset
+func (b *backend) pathKeyRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("roleset").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing roleset"), nil
+	}
+
+	roleset, err := b.Roleset(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if roleset == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown roleset: %s", name)), nil
+	}
+	if roleset.SecretType != secretTypeKey {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"roleset %s generates %s secrets; use the token path instead", name, roleset.SecretType)), nil
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	key, err := client.CreateKey(roleset.ServiceAccountEmail)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to create key: %s", err)), nil
+	}
+
+	// Return the secret
+	resp := b.Secret(SecretServiceAccountKeyType).Response(map[string]interface{}{
+		"private_key_data": key.PrivateKeyData,
+		"key_algorithm":    key.KeyAlgorithm,
+		"key_type":         key.PrivateKeyType,
+	}, map[string]interface{}{
+		"key_name": key.Name,
+		"roleset":  name,
+	})
+
+	// Determine if we have a lease
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease != nil {
+		resp.Secret.TTL = lease.TTL
+	}
+
+	return resp, nil
+}
+
+const pathKeyHelpSyn = `
+Generate a service account key for a certain roleset.
+`
+
+const pathKeyHelpDesc = `
+This path creates a new key of the service account of a service account key
+roleset. The private key data is the base64 encoded JSON credentials file of
+the key. The key will be automatically deleted when the lease is up.
+`
@@ -0,0 +1,344 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	secretTypeAccessToken = "access_token"
+	secretTypeKey         = "service_account_key"
+)
+
+func pathListRolesets(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rolesets/?$",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRolesetList,
+		},
+		HelpSynopsis:    pathRolesetHelpSyn,
+		HelpDescription: pathRolesetHelpDesc,
+	}
+}
+
+func pathRolesets(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rolesets/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the roleset.",
+			},
+			"service_account_email": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Email of the service account the credentials are generated for.",
+			},
+			"secret_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     secretTypeAccessToken,
+				Description: `Type of the generated credentials: "access_token" or "service_account_key".`,
+			},
+			"token_scopes": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the OAuth2 scopes of the access
+tokens. Required with the access_token secret type.`,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRolesetRead,
+			logical.UpdateOperation: b.pathRolesetUpdate,
+			logical.DeleteOperation: b.pathRolesetDelete,
+		},
+		HelpSynopsis:    pathRolesetHelpSyn,
+		HelpDescription: pathRolesetHelpDesc,
+	}
+}
+
+func pathRolesetRotateKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rolesets/" + framework.GenericNameRegex("name") + "/rotate-key",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the roleset.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRolesetRotateKey,
+		},
+		HelpSynopsis:    pathRolesetRotateKeyHelpSyn,
+		HelpDescription: pathRolesetRotateKeyHelpDesc,
+	}
+}
+
+// Reads the roleset from the storage
+func (b *backend) Roleset(s logical.Storage, n string) (*rolesetEntry, error) {
+	entry, err := s.Get("roleset/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result rolesetEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) putRoleset(s logical.Storage, name string, roleset *rolesetEntry) error {
+	entry, err := logical.StorageEntryJSON("roleset/"+name, roleset)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// Deletes an existing roleset along with its token key
+func (b *backend) pathRolesetDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	b.rolesetLock.Lock()
+	defer b.rolesetLock.Unlock()
+
+	roleset, err := b.Roleset(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if roleset == nil {
+		return nil, nil
+	}
+
+	if roleset.TokenKeyName != "" {
+		client, err := b.Client(req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.DeleteKey(roleset.TokenKeyName); err != nil {
+			return nil, fmt.Errorf("failed to delete the token key: %s", err)
+		}
+	}
+
+	return nil, req.Storage.Delete("roleset/" + name)
+}
+
+// Reads an existing roleset
+func (b *backend) pathRolesetRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	roleset, err := b.Roleset(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if roleset == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"service_account_email": roleset.ServiceAccountEmail,
+			"secret_type":           roleset.SecretType,
+			"token_scopes":          roleset.TokenScopes,
+			"token_key_name":        roleset.TokenKeyName,
+		},
+	}, nil
+}
+
+// Lists all the rolesets registered with the backend
+func (b *backend) pathRolesetList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	rolesets, err := req.Storage.List("roleset/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(rolesets), nil
+}
+
+// Registers a new roleset with the backend. Access token rolesets get a key
+// of their service account, used by Vault to generate the tokens.
+func (b *backend) pathRolesetUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	roleset := &rolesetEntry{
+		ServiceAccountEmail: d.Get("service_account_email").(string),
+		SecretType:          d.Get("secret_type").(string),
+	}
+	for _, scope := range strings.Split(d.Get("token_scopes").(string), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			roleset.TokenScopes = append(roleset.TokenScopes, scope)
+		}
+	}
+
+	switch {
+	case roleset.ServiceAccountEmail == "":
+		return logical.ErrorResponse("missing service_account_email"), nil
+	case roleset.SecretType == secretTypeAccessToken && len(roleset.TokenScopes) == 0:
+		return logical.ErrorResponse("token_scopes are required with the access_token secret type"), nil
+	case roleset.SecretType == secretTypeKey && len(roleset.TokenScopes) > 0:
+		return logical.ErrorResponse("token_scopes are only supported with the access_token secret type"), nil
+	case roleset.SecretType != secretTypeAccessToken && roleset.SecretType != secretTypeKey:
+		return logical.ErrorResponse(fmt.Sprintf("unknown secret_type %q", roleset.SecretType)), nil
+	}
+
+	b.rolesetLock.Lock()
+	defer b.rolesetLock.Unlock()
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	exists, err := client.ServiceAccountExists(roleset.ServiceAccountEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account: %s", err)
+	}
+	if !exists {
+		return logical.ErrorResponse(fmt.Sprintf("service account %q not found", roleset.ServiceAccountEmail)), nil
+	}
+
+	previous, err := b.Roleset(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the token key of the roleset if it is still relevant
+	var oldKeyName string
+	if previous != nil && previous.TokenKeyName != "" {
+		if roleset.SecretType == secretTypeAccessToken && previous.ServiceAccountEmail == roleset.ServiceAccountEmail {
+			roleset.TokenKeyName = previous.TokenKeyName
+			roleset.TokenKeyData = previous.TokenKeyData
+		} else {
+			oldKeyName = previous.TokenKeyName
+		}
+	}
+	if roleset.SecretType == secretTypeAccessToken && roleset.TokenKeyName == "" {
+		key, err := client.CreateKey(roleset.ServiceAccountEmail)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the token key: %s", err)
+		}
+		roleset.TokenKeyName = key.Name
+		roleset.TokenKeyData = key.PrivateKeyData
+	}
+
+	if err := b.putRoleset(req.Storage, name, roleset); err != nil {
+		return nil, err
+	}
+
+	if oldKeyName != "" {
+		if err := client.DeleteKey(oldKeyName); err != nil {
+			return nil, fmt.Errorf("failed to delete the previous token key: %s", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// Replaces the token key of an access token roleset
+func (b *backend) pathRolesetRotateKey(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	b.rolesetLock.Lock()
+	defer b.rolesetLock.Unlock()
+
+	roleset, err := b.Roleset(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if roleset == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown roleset: %s", name)), nil
+	}
+	if roleset.SecretType != secretTypeAccessToken {
+		return logical.ErrorResponse("only the rolesets of the access_token secret type have a key to rotate"), nil
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	key, err := client.CreateKey(roleset.ServiceAccountEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the token key: %s", err)
+	}
+
+	oldKeyName := roleset.TokenKeyName
+	roleset.TokenKeyName = key.Name
+	roleset.TokenKeyData = key.PrivateKeyData
+	if err := b.putRoleset(req.Storage, name, roleset); err != nil {
+		return nil, err
+	}
+
+	if oldKeyName != "" {
+		if err := client.DeleteKey(oldKeyName); err != nil {
+			return nil, fmt.Errorf("failed to delete the previous token key: %s", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// rolesetEntry defines the service account and the type of the credentials
+// issued against it
+type rolesetEntry struct {
+	ServiceAccountEmail string   `json:"service_account_email"`
+	SecretType          string   `json:"secret_type"`
+	TokenScopes         []string `json:"token_scopes"`
+
+	// TokenKeyName and TokenKeyData are the resource name and the base64
+	// encoded JSON credentials file of the key used to generate the access
+	// tokens
+	TokenKeyName string `json:"token_key_name"`
+	TokenKeyData string `json:"token_key_data"`
+}
+
+const pathRolesetHelpSyn = `
+Manage the rolesets that can be created with this backend.
+`
+
+const pathRolesetHelpDesc = `
+This path lets you manage the rolesets that can be created with this backend.
+
+The "service_account_email" parameter is the email of the existing service
+account the credentials are generated for. The permissions of the credentials
+are the ones granted to the service account in Google Cloud IAM.
+
+The "secret_type" parameter is the type of the generated credentials:
+
+  * "access_token": OAuth2 access tokens with the "token_scopes" scopes, read
+    from the "token/" path. Vault creates a key of the service account to
+    generate them, which can be rotated with the "rotate-key" endpoint of the
+    roleset. The tokens are not leased and expire after an hour.
+
+  * "service_account_key": keys of the service account, read from the "key/"
+    path. The keys are leased and deleted when the lease is revoked.
+`
+
+const pathRolesetRotateKeyHelpSyn = `
+Rotate the key used to generate the access tokens of a roleset.
+`
+
+const pathRolesetRotateKeyHelpDesc = `
+This path creates a new key of the service account of an access token
+roleset, used to generate the access tokens from then on, and deletes the
+previous key. The access tokens already generated are still valid until they
+expire.
+`
@@ -0,0 +1,84 @@
+package gcp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+func pathToken(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "token/" + framework.GenericNameRegex("roleset"),
+		Fields: map[string]*framework.FieldSchema{
+			"roleset": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the roleset.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathTokenRead,
+		},
+
+		HelpSynopsis:    pathTokenHelpSyn,
+		HelpDescription: pathTokenHelpDesc,
+	}
+}
+
+// Generates an access token from the token key of the roleset
+func (b *backend) pathTokenRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("roleset").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing roleset"), nil
+	}
+
+	roleset, err := b.Roleset(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if roleset == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown roleset: %s", name)), nil
+	}
+	if roleset.SecretType != secretTypeAccessToken {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"roleset %s generates %s secrets; use the key path instead", name, roleset.SecretType)), nil
+	}
+
+	credentials, err := base64.StdEncoding.DecodeString(roleset.TokenKeyData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the token key: %s", err)
+	}
+	jwtConfig, err := google.JWTConfigFromJSON(credentials, roleset.TokenScopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the token key: %s", err)
+	}
+	token, err := jwtConfig.TokenSource(oauth2.NoContext).Token()
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to generate access token: %s", err)), nil
+	}
+
+	// The access tokens can't be revoked, so they are not leased
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"token":              token.AccessToken,
+			"token_ttl":          int64(token.Expiry.Sub(time.Now()) / time.Second),
+			"expires_at_seconds": token.Expiry.Unix(),
+		},
+	}, nil
+}
+
+const pathTokenHelpSyn = `
+Generate an OAuth2 access token for a certain roleset.
+`
+
+const pathTokenHelpDesc = `
+This path generates an OAuth2 access token of the service account of an
+access token roleset, with the scopes of the roleset. Access tokens can't be
+revoked, so they are not leased: they expire on their own, usually after an
+hour.
+`
@@ -0,0 +1,73 @@
+package gcp
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// SecretServiceAccountKeyType is the key for this backend's secrets.
+const SecretServiceAccountKeyType = "service_account_key"
+
+func secretServiceAccountKey(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretServiceAccountKeyType,
+		Fields: map[string]*framework.FieldSchema{
+			"private_key_data": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Base64 encoded JSON credentials file of the key",
+			},
+			"key_algorithm": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Algorithm of the key",
+			},
+			"key_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Format of the private key data",
+			},
+		},
+		Renew:  b.secretServiceAccountKeyRenew,
+		Revoke: b.secretServiceAccountKeyRevoke,
+	}
+}
+
+// Renew the previously issued secret
+func (b *backend) secretServiceAccountKeyRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the lease information
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	return framework.LeaseExtend(lease.TTL, lease.MaxTTL, b.System())(req, d)
+}
+
+// Revoke the previously issued secret
+func (b *backend) secretServiceAccountKeyRevoke(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the key name from the internal data
+	keyNameRaw, ok := req.Secret.InternalData["key_name"]
+	if !ok {
+		return nil, fmt.Errorf("secret is missing key_name internal data")
+	}
+	keyName, ok := keyNameRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("secret is missing key_name internal data")
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.DeleteKey(keyName); err != nil {
+		return nil, fmt.Errorf("could not delete key: %s", err)
+	}
+
+	return nil, nil
+}
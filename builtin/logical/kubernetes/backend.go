@@ -0,0 +1,147 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// Factory creates and configures the backend
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend().Setup(conf)
+}
+
+// Backend creates a new backend with all the paths and secrets belonging to it
+func Backend() *backend {
+	var b backend
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		Paths: []*framework.Path{
+			pathConfig(&b),
+			pathConfigLease(&b),
+			pathListRoles(&b),
+			pathRoles(&b),
+			pathCreds(&b),
+		},
+
+		Secrets: []*framework.Secret{
+			secretToken(&b),
+		},
+
+		Clean: b.resetClient,
+
+		Invalidate: b.invalidate,
+	}
+
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	client *client
+	lock   sync.RWMutex
+}
+
+// Client returns the client of the Kubernetes API server
+func (b *backend) Client(s logical.Storage) (*client, error) {
+	b.lock.RLock()
+
+	// If we already have a client, return it
+	if b.client != nil {
+		b.lock.RUnlock()
+		return b.client, nil
+	}
+
+	b.lock.RUnlock()
+
+	config, err := b.config(s)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("configure the Kubernetes API server with config first")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// If the client was created during the lock switch, return it
+	if b.client != nil {
+		return b.client, nil
+	}
+
+	b.client, err = newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.client, nil
+}
+
+// resetClient forces a new client next time Client() is called.
+func (b *backend) resetClient() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.client = nil
+}
+
+func (b *backend) invalidate(key string) {
+	switch key {
+	case "config":
+		b.resetClient()
+	}
+}
+
+// config returns the configuration, or nil if the backend is not configured
+// yet
+func (b *backend) config(s logical.Storage) (*kubeConfig, error) {
+	entry, err := s.Get("config")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config kubeConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Lease returns the lease information
+func (b *backend) Lease(s logical.Storage) (*configLease, error) {
+	entry, err := s.Get("config/lease")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result configLease
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+const backendHelp = `
+The Kubernetes backend generates short-lived tokens of Kubernetes service
+accounts. Depending on the role, the tokens belong to an existing service
+account, or to a service account created for them along with a role binding
+and deleted at the end of the lease.
+
+After mounting this backend, the Kubernetes API server must be configured
+with the "config" path and roles must be written using the "roles/" endpoints
+before any tokens can be generated.
+`
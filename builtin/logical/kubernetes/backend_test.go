@@ -0,0 +1,258 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+)
+
+// testServer is a fake of the Kubernetes API server
+type testServer struct {
+	sync.Mutex
+
+	serviceAccounts map[string]bool
+	roleBindings    map[string]string
+	tokens          []string
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer vault-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 5 && parts[0] == "api" && parts[4] == "serviceaccounts" && r.Method == "POST":
+		var body struct {
+			Metadata objectMeta `json:"metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.serviceAccounts[parts[3]+"/"+body.Metadata.Name] = true
+		w.WriteHeader(http.StatusCreated)
+	case len(parts) == 6 && parts[0] == "api" && parts[4] == "serviceaccounts" && r.Method == "DELETE":
+		if !s.serviceAccounts[parts[3]+"/"+parts[5]] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.serviceAccounts, parts[3]+"/"+parts[5])
+	case len(parts) == 7 && parts[0] == "api" && parts[6] == "token" && r.Method == "POST":
+		if !s.serviceAccounts[parts[3]+"/"+parts[5]] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body struct {
+			Spec struct {
+				Audiences         []string `json:"audiences"`
+				ExpirationSeconds int64    `json:"expirationSeconds"`
+			} `json:"spec"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := fmt.Sprintf("%s/%s/%s", parts[3], parts[5], strings.Join(body.Spec.Audiences, ","))
+		s.tokens = append(s.tokens, token)
+		expiration := time.Now().Add(time.Duration(body.Spec.ExpirationSeconds) * time.Second)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": map[string]interface{}{
+				"token":               token,
+				"expirationTimestamp": expiration.UTC().Format(time.RFC3339),
+			},
+		})
+	case len(parts) == 6 && parts[1] == "rbac.authorization.k8s.io" && parts[5] == "rolebindings" && r.Method == "POST":
+		var body struct {
+			Metadata objectMeta `json:"metadata"`
+			RoleRef  struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"roleRef"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.roleBindings[parts[4]+"/"+body.Metadata.Name] = body.RoleRef.Kind + "/" + body.RoleRef.Name
+		w.WriteHeader(http.StatusCreated)
+	case len(parts) == 7 && parts[1] == "rbac.authorization.k8s.io" && parts[5] == "rolebindings" && r.Method == "DELETE":
+		if _, ok := s.roleBindings[parts[4]+"/"+parts[6]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.roleBindings, parts[4]+"/"+parts[6])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestServer() *testServer {
+	return &testServer{
+		serviceAccounts: map[string]bool{
+			"ci/deployer": true,
+		},
+		roleBindings: make(map[string]string),
+	}
+}
+
+func TestBackend_existingServiceAccount(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	server := newTestServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, ts.URL),
+			testAccStepConfigLease(t),
+			testAccStepRole(t, "deployer", map[string]interface{}{
+				"allowed_kubernetes_namespaces": "ci",
+				"service_account_name":          "deployer",
+				"token_default_audiences":       "https://kubernetes.default.svc",
+			}, false),
+			// The namespace must be allowed by the role
+			testAccStepCreds(t, "deployer", map[string]interface{}{
+				"kubernetes_namespace": "default",
+			}, func(resp *logical.Response) error {
+				if resp == nil || !resp.IsError() {
+					return fmt.Errorf("expected error, got %#v", resp)
+				}
+				return nil
+			}),
+			// The namespace defaults to the only allowed namespace of the role
+			testAccStepCreds(t, "deployer", nil, func(resp *logical.Response) error {
+				if resp.Data["service_account_token"] != "ci/deployer/https://kubernetes.default.svc" {
+					return fmt.Errorf("bad: %#v", resp)
+				}
+				if resp.Secret.TTL <= 14*time.Minute || resp.Secret.TTL > 15*time.Minute || resp.Secret.Renewable {
+					return fmt.Errorf("bad: %#v", resp.Secret)
+				}
+				return nil
+			}),
+		},
+	})
+
+	// Revoking the token leaves the service account alone
+	if !server.serviceAccounts["ci/deployer"] {
+		t.Fatalf("bad: %#v", server.serviceAccounts)
+	}
+}
+
+func TestBackend_createdServiceAccount(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	server := newTestServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, ts.URL),
+			testAccStepRole(t, "Pod_Reader", map[string]interface{}{
+				"allowed_kubernetes_namespaces": "*",
+				"kubernetes_role_name":          "view",
+				"kubernetes_role_type":          "Unknown",
+			}, true),
+			testAccStepRole(t, "Pod_Reader", map[string]interface{}{
+				"allowed_kubernetes_namespaces": "*",
+				"kubernetes_role_name":          "view",
+				"kubernetes_role_type":          "ClusterRole",
+			}, false),
+			// The namespace is required when the role allows several
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "creds/Pod_Reader",
+				ErrorOk:   true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return fmt.Errorf("expected error, got %#v", resp)
+					}
+					return nil
+				},
+			},
+			testAccStepCreds(t, "Pod_Reader", map[string]interface{}{
+				"kubernetes_namespace": "apps",
+				"audiences":            "vault",
+			}, func(resp *logical.Response) error {
+				serviceAccount := resp.Data["service_account_name"].(string)
+				if !strings.HasPrefix(serviceAccount, "vault-pod-reader-") {
+					return fmt.Errorf("bad: %#v", resp)
+				}
+				if resp.Data["service_account_token"] != "apps/"+serviceAccount+"/vault" {
+					return fmt.Errorf("bad: %#v", resp)
+				}
+				server.Lock()
+				defer server.Unlock()
+				if !server.serviceAccounts["apps/"+serviceAccount] || server.roleBindings["apps/"+serviceAccount] != "ClusterRole/view" {
+					return fmt.Errorf("bad: %#v %#v", server.serviceAccounts, server.roleBindings)
+				}
+				return nil
+			}),
+		},
+	})
+
+	// Revoking the token deletes the service account and its role binding
+	if len(server.serviceAccounts) != 1 || len(server.roleBindings) != 0 {
+		t.Fatalf("bad: %#v %#v", server.serviceAccounts, server.roleBindings)
+	}
+}
+
+func testAccStepConfig(t *testing.T, url string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"kubernetes_host":     url,
+			"service_account_jwt": "vault-token",
+		},
+	}
+}
+
+func testAccStepConfigLease(t *testing.T) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config/lease",
+		Data: map[string]interface{}{
+			"ttl": "15m",
+		},
+	}
+}
+
+func testAccStepRole(t *testing.T, name string, data map[string]interface{}, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/" + name,
+		Data:      data,
+		ErrorOk:   expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepCreds(t *testing.T, name string, data map[string]interface{}, check logicaltest.TestCheckFunc) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "creds/" + name,
+		Data:      data,
+		ErrorOk:   true,
+		Check:     check,
+	}
+}
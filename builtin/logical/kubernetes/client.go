@@ -0,0 +1,214 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// client talks to the Kubernetes API server to manage service accounts, their
+// role bindings and their tokens
+type client struct {
+	host       string
+	token      string
+	httpClient *http.Client
+}
+
+// objectMeta is the metadata of a Kubernetes object
+type objectMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+func newClient(config *kubeConfig) (*client, error) {
+	transport := cleanhttp.DefaultPooledTransport()
+	if config.CACert != "" {
+		caPool := x509.NewCertPool()
+		if ok := caPool.AppendCertsFromPEM([]byte(config.CACert)); !ok {
+			return nil, fmt.Errorf("could not parse the CA certificate")
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: caPool,
+		}
+	}
+
+	return &client{
+		host:  strings.TrimSuffix(config.Host, "/"),
+		token: config.Token,
+		httpClient: &http.Client{
+			Transport: transport,
+		},
+	}, nil
+}
+
+// ServiceAccountExists returns whether the service account exists
+func (c *client) ServiceAccountExists(namespace, name string) (bool, error) {
+	err := c.do("GET", serviceAccountPath(namespace, name), nil, nil)
+	switch {
+	case err == nil:
+		return true, nil
+	case isNotFound(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// CreateServiceAccount creates a service account
+func (c *client) CreateServiceAccount(namespace, name string, labels map[string]string) error {
+	return c.do("POST", fmt.Sprintf("/api/v1/namespaces/%s/serviceaccounts", url.PathEscape(namespace)), map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ServiceAccount",
+		"metadata": &objectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+	}, nil)
+}
+
+// DeleteServiceAccount deletes a service account, which invalidates its
+// tokens. Deleting a service account that doesn't exist is not an error.
+func (c *client) DeleteServiceAccount(namespace, name string) error {
+	err := c.do("DELETE", serviceAccountPath(namespace, name), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateRoleBinding binds the Role or ClusterRole to the service account in
+// its namespace
+func (c *client) CreateRoleBinding(namespace, name, roleType, roleName, serviceAccount string, labels map[string]string) error {
+	return c.do("POST", roleBindingsPath(namespace), map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "RoleBinding",
+		"metadata": &objectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		"roleRef": map[string]string{
+			"apiGroup": "rbac.authorization.k8s.io",
+			"kind":     roleType,
+			"name":     roleName,
+		},
+		"subjects": []map[string]string{
+			{
+				"kind":      "ServiceAccount",
+				"name":      serviceAccount,
+				"namespace": namespace,
+			},
+		},
+	}, nil)
+}
+
+// DeleteRoleBinding deletes a role binding. Deleting a role binding that
+// doesn't exist is not an error.
+func (c *client) DeleteRoleBinding(namespace, name string) error {
+	err := c.do("DELETE", roleBindingsPath(namespace)+"/"+url.PathEscape(name), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateToken requests a token of the service account, valid for the given
+// audiences until it expires
+func (c *client) CreateToken(namespace, name string, ttl time.Duration, audiences []string) (string, time.Time, error) {
+	var result struct {
+		Status struct {
+			Token               string    `json:"token"`
+			ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+		} `json:"status"`
+	}
+	err := c.do("POST", serviceAccountPath(namespace, name)+"/token", map[string]interface{}{
+		"apiVersion": "authentication.k8s.io/v1",
+		"kind":       "TokenRequest",
+		"spec": map[string]interface{}{
+			"audiences":         audiences,
+			"expirationSeconds": int64(ttl / time.Second),
+		},
+	}, &result)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return result.Status.Token, result.Status.ExpirationTimestamp, nil
+}
+
+func serviceAccountPath(namespace, name string) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/serviceaccounts/%s", url.PathEscape(namespace), url.PathEscape(name))
+}
+
+func roleBindingsPath(namespace string) string {
+	return fmt.Sprintf("/apis/rbac.authorization.k8s.io/v1/namespaces/%s/rolebindings", url.PathEscape(namespace))
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.host+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &apiError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+
+	if result != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, result)
+	}
+	return nil
+}
+
+// apiError is an error response of the Kubernetes API server
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,115 @@
+package kubernetes
+
+import (
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config",
+		Fields: map[string]*framework.FieldSchema{
+			"kubernetes_host": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "URL of the Kubernetes API server",
+			},
+			"kubernetes_ca_cert": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM-encoded CA certificate used to verify the certificate of the Kubernetes API server",
+			},
+			"service_account_jwt": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Token used to authenticate to the Kubernetes API server",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigUpdate,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	// The token is not returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"kubernetes_host":    config.Host,
+			"kubernetes_ca_cert": config.CACert,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config := &kubeConfig{
+		Host:   data.Get("kubernetes_host").(string),
+		CACert: data.Get("kubernetes_ca_cert").(string),
+		Token:  data.Get("service_account_jwt").(string),
+	}
+
+	switch {
+	case config.Host == "":
+		return logical.ErrorResponse("missing kubernetes_host"), nil
+	case config.Token == "":
+		return logical.ErrorResponse("missing service_account_jwt"), nil
+	}
+
+	if _, err := newClient(config); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	// Reset the client
+	b.resetClient()
+
+	return nil, nil
+}
+
+// kubeConfig contains the information required to talk to the Kubernetes API
+// server
+type kubeConfig struct {
+	// Host is the URL of the Kubernetes API server
+	Host string `json:"kubernetes_host"`
+
+	// CACert is the PEM-encoded CA certificate used to verify the API server
+	CACert string `json:"kubernetes_ca_cert"`
+
+	// Token is the token of a service account allowed to manage the service
+	// accounts, their role bindings and their tokens
+	Token string `json:"service_account_jwt"`
+}
+
+const pathConfigHelpSyn = `
+Configure the Kubernetes API server used to generate the tokens.
+`
+
+const pathConfigHelpDesc = `
+This path configures the Kubernetes API server used by the backend. The
+"kubernetes_host" parameter is the URL of the API server, and
+"kubernetes_ca_cert" the PEM-encoded CA certificate used to verify it.
+
+The "service_account_jwt" parameter is the token of a service account allowed
+to create tokens of the service accounts of the roles, which requires the
+"create" verb on the "serviceaccounts/token" resource. Roles creating service
+accounts also require it to manage service accounts and role bindings, and to
+bind the Kubernetes roles of the Vault roles.
+`
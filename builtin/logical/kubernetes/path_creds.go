@@ -0,0 +1,188 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// invalidNameChars are the characters not allowed in the names of Kubernetes
+// objects
+var invalidNameChars = regexp.MustCompile("[^a-z0-9-]")
+
+func pathCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+			"kubernetes_namespace": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Namespace of the token. Defaults to the allowed namespace
+of the role if it has only one.`,
+			},
+			"audiences": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of the audiences of the token. Defaults to the default audiences of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathCredsRead,
+			logical.UpdateOperation: b.pathCredsRead,
+		},
+
+		HelpSynopsis:    pathCredsReadHelpSyn,
+		HelpDescription: pathCredsReadHelpDesc,
+	}
+}
+
+// Issues the credential based on the role name
+func (b *backend) pathCredsRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	// Get the role
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	namespace := d.Get("kubernetes_namespace").(string)
+	if namespace == "" && len(role.AllowedNamespaces) == 1 && role.AllowedNamespaces[0] != "*" {
+		namespace = role.AllowedNamespaces[0]
+	}
+	switch {
+	case namespace == "":
+		return logical.ErrorResponse("missing kubernetes_namespace"), nil
+	case !role.allowsNamespace(namespace):
+		return logical.ErrorResponse(fmt.Sprintf("namespace %q is not allowed by role %s", namespace, name)), nil
+	}
+
+	audiences := parseList(d.Get("audiences").(string))
+	if len(audiences) == 0 {
+		audiences = role.DefaultAudiences
+	}
+
+	// The token expires at the end of the lease
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	ttl := b.System().DefaultLeaseTTL()
+	if lease != nil && lease.TTL > 0 {
+		ttl = lease.TTL
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	internalData := map[string]interface{}{
+		"role":                      name,
+		"service_account_namespace": namespace,
+	}
+	serviceAccount := role.ServiceAccountName
+	if serviceAccount == "" {
+		if serviceAccount, err = b.createServiceAccount(client, name, namespace, role); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		internalData["created_service_account"] = serviceAccount
+	}
+
+	token, expiration, err := client.CreateToken(namespace, serviceAccount, ttl, audiences)
+	if err != nil {
+		err = fmt.Errorf("failed to create token of service account %s/%s: %s", namespace, serviceAccount, err)
+		if role.ServiceAccountName == "" {
+			if rmErr := deleteServiceAccount(client, namespace, serviceAccount); rmErr != nil {
+				err = fmt.Errorf("%s. %s", rmErr, err)
+			}
+		}
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Return the secret
+	resp := b.Secret(SecretTokenType).Response(map[string]interface{}{
+		"service_account_token":     token,
+		"service_account_name":      serviceAccount,
+		"service_account_namespace": namespace,
+	}, internalData)
+
+	// The token has a fixed expiration, so the lease can't be renewed
+	resp.Secret.TTL = expiration.Sub(time.Now())
+	resp.Secret.Renewable = false
+
+	return resp, nil
+}
+
+// createServiceAccount creates a service account for a token of the role,
+// bound to the Kubernetes role of the role
+func (b *backend) createServiceAccount(client *client, name, namespace string, role *roleEntry) (string, error) {
+	suffix, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	prefix := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(prefix) > 40 {
+		prefix = prefix[:40]
+	}
+	serviceAccount := fmt.Sprintf("vault-%s-%s", prefix, suffix[:8])
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "vault",
+	}
+
+	if err := client.CreateServiceAccount(namespace, serviceAccount, labels); err != nil {
+		return "", fmt.Errorf("failed to create service account %s/%s: %s", namespace, serviceAccount, err)
+	}
+
+	// The role binding has the name of the service account
+	if err := client.CreateRoleBinding(namespace, serviceAccount, role.KubernetesRoleType, role.KubernetesRoleName, serviceAccount, labels); err != nil {
+		err = fmt.Errorf("failed to create role binding %s/%s: %s", namespace, serviceAccount, err)
+		if rmErr := client.DeleteServiceAccount(namespace, serviceAccount); rmErr != nil {
+			return "", fmt.Errorf("failed to delete service account %s/%s: %s. %s", namespace, serviceAccount, rmErr, err)
+		}
+		return "", err
+	}
+
+	return serviceAccount, nil
+}
+
+// deleteServiceAccount deletes a service account created for a token, along
+// with its role binding
+func deleteServiceAccount(client *client, namespace, serviceAccount string) error {
+	if err := client.DeleteRoleBinding(namespace, serviceAccount); err != nil {
+		return fmt.Errorf("failed to delete role binding %s/%s: %s", namespace, serviceAccount, err)
+	}
+	if err := client.DeleteServiceAccount(namespace, serviceAccount); err != nil {
+		return fmt.Errorf("failed to delete service account %s/%s: %s", namespace, serviceAccount, err)
+	}
+	return nil
+}
+
+const pathCredsReadHelpSyn = `
+Request a Kubernetes service account token for a certain role.
+`
+
+const pathCredsReadHelpDesc = `
+This path generates a token of the service account of a certain role, in the
+"kubernetes_namespace" namespace, for the "audiences" audiences. If the role
+has a Kubernetes role, a service account bound to it is created for the
+token, and deleted when the lease is revoked.
+
+The token expires at the end of the lease, so the lease can't be renewed.
+Note that Kubernetes API servers don't issue tokens valid for less than ten
+minutes.
+`
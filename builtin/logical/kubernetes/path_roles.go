@@ -0,0 +1,216 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/?$",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+			"allowed_kubernetes_namespaces": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the Kubernetes namespaces the
+tokens can be generated in. "*" allows all the namespaces.`,
+			},
+			"service_account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the existing service account the tokens are generated for.",
+			},
+			"kubernetes_role_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Name of the Kubernetes Role or ClusterRole bound to the
+service accounts created for the tokens.`,
+			},
+			"kubernetes_role_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "Role",
+				Description: `Kind of the Kubernetes role: "Role" or "ClusterRole".`,
+			},
+			"token_default_audiences": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of the default audiences of the tokens.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleUpdate,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+// Reads the role configuration from the storage
+func (b *backend) Role(s logical.Storage, n string) (*roleEntry, error) {
+	entry, err := s.Get("role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Deletes an existing role
+func (b *backend) pathRoleDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	return nil, req.Storage.Delete("role/" + name)
+}
+
+// Reads an existing role
+func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"allowed_kubernetes_namespaces": role.AllowedNamespaces,
+			"service_account_name":          role.ServiceAccountName,
+			"kubernetes_role_name":          role.KubernetesRoleName,
+			"kubernetes_role_type":          role.KubernetesRoleType,
+			"token_default_audiences":       role.DefaultAudiences,
+		},
+	}, nil
+}
+
+// Lists all the roles registered with the backend
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(roles), nil
+}
+
+// Registers a new role with the backend
+func (b *backend) pathRoleUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	role := &roleEntry{
+		AllowedNamespaces:  parseList(d.Get("allowed_kubernetes_namespaces").(string)),
+		ServiceAccountName: d.Get("service_account_name").(string),
+		KubernetesRoleName: d.Get("kubernetes_role_name").(string),
+		DefaultAudiences:   parseList(d.Get("token_default_audiences").(string)),
+	}
+
+	switch {
+	case len(role.AllowedNamespaces) == 0:
+		return logical.ErrorResponse("missing allowed_kubernetes_namespaces"), nil
+	case role.ServiceAccountName == "" && role.KubernetesRoleName == "":
+		return logical.ErrorResponse("both service_account_name and kubernetes_role_name not specified"), nil
+	case role.ServiceAccountName != "" && role.KubernetesRoleName != "":
+		return logical.ErrorResponse("service_account_name cannot be combined with kubernetes_role_name"), nil
+	}
+
+	if role.KubernetesRoleName != "" {
+		role.KubernetesRoleType = d.Get("kubernetes_role_type").(string)
+		if role.KubernetesRoleType != "Role" && role.KubernetesRoleType != "ClusterRole" {
+			return logical.ErrorResponse(fmt.Sprintf("invalid kubernetes_role_type %q", role.KubernetesRoleType)), nil
+		}
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("role/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// parseList parses a comma-separated list, keeping the case of the elements
+func parseList(s string) []string {
+	var result []string
+	for _, elem := range strutil.ParseStringSlice(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			result = append(result, elem)
+		}
+	}
+	return result
+}
+
+// Role that defines the service accounts of the tokens issued against it
+type roleEntry struct {
+	AllowedNamespaces  []string `json:"allowed_kubernetes_namespaces"`
+	ServiceAccountName string   `json:"service_account_name"`
+	KubernetesRoleName string   `json:"kubernetes_role_name"`
+	KubernetesRoleType string   `json:"kubernetes_role_type"`
+	DefaultAudiences   []string `json:"token_default_audiences"`
+}
+
+// allowsNamespace returns whether tokens can be generated in the namespace
+func (r *roleEntry) allowsNamespace(namespace string) bool {
+	return strutil.StrListContains(r.AllowedNamespaces, "*") || strutil.StrListContains(r.AllowedNamespaces, namespace)
+}
+
+const pathRoleHelpSyn = `
+Manage the roles that can be created with this backend.
+`
+
+const pathRoleHelpDesc = `
+This path lets you manage the roles that can be created with this backend.
+
+The "allowed_kubernetes_namespaces" parameter is the list of the namespaces
+the tokens can be generated in, "*" allowing all of them.
+
+The tokens belong either to the existing service account named by the
+"service_account_name" parameter, or to a service account created for each
+token and bound to the Kubernetes role named by the "kubernetes_role_name"
+parameter. The "kubernetes_role_type" parameter is the kind of this role,
+"Role" or "ClusterRole", and the role binding grants it in the namespace of
+the token only. The created service accounts and role bindings are deleted
+when the lease is revoked, which invalidates the tokens.
+
+Exactly one of "service_account_name" and "kubernetes_role_name" must be set.
+`
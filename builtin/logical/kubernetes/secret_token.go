@@ -0,0 +1,61 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// SecretTokenType is the key for this backend's secrets.
+const SecretTokenType = "token"
+
+func secretToken(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretTokenType,
+		Fields: map[string]*framework.FieldSchema{
+			"service_account_token": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Token of the service account",
+			},
+			"service_account_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the service account",
+			},
+			"service_account_namespace": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Namespace of the service account",
+			},
+		},
+		Revoke: b.secretTokenRevoke,
+	}
+}
+
+// Revoke the previously issued secret. Tokens of existing service accounts
+// can't be revoked and expire on their own.
+func (b *backend) secretTokenRevoke(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	serviceAccountRaw, ok := req.Secret.InternalData["created_service_account"]
+	if !ok {
+		return nil, nil
+	}
+	serviceAccount, ok := serviceAccountRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("secret has created_service_account but value could not be understood")
+	}
+	namespace, ok := req.Secret.InternalData["service_account_namespace"].(string)
+	if !ok {
+		return nil, fmt.Errorf("secret is missing service_account_namespace internal data")
+	}
+
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deleteServiceAccount(client, namespace, serviceAccount); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -82,6 +82,7 @@ func TestBackend_config_connection(t *testing.T) {
 
 	configData := map[string]interface{}{
 		"uri":               "sample_connection_uri",
+		"password_policy":   "",
 		"verify_connection": false,
 	}
 
@@ -107,6 +108,44 @@ func TestBackend_config_connection(t *testing.T) {
 	}
 }
 
+func TestBackend_roleUsernameTemplate(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roleReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/web",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"db":                "foo",
+			"username_template": "{{.Unknown}}",
+		},
+	}
+	resp, err := b.HandleRequest(roleReq)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err:%s resp:%#v\n", err, resp)
+	}
+
+	roleReq.Data["username_template"] = "v-{{.RoleName}}-{{random 8}}"
+	resp, err = b.HandleRequest(roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	roleReq.Operation = logical.ReadOperation
+	resp, err = b.HandleRequest(roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["username_template"] != "v-{{.RoleName}}-{{random 8}}" {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
 func TestBackend_basic(t *testing.T) {
 	config := logical.TestBackendConfig()
 	config.StorageView = &logical.InmemStorage{}
@@ -22,6 +22,10 @@ func pathConfigConnection(b *backend) *framework.Path {
 				Default:     true,
 				Description: `If set, uri is verified by actually connecting to the database`,
 			},
+			"password_policy": {
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate the passwords of the users. Defaults to random UUIDs.",
+			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation:   b.pathConnectionRead,
@@ -62,6 +66,15 @@ func (b *backend) pathConnectionWrite(req *logical.Request, data *framework.Fiel
 		return logical.ErrorResponse(fmt.Sprintf("invalid uri: %s", err)), nil
 	}
 
+	// Make sure the password policy exists and can generate passwords
+	passwordPolicy := data.Get("password_policy").(string)
+	if passwordPolicy != "" {
+		if _, err := b.System().GeneratePasswordFromPolicy(passwordPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error validating password policy: %s", err)), nil
+		}
+	}
+
 	// Don't check the config if verification is disabled
 	verifyConnection := data.Get("verify_connection").(bool)
 	if verifyConnection {
@@ -80,7 +93,8 @@ func (b *backend) pathConnectionWrite(req *logical.Request, data *framework.Fiel
 
 	// Store it
 	entry, err := logical.StorageEntryJSON("config/connection", connectionConfig{
-		URI: uri,
+		URI:            uri,
+		PasswordPolicy: passwordPolicy,
 	})
 	if err != nil {
 		return nil, err
@@ -99,7 +113,8 @@ func (b *backend) pathConnectionWrite(req *logical.Request, data *framework.Fiel
 }
 
 type connectionConfig struct {
-	URI string `json:"uri" structs:"uri" mapstructure:"uri"`
+	URI            string `json:"uri" structs:"uri" mapstructure:"uri"`
+	PasswordPolicy string `json:"password_policy" structs:"password_policy" mapstructure:"password_policy"`
 }
 
 const pathConfigConnectionHelpSyn = `
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/dbutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -49,19 +51,30 @@ func (b *backend) pathCredsCreateRead(req *logical.Request, data *framework.Fiel
 	}
 
 	// Generate the username and password
-	displayName := req.DisplayName
-	if displayName != "" {
-		displayName += "-"
+	var username string
+	if role.UsernameTemplate != "" {
+		username, err = templateutil.RenderUsername(role.UsernameTemplate, templateutil.UsernameData{
+			DisplayName: req.DisplayName,
+			RoleName:    name,
+		}, 0)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		displayName := req.DisplayName
+		if displayName != "" {
+			displayName += "-"
+		}
+
+		userUUID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		username = fmt.Sprintf("vault-%s%s", displayName, userUUID)
 	}
 
-	userUUID, err := uuid.GenerateUUID()
-	if err != nil {
-		return nil, err
-	}
-
-	username := fmt.Sprintf("vault-%s%s", displayName, userUUID)
-
-	password, err := uuid.GenerateUUID()
+	password, err := dbutil.GeneratePassword(req.Storage, b.System())
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package mongodb
 import (
 	"encoding/json"
 
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -36,6 +37,11 @@ func pathRoles(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "MongoDB roles to assign to the users generated for this role.",
 			},
+			"username_template": {
+				Type: framework.TypeString,
+				Description: `Template of the usernames of the generated users, in Go
+template syntax. See help for more info.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -93,8 +99,9 @@ func (b *backend) pathRoleRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"db":    role.DB,
-			"roles": string(rolesJsonBytes),
+			"db":                role.DB,
+			"roles":             string(rolesJsonBytes),
+			"username_template": role.UsernameTemplate,
 		},
 	}, nil
 }
@@ -122,6 +129,13 @@ func (b *backend) pathRoleCreate(
 		return logical.ErrorResponse("db parameter is required"), nil
 	}
 
+	usernameTemplate := data.Get("username_template").(string)
+	if usernameTemplate != "" {
+		if err := templateutil.ValidateUsernameTemplate(usernameTemplate); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	// Example roles JSON:
 	//
 	// [ "readWrite", { "role": "readWrite", "db": "test" } ]
@@ -154,8 +168,9 @@ func (b *backend) pathRoleCreate(
 
 	// Store it
 	entry, err := logical.StorageEntryJSON("role/"+name, &roleStorageEntry{
-		DB:           roleDB,
-		MongoDBRoles: roles,
+		DB:               roleDB,
+		MongoDBRoles:     roles,
+		UsernameTemplate: usernameTemplate,
 	})
 	if err != nil {
 		return nil, err
@@ -190,8 +205,9 @@ func (roles mongodbRoles) toStandardRolesArray() []interface{} {
 }
 
 type roleStorageEntry struct {
-	DB           string       `json:"db"`
-	MongoDBRoles mongodbRoles `json:"roles"`
+	DB               string       `json:"db"`
+	MongoDBRoles     mongodbRoles `json:"roles"`
+	UsernameTemplate string       `json:"username_template"`
 }
 
 type mongodbRole struct {
@@ -225,4 +241,18 @@ database:
 
 Please consult the MongoDB documentation for more
 details on Role-Based Access Control in MongoDB.
+
+The "username_template" parameter customizes the usernames of the generated
+users. It uses the Go template syntax, with the {{.DisplayName}} and
+{{.RoleName}} values, and the following functions:
+
+  random N        N random alphanumeric characters
+  uuid            a random UUID
+  unix_time       the current unix time, in seconds
+  truncate N s    the first N characters of s
+  lowercase s     s in lower case
+  uppercase s     s in upper case
+  replace a b s   s with all the occurrences of a replaced with b
+
+For example: "v_{{.RoleName}}_{{lowercase .DisplayName}}_{{random 8}}".
 `
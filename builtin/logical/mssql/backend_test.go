@@ -25,6 +25,7 @@ func TestBackend_config_connection(t *testing.T) {
 	configData := map[string]interface{}{
 		"connection_string":    "sample_connection_string",
 		"max_open_connections": 7,
+		"password_policy":      "",
 		"verify_connection":    false,
 	}
 
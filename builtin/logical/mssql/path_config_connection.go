@@ -26,6 +26,10 @@ func pathConfigConnection(b *backend) *framework.Path {
 				Default:     true,
 				Description: "If set, connection_string is verified by actually connecting to the database",
 			},
+			"password_policy": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate the passwords of the users. Defaults to random UUIDs.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -66,6 +70,15 @@ func (b *backend) pathConnectionWrite(req *logical.Request, data *framework.Fiel
 		maxOpenConns = 2
 	}
 
+	// Make sure the password policy exists and can generate passwords
+	passwordPolicy := data.Get("password_policy").(string)
+	if passwordPolicy != "" {
+		if _, err := b.System().GeneratePasswordFromPolicy(passwordPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error validating password policy: %s", err)), nil
+		}
+	}
+
 	// Don't check the connection_string if verification is disabled
 	verifyConnection := data.Get("verify_connection").(bool)
 	if verifyConnection {
@@ -87,6 +100,7 @@ func (b *backend) pathConnectionWrite(req *logical.Request, data *framework.Fiel
 	entry, err := logical.StorageEntryJSON("config/connection", connectionConfig{
 		ConnectionString:   connString,
 		MaxOpenConnections: maxOpenConns,
+		PasswordPolicy:     passwordPolicy,
 	})
 	if err != nil {
 		return nil, err
@@ -107,6 +121,7 @@ func (b *backend) pathConnectionWrite(req *logical.Request, data *framework.Fiel
 type connectionConfig struct {
 	ConnectionString   string `json:"connection_string" structs:"connection_string" mapstructure:"connection_string"`
 	MaxOpenConnections int    `json:"max_open_connections" structs:"max_open_connections" mapstructure:"max_open_connections"`
+	PasswordPolicy     string `json:"password_policy" structs:"password_policy" mapstructure:"password_policy"`
 }
 
 const pathConfigConnectionHelpSyn = `
@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/dbutil"
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -51,17 +53,29 @@ func (b *backend) pathCredsCreateRead(
 		leaseConfig = &configLease{}
 	}
 
-	// Generate our username and password
-	displayName := req.DisplayName
-	if len(displayName) > 10 {
-		displayName = displayName[:10]
-	}
-	userUUID, err := uuid.GenerateUUID()
-	if err != nil {
-		return nil, err
+	// Generate our username and password. SQL Server limits logins to 128
+	// characters
+	var username string
+	if role.UsernameTemplate != "" {
+		username, err = templateutil.RenderUsername(role.UsernameTemplate, templateutil.UsernameData{
+			DisplayName: req.DisplayName,
+			RoleName:    name,
+		}, 128)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		displayName := req.DisplayName
+		if len(displayName) > 10 {
+			displayName = displayName[:10]
+		}
+		userUUID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		username = fmt.Sprintf("%s-%s", displayName, userUUID)
 	}
-	username := fmt.Sprintf("%s-%s", displayName, userUUID)
-	password, err := uuid.GenerateUUID()
+	password, err := dbutil.GeneratePassword(req.Storage, b.System())
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +106,7 @@ func (b *backend) pathCredsCreateRead(
 
 		stmt, err := tx.Prepare(Query(query, map[string]string{
 			"name":     username,
-			"password": password,
+			"password": dbutil.EscapeLiteral(password),
 		}))
 		if err != nil {
 			return nil, err
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -35,6 +36,12 @@ func pathRoles(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "SQL string to create a role. See help for more info.",
 			},
+
+			"username_template": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Template of the usernames of the generated users, in Go
+template syntax. See help for more info.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -87,7 +94,8 @@ func (b *backend) pathRoleRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"sql": role.SQL,
+			"sql":               role.SQL,
+			"username_template": role.UsernameTemplate,
 		},
 	}, nil
 }
@@ -107,6 +115,13 @@ func (b *backend) pathRoleCreate(
 	name := data.Get("name").(string)
 	sql := data.Get("sql").(string)
 
+	usernameTemplate := data.Get("username_template").(string)
+	if usernameTemplate != "" {
+		if err := templateutil.ValidateUsernameTemplate(usernameTemplate); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	// Get our connection
 	db, err := b.DB(req.Storage)
 	if err != nil {
@@ -133,7 +148,8 @@ func (b *backend) pathRoleCreate(
 
 	// Store it
 	entry, err := logical.StorageEntryJSON("role/"+name, &roleEntry{
-		SQL: sql,
+		SQL:              sql,
+		UsernameTemplate: usernameTemplate,
 	})
 	if err != nil {
 		return nil, err
@@ -145,7 +161,8 @@ func (b *backend) pathRoleCreate(
 }
 
 type roleEntry struct {
-	SQL string `json:"sql"`
+	SQL              string `json:"sql"`
+	UsernameTemplate string `json:"username_template"`
 }
 
 const pathRoleHelpSyn = `
@@ -172,4 +189,21 @@ Example SQL query to use:
 
 Please see the Microsoft SQL Server manual on the GRANT command to learn how to
 do more fine grained access.
+
+The "username_template" parameter customizes the usernames of the generated
+users. It uses the Go template syntax, with the {{.DisplayName}} and
+{{.RoleName}} values, and the following functions:
+
+  random N        N random alphanumeric characters
+  uuid            a random UUID
+  unix_time       the current unix time, in seconds
+  truncate N s    the first N characters of s
+  lowercase s     s in lower case
+  uppercase s     s in upper case
+  replace a b s   s with all the occurrences of a replaced with b
+
+For example: "v_{{.RoleName}}_{{lowercase .DisplayName}}_{{random 8}}".
+
+Usernames longer than the 128 characters allowed by SQL Server are
+truncated.
 `
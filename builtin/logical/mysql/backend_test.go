@@ -84,6 +84,7 @@ func TestBackend_config_connection(t *testing.T) {
 		"connection_url":       "sample_connection_url",
 		"max_open_connections": 9,
 		"max_idle_connections": 7,
+		"password_policy":      "",
 		"verify_connection":    false,
 	}
 
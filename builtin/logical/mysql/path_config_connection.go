@@ -36,6 +36,10 @@ This name is deprecated.`,
 				Default:     true,
 				Description: "If set, connection_url is verified by actually connecting to the database",
 			},
+			"password_policy": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate the passwords of the users. Defaults to random UUIDs.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -92,6 +96,15 @@ func (b *backend) pathConnectionWrite(
 		maxIdleConns = maxOpenConns
 	}
 
+	// Make sure the password policy exists and can generate passwords
+	passwordPolicy := data.Get("password_policy").(string)
+	if passwordPolicy != "" {
+		if _, err := b.System().GeneratePasswordFromPolicy(passwordPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"error validating password policy: %s", err)), nil
+		}
+	}
+
 	// Don't check the connection_url if verification is disabled
 	verifyConnection := data.Get("verify_connection").(bool)
 	if verifyConnection {
@@ -114,6 +127,7 @@ func (b *backend) pathConnectionWrite(
 		ConnectionURL:      connURL,
 		MaxOpenConnections: maxOpenConns,
 		MaxIdleConnections: maxIdleConns,
+		PasswordPolicy:     passwordPolicy,
 	})
 	if err != nil {
 		return nil, err
@@ -137,6 +151,7 @@ type connectionConfig struct {
 	ConnectionString   string `json:"value" structs:"value" mapstructure:"value"`
 	MaxOpenConnections int    `json:"max_open_connections" structs:"max_open_connections" mapstructure:"max_open_connections"`
 	MaxIdleConnections int    `json:"max_idle_connections" structs:"max_idle_connections" mapstructure:"max_idle_connections"`
+	PasswordPolicy     string `json:"password_policy" structs:"password_policy" mapstructure:"password_policy"`
 }
 
 const pathConfigConnectionHelpSyn = `
@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/dbutil"
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	_ "github.com/lib/pq"
@@ -62,23 +64,37 @@ func (b *backend) pathRoleCreateRead(
 	// the entire contactenated string is then truncated to role.usernameLength,
 	// which by default is 16 due to limitations in older but still-prevalant
 	// versions of MySQL.
-	roleName := name
-	if len(roleName) > role.RolenameLength {
-		roleName = roleName[:role.RolenameLength]
-	}
-	displayName := req.DisplayName
-	if len(displayName) > role.DisplaynameLength {
-		displayName = displayName[:role.DisplaynameLength]
-	}
-	userUUID, err := uuid.GenerateUUID()
-	if err != nil {
-		return nil, err
-	}
-	username := fmt.Sprintf("%s-%s-%s", roleName, displayName, userUUID)
-	if len(username) > role.UsernameLength {
-		username = username[:role.UsernameLength]
+	//
+	// If the role has a username template, it is used instead, and the
+	// username is truncated to role.usernameLength as well.
+	var username string
+	if role.UsernameTemplate != "" {
+		username, err = templateutil.RenderUsername(role.UsernameTemplate, templateutil.UsernameData{
+			DisplayName: req.DisplayName,
+			RoleName:    name,
+		}, role.UsernameLength)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		roleName := name
+		if len(roleName) > role.RolenameLength {
+			roleName = roleName[:role.RolenameLength]
+		}
+		displayName := req.DisplayName
+		if len(displayName) > role.DisplaynameLength {
+			displayName = displayName[:role.DisplaynameLength]
+		}
+		userUUID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		username = fmt.Sprintf("%s-%s-%s", roleName, displayName, userUUID)
+		if len(username) > role.UsernameLength {
+			username = username[:role.UsernameLength]
+		}
 	}
-	password, err := uuid.GenerateUUID()
+	password, err := dbutil.GeneratePassword(req.Storage, b.System())
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +121,7 @@ func (b *backend) pathRoleCreateRead(
 
 		stmt, err := tx.Prepare(Query(query, map[string]string{
 			"name":     username,
-			"password": password,
+			"password": dbutil.EscapeMySQLLiteral(password),
 		}))
 		if err != nil {
 			return nil, err
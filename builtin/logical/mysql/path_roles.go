@@ -6,6 +6,7 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -59,6 +60,12 @@ func pathRoles(b *backend) *framework.Path {
 				Description: "number of characters to truncate the displayname portion of generated mysql usernames to (default 4)",
 				Default:     4,
 			},
+
+			"username_template": {
+				Type: framework.TypeString,
+				Description: `Template of the usernames of the generated users, in Go
+template syntax. See help for more info.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -117,8 +124,9 @@ func (b *backend) pathRoleRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"sql":            role.SQL,
-			"revocation_sql": role.RevocationSQL,
+			"sql":               role.SQL,
+			"revocation_sql":    role.RevocationSQL,
+			"username_template": role.UsernameTemplate,
 		},
 	}, nil
 }
@@ -137,6 +145,13 @@ func (b *backend) pathRoleCreate(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
+	usernameTemplate := data.Get("username_template").(string)
+	if usernameTemplate != "" {
+		if err := templateutil.ValidateUsernameTemplate(usernameTemplate); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	// Get our connection
 	db, err := b.DB(req.Storage)
 	if err != nil {
@@ -169,6 +184,7 @@ func (b *backend) pathRoleCreate(
 		UsernameLength:    data.Get("username_length").(int),
 		DisplaynameLength: data.Get("displayname_length").(int),
 		RolenameLength:    data.Get("rolename_length").(int),
+		UsernameTemplate:  usernameTemplate,
 	})
 	if err != nil {
 		return nil, err
@@ -185,6 +201,7 @@ type roleEntry struct {
 	UsernameLength    int    `json:"username_length" mapstructure:"username_length" structs:"username_length"`
 	DisplaynameLength int    `json:"displayname_length" mapstructure:"displayname_length" structs:"displayname_length"`
 	RolenameLength    int    `json:"rolename_length" mapstructure:"rolename_length" structs:"rolename_length"`
+	UsernameTemplate  string `json:"username_template" mapstructure:"username_template" structs:"username_template"`
 }
 
 const pathRoleHelpSyn = `
@@ -230,4 +247,22 @@ later, setting "username_length" to 32 and setting both "rolename_length" and
 "displayname_length" to 8.  However due the the prevalence of older versions of
 MySQL in general deployment, the defaults are currently tuned for a
 username_length of 16.
+
+The "username_template" parameter customizes the usernames of the generated
+users. It uses the Go template syntax, with the {{.DisplayName}} and
+{{.RoleName}} values, and the following functions:
+
+  random N        N random alphanumeric characters
+  uuid            a random UUID
+  unix_time       the current unix time, in seconds
+  truncate N s    the first N characters of s
+  lowercase s     s in lower case
+  uppercase s     s in upper case
+  replace a b s   s with all the occurrences of a replaced with b
+
+For example: "v_{{.RoleName}}_{{lowercase .DisplayName}}_{{random 8}}".
+
+Usernames are truncated to "username_length" characters, so make sure the
+random part of the template fits within that length. The "rolename_length" and
+"displayname_length" parameters do not apply to templated usernames.
 `
@@ -0,0 +1,76 @@
+package pki
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// validateACMEChallenge checks that the client has provisioned the response
+// to a challenge for the given domain, returning an error describing why
+// the challenge failed otherwise
+func (b *backend) validateACMEChallenge(challengeType, domain, token, keyAuthorization string) error {
+	switch challengeType {
+	case "http-01":
+		return b.validateACMEHTTP01(domain, token, keyAuthorization)
+	case "dns-01":
+		return b.validateACMEDNS01(domain, keyAuthorization)
+	}
+
+	return fmt.Errorf("unsupported challenge type %q", challengeType)
+}
+
+// validateACMEHTTP01 implements the http-01 challenge of RFC 8555 section
+// 8.3: the key authorization must be served over plain HTTP at a well-known
+// path of the domain
+func (b *backend) validateACMEHTTP01(domain, token, keyAuthorization string) error {
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, token)
+
+	resp, err := b.acmeHTTPClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d fetching %s", resp.StatusCode, url)
+	}
+
+	// The key authorization is short; don't read more than needed
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", url, err)
+	}
+
+	if strings.TrimSpace(string(body)) != keyAuthorization {
+		return fmt.Errorf("the key authorization served at %s is incorrect", url)
+	}
+
+	return nil
+}
+
+// validateACMEDNS01 implements the dns-01 challenge of RFC 8555 section 8.4:
+// the digest of the key authorization must be published in a TXT record of
+// the _acme-challenge subdomain
+func (b *backend) validateACMEDNS01(domain, keyAuthorization string) error {
+	name := "_acme-challenge." + domain
+
+	records, err := b.acmeLookupTXT(name)
+	if err != nil {
+		return fmt.Errorf("error looking up TXT records of %s: %v", name, err)
+	}
+
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no TXT record of %s matches the key authorization", name)
+}
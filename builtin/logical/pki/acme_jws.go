@@ -0,0 +1,203 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// acmeJWK is the JSON Web Key (RFC 7517) of an ACME account. Only the
+// members needed for RSA and EC public keys are kept.
+type acmeJWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+func (k *acmeJWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %v", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if exponent.BitLen() > 31 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys < 2048 bits are unsafe and not supported")
+		}
+		return key, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC point: %v", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC point: %v", err)
+		}
+
+		key := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("EC point is not on the curve")
+		}
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// thumbprint returns the JWK thumbprint of the key as defined in RFC 7638,
+// which identifies the account in key authorizations
+func (k *acmeJWK) thumbprint() string {
+	// The required members must be serialized in lexicographic order and
+	// without whitespace
+	var canonical string
+	switch k.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	default:
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Crv, k.Kty, k.X, k.Y)
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type acmeJWSHeader struct {
+	Alg   string   `json:"alg"`
+	Nonce string   `json:"nonce"`
+	URL   string   `json:"url"`
+	JWK   *acmeJWK `json:"jwk"`
+	KID   string   `json:"kid"`
+}
+
+// acmeJWS is a request body in the flattened JSON serialization of JWS, as
+// required for all ACME POST requests
+type acmeJWS struct {
+	Header  acmeJWSHeader
+	Payload []byte
+
+	signingInput []byte
+	signature    []byte
+}
+
+func parseACMEJWS(raw map[string]interface{}) (*acmeJWS, error) {
+	protected, _ := raw["protected"].(string)
+	payload, ok := raw["payload"].(string)
+	signature, _ := raw["signature"].(string)
+	if protected == "" || !ok || signature == "" {
+		return nil, fmt.Errorf("request must be a JWS with protected, payload and signature members")
+	}
+
+	var jws acmeJWS
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(protected)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protected header encoding: %v", err)
+	}
+	if err := json.Unmarshal(headerBytes, &jws.Header); err != nil {
+		return nil, fmt.Errorf("invalid protected header: %v", err)
+	}
+
+	if jws.Payload, err = base64.RawURLEncoding.DecodeString(payload); err != nil {
+		return nil, fmt.Errorf("invalid payload encoding: %v", err)
+	}
+	if jws.signature, err = base64.RawURLEncoding.DecodeString(signature); err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	jws.signingInput = []byte(protected + "." + payload)
+
+	return &jws, nil
+}
+
+// verify checks the signature of the JWS with the given public key
+func (j *acmeJWS) verify(key crypto.PublicKey) error {
+	var hash crypto.Hash
+	switch j.Header.Alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "ES384":
+		hash = crypto.SHA384
+	case "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", j.Header.Alg)
+	}
+
+	h := hash.New()
+	h.Write(j.signingInput)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if j.Header.Alg != "RS256" {
+			return fmt.Errorf("algorithm %q cannot be used with an RSA key", j.Header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, j.signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+
+	case *ecdsa.PublicKey:
+		curveAlgs := map[string]string{
+			"P-256": "ES256",
+			"P-384": "ES384",
+			"P-521": "ES512",
+		}
+		if curveAlgs[key.Curve.Params().Name] != j.Header.Alg {
+			return fmt.Errorf("algorithm %q cannot be used with curve %s", j.Header.Alg, key.Curve.Params().Name)
+		}
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(j.signature) != 2*size {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(j.signature[:size])
+		s := new(big.Int).SetBytes(j.signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+
+	default:
+		return fmt.Errorf("unsupported key type")
+	}
+
+	return nil
+}
@@ -1,10 +1,14 @@
 package pki
 
 import (
+	"net"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -28,12 +32,30 @@ func Backend() *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"crl/delta",
+				"crl/delta/pem",
+				"acme/*",
+				"est/*",
+				"scep",
+				"scep/*",
+				"ocsp",
+				"ocsp/*",
+				"crl/issuer/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
+				"crls/",
+				"delta-crl",
 				"certs/",
+				"tidy_status",
+			},
+
+			SealWrapStorage: []string{
+				"issuers/",
+				"config/ca_bundle",
+				"config/intermediate_key",
 			},
 		},
 
@@ -41,6 +63,7 @@ func Backend() *backend {
 			pathListRoles(&b),
 			pathRoles(&b),
 			pathGenerateRoot(&b),
+			pathRotateRoot(&b),
 			pathGenerateIntermediate(&b),
 			pathSetSignedIntermediate(&b),
 			pathSignIntermediate(&b),
@@ -58,16 +81,51 @@ func Backend() *backend {
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
 			pathRevoke(&b),
+			pathListIssuers(&b),
+			pathIssuer(&b),
+			pathIssuerGenerateCSR(&b),
+			pathIssuerCrossSigned(&b),
+			pathConfigIssuers(&b),
+			pathFetchIssuerCRL(&b),
 			pathTidy(&b),
+			pathTidyStatus(&b),
+			pathConfigAutoTidy(&b),
+			pathConfigACME(&b),
+			pathACMEDirectory(&b),
+			pathACMENewNonce(&b),
+			pathACMENewAccount(&b),
+			pathACMEAccount(&b),
+			pathACMENewOrder(&b),
+			pathACMEOrder(&b),
+			pathACMEOrderFinalize(&b),
+			pathACMEAuthorization(&b),
+			pathACMEChallenge(&b),
+			pathACMECertificate(&b),
+			pathACMERevokeCert(&b),
+			pathConfigOCSP(&b),
+			pathOCSP(&b),
+			pathOCSPGet(&b),
+			pathConfigEST(&b),
+			pathESTCACerts(&b),
+			pathESTSimpleEnroll(&b),
+			pathESTSimpleReenroll(&b),
+			pathSCEP(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
 		},
+
+		PeriodicFunc: b.periodicFunc,
 	}
 
 	b.crlLifetime = time.Hour * 72
 
+	b.acmeNonces = make(map[string]time.Time)
+	b.acmeHTTPClient = cleanhttp.DefaultClient()
+	b.acmeHTTPClient.Timeout = 10 * time.Second
+	b.acmeLookupTXT = net.LookupTXT
+
 	return &b
 }
 
@@ -76,6 +134,38 @@ type backend struct {
 
 	crlLifetime       time.Duration
 	revokeStorageLock sync.RWMutex
+
+	// issuersLock serializes changes to the issuers
+	issuersLock sync.Mutex
+
+	// acmeLock serializes changes to the ACME accounts, orders and
+	// authorizations
+	acmeLock       sync.Mutex
+	acmeNonces     map[string]time.Time
+	acmeNonceQueue []string
+	acmeNoncesLock sync.Mutex
+
+	// tidyRunning is set while a tidy operation is in progress
+	tidyRunning int32
+
+	// acmeHTTPClient and acmeLookupTXT are used to validate the ACME
+	// challenges
+	acmeHTTPClient *http.Client
+	acmeLookupTXT  func(string) ([]string, error)
+}
+
+// periodicFunc of the backend will be invoked once a minute by the
+// RollbackManager. It rebuilds the CRL before it expires and tidies up the
+// storage when configured to.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	var result error
+	if err := rebuildExpiringCRL(b, req); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := runAutoTidy(b, req); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result
 }
 
 const backendHelp = `
@@ -83,4 +173,13 @@ The PKI backend dynamically generates X509 server and client certificates.
 
 After mounting this backend, configure the CA using the "pem_bundle" endpoint within
 the "config/" path.
+
+Certificates can also be requested by ACME clients once enabled with the
+"config/acme" endpoint.
+
+The revocation status of the issued certificates is available through the
+CRL and the OCSP responder at the "ocsp" endpoint.
+
+Devices without a Vault client can enroll through EST or SCEP once enabled
+with the "config/est" endpoint.
 `
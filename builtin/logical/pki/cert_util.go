@@ -9,10 +9,14 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"net"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -47,6 +51,12 @@ type creationBundle struct {
 	KeyUsage       x509.KeyUsage
 	ExtKeyUsage    certExtKeyUsage
 
+	// Extended key usages configured in the role in addition to the flags,
+	// and the custom extensions supplied in the request
+	OtherExtKeyUsages []x509.ExtKeyUsage
+	ExtKeyUsageOIDs   []asn1.ObjectIdentifier
+	Extensions        []pkix.Extension
+
 	// Only used when signing a CA cert
 	UseCSRValues bool
 
@@ -55,11 +65,191 @@ type creationBundle struct {
 
 	// The maximum path length to encode
 	MaxPathLength int
+
+	// Name constraints and policies of CA certs
+	CAConstraints *caConstraints
+}
+
+// caConstraints technically constrains the certificates a CA can issue
+type caConstraints struct {
+	PermittedDNSDomains     []string
+	ExcludedDNSDomains      []string
+	PermittedIPRanges       []*net.IPNet
+	ExcludedIPRanges        []*net.IPNet
+	PermittedEmailAddresses []string
+	ExcludedEmailAddresses  []string
+	PolicyIdentifiers       []asn1.ObjectIdentifier
+}
+
+// parseCAConstraints reads the name constraints and policy identifiers of a
+// CA certificate
+func parseCAConstraints(data *framework.FieldData) (*caConstraints, error) {
+	constraints := &caConstraints{
+		PermittedDNSDomains:     strutil.ParseDedupAndSortStrings(data.Get("permitted_dns_domains").(string), ","),
+		ExcludedDNSDomains:      strutil.ParseDedupAndSortStrings(data.Get("excluded_dns_domains").(string), ","),
+		PermittedEmailAddresses: strutil.ParseDedupAndSortStrings(data.Get("permitted_email_addresses").(string), ","),
+		ExcludedEmailAddresses:  strutil.ParseDedupAndSortStrings(data.Get("excluded_email_addresses").(string), ","),
+	}
+
+	var err error
+	constraints.PermittedIPRanges, err = parseIPRanges(data.Get("permitted_ip_ranges").(string))
+	if err != nil {
+		return nil, err
+	}
+	constraints.ExcludedIPRanges, err = parseIPRanges(data.Get("excluded_ip_ranges").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, policy := range strutil.ParseDedupAndSortStrings(data.Get("policy_identifiers").(string), ",") {
+		oid, err := parseOID(policy)
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("invalid policy identifier %q", policy)}
+		}
+		constraints.PolicyIdentifiers = append(constraints.PolicyIdentifiers, oid)
+	}
+
+	if err := checkNameConstraintsSupported(constraints); err != nil {
+		return nil, err
+	}
+
+	return constraints, nil
+}
+
+// parseOID parses an object identifier in dotted decimal notation
+func parseOID(value string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, component := range strings.Split(value, ".") {
+		number, err := strconv.Atoi(component)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid object identifier %q", value)
+		}
+		oid = append(oid, number)
+	}
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("invalid object identifier %q", value)
+	}
+	return oid, nil
+}
+
+// parseOIDs parses a comma-separated list of object identifiers
+func parseOIDs(input string) ([]asn1.ObjectIdentifier, error) {
+	var oids []asn1.ObjectIdentifier
+	for _, value := range strutil.ParseDedupAndSortStrings(input, ",") {
+		oid, err := parseOID(value)
+		if err != nil {
+			return nil, err
+		}
+		oids = append(oids, oid)
+	}
+	return oids, nil
+}
+
+// reservedExtensionOIDs are the extensions built from the role and the CA, which
+// custom extensions may not override
+var reservedExtensionOIDs = []string{
+	"2.5.29.14",         // subject key identifier
+	"2.5.29.15",         // key usage
+	"2.5.29.17",         // subject alternative name
+	"2.5.29.19",         // basic constraints
+	"2.5.29.30",         // name constraints
+	"2.5.29.31",         // CRL distribution points
+	"2.5.29.32",         // certificate policies
+	"2.5.29.35",         // authority key identifier
+	"2.5.29.37",         // extended key usage
+	"1.3.6.1.5.5.7.1.1", // authority information access
+}
+
+// parseRequestExtensions reads the custom extensions of a request, which must
+// be allowed by the role
+func parseRequestExtensions(data *framework.FieldData, role *roleEntry) ([]pkix.Extension, error) {
+	extensionsRaw, ok := data.GetOk("extensions")
+	if !ok {
+		return nil, nil
+	}
+	values := extensionsRaw.(map[string]interface{})
+	critical := strutil.ParseDedupAndSortStrings(data.Get("critical_extensions").(string), ",")
+	allowed := strutil.ParseDedupAndSortStrings(role.AllowedExtensions, ",")
+
+	for _, oid := range critical {
+		if _, ok := values[oid]; !ok {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"critical extension %s is not in the requested extensions", oid)}
+		}
+	}
+
+	// Sort the extensions so they are always encoded in the same order
+	oids := make([]string, 0, len(values))
+	for oid := range values {
+		oids = append(oids, oid)
+	}
+	sort.Strings(oids)
+
+	var extensions []pkix.Extension
+	for _, oidStr := range oids {
+		oid, err := parseOID(oidStr)
+		if err != nil {
+			return nil, errutil.UserError{Err: err.Error()}
+		}
+		if !strutil.StrListContains(allowed, oid.String()) {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"extension %s not allowed by this role", oidStr)}
+		}
+
+		encoded, ok := values[oidStr].(string)
+		if !ok {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"the value of extension %s must be a base64-encoded string", oidStr)}
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"unable to decode the value of extension %s: %s", oidStr, err)}
+		}
+
+		extensions = append(extensions, pkix.Extension{
+			Id:       oid,
+			Critical: strutil.StrListContains(critical, oidStr),
+			Value:    value,
+		})
+	}
+
+	return extensions, nil
+}
+
+func parseIPRanges(ranges string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, cidr := range strutil.ParseDedupAndSortStrings(ranges, ",") {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("invalid IP range %q: %v", cidr, err)}
+		}
+		result = append(result, ipNet)
+	}
+	return result, nil
+}
+
+// addCAConstraints adds the name constraints and policy identifiers to the
+// template of a CA certificate
+func addCAConstraints(creationInfo *creationBundle, certTemplate *x509.Certificate) {
+	constraints := creationInfo.CAConstraints
+	if constraints == nil {
+		return
+	}
+
+	setNameConstraints(constraints, certTemplate)
+	certTemplate.PolicyIdentifiers = constraints.PolicyIdentifiers
+
+	// RFC 5280 requires the name constraints to be critical
+	certTemplate.PermittedDNSDomainsCritical = true
 }
 
 type caInfoBundle struct {
 	certutil.ParsedCertBundle
 	URLs *urlEntries
+
+	// Certificates of the CA cross-signed by other CAs
+	CrossSigned []*certutil.CertBlock
 }
 
 func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
@@ -78,6 +268,10 @@ func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
 		}
 	}
 
+	// Clients trusting the other CAs build their path through the
+	// cross-signed certificates
+	chain = append(chain, b.CrossSigned...)
+
 	return chain
 }
 
@@ -128,6 +322,11 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 			return logical.ErrorResponse(fmt.Sprintf(
 				"unsupported bit length for EC key: %d", keyBits))
 		}
+	case "ed25519":
+		// The key size is fixed, so the bit length is ignored
+		if !certutil.Ed25519Supported() {
+			return logical.ErrorResponse("ed25519 keys are not supported by this build of Vault")
+		}
 	default:
 		return logical.ErrorResponse(fmt.Sprintf(
 			"unknown key type %s", keyType))
@@ -136,47 +335,11 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 	return nil
 }
 
-// Fetches the CA info. Unlike other certificates, the CA info is stored
-// in the backend as a CertBundle, because we are storing its private key
+// Fetches the CA info of the default issuer. Unlike other certificates, the
+// CA info is stored in the backend as a CertBundle, because we are storing
+// its private key
 func fetchCAInfo(req *logical.Request) (*caInfoBundle, error) {
-	bundleEntry, err := req.Storage.Get("config/ca_bundle")
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
-	}
-	if bundleEntry == nil {
-		return nil, errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
-	}
-
-	var bundle certutil.CertBundle
-	if err := bundleEntry.DecodeJSON(&bundle); err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode local CA certificate/key: %v", err)}
-	}
-
-	parsedBundle, err := bundle.ToParsedCertBundle()
-	if err != nil {
-		return nil, errutil.InternalError{Err: err.Error()}
-	}
-
-	if parsedBundle.Certificate == nil {
-		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
-	}
-
-	caInfo := &caInfoBundle{*parsedBundle, nil}
-
-	entries, err := getURLs(req)
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
-	}
-	if entries == nil {
-		entries = &urlEntries{
-			IssuingCertificates:   []string{},
-			CRLDistributionPoints: []string{},
-			OCSPServers:           []string{},
-		}
-	}
-	caInfo.URLs = entries
-
-	return caInfo, nil
+	return fetchCAInfoByRef(req, defaultIssuerRef)
 }
 
 // Allows fetching certificates from the backend; it handles the slightly
@@ -193,6 +356,8 @@ func fetchCertBySerial(req *logical.Request, prefix, serial string) (*logical.St
 		path = "ca"
 	case serial == "crl":
 		path = "crl"
+	case serial == "delta-crl":
+		path = "delta-crl"
 	default:
 		path = "certs/" + strings.Replace(strings.ToLower(serial), "-", ":", -1)
 	}
@@ -414,7 +579,7 @@ func generateCert(b *backend,
 		}
 	}
 
-	parsedBundle, err := createCertificate(creationBundle)
+	parsedBundle, err := createCertificate(creationBundle, b.GetRandomReader())
 	if err != nil {
 		return nil, err
 	}
@@ -435,7 +600,7 @@ func generateIntermediateCSR(b *backend,
 		return nil, err
 	}
 
-	parsedBundle, err := createCSR(creationBundle)
+	parsedBundle, err := createCSR(creationBundle, b.GetRandomReader())
 	if err != nil {
 		return nil, err
 	}
@@ -512,6 +677,14 @@ func signCert(b *backend,
 				pubKey.Params().BitSize)}
 		}
 
+	case "ed25519":
+		// Verify that the key matches the role type
+		if csr.PublicKeyAlgorithm != x509Ed25519 {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"role requires keys of type %s",
+				role.KeyType)}
+		}
+
 	case "any":
 		// We only care about running RSA < 2048 bit checks, so if not RSA
 		// break out
@@ -734,6 +907,21 @@ func generateCreationBundle(b *backend,
 		}
 	}
 
+	otherExtUsages, err := parseExtKeyUsages(role.ExtKeyUsage)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+	extUsageOIDs, err := parseOIDs(role.ExtKeyUsageOIDs)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+
+	// Read in the custom extensions
+	extensions, err := parseRequestExtensions(data, role)
+	if err != nil {
+		return nil, err
+	}
+
 	creationBundle := &creationBundle{
 		CommonName:     cn,
 		OU:             ou,
@@ -747,6 +935,11 @@ func generateCreationBundle(b *backend,
 		TTL:            ttl,
 		KeyUsage:       x509.KeyUsage(parseKeyUsages(role.KeyUsage)),
 		ExtKeyUsage:    extUsage,
+		CAConstraints:  role.CAConstraints,
+
+		OtherExtKeyUsages: otherExtUsages,
+		ExtKeyUsageOIDs:   extUsageOIDs,
+		Extensions:        extensions,
 	}
 
 	// Don't deal with URLs or max path length if it's self-signed, as these
@@ -803,11 +996,29 @@ func addKeyUsages(creationInfo *creationBundle, certTemplate *x509.Certificate)
 	if creationInfo.ExtKeyUsage&emailProtectionExtKeyUsage != 0 {
 		certTemplate.ExtKeyUsage = append(certTemplate.ExtKeyUsage, x509.ExtKeyUsageEmailProtection)
 	}
+	for _, usage := range creationInfo.OtherExtKeyUsages {
+		// Avoid duplicating the usages also set through the flags
+		if !extKeyUsageListContains(certTemplate.ExtKeyUsage, usage) {
+			certTemplate.ExtKeyUsage = append(certTemplate.ExtKeyUsage, usage)
+		}
+	}
+	certTemplate.UnknownExtKeyUsage = creationInfo.ExtKeyUsageOIDs
+
+	certTemplate.ExtraExtensions = append(certTemplate.ExtraExtensions, creationInfo.Extensions...)
+}
+
+func extKeyUsageListContains(usages []x509.ExtKeyUsage, usage x509.ExtKeyUsage) bool {
+	for _, u := range usages {
+		if u == usage {
+			return true
+		}
+	}
+	return false
 }
 
 // Performs the heavy lifting of creating a certificate. Returns
 // a fully-filled-in ParsedCertBundle.
-func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle, error) {
+func createCertificate(creationInfo *creationBundle, randReader io.Reader) (*certutil.ParsedCertBundle, error) {
 	var err error
 	result := &certutil.ParsedCertBundle{}
 
@@ -816,9 +1027,10 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 		return nil, err
 	}
 
-	if err := certutil.GeneratePrivateKey(creationInfo.KeyType,
+	if err := certutil.GeneratePrivateKeyWithRandomSource(creationInfo.KeyType,
 		creationInfo.KeyBits,
-		result); err != nil {
+		result,
+		randReader); err != nil {
 		return nil, err
 	}
 
@@ -852,6 +1064,10 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 
 	addKeyUsages(creationInfo, certTemplate)
 
+	if creationInfo.IsCA {
+		addCAConstraints(creationInfo, certTemplate)
+	}
+
 	certTemplate.IssuingCertificateURL = creationInfo.URLs.IssuingCertificates
 	certTemplate.CRLDistributionPoints = creationInfo.URLs.CRLDistributionPoints
 	certTemplate.OCSPServer = creationInfo.URLs.OCSPServers
@@ -863,6 +1079,8 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case certutil.ECPrivateKey:
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case certutil.Ed25519PrivateKey:
+			certTemplate.SignatureAlgorithm = x509PureEd25519
 		}
 
 		caCert := creationInfo.SigningBundle.Certificate
@@ -882,6 +1100,8 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case "ec":
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case "ed25519":
+			certTemplate.SignatureAlgorithm = x509PureEd25519
 		}
 
 		certTemplate.BasicConstraintsValid = true
@@ -899,17 +1119,7 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 	}
 
 	if creationInfo.SigningBundle != nil {
-		if len(creationInfo.SigningBundle.Certificate.AuthorityKeyId) > 0 &&
-			!bytes.Equal(creationInfo.SigningBundle.Certificate.AuthorityKeyId, creationInfo.SigningBundle.Certificate.SubjectKeyId) {
-
-			result.CAChain = []*certutil.CertBlock{
-				&certutil.CertBlock{
-					Certificate: creationInfo.SigningBundle.Certificate,
-					Bytes:       creationInfo.SigningBundle.CertificateBytes,
-				},
-			}
-			result.CAChain = append(result.CAChain, creationInfo.SigningBundle.CAChain...)
-		}
+		result.CAChain = creationInfo.SigningBundle.GetCAChain()
 	}
 
 	return result, nil
@@ -917,13 +1127,14 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 
 // Creates a CSR. This is currently only meant for use when
 // generating an intermediate certificate.
-func createCSR(creationInfo *creationBundle) (*certutil.ParsedCSRBundle, error) {
+func createCSR(creationInfo *creationBundle, randReader io.Reader) (*certutil.ParsedCSRBundle, error) {
 	var err error
 	result := &certutil.ParsedCSRBundle{}
 
-	if err := certutil.GeneratePrivateKey(creationInfo.KeyType,
+	if err := certutil.GeneratePrivateKeyWithRandomSource(creationInfo.KeyType,
 		creationInfo.KeyBits,
-		result); err != nil {
+		result,
+		randReader); err != nil {
 		return nil, err
 	}
 
@@ -944,6 +1155,8 @@ func createCSR(creationInfo *creationBundle) (*certutil.ParsedCSRBundle, error)
 		csrTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case "ec":
 		csrTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case "ed25519":
+		csrTemplate.SignatureAlgorithm = x509PureEd25519
 	}
 
 	csr, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, result.PrivateKey)
@@ -1010,6 +1223,8 @@ func signCertificate(creationInfo *creationBundle,
 		certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case certutil.ECPrivateKey:
 		certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case certutil.Ed25519PrivateKey:
+		certTemplate.SignatureAlgorithm = x509PureEd25519
 	}
 
 	if creationInfo.UseCSRValues {
@@ -1048,6 +1263,8 @@ func signCertificate(creationInfo *creationBundle,
 		if certTemplate.MaxPathLen == 0 {
 			certTemplate.MaxPathLenZero = true
 		}
+
+		addCAConstraints(creationInfo, certTemplate)
 	}
 
 	certBytes, err = x509.CreateCertificate(rand.Reader, certTemplate, caCert, csr.PublicKey, creationInfo.SigningBundle.PrivateKey)
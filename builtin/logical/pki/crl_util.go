@@ -1,10 +1,16 @@
 package pki
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/hashicorp/vault/helper/errutil"
@@ -98,7 +104,7 @@ func revokeCert(b *backend, req *logical.Request, serial string, fromLease bool)
 
 	}
 
-	crlErr := buildCRL(b, req)
+	crlErr := buildCRLAfterRevocation(b, req)
 	switch crlErr.(type) {
 	case errutil.UserError:
 		return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
@@ -117,39 +123,74 @@ func revokeCert(b *backend, req *logical.Request, serial string, fromLease bool)
 	return resp, nil
 }
 
-// Builds a CRL by going through the list of revoked certificates and building
-// a new CRL with the stored revocation times and serial numbers.
-func buildCRL(b *backend, req *logical.Request) error {
+// crlState tracks the CRLs built by the backend. Complete and delta CRLs
+// share the sequence of CRL numbers.
+type crlState struct {
+	// Number is the CRL number of the last CRLs built
+	Number int64 `json:"number"`
+
+	// BaseNumber and BaseTime are the CRL number and build time of the last
+	// complete CRLs, which the delta CRLs refer to
+	BaseNumber int64     `json:"base_number"`
+	BaseTime   time.Time `json:"base_time"`
+
+	// NextUpdate is the time at which the last complete CRLs expire
+	NextUpdate time.Time `json:"next_update"`
+}
+
+func fetchCRLState(req *logical.Request) (*crlState, error) {
+	entry, err := req.Storage.Get("crl_state")
+	if err != nil {
+		return nil, err
+	}
+
+	var state crlState
+	if entry != nil {
+		if err := entry.DecodeJSON(&state); err != nil {
+			return nil, err
+		}
+	}
+
+	return &state, nil
+}
+
+// revokedCertEntry is a revoked certificate along with its CRL entry
+type revokedCertEntry struct {
+	cert  *x509.Certificate
+	entry pkix.RevokedCertificate
+}
+
+func fetchRevokedCerts(req *logical.Request) ([]revokedCertEntry, error) {
 	revokedSerials, err := req.Storage.List("revoked/")
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("Error fetching list of revoked certs: %s", err)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("Error fetching list of revoked certs: %s", err)}
 	}
 
-	revokedCerts := []pkix.RevokedCertificate{}
-	var revInfo revocationInfo
+	revoked := []revokedCertEntry{}
 	for _, serial := range revokedSerials {
 		revokedEntry, err := req.Storage.Get("revoked/" + serial)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Unable to fetch revoked cert with serial %s: %s", serial, err)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Unable to fetch revoked cert with serial %s: %s", serial, err)}
 		}
 		if revokedEntry == nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Revoked certificate entry for serial %s is nil", serial)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Revoked certificate entry for serial %s is nil", serial)}
 		}
 		if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
 			// TODO: In this case, remove it and continue? How likely is this to
 			// happen? Alternately, could skip it entirely, or could implement a
 			// delete function so that there is a way to remove these
-			return errutil.InternalError{Err: fmt.Sprintf("Found revoked serial but actual certificate is empty")}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Found revoked serial but actual certificate is empty")}
 		}
 
+		var revInfo revocationInfo
 		err = revokedEntry.DecodeJSON(&revInfo)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Error decoding revocation entry for serial %s: %s", serial, err)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Error decoding revocation entry for serial %s: %s", serial, err)}
 		}
 
 		revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Unable to parse stored revoked certificate with serial %s: %s", serial, err)}
 		}
 
 		// NOTE: We have to change this to UTC time because the CRL standard
@@ -162,42 +203,358 @@ func buildCRL(b *backend, req *logical.Request) error {
 		} else {
 			newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
 		}
-		revokedCerts = append(revokedCerts, newRevCert)
+
+		revoked = append(revoked, revokedCertEntry{
+			cert:  revokedCert,
+			entry: newRevCert,
+		})
 	}
 
-	signingBundle, caErr := fetchCAInfo(req)
-	switch caErr.(type) {
-	case errutil.UserError:
-		return errutil.UserError{Err: fmt.Sprintf("Could not fetch the CA certificate: %s", caErr)}
-	case errutil.InternalError:
-		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CA certificate: %s", caErr)}
+	return revoked, nil
+}
+
+// buildCRLAfterRevocation updates the CRLs after a certificate is revoked.
+// When delta CRLs are enabled only those are rebuilt, the complete CRLs
+// being left to the periodic rebuild.
+func buildCRLAfterRevocation(b *backend, req *logical.Request) error {
+	crlInfo, err := b.CRL(req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL config information: %s", err)}
+	}
+	if crlInfo != nil && crlInfo.EnableDelta {
+		return buildCRLs(b, req, true)
+	}
+
+	return buildCRL(b, req)
+}
+
+// Builds the CRL of every issuer by going through the list of revoked
+// certificates and building new CRLs with the stored revocation times and
+// serial numbers.
+func buildCRL(b *backend, req *logical.Request) error {
+	if err := buildCRLs(b, req, false); err != nil {
+		return err
 	}
 
-	crlLifetime := b.crlLifetime
 	crlInfo, err := b.CRL(req.Storage)
 	if err != nil {
 		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL config information: %s", err)}
 	}
-	if crlInfo != nil {
-		crlDur, err := time.ParseDuration(crlInfo.Expiry)
+	if crlInfo != nil && crlInfo.EnableDelta {
+		// Start over with empty delta CRLs referring to the new complete CRLs
+		return buildCRLs(b, req, true)
+	}
+
+	// Delta CRLs referring to older complete CRLs must not be served anymore
+	keys := []string{"delta-crl"}
+	issuerIDs, err := req.Storage.List("issuers/")
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error fetching issuers: %s", err)}
+	}
+	for _, id := range issuerIDs {
+		keys = append(keys, "crls/"+id+"/delta")
+	}
+	for _, key := range keys {
+		if err := req.Storage.Delete(key); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error deleting delta CRL: %s", err)}
+		}
+	}
+
+	return nil
+}
+
+// buildCRLs builds either the complete CRLs or, if delta is true, the delta
+// CRLs listing the certificates revoked since the complete CRLs were built
+func buildCRLs(b *backend, req *logical.Request, delta bool) error {
+	now := time.Now().UTC()
+
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error fetching issuers configuration: %s", err)}
+	}
+	if config.Default == "" {
+		return errutil.UserError{Err: "Could not fetch the CA certificate: backend must be configured with a CA certificate/key"}
+	}
+
+	issuers, err := listIssuers(req)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error fetching issuers: %s", err)}
+	}
+
+	state, err := fetchCRLState(req)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL state: %s", err)}
+	}
+	if delta && state.BaseNumber == 0 {
+		// Delta CRLs need a complete CRL to refer to
+		return buildCRL(b, req)
+	}
+
+	revoked, err := fetchRevokedCerts(req)
+	if err != nil {
+		return err
+	}
+
+	// The CRL of every issuer lists the certificates it signed, while the
+	// CRL of the default issuer stored at the location used before multiple
+	// issuers were supported keeps listing all of them
+	var defaultBundle *caInfoBundle
+	allRevokedCerts := []pkix.RevokedCertificate{}
+	signingBundles := make([]*caInfoBundle, len(issuers))
+	revokedCerts := make([][]pkix.RevokedCertificate, len(issuers))
+	for i, issuer := range issuers {
+		signingBundle, caErr := issuer.caInfo(req)
+		if caErr != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error fetching CA certificate of issuer %s: %s", issuer.ID, caErr)}
+		}
+		signingBundles[i] = signingBundle
+		revokedCerts[i] = []pkix.RevokedCertificate{}
+		if issuer.ID == config.Default {
+			defaultBundle = signingBundle
+		}
+	}
+	if defaultBundle == nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Default issuer %s not found", config.Default)}
+	}
+
+	for _, revokedCert := range revoked {
+		// Certificates revoked before the complete CRLs were built are
+		// already listed on them
+		if delta && !revokedCert.entry.RevocationTime.After(state.BaseTime) {
+			continue
+		}
+
+		allRevokedCerts = append(allRevokedCerts, revokedCert.entry)
+		for i, signingBundle := range signingBundles {
+			if issuedBy(revokedCert.cert, signingBundle.Certificate) {
+				revokedCerts[i] = append(revokedCerts[i], revokedCert.entry)
+				break
+			}
+		}
+	}
+
+	state.Number++
+	params := crlParams{
+		Number:     state.Number,
+		ThisUpdate: now,
+	}
+	suffix, legacyKey := "", "crl"
+	if delta {
+		// Delta CRLs are superseded along with their complete CRL
+		params.BaseNumber = state.BaseNumber
+		params.NextUpdate = state.NextUpdate
+		suffix, legacyKey = "/delta", "delta-crl"
+	} else {
+		crlLifetime := b.crlLifetime
+		crlInfo, err := b.CRL(req.Storage)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL config information: %s", err)}
+		}
+		if crlInfo != nil {
+			crlDur, err := time.ParseDuration(crlInfo.Expiry)
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("Error parsing CRL duration of %s", crlInfo.Expiry)}
+			}
+			crlLifetime = crlDur
+		}
+
+		params.NextUpdate = now.Add(crlLifetime)
+		state.BaseNumber = state.Number
+		state.BaseTime = now
+		state.NextUpdate = params.NextUpdate
+	}
+
+	for i, signingBundle := range signingBundles {
+		crlBytes, err := createCRL(signingBundle, revokedCerts[i], params)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error creating new CRL: %s", err)}
+		}
+
+		err = req.Storage.Put(&logical.StorageEntry{
+			Key:   "crls/" + issuers[i].ID + suffix,
+			Value: crlBytes,
+		})
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Error parsing CRL duration of %s", crlInfo.Expiry)}
+			return errutil.InternalError{Err: fmt.Sprintf("Error storing CRL: %s", err)}
 		}
-		crlLifetime = crlDur
 	}
 
-	crlBytes, err := signingBundle.Certificate.CreateCRL(rand.Reader, signingBundle.PrivateKey, revokedCerts, time.Now(), time.Now().Add(crlLifetime))
+	crlBytes, err := createCRL(defaultBundle, allRevokedCerts, params)
 	if err != nil {
 		return errutil.InternalError{Err: fmt.Sprintf("Error creating new CRL: %s", err)}
 	}
 
 	err = req.Storage.Put(&logical.StorageEntry{
-		Key:   "crl",
+		Key:   legacyKey,
 		Value: crlBytes,
 	})
 	if err != nil {
 		return errutil.InternalError{Err: fmt.Sprintf("Error storing CRL: %s", err)}
 	}
 
+	entry, err := logical.StorageEntryJSON("crl_state", state)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error encoding CRL state: %s", err)}
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error storing CRL state: %s", err)}
+	}
+
 	return nil
 }
+
+// rebuildExpiringCRL rebuilds the complete CRLs when they are about to
+// expire, if automatic rebuilding is enabled
+func rebuildExpiringCRL(b *backend, req *logical.Request) error {
+	crlInfo, err := b.CRL(req.Storage)
+	if err != nil {
+		return err
+	}
+	if crlInfo == nil || !crlInfo.AutoRebuild {
+		return nil
+	}
+
+	gracePeriod, err := time.ParseDuration(crlInfo.AutoRebuildGracePeriod)
+	if err != nil {
+		return fmt.Errorf("error parsing CRL auto rebuild grace period of %s", crlInfo.AutoRebuildGracePeriod)
+	}
+
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return err
+	}
+	if config.Default == "" {
+		return nil
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	state, err := fetchCRLState(req)
+	if err != nil {
+		return err
+	}
+	if state.BaseNumber != 0 && time.Now().Add(gracePeriod).Before(state.NextUpdate) {
+		return nil
+	}
+
+	return buildCRL(b, req)
+}
+
+// crlParams holds the values of a CRL besides its entries. BaseNumber is
+// set for delta CRLs only.
+type crlParams struct {
+	Number     int64
+	BaseNumber int64
+	ThisUpdate time.Time
+	NextUpdate time.Time
+}
+
+// createCRL builds a CRL signed by the CA. It is encoded by hand, as the
+// standard library can't set the CRL number and delta CRL indicator
+// extensions without requiring the CA certificate to assert the CRL signing
+// key usage, which older CAs of this backend don't.
+func createCRL(caInfo *caInfoBundle, revokedCerts []pkix.RevokedCertificate, params crlParams) ([]byte, error) {
+	key, ok := caInfo.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the private key of the CA can't sign")
+	}
+
+	signatureAlgorithm, err := signatureAlgorithmForKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	tbsCertList := pkix.TBSCertificateList{
+		Version:             1,
+		Signature:           signatureAlgorithm,
+		Issuer:              caInfo.Certificate.Subject.ToRDNSequence(),
+		ThisUpdate:          params.ThisUpdate.UTC(),
+		NextUpdate:          params.NextUpdate.UTC(),
+		RevokedCertificates: revokedCerts,
+	}
+
+	if len(caInfo.Certificate.SubjectKeyId) > 0 {
+		aki, err := asn1.Marshal(struct {
+			ID []byte `asn1:"optional,tag:0"`
+		}{caInfo.Certificate.SubjectKeyId})
+		if err != nil {
+			return nil, err
+		}
+		tbsCertList.Extensions = append(tbsCertList.Extensions, pkix.Extension{Id: oidAuthorityKeyID, Value: aki})
+	}
+
+	number, err := asn1.Marshal(big.NewInt(params.Number))
+	if err != nil {
+		return nil, err
+	}
+	tbsCertList.Extensions = append(tbsCertList.Extensions, pkix.Extension{Id: oidCRLNumber, Value: number})
+
+	if params.BaseNumber != 0 {
+		baseNumber, err := asn1.Marshal(big.NewInt(params.BaseNumber))
+		if err != nil {
+			return nil, err
+		}
+		tbsCertList.Extensions = append(tbsCertList.Extensions, pkix.Extension{Id: oidDeltaCRLIndicator, Critical: true, Value: baseNumber})
+	}
+
+	tbsCertListBytes, err := asn1.Marshal(tbsCertList)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signWithKey(key, tbsCertListBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkix.CertificateList{
+		TBSCertList:        pkix.TBSCertificateList{Raw: tbsCertListBytes},
+		SignatureAlgorithm: signatureAlgorithm,
+		SignatureValue: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+	})
+}
+
+var (
+	oidAuthorityKeyID    = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidCRLNumber         = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// signatureAlgorithmForKey returns the identifier of the signature algorithm
+// used with the key: SHA-256 with RSA and ECDSA keys, or Ed25519
+func signatureAlgorithmForKey(key crypto.Signer) (pkix.AlgorithmIdentifier, error) {
+	var signatureAlgorithm pkix.AlgorithmIdentifier
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		signatureAlgorithm.Algorithm = oidSHA256WithRSA
+		// The parameters are an ASN.1 NULL
+		signatureAlgorithm.Parameters = asn1.RawValue{Tag: 5}
+	case *ecdsa.PublicKey:
+		signatureAlgorithm.Algorithm = oidECDSAWithSHA256
+	default:
+		if !isEd25519PublicKey(key.Public()) {
+			return signatureAlgorithm, fmt.Errorf("unsupported signing key type %T", key.Public())
+		}
+		signatureAlgorithm.Algorithm = oidEd25519
+	}
+
+	return signatureAlgorithm, nil
+}
+
+func signWithKey(key crypto.Signer, data []byte) ([]byte, error) {
+	if isEd25519PublicKey(key.Public()) {
+		// Ed25519 signs the message itself
+		return key.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(data)
+	return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
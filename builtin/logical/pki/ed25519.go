@@ -0,0 +1,22 @@
+// +build go1.13
+
+package pki
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+)
+
+// The Ed25519 keys are only supported by crypto/x509 from Go 1.13, see
+// certutil.Ed25519Supported
+
+const (
+	x509Ed25519     = x509.Ed25519
+	x509PureEd25519 = x509.PureEd25519
+)
+
+func isEd25519PublicKey(key crypto.PublicKey) bool {
+	_, ok := key.(ed25519.PublicKey)
+	return ok
+}
@@ -0,0 +1,147 @@
+// +build go1.13
+
+package pki
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_Ed25519(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCert := func(pemCert string) *x509.Certificate {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			t.Fatalf("bad certificate: %q", pemCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	resp := request("root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Ed25519 Root",
+		"ttl":         "24h",
+		"key_type":    "ed25519",
+	})
+	rootCert := parseCert(resp.Data["certificate"].(string))
+	if rootCert.PublicKeyAlgorithm != x509.Ed25519 || rootCert.SignatureAlgorithm != x509.PureEd25519 {
+		t.Fatalf("bad: root key %v, signature %v", rootCert.PublicKeyAlgorithm, rootCert.SignatureAlgorithm)
+	}
+
+	request("roles/grpc", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+		"key_type":         "ed25519",
+	})
+	resp = request("issue/grpc", map[string]interface{}{
+		"common_name": "svc.example.com",
+	})
+	if resp.Data["private_key_type"] != certutil.Ed25519PrivateKey {
+		t.Fatalf("bad: private key type: %v", resp.Data["private_key_type"])
+	}
+	cert := parseCert(resp.Data["certificate"].(string))
+	if _, ok := cert.PublicKey.(ed25519.PublicKey); !ok {
+		t.Fatalf("bad: public key type: %T", cert.PublicKey)
+	}
+	if err := cert.CheckSignatureFrom(rootCert); err != nil {
+		t.Fatalf("certificate not signed by the root: %v", err)
+	}
+	serial := resp.Data["serial_number"].(string)
+
+	// Signing CSRs checks the key type of the role
+	_, csrKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "csr.example.com"},
+	}, csrKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+
+	resp = request("sign/grpc", map[string]interface{}{
+		"csr":         csrPEM,
+		"common_name": "csr.example.com",
+	})
+	if !bytes.Equal(parseCert(resp.Data["certificate"].(string)).PublicKey.(ed25519.PublicKey), csrKey.Public().(ed25519.PublicKey)) {
+		t.Fatalf("bad: signed certificate does not hold the CSR key")
+	}
+
+	request("roles/rsa", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "sign/rsa",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"csr":         csrPEM,
+			"common_name": "csr.example.com",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error signing an Ed25519 CSR for an RSA role, got: %v, %#v", err, resp)
+	}
+
+	// The CRL is signed with the Ed25519 key
+	request("revoke", map[string]interface{}{"serial_number": serial})
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "crl",
+		Storage:   storage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rootCert.CheckCRLSignature(crl); err != nil || len(crl.TBSCertList.RevokedCertificates) != 1 {
+		t.Fatalf("bad: CRL: %v, %#v", err, crl.TBSCertList.RevokedCertificates)
+	}
+
+	// Intermediates can use Ed25519 keys too
+	resp = request("intermediate/generate/internal", map[string]interface{}{
+		"common_name": "Vault Ed25519 Intermediate",
+		"key_type":    "ed25519",
+	})
+	resp = request("root/sign-intermediate", map[string]interface{}{
+		"csr":    resp.Data["csr"],
+		"format": "pem_bundle",
+		"ttl":    "12h",
+	})
+	request("intermediate/set-signed", map[string]interface{}{
+		"certificate": resp.Data["certificate"],
+		"set_default": false,
+	})
+}
@@ -0,0 +1,17 @@
+// +build !go1.13
+
+package pki
+
+import (
+	"crypto"
+	"crypto/x509"
+)
+
+const (
+	x509Ed25519     = x509.UnknownPublicKeyAlgorithm
+	x509PureEd25519 = x509.UnknownSignatureAlgorithm
+)
+
+func isEd25519PublicKey(key crypto.PublicKey) bool {
+	return false
+}
@@ -0,0 +1,130 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// The ASN.1 structures below follow RFC 2315 and RFC 2986; EST (RFC 7030)
+// returns certificates as degenerate "certs-only" PKCS#7 messages and the
+// shared secret of a client is carried in the challengePassword attribute of
+// its CSR
+
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+	oidChallengePassword = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	SignerInfos      []asn1.RawValue `asn1:"set"`
+}
+
+type csrAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type csrTBS struct {
+	Version    int
+	Subject    asn1.RawValue
+	PublicKey  asn1.RawValue
+	Attributes []csrAttribute `asn1:"optional,tag:0"`
+}
+
+// createCertsOnlyPKCS7 returns a signed-data PKCS#7 message without signers
+// holding the given certificates
+func createCertsOnlyPKCS7(certs []*x509.Certificate) ([]byte, error) {
+	var rawCerts []byte
+	for _, cert := range certs {
+		rawCerts = append(rawCerts, cert.Raw...)
+	}
+
+	signedData, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{},
+		ContentInfo: pkcs7ContentInfo{
+			ContentType: oidPKCS7Data,
+		},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      rawCerts,
+		},
+		SignerInfos: []asn1.RawValue{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7SignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      signedData,
+		},
+	})
+}
+
+// csrChallengePassword returns the challengePassword attribute of the CSR,
+// or an empty string if it has none
+func csrChallengePassword(csr *x509.CertificateRequest) (string, error) {
+	var tbs csrTBS
+	if _, err := asn1.Unmarshal(csr.RawTBSCertificateRequest, &tbs); err != nil {
+		return "", fmt.Errorf("unable to parse the CSR attributes: %v", err)
+	}
+
+	for _, attribute := range tbs.Attributes {
+		if !attribute.Type.Equal(oidChallengePassword) {
+			continue
+		}
+		if len(attribute.Values) != 1 {
+			return "", fmt.Errorf("the challengePassword attribute must have a single value")
+		}
+		var password string
+		if _, err := asn1.Unmarshal(attribute.Values[0].FullBytes, &password); err != nil {
+			return "", fmt.Errorf("unable to parse the challengePassword attribute: %v", err)
+		}
+		return password, nil
+	}
+
+	return "", nil
+}
+
+// decodeESTBody decodes the base64 body of an EST request, which may be
+// split over several lines
+func decodeESTBody(body []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+}
+
+// encodeESTBody encodes the body of an EST response in base64, as required
+// by RFC 7030, wrapping lines at 64 characters
+func encodeESTBody(body []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(body)
+
+	var lines []string
+	for len(encoded) > 64 {
+		lines = append(lines, encoded[:64])
+		encoded = encoded[64:]
+	}
+	lines = append(lines, encoded)
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
@@ -67,6 +67,19 @@ default TTL is used, in that order. Cannot
 be later than the role max TTL.`,
 	}
 
+	fields["extensions"] = &framework.FieldSchema{
+		Type: framework.TypeMap,
+		Description: `Custom extensions to add to the certificate, as
+a map of OIDs to base64-encoded DER values. The
+OIDs must be allowed by the role.`,
+	}
+
+	fields["critical_extensions"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `The OIDs of the custom extensions to mark as
+critical, in a comma-delimited list.`,
+	}
+
 	return fields
 }
 
@@ -122,14 +135,14 @@ the private key!`,
 		Default: 2048,
 		Description: `The number of bits to use. You will almost
 certainly want to change this if you adjust
-the key_type.`,
+the key_type. Ignored for "ed25519" keys.`,
 	}
 
 	fields["key_type"] = &framework.FieldSchema{
 		Type:    framework.TypeString,
 		Default: "rsa",
-		Description: `The type of key to use; defaults to RSA. "rsa"
-and "ec" are the only valid values.`,
+		Description: `The type of key to use; defaults to RSA. "rsa",
+"ec" and "ed25519" are the only valid values.`,
 	}
 
 	return fields
@@ -144,5 +157,79 @@ func addCAIssueFields(fields map[string]*framework.FieldSchema) map[string]*fram
 		Description: "The maximum allowable path length",
 	}
 
+	fields["permitted_dns_domains"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Comma-separated list of the DNS domains, and
+their subdomains, that the CA may issue
+certificates for. A leading period restricts the
+constraint to subdomains.`,
+	}
+
+	fields["excluded_dns_domains"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Comma-separated list of the DNS domains, and
+their subdomains, that the CA must not issue
+certificates for.`,
+	}
+
+	fields["permitted_ip_ranges"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Comma-separated list of the IP ranges, in CIDR
+notation, that the CA may issue certificates for.`,
+	}
+
+	fields["excluded_ip_ranges"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Comma-separated list of the IP ranges, in CIDR
+notation, that the CA must not issue certificates
+for.`,
+	}
+
+	fields["permitted_email_addresses"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Comma-separated list of the email addresses,
+hosts or domains that the CA may issue certificates
+for.`,
+	}
+
+	fields["excluded_email_addresses"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Comma-separated list of the email addresses,
+hosts or domains that the CA must not issue
+certificates for.`,
+	}
+
+	fields["policy_identifiers"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Comma-separated list of the certificate policy
+OIDs, e.g. "1.3.6.1.4.1.44947.1.1.1", to encode in
+the CA certificate.`,
+	}
+
+	return fields
+}
+
+// addIssuerNameFields adds fields naming the issuer created from a new CA
+// certificate
+func addIssuerNameFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name of the new issuer, which can then
+be used instead of its ID to refer to it.`,
+	}
+
+	return fields
+}
+
+// addIssuerRefFields adds fields selecting the issuer signing a
+// certificate
+func addIssuerRefFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_ref"] = &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: defaultIssuerRef,
+		Description: `ID or name of the issuer signing the certificate;
+defaults to the default issuer.`,
+	}
+
 	return fields
 }
@@ -0,0 +1,378 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// defaultIssuerRef refers to the issuer configured as the default of the
+// backend
+const defaultIssuerRef = "default"
+
+var issuerNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// issuerEntry is a CA of the backend. Several issuers can be configured so
+// that a CA can be rotated while the certificates of the previous one are
+// still in use: all of them keep their CRL up to date and answer OCSP
+// requests, and roles select the one they issue certificates from.
+type issuerEntry struct {
+	ID     string               `json:"id"`
+	Name   string               `json:"name"`
+	Bundle *certutil.CertBundle `json:"bundle"`
+
+	// CrossSignedCertificates holds the PEM-encoded certificates issued by
+	// other CAs for the subject and key of the issuer
+	CrossSignedCertificates []string `json:"cross_signed_certificates,omitempty"`
+}
+
+// issuersConfig holds the issuer used when none is explicitly requested
+type issuersConfig struct {
+	Default string `json:"default"`
+}
+
+// fetchIssuersConfig returns the issuers configuration, migrating the single
+// CA bundle of older versions to an issuer on first use
+func fetchIssuersConfig(req *logical.Request) (*issuersConfig, error) {
+	entry, err := req.Storage.Get("config/issuers")
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		var config issuersConfig
+		if err := entry.DecodeJSON(&config); err != nil {
+			return nil, err
+		}
+		return &config, nil
+	}
+
+	return migrateLegacyCABundle(req)
+}
+
+func migrateLegacyCABundle(req *logical.Request) (*issuersConfig, error) {
+	config := &issuersConfig{}
+
+	bundleEntry, err := req.Storage.Get("config/ca_bundle")
+	if err != nil {
+		return nil, err
+	}
+	if bundleEntry == nil {
+		return config, nil
+	}
+
+	var bundle certutil.CertBundle
+	if err := bundleEntry.DecodeJSON(&bundle); err != nil {
+		return nil, fmt.Errorf("unable to decode local CA certificate/key: %v", err)
+	}
+
+	if bundle.Certificate == "" {
+		// A key waiting for its intermediate certificate to be set
+		entry, err := logical.StorageEntryJSON("config/intermediate_key", bundle)
+		if err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Put(entry); err != nil {
+			return nil, err
+		}
+	} else {
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		if err := storeIssuer(req, &issuerEntry{ID: id, Bundle: &bundle}); err != nil {
+			return nil, err
+		}
+		config.Default = id
+	}
+
+	entry, err := logical.StorageEntryJSON("config/issuers", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Delete("config/ca_bundle"); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func storeIssuer(req *logical.Request, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON("issuers/"+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+	return req.Storage.Put(entry)
+}
+
+func fetchIssuerByID(req *logical.Request, id string) (*issuerEntry, error) {
+	entry, err := req.Storage.Get("issuers/" + id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issuer issuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, err
+	}
+
+	return &issuer, nil
+}
+
+// listIssuers returns all the issuers of the backend
+func listIssuers(req *logical.Request) ([]*issuerEntry, error) {
+	if _, err := fetchIssuersConfig(req); err != nil {
+		return nil, err
+	}
+
+	ids, err := req.Storage.List("issuers/")
+	if err != nil {
+		return nil, err
+	}
+
+	var issuers []*issuerEntry
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(req, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil {
+			issuers = append(issuers, issuer)
+		}
+	}
+
+	return issuers, nil
+}
+
+// resolveIssuerRef returns the issuer referred to by "default", its ID or
+// its name, or nil if there is none
+func resolveIssuerRef(req *logical.Request, ref string) (*issuerEntry, error) {
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref == "" || ref == defaultIssuerRef {
+		if config.Default == "" {
+			return nil, nil
+		}
+		return fetchIssuerByID(req, config.Default)
+	}
+
+	issuer, err := fetchIssuerByID(req, ref)
+	if err != nil || issuer != nil {
+		return issuer, err
+	}
+
+	issuers, err := listIssuers(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuer := range issuers {
+		if issuer.Name == ref {
+			return issuer, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// validateIssuerName checks that the name can be used to refer to the
+// issuer with the given ID
+func validateIssuerName(req *logical.Request, name, id string) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultIssuerRef || !issuerNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid issuer name %q", name)
+	}
+
+	existing, err := resolveIssuerRef(req, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return fmt.Errorf("issuer name %q is already in use", name)
+	}
+
+	return nil
+}
+
+// setDefaultIssuer makes the issuer the default one, also storing its
+// certificate at the location of the single CA of older versions. The CRL
+// must be rebuilt afterwards to be signed by the new default issuer.
+func setDefaultIssuer(req *logical.Request, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON("config/issuers", &issuersConfig{Default: issuer.ID})
+	if err != nil {
+		return err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return err
+	}
+
+	parsedBundle, err := issuer.Bundle.ToParsedCertBundle()
+	if err != nil {
+		return err
+	}
+	err = req.Storage.Put(&logical.StorageEntry{
+		Key:   "ca",
+		Value: parsedBundle.CertificateBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// addIssuer stores an issuer built from the given CA bundle, optionally
+// making it the default one. The first issuer always becomes the default.
+func (b *backend) addIssuer(req *logical.Request, parsedBundle *certutil.ParsedCertBundle, name string, setDefault bool) (*issuerEntry, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuers, err := listIssuers(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Importing the certificate of an existing issuer again only updates
+	// its key, e.g. when setting a signed intermediate also loaded through
+	// "config/ca"
+	var issuer *issuerEntry
+	for _, existing := range issuers {
+		existingBundle, err := existing.Bundle.ToParsedCertBundle()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(existingBundle.CertificateBytes, parsedBundle.CertificateBytes) {
+			issuer = existing
+			break
+		}
+	}
+
+	if issuer == nil {
+		if err := validateIssuerName(req, name, ""); err != nil {
+			return nil, errutil.UserError{Err: err.Error()}
+		}
+
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		issuer = &issuerEntry{
+			ID:   id,
+			Name: name,
+		}
+	}
+
+	issuer.Bundle, err = parsedBundle.ToCertBundle()
+	if err != nil {
+		return nil, fmt.Errorf("error converting raw values into cert bundle: %s", err)
+	}
+	if err := storeIssuer(req, issuer); err != nil {
+		return nil, err
+	}
+
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	if setDefault || config.Default == "" {
+		if err := setDefaultIssuer(req, issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	// Build a fresh CRL
+	if err := buildCRL(b, req); err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// fetchCAInfoByRef returns the CA info of the referenced issuer
+func fetchCAInfoByRef(req *logical.Request, ref string) (*caInfoBundle, error) {
+	issuer, err := resolveIssuerRef(req, ref)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
+	}
+	if issuer == nil {
+		if ref == "" || ref == defaultIssuerRef {
+			return nil, errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
+		}
+		return nil, errutil.UserError{Err: fmt.Sprintf("unknown issuer %q", ref)}
+	}
+
+	return issuer.caInfo(req)
+}
+
+func (i *issuerEntry) caInfo(req *logical.Request) (*caInfoBundle, error) {
+	parsedBundle, err := i.Bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+
+	if parsedBundle.Certificate == nil {
+		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
+	}
+
+	caInfo := &caInfoBundle{ParsedCertBundle: *parsedBundle}
+
+	for _, pemCert := range i.CrossSignedCertificates {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			return nil, errutil.InternalError{Err: "stored cross-signed certificate not able to be parsed"}
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("stored cross-signed certificate not able to be parsed: %v", err)}
+		}
+		caInfo.CrossSigned = append(caInfo.CrossSigned, &certutil.CertBlock{
+			Certificate: cert,
+			Bytes:       block.Bytes,
+		})
+	}
+
+	entries, err := getURLs(req)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
+	}
+	if entries == nil {
+		entries = &urlEntries{
+			IssuingCertificates:   []string{},
+			CRLDistributionPoints: []string{},
+			OCSPServers:           []string{},
+		}
+	}
+	caInfo.URLs = entries
+
+	return caInfo, nil
+}
+
+// issuedBy returns true if the certificate was signed by the issuer. Issuers
+// rotated in place usually share their subject, so the key identifiers are
+// compared too when available.
+func issuedBy(cert, issuer *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, issuer.RawSubject) {
+		return false
+	}
+	if len(cert.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 {
+		return bytes.Equal(cert.AuthorityKeyId, issuer.SubjectKeyId)
+	}
+	return cert.CheckSignatureFrom(issuer) == nil
+}
@@ -0,0 +1,23 @@
+// +build go1.10
+
+package pki
+
+import (
+	"crypto/x509"
+)
+
+// The excluded DNS domains are only supported by crypto/x509 from Go 1.9, and
+// the IP and email constraints from Go 1.10
+
+func checkNameConstraintsSupported(constraints *caConstraints) error {
+	return nil
+}
+
+func setNameConstraints(constraints *caConstraints, certTemplate *x509.Certificate) {
+	certTemplate.PermittedDNSDomains = constraints.PermittedDNSDomains
+	certTemplate.ExcludedDNSDomains = constraints.ExcludedDNSDomains
+	certTemplate.PermittedIPRanges = constraints.PermittedIPRanges
+	certTemplate.ExcludedIPRanges = constraints.ExcludedIPRanges
+	certTemplate.PermittedEmailAddresses = constraints.PermittedEmailAddresses
+	certTemplate.ExcludedEmailAddresses = constraints.ExcludedEmailAddresses
+}
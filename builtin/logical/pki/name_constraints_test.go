@@ -0,0 +1,117 @@
+// +build go1.10
+
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_CAConstraints(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCert := func(pemCert string) *x509.Certificate {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			t.Fatalf("bad certificate: %q", pemCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"policy_identifiers": "1.3.6.foo"},
+		{"policy_identifiers": "1"},
+		{"permitted_ip_ranges": "10.0.0.1"},
+	} {
+		data["common_name"] = "Vault Constrained Root"
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "root/generate/internal",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %#v, got: %v, %#v", data, err, resp)
+		}
+	}
+
+	resp := request("root/generate/internal", map[string]interface{}{
+		"common_name":           "Vault Constrained Root",
+		"ttl":                   "24h",
+		"permitted_dns_domains": "example.com,example.org",
+		"excluded_dns_domains":  "secret.example.com",
+		"excluded_ip_ranges":    "10.0.0.0/8",
+		"policy_identifiers":    "1.3.6.1.4.1.44947.1.1.1",
+	})
+	rootCert := parseCert(resp.Data["certificate"].(string))
+	if !reflect.DeepEqual(rootCert.PermittedDNSDomains, []string{"example.com", "example.org"}) ||
+		!reflect.DeepEqual(rootCert.ExcludedDNSDomains, []string{"secret.example.com"}) ||
+		len(rootCert.ExcludedIPRanges) != 1 || rootCert.ExcludedIPRanges[0].String() != "10.0.0.0/8" ||
+		!rootCert.PermittedDNSDomainsCritical {
+		t.Fatalf("bad: root name constraints: %#v, %#v, %#v", rootCert.PermittedDNSDomains, rootCert.ExcludedDNSDomains, rootCert.ExcludedIPRanges)
+	}
+	if len(rootCert.PolicyIdentifiers) != 1 || rootCert.PolicyIdentifiers[0].String() != "1.3.6.1.4.1.44947.1.1.1" {
+		t.Fatalf("bad: root policies: %v", rootCert.PolicyIdentifiers)
+	}
+
+	resp = request("intermediate/generate/internal", map[string]interface{}{
+		"common_name": "Vault Constrained Intermediate",
+	})
+	resp = request("root/sign-intermediate", map[string]interface{}{
+		"csr":                       resp.Data["csr"],
+		"ttl":                       "12h",
+		"permitted_dns_domains":     "internal.example.com",
+		"permitted_email_addresses": "example.com",
+		"policy_identifiers":        "2.23.140.1.2.1",
+	})
+	intermediateCert := parseCert(resp.Data["certificate"].(string))
+	if !reflect.DeepEqual(intermediateCert.PermittedDNSDomains, []string{"internal.example.com"}) ||
+		!reflect.DeepEqual(intermediateCert.PermittedEmailAddresses, []string{"example.com"}) ||
+		len(intermediateCert.PolicyIdentifiers) != 1 || intermediateCert.PolicyIdentifiers[0].String() != "2.23.140.1.2.1" {
+		t.Fatalf("bad: intermediate constraints: %#v, %#v, %v", intermediateCert.PermittedDNSDomains, intermediateCert.PermittedEmailAddresses, intermediateCert.PolicyIdentifiers)
+	}
+
+	// Clients reject the certificates issued outside of the constraints
+	request("roles/any", map[string]interface{}{
+		"allow_any_name": true,
+		"ttl":            "1h",
+	})
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+	for name, valid := range map[string]bool{
+		"www.example.com":    true,
+		"secret.example.com": false,
+		"www.example.net":    false,
+	} {
+		resp = request("issue/any", map[string]interface{}{
+			"common_name": name,
+		})
+		_, err := parseCert(resp.Data["certificate"].(string)).Verify(x509.VerifyOptions{
+			DNSName: name,
+			Roots:   roots,
+		})
+		if valid != (err == nil) {
+			t.Fatalf("bad: verification of %s: %v", name, err)
+		}
+	}
+}
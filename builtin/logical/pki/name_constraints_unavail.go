@@ -0,0 +1,22 @@
+// +build !go1.10
+
+package pki
+
+import (
+	"crypto/x509"
+
+	"github.com/hashicorp/vault/helper/errutil"
+)
+
+func checkNameConstraintsSupported(constraints *caConstraints) error {
+	if len(constraints.ExcludedDNSDomains) != 0 ||
+		len(constraints.PermittedIPRanges) != 0 || len(constraints.ExcludedIPRanges) != 0 ||
+		len(constraints.PermittedEmailAddresses) != 0 || len(constraints.ExcludedEmailAddresses) != 0 {
+		return errutil.UserError{Err: "only permitted_dns_domains name constraints are supported by this build of Vault, which requires Go 1.10 or later for the others"}
+	}
+	return nil
+}
+
+func setNameConstraints(constraints *caConstraints, certTemplate *x509.Certificate) {
+	certTemplate.PermittedDNSDomains = constraints.PermittedDNSDomains
+}
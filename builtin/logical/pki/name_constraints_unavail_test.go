@@ -0,0 +1,62 @@
+// +build !go1.10
+
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_CAConstraints(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	// Only the permitted DNS domains can be encoded before Go 1.10
+	for _, data := range []map[string]interface{}{
+		{"excluded_dns_domains": "secret.example.com"},
+		{"permitted_ip_ranges": "10.0.0.0/8"},
+		{"excluded_email_addresses": "example.com"},
+	} {
+		data["common_name"] = "Vault Constrained Root"
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "root/generate/internal",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %#v, got: %v, %#v", data, err, resp)
+		}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/internal",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name":           "Vault Constrained Root",
+			"permitted_dns_domains": "example.com",
+			"policy_identifiers":    "1.3.6.1.4.1.44947.1.1.1",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	if block == nil {
+		t.Fatalf("bad certificate: %#v", resp.Data)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cert.PermittedDNSDomains, []string{"example.com"}) || !cert.PermittedDNSDomainsCritical {
+		t.Fatalf("bad: root name constraints: %#v", cert.PermittedDNSDomains)
+	}
+	if len(cert.PolicyIdentifiers) != 1 || cert.PolicyIdentifiers[0].String() != "1.3.6.1.4.1.44947.1.1.1" {
+		t.Fatalf("bad: root policies: %v", cert.PolicyIdentifiers)
+	}
+}
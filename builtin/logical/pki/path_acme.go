@@ -0,0 +1,1296 @@
+package pki
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	acmeErrorPrefix   = "urn:ietf:params:acme:error:"
+	acmeNonceLifetime = 15 * time.Minute
+	acmeMaxNonces     = 10000
+	acmeOrderLifetime = 24 * time.Hour
+
+	acmeStatusPending     = "pending"
+	acmeStatusReady       = "ready"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+)
+
+// acmeError is an ACME problem document (RFC 7807) returned to the client
+type acmeError struct {
+	Status int
+	Type   string
+	Detail string
+}
+
+func (e *acmeError) Error() string {
+	return e.Detail
+}
+
+func newACMEError(status int, errType string, format string, args ...interface{}) *acmeError {
+	return &acmeError{
+		Status: status,
+		Type:   acmeErrorPrefix + errType,
+		Detail: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *acmeError) response() *logical.Response {
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":   e.Type,
+		"detail": e.Detail,
+		"status": e.Status,
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/problem+json",
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  e.Status,
+			logical.HTTPRawHeaders:  map[string][]string{},
+		},
+	}
+}
+
+type acmeAccount struct {
+	ID        string    `json:"id"`
+	Key       *acmeJWK  `json:"key"`
+	Status    string    `json:"status"`
+	Contact   []string  `json:"contact"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeOrder struct {
+	ID                string           `json:"id"`
+	AccountID         string           `json:"account_id"`
+	Role              string           `json:"role"`
+	Status            string           `json:"status"`
+	Expires           time.Time        `json:"expires"`
+	Identifiers       []acmeIdentifier `json:"identifiers"`
+	AuthorizationIDs  []string         `json:"authorization_ids"`
+	CertificateSerial string           `json:"certificate_serial"`
+	CertificateChain  string           `json:"certificate_chain"`
+}
+
+type acmeChallenge struct {
+	Type      string    `json:"type"`
+	Token     string    `json:"token"`
+	Status    string    `json:"status"`
+	Validated time.Time `json:"validated"`
+	Error     string    `json:"error"`
+}
+
+type acmeAuthorization struct {
+	ID         string           `json:"id"`
+	AccountID  string           `json:"account_id"`
+	Role       string           `json:"role"`
+	Identifier acmeIdentifier   `json:"identifier"`
+	Wildcard   bool             `json:"wildcard"`
+	Status     string           `json:"status"`
+	Expires    time.Time        `json:"expires"`
+	Challenges []*acmeChallenge `json:"challenges"`
+}
+
+// acmeContext holds the configuration that applies to a request to one of
+// the ACME directories of the backend
+type acmeContext struct {
+	config   *acmeConfig
+	roleName string
+	role     *roleEntry
+
+	// prefix is the URL of the directory, with a trailing slash
+	prefix string
+}
+
+// acmeHandler processes a signed ACME request. The account is nil for
+// requests signed with a JWK rather than an account URL, i.e. new-account.
+type acmeHandler func(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error)
+
+// acmePattern returns the pattern of an ACME path, matching both the
+// default directory and the directories of the roles
+func acmePattern(suffix string) string {
+	return "acme/(roles/" + framework.GenericNameRegex("role") + "/)?" + suffix
+}
+
+func addACMEFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["role"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The role of the ACME directory; the default role if empty`,
+	}
+
+	fields["protected"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The protected header of the JWS`,
+	}
+
+	fields["payload"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The payload of the JWS`,
+	}
+
+	fields["signature"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The signature of the JWS`,
+	}
+
+	return fields
+}
+
+func acmeIDField(fields map[string]*framework.FieldSchema, name string) map[string]*framework.FieldSchema {
+	fields[name] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Identifier of the ACME object`,
+	}
+	return fields
+}
+
+func pathACMEDirectory(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("directory"),
+		Fields:  addACMEFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.acmeOperation(b.pathACMEDirectoryRead),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewNonce(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("new-nonce"),
+		Fields:  addACMEFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.acmeOperation(b.pathACMENewNonceRead),
+			logical.HeadOperation: b.acmeOperation(b.pathACMENewNonceRead),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("new-account"),
+		Fields:  addACMEFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(true, b.pathACMENewAccount),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("account/" + framework.GenericNameRegex("id")),
+		Fields:  acmeIDField(addACMEFields(map[string]*framework.FieldSchema{}), "id"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMEAccountUpdate),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("new-order"),
+		Fields:  addACMEFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMENewOrder),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("order/" + framework.GenericNameRegex("id")),
+		Fields:  acmeIDField(addACMEFields(map[string]*framework.FieldSchema{}), "id"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMEOrderRead),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEOrderFinalize(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("order/" + framework.GenericNameRegex("id") + "/finalize"),
+		Fields:  acmeIDField(addACMEFields(map[string]*framework.FieldSchema{}), "id"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMEOrderFinalize),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAuthorization(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("authz/" + framework.GenericNameRegex("id")),
+		Fields:  acmeIDField(addACMEFields(map[string]*framework.FieldSchema{}), "id"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMEAuthorizationRead),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEChallenge(b *backend) *framework.Path {
+	fields := acmeIDField(addACMEFields(map[string]*framework.FieldSchema{}), "id")
+	fields["type"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Type of the challenge`,
+	}
+
+	return &framework.Path{
+		Pattern: acmePattern("challenge/" + framework.GenericNameRegex("id") + "/" + framework.GenericNameRegex("type")),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMEChallengeUpdate),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMECertificate(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("cert/" + framework.GenericNameRegex("id")),
+		Fields:  acmeIDField(addACMEFields(map[string]*framework.FieldSchema{}), "id"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMECertificateRead),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMERevokeCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("revoke-cert"),
+		Fields:  addACMEFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeSignedOperation(false, b.pathACMERevokeCert),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+// acmeJSONResponse returns a raw response with the given status and body
+// encoded as JSON
+func acmeJSONResponse(status int, body interface{}) (*logical.Response, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     raw,
+			logical.HTTPStatusCode:  status,
+			logical.HTTPRawHeaders:  map[string][]string{},
+		},
+	}, nil
+}
+
+func acmeAddHeader(resp *logical.Response, name, value string) {
+	headers, ok := resp.Data[logical.HTTPRawHeaders].(map[string][]string)
+	if !ok {
+		headers = map[string][]string{}
+		resp.Data[logical.HTTPRawHeaders] = headers
+	}
+	headers[name] = append(headers[name], value)
+}
+
+// acmeRandom returns a random URL-safe string, used for nonces and
+// challenge tokens
+func acmeRandom() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (b *backend) newACMENonce() (string, error) {
+	nonce, err := acmeRandom()
+	if err != nil {
+		return "", err
+	}
+
+	b.acmeNoncesLock.Lock()
+	defer b.acmeNoncesLock.Unlock()
+
+	// The nonces all have the same lifetime, so the queue is ordered by
+	// expiration: drop the expired nonces from its head, and the oldest ones
+	// once the store is full, as new-nonce can be called anonymously
+	now := time.Now()
+	for len(b.acmeNonceQueue) > 0 {
+		oldest := b.acmeNonceQueue[0]
+		if len(b.acmeNonceQueue) < acmeMaxNonces && now.Before(b.acmeNonces[oldest]) {
+			break
+		}
+		delete(b.acmeNonces, oldest)
+		b.acmeNonceQueue = b.acmeNonceQueue[1:]
+	}
+	b.acmeNonces[nonce] = now.Add(acmeNonceLifetime)
+	b.acmeNonceQueue = append(b.acmeNonceQueue, nonce)
+
+	return nonce, nil
+}
+
+// consumeACMENonce returns true if the nonce was issued by this backend and
+// has not been used yet
+func (b *backend) consumeACMENonce(nonce string) bool {
+	b.acmeNoncesLock.Lock()
+	defer b.acmeNoncesLock.Unlock()
+
+	expires, ok := b.acmeNonces[nonce]
+	if !ok {
+		return false
+	}
+	delete(b.acmeNonces, nonce)
+
+	return time.Now().Before(expires)
+}
+
+func (b *backend) acmeContext(req *logical.Request, data *framework.FieldData) (*acmeContext, error) {
+	config, err := b.ACMEConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil || !config.Enabled {
+		return nil, newACMEError(http.StatusForbidden, "unauthorized", "ACME is not enabled on this backend")
+	}
+
+	roleName := data.Get("role").(string)
+	pathPrefix := "acme/"
+	if roleName == "" {
+		roleName = config.DefaultRole
+		if roleName == "" {
+			return nil, newACMEError(http.StatusNotFound, "malformed", "no default role is configured for ACME")
+		}
+	} else {
+		if !config.roleAllowed(roleName) {
+			return nil, newACMEError(http.StatusForbidden, "unauthorized", "role %q is not allowed for ACME", roleName)
+		}
+		pathPrefix = "acme/roles/" + roleName + "/"
+	}
+
+	role, err := b.getRole(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, newACMEError(http.StatusNotFound, "malformed", "unknown role %q", roleName)
+	}
+
+	return &acmeContext{
+		config:   config,
+		roleName: roleName,
+		role:     role,
+		prefix:   config.BaseURL + "/" + pathPrefix,
+	}, nil
+}
+
+// acmeFinish converts ACME errors to problem documents and adds the headers
+// that every ACME response carries
+func (b *backend) acmeFinish(ctx *acmeContext, resp *logical.Response, err error) (*logical.Response, error) {
+	if acmeErr, ok := err.(*acmeError); ok {
+		resp, err = acmeErr.response(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := b.newACMENonce()
+	if err != nil {
+		return nil, err
+	}
+	acmeAddHeader(resp, "Replay-Nonce", nonce)
+
+	if ctx != nil {
+		acmeAddHeader(resp, "Link", fmt.Sprintf(`<%sdirectory>;rel="index"`, ctx.prefix))
+	}
+
+	return resp, nil
+}
+
+// acmeOperation wraps the handler of an ACME request that is not signed
+func (b *backend) acmeOperation(handler func(*logical.Request, *framework.FieldData, *acmeContext) (*logical.Response, error)) framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		ctx, err := b.acmeContext(req, data)
+		if err != nil {
+			return b.acmeFinish(nil, nil, err)
+		}
+
+		resp, err := handler(req, data, ctx)
+		return b.acmeFinish(ctx, resp, err)
+	}
+}
+
+// acmeSignedOperation wraps the handler of an ACME request that must be
+// signed, either with a JWK when useJWK is true or with the key of an
+// existing account otherwise
+func (b *backend) acmeSignedOperation(useJWK bool, handler acmeHandler) framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		ctx, err := b.acmeContext(req, data)
+		if err != nil {
+			return b.acmeFinish(nil, nil, err)
+		}
+
+		jws, account, err := b.verifyACMERequest(req, ctx, useJWK)
+		if err != nil {
+			return b.acmeFinish(ctx, nil, err)
+		}
+
+		resp, err := handler(req, data, ctx, jws, account)
+		return b.acmeFinish(ctx, resp, err)
+	}
+}
+
+func (b *backend) verifyACMERequest(req *logical.Request, ctx *acmeContext, useJWK bool) (*acmeJWS, *acmeAccount, error) {
+	jws, err := parseACMEJWS(req.Data)
+	if err != nil {
+		return nil, nil, newACMEError(http.StatusBadRequest, "malformed", "%s", err.Error())
+	}
+
+	if !b.consumeACMENonce(jws.Header.Nonce) {
+		return nil, nil, newACMEError(http.StatusBadRequest, "badNonce", "invalid or expired nonce")
+	}
+
+	if expected := ctx.config.BaseURL + "/" + req.Path; jws.Header.URL != expected {
+		return nil, nil, newACMEError(http.StatusUnauthorized, "unauthorized", "the signed URL %q does not match the request URL %q", jws.Header.URL, expected)
+	}
+
+	if useJWK {
+		if jws.Header.JWK == nil || jws.Header.KID != "" {
+			return nil, nil, newACMEError(http.StatusBadRequest, "malformed", "request must be signed with a JWK")
+		}
+
+		key, err := jws.Header.JWK.publicKey()
+		if err != nil {
+			return nil, nil, newACMEError(http.StatusBadRequest, "badPublicKey", "%s", err.Error())
+		}
+		if err := jws.verify(key); err != nil {
+			return nil, nil, newACMEError(http.StatusBadRequest, "malformed", "%s", err.Error())
+		}
+
+		return jws, nil, nil
+	}
+
+	if jws.Header.KID == "" || jws.Header.JWK != nil {
+		return nil, nil, newACMEError(http.StatusBadRequest, "malformed", "request must be signed with an account key ID")
+	}
+
+	accountPrefix := ctx.prefix + "account/"
+	if !strings.HasPrefix(jws.Header.KID, accountPrefix) {
+		return nil, nil, newACMEError(http.StatusBadRequest, "accountDoesNotExist", "unknown account %q", jws.Header.KID)
+	}
+
+	account := &acmeAccount{}
+	found, err := getACMEEntry(req.Storage, "acme/accounts/"+strings.TrimPrefix(jws.Header.KID, accountPrefix), account)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, newACMEError(http.StatusBadRequest, "accountDoesNotExist", "unknown account %q", jws.Header.KID)
+	}
+	if account.Status != acmeStatusValid {
+		return nil, nil, newACMEError(http.StatusUnauthorized, "unauthorized", "account is %s", account.Status)
+	}
+
+	key, err := account.Key.publicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := jws.verify(key); err != nil {
+		return nil, nil, newACMEError(http.StatusBadRequest, "malformed", "%s", err.Error())
+	}
+
+	return jws, account, nil
+}
+
+func getACMEEntry(s logical.Storage, path string, out interface{}) (bool, error) {
+	entry, err := s.Get(path)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+
+	if err := entry.DecodeJSON(out); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func putACMEEntry(s logical.Storage, path string, in interface{}) error {
+	entry, err := logical.StorageEntryJSON(path, in)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(entry)
+}
+
+// decodeACMEPayload decodes the JSON payload of a request. An empty payload
+// is a POST-as-GET request and leaves out untouched.
+func decodeACMEPayload(jws *acmeJWS, out interface{}) error {
+	if len(jws.Payload) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(jws.Payload, out); err != nil {
+		return newACMEError(http.StatusBadRequest, "malformed", "invalid payload: %v", err)
+	}
+
+	return nil
+}
+
+func (a *acmeAccount) toJSON() map[string]interface{} {
+	contact := a.Contact
+	if contact == nil {
+		contact = []string{}
+	}
+
+	return map[string]interface{}{
+		"status":  a.Status,
+		"contact": contact,
+	}
+}
+
+func (o *acmeOrder) toJSON(prefix string) map[string]interface{} {
+	authorizations := make([]string, 0, len(o.AuthorizationIDs))
+	for _, id := range o.AuthorizationIDs {
+		authorizations = append(authorizations, prefix+"authz/"+id)
+	}
+
+	ret := map[string]interface{}{
+		"status":         o.Status,
+		"expires":        o.Expires.Format(time.RFC3339),
+		"identifiers":    o.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       prefix + "order/" + o.ID + "/finalize",
+	}
+	if o.CertificateSerial != "" {
+		ret["certificate"] = prefix + "cert/" + o.ID
+	}
+
+	return ret
+}
+
+func (c *acmeChallenge) toJSON(prefix, authzID string) map[string]interface{} {
+	ret := map[string]interface{}{
+		"type":   c.Type,
+		"url":    prefix + "challenge/" + authzID + "/" + c.Type,
+		"token":  c.Token,
+		"status": c.Status,
+	}
+	if !c.Validated.IsZero() {
+		ret["validated"] = c.Validated.Format(time.RFC3339)
+	}
+	if c.Error != "" {
+		ret["error"] = map[string]interface{}{
+			"type":   acmeErrorPrefix + "incorrectResponse",
+			"detail": c.Error,
+		}
+	}
+
+	return ret
+}
+
+func (a *acmeAuthorization) toJSON(prefix string) map[string]interface{} {
+	challenges := make([]map[string]interface{}, 0, len(a.Challenges))
+	for _, challenge := range a.Challenges {
+		challenges = append(challenges, challenge.toJSON(prefix, a.ID))
+	}
+
+	ret := map[string]interface{}{
+		"identifier": a.Identifier,
+		"status":     a.Status,
+		"expires":    a.Expires.Format(time.RFC3339),
+		"challenges": challenges,
+	}
+	if a.Wildcard {
+		ret["wildcard"] = true
+	}
+
+	return ret
+}
+
+func (b *backend) pathACMEDirectoryRead(req *logical.Request, data *framework.FieldData, ctx *acmeContext) (*logical.Response, error) {
+	return acmeJSONResponse(http.StatusOK, map[string]interface{}{
+		"newNonce":   ctx.prefix + "new-nonce",
+		"newAccount": ctx.prefix + "new-account",
+		"newOrder":   ctx.prefix + "new-order",
+		"revokeCert": ctx.prefix + "revoke-cert",
+		"meta": map[string]interface{}{
+			"externalAccountRequired": false,
+		},
+	})
+}
+
+func (b *backend) pathACMENewNonceRead(req *logical.Request, data *framework.FieldData, ctx *acmeContext) (*logical.Response, error) {
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode: http.StatusNoContent,
+			logical.HTTPRawHeaders: map[string][]string{
+				"Cache-Control": []string{"no-store"},
+			},
+		},
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathACMENewAccount(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, _ *acmeAccount) (*logical.Response, error) {
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := decodeACMEPayload(jws, &payload); err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	thumbprint := jws.Header.JWK.thumbprint()
+	var accountID string
+	found, err := getACMEEntry(req.Storage, "acme/account-keys/"+thumbprint, &accountID)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		account := &acmeAccount{}
+		if _, err := getACMEEntry(req.Storage, "acme/accounts/"+accountID, account); err != nil {
+			return nil, err
+		}
+
+		resp, err := acmeJSONResponse(http.StatusOK, account.toJSON())
+		if err != nil {
+			return nil, err
+		}
+		acmeAddHeader(resp, "Location", ctx.prefix+"account/"+accountID)
+		return resp, nil
+	}
+
+	if payload.OnlyReturnExisting {
+		return nil, newACMEError(http.StatusBadRequest, "accountDoesNotExist", "no account exists for this key")
+	}
+
+	for _, contact := range payload.Contact {
+		if !strings.HasPrefix(contact, "mailto:") {
+			return nil, newACMEError(http.StatusBadRequest, "unsupportedContact", "unsupported contact %q, only mailto: contacts are supported", contact)
+		}
+	}
+
+	accountID, err = uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	account := &acmeAccount{
+		ID:        accountID,
+		Key:       jws.Header.JWK,
+		Status:    acmeStatusValid,
+		Contact:   payload.Contact,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := putACMEEntry(req.Storage, "acme/accounts/"+accountID, account); err != nil {
+		return nil, err
+	}
+	if err := putACMEEntry(req.Storage, "acme/account-keys/"+thumbprint, accountID); err != nil {
+		return nil, err
+	}
+
+	resp, err := acmeJSONResponse(http.StatusCreated, account.toJSON())
+	if err != nil {
+		return nil, err
+	}
+	acmeAddHeader(resp, "Location", ctx.prefix+"account/"+accountID)
+	return resp, nil
+}
+
+func (b *backend) pathACMEAccountUpdate(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	if data.Get("id").(string) != account.ID {
+		return nil, newACMEError(http.StatusUnauthorized, "unauthorized", "the request is not signed by this account")
+	}
+
+	var payload struct {
+		Contact []string `json:"contact"`
+		Status  string   `json:"status"`
+	}
+	if err := decodeACMEPayload(jws, &payload); err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	modified := false
+	if payload.Contact != nil {
+		for _, contact := range payload.Contact {
+			if !strings.HasPrefix(contact, "mailto:") {
+				return nil, newACMEError(http.StatusBadRequest, "unsupportedContact", "unsupported contact %q, only mailto: contacts are supported", contact)
+			}
+		}
+		account.Contact = payload.Contact
+		modified = true
+	}
+
+	switch payload.Status {
+	case "":
+	case acmeStatusDeactivated:
+		account.Status = acmeStatusDeactivated
+		modified = true
+	default:
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "invalid status %q", payload.Status)
+	}
+
+	if modified {
+		if err := putACMEEntry(req.Storage, "acme/accounts/"+account.ID, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return acmeJSONResponse(http.StatusOK, account.toJSON())
+}
+
+func (b *backend) pathACMENewOrder(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := decodeACMEPayload(jws, &payload); err != nil {
+		return nil, err
+	}
+
+	if len(payload.Identifiers) == 0 {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "no identifiers given")
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "notBefore and notAfter are not supported; the validity is set by the role")
+	}
+
+	var names []string
+	for i, identifier := range payload.Identifiers {
+		if identifier.Type != "dns" {
+			return nil, newACMEError(http.StatusBadRequest, "unsupportedIdentifier", "unsupported identifier type %q", identifier.Type)
+		}
+		payload.Identifiers[i].Value = strings.ToLower(identifier.Value)
+		names = append(names, payload.Identifiers[i].Value)
+	}
+
+	badName, err := validateNames(req, names, ctx.role)
+	if err != nil {
+		return nil, err
+	}
+	if badName != "" {
+		return nil, newACMEError(http.StatusBadRequest, "rejectedIdentifier", "name %q is not allowed by role %q", badName, ctx.roleName)
+	}
+
+	orderID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	order := &acmeOrder{
+		ID:          orderID,
+		AccountID:   account.ID,
+		Role:        ctx.roleName,
+		Status:      acmeStatusPending,
+		Expires:     time.Now().Add(acmeOrderLifetime).UTC(),
+		Identifiers: payload.Identifiers,
+	}
+
+	for _, identifier := range payload.Identifiers {
+		authz, err := newACMEAuthorization(account, order.Role, identifier, order.Expires)
+		if err != nil {
+			return nil, err
+		}
+		if err := putACMEEntry(req.Storage, "acme/authorizations/"+authz.ID, authz); err != nil {
+			return nil, err
+		}
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authz.ID)
+	}
+
+	if err := putACMEEntry(req.Storage, "acme/orders/"+order.ID, order); err != nil {
+		return nil, err
+	}
+
+	resp, err := acmeJSONResponse(http.StatusCreated, order.toJSON(ctx.prefix))
+	if err != nil {
+		return nil, err
+	}
+	acmeAddHeader(resp, "Location", ctx.prefix+"order/"+order.ID)
+	return resp, nil
+}
+
+// newACMEAuthorization creates the authorization of an identifier of an
+// order, offering the http-01 and dns-01 challenges. Wildcard names can
+// only be validated with dns-01.
+func newACMEAuthorization(account *acmeAccount, role string, identifier acmeIdentifier, expires time.Time) (*acmeAuthorization, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	authz := &acmeAuthorization{
+		ID:         id,
+		AccountID:  account.ID,
+		Role:       role,
+		Identifier: identifier,
+		Status:     acmeStatusPending,
+		Expires:    expires,
+	}
+
+	challengeTypes := []string{"http-01", "dns-01"}
+	if strings.HasPrefix(identifier.Value, "*.") {
+		authz.Identifier.Value = strings.TrimPrefix(identifier.Value, "*.")
+		authz.Wildcard = true
+		challengeTypes = []string{"dns-01"}
+	}
+
+	for _, challengeType := range challengeTypes {
+		token, err := acmeRandom()
+		if err != nil {
+			return nil, err
+		}
+		authz.Challenges = append(authz.Challenges, &acmeChallenge{
+			Type:   challengeType,
+			Token:  token,
+			Status: acmeStatusPending,
+		})
+	}
+
+	return authz, nil
+}
+
+// getACMEOrder fetches an order of the given account placed in the
+// directory of the given role and brings its status up to date with the
+// state of its authorizations
+func (b *backend) getACMEOrder(s logical.Storage, id string, account *acmeAccount, role string) (*acmeOrder, error) {
+	order := &acmeOrder{}
+	found, err := getACMEEntry(s, "acme/orders/"+id, order)
+	if err != nil {
+		return nil, err
+	}
+	if !found || order.AccountID != account.ID || order.Role != role {
+		return nil, newACMEError(http.StatusNotFound, "malformed", "order not found")
+	}
+
+	if order.Status != acmeStatusPending {
+		return order, nil
+	}
+
+	status := acmeStatusReady
+	if time.Now().After(order.Expires) {
+		status = acmeStatusInvalid
+	}
+	for _, authzID := range order.AuthorizationIDs {
+		if status == acmeStatusInvalid {
+			break
+		}
+
+		authz := &acmeAuthorization{}
+		if _, err := getACMEEntry(s, "acme/authorizations/"+authzID, authz); err != nil {
+			return nil, err
+		}
+
+		switch authz.Status {
+		case acmeStatusValid:
+		case acmeStatusPending:
+			status = acmeStatusPending
+		default:
+			status = acmeStatusInvalid
+		}
+	}
+
+	if status != order.Status {
+		order.Status = status
+		if err := putACMEEntry(s, "acme/orders/"+order.ID, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func (b *backend) pathACMEOrderRead(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	order, err := b.getACMEOrder(req.Storage, data.Get("id").(string), account, ctx.roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	return acmeJSONResponse(http.StatusOK, order.toJSON(ctx.prefix))
+}
+
+func (b *backend) pathACMEOrderFinalize(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := decodeACMEPayload(jws, &payload); err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	order, err := b.getACMEOrder(req.Storage, data.Get("id").(string), account, ctx.roleName)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != acmeStatusReady {
+		return nil, newACMEError(http.StatusForbidden, "orderNotReady", "order is %s", order.Status)
+	}
+
+	csrBytes, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "invalid CSR encoding: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "invalid CSR: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "invalid CSR signature: %v", err)
+	}
+
+	// The CSR must request exactly the names of the order
+	var orderNames []string
+	for _, identifier := range order.Identifiers {
+		orderNames = append(orderNames, identifier.Value)
+	}
+	csrNames := csr.DNSNames
+	if csr.Subject.CommonName != "" {
+		csrNames = append(csrNames, csr.Subject.CommonName)
+	}
+	if !strutil.EquivalentSlices(strutil.RemoveDuplicates(csrNames), orderNames) ||
+		len(csr.IPAddresses) != 0 || len(csr.EmailAddresses) != 0 {
+		return nil, newACMEError(http.StatusBadRequest, "badCSR", "the CSR must request exactly the names of the order")
+	}
+
+	commonName := csr.Subject.CommonName
+	if commonName == "" {
+		commonName = orderNames[0]
+	}
+
+	signingBundle, err := fetchCAInfoByRef(req, ctx.role.IssuerRef)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := addNonCACommonFields(map[string]*framework.FieldSchema{})
+	fields["csr"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+	}
+	signData := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csrBytes,
+			})),
+			"common_name": commonName,
+			"alt_names":   strings.Join(strutil.StrListDelete(orderNames, commonName), ","),
+		},
+		Schema: fields,
+	}
+
+	parsedBundle, err := signCert(b, ctx.role, signingBundle, false, false, req, signData)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return nil, newACMEError(http.StatusBadRequest, "badCSR", "%s", err.Error())
+		default:
+			return nil, err
+		}
+	}
+
+	cb, err := parsedBundle.ToCertBundle()
+	if err != nil {
+		return nil, fmt.Errorf("Error converting raw cert bundle to cert bundle: %s", err)
+	}
+
+	err = req.Storage.Put(&logical.StorageEntry{
+		Key:   "certs/" + cb.SerialNumber,
+		Value: parsedBundle.CertificateBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to store certificate locally: %v", err)
+	}
+	if err := putACMEEntry(req.Storage, "acme/certs/"+cb.SerialNumber, order.ID); err != nil {
+		return nil, err
+	}
+
+	chain := []string{cb.Certificate}
+	chain = append(chain, cb.CAChain...)
+
+	order.Status = acmeStatusValid
+	order.CertificateSerial = cb.SerialNumber
+	order.CertificateChain = strings.Join(chain, "\n") + "\n"
+	if err := putACMEEntry(req.Storage, "acme/orders/"+order.ID, order); err != nil {
+		return nil, err
+	}
+
+	resp, err := acmeJSONResponse(http.StatusOK, order.toJSON(ctx.prefix))
+	if err != nil {
+		return nil, err
+	}
+	acmeAddHeader(resp, "Location", ctx.prefix+"order/"+order.ID)
+	return resp, nil
+}
+
+func (b *backend) getACMEAuthorization(s logical.Storage, id string, account *acmeAccount, role string) (*acmeAuthorization, error) {
+	authz := &acmeAuthorization{}
+	found, err := getACMEEntry(s, "acme/authorizations/"+id, authz)
+	if err != nil {
+		return nil, err
+	}
+	if !found || authz.AccountID != account.ID || authz.Role != role {
+		return nil, newACMEError(http.StatusNotFound, "malformed", "authorization not found")
+	}
+
+	if authz.Status == acmeStatusPending && time.Now().After(authz.Expires) {
+		authz.Status = acmeStatusInvalid
+		if err := putACMEEntry(s, "acme/authorizations/"+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return authz, nil
+}
+
+func (b *backend) pathACMEAuthorizationRead(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	authz, err := b.getACMEAuthorization(req.Storage, data.Get("id").(string), account, ctx.roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	return acmeJSONResponse(http.StatusOK, authz.toJSON(ctx.prefix))
+}
+
+func (b *backend) pathACMEChallengeUpdate(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	authzID := data.Get("id").(string)
+	challengeType := data.Get("type").(string)
+
+	findChallenge := func(authz *acmeAuthorization) (*acmeChallenge, error) {
+		for _, challenge := range authz.Challenges {
+			if challenge.Type == challengeType {
+				return challenge, nil
+			}
+		}
+		return nil, newACMEError(http.StatusNotFound, "malformed", "challenge not found")
+	}
+
+	b.acmeLock.Lock()
+	authz, err := b.getACMEAuthorization(req.Storage, authzID, account, ctx.roleName)
+	b.acmeLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := findChallenge(authz)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the challenge without holding the lock, as it involves
+	// reaching out to the client
+	if authz.Status == acmeStatusPending && challenge.Status == acmeStatusPending {
+		keyAuthorization := challenge.Token + "." + account.Key.thumbprint()
+		validationErr := b.validateACMEChallenge(challenge.Type, authz.Identifier.Value, challenge.Token, keyAuthorization)
+
+		b.acmeLock.Lock()
+		defer b.acmeLock.Unlock()
+
+		authz, err = b.getACMEAuthorization(req.Storage, authzID, account, ctx.roleName)
+		if err != nil {
+			return nil, err
+		}
+		challenge, err = findChallenge(authz)
+		if err != nil {
+			return nil, err
+		}
+
+		if authz.Status == acmeStatusPending && challenge.Status == acmeStatusPending {
+			if validationErr != nil {
+				challenge.Status = acmeStatusInvalid
+				challenge.Error = validationErr.Error()
+				authz.Status = acmeStatusInvalid
+			} else {
+				challenge.Status = acmeStatusValid
+				challenge.Validated = time.Now().UTC()
+				authz.Status = acmeStatusValid
+			}
+
+			if err := putACMEEntry(req.Storage, "acme/authorizations/"+authz.ID, authz); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	resp, err := acmeJSONResponse(http.StatusOK, challenge.toJSON(ctx.prefix, authz.ID))
+	if err != nil {
+		return nil, err
+	}
+	acmeAddHeader(resp, "Link", fmt.Sprintf(`<%sauthz/%s>;rel="up"`, ctx.prefix, authz.ID))
+	return resp, nil
+}
+
+func (b *backend) pathACMECertificateRead(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	order, err := b.getACMEOrder(req.Storage, data.Get("id").(string), account, ctx.roleName)
+	if err != nil {
+		return nil, err
+	}
+	if order.CertificateChain == "" {
+		return nil, newACMEError(http.StatusNotFound, "malformed", "certificate not found")
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/pem-certificate-chain",
+			logical.HTTPRawBody:     []byte(order.CertificateChain),
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawHeaders:  map[string][]string{},
+		},
+	}, nil
+}
+
+func (b *backend) pathACMERevokeCert(req *logical.Request, data *framework.FieldData, ctx *acmeContext, jws *acmeJWS, account *acmeAccount) (*logical.Response, error) {
+	var payload struct {
+		Certificate string `json:"certificate"`
+		Reason      int    `json:"reason"`
+	}
+	if err := decodeACMEPayload(jws, &payload); err != nil {
+		return nil, err
+	}
+
+	certBytes, err := base64.RawURLEncoding.DecodeString(payload.Certificate)
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "invalid certificate encoding: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "invalid certificate: %v", err)
+	}
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	// Only the account that ordered a certificate can revoke it
+	var orderID string
+	found, err := getACMEEntry(req.Storage, "acme/certs/"+serial, &orderID)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		order := &acmeOrder{}
+		found, err = getACMEEntry(req.Storage, "acme/orders/"+orderID, order)
+		if err != nil {
+			return nil, err
+		}
+		found = found && order.AccountID == account.ID
+	}
+	if !found {
+		return nil, newACMEError(http.StatusForbidden, "unauthorized", "the certificate was not issued to this account")
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	resp, err := revokeCert(b, req, serial, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil && resp.IsError() {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "%s", resp.Data["error"].(string))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     []byte{},
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawHeaders:  map[string][]string{},
+		},
+	}, nil
+}
+
+const pathACMEHelpSyn = `
+ACME (RFC 8555) server endpoints.
+`
+
+const pathACMEHelpDesc = `
+These endpoints implement an ACME server so that standard ACME clients can
+request certificates from this backend without a Vault token. They must be
+enabled with "config/acme". Clients use the directory at "acme/directory",
+which issues certificates according to the default role, or the directory of
+an allowed role at "acme/roles/<role>/directory".
+
+Before a certificate is issued, the client must prove control of each
+requested name with an http-01 or dns-01 challenge, and the names must be
+allowed by the role. Wildcard names can only be validated with dns-01.
+`
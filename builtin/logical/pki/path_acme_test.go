@@ -0,0 +1,369 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const acmeTestBaseURL = "https://vault.example.com/v1/pki"
+
+// acmeTestClient is a minimal ACME client signing its requests with an
+// ECDSA P-256 account key
+type acmeTestClient struct {
+	t       *testing.T
+	b       *backend
+	storage logical.Storage
+	key     *ecdsa.PrivateKey
+	kid     string
+	nonce   string
+}
+
+func (c *acmeTestClient) jwk() *acmeJWK {
+	x, y := make([]byte, 32), make([]byte, 32)
+	xBytes, yBytes := c.key.X.Bytes(), c.key.Y.Bytes()
+	copy(x[32-len(xBytes):], xBytes)
+	copy(y[32-len(yBytes):], yBytes)
+
+	return &acmeJWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(x),
+		Y:   base64.RawURLEncoding.EncodeToString(y),
+	}
+}
+
+func (c *acmeTestClient) sign(path string, payload interface{}) map[string]interface{} {
+	header := map[string]interface{}{
+		"alg":   "ES256",
+		"nonce": c.nonce,
+		"url":   acmeTestBaseURL + "/" + path,
+	}
+	if c.kid != "" {
+		header["kid"] = c.kid
+	} else {
+		header["jwk"] = c.jwk()
+	}
+
+	headerBytes, _ := json.Marshal(header)
+	var payloadBytes []byte
+	if payload != nil {
+		payloadBytes, _ = json.Marshal(payload)
+	}
+
+	protected := base64.RawURLEncoding.EncodeToString(headerBytes)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+
+	digest := sha256.Sum256([]byte(protected + "." + encodedPayload))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rBytes):32], rBytes)
+	copy(signature[64-len(sBytes):], sBytes)
+
+	return map[string]interface{}{
+		"protected": protected,
+		"payload":   encodedPayload,
+		"signature": base64.RawURLEncoding.EncodeToString(signature),
+	}
+}
+
+func (c *acmeTestClient) request(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	resp, err := c.b.HandleRequest(&logical.Request{
+		Operation: op,
+		Path:      path,
+		Storage:   c.storage,
+		Data:      data,
+	})
+	if err != nil {
+		c.t.Fatalf("path %s: err: %v", path, err)
+	}
+	if resp == nil {
+		c.t.Fatalf("path %s: nil response", path)
+	}
+
+	headers := resp.Data[logical.HTTPRawHeaders].(map[string][]string)
+	if nonces := headers["Replay-Nonce"]; len(nonces) == 1 {
+		c.nonce = nonces[0]
+	} else {
+		c.t.Fatalf("path %s: missing Replay-Nonce header", path)
+	}
+
+	return resp
+}
+
+// post sends a signed request and decodes the JSON response body, failing
+// the test if the status differs from the expected one
+func (c *acmeTestClient) post(path string, payload interface{}, status int) (map[string]interface{}, *logical.Response) {
+	resp := c.request(logical.UpdateOperation, path, c.sign(path, payload))
+
+	body := resp.Data[logical.HTTPRawBody].([]byte)
+	if resp.Data[logical.HTTPStatusCode].(int) != status {
+		c.t.Fatalf("path %s: expected status %d, got %d: %s", path, status, resp.Data[logical.HTTPStatusCode], body)
+	}
+
+	var result map[string]interface{}
+	if len(body) != 0 && resp.Data[logical.HTTPContentType] != "application/pem-certificate-chain" {
+		if err := json.Unmarshal(body, &result); err != nil {
+			c.t.Fatalf("path %s: %v", path, err)
+		}
+	}
+
+	return result, resp
+}
+
+func acmeTestPath(url string) string {
+	return strings.TrimPrefix(url, acmeTestBaseURL+"/")
+}
+
+func TestPki_ACMEIssueCertificate(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	for _, step := range []struct {
+		path string
+		data map[string]interface{}
+	}{
+		{"root/generate/internal", map[string]interface{}{
+			"common_name": "Vault ACME Test CA",
+			"ttl":         "24h",
+		}},
+		{"roles/web", map[string]interface{}{
+			"allowed_domains":  "example.com",
+			"allow_subdomains": true,
+			"key_type":         "ec",
+			"key_bits":         256,
+			"ttl":              "1h",
+		}},
+		{"roles/any", map[string]interface{}{
+			"allow_any_name": true,
+			"key_type":       "ec",
+			"key_bits":       256,
+			"ttl":            "1h",
+		}},
+		{"config/acme", map[string]interface{}{
+			"enabled":       true,
+			"base_url":      acmeTestBaseURL,
+			"default_role":  "web",
+			"allowed_roles": "any",
+		}},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      step.path,
+			Storage:   storage,
+			Data:      step.data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", step.path, err, resp)
+		}
+	}
+
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &acmeTestClient{
+		t:       t,
+		b:       b,
+		storage: storage,
+		key:     accountKey,
+	}
+
+	var directory struct {
+		NewNonce   string
+		NewAccount string
+		NewOrder   string
+		RevokeCert string
+	}
+	resp := client.request(logical.ReadOperation, "acme/directory", nil)
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &directory); err != nil {
+		t.Fatal(err)
+	}
+	if directory.NewAccount != acmeTestBaseURL+"/acme/new-account" {
+		t.Fatalf("bad: directory: %#v", directory)
+	}
+
+	// A nonce can only be used once
+	client.request(logical.HeadOperation, acmeTestPath(directory.NewNonce), nil)
+	nonce := client.nonce
+	client.post(acmeTestPath(directory.NewAccount), map[string]interface{}{
+		"termsOfServiceAgreed": true,
+		"contact":              []string{"mailto:admin@example.com"},
+	}, http.StatusCreated)
+	client.nonce = nonce
+	problem, _ := client.post(acmeTestPath(directory.NewAccount), map[string]interface{}{}, http.StatusBadRequest)
+	if problem["type"] != acmeErrorPrefix+"badNonce" {
+		t.Fatalf("bad: %#v", problem)
+	}
+
+	// Registering the same key again returns the existing account
+	_, resp = client.post(acmeTestPath(directory.NewAccount), map[string]interface{}{
+		"onlyReturnExisting": true,
+	}, http.StatusOK)
+	client.kid = resp.Data[logical.HTTPRawHeaders].(map[string][]string)["Location"][0]
+
+	// Names outside of the role are rejected
+	problem, _ = client.post(acmeTestPath(directory.NewOrder), map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "www.example.org"}},
+	}, http.StatusBadRequest)
+	if problem["type"] != acmeErrorPrefix+"rejectedIdentifier" {
+		t.Fatalf("bad: %#v", problem)
+	}
+
+	order, resp := client.post(acmeTestPath(directory.NewOrder), map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "www.example.com"}},
+	}, http.StatusCreated)
+	orderURL := resp.Data[logical.HTTPRawHeaders].(map[string][]string)["Location"][0]
+	if order["status"] != acmeStatusPending {
+		t.Fatalf("bad: order: %#v", order)
+	}
+
+	authz, _ := client.post(acmeTestPath(order["authorizations"].([]interface{})[0].(string)), nil, http.StatusOK)
+	var challenge map[string]interface{}
+	for _, c := range authz["challenges"].([]interface{}) {
+		if c.(map[string]interface{})["type"] == "http-01" {
+			challenge = c.(map[string]interface{})
+		}
+	}
+	if challenge == nil {
+		t.Fatalf("no http-01 challenge offered: %#v", authz)
+	}
+
+	// Serve the key authorization and route all validation requests to
+	// the test server
+	token := challenge["token"].(string)
+	keyAuthorization := token + "." + client.jwk().thumbprint()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" || r.URL.Path != "/.well-known/acme-challenge/"+token {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, keyAuthorization)
+	}))
+	defer server.Close()
+	b.acmeHTTPClient = &http.Client{
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+		},
+	}
+
+	challenge, _ = client.post(acmeTestPath(challenge["url"].(string)), map[string]interface{}{}, http.StatusOK)
+	if challenge["status"] != acmeStatusValid {
+		t.Fatalf("bad: challenge: %#v", challenge)
+	}
+
+	order, _ = client.post(acmeTestPath(orderURL), nil, http.StatusOK)
+	if order["status"] != acmeStatusReady {
+		t.Fatalf("bad: order: %#v", order)
+	}
+
+	// The order cannot be used through the directory of another role
+	kid := client.kid
+	client.kid = strings.Replace(kid, "/acme/", "/acme/roles/any/", 1)
+	problem, _ = client.post(strings.Replace(acmeTestPath(orderURL), "acme/", "acme/roles/any/", 1), nil, http.StatusNotFound)
+	if problem["type"] != acmeErrorPrefix+"malformed" {
+		t.Fatalf("bad: %#v", problem)
+	}
+	client.post(strings.Replace(acmeTestPath(order["finalize"].(string)), "acme/", "acme/roles/any/", 1), map[string]interface{}{}, http.StatusNotFound)
+	client.kid = kid
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "www.example.com"},
+		DNSNames: []string{"www.example.com"},
+	}, crypto.Signer(certKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, _ = client.post(acmeTestPath(order["finalize"].(string)), map[string]interface{}{
+		"csr": base64.RawURLEncoding.EncodeToString(csr),
+	}, http.StatusOK)
+	if order["status"] != acmeStatusValid {
+		t.Fatalf("bad: order: %#v", order)
+	}
+
+	_, resp = client.post(acmeTestPath(order["certificate"].(string)), nil, http.StatusOK)
+	block, _ := pem.Decode(resp.Data[logical.HTTPRawBody].([]byte))
+	if block == nil {
+		t.Fatalf("no certificate returned")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "www.example.com" || len(cert.DNSNames) != 1 || cert.DNSNames[0] != "www.example.com" {
+		t.Fatalf("bad: certificate subject: %v, DNS names: %v", cert.Subject, cert.DNSNames)
+	}
+
+	client.post(acmeTestPath(directory.RevokeCert), map[string]interface{}{
+		"certificate": base64.RawURLEncoding.EncodeToString(block.Bytes),
+	}, http.StatusOK)
+	revoked, err := fetchCertBySerial(&logical.Request{Storage: storage}, "revoked/", certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked == nil {
+		t.Fatalf("certificate was not revoked")
+	}
+}
+
+func TestPki_ACMENonces(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	// HEAD requests are only served by new-nonce
+	_, err := b.HandleRequest(&logical.Request{
+		Operation: logical.HeadOperation,
+		Path:      "roles/web",
+		Storage:   storage,
+	})
+	if err != logical.ErrUnsupportedOperation {
+		t.Fatalf("expected unsupported operation, got: %v", err)
+	}
+
+	first, err := b.newACMENonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last string
+	for i := 0; i < acmeMaxNonces; i++ {
+		if last, err = b.newACMENonce(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The oldest nonces are evicted once the store is full
+	if len(b.acmeNonces) != acmeMaxNonces || len(b.acmeNonceQueue) != acmeMaxNonces {
+		t.Fatalf("bad: %d nonces, %d queued", len(b.acmeNonces), len(b.acmeNonceQueue))
+	}
+	if b.consumeACMENonce(first) {
+		t.Fatalf("evicted nonce was accepted")
+	}
+	if !b.consumeACMENonce(last) || b.consumeACMENonce(last) {
+		t.Fatalf("nonce was not accepted exactly once")
+	}
+}
@@ -0,0 +1,175 @@
+package pki
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// acmeConfig holds the configuration of the ACME server of the backend
+type acmeConfig struct {
+	Enabled      bool     `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+	BaseURL      string   `json:"base_url" mapstructure:"base_url" structs:"base_url"`
+	DefaultRole  string   `json:"default_role" mapstructure:"default_role" structs:"default_role"`
+	AllowedRoles []string `json:"allowed_roles" mapstructure:"allowed_roles" structs:"allowed_roles"`
+}
+
+// roleAllowed returns true if the ACME directory of the given role can be
+// used
+func (c *acmeConfig) roleAllowed(role string) bool {
+	return role == c.DefaultRole || strutil.StrListContains(c.AllowedRoles, "*") || strutil.StrListContains(c.AllowedRoles, role)
+}
+
+func pathConfigACME(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/acme",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `Whether the ACME server of this backend is enabled.`,
+			},
+
+			"base_url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The URL under which clients reach this backend,
+e.g. "https://vault.example.com:8200/v1/pki". It is
+used to build the URLs of the ACME directory and is
+required to enable ACME.`,
+			},
+
+			"default_role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role used to issue certificates requested through
+the "acme/directory" directory. If empty, only the
+directories of the allowed roles can be used.`,
+			},
+
+			"allowed_roles": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the roles whose ACME
+directories, at "acme/roles/<role>/directory", can be
+used. "*" allows all roles.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathACMEConfigRead,
+			logical.UpdateOperation: b.pathACMEConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigACMEHelpSyn,
+		HelpDescription: pathConfigACMEHelpDesc,
+	}
+}
+
+func (b *backend) ACMEConfig(s logical.Storage) (*acmeConfig, error) {
+	entry, err := s.Get("config/acme")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result acmeConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathACMEConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ACMEConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":       config.Enabled,
+			"base_url":      config.BaseURL,
+			"default_role":  config.DefaultRole,
+			"allowed_roles": strings.Join(config.AllowedRoles, ","),
+		},
+	}, nil
+}
+
+func (b *backend) pathACMEConfigWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ACMEConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &acmeConfig{}
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if baseURLRaw, ok := data.GetOk("base_url"); ok {
+		config.BaseURL = strings.TrimSuffix(baseURLRaw.(string), "/")
+	}
+	if defaultRoleRaw, ok := data.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRoleRaw.(string)
+	}
+	if allowedRolesRaw, ok := data.GetOk("allowed_roles"); ok {
+		config.AllowedRoles = strutil.ParseDedupAndSortStrings(allowedRolesRaw.(string), ",")
+	}
+
+	if config.BaseURL != "" {
+		parsed, err := url.Parse(config.BaseURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return logical.ErrorResponse(fmt.Sprintf("invalid base_url %q", config.BaseURL)), nil
+		}
+	}
+
+	if config.Enabled && config.BaseURL == "" {
+		return logical.ErrorResponse("base_url is required to enable ACME"), nil
+	}
+
+	if config.DefaultRole != "" {
+		role, err := b.getRole(req.Storage, config.DefaultRole)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role %q", config.DefaultRole)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config/acme", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigACMEHelpSyn = `
+Configure the ACME server of this backend.
+`
+
+const pathConfigACMEHelpDesc = `
+This endpoint enables the ACME (RFC 8555) server of this backend and maps its
+directories to roles. Clients using the directory at "acme/directory" are
+issued certificates according to the default role; the directory of any
+other allowed role is available at "acme/roles/<role>/directory".
+
+The base_url must be the URL of this backend as seen by the ACME clients,
+as it is used to build the URLs returned by the server and to check the
+URLs that clients sign in their requests.
+`
@@ -0,0 +1,175 @@
+package pki
+
+import (
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// autoTidyConfig holds the configuration of the periodic tidy operation
+type autoTidyConfig struct {
+	Enabled            bool `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+	Interval           int  `json:"interval_duration" mapstructure:"interval_duration" structs:"interval_duration"`
+	SafetyBuffer       int  `json:"safety_buffer" mapstructure:"safety_buffer" structs:"safety_buffer"`
+	TidyCertStore      bool `json:"tidy_cert_store" mapstructure:"tidy_cert_store" structs:"tidy_cert_store"`
+	TidyRevocationList bool `json:"tidy_revocation_list" mapstructure:"tidy_revocation_list" structs:"tidy_revocation_list"`
+}
+
+func pathConfigAutoTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/auto-tidy",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `Set to true to enable the periodic tidy operation`,
+			},
+
+			"interval_duration": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The minimum amount of time between two periodic
+tidy operations. Defaults to 12 hours.`,
+				Default: 43200, //12h, but TypeDurationSecond currently requires defaults to be int
+			},
+
+			"safety_buffer": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before it is removed
+from the backend storage and/or revocation list.
+Defaults to 72 hours.`,
+				Default: 259200, //72h, but TypeDurationSecond currently requires defaults to be int
+			},
+
+			"tidy_cert_store": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the certificate store`,
+			},
+
+			"tidy_revocation_list": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the revocation list`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathAutoTidyRead,
+			logical.UpdateOperation: b.pathAutoTidyWrite,
+		},
+
+		HelpSynopsis:    pathConfigAutoTidyHelpSyn,
+		HelpDescription: pathConfigAutoTidyHelpDesc,
+	}
+}
+
+func (b *backend) AutoTidy(s logical.Storage) (*autoTidyConfig, error) {
+	entry, err := s.Get("config/auto-tidy")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result autoTidyConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathAutoTidyRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.AutoTidy(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":              config.Enabled,
+			"interval_duration":    config.Interval,
+			"safety_buffer":        config.SafetyBuffer,
+			"tidy_cert_store":      config.TidyCertStore,
+			"tidy_revocation_list": config.TidyRevocationList,
+		},
+	}, nil
+}
+
+func (b *backend) pathAutoTidyWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &autoTidyConfig{
+		Enabled:            d.Get("enabled").(bool),
+		Interval:           d.Get("interval_duration").(int),
+		SafetyBuffer:       d.Get("safety_buffer").(int),
+		TidyCertStore:      d.Get("tidy_cert_store").(bool),
+		TidyRevocationList: d.Get("tidy_revocation_list").(bool),
+	}
+
+	if config.Interval <= 0 {
+		return logical.ErrorResponse("interval_duration must be positive"), nil
+	}
+	if config.Enabled && !config.TidyCertStore && !config.TidyRevocationList {
+		return logical.ErrorResponse("at least one of tidy_cert_store or tidy_revocation_list must be set to enable the periodic tidy"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/auto-tidy", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// runAutoTidy starts a tidy operation with the configured parameters once
+// the interval has passed since the last periodic tidy started
+func runAutoTidy(b *backend, req *logical.Request) error {
+	config, err := b.AutoTidy(req.Storage)
+	if err != nil {
+		return err
+	}
+	if config == nil || !config.Enabled {
+		return nil
+	}
+
+	status, err := fetchTidyStatus(req.Storage)
+	if err != nil {
+		return err
+	}
+	if status != nil && !status.LastAutoTidyTime.IsZero() &&
+		time.Now().Before(status.LastAutoTidyTime.Add(time.Duration(config.Interval)*time.Second)) {
+		return nil
+	}
+
+	err = b.tidy(req, tidyParams{
+		SafetyBuffer:       config.SafetyBuffer,
+		TidyCertStore:      config.TidyCertStore,
+		TidyRevocationList: config.TidyRevocationList,
+	}, "auto")
+	if err == errTidyInProgress {
+		return nil
+	}
+	return err
+}
+
+const pathConfigAutoTidyHelpSyn = `
+Configure the periodic tidy operation.
+`
+
+const pathConfigAutoTidyHelpDesc = `
+This endpoint allows the tidy operation to be run automatically. Once enabled,
+the expired certificates and/or revocation information are removed as with the
+"tidy" endpoint, at most once per 'interval_duration'.
+
+The outcome of the last tidy operation, periodic or not, can be read from the
+"tidy-status" endpoint.
+`
@@ -1,8 +1,6 @@
 package pki
 
 import (
-	"fmt"
-
 	"github.com/hashicorp/vault/helper/certutil"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
@@ -10,7 +8,7 @@ import (
 )
 
 func pathConfigCA(b *backend) *framework.Path {
-	return &framework.Path{
+	ret := &framework.Path{
 		Pattern: "config/ca",
 		Fields: map[string]*framework.FieldSchema{
 			"pem_bundle": &framework.FieldSchema{
@@ -29,6 +27,10 @@ endpoint, just the signed certificate.`,
 		HelpSynopsis:    pathConfigCAHelpSyn,
 		HelpDescription: pathConfigCAHelpDesc,
 	}
+
+	ret.Fields = addIssuerNameFields(ret.Fields)
+
+	return ret
 }
 
 func (b *backend) pathCAWrite(
@@ -58,32 +60,21 @@ func (b *backend) pathCAWrite(
 		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
 
-	cb, err := parsedBundle.ToCertBundle()
-	if err != nil {
-		return nil, fmt.Errorf("error converting raw values into cert bundle: %s", err)
-	}
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(entry)
-	if err != nil {
-		return nil, err
-	}
-
-	// For ease of later use, also store just the certificate at a known
-	// location, plus a fresh CRL
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(entry)
+	issuer, err := b.addIssuer(req, parsedBundle, data.Get("issuer_name").(string), true)
 	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
 
-	err = buildCRL(b, req)
-
-	return nil, err
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id": issuer.ID,
+		},
+	}, nil
 }
 
 const pathConfigCAHelpSyn = `
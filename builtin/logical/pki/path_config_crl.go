@@ -10,7 +10,10 @@ import (
 
 // CRLConfig holds basic CRL configuration information
 type crlConfig struct {
-	Expiry string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	Expiry                 string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	AutoRebuild            bool   `json:"auto_rebuild" mapstructure:"auto_rebuild" structs:"auto_rebuild"`
+	AutoRebuildGracePeriod string `json:"auto_rebuild_grace_period" mapstructure:"auto_rebuild_grace_period" structs:"auto_rebuild_grace_period"`
+	EnableDelta            bool   `json:"enable_delta" mapstructure:"enable_delta" structs:"enable_delta"`
 }
 
 func pathConfigCRL(b *backend) *framework.Path {
@@ -23,6 +26,28 @@ func pathConfigCRL(b *backend) *framework.Path {
 valid; defaults to 72 hours`,
 				Default: "72h",
 			},
+
+			"auto_rebuild": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If true, the CRL is rebuilt periodically before
+it expires, instead of only on revocation or
+rotation`,
+			},
+
+			"auto_rebuild_grace_period": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How long before its expiration the CRL is
+rebuilt when auto_rebuild is set; defaults to 12
+hours`,
+				Default: "12h",
+			},
+
+			"enable_delta": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If true, revocations only update delta CRLs,
+listing the certificates revoked since the last
+complete CRL; requires auto_rebuild`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -64,7 +89,10 @@ func (b *backend) pathCRLRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"expiry": config.Expiry,
+			"expiry":                    config.Expiry,
+			"auto_rebuild":              config.AutoRebuild,
+			"auto_rebuild_grace_period": config.AutoRebuildGracePeriod,
+			"enable_delta":              config.EnableDelta,
 		},
 	}, nil
 }
@@ -73,13 +101,30 @@ func (b *backend) pathCRLWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	expiry := d.Get("expiry").(string)
 
-	_, err := time.ParseDuration(expiry)
+	expiryDur, err := time.ParseDuration(expiry)
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf("Given expiry could not be decoded: %s", err)), nil
 	}
 
 	config := &crlConfig{
-		Expiry: expiry,
+		Expiry:                 expiry,
+		AutoRebuild:            d.Get("auto_rebuild").(bool),
+		AutoRebuildGracePeriod: d.Get("auto_rebuild_grace_period").(string),
+		EnableDelta:            d.Get("enable_delta").(bool),
+	}
+
+	gracePeriod, err := time.ParseDuration(config.AutoRebuildGracePeriod)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given auto_rebuild_grace_period could not be decoded: %s", err)), nil
+	}
+	if config.AutoRebuild && gracePeriod >= expiryDur {
+		return logical.ErrorResponse("auto_rebuild_grace_period must be shorter than the expiry"), nil
+	}
+
+	// The complete CRL is only kept up to date by the periodic rebuild once
+	// revocations update the delta CRL
+	if config.EnableDelta && !config.AutoRebuild {
+		return logical.ErrorResponse("enable_delta requires auto_rebuild"), nil
 	}
 
 	entry, err := logical.StorageEntryJSON("config/crl", config)
@@ -91,13 +136,31 @@ func (b *backend) pathCRLWrite(
 		return nil, err
 	}
 
-	return nil, nil
+	// Rebuild the CRL to start or stop serving delta CRLs
+	issuersConfig, err := fetchIssuersConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	if issuersConfig.Default == "" {
+		return nil, nil
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	return nil, buildCRL(b, req)
 }
 
 const pathConfigCRLHelpSyn = `
-Configure the CRL expiration.
+Configure the CRL expiration and rebuilding.
 `
 
 const pathConfigCRLHelpDesc = `
 This endpoint allows configuration of the CRL lifetime.
+
+With "auto_rebuild" set, the CRL is rebuilt before it expires, even when no
+certificate is revoked. With "enable_delta" also set, revocations only update
+the delta CRLs, available at "crl/delta" and "crl/issuer/<issuer_ref>/delta",
+which list the certificates revoked since the last complete CRL; the complete
+CRL is then only rebuilt before it expires or with "crl/rotate".
 `
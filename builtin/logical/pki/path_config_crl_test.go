@@ -0,0 +1,248 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testCRL is a parsed CRL along with its CRL number
+type testCRL struct {
+	*pkix.CertificateList
+	Number *big.Int
+}
+
+func parseTestCRL(t *testing.T, der []byte) *testCRL {
+	crl, err := x509.ParseCRL(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &testCRL{CertificateList: crl}
+	for _, ext := range crl.TBSCertList.Extensions {
+		if ext.Id.Equal(oidCRLNumber) {
+			if _, err := asn1.Unmarshal(ext.Value, &result.Number); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if result.Number == nil {
+		t.Fatalf("the CRL has no CRL number")
+	}
+	return result
+}
+
+func TestPki_DeltaCRL(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+	})
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerID := resp.Data["issuer_id"].(string)
+
+	// fetchCRL returns the CRL at the path along with its base CRL number
+	// if it is a delta CRL, verifying its signature
+	fetchCRL := func(path string) (*testCRL, int64) {
+		resp := request(logical.ReadOperation, path, nil)
+		crl := parseTestCRL(t, resp.Data[logical.HTTPRawBody].([]byte))
+		if err := caCert.CheckCRLSignature(crl.CertificateList); err != nil {
+			t.Fatalf("bad: CRL signature: %v", err)
+		}
+		var baseNumber int64
+		for _, ext := range crl.TBSCertList.Extensions {
+			if ext.Id.Equal(oidDeltaCRLIndicator) {
+				if !ext.Critical {
+					t.Fatalf("the delta CRL indicator must be critical")
+				}
+				if _, err := asn1.Unmarshal(ext.Value, &baseNumber); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return crl, baseNumber
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/crl",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enable_delta": true,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error enabling delta CRLs without auto rebuild, got: %v, %#v", err, resp)
+	}
+
+	request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"auto_rebuild": true,
+		"enable_delta": true,
+	})
+	resp = request(logical.ReadOperation, "config/crl", nil)
+	if resp.Data["auto_rebuild_grace_period"] != "12h" || !resp.Data["enable_delta"].(bool) {
+		t.Fatalf("bad: CRL config: %#v", resp.Data)
+	}
+
+	request(logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	var serials []string
+	for _, name := range []string{"one.example.com", "two.example.com"} {
+		resp = request(logical.UpdateOperation, "issue/web", map[string]interface{}{
+			"common_name": name,
+		})
+		serials = append(serials, resp.Data["serial_number"].(string))
+	}
+
+	complete, _ := fetchCRL("crl")
+	delta, baseNumber := fetchCRL("crl/delta")
+	if baseNumber != complete.Number.Int64() || delta.Number.Cmp(complete.Number) <= 0 || len(delta.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatalf("bad: delta CRL %d on top of %d: %#v", delta.Number, baseNumber, delta.TBSCertList.RevokedCertificates)
+	}
+
+	// Revocations only update the delta CRLs
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{"serial_number": serials[0]})
+
+	crl, _ := fetchCRL("crl")
+	if crl.Number.Cmp(complete.Number) != 0 || len(crl.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatalf("the complete CRL was rebuilt on revocation")
+	}
+	for _, path := range []string{"crl/delta", "crl/issuer/default/delta", "crl/issuer/" + issuerID + "/delta"} {
+		delta, baseNumber = fetchCRL(path)
+		if baseNumber != complete.Number.Int64() || len(delta.TBSCertList.RevokedCertificates) != 1 ||
+			certutil.GetHexFormatted(delta.TBSCertList.RevokedCertificates[0].SerialNumber.Bytes(), ":") != serials[0] {
+			t.Fatalf("bad: delta CRL at %s: %#v", path, delta.TBSCertList.RevokedCertificates)
+		}
+	}
+
+	resp = request(logical.ReadOperation, "crl/delta/pem", nil)
+	if block, _ := pem.Decode(resp.Data[logical.HTTPRawBody].([]byte)); block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("bad: PEM delta CRL: %q", resp.Data[logical.HTTPRawBody])
+	}
+
+	// Rotating the CRL folds the delta into the complete CRL
+	request(logical.ReadOperation, "crl/rotate", nil)
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{"serial_number": serials[1]})
+
+	complete, _ = fetchCRL("crl")
+	delta, baseNumber = fetchCRL("crl/delta")
+	if len(complete.TBSCertList.RevokedCertificates) != 1 || baseNumber != complete.Number.Int64() ||
+		len(delta.TBSCertList.RevokedCertificates) != 1 ||
+		certutil.GetHexFormatted(delta.TBSCertList.RevokedCertificates[0].SerialNumber.Bytes(), ":") != serials[1] {
+		t.Fatalf("bad: complete CRL %#v, delta CRL %#v", complete.TBSCertList.RevokedCertificates, delta.TBSCertList.RevokedCertificates)
+	}
+
+	// Disabling delta CRLs stops serving them
+	request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"auto_rebuild": true,
+	})
+	complete, _ = fetchCRL("crl")
+	if len(complete.TBSCertList.RevokedCertificates) != 2 {
+		t.Fatalf("bad: complete CRL: %#v", complete.TBSCertList.RevokedCertificates)
+	}
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "crl/delta",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.Data[logical.HTTPStatusCode] == 200 {
+		t.Fatalf("expected no delta CRL, got: %v, %#v", err, resp)
+	}
+}
+
+func TestPki_CRLAutoRebuild(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	req := &logical.Request{Storage: storage}
+
+	// Nothing to rebuild without a CA
+	if err := b.periodicFunc(req); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(path string, data map[string]interface{}) {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+	}
+	request("root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+	})
+	request("config/crl", map[string]interface{}{
+		"expiry":                    "1h",
+		"auto_rebuild":              true,
+		"auto_rebuild_grace_period": "10m",
+	})
+
+	state, err := fetchCRLState(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	number := state.Number
+
+	// The CRL isn't rebuilt before the grace period
+	if err := b.periodicFunc(req); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = fetchCRLState(req); err != nil || state.Number != number {
+		t.Fatalf("bad: CRL rebuilt too early: %#v, %v", state, err)
+	}
+
+	state.NextUpdate = time.Now().Add(5 * time.Minute)
+	entry, err := logical.StorageEntryJSON("crl_state", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.periodicFunc(req); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = fetchCRLState(req); err != nil || state.Number != number+1 || state.NextUpdate.Before(time.Now().Add(50*time.Minute)) {
+		t.Fatalf("bad: CRL not rebuilt: %#v, %v", state, err)
+	}
+
+	entry, err = storage.Get("crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl := parseTestCRL(t, entry.Value)
+	if crl.Number.Int64() != state.Number || !crl.TBSCertList.NextUpdate.Equal(state.NextUpdate.Truncate(time.Second)) {
+		t.Fatalf("bad: CRL number %d, next update %v", crl.Number, crl.TBSCertList.NextUpdate)
+	}
+}
@@ -0,0 +1,222 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// estConfig holds the configuration of the EST server of the backend
+type estConfig struct {
+	Enabled      bool     `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+	DefaultRole  string   `json:"default_role" mapstructure:"default_role" structs:"default_role"`
+	AllowedRoles []string `json:"allowed_roles" mapstructure:"allowed_roles" structs:"allowed_roles"`
+
+	// ChallengePassword is the shared secret that clients without a
+	// certificate put in the challengePassword attribute of their CSR
+	ChallengePassword string `json:"challenge_password" mapstructure:"challenge_password" structs:"challenge_password"`
+
+	// TrustedCertificates holds the PEM-encoded CAs, in addition to the
+	// issuers of the backend, whose client certificates may enroll
+	TrustedCertificates string `json:"trusted_certificates" mapstructure:"trusted_certificates" structs:"trusted_certificates"`
+}
+
+// roleAllowed returns true if the EST endpoints of the given role can be
+// used
+func (c *estConfig) roleAllowed(role string) bool {
+	return role == c.DefaultRole || strutil.StrListContains(c.AllowedRoles, "*") || strutil.StrListContains(c.AllowedRoles, role)
+}
+
+func pathConfigEST(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/est",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `Whether the EST server of this backend is enabled.`,
+			},
+
+			"default_role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role used to issue certificates requested through
+the "est/" endpoints. If empty, only the endpoints of
+the allowed roles can be used.`,
+			},
+
+			"allowed_roles": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Comma-separated list of the roles whose EST
+endpoints, at "est/roles/<role>/", can be used. "*"
+allows all roles.`,
+			},
+
+			"challenge_password": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Shared secret that clients without a trusted
+certificate must put in the challengePassword
+attribute of their CSR to enroll. If empty, only
+clients with a trusted certificate can enroll.`,
+			},
+
+			"trusted_certificates": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-encoded CA certificates whose client
+certificates are allowed to enroll. The certificates
+issued by this backend only allow re-enrollment.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathESTConfigRead,
+			logical.UpdateOperation: b.pathESTConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigESTHelpSyn,
+		HelpDescription: pathConfigESTHelpDesc,
+	}
+}
+
+func (b *backend) ESTConfig(s logical.Storage) (*estConfig, error) {
+	entry, err := s.Get("config/est")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result estConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathESTConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ESTConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":                config.Enabled,
+			"default_role":           config.DefaultRole,
+			"allowed_roles":          strings.Join(config.AllowedRoles, ","),
+			"challenge_password_set": config.ChallengePassword != "",
+			"trusted_certificates":   config.TrustedCertificates,
+		},
+	}, nil
+}
+
+func (b *backend) pathESTConfigWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ESTConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &estConfig{}
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if defaultRoleRaw, ok := data.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRoleRaw.(string)
+	}
+	if allowedRolesRaw, ok := data.GetOk("allowed_roles"); ok {
+		config.AllowedRoles = strutil.ParseDedupAndSortStrings(allowedRolesRaw.(string), ",")
+	}
+	if challengePasswordRaw, ok := data.GetOk("challenge_password"); ok {
+		config.ChallengePassword = challengePasswordRaw.(string)
+	}
+	if trustedCertificatesRaw, ok := data.GetOk("trusted_certificates"); ok {
+		config.TrustedCertificates = trustedCertificatesRaw.(string)
+	}
+
+	if config.TrustedCertificates != "" {
+		if _, err := parseTrustedCertificates(config.TrustedCertificates); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if config.DefaultRole != "" {
+		role, err := b.getRole(req.Storage, config.DefaultRole)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role %q", config.DefaultRole)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config/est", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// parseTrustedCertificates parses the PEM-encoded CA certificates trusted to
+// authenticate EST clients
+func parseTrustedCertificates(pemCerts string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(pemCerts)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("trusted_certificates must only contain certificates, found %q", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse trusted certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("trusted_certificates contains no certificate")
+	}
+
+	return certs, nil
+}
+
+const pathConfigESTHelpSyn = `
+Configure the EST server of this backend.
+`
+
+const pathConfigESTHelpDesc = `
+This endpoint enables the EST (RFC 7030) server of this backend, so that
+devices without a Vault client can enroll, and maps its endpoints to roles.
+Clients using the endpoints under "est/" are issued certificates according to
+the default role; the endpoints of any other allowed role are available under
+"est/roles/<role>/".
+
+Clients authenticate either with a TLS client certificate issued by one of the
+trusted_certificates, or with the challenge_password in the challengePassword
+attribute of their CSR. Re-enrollment always requires the current certificate
+of the client, issued by the issuer of the role, for the same subject.
+
+The same configuration applies to the SCEP endpoints at "scep" and
+"scep/roles/<role>", whose clients authenticate with the certificate that signs
+their requests instead of a TLS client certificate.
+`
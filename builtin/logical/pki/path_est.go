@@ -0,0 +1,429 @@
+package pki
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// estPattern returns the pattern of an EST path, matching both the default
+// endpoints and the endpoints of the roles
+func estPattern(suffix string) string {
+	return "est/(roles/" + framework.GenericNameRegex("role") + "/)?" + suffix
+}
+
+func estFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"role": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `The role of the EST endpoints; the default role if empty`,
+		},
+	}
+}
+
+func pathESTCACerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("cacerts"),
+		Fields:  estFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathESTCACertsRead,
+		},
+
+		HelpSynopsis:    pathESTCACertsHelpSyn,
+		HelpDescription: pathESTCACertsHelpDesc,
+	}
+}
+
+func pathESTSimpleEnroll(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("simpleenroll"),
+		Fields:  estFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathESTSimpleEnroll,
+		},
+
+		HelpSynopsis:    pathESTSimpleEnrollHelpSyn,
+		HelpDescription: pathESTSimpleEnrollHelpDesc,
+	}
+}
+
+func pathESTSimpleReenroll(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: estPattern("simplereenroll"),
+		Fields:  estFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathESTSimpleReenroll,
+		},
+
+		HelpSynopsis:    pathESTSimpleReenrollHelpSyn,
+		HelpDescription: pathESTSimpleReenrollHelpDesc,
+	}
+}
+
+// estErrorResponse returns a plain text error, as EST clients can't parse
+// Vault errors
+func estErrorResponse(status int, format string, args ...interface{}) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  status,
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte(fmt.Sprintf(format, args...) + "\n"),
+		},
+	}
+}
+
+// estCertsResponse returns the certificates as a base64 encoded certs-only
+// PKCS#7 message
+func estCertsResponse(certs []*x509.Certificate) (*logical.Response, error) {
+	body, err := createCertsOnlyPKCS7(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPContentType: "application/pkcs7-mime; smime-type=certs-only",
+			logical.HTTPRawBody:     encodeESTBody(body),
+			logical.HTTPRawHeaders: map[string][]string{
+				"Content-Transfer-Encoding": []string{"base64"},
+			},
+		},
+	}, nil
+}
+
+// estRole returns the EST configuration and the role of the requested
+// endpoints, or the response to return when EST can't be used
+func (b *backend) estRole(req *logical.Request, data *framework.FieldData) (*estConfig, *roleEntry, *logical.Response, error) {
+	config, err := b.ESTConfig(req.Storage)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if config == nil || !config.Enabled {
+		return nil, nil, estErrorResponse(http.StatusForbidden, "EST is not enabled on this backend"), nil
+	}
+
+	roleName := data.Get("role").(string)
+	if roleName == "" {
+		roleName = config.DefaultRole
+		if roleName == "" {
+			return nil, nil, estErrorResponse(http.StatusNotFound, "no default role is configured for EST"), nil
+		}
+	} else if !config.roleAllowed(roleName) {
+		return nil, nil, estErrorResponse(http.StatusForbidden, "role %q is not allowed for EST", roleName), nil
+	}
+
+	role, err := b.getRole(req.Storage, roleName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if role == nil {
+		return nil, nil, estErrorResponse(http.StatusNotFound, "unknown role %q", roleName), nil
+	}
+
+	return config, role, nil, nil
+}
+
+func (b *backend) pathESTCACertsRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	_, role, resp, err := b.estRole(req, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	caInfo, err := fetchCAInfoByRef(req, role.IssuerRef)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return estErrorResponse(http.StatusNotFound, "%s", err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	certs := []*x509.Certificate{caInfo.Certificate}
+	for _, block := range caInfo.CAChain {
+		certs = append(certs, block.Certificate)
+	}
+
+	return estCertsResponse(certs)
+}
+
+func (b *backend) pathESTSimpleEnroll(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, role, resp, err := b.estRole(req, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	csr, resp := parseESTRequest(req)
+	if resp != nil {
+		return resp, nil
+	}
+
+	// Clients prove that they may enroll with a certificate of one of the
+	// trusted CAs or with the shared secret. The certificates issued by this
+	// backend only allow their holder to re-enroll.
+	var trusted []*x509.Certificate
+	if config.TrustedCertificates != "" {
+		trusted, err = parseTrustedCertificates(config.TrustedCertificates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	clientCert, err := b.estClientCertificate(req, trusted)
+	if err != nil {
+		return nil, err
+	}
+	if clientCert == nil {
+		password, err := csrChallengePassword(csr)
+		if err != nil {
+			return estErrorResponse(http.StatusBadRequest, "%s", err.Error()), nil
+		}
+		if config.ChallengePassword == "" || password == "" ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(config.ChallengePassword)) != 1 {
+			return estErrorResponse(http.StatusUnauthorized, "a trusted client certificate or a valid challenge password is required"), nil
+		}
+	}
+
+	return b.estIssue(req, role, csr)
+}
+
+func (b *backend) pathESTSimpleReenroll(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	_, role, resp, err := b.estRole(req, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	csr, resp := parseESTRequest(req)
+	if resp != nil {
+		return resp, nil
+	}
+
+	// Only the certificates of the issuer of the role can be renewed
+	// through its endpoints
+	caInfo, err := fetchCAInfoByRef(req, role.IssuerRef)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return estErrorResponse(http.StatusNotFound, "%s", err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+	clientCert, err := b.estClientCertificate(req, []*x509.Certificate{caInfo.Certificate})
+	if err != nil {
+		return nil, err
+	}
+	if clientCert == nil {
+		return estErrorResponse(http.StatusUnauthorized, "a client certificate issued by the issuer of the role is required"), nil
+	}
+	serial := certutil.GetHexFormatted(clientCert.SerialNumber.Bytes(), ":")
+	revokedEntry, err := fetchCertBySerial(req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revokedEntry != nil {
+		b.Logger().Debug("EST client certificate revoked", "serial", serial)
+		return estErrorResponse(http.StatusUnauthorized, "the client certificate is revoked"), nil
+	}
+
+	// The renewed certificate must identify the same client, see section
+	// 4.2.2 of RFC 7030
+	if !csrMatchesCertificate(csr, clientCert) {
+		return estErrorResponse(http.StatusBadRequest, "the CSR must request the subject and names of the current certificate"), nil
+	}
+
+	return b.estIssue(req, role, csr)
+}
+
+// parseESTRequest parses the base64 encoded CSR of an enrollment request
+func parseESTRequest(req *logical.Request) (*x509.CertificateRequest, *logical.Response) {
+	body, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok {
+		return nil, estErrorResponse(http.StatusUnsupportedMediaType, "the request must be an application/pkcs10 CSR")
+	}
+
+	der, err := decodeESTBody(body)
+	if err != nil {
+		return nil, estErrorResponse(http.StatusBadRequest, "invalid CSR encoding: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, estErrorResponse(http.StatusBadRequest, "invalid CSR: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, estErrorResponse(http.StatusBadRequest, "invalid CSR signature: %v", err)
+	}
+
+	return csr, nil
+}
+
+// estClientCertificate returns the TLS client certificate of the request if
+// it chains to one of the given roots, or nil otherwise
+func (b *backend) estClientCertificate(req *logical.Request, roots []*x509.Certificate) (*x509.Certificate, error) {
+	if req.Connection == nil || req.Connection.ConnState == nil ||
+		len(req.Connection.ConnState.PeerCertificates) == 0 || len(roots) == 0 {
+		return nil, nil
+	}
+	peerCerts := req.Connection.ConnState.PeerCertificates
+	clientCert := peerCerts[0]
+
+	rootPool := x509.NewCertPool()
+	for _, cert := range roots {
+		rootPool.AddCert(cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range peerCerts[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := clientCert.Verify(x509.VerifyOptions{
+		Roots:         rootPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		b.Logger().Debug("EST client certificate not trusted", "error", err)
+		return nil, nil
+	}
+
+	return clientCert, nil
+}
+
+// estIssue signs the CSR according to the role and returns the certificate
+// to the client
+func (b *backend) estIssue(req *logical.Request, role *roleEntry, csr *x509.CertificateRequest) (*logical.Response, error) {
+	cert, err := b.enrollCSR(req, role, csr)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return estErrorResponse(http.StatusBadRequest, "%s", err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	return estCertsResponse([]*x509.Certificate{cert})
+}
+
+// enrollCSR signs the CSR of an enrolling client according to the role and
+// stores the issued certificate
+func (b *backend) enrollCSR(req *logical.Request, role *roleEntry, csr *x509.CertificateRequest) (*x509.Certificate, error) {
+	signingBundle, err := fetchCAInfoByRef(req, role.IssuerRef)
+	if err != nil {
+		return nil, err
+	}
+
+	commonName := csr.Subject.CommonName
+	if commonName == "" && len(csr.DNSNames) > 0 {
+		commonName = csr.DNSNames[0]
+	}
+	altNames := append(append([]string{}, csr.DNSNames...), csr.EmailAddresses...)
+
+	fields := addNonCACommonFields(map[string]*framework.FieldSchema{})
+	fields["csr"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+	}
+	signData := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csr.Raw,
+			})),
+			"common_name": commonName,
+			"alt_names":   strings.Join(altNames, ","),
+			"ip_sans":     strings.Join(ipStrings(csr.IPAddresses), ","),
+		},
+		Schema: fields,
+	}
+
+	parsedBundle, err := signCert(b, role, signingBundle, false, false, req, signData)
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := parsedBundle.ToCertBundle()
+	if err != nil {
+		return nil, fmt.Errorf("Error converting raw cert bundle to cert bundle: %s", err)
+	}
+
+	err = req.Storage.Put(&logical.StorageEntry{
+		Key:   "certs/" + cb.SerialNumber,
+		Value: parsedBundle.CertificateBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to store certificate locally: %v", err)
+	}
+
+	return parsedBundle.Certificate, nil
+}
+
+// csrMatchesCertificate returns true if the CSR requests the subject and
+// names of the certificate, as required to renew it
+func csrMatchesCertificate(csr *x509.CertificateRequest, cert *x509.Certificate) bool {
+	return csr.Subject.CommonName == cert.Subject.CommonName &&
+		strutil.EquivalentSlices(csr.DNSNames, cert.DNSNames) &&
+		strutil.EquivalentSlices(csr.EmailAddresses, cert.EmailAddresses) &&
+		strutil.EquivalentSlices(ipStrings(csr.IPAddresses), ipStrings(cert.IPAddresses))
+}
+
+func ipStrings(ips []net.IP) []string {
+	var result []string
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+	return result
+}
+
+const pathESTCACertsHelpSyn = `
+Fetch the CA certificates of an EST endpoint.
+`
+
+const pathESTCACertsHelpDesc = `
+This endpoint implements the "cacerts" operation of EST (RFC 7030). It returns
+the certificate of the issuer of the role, along with its chain, as a base64
+encoded certs-only PKCS#7 message. It requires EST to be enabled with the
+"config/est" endpoint.
+`
+
+const pathESTSimpleEnrollHelpSyn = `
+Enroll a client through EST.
+`
+
+const pathESTSimpleEnrollHelpDesc = `
+This endpoint implements the "simpleenroll" operation of EST (RFC 7030). The
+body of the request is a base64 encoded PKCS#10 CSR with the
+"application/pkcs10" content type; it is signed according to the role and the
+certificate is returned as a base64 encoded certs-only PKCS#7 message.
+
+The client must either present a TLS client certificate issued by one of the
+trusted certificates configured in "config/est", or include the configured
+challenge password in the challengePassword attribute of its CSR. The
+certificates issued by this backend only allow their holder to re-enroll.
+`
+
+const pathESTSimpleReenrollHelpSyn = `
+Renew the certificate of a client through EST.
+`
+
+const pathESTSimpleReenrollHelpDesc = `
+This endpoint implements the "simplereenroll" operation of EST (RFC 7030). It
+works like "simpleenroll", except that the client must present its current
+certificate, issued by the issuer of the role and not revoked, as a TLS client
+certificate, and that the CSR must request the same subject and names.
+`
@@ -0,0 +1,317 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_EST(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// estRequest sends an EST request, presenting the client certificate if
+	// any, and returns the status code and the certificates of the response
+	estRequest := func(op logical.Operation, path string, csr []byte, clientCerts ...*x509.Certificate) (int, []*x509.Certificate) {
+		req := &logical.Request{
+			Operation:  op,
+			Path:       path,
+			Storage:    storage,
+			Connection: &logical.Connection{},
+		}
+		if csr != nil {
+			req.Data = map[string]interface{}{
+				logical.HTTPRawBody: []byte(base64.StdEncoding.EncodeToString(csr)),
+			}
+		}
+		if len(clientCerts) > 0 {
+			req.Connection.ConnState = &tls.ConnectionState{
+				PeerCertificates: clientCerts,
+			}
+		}
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("path %s: err: %v", path, err)
+		}
+		status := resp.Data[logical.HTTPStatusCode].(int)
+		if status != http.StatusOK {
+			return status, nil
+		}
+
+		if resp.Data[logical.HTTPRawHeaders].(map[string][]string)["Content-Transfer-Encoding"][0] != "base64" {
+			t.Fatalf("bad: headers: %#v", resp.Data[logical.HTTPRawHeaders])
+		}
+		der, err := decodeESTBody(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var contentInfo pkcs7ContentInfo
+		if _, err := asn1.Unmarshal(der, &contentInfo); err != nil {
+			t.Fatal(err)
+		}
+		var signedData pkcs7SignedData
+		if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+			t.Fatal(err)
+		}
+		certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status, certs
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createCSR := func(commonName string, dnsNames []string, password string) []byte {
+		return createTestCSR(t, key, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: commonName},
+			DNSNames: dnsNames,
+		}, password)
+	}
+
+	resp := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+	})
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request(logical.UpdateOperation, "roles/devices", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"key_bits":         256,
+		"ttl":              "1h",
+	})
+	request(logical.UpdateOperation, "roles/servers", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+	})
+
+	if status, _ := estRequest(logical.ReadOperation, "est/cacerts", nil); status != http.StatusForbidden {
+		t.Fatalf("expected EST to be disabled, got status %d", status)
+	}
+
+	request(logical.UpdateOperation, "config/est", map[string]interface{}{
+		"enabled":            true,
+		"default_role":       "devices",
+		"challenge_password": "s3cret",
+	})
+	resp = request(logical.ReadOperation, "config/est", nil)
+	if resp.Data["default_role"] != "devices" || !resp.Data["challenge_password_set"].(bool) || resp.Data["challenge_password"] != nil {
+		t.Fatalf("bad: EST config: %#v", resp.Data)
+	}
+
+	status, certs := estRequest(logical.ReadOperation, "est/cacerts", nil)
+	if status != http.StatusOK || len(certs) != 1 || !certs[0].Equal(caCert) {
+		t.Fatalf("bad: CA certificates: %d, %#v", status, certs)
+	}
+	if status, _ := estRequest(logical.ReadOperation, "est/roles/servers/cacerts", nil); status != http.StatusForbidden {
+		t.Fatalf("expected the role to be denied, got status %d", status)
+	}
+
+	// Clients without a certificate need the challenge password
+	for _, password := range []string{"", "wrong"} {
+		status, _ := estRequest(logical.UpdateOperation, "est/simpleenroll", createCSR("device.example.com", nil, password))
+		if status != http.StatusUnauthorized {
+			t.Fatalf("expected enrollment with password %q to be denied, got status %d", password, status)
+		}
+	}
+	status, certs = estRequest(logical.UpdateOperation, "est/simpleenroll", createCSR("device.example.com", nil, "s3cret"))
+	if status != http.StatusOK || len(certs) != 1 {
+		t.Fatalf("bad: enrollment: %d, %#v", status, certs)
+	}
+	deviceCert := certs[0]
+	if deviceCert.Subject.CommonName != "device.example.com" || deviceCert.CheckSignatureFrom(caCert) != nil {
+		t.Fatalf("bad: enrolled certificate: %#v", deviceCert.Subject)
+	}
+	if status, _ := estRequest(logical.UpdateOperation, "est/simpleenroll", createCSR("device.example.org", nil, "s3cret")); status != http.StatusBadRequest {
+		t.Fatalf("expected a name denied by the role to be rejected, got status %d", status)
+	}
+
+	// Re-enrollment requires the current certificate
+	renewCSR := createCSR("device.example.com", []string{"device.example.com"}, "")
+	if status, _ := estRequest(logical.UpdateOperation, "est/simplereenroll", renewCSR); status != http.StatusUnauthorized {
+		t.Fatalf("expected re-enrollment without a certificate to be denied, got status %d", status)
+	}
+	if status, _ := estRequest(logical.UpdateOperation, "est/simplereenroll", createCSR("other.example.com", []string{"other.example.com"}, ""), deviceCert); status != http.StatusBadRequest {
+		t.Fatalf("expected re-enrollment for other names to be rejected, got status %d", status)
+	}
+	status, certs = estRequest(logical.UpdateOperation, "est/simplereenroll", renewCSR, deviceCert)
+	if status != http.StatusOK || len(certs) != 1 || certs[0].SerialNumber.Cmp(deviceCert.SerialNumber) == 0 {
+		t.Fatalf("bad: re-enrollment: %d, %#v", status, certs)
+	}
+
+	// Roles of another issuer don't renew the certificate
+	request(logical.UpdateOperation, "root/rotate/internal", map[string]interface{}{
+		"common_name": "Vault Other Root",
+		"ttl":         "24h",
+		"issuer_name": "other",
+	})
+	request(logical.UpdateOperation, "roles/others", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"issuer_ref":       "other",
+	})
+	request(logical.UpdateOperation, "config/est", map[string]interface{}{
+		"allowed_roles": "others",
+	})
+	if status, _ := estRequest(logical.UpdateOperation, "est/roles/others/simplereenroll", renewCSR, deviceCert); status != http.StatusUnauthorized {
+		t.Fatalf("expected re-enrollment through a role of another issuer to be denied, got status %d", status)
+	}
+
+	// The certificates issued by the backend only allow to re-enroll
+	status, _ = estRequest(logical.UpdateOperation, "est/simpleenroll", createCSR("second.example.com", nil, ""), deviceCert)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected enrollment with an issued certificate to be denied, got status %d", status)
+	}
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": certutil.GetHexFormatted(deviceCert.SerialNumber.Bytes(), ":"),
+	})
+	if status, _ := estRequest(logical.UpdateOperation, "est/simplereenroll", renewCSR, deviceCert); status != http.StatusUnauthorized {
+		t.Fatalf("expected re-enrollment with a revoked certificate to be denied, got status %d", status)
+	}
+
+	// Certificates of trusted CAs may enroll but not re-enroll
+	vendorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vendorTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Device Vendor CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	vendorDER, err := x509.CreateCertificate(rand.Reader, vendorTemplate, vendorTemplate, vendorKey.Public(), vendorKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vendorCert, err := x509.ParseCertificate(vendorDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "vendor.example.com"},
+		DNSNames:     []string{"vendor.example.com"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, vendorCert, key.Public(), vendorKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idCert, err := x509.ParseCertificate(idDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vendorCSR := createCSR("vendor.example.com", []string{"vendor.example.com"}, "")
+	if status, _ := estRequest(logical.UpdateOperation, "est/simpleenroll", vendorCSR, idCert); status != http.StatusUnauthorized {
+		t.Fatalf("expected enrollment with an untrusted certificate to be denied, got status %d", status)
+	}
+	request(logical.UpdateOperation, "config/est", map[string]interface{}{
+		"trusted_certificates": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: vendorDER})),
+	})
+	if status, _ := estRequest(logical.UpdateOperation, "est/simpleenroll", vendorCSR, idCert); status != http.StatusOK {
+		t.Fatalf("bad: enrollment with a trusted certificate: %d", status)
+	}
+	if status, _ := estRequest(logical.UpdateOperation, "est/simplereenroll", vendorCSR, idCert); status != http.StatusUnauthorized {
+		t.Fatalf("expected re-enrollment with a certificate of another CA to be denied, got status %d", status)
+	}
+}
+
+// createTestCSR returns a CSR signed by the key, with the challengePassword
+// attribute if the password isn't empty
+func createTestCSR(t *testing.T, key crypto.Signer, template *x509.CertificateRequest, password string) []byte {
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password == "" {
+		return der
+	}
+
+	// Go doesn't encode the challengePassword attribute, add it and sign the
+	// CSR again
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tbs csrTBS
+	if _, err := asn1.Unmarshal(csr.RawTBSCertificateRequest, &tbs); err != nil {
+		t.Fatal(err)
+	}
+	value, err := asn1.Marshal(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbs.Attributes = append(tbs.Attributes, csrAttribute{
+		Type:   oidChallengePassword,
+		Values: []asn1.RawValue{{FullBytes: value}},
+	})
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbsDER)
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureAlgorithm := oidECDSAWithSHA256
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		signatureAlgorithm = oidSHA256WithRSA
+	}
+	der, err = asn1.Marshal(struct {
+		TBS                asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}{
+		TBS:                asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: signatureAlgorithm},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -40,7 +40,7 @@ func pathFetchCAChain(b *backend) *framework.Path {
 // Returns the CRL in raw format
 func pathFetchCRL(b *backend) *framework.Path {
 	return &framework.Path{
-		Pattern: `crl(/pem)?`,
+		Pattern: `crl(/delta)?(/pem)?`,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathFetchRead,
@@ -143,6 +143,12 @@ func (b *backend) pathFetchRead(req *logical.Request, data *framework.FieldData)
 		if req.Path == "crl/pem" {
 			pemType = "X509 CRL"
 		}
+	case req.Path == "crl/delta" || req.Path == "crl/delta/pem":
+		serial = "delta-crl"
+		contentType = "application/pkix-crl"
+		if req.Path == "crl/delta/pem" {
+			pemType = "X509 CRL"
+		}
 	case req.Path == "cert/crl":
 		serial = "crl"
 		pemType = "X509 CRL"
@@ -261,5 +267,7 @@ This allows certificates to be fetched. If using the fetch/ prefix any non-revok
 
 Using "ca" or "crl" as the value fetches the appropriate information in DER encoding. Add "/pem" to either to get PEM encoding.
 
+Using "crl/delta" fetches the delta CRL, when enabled in "config/crl", in DER encoding. Add "/pem" to get PEM encoding.
+
 Using "ca_chain" as the value fetches the certificate authority trust chain in PEM encoding.
 `
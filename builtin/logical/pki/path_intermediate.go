@@ -40,6 +40,14 @@ certificate with a public key matching the
 previously-generated key from the generation
 endpoint.`,
 			},
+
+			"set_default": &framework.FieldSchema{
+				Type:    framework.TypeBool,
+				Default: true,
+				Description: `Whether the new issuer becomes the default one.
+Set to false to rotate the intermediate CA
+without switching to it immediately.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -50,6 +58,8 @@ endpoint.`,
 		HelpDescription: pathSetSignedIntermediateHelpDesc,
 	}
 
+	ret.Fields = addIssuerNameFields(ret.Fields)
+
 	return ret
 }
 
@@ -106,11 +116,19 @@ func (b *backend) pathGenerateIntermediate(
 		}
 	}
 
+	// Keep the key until the signed certificate is set; the current issuers
+	// stay in use meanwhile
 	cb := &certutil.CertBundle{}
 	cb.PrivateKey = csrb.PrivateKey
 	cb.PrivateKeyType = csrb.PrivateKeyType
 
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
+	// Make sure a pending key of an older version isn't migrated over this
+	// one
+	if _, err := fetchIssuersConfig(req); err != nil {
+		return nil, err
+	}
+
+	entry, err := logical.StorageEntryJSON("config/intermediate_key", cb)
 	if err != nil {
 		return nil, err
 	}
@@ -144,8 +162,12 @@ func (b *backend) pathSetSignedIntermediate(
 		return logical.ErrorResponse("supplied certificate could not be successfully parsed"), nil
 	}
 
+	if _, err := fetchIssuersConfig(req); err != nil {
+		return nil, err
+	}
+
 	cb := &certutil.CertBundle{}
-	entry, err := req.Storage.Get("config/ca_bundle")
+	entry, err := req.Storage.Get("config/intermediate_key")
 	if err != nil {
 		return nil, err
 	}
@@ -182,40 +204,39 @@ func (b *backend) pathSetSignedIntermediate(
 		return nil, fmt.Errorf("verification of parsed bundle failed: %s", err)
 	}
 
-	cb, err = inputBundle.ToCertBundle()
-	if err != nil {
-		return nil, fmt.Errorf("error converting raw values into cert bundle: %s", err)
+	issuerName := data.Get("issuer_name").(string)
+	if err := validateIssuerName(req, issuerName, ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(entry)
+	err = req.Storage.Put(&logical.StorageEntry{
+		Key:   "certs/" + certutil.GetHexFormatted(inputBundle.Certificate.SerialNumber.Bytes(), ":"),
+		Value: inputBundle.CertificateBytes,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	entry.Key = "certs/" + cb.SerialNumber
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(entry)
+	issuer, err := b.addIssuer(req, inputBundle, issuerName, data.Get("set_default").(bool))
 	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(entry)
+	err = req.Storage.Delete("config/intermediate_key")
 	if err != nil {
 		return nil, err
 	}
 
-	// Build a fresh CRL
-	err = buildCRL(b, req)
-
-	return nil, err
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id": issuer.ID,
+		},
+	}, nil
 }
 
 const pathGenerateIntermediateHelpSyn = `
@@ -231,5 +252,9 @@ Provide the signed intermediate CA cert.
 `
 
 const pathSetSignedIntermediateHelpDesc = `
+This adds a new issuer made of the signed certificate and the key previously
+generated by "intermediate/generate". Unless "set_default" is false, the new
+issuer becomes the default one.
+
 See the API documentation for more information.
 `
@@ -72,6 +72,8 @@ taken verbatim from the CSR, except for
 basic constraints.`,
 	}
 
+	ret.Fields = addIssuerRefFields(ret.Fields)
+
 	return ret
 }
 
@@ -125,6 +127,7 @@ func (b *backend) pathSignVerbatim(
 		EnforceHostnames: false,
 		KeyType:          "any",
 		UseCSRCommonName: true,
+		IssuerRef:        data.Get("issuer_ref").(string),
 	}
 
 	return b.pathIssueSignCert(req, data, role, true, true)
@@ -139,7 +142,7 @@ func (b *backend) pathIssueSignCert(
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(req)
+	signingBundle, caErr := fetchCAInfoByRef(req, role.IssuerRef)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -0,0 +1,585 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathIssuerList,
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+func pathIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID or name of the issuer, or "default"`,
+			},
+
+			"issuer_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `New name of the issuer`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerRead,
+			logical.UpdateOperation: b.pathIssuerWrite,
+			logical.DeleteOperation: b.pathIssuerDelete,
+		},
+
+		HelpSynopsis:    pathIssuerHelpSyn,
+		HelpDescription: pathIssuerHelpDesc,
+	}
+}
+
+func pathIssuerGenerateCSR(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref") + "/generate-csr",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID or name of the issuer, or "default"`,
+			},
+
+			"format": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "pem",
+				Description: `Format for returned data. Can be "pem" or "der".
+Defaults to "pem".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssuerGenerateCSR,
+		},
+
+		HelpSynopsis:    pathIssuerGenerateCSRHelpSyn,
+		HelpDescription: pathIssuerGenerateCSRHelpDesc,
+	}
+}
+
+func pathIssuerCrossSigned(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref") + "/cross-signed",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID or name of the issuer, or "default"`,
+			},
+
+			"certificate": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-format CA certificates issued by other CAs
+for the subject and key of the issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssuerCrossSignedWrite,
+			logical.DeleteOperation: b.pathIssuerCrossSignedDelete,
+		},
+
+		HelpSynopsis:    pathIssuerCrossSignedHelpSyn,
+		HelpDescription: pathIssuerCrossSignedHelpDesc,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+		Fields: map[string]*framework.FieldSchema{
+			"default": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID or name of the default issuer`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuersConfigRead,
+			logical.UpdateOperation: b.pathIssuersConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+// Returns the CRL of an issuer in raw format
+func pathFetchIssuerCRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "crl/issuer/" + framework.GenericNameRegex("issuer_ref") + "(/delta)?(/pem)?",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID or name of the issuer, or "default"`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerCRLRead,
+		},
+
+		HelpSynopsis:    pathFetchHelpSyn,
+		HelpDescription: pathFetchHelpDesc,
+	}
+}
+
+func (b *backend) pathIssuerList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	issuers, err := listIssuers(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	keyInfo := map[string]interface{}{}
+	for _, issuer := range issuers {
+		ids = append(ids, issuer.ID)
+		keyInfo[issuer.ID] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"is_default":  issuer.ID == config.Default,
+		}
+	}
+
+	resp := logical.ListResponse(ids)
+	resp.Data["key_info"] = keyInfo
+	return resp, nil
+}
+
+func (b *backend) pathIssuerRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, err := resolveIssuerRef(req, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	caInfo, err := issuer.caInfo(req)
+	if err != nil {
+		return nil, err
+	}
+
+	caChain := []string{}
+	for _, ca := range caInfo.GetCAChain() {
+		caChain = append(caChain, strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: ca.Bytes,
+		}))))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":     issuer.ID,
+			"issuer_name":   issuer.Name,
+			"is_default":    issuer.ID == config.Default,
+			"certificate":   issuer.Bundle.Certificate,
+			"ca_chain":      caChain,
+			"serial_number": issuer.Bundle.SerialNumber,
+			"expiration":    caInfo.Certificate.NotAfter.Unix(),
+
+			"cross_signed_certificates": issuer.CrossSignedCertificates,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuerWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := resolveIssuerRef(req, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", data.Get("issuer_ref").(string))), nil
+	}
+
+	if nameRaw, ok := data.GetOk("issuer_name"); ok {
+		if err := validateIssuerName(req, nameRaw.(string), issuer.ID); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		issuer.Name = nameRaw.(string)
+	}
+
+	if err := storeIssuer(req, issuer); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathIssuerDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := resolveIssuerRef(req, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Delete("issuers/" + issuer.ID); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"crls/" + issuer.ID, "crls/" + issuer.ID + "/delta"} {
+		if err := req.Storage.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if issuer.ID != config.Default {
+		return nil, nil
+	}
+
+	// Without a default issuer, certificates can only be issued by roles
+	// referring to another issuer explicitly
+	entry, err := logical.StorageEntryJSON("config/issuers", &issuersConfig{})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"ca", "crl", "delta-crl"} {
+		if err := req.Storage.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &logical.Response{}
+	resp.AddWarning("The default issuer was deleted; set a new one with the \"config/issuers\" endpoint.")
+	return resp, nil
+}
+
+func (b *backend) pathIssuerGenerateCSR(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	format := data.Get("format").(string)
+	if format != "pem" && format != "der" {
+		return logical.ErrorResponse(`the "format" parameter must be "pem" or "der"`), nil
+	}
+
+	issuer, err := resolveIssuerRef(req, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", data.Get("issuer_ref").(string))), nil
+	}
+
+	parsedBundle, err := issuer.Bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, err
+	}
+	if parsedBundle.PrivateKey == nil {
+		return nil, fmt.Errorf("private key of issuer %s could not be parsed", issuer.ID)
+	}
+
+	// Keep the exact subject of the issuer so that the certificates it
+	// issued chain to the cross-signed certificate
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		RawSubject:     parsedBundle.Certificate.RawSubject,
+		DNSNames:       parsedBundle.Certificate.DNSNames,
+		EmailAddresses: parsedBundle.Certificate.EmailAddresses,
+		IPAddresses:    parsedBundle.Certificate.IPAddresses,
+	}, parsedBundle.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create CSR: %s", err)
+	}
+
+	csr := base64.StdEncoding.EncodeToString(csrBytes)
+	if format == "pem" {
+		csr = strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE REQUEST",
+			Bytes: csrBytes,
+		})))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id": issuer.ID,
+			"csr":       csr,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuerCrossSignedWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := resolveIssuerRef(req, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", data.Get("issuer_ref").(string))), nil
+	}
+
+	parsedBundle, err := issuer.Bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(data.Get("certificate").(string))
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return logical.ErrorResponse(fmt.Sprintf("certificate must only contain certificates, found %q", block.Type)), nil
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("unable to parse certificate: %v", err)), nil
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return logical.ErrorResponse("no certificate provided in the \"certificate\" parameter"), nil
+	}
+
+	for _, cert := range certs {
+		if cert.Equal(parsedBundle.Certificate) {
+			return logical.ErrorResponse("the certificate of the issuer itself cannot be added as cross-signed"), nil
+		}
+		if !cert.IsCA {
+			return logical.ErrorResponse("the given certificate is not marked for CA use"), nil
+		}
+		if !bytes.Equal(cert.RawSubject, parsedBundle.Certificate.RawSubject) {
+			return logical.ErrorResponse("the subject of the given certificate does not match the issuer"), nil
+		}
+		equal, err := certutil.ComparePublicKeys(cert.PublicKey, parsedBundle.Certificate.PublicKey)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("could not compare public keys: %s", err)), nil
+		}
+		if !equal {
+			return logical.ErrorResponse("the public key of the given certificate does not match the issuer"), nil
+		}
+
+		pemCert := strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Raw,
+		})))
+		if !strutil.StrListContains(issuer.CrossSignedCertificates, pemCert) {
+			issuer.CrossSignedCertificates = append(issuer.CrossSignedCertificates, pemCert)
+		}
+	}
+
+	if err := storeIssuer(req, issuer); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathIssuerCrossSignedDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := resolveIssuerRef(req, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	issuer.CrossSignedCertificates = nil
+	if err := storeIssuer(req, issuer); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathIssuersConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := fetchIssuersConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.Default,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuersConfigWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	ref := data.Get("default").(string)
+	if ref == "" || ref == defaultIssuerRef {
+		return logical.ErrorResponse("the ID or name of the default issuer must be provided"), nil
+	}
+
+	issuer, err := resolveIssuerRef(req, ref)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", ref)), nil
+	}
+
+	if err := setDefaultIssuer(req, issuer); err != nil {
+		return nil, err
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	return nil, buildCRL(b, req)
+}
+
+func (b *backend) pathFetchIssuerCRLRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, err := resolveIssuerRef(req, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", data.Get("issuer_ref").(string))), nil
+	}
+
+	key := "crls/" + issuer.ID
+	if strings.HasSuffix(strings.TrimSuffix(req.Path, "/pem"), "/delta") {
+		key += "/delta"
+	}
+
+	entry, err := req.Storage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var crl []byte
+	if entry != nil {
+		crl = entry.Value
+	}
+
+	if strings.HasSuffix(req.Path, "/pem") && len(crl) > 0 {
+		crl = pem.EncodeToMemory(&pem.Block{
+			Type:  "X509 CRL",
+			Bytes: crl,
+		})
+	}
+
+	statusCode := 200
+	if len(crl) == 0 {
+		statusCode = 204
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/pkix-crl",
+			logical.HTTPRawBody:     crl,
+			logical.HTTPStatusCode:  statusCode,
+		},
+	}, nil
+}
+
+const pathListIssuersHelpSyn = `
+List the issuers of this backend.
+`
+
+const pathListIssuersHelpDesc = `
+Issuers are listed by ID; the "key_info" field holds their name and whether
+they are the default issuer.
+`
+
+const pathIssuerHelpSyn = `
+Read, rename or delete an issuer.
+`
+
+const pathIssuerHelpDesc = `
+An issuer is a CA certificate and private key of this backend. Issuers are
+created by the "root/generate", "root/rotate", "config/ca" and
+"intermediate/set-signed" endpoints, and can be referred to by their ID, their
+name or, for the default one, "default".
+
+Roles select the issuer signing their certificates with "issuer_ref". Every
+issuer keeps its own CRL, available at "crl/issuer/<issuer_ref>", and answers
+OCSP requests about its certificates, so that the certificates of a previous
+CA can still be revoked while it is being rotated out.
+`
+
+const pathIssuerGenerateCSRHelpSyn = `
+Generate a CSR for the key of an issuer.
+`
+
+const pathIssuerGenerateCSRHelpDesc = `
+This returns a CSR signed by the private key of the issuer, with the subject
+of its certificate, so that another CA can cross-sign the issuer without its
+key leaving the backend. The resulting certificate is then added to the chain
+of the issuer with the "issuer/<issuer_ref>/cross-signed" endpoint.
+`
+
+const pathIssuerCrossSignedHelpSyn = `
+Add or remove the cross-signed certificates of an issuer.
+`
+
+const pathIssuerCrossSignedHelpDesc = `
+Writing to this endpoint adds certificates issued by other CAs for the
+subject and key of the issuer, usually from a CSR generated with the
+"issuer/<issuer_ref>/generate-csr" endpoint. They are appended to the CA chain
+of the issuer and of the certificates it issues, so that clients trusting the
+other CAs can validate them. Deleting this endpoint removes all of them.
+`
+
+const pathConfigIssuersHelpSyn = `
+Configure the default issuer of this backend.
+`
+
+const pathConfigIssuersHelpDesc = `
+The default issuer signs the certificates of the roles not bound to a specific
+issuer. Its certificate is served at the "ca" endpoint, and it signs the CRL
+served at the "crl" endpoint, which lists the certificates revoked in this
+backend regardless of their issuer.
+`
@@ -0,0 +1,349 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_IssuerRotation(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCert := func(pemCert string) *x509.Certificate {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			t.Fatalf("bad certificate: %q", pemCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	crlSerials := func(path string) map[string]bool {
+		resp := request(logical.ReadOperation, path, nil)
+		crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		serials := map[string]bool{}
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			serials[certutil.GetHexFormatted(revoked.SerialNumber.Bytes(), ":")] = true
+		}
+		return serials
+	}
+
+	resp := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+		"issuer_name": "old",
+	})
+	oldRoot := parseCert(resp.Data["certificate"].(string))
+	oldID := resp.Data["issuer_id"].(string)
+
+	// Rotating keeps the existing default issuer
+	resp = request(logical.UpdateOperation, "root/rotate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+		"issuer_name": "new",
+	})
+	newRoot := parseCert(resp.Data["certificate"].(string))
+	newID := resp.Data["issuer_id"].(string)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/rotate/internal",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name": "Vault Test Root",
+			"issuer_name": "new",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error reusing an issuer name, got: %v, %#v", err, resp)
+	}
+
+	resp = request(logical.ListOperation, "issuers/", nil)
+	keyInfo := resp.Data["key_info"].(map[string]interface{})
+	if len(resp.Data["keys"].([]string)) != 2 ||
+		!keyInfo[oldID].(map[string]interface{})["is_default"].(bool) ||
+		keyInfo[newID].(map[string]interface{})["issuer_name"] != "new" {
+		t.Fatalf("bad: issuers: %#v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/bad",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"issuer_ref": "missing",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for an unknown issuer, got: %v, %#v", err, resp)
+	}
+
+	for role, issuerRef := range map[string]string{"current": "default", "next": "new"} {
+		request(logical.UpdateOperation, "roles/"+role, map[string]interface{}{
+			"allowed_domains":  "example.com",
+			"allow_subdomains": true,
+			"ttl":              "1h",
+			"issuer_ref":       issuerRef,
+		})
+	}
+
+	// Both issuers sign certificates concurrently
+	resp = request(logical.UpdateOperation, "issue/current", map[string]interface{}{
+		"common_name": "old.example.com",
+	})
+	oldCert := parseCert(resp.Data["certificate"].(string))
+	oldSerial := resp.Data["serial_number"].(string)
+	if err := oldCert.CheckSignatureFrom(oldRoot); err != nil {
+		t.Fatalf("certificate not signed by the default issuer: %v", err)
+	}
+
+	resp = request(logical.UpdateOperation, "issue/next", map[string]interface{}{
+		"common_name": "new.example.com",
+	})
+	newCert := parseCert(resp.Data["certificate"].(string))
+	newSerial := resp.Data["serial_number"].(string)
+	if err := newCert.CheckSignatureFrom(newRoot); err != nil {
+		t.Fatalf("certificate not signed by the role issuer: %v", err)
+	}
+
+	// Each issuer lists the certificates it signed on its CRL
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{"serial_number": oldSerial})
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{"serial_number": newSerial})
+
+	if serials := crlSerials("crl/issuer/old"); len(serials) != 1 || !serials[oldSerial] {
+		t.Fatalf("bad: CRL of the old issuer: %v", serials)
+	}
+	if serials := crlSerials("crl/issuer/" + newID + "/pem"); len(serials) != 1 || !serials[newSerial] {
+		t.Fatalf("bad: CRL of the new issuer: %v", serials)
+	}
+	if serials := crlSerials("crl"); len(serials) != 2 {
+		t.Fatalf("bad: CRL of the backend: %v", serials)
+	}
+
+	// Switch to the new issuer
+	request(logical.UpdateOperation, "config/issuers", map[string]interface{}{
+		"default": "new",
+	})
+	resp = request(logical.ReadOperation, "ca", nil)
+	if string(resp.Data[logical.HTTPRawBody].([]byte)) != string(newRoot.Raw) {
+		t.Fatalf("the CA certificate is not the one of the new default issuer")
+	}
+	resp = request(logical.UpdateOperation, "issue/current", map[string]interface{}{
+		"common_name": "current.example.com",
+	})
+	if err := parseCert(resp.Data["certificate"].(string)).CheckSignatureFrom(newRoot); err != nil {
+		t.Fatalf("certificate not signed by the new default issuer: %v", err)
+	}
+
+	request(logical.UpdateOperation, "issuer/old", map[string]interface{}{
+		"issuer_name": "retired",
+	})
+	resp = request(logical.ReadOperation, "issuer/retired", nil)
+	if resp.Data["issuer_id"] != oldID || resp.Data["is_default"].(bool) {
+		t.Fatalf("bad: issuer: %#v", resp.Data)
+	}
+
+	request(logical.DeleteOperation, "issuer/retired", nil)
+	resp = request(logical.ListOperation, "issuers/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != newID {
+		t.Fatalf("bad: issuers: %#v", resp.Data)
+	}
+}
+
+func TestPki_IssuerLegacyMigration(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/exported",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name": "Vault Test Root",
+			"ttl":         "24h",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// Lay the storage out like older versions with a single CA bundle
+	cb := &certutil.CertBundle{
+		Certificate:    resp.Data["certificate"].(string),
+		PrivateKey:     resp.Data["private_key"].(string),
+		PrivateKeyType: resp.Data["private_key_type"].(certutil.PrivateKeyType),
+		SerialNumber:   resp.Data["serial_number"].(string),
+	}
+	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"config/issuers", "issuers/" + resp.Data["issuer_id"].(string)} {
+		if err := storage.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "issuer/default",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["certificate"] != cb.Certificate || !resp.Data["is_default"].(bool) {
+		t.Fatalf("bad: migrated issuer: %#v", resp.Data)
+	}
+
+	entry, err = storage.Get("config/ca_bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatalf("the legacy CA bundle was not removed")
+	}
+}
+
+func TestPki_IssuerCrossSigning(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	otherB, otherStorage := createBackendWithStorage(t)
+
+	request := func(b *backend, storage logical.Storage, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCert := func(pemCert string) *x509.Certificate {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			t.Fatalf("bad certificate: %q", pemCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	resp := request(b, storage, logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+		"issuer_name": "root",
+	})
+	root := parseCert(resp.Data["certificate"].(string))
+	resp = request(otherB, otherStorage, logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Other Root",
+		"ttl":         "48h",
+	})
+	otherRootPEM := resp.Data["certificate"].(string)
+	otherRoot := parseCert(otherRootPEM)
+
+	// The CSR is signed by the key of the issuer, with its subject
+	resp = request(b, storage, logical.UpdateOperation, "issuer/root/generate-csr", nil)
+	block, _ := pem.Decode([]byte(resp.Data["csr"].(string)))
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csr.CheckSignature() != nil || !bytes.Equal(csr.RawSubject, root.RawSubject) {
+		t.Fatalf("bad: CSR: %#v", csr.Subject)
+	}
+	equal, err := certutil.ComparePublicKeys(csr.PublicKey, root.PublicKey)
+	if err != nil || !equal {
+		t.Fatalf("bad: CSR key: %v", err)
+	}
+
+	resp = request(otherB, otherStorage, logical.UpdateOperation, "root/sign-intermediate", map[string]interface{}{
+		"csr":            resp.Data["csr"],
+		"use_csr_values": true,
+		"ttl":            "12h",
+	})
+	crossSignedPEM := resp.Data["certificate"].(string)
+
+	for _, certificate := range []string{otherRootPEM, "not a certificate"} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "issuer/root/cross-signed",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"certificate": certificate,
+			},
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error adding %q, got: %v, %#v", certificate, err, resp)
+		}
+	}
+	request(b, storage, logical.UpdateOperation, "issuer/root/cross-signed", map[string]interface{}{
+		"certificate": crossSignedPEM,
+	})
+
+	resp = request(b, storage, logical.ReadOperation, "issuer/root", nil)
+	if crossSigned := resp.Data["cross_signed_certificates"].([]string); len(crossSigned) != 1 || crossSigned[0] != strings.TrimSpace(crossSignedPEM) {
+		t.Fatalf("bad: cross-signed certificates: %#v", resp.Data["cross_signed_certificates"])
+	}
+
+	// Clients trusting the other root validate the certificates of the
+	// issuer through the cross-signed certificate of the chain
+	request(b, storage, logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	resp = request(b, storage, logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	leaf := parseCert(resp.Data["certificate"].(string))
+	intermediates := x509.NewCertPool()
+	for _, pemCert := range resp.Data["ca_chain"].([]string) {
+		intermediates.AddCert(parseCert(pemCert))
+	}
+	for _, trusted := range []*x509.Certificate{root, otherRoot} {
+		roots := x509.NewCertPool()
+		roots.AddCert(trusted)
+		if _, err := leaf.Verify(x509.VerifyOptions{
+			DNSName:       "www.example.com",
+			Roots:         roots,
+			Intermediates: intermediates,
+		}); err != nil {
+			t.Fatalf("verification with root %s failed: %v", trusted.Subject.CommonName, err)
+		}
+	}
+
+	request(b, storage, logical.DeleteOperation, "issuer/root/cross-signed", nil)
+	resp = request(b, storage, logical.ReadOperation, "issuer/root", nil)
+	if len(resp.Data["cross_signed_certificates"].([]string)) != 0 {
+		t.Fatalf("bad: cross-signed certificates: %#v", resp.Data["cross_signed_certificates"])
+	}
+}
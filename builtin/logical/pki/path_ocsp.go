@@ -0,0 +1,406 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspConfig holds the configuration of the OCSP responder of the backend
+type ocspConfig struct {
+	// ResponderBundle holds the certificate and key of a delegated
+	// responder; responses are signed by the CA when it is not set
+	ResponderBundle *certutil.CertBundle `json:"responder_bundle" mapstructure:"responder_bundle" structs:"responder_bundle"`
+	NextUpdate      string               `json:"next_update" mapstructure:"next_update" structs:"next_update"`
+}
+
+func pathConfigOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ocsp",
+		Fields: map[string]*framework.FieldSchema{
+			"pem_bundle": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-format, concatenated unencrypted secret
+key and certificate of a delegated responder. The
+certificate must be issued by an issuer of this
+backend for the OCSP signing extended key usage.
+It only signs the responses about the certificates
+of its issuer. If empty, responses are signed by
+the issuers.`,
+			},
+
+			"next_update": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How long clients may cache the responses;
+defaults to 12 hours. If "0", responses carry no
+next update time.`,
+				Default: "12h",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathOCSPConfigRead,
+			logical.UpdateOperation: b.pathOCSPConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigOCSPHelpSyn,
+		HelpDescription: pathConfigOCSPHelpDesc,
+	}
+}
+
+func pathOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathOCSPPost,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func pathOCSPGet(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp/(?P<request>.+)",
+		Fields: map[string]*framework.FieldSchema{
+			"request": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Base64 encoded DER OCSP request`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathOCSPRead,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func (b *backend) OCSPConfig(s logical.Storage) (*ocspConfig, error) {
+	entry, err := s.Get("config/ocsp")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result ocspConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathOCSPConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.OCSPConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"next_update": config.NextUpdate,
+		},
+	}
+	if config.ResponderBundle != nil {
+		resp.Data["certificate"] = config.ResponderBundle.Certificate
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathOCSPConfigWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config := &ocspConfig{
+		NextUpdate: data.Get("next_update").(string),
+	}
+
+	if _, err := time.ParseDuration(config.NextUpdate); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given next_update could not be decoded: %s", err)), nil
+	}
+
+	if pemBundle := data.Get("pem_bundle").(string); pemBundle != "" {
+		parsedBundle, err := certutil.ParsePEMBundle(pemBundle)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if parsedBundle.PrivateKey == nil || parsedBundle.Certificate == nil {
+			return logical.ErrorResponse("the PEM bundle must contain the responder certificate and private key"), nil
+		}
+
+		issuers, err := listIssuers(req)
+		if err != nil {
+			return nil, err
+		}
+		issued := false
+		for _, issuer := range issuers {
+			caInfo, err := issuer.caInfo(req)
+			if err != nil {
+				return nil, err
+			}
+			if parsedBundle.Certificate.CheckSignatureFrom(caInfo.Certificate) == nil {
+				issued = true
+			}
+		}
+		if !issued {
+			return logical.ErrorResponse("the responder certificate is not issued by an issuer of this backend"), nil
+		}
+
+		ocspSigning := false
+		for _, usage := range parsedBundle.Certificate.ExtKeyUsage {
+			if usage == x509.ExtKeyUsageOCSPSigning {
+				ocspSigning = true
+			}
+		}
+		if !ocspSigning {
+			return logical.ErrorResponse("the responder certificate does not allow OCSP signing"), nil
+		}
+
+		config.ResponderBundle, err = parsedBundle.ToCertBundle()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config/ocsp", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathOCSPRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, err := base64.StdEncoding.DecodeString(data.Get("request").(string))
+	if err != nil {
+		return ocspRawResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	return b.answerOCSPRequest(req, der), nil
+}
+
+func (b *backend) pathOCSPPost(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok {
+		return ocspRawResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	return b.answerOCSPRequest(req, der), nil
+}
+
+// answerOCSPRequest builds the response to a DER encoded OCSP request.
+// Failures are reported to the client through the status of the OCSP
+// response rather than as Vault errors, as OCSP clients can't parse those.
+func (b *backend) answerOCSPRequest(req *logical.Request, der []byte) *logical.Response {
+	// Only the first certificate of a request is answered
+	ocspReq, err := ocsp.ParseRequest(der)
+	if err != nil {
+		return ocspRawResponse(ocsp.MalformedRequestErrorResponse)
+	}
+
+	// The response is signed by the issuer of the certificate; without a CA,
+	// or for certificates of other issuers, this backend isn't authoritative
+	caInfo, err := fetchCAInfo(req)
+	if err != nil {
+		return ocspRawResponse(ocsp.UnauthorizedErrorResponse)
+	}
+	issuers, err := listIssuers(req)
+	if err != nil {
+		b.Logger().Error("error fetching the issuers", "error", err)
+		return ocspRawResponse(ocsp.InternalErrorErrorResponse)
+	}
+	for _, issuer := range issuers {
+		issuerInfo, err := issuer.caInfo(req)
+		if err != nil {
+			b.Logger().Error("error parsing the issuer", "issuer_id", issuer.ID, "error", err)
+			return ocspRawResponse(ocsp.InternalErrorErrorResponse)
+		}
+		if ocspMatchesIssuer(ocspReq, issuerInfo.Certificate) {
+			caInfo = issuerInfo
+			break
+		}
+	}
+	if !ocspMatchesIssuer(ocspReq, caInfo.Certificate) {
+		return ocspRawResponse(ocsp.UnauthorizedErrorResponse)
+	}
+
+	config, err := b.OCSPConfig(req.Storage)
+	if err != nil {
+		b.Logger().Error("error fetching the OCSP configuration", "error", err)
+		return ocspRawResponse(ocsp.InternalErrorErrorResponse)
+	}
+
+	responder := caInfo.Certificate
+	var key crypto.Signer = caInfo.PrivateKey
+	nextUpdate := 12 * time.Hour
+	if config != nil {
+		if config.ResponderBundle != nil {
+			parsedBundle, err := config.ResponderBundle.ToParsedCertBundle()
+			if err != nil {
+				b.Logger().Error("error parsing the OCSP responder bundle", "error", err)
+				return ocspRawResponse(ocsp.InternalErrorErrorResponse)
+			}
+			// A delegated responder can only sign for its own issuer
+			if issuedBy(parsedBundle.Certificate, caInfo.Certificate) {
+				responder = parsedBundle.Certificate
+				key = parsedBundle.PrivateKey
+			}
+		}
+		if nextUpdate, err = time.ParseDuration(config.NextUpdate); err != nil {
+			b.Logger().Error("error parsing the OCSP next update", "error", err)
+			return ocspRawResponse(ocsp.InternalErrorErrorResponse)
+		}
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	template, err := certStatus(req, ocspReq.SerialNumber)
+	if err != nil {
+		b.Logger().Error("error fetching the certificate status", "error", err)
+		return ocspRawResponse(ocsp.InternalErrorErrorResponse)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	template.ThisUpdate = now
+	if nextUpdate > 0 {
+		template.NextUpdate = now.Add(nextUpdate)
+	}
+	template.IssuerHash = ocspReq.HashAlgorithm
+	// Delegated responders include their certificate in the response
+	if !bytes.Equal(responder.Raw, caInfo.Certificate.Raw) {
+		template.Certificate = responder
+	}
+
+	response, err := ocsp.CreateResponse(caInfo.Certificate, responder, template, key)
+	if err != nil {
+		b.Logger().Error("error creating the OCSP response", "error", err)
+		return ocspRawResponse(ocsp.InternalErrorErrorResponse)
+	}
+
+	return ocspRawResponse(response)
+}
+
+// ocspMatchesIssuer returns true if the request is for a certificate issued
+// by the given CA
+func ocspMatchesIssuer(ocspReq *ocsp.Request, issuer *x509.Certificate) bool {
+	if !ocspReq.HashAlgorithm.Available() {
+		return false
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+
+	h := ocspReq.HashAlgorithm.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	return bytes.Equal(nameHash, ocspReq.IssuerNameHash) && bytes.Equal(keyHash, ocspReq.IssuerKeyHash)
+}
+
+// certStatus looks up the status of the certificate with the given serial
+// in the revocation storage. The caller must hold the revocation lock.
+func certStatus(req *logical.Request, serialNumber *big.Int) (ocsp.Response, error) {
+	status := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: serialNumber,
+	}
+
+	serial := certutil.GetHexFormatted(serialNumber.Bytes(), ":")
+	revokedEntry, err := fetchCertBySerial(req, "revoked/", serial)
+	if err != nil {
+		return status, err
+	}
+	if revokedEntry != nil {
+		var revInfo revocationInfo
+		if err := revokedEntry.DecodeJSON(&revInfo); err != nil {
+			return status, fmt.Errorf("error decoding revocation entry for serial %s: %v", serial, err)
+		}
+		status.Status = ocsp.Revoked
+		status.RevokedAt = time.Unix(revInfo.RevocationTime, 0)
+		return status, nil
+	}
+
+	certEntry, err := fetchCertBySerial(req, "certs/", serial)
+	if err != nil {
+		return status, err
+	}
+	if certEntry != nil {
+		status.Status = ocsp.Good
+	}
+
+	return status, nil
+}
+
+func ocspRawResponse(body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPContentType: "application/ocsp-response",
+			logical.HTTPRawBody:     body,
+		},
+	}
+}
+
+const pathConfigOCSPHelpSyn = `
+Configure the OCSP responder of this backend.
+`
+
+const pathConfigOCSPHelpDesc = `
+This endpoint configures how long the OCSP responses may be cached and,
+optionally, a delegated responder certificate used to sign them instead of
+the CA.
+`
+
+const pathOCSPHelpSyn = `
+Query the revocation status of certificates over OCSP.
+`
+
+const pathOCSPHelpDesc = `
+This endpoint implements an OCSP responder (RFC 6960) for the certificates
+issued by this backend. Requests are accepted either as the DER body of a
+POST with the "application/ocsp-request" content type, or base64 encoded in
+the path of a GET request. Only the first certificate of a request is
+answered, and nonces are not echoed.
+
+Certificates unknown to the backend are reported with the "unknown" status.
+Requests for certificates of other issuers are answered with the
+"unauthorized" error status.
+`
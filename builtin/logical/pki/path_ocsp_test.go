@@ -0,0 +1,190 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspTestRequest builds a DER OCSP request for the given serial issued by
+// the given CA
+func ocspTestRequest(t *testing.T, issuer *x509.Certificate, serial *big.Int) []byte {
+	der, err := ocsp.CreateRequest(&x509.Certificate{SerialNumber: serial}, issuer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// ocspTestResponse decodes an OCSP response, verifying that it is signed for
+// the issuer by the given responder
+func ocspTestResponse(t *testing.T, resp *logical.Response, issuer, responder *x509.Certificate) *ocsp.Response {
+	if resp.Data[logical.HTTPContentType] != "application/ocsp-response" {
+		t.Fatalf("bad: content type: %v", resp.Data[logical.HTTPContentType])
+	}
+
+	ocspResp, err := ocsp.ParseResponse(resp.Data[logical.HTTPRawBody].([]byte), issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case responder.Equal(issuer) && ocspResp.Certificate != nil:
+		t.Fatalf("bad: responder certificate included: %#v", ocspResp.Certificate)
+	case !responder.Equal(issuer) && (ocspResp.Certificate == nil || !ocspResp.Certificate.Equal(responder)):
+		t.Fatalf("bad: responder certificate: %#v", ocspResp.Certificate)
+	}
+
+	return ocspResp
+}
+
+func TestPki_OCSP(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// Malformed requests are reported in the OCSP response
+	resp := request(logical.ReadOperation, "ocsp/bm90IGFuIE9DU1AgcmVxdWVzdA==", nil)
+	if !bytes.Equal(resp.Data[logical.HTTPRawBody].([]byte), ocsp.MalformedRequestErrorResponse) {
+		t.Fatalf("bad: response: %#v", resp)
+	}
+
+	resp = request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Vault OCSP Test CA",
+		"ttl":         "24h",
+	})
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request(logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	resp = request(logical.UpdateOperation, "issue/web", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	serial := resp.Data["serial_number"].(string)
+	block, _ = pem.Decode([]byte(resp.Data["certificate"].(string)))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// GET requests carry the request in the path
+	resp = request(logical.ReadOperation, "ocsp/"+base64.StdEncoding.EncodeToString(ocspTestRequest(t, caCert, cert.SerialNumber)), nil)
+	single := ocspTestResponse(t, resp, caCert, caCert)
+	if single.Status != ocsp.Good || single.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Fatalf("bad: expected good status: %#v", single)
+	}
+	if single.NextUpdate.Sub(single.ThisUpdate) != 12*time.Hour {
+		t.Fatalf("bad: next update: %v", single.NextUpdate)
+	}
+
+	resp = request(logical.UpdateOperation, "ocsp", map[string]interface{}{
+		logical.HTTPRawBody: ocspTestRequest(t, caCert, big.NewInt(42)),
+	})
+	if single := ocspTestResponse(t, resp, caCert, caCert); single.Status != ocsp.Unknown {
+		t.Fatalf("bad: expected unknown status: %#v", single)
+	}
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+	resp = request(logical.UpdateOperation, "ocsp", map[string]interface{}{
+		logical.HTTPRawBody: ocspTestRequest(t, caCert, cert.SerialNumber),
+	})
+	single = ocspTestResponse(t, resp, caCert, caCert)
+	if single.Status != ocsp.Revoked || single.RevokedAt.IsZero() {
+		t.Fatalf("bad: expected revoked status: %#v", single)
+	}
+
+	// Delegate the signing of the responses to a responder certificate
+	caInfo, err := fetchCAInfo(&logical.Request{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responderDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1000),
+		Subject:      pkix.Name{CommonName: "Vault OCSP Responder"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, caCert, responderKey.Public(), caInfo.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responderCert, err := x509.ParseCertificate(responderDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(responderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request(logical.UpdateOperation, "config/ocsp", map[string]interface{}{
+		"pem_bundle": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})) +
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: responderDER})),
+		"next_update": "1h",
+	})
+	resp = request(logical.UpdateOperation, "ocsp", map[string]interface{}{
+		logical.HTTPRawBody: ocspTestRequest(t, caCert, cert.SerialNumber),
+	})
+	single = ocspTestResponse(t, resp, caCert, responderCert)
+	if single.Status != ocsp.Revoked || single.NextUpdate.Sub(single.ThisUpdate) != time.Hour {
+		t.Fatalf("bad: expected revoked status: %#v", single)
+	}
+
+	// The CA itself can't be configured as a delegated responder
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/ocsp",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"pem_bundle": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})) +
+				string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})),
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error configuring the CA as responder, got: %v, %#v", err, resp)
+	}
+
+	// Without a CA the backend isn't authoritative
+	b, storage = createBackendWithStorage(t)
+	resp = request(logical.UpdateOperation, "ocsp", map[string]interface{}{
+		logical.HTTPRawBody: ocspTestRequest(t, caCert, cert.SerialNumber),
+	})
+	if !bytes.Equal(resp.Data[logical.HTTPRawBody].([]byte), ocsp.UnauthorizedErrorResponse) {
+		t.Fatalf("bad: response: %#v", resp)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -137,8 +138,8 @@ protection use. Defaults to false.`,
 			"key_type": &framework.FieldSchema{
 				Type:    framework.TypeString,
 				Default: "rsa",
-				Description: `The type of key to use; defaults to RSA. "rsa"
-and "ec" are the only valid values.`,
+				Description: `The type of key to use; defaults to RSA. "rsa",
+"ec" and "ed25519" are the only valid values.`,
 			},
 
 			"key_bits": &framework.FieldSchema{
@@ -146,7 +147,7 @@ and "ec" are the only valid values.`,
 				Default: 2048,
 				Description: `The number of bits to use. You will almost
 certainly want to change this if you adjust
-the key_type.`,
+the key_type. Ignored for "ed25519" keys.`,
 			},
 
 			"key_usage": &framework.FieldSchema{
@@ -160,6 +161,33 @@ To remove all key usages from being set, set
 this value to an empty string.`,
 			},
 
+			"ext_key_usage": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `A comma-separated set of extended key usages,
+in addition to the ones set by the flags. Valid
+values can be found at
+https://golang.org/pkg/crypto/x509/#ExtKeyUsage
+-- simply drop the "ExtKeyUsage" part of the name.`,
+			},
+
+			"ext_key_usage_oids": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `A comma-separated set of extended key usage
+OIDs, for the usages without a name.`,
+			},
+
+			"allowed_extensions": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `A comma-separated set of extension OIDs that may
+be supplied in the "extensions" parameter of
+issue and sign requests. The extensions built
+by the backend, such as the key usages and the
+subject alternative names, cannot be allowed.`,
+			},
+
 			"use_csr_common_name": &framework.FieldSchema{
 				Type:    framework.TypeBool,
 				Default: true,
@@ -196,6 +224,14 @@ to the CRL.  When large number of certificates are generated with long
 lifetimes, it is recommended that lease generation be disabled, as large amount of
 leases adversely affect the startup time of Vault.`,
 			},
+
+			"issuer_ref": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: defaultIssuerRef,
+				Description: `ID or name of the issuer signing the certificates
+of this role. Defaults to the default issuer of
+the backend.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -272,6 +308,13 @@ func (b *backend) getRole(s logical.Storage, n string) (*roleEntry, error) {
 		modified = true
 	}
 
+	// Roles created before multiple issuers were supported use the default
+	// one
+	if result.IssuerRef == "" {
+		result.IssuerRef = defaultIssuerRef
+		modified = true
+	}
+
 	if modified {
 		jsonEntry, err := logical.StorageEntryJSON("role/"+n, &result)
 		if err != nil {
@@ -372,9 +415,13 @@ func (b *backend) pathRoleCreate(
 		KeyBits:             data.Get("key_bits").(int),
 		UseCSRCommonName:    data.Get("use_csr_common_name").(bool),
 		KeyUsage:            data.Get("key_usage").(string),
+		ExtKeyUsage:         data.Get("ext_key_usage").(string),
+		ExtKeyUsageOIDs:     data.Get("ext_key_usage_oids").(string),
+		AllowedExtensions:   data.Get("allowed_extensions").(string),
 		OU:                  data.Get("ou").(string),
 		Organization:        data.Get("organization").(string),
 		GenerateLease:       new(bool),
+		IssuerRef:           data.Get("issuer_ref").(string),
 	}
 
 	*entry.GenerateLease = data.Get("generate_lease").(bool)
@@ -426,6 +473,39 @@ func (b *backend) pathRoleCreate(
 		return errResp, nil
 	}
 
+	if _, err := parseExtKeyUsages(entry.ExtKeyUsage); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if _, err := parseOIDs(entry.ExtKeyUsageOIDs); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Store the allowed extensions in canonical form, as they are compared
+	// with the OIDs of the requests
+	allowedExtensions, err := parseOIDs(entry.AllowedExtensions)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	var allowed []string
+	for _, oid := range allowedExtensions {
+		if strutil.StrListContains(reservedExtensionOIDs, oid.String()) {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"extension %s is set by the backend and cannot be allowed", oid)), nil
+		}
+		allowed = append(allowed, oid.String())
+	}
+	entry.AllowedExtensions = strings.Join(allowed, ",")
+
+	if entry.IssuerRef != defaultIssuerRef {
+		issuer, err := resolveIssuerRef(req, entry.IssuerRef)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", entry.IssuerRef)), nil
+		}
+	}
+
 	// Store it
 	jsonEntry, err := logical.StorageEntryJSON("role/"+name, entry)
 	if err != nil {
@@ -467,6 +547,38 @@ func parseKeyUsages(input string) int {
 	return int(parsedKeyUsages)
 }
 
+// extKeyUsageNames maps the names of the extended key usages known to the
+// crypto/x509 of Go 1.8 to their values; the others are given by OID
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"any":                        x509.ExtKeyUsageAny,
+	"serverauth":                 x509.ExtKeyUsageServerAuth,
+	"clientauth":                 x509.ExtKeyUsageClientAuth,
+	"codesigning":                x509.ExtKeyUsageCodeSigning,
+	"emailprotection":            x509.ExtKeyUsageEmailProtection,
+	"ipsecendsystem":             x509.ExtKeyUsageIPSECEndSystem,
+	"ipsectunnel":                x509.ExtKeyUsageIPSECTunnel,
+	"ipsecuser":                  x509.ExtKeyUsageIPSECUser,
+	"timestamping":               x509.ExtKeyUsageTimeStamping,
+	"ocspsigning":                x509.ExtKeyUsageOCSPSigning,
+	"microsoftservergatedcrypto": x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	"netscapeservergatedcrypto":  x509.ExtKeyUsageNetscapeServerGatedCrypto,
+}
+
+// parseExtKeyUsages parses a comma-separated set of extended key usage names;
+// unlike key usages, unknown names are rejected
+func parseExtKeyUsages(input string) ([]x509.ExtKeyUsage, error) {
+	var parsedExtKeyUsages []x509.ExtKeyUsage
+	for _, k := range strutil.ParseDedupAndSortStrings(input, ",") {
+		usage, ok := extKeyUsageNames[strings.ToLower(k)]
+		if !ok {
+			return nil, fmt.Errorf("unknown extended key usage %q", k)
+		}
+		parsedExtKeyUsages = append(parsedExtKeyUsages, usage)
+	}
+
+	return parsedExtKeyUsages, nil
+}
+
 type roleEntry struct {
 	LeaseMax              string `json:"lease_max" structs:"lease_max" mapstructure:"lease_max"`
 	Lease                 string `json:"lease" structs:"lease" mapstructure:"lease"`
@@ -491,9 +603,16 @@ type roleEntry struct {
 	KeyBits               int    `json:"key_bits" structs:"key_bits" mapstructure:"key_bits"`
 	MaxPathLength         *int   `json:",omitempty" structs:"max_path_length,omitempty" mapstructure:"max_path_length"`
 	KeyUsage              string `json:"key_usage" structs:"key_usage" mapstructure:"key_usage"`
+	ExtKeyUsage           string `json:"ext_key_usage" structs:"ext_key_usage" mapstructure:"ext_key_usage"`
+	ExtKeyUsageOIDs       string `json:"ext_key_usage_oids" structs:"ext_key_usage_oids" mapstructure:"ext_key_usage_oids"`
+	AllowedExtensions     string `json:"allowed_extensions" structs:"allowed_extensions" mapstructure:"allowed_extensions"`
 	OU                    string `json:"ou" structs:"ou" mapstructure:"ou"`
 	Organization          string `json:"organization" structs:"organization" mapstructure:"organization"`
 	GenerateLease         *bool  `json:"generate_lease,omitempty" structs:"generate_lease,omitempty"`
+	IssuerRef             string `json:"issuer_ref" structs:"issuer_ref" mapstructure:"issuer_ref"`
+
+	// Only set on the roles built to generate or sign CA certificates
+	CAConstraints *caConstraints `json:"-" structs:"-" mapstructure:"-"`
 }
 
 const pathListRolesHelpSyn = `List the existing roles in this backend`
@@ -24,6 +24,27 @@ func pathGenerateRoot(b *backend) *framework.Path {
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameFields(ret.Fields)
+
+	return ret
+}
+
+func pathRotateRoot(b *backend) *framework.Path {
+	ret := &framework.Path{
+		Pattern: "root/rotate/" + framework.GenericNameRegex("exported"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCARotateRoot,
+		},
+
+		HelpSynopsis:    pathRotateRootHelpSyn,
+		HelpDescription: pathRotateRootHelpDesc,
+	}
+
+	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
+	ret.Fields = addCAKeyGenerationFields(ret.Fields)
+	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameFields(ret.Fields)
 
 	return ret
 }
@@ -42,6 +63,7 @@ func pathSignIntermediate(b *backend) *framework.Path {
 
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerRefFields(ret.Fields)
 
 	ret.Fields["csr"] = &framework.FieldSchema{
 		Type:        framework.TypeString,
@@ -68,6 +90,18 @@ the non-repudiation flag.`,
 
 func (b *backend) pathCAGenerateRoot(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.generateRoot(req, data, true)
+}
+
+// pathCARotateRoot generates a new root issuer without replacing the default
+// one, so that it can be distributed to clients before being used
+func (b *backend) pathCARotateRoot(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.generateRoot(req, data, false)
+}
+
+func (b *backend) generateRoot(
+	req *logical.Request, data *framework.FieldData, setDefault bool) (*logical.Response, error) {
 	var err error
 
 	exported, format, role, errorResp := b.getGenerationParams(data)
@@ -75,12 +109,21 @@ func (b *backend) pathCAGenerateRoot(
 		return errorResp, nil
 	}
 
+	if err := validateIssuerName(req, data.Get("issuer_name").(string), ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	maxPathLengthIface, ok := data.GetOk("max_path_length")
 	if ok {
 		maxPathLength := maxPathLengthIface.(int)
 		role.MaxPathLength = &maxPathLength
 	}
 
+	role.CAConstraints, err = parseCAConstraints(data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	parsedBundle, err := generateCert(b, role, nil, true, req, data)
 	if err != nil {
 		switch err.(type) {
@@ -132,16 +175,6 @@ func (b *backend) pathCAGenerateRoot(
 		}
 	}
 
-	// Store it as the CA bundle
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(entry)
-	if err != nil {
-		return nil, err
-	}
-
 	// Also store it as just the certificate identified by serial number, so it
 	// can be revoked
 	err = req.Storage.Put(&logical.StorageEntry{
@@ -152,20 +185,16 @@ func (b *backend) pathCAGenerateRoot(
 		return nil, fmt.Errorf("Unable to store certificate locally: %v", err)
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(entry)
+	issuer, err := b.addIssuer(req, parsedBundle, data.Get("issuer_name").(string), setDefault)
 	if err != nil {
-		return nil, err
-	}
-
-	// Build a fresh CRL
-	err = buildCRL(b, req)
-	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
+	resp.Data["issuer_id"] = issuer.ID
 
 	if parsedBundle.Certificate.MaxPathLen == 0 {
 		resp.AddWarning("Max path length of the generated certificate is zero. This certificate cannot be used to issue intermediate CA certificates.")
@@ -199,7 +228,7 @@ func (b *backend) pathCASignIntermediate(
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(req)
+	signingBundle, caErr := fetchCAInfoByRef(req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -217,6 +246,11 @@ func (b *backend) pathCASignIntermediate(
 		role.MaxPathLength = &maxPathLength
 	}
 
+	role.CAConstraints, err = parseCAConstraints(data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	parsedBundle, err := signCert(b, role, signingBundle, true, useCSRValues, req, data)
 	if err != nil {
 		switch err.(type) {
@@ -299,6 +333,17 @@ const pathGenerateRootHelpDesc = `
 See the API documentation for more information.
 `
 
+const pathRotateRootHelpSyn = `
+Generate a new root issuer without replacing the default one.
+`
+
+const pathRotateRootHelpDesc = `
+This endpoint generates a new root CA certificate and private key like
+"root/generate", but keeps the current default issuer. Both issuers sign their
+CRL and answer OCSP requests, so the new root can be distributed to clients
+and roles moved to it before it is made the default with "config/issuers".
+`
+
 const pathSignIntermediateHelpSyn = `
 Issue an intermediate CA certificate based on the provided CSR.
 `
@@ -0,0 +1,325 @@
+package pki
+
+import (
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// scepCapabilities is the response of the GetCACaps operation
+const scepCapabilities = "POSTPKIOperation\nRenewal\nSHA-1\nSHA-256\nSHA-512\nAES\nDES3\n"
+
+func pathSCEP(b *backend) *framework.Path {
+	return &framework.Path{
+		// Clients usually append the "pkiclient.exe" of the original CGI
+		// implementation to the URL of the server
+		Pattern: "scep(/roles/" + framework.GenericNameRegex("role") + ")?(/pkiclient\\.exe)?",
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The role of the SCEP endpoint; the default role if empty`,
+			},
+
+			"operation": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The SCEP operation: GetCACert, GetCACaps or PKIOperation`,
+				Query:       true,
+			},
+
+			"message": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The base64 encoded message of a PKIOperation sent with GET`,
+				Query:       true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathSCEPRead,
+			logical.UpdateOperation: b.pathSCEPWrite,
+		},
+
+		HelpSynopsis:    pathSCEPHelpSyn,
+		HelpDescription: pathSCEPHelpDesc,
+	}
+}
+
+func (b *backend) pathSCEPRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	switch operation := data.Get("operation").(string); operation {
+	case "GetCACert":
+		return b.scepGetCACert(req, data)
+
+	case "GetCACaps":
+		_, _, resp, err := b.estRole(req, data)
+		if resp != nil || err != nil {
+			return resp, err
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPStatusCode:  http.StatusOK,
+				logical.HTTPContentType: "text/plain",
+				logical.HTTPRawBody:     []byte(scepCapabilities),
+			},
+		}, nil
+
+	case "PKIOperation":
+		// Query parameters may have their "+" decoded as spaces when the
+		// client didn't escape them
+		message := strings.Replace(data.Get("message").(string), " ", "+", -1)
+		der, err := decodeESTBody([]byte(message))
+		if err != nil || len(der) == 0 {
+			return estErrorResponse(http.StatusBadRequest, "the message must be a base64 encoded SCEP message"), nil
+		}
+		return b.scepPKIOperation(req, data, der)
+
+	default:
+		return estErrorResponse(http.StatusBadRequest, "unsupported SCEP operation %q", operation), nil
+	}
+}
+
+// pathSCEPWrite handles the PKIOperation requests sent with POST, whose
+// query parameters aren't passed to the backend
+func (b *backend) pathSCEPWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	body, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok {
+		return estErrorResponse(http.StatusUnsupportedMediaType, "the request must be an application/x-pki-message SCEP message"), nil
+	}
+
+	return b.scepPKIOperation(req, data, body)
+}
+
+func (b *backend) scepGetCACert(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	_, role, resp, err := b.estRole(req, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	caInfo, err := fetchCAInfoByRef(req, role.IssuerRef)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return estErrorResponse(http.StatusNotFound, "%s", err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	// A single certificate is returned as is, a chain as a certs-only
+	// PKCS#7 message
+	if len(caInfo.CAChain) == 0 {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPStatusCode:  http.StatusOK,
+				logical.HTTPContentType: "application/x-x509-ca-cert",
+				logical.HTTPRawBody:     caInfo.Certificate.Raw,
+			},
+		}, nil
+	}
+
+	certs := []*x509.Certificate{caInfo.Certificate}
+	for _, block := range caInfo.CAChain {
+		certs = append(certs, block.Certificate)
+	}
+	body, err := createCertsOnlyPKCS7(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPContentType: "application/x-x509-ca-ra-cert",
+			logical.HTTPRawBody:     body,
+		},
+	}, nil
+}
+
+// scepPKIOperation handles the enrollment and renewal requests. Requests
+// that can't be parsed get a plain text error; the others are answered with
+// a CertRep message holding either the certificate or the failure.
+func (b *backend) scepPKIOperation(
+	req *logical.Request, data *framework.FieldData, der []byte) (*logical.Response, error) {
+	config, role, resp, err := b.estRole(req, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	caInfo, err := fetchCAInfoByRef(req, role.IssuerRef)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return estErrorResponse(http.StatusNotFound, "%s", err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+	// SCEP requests are encrypted for the CA, which needs an RSA key to
+	// decrypt them
+	caKey, ok := caInfo.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return estErrorResponse(http.StatusNotImplemented, "SCEP requires the issuer of the role to have an RSA key"), nil
+	}
+
+	msg, err := parseSCEPMessage(der)
+	if err != nil {
+		return estErrorResponse(http.StatusBadRequest, "invalid SCEP message: %v", err), nil
+	}
+
+	failure := func(failInfo, reason string) (*logical.Response, error) {
+		b.Logger().Debug("SCEP request denied", "transaction_id", msg.TransactionID, "reason", reason)
+		return scepCertRepResponse(msg, caInfo.Certificate, caKey, scepStatusFailure, failInfo, nil)
+	}
+
+	if msg.MessageType != scepPKCSReq && msg.MessageType != scepRenewalReq {
+		return failure(scepFailBadRequest, "unsupported message type "+msg.MessageType)
+	}
+	if err := msg.Verify(); err != nil {
+		return failure(scepFailBadMessageCheck, err.Error())
+	}
+	if _, ok := msg.SignerCert.PublicKey.(*rsa.PublicKey); !ok {
+		return failure(scepFailBadAlg, "the signer certificate doesn't have an RSA key")
+	}
+	content, err := msg.Decrypt(caInfo.Certificate, caKey)
+	if err != nil {
+		return failure(scepFailBadMessageCheck, err.Error())
+	}
+	csr, err := x509.ParseCertificateRequest(content)
+	if err != nil {
+		return failure(scepFailBadRequest, err.Error())
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return failure(scepFailBadRequest, err.Error())
+	}
+
+	switch msg.MessageType {
+	case scepPKCSReq:
+		// Clients prove that they may enroll with a certificate of one of
+		// the trusted CAs or with the shared secret, as with EST
+		trusted, err := b.scepSignerTrusted(msg, config)
+		if err != nil {
+			return nil, err
+		}
+		if !trusted {
+			password, err := csrChallengePassword(csr)
+			if err != nil {
+				return failure(scepFailBadRequest, err.Error())
+			}
+			if config.ChallengePassword == "" || password == "" ||
+				subtle.ConstantTimeCompare([]byte(password), []byte(config.ChallengePassword)) != 1 {
+				return failure(scepFailBadRequest, "a trusted signer certificate or a valid challenge password is required")
+			}
+		}
+
+	case scepRenewalReq:
+		// Only the current, unrevoked, certificates of the issuer of the
+		// role can be renewed, for the same subject and names
+		if _, err := msg.SignerCert.Verify(x509.VerifyOptions{
+			Roots:     certPool(caInfo.Certificate),
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return failure(scepFailBadRequest, "the signer certificate wasn't issued by the issuer of the role")
+		}
+		serial := certutil.GetHexFormatted(msg.SignerCert.SerialNumber.Bytes(), ":")
+		revokedEntry, err := fetchCertBySerial(req, "revoked/", serial)
+		if err != nil {
+			return nil, err
+		}
+		if revokedEntry != nil {
+			return failure(scepFailBadRequest, "the signer certificate is revoked")
+		}
+		if !csrMatchesCertificate(csr, msg.SignerCert) {
+			return failure(scepFailBadRequest, "the CSR must request the subject and names of the current certificate")
+		}
+	}
+
+	cert, err := b.enrollCSR(req, role, csr)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return failure(scepFailBadRequest, err.Error())
+		default:
+			return nil, err
+		}
+	}
+	certs, err := createCertsOnlyPKCS7([]*x509.Certificate{cert})
+	if err != nil {
+		return nil, err
+	}
+
+	return scepCertRepResponse(msg, caInfo.Certificate, caKey, scepStatusSuccess, "", certs)
+}
+
+// scepSignerTrusted returns true if the signer of the request chains to one
+// of the trusted certificates of the configuration
+func (b *backend) scepSignerTrusted(msg *scepMessage, config *estConfig) (bool, error) {
+	if config.TrustedCertificates == "" {
+		return false, nil
+	}
+	trusted, err := parseTrustedCertificates(config.TrustedCertificates)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = msg.SignerCert.Verify(x509.VerifyOptions{
+		Roots:         certPool(trusted...),
+		Intermediates: certPool(msg.p7.Certificates...),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		b.Logger().Debug("SCEP signer certificate not trusted", "error", err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func scepCertRepResponse(msg *scepMessage, caCert *x509.Certificate, caKey *rsa.PrivateKey, status, failInfo string, content []byte) (*logical.Response, error) {
+	body, err := createSCEPCertRep(msg, caCert, caKey, status, failInfo, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPContentType: "application/x-pki-message",
+			logical.HTTPRawBody:     body,
+		},
+	}, nil
+}
+
+func certPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+const pathSCEPHelpSyn = `
+Enroll a client through SCEP.
+`
+
+const pathSCEPHelpDesc = `
+This endpoint implements the GetCACert, GetCACaps and PKIOperation operations
+of SCEP (RFC 8894), given by the "operation" query parameter. It uses the
+configuration of "config/est": the endpoint at "scep" issues certificates
+according to the default role, and the endpoints of the allowed roles are
+available at "scep/roles/<role>".
+
+Enrollment requests (PKCSReq) must either be signed by a certificate issued by
+one of the trusted certificates, or include the challenge password in the
+challengePassword attribute of their CSR. Renewal requests (RenewalReq) must
+be signed by the current, unrevoked, certificate of the client, issued by the
+issuer of the role, and request the same subject and names. The issuer of the
+role must have an RSA key.
+`
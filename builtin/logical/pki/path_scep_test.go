@@ -0,0 +1,275 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fullsailor/pkcs7"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_SCEP(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// scepRequest sends a SCEP request and returns the status code, the
+	// content type and the body of the response
+	scepRequest := func(op logical.Operation, path string, query map[string][]string, body []byte) (int, string, []byte) {
+		req := &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Query:     query,
+		}
+		if body != nil {
+			req.Data = map[string]interface{}{
+				logical.HTTPRawBody: body,
+			}
+		}
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("path %s: err: %v", path, err)
+		}
+		return resp.Data[logical.HTTPStatusCode].(int),
+			resp.Data[logical.HTTPContentType].(string),
+			resp.Data[logical.HTTPRawBody].([]byte)
+	}
+	operation := func(name string) map[string][]string {
+		return map[string][]string{"operation": []string{name}}
+	}
+
+	resp := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+	})
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request(logical.UpdateOperation, "roles/devices", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+
+	if status, _, _ := scepRequest(logical.ReadOperation, "scep", operation("GetCACaps"), nil); status != http.StatusForbidden {
+		t.Fatalf("expected SCEP to be disabled, got status %d", status)
+	}
+
+	request(logical.UpdateOperation, "config/est", map[string]interface{}{
+		"enabled":            true,
+		"default_role":       "devices",
+		"challenge_password": "s3cret",
+	})
+
+	status, contentType, body := scepRequest(logical.ReadOperation, "scep/pkiclient.exe", operation("GetCACert"), nil)
+	if status != http.StatusOK || contentType != "application/x-x509-ca-cert" || !bytes.Equal(body, caCert.Raw) {
+		t.Fatalf("bad: CA certificate: %d, %s", status, contentType)
+	}
+	status, _, body = scepRequest(logical.ReadOperation, "scep", operation("GetCACaps"), nil)
+	if status != http.StatusOK || !bytes.Contains(body, []byte("POSTPKIOperation\n")) {
+		t.Fatalf("bad: CA capabilities: %d, %q", status, body)
+	}
+	if status, _, _ := scepRequest(logical.ReadOperation, "scep", operation("GetNextCACert"), nil); status != http.StatusBadRequest {
+		t.Fatalf("expected an unsupported operation to be rejected, got status %d", status)
+	}
+
+	// Clients sign their first request with a self-signed certificate
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfSignedDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device.example.com"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device.example.com"},
+	}, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfSigned, err := x509.ParseCertificate(selfSignedDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createCSR := func(commonName string, password string) []byte {
+		return createTestCSR(t, key, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: commonName},
+			DNSNames: []string{commonName},
+		}, password)
+	}
+
+	senderNonce := []byte("0123456789abcdef")
+	createMessage := func(messageType string, csr []byte, signer *x509.Certificate) []byte {
+		envelope, err := pkcs7.Encrypt(csr, []*x509.Certificate{caCert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		signedData, err := pkcs7.NewSignedData(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = signedData.AddSigner(signer, key, pkcs7.SignerInfoConfig{
+			ExtraSignedAttributes: []pkcs7.Attribute{
+				{Type: oidSCEPMessageType, Value: messageType},
+				{Type: oidSCEPTransactionID, Value: "transaction-" + messageType},
+				{Type: oidSCEPSenderNonce, Value: senderNonce},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		message, err := signedData.Finish()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+
+	// certRep checks the CertRep response to the message and returns its
+	// status, its failure info and the certificate it holds on success
+	certRep := func(status int, contentType string, body []byte, messageType string, recipient *x509.Certificate) (string, string, *x509.Certificate) {
+		if status != http.StatusOK || contentType != "application/x-pki-message" {
+			t.Fatalf("bad: PKIOperation: %d, %s: %s", status, contentType, body)
+		}
+		p7, err := pkcs7.Parse(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p7.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if signer := p7.GetOnlySigner(); signer == nil || !signer.Equal(caCert) {
+			t.Fatalf("bad: CertRep signer: %#v", signer)
+		}
+
+		var respType, transactionID, pkiStatus, failInfo string
+		var recipientNonce []byte
+		if err := p7.UnmarshalSignedAttribute(oidSCEPMessageType, &respType); err != nil {
+			t.Fatal(err)
+		}
+		if err := p7.UnmarshalSignedAttribute(oidSCEPTransactionID, &transactionID); err != nil {
+			t.Fatal(err)
+		}
+		if err := p7.UnmarshalSignedAttribute(oidSCEPPKIStatus, &pkiStatus); err != nil {
+			t.Fatal(err)
+		}
+		if err := p7.UnmarshalSignedAttribute(oidSCEPRecipientNonce, &recipientNonce); err != nil {
+			t.Fatal(err)
+		}
+		if respType != scepCertRep || transactionID != "transaction-"+messageType || !bytes.Equal(recipientNonce, senderNonce) {
+			t.Fatalf("bad: CertRep attributes: %s, %s, %q", respType, transactionID, recipientNonce)
+		}
+		if pkiStatus != scepStatusSuccess {
+			if err := p7.UnmarshalSignedAttribute(oidSCEPFailInfo, &failInfo); err != nil {
+				t.Fatal(err)
+			}
+			return pkiStatus, failInfo, nil
+		}
+
+		envelope, err := pkcs7.Parse(p7.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := envelope.Decrypt(recipient, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certsOnly, err := pkcs7.Parse(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(certsOnly.Certificates) != 1 {
+			t.Fatalf("bad: CertRep certificates: %#v", certsOnly.Certificates)
+		}
+		return pkiStatus, "", certsOnly.Certificates[0]
+	}
+
+	pkiOperation := func(messageType string, csr []byte, signer *x509.Certificate) (string, string, *x509.Certificate) {
+		status, contentType, body := scepRequest(logical.UpdateOperation, "scep/pkiclient.exe", nil, createMessage(messageType, csr, signer))
+		return certRep(status, contentType, body, messageType, signer)
+	}
+
+	// Enrollment requires the challenge password
+	for _, password := range []string{"", "wrong"} {
+		if pkiStatus, failInfo, _ := pkiOperation(scepPKCSReq, createCSR("device.example.com", password), selfSigned); pkiStatus != scepStatusFailure || failInfo != scepFailBadRequest {
+			t.Fatalf("expected enrollment with password %q to fail, got %s, %s", password, pkiStatus, failInfo)
+		}
+	}
+	pkiStatus, _, deviceCert := pkiOperation(scepPKCSReq, createCSR("device.example.com", "s3cret"), selfSigned)
+	if pkiStatus != scepStatusSuccess || deviceCert.Subject.CommonName != "device.example.com" || deviceCert.CheckSignatureFrom(caCert) != nil {
+		t.Fatalf("bad: enrollment: %s, %#v", pkiStatus, deviceCert)
+	}
+	if pkiStatus, failInfo, _ := pkiOperation(scepPKCSReq, createCSR("device.example.org", "s3cret"), selfSigned); pkiStatus != scepStatusFailure || failInfo != scepFailBadRequest {
+		t.Fatalf("expected a name denied by the role to fail, got %s, %s", pkiStatus, failInfo)
+	}
+
+	// The signature of the message is verified
+	message := createMessage(scepPKCSReq, createCSR("device.example.com", "s3cret"), selfSigned)
+	message[len(message)-1] ^= 0xff
+	status, contentType, body = scepRequest(logical.UpdateOperation, "scep", nil, message)
+	if pkiStatus, failInfo, _ := certRep(status, contentType, body, scepPKCSReq, selfSigned); pkiStatus != scepStatusFailure || failInfo != scepFailBadMessageCheck {
+		t.Fatalf("expected a message with an invalid signature to fail, got %s, %s", pkiStatus, failInfo)
+	}
+
+	// The message can also be given in the query of a GET
+	message = createMessage(scepPKCSReq, createCSR("other.example.com", "s3cret"), selfSigned)
+	status, contentType, body = scepRequest(logical.ReadOperation, "scep", map[string][]string{
+		"operation": []string{"PKIOperation"},
+		"message":   []string{base64.StdEncoding.EncodeToString(message)},
+	}, nil)
+	if pkiStatus, _, cert := certRep(status, contentType, body, scepPKCSReq, selfSigned); pkiStatus != scepStatusSuccess || cert.Subject.CommonName != "other.example.com" {
+		t.Fatalf("bad: enrollment through GET: %s, %#v", pkiStatus, cert)
+	}
+
+	// Renewal requests are signed with the current certificate
+	if pkiStatus, failInfo, _ := pkiOperation(scepRenewalReq, createCSR("device.example.com", ""), selfSigned); pkiStatus != scepStatusFailure || failInfo != scepFailBadRequest {
+		t.Fatalf("expected renewal with a self-signed certificate to fail, got %s, %s", pkiStatus, failInfo)
+	}
+	if pkiStatus, failInfo, _ := pkiOperation(scepRenewalReq, createCSR("other.example.com", ""), deviceCert); pkiStatus != scepStatusFailure || failInfo != scepFailBadRequest {
+		t.Fatalf("expected renewal for other names to fail, got %s, %s", pkiStatus, failInfo)
+	}
+	pkiStatus, _, renewedCert := pkiOperation(scepRenewalReq, createCSR("device.example.com", ""), deviceCert)
+	if pkiStatus != scepStatusSuccess || renewedCert.SerialNumber.Cmp(deviceCert.SerialNumber) == 0 {
+		t.Fatalf("bad: renewal: %s, %#v", pkiStatus, renewedCert)
+	}
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": certutil.GetHexFormatted(deviceCert.SerialNumber.Bytes(), ":"),
+	})
+	if pkiStatus, failInfo, _ := pkiOperation(scepRenewalReq, createCSR("device.example.com", ""), deviceCert); pkiStatus != scepStatusFailure || failInfo != scepFailBadRequest {
+		t.Fatalf("expected renewal with a revoked certificate to fail, got %s, %s", pkiStatus, failInfo)
+	}
+
+	if status, _, _ := scepRequest(logical.UpdateOperation, "scep", nil, []byte("not a message")); status != http.StatusBadRequest {
+		t.Fatalf("expected an invalid message to be rejected, got status %d", status)
+	}
+}
@@ -2,7 +2,9 @@ package pki
 
 import (
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/vault/logical"
@@ -46,48 +48,171 @@ Defaults to 72 hours.`,
 	}
 }
 
+func pathTidyStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy-status",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathTidyStatusRead,
+		},
+
+		HelpSynopsis:    pathTidyStatusHelpSyn,
+		HelpDescription: pathTidyStatusHelpDesc,
+	}
+}
+
+// tidyParams selects what a tidy operation removes
+type tidyParams struct {
+	SafetyBuffer       int  `json:"safety_buffer"`
+	TidyCertStore      bool `json:"tidy_cert_store"`
+	TidyRevocationList bool `json:"tidy_revocation_list"`
+}
+
+// tidyStatus reports the last tidy operation
+type tidyStatus struct {
+	tidyParams
+
+	// Source is either "manual" or "auto"
+	Source    string    `json:"source"`
+	State     string    `json:"state"`
+	Error     string    `json:"error"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+
+	CertStoreDeletedCount   int `json:"cert_store_deleted_count"`
+	RevokedCertDeletedCount int `json:"revoked_cert_deleted_count"`
+
+	// LastAutoTidyTime is kept across manual runs to schedule the periodic
+	// tidy
+	LastAutoTidyTime time.Time `json:"last_auto_tidy_time"`
+}
+
+func fetchTidyStatus(s logical.Storage) (*tidyStatus, error) {
+	entry, err := s.Get("tidy_status")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var status tidyStatus
+	if err := entry.DecodeJSON(&status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func storeTidyStatus(s logical.Storage, status *tidyStatus) error {
+	entry, err := logical.StorageEntryJSON("tidy_status", status)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
 func (b *backend) pathTidyWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	safetyBuffer := d.Get("safety_buffer").(int)
-	tidyCertStore := d.Get("tidy_cert_store").(bool)
-	tidyRevocationList := d.Get("tidy_revocation_list").(bool)
+	params := tidyParams{
+		SafetyBuffer:       d.Get("safety_buffer").(int),
+		TidyCertStore:      d.Get("tidy_cert_store").(bool),
+		TidyRevocationList: d.Get("tidy_revocation_list").(bool),
+	}
+
+	if err := b.tidy(req, params, "manual"); err != nil {
+		if err == errTidyInProgress {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
 
-	bufferDuration := time.Duration(safetyBuffer) * time.Second
+var errTidyInProgress = errors.New("a tidy operation is already in progress")
 
-	if tidyCertStore {
+// tidy removes the expired certificates and revocation information, keeping
+// track of its progress in the tidy status
+func (b *backend) tidy(req *logical.Request, params tidyParams, source string) error {
+	if !atomic.CompareAndSwapInt32(&b.tidyRunning, 0, 1) {
+		return errTidyInProgress
+	}
+	defer atomic.StoreInt32(&b.tidyRunning, 0)
+
+	lastStatus, err := fetchTidyStatus(req.Storage)
+	if err != nil {
+		return err
+	}
+
+	status := &tidyStatus{
+		tidyParams: params,
+		Source:     source,
+		State:      "running",
+		StartTime:  time.Now(),
+	}
+	if source == "auto" {
+		status.LastAutoTidyTime = status.StartTime
+	} else if lastStatus != nil {
+		status.LastAutoTidyTime = lastStatus.LastAutoTidyTime
+	}
+	if err := storeTidyStatus(req.Storage, status); err != nil {
+		return err
+	}
+
+	tidyErr := b.doTidy(req, params, status)
+
+	status.EndTime = time.Now()
+	status.State = "finished"
+	if tidyErr != nil {
+		status.State = "error"
+		status.Error = tidyErr.Error()
+	}
+	if err := storeTidyStatus(req.Storage, status); err != nil {
+		return err
+	}
+
+	return tidyErr
+}
+
+func (b *backend) doTidy(req *logical.Request, params tidyParams, status *tidyStatus) error {
+	bufferDuration := time.Duration(params.SafetyBuffer) * time.Second
+
+	if params.TidyCertStore {
 		serials, err := req.Storage.List("certs/")
 		if err != nil {
-			return nil, fmt.Errorf("error fetching list of certs: %s", err)
+			return fmt.Errorf("error fetching list of certs: %s", err)
 		}
 
 		for _, serial := range serials {
 			certEntry, err := req.Storage.Get("certs/" + serial)
 			if err != nil {
-				return nil, fmt.Errorf("error fetching certificate %s: %s", serial, err)
+				return fmt.Errorf("error fetching certificate %s: %s", serial, err)
 			}
 
 			if certEntry == nil {
-				return nil, fmt.Errorf("certificate entry for serial %s is nil", serial)
+				return fmt.Errorf("certificate entry for serial %s is nil", serial)
 			}
 
 			if certEntry.Value == nil || len(certEntry.Value) == 0 {
-				return nil, fmt.Errorf("found entry for serial %s but actual certificate is empty", serial)
+				return fmt.Errorf("found entry for serial %s but actual certificate is empty", serial)
 			}
 
 			cert, err := x509.ParseCertificate(certEntry.Value)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse stored certificate with serial %s: %s", serial, err)
+				return fmt.Errorf("unable to parse stored certificate with serial %s: %s", serial, err)
 			}
 
 			if time.Now().After(cert.NotAfter.Add(bufferDuration)) {
 				if err := req.Storage.Delete("certs/" + serial); err != nil {
-					return nil, fmt.Errorf("error deleting serial %s from storage: %s", serial, err)
+					return fmt.Errorf("error deleting serial %s from storage: %s", serial, err)
 				}
+				status.CertStoreDeletedCount++
 			}
 		}
 	}
 
-	if tidyRevocationList {
+	if params.TidyRevocationList {
 		b.revokeStorageLock.Lock()
 		defer b.revokeStorageLock.Unlock()
 
@@ -95,51 +220,90 @@ func (b *backend) pathTidyWrite(
 
 		revokedSerials, err := req.Storage.List("revoked/")
 		if err != nil {
-			return nil, fmt.Errorf("error fetching list of revoked certs: %s", err)
+			return fmt.Errorf("error fetching list of revoked certs: %s", err)
 		}
 
 		var revInfo revocationInfo
 		for _, serial := range revokedSerials {
 			revokedEntry, err := req.Storage.Get("revoked/" + serial)
 			if err != nil {
-				return nil, fmt.Errorf("unable to fetch revoked cert with serial %s: %s", serial, err)
+				return fmt.Errorf("unable to fetch revoked cert with serial %s: %s", serial, err)
 			}
 			if revokedEntry == nil {
-				return nil, fmt.Errorf("revoked certificate entry for serial %s is nil", serial)
+				return fmt.Errorf("revoked certificate entry for serial %s is nil", serial)
 			}
 			if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
 				// TODO: In this case, remove it and continue? How likely is this to
 				// happen? Alternately, could skip it entirely, or could implement a
 				// delete function so that there is a way to remove these
-				return nil, fmt.Errorf("found revoked serial but actual certificate is empty")
+				return fmt.Errorf("found revoked serial but actual certificate is empty")
 			}
 
 			err = revokedEntry.DecodeJSON(&revInfo)
 			if err != nil {
-				return nil, fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
+				return fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
 			}
 
 			revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse stored revoked certificate with serial %s: %s", serial, err)
+				return fmt.Errorf("unable to parse stored revoked certificate with serial %s: %s", serial, err)
 			}
 
 			if time.Now().After(revokedCert.NotAfter.Add(bufferDuration)) {
 				if err := req.Storage.Delete("revoked/" + serial); err != nil {
-					return nil, fmt.Errorf("error deleting serial %s from revoked list: %s", serial, err)
+					return fmt.Errorf("error deleting serial %s from revoked list: %s", serial, err)
 				}
+				status.RevokedCertDeletedCount++
 				tidiedRevoked = true
 			}
 		}
 
 		if tidiedRevoked {
 			if err := buildCRL(b, req); err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
 
-	return nil, nil
+	return nil
+}
+
+func (b *backend) pathTidyStatusRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	status, err := fetchTidyStatus(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"state": "inactive",
+			},
+		}, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"state":                      status.State,
+			"source":                     status.Source,
+			"error":                      status.Error,
+			"safety_buffer":              status.SafetyBuffer,
+			"tidy_cert_store":            status.TidyCertStore,
+			"tidy_revocation_list":       status.TidyRevocationList,
+			"time_started":               status.StartTime.Format(time.RFC3339),
+			"time_finished":              nil,
+			"cert_store_deleted_count":   status.CertStoreDeletedCount,
+			"revoked_cert_deleted_count": status.RevokedCertDeletedCount,
+		},
+	}
+	if !status.EndTime.IsZero() {
+		resp.Data["time_finished"] = status.EndTime.Format(time.RFC3339)
+	}
+	if !status.LastAutoTidyTime.IsZero() {
+		resp.Data["last_auto_tidy_time"] = status.LastAutoTidyTime.Format(time.RFC3339)
+	}
+
+	return resp, nil
 }
 
 const pathTidyHelpSyn = `
@@ -168,3 +332,14 @@ certificate storage or in revocation infomation will then be checked. If the
 current time, minus the value of 'safety_buffer', is greater than the
 expiration, it will be removed.
 `
+
+const pathTidyStatusHelpSyn = `
+Report the status of the last tidy operation.
+`
+
+const pathTidyStatusHelpDesc = `
+This endpoint reports whether the last tidy operation, started either through
+the "tidy" endpoint or automatically as configured with "config/auto-tidy", is
+running, finished or failed, along with its parameters, its start and end
+times, and the number of certificates and revocation entries it removed.
+`
@@ -0,0 +1,114 @@
+package pki
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_AutoTidy(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := request(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != "inactive" {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "Vault Test Root",
+		"ttl":         "24h",
+	})
+	request(logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+	})
+	var serials []string
+	for _, name := range []string{"one.example.com", "two.example.com"} {
+		resp = request(logical.UpdateOperation, "issue/web", map[string]interface{}{
+			"common_name": name,
+			"ttl":         "1s",
+		})
+		serials = append(serials, resp.Data["serial_number"].(string))
+	}
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{"serial_number": serials[0]})
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/auto-tidy",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enabled": true,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error enabling the periodic tidy without anything to tidy, got: %v, %#v", err, resp)
+	}
+
+	request(logical.UpdateOperation, "config/auto-tidy", map[string]interface{}{
+		"enabled":              true,
+		"interval_duration":    "1h",
+		"safety_buffer":        "1s",
+		"tidy_cert_store":      true,
+		"tidy_revocation_list": true,
+	})
+	resp = request(logical.ReadOperation, "config/auto-tidy", nil)
+	if resp.Data["interval_duration"] != 3600 || resp.Data["safety_buffer"] != 1 {
+		t.Fatalf("bad: auto tidy config: %#v", resp.Data)
+	}
+
+	time.Sleep(3 * time.Second)
+
+	req := &logical.Request{Storage: storage}
+	if err := b.periodicFunc(req); err != nil {
+		t.Fatal(err)
+	}
+	resp = request(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != "finished" || resp.Data["source"] != "auto" || resp.Data["error"] != "" ||
+		resp.Data["cert_store_deleted_count"] != 2 || resp.Data["revoked_cert_deleted_count"] != 1 ||
+		resp.Data["time_finished"] == nil {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+	lastAutoTidy := resp.Data["last_auto_tidy_time"]
+
+	entry, err := storage.Get("revoked/" + strings.ToLower(serials[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatalf("the expired revoked certificate was not removed")
+	}
+
+	// A manual tidy doesn't reset the schedule of the periodic tidy
+	request(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store": true,
+	})
+	resp = request(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["source"] != "manual" || resp.Data["cert_store_deleted_count"] != 0 ||
+		resp.Data["last_auto_tidy_time"] != lastAutoTidy {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+
+	// The periodic tidy doesn't run again before the interval has passed
+	if err := b.periodicFunc(req); err != nil {
+		t.Fatal(err)
+	}
+	resp = request(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["source"] != "manual" {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+}
@@ -0,0 +1,347 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/fullsailor/pkcs7"
+)
+
+// The messages of SCEP (RFC 8894) are PKCS#7 signed-data messages whose
+// content is an enveloped-data message encrypted for the recipient. The
+// vendored pkcs7 package parses them, decrypts the requests and signs the
+// responses; the signatures of the requests are verified here since it only
+// supports SHA-1, and the responses are encrypted here with the content
+// cipher chosen by the client.
+
+var (
+	oidPKCS7EnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	oidSCEPMessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidSCEPPKIStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidSCEPFailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidSCEPSenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidSCEPRecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidSCEPTransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+
+	oidDigestSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+
+	oidCipherDESCBC     = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 7}
+	oidCipherDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidCipherAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidCipherAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// The values of the messageType, pkiStatus and failInfo attributes
+const (
+	scepCertRep    = "3"
+	scepRenewalReq = "17"
+	scepPKCSReq    = "19"
+
+	scepStatusSuccess = "0"
+	scepStatusFailure = "2"
+
+	scepFailBadAlg          = "0"
+	scepFailBadMessageCheck = "1"
+	scepFailBadRequest      = "2"
+)
+
+type scepAttribute struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type scepIssuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type scepRecipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  scepIssuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type scepEncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional"`
+}
+
+type scepEnvelopedData struct {
+	Version              int
+	RecipientInfos       []scepRecipientInfo `asn1:"set"`
+	EncryptedContentInfo scepEncryptedContentInfo
+}
+
+// scepMessage is a parsed SCEP request
+type scepMessage struct {
+	p7 *pkcs7.PKCS7
+
+	MessageType   string
+	TransactionID string
+	SenderNonce   []byte
+
+	// SignerCert is the certificate that signed the request, which the
+	// response is encrypted for
+	SignerCert *x509.Certificate
+
+	// ContentCipher is the algorithm the request was encrypted with
+	ContentCipher asn1.ObjectIdentifier
+}
+
+// parseSCEPMessage parses a DER encoded SCEP request, without verifying its
+// signature
+func parseSCEPMessage(der []byte) (*scepMessage, error) {
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, err
+	}
+	signerCert := p7.GetOnlySigner()
+	if signerCert == nil {
+		return nil, errors.New("the message must be signed by a single signer whose certificate is included")
+	}
+
+	msg := &scepMessage{
+		p7:         p7,
+		SignerCert: signerCert,
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPMessageType, &msg.MessageType); err != nil {
+		return nil, fmt.Errorf("invalid messageType attribute: %v", err)
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPTransactionID, &msg.TransactionID); err != nil {
+		return nil, fmt.Errorf("invalid transactionID attribute: %v", err)
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPSenderNonce, &msg.SenderNonce); err != nil {
+		return nil, fmt.Errorf("invalid senderNonce attribute: %v", err)
+	}
+
+	var contentInfo pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(p7.Content, &contentInfo); err != nil {
+		return nil, fmt.Errorf("invalid enveloped content: %v", err)
+	}
+	if !contentInfo.ContentType.Equal(oidPKCS7EnvelopedData) {
+		return nil, errors.New("the content of the message must be enveloped data")
+	}
+	var envelopedData scepEnvelopedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &envelopedData); err != nil {
+		return nil, fmt.Errorf("invalid enveloped content: %v", err)
+	}
+	msg.ContentCipher = envelopedData.EncryptedContentInfo.ContentEncryptionAlgorithm.Algorithm
+
+	return msg, nil
+}
+
+// Verify checks the signature of the request with the certificate of its
+// signer
+func (m *scepMessage) Verify() error {
+	signer := m.p7.Signers[0]
+
+	var hash crypto.Hash
+	var sigAlg x509.SignatureAlgorithm
+	switch alg := signer.DigestAlgorithm.Algorithm; {
+	case alg.Equal(oidDigestSHA1):
+		hash, sigAlg = crypto.SHA1, x509.SHA1WithRSA
+	case alg.Equal(oidDigestSHA256):
+		hash, sigAlg = crypto.SHA256, x509.SHA256WithRSA
+	case alg.Equal(oidDigestSHA512):
+		hash, sigAlg = crypto.SHA512, x509.SHA512WithRSA
+	default:
+		return fmt.Errorf("unsupported digest algorithm %s", alg)
+	}
+
+	var digest []byte
+	if err := m.p7.UnmarshalSignedAttribute(oidAttributeMessageDigest, &digest); err != nil {
+		return fmt.Errorf("invalid messageDigest attribute: %v", err)
+	}
+	h := hash.New()
+	h.Write(m.p7.Content)
+	if !hmac.Equal(digest, h.Sum(nil)) {
+		return errors.New("the message digest doesn't match the content")
+	}
+
+	// The signature covers the DER encoding of the SET OF the signed
+	// attributes
+	attributes := make([]scepAttribute, 0, len(signer.AuthenticatedAttributes))
+	for _, attribute := range signer.AuthenticatedAttributes {
+		attributes = append(attributes, scepAttribute{
+			Type:  attribute.Type,
+			Value: attribute.Value,
+		})
+	}
+	encoded, err := asn1.Marshal(struct {
+		A []scepAttribute `asn1:"set"`
+	}{A: attributes})
+	if err != nil {
+		return err
+	}
+	var signed asn1.RawValue
+	if _, err := asn1.Unmarshal(encoded, &signed); err != nil {
+		return err
+	}
+
+	return m.SignerCert.CheckSignature(sigAlg, signed.Bytes, signer.EncryptedDigest)
+}
+
+// Decrypt returns the content of the request, encrypted for the certificate
+// of the CA
+func (m *scepMessage) Decrypt(caCert *x509.Certificate, caKey *rsa.PrivateKey) ([]byte, error) {
+	envelope, err := pkcs7.Parse(m.p7.Content)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Decrypt(caCert, caKey)
+}
+
+// createSCEPCertRep returns a CertRep message answering the request, signed
+// by the CA. The content is only given on success and is encrypted for the
+// signer of the request.
+func createSCEPCertRep(msg *scepMessage, caCert *x509.Certificate, caKey *rsa.PrivateKey, status, failInfo string, content []byte) ([]byte, error) {
+	senderNonce := make([]byte, 16)
+	if _, err := rand.Read(senderNonce); err != nil {
+		return nil, err
+	}
+	attributes := []pkcs7.Attribute{
+		{Type: oidSCEPMessageType, Value: scepCertRep},
+		{Type: oidSCEPTransactionID, Value: msg.TransactionID},
+		{Type: oidSCEPPKIStatus, Value: status},
+		{Type: oidSCEPSenderNonce, Value: senderNonce},
+		{Type: oidSCEPRecipientNonce, Value: msg.SenderNonce},
+	}
+	if status == scepStatusFailure {
+		attributes = append(attributes, pkcs7.Attribute{Type: oidSCEPFailInfo, Value: failInfo})
+	}
+
+	var envelope []byte
+	if content != nil {
+		var err error
+		envelope, err = encryptSCEPContent(content, msg.SignerCert, msg.ContentCipher)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signedData, err := pkcs7.NewSignedData(envelope)
+	if err != nil {
+		return nil, err
+	}
+	if err := signedData.AddSigner(caCert, caKey, pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: attributes,
+	}); err != nil {
+		return nil, err
+	}
+	return signedData.Finish()
+}
+
+// encryptSCEPContent returns an enveloped-data message holding the content,
+// encrypted with the given cipher, or with AES-128-CBC if the cipher isn't
+// supported, for the RSA key of the recipient
+func encryptSCEPContent(content []byte, recipient *x509.Certificate, contentCipher asn1.ObjectIdentifier) ([]byte, error) {
+	pub, ok := recipient.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("the responses can only be encrypted for RSA keys")
+	}
+
+	var key []byte
+	var newCipher func([]byte) (cipher.Block, error)
+	switch {
+	case contentCipher.Equal(oidCipherDESCBC):
+		key, newCipher = make([]byte, 8), des.NewCipher
+	case contentCipher.Equal(oidCipherDESEDE3CBC):
+		key, newCipher = make([]byte, 24), des.NewTripleDESCipher
+	case contentCipher.Equal(oidCipherAES256CBC):
+		key, newCipher = make([]byte, 32), aes.NewCipher
+	default:
+		contentCipher = oidCipherAES128CBC
+		key, newCipher = make([]byte, 16), aes.NewCipher
+	}
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, block.BlockSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding, see section 10.3 of RFC 2315
+	padding := block.BlockSize() - len(content)%block.BlockSize()
+	encrypted := append(append([]byte{}, content...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The encrypted content is given in the constructed form, as a single
+	// OCTET STRING, which is the only one the pkcs7 package can parse
+	encryptedContent, err := asn1.Marshal(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopedData, err := asn1.Marshal(scepEnvelopedData{
+		RecipientInfos: []scepRecipientInfo{
+			{
+				IssuerAndSerialNumber: scepIssuerAndSerial{
+					IssuerName:   asn1.RawValue{FullBytes: recipient.RawIssuer},
+					SerialNumber: recipient.SerialNumber,
+				},
+				KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+					Algorithm:  oidRSAEncryption,
+					Parameters: asn1.RawValue{Tag: asn1.TagNull},
+				},
+				EncryptedKey: encryptedKey,
+			},
+		},
+		EncryptedContentInfo: scepEncryptedContentInfo{
+			ContentType: oidPKCS7Data,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  contentCipher,
+				Parameters: asn1.RawValue{Tag: asn1.TagOctetString, Bytes: iv},
+			},
+			EncryptedContent: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        0,
+				IsCompound: true,
+				Bytes:      encryptedContent,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7EnvelopedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      envelopedData,
+		},
+	})
+}
@@ -27,6 +27,11 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathListRoles(&b),
 			pathRoles(&b),
 			pathRoleCreate(&b),
+			pathListStaticRoles(&b),
+			pathStaticRoles(&b),
+			pathStaticCreds(&b),
+			pathRotateRole(&b),
+			pathRotateRoot(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -36,6 +41,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 		Clean: b.ResetDB,
 
 		Invalidate: b.invalidate,
+
+		PeriodicFunc: b.periodicFunc,
 	}
 
 	b.logger = conf.Logger
@@ -48,6 +55,14 @@ type backend struct {
 	db   *sql.DB
 	lock sync.Mutex
 
+	// staticRolesLock serializes the changes to the static roles and the
+	// rotations of their passwords
+	staticRolesLock sync.Mutex
+
+	// rootLock serializes the changes to the connection configuration and
+	// the rotations of the root password
+	rootLock sync.Mutex
+
 	logger log.Logger
 }
 
@@ -70,25 +85,20 @@ func (b *backend) DB(s logical.Storage) (*sql.DB, error) {
 	}
 
 	// Otherwise, attempt to make connection
-	entry, err := s.Get("config/connection")
+	connConfig, err := b.connectionConfig(s)
 	if err != nil {
 		return nil, err
 	}
-	if entry == nil {
+	if connConfig == nil {
 		return nil,
 			fmt.Errorf("configure the DB connection with config/connection first")
 	}
 
-	var connConfig connectionConfig
-	if err := entry.DecodeJSON(&connConfig); err != nil {
+	conn, err := connConfig.connectionString()
+	if err != nil {
 		return nil, err
 	}
 
-	conn := connConfig.ConnectionURL
-	if len(conn) == 0 {
-		conn = connConfig.ConnectionString
-	}
-
 	// Ensure timezone is set to UTC for all the conenctions
 	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
 		if strings.Contains(conn, "?") {
@@ -154,7 +164,8 @@ func (b *backend) Lease(s logical.Storage) (*configLease, error) {
 }
 
 const backendHelp = `
-The PostgreSQL backend dynamically generates database users.
+The PostgreSQL backend dynamically generates database users, and rotates
+the passwords of existing users bound to static roles.
 
 After mounting this backend, configure it using the endpoints within
 the "config/" path.
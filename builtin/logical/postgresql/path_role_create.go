@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/dbutil"
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	_ "github.com/lib/pq"
@@ -64,19 +66,30 @@ func (b *backend) pathRoleCreateRead(
 	}
 
 	// Generate the username, password and expiration. PG limits user to 63 characters
-	displayName := req.DisplayName
-	if len(displayName) > 26 {
-		displayName = displayName[:26]
-	}
-	userUUID, err := uuid.GenerateUUID()
-	if err != nil {
-		return nil, err
-	}
-	username := fmt.Sprintf("%s-%s", displayName, userUUID)
-	if len(username) > 63 {
-		username = username[:63]
+	var username string
+	if role.UsernameTemplate != "" {
+		username, err = templateutil.RenderUsername(role.UsernameTemplate, templateutil.UsernameData{
+			DisplayName: req.DisplayName,
+			RoleName:    name,
+		}, 63)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		displayName := req.DisplayName
+		if len(displayName) > 26 {
+			displayName = displayName[:26]
+		}
+		userUUID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		username = fmt.Sprintf("%s-%s", displayName, userUUID)
+		if len(username) > 63 {
+			username = username[:63]
+		}
 	}
-	password, err := uuid.GenerateUUID()
+	password, err := dbutil.GeneratePassword(req.Storage, b.System())
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +125,7 @@ func (b *backend) pathRoleCreateRead(
 		b.logger.Trace("postgres/pathRoleCreateRead: preparing statement")
 		stmt, err := tx.Prepare(Query(query, map[string]string{
 			"name":       username,
-			"password":   password,
+			"password":   dbutil.EscapeLiteral(password),
 			"expiration": expiration,
 		}))
 		if err != nil {
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/templateutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -43,6 +44,12 @@ string, a base64-encoded semicolon-separated string, a serialized JSON string
 array, or a base64-encoded serialized JSON string array. The '{{name}}' value
 will be substituted.`,
 			},
+
+			"username_template": {
+				Type: framework.TypeString,
+				Description: `Template of the usernames of the generated users, in Go
+template syntax. See help for more info.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -95,8 +102,9 @@ func (b *backend) pathRoleRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"sql":            role.SQL,
-			"revocation_sql": role.RevocationSQL,
+			"sql":               role.SQL,
+			"revocation_sql":    role.RevocationSQL,
+			"username_template": role.UsernameTemplate,
 		},
 	}, nil
 }
@@ -116,6 +124,13 @@ func (b *backend) pathRoleCreate(
 	name := data.Get("name").(string)
 	sql := data.Get("sql").(string)
 
+	usernameTemplate := data.Get("username_template").(string)
+	if usernameTemplate != "" {
+		if err := templateutil.ValidateUsernameTemplate(usernameTemplate); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	// Get our connection
 	db, err := b.DB(req.Storage)
 	if err != nil {
@@ -143,8 +158,9 @@ func (b *backend) pathRoleCreate(
 
 	// Store it
 	entry, err := logical.StorageEntryJSON("role/"+name, &roleEntry{
-		SQL:           sql,
-		RevocationSQL: data.Get("revocation_sql").(string),
+		SQL:              sql,
+		RevocationSQL:    data.Get("revocation_sql").(string),
+		UsernameTemplate: usernameTemplate,
 	})
 	if err != nil {
 		return nil, err
@@ -157,8 +173,9 @@ func (b *backend) pathRoleCreate(
 }
 
 type roleEntry struct {
-	SQL           string `json:"sql" mapstructure:"sql" structs:"sql"`
-	RevocationSQL string `json:"revocation_sql" mapstructure:"revocation_sql" structs:"revocation_sql"`
+	SQL              string `json:"sql" mapstructure:"sql" structs:"sql"`
+	RevocationSQL    string `json:"revocation_sql" mapstructure:"revocation_sql" structs:"revocation_sql"`
+	UsernameTemplate string `json:"username_template" mapstructure:"username_template" structs:"username_template"`
 }
 
 const pathRoleHelpSyn = `
@@ -197,4 +214,22 @@ Example of a decent revocation SQL query to use:
 	REVOKE ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA public FROM {{name}};
 	REVOKE USAGE ON SCHEMA public FROM {{name}};
 	DROP ROLE IF EXISTS {{name}};
+
+The "username_template" parameter customizes the usernames of the generated
+users. It uses the Go template syntax, with the {{.DisplayName}} and
+{{.RoleName}} values, and the following functions:
+
+  random N        N random alphanumeric characters
+  uuid            a random UUID
+  unix_time       the current unix time, in seconds
+  truncate N s    the first N characters of s
+  lowercase s     s in lower case
+  uppercase s     s in upper case
+  replace a b s   s with all the occurrences of a replaced with b
+
+For example: "v_{{.RoleName}}_{{lowercase .DisplayName}}_{{random 8}}".
+
+Usernames longer than the 63 characters allowed by PostgreSQL are
+truncated, so make sure the random part of the template fits within that
+length.
 `
@@ -0,0 +1,144 @@
+package postgresql
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/dbutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRotateRoot(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-root",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRootWrite,
+		},
+
+		HelpSynopsis:    pathRotateRootHelpSyn,
+		HelpDescription: pathRotateRootHelpDesc,
+	}
+}
+
+func (b *backend) pathRotateRootWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.rootLock.Lock()
+	defer b.rootLock.Unlock()
+
+	config, err := b.connectionConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("configure the DB connection with config/connection first"), nil
+	}
+	if config.Username == "" {
+		return logical.ErrorResponse("the root credentials must be set with username and password to be rotated"), nil
+	}
+
+	return nil, b.rotateRoot(req.Storage, config)
+}
+
+// rotateRootIfDue rotates the root password if its rotation period elapsed
+func (b *backend) rotateRootIfDue(s logical.Storage) error {
+	b.rootLock.Lock()
+	defer b.rootLock.Unlock()
+
+	config, err := b.connectionConfig(s)
+	if err != nil {
+		return err
+	}
+	if config == nil || config.Username == "" || config.RootRotationPeriod == 0 ||
+		config.NextRootRotation().After(time.Now()) {
+		return nil
+	}
+
+	return b.rotateRoot(s, config)
+}
+
+// rotateRoot sets a new password for the root user and stores it. The root
+// lock must be held by the caller.
+func (b *backend) rotateRoot(s logical.Storage, config *connectionConfig) error {
+	b.logger.Trace("postgres/rotateRoot: enter")
+	defer b.logger.Trace("postgres/rotateRoot: exit")
+
+	password, err := dbutil.GeneratePassword(s, b.System())
+	if err != nil {
+		return err
+	}
+
+	// Get our handle
+	db, err := b.DB(s)
+	if err != nil {
+		return err
+	}
+
+	// Start a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rotationSQL := config.RootRotationSQL
+	if rotationSQL == "" {
+		rotationSQL = defaultStaticRoleRotationSQL
+	}
+
+	// Execute each query
+	for _, query := range strutil.ParseArbitraryStringSlice(rotationSQL, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		stmt, err := tx.Prepare(Query(query, map[string]string{
+			"name":     config.Username,
+			"password": dbutil.EscapeLiteral(password),
+		}))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec(); err != nil {
+			return err
+		}
+	}
+
+	// As for the static roles, store the new password before committing the
+	// transaction and restore the previous one if the commit fails
+	previousPassword, previousRotation := config.Password, config.LastRootRotation
+	config.Password = password
+	config.LastRootRotation = time.Now().UTC()
+	if err := putConnectionConfig(s, config); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		config.Password, config.LastRootRotation = previousPassword, previousRotation
+		if putErr := putConnectionConfig(s, config); putErr != nil {
+			err = multierror.Append(err, putErr)
+		}
+		return err
+	}
+
+	// The next connections use the new password
+	b.ResetDB()
+
+	return nil
+}
+
+const pathRotateRootHelpSyn = `
+Rotate the password of the root user.
+`
+
+const pathRotateRootHelpDesc = `
+This path sets a new password for the root user configured with "username" and
+"password" in "config/connection", immediately, using the "root_rotation_sql"
+of the connection. Once rotated, the password is only known to Vault. The
+next scheduled rotation happens "root_rotation_period" after this one.
+`
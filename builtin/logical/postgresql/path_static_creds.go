@@ -0,0 +1,223 @@
+package postgresql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/dbutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathStaticCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathStaticCredsRead,
+		},
+
+		HelpSynopsis:    pathStaticCredsHelpSyn,
+		HelpDescription: pathStaticCredsHelpDesc,
+	}
+}
+
+func pathRotateRole(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRoleWrite,
+		},
+
+		HelpSynopsis:    pathRotateRoleHelpSyn,
+		HelpDescription: pathRotateRoleHelpDesc,
+	}
+}
+
+func (b *backend) pathStaticCredsRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	role, err := b.StaticRole(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+	}
+
+	// The password is valid until the next rotation
+	ttl := role.NextRotation().Sub(time.Now())
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"username":            role.Username,
+			"password":            role.Password,
+			"last_vault_rotation": role.LastVaultRotation,
+			"rotation_period":     int64(role.RotationPeriod / time.Second),
+			"ttl":                 int64(ttl / time.Second),
+		},
+	}, nil
+}
+
+func (b *backend) pathRotateRoleWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	b.staticRolesLock.Lock()
+	defer b.staticRolesLock.Unlock()
+
+	role, err := b.StaticRole(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+	}
+
+	return nil, b.rotateStaticRole(req.Storage, name, role)
+}
+
+// rotateStaticRole sets a new password for the user of the static role and
+// stores it. The static roles lock must be held by the caller.
+func (b *backend) rotateStaticRole(s logical.Storage, name string, role *staticRoleEntry) error {
+	b.logger.Trace("postgres/rotateStaticRole: enter")
+	defer b.logger.Trace("postgres/rotateStaticRole: exit")
+
+	password, err := dbutil.GeneratePassword(s, b.System())
+	if err != nil {
+		return err
+	}
+
+	// Get our handle
+	db, err := b.DB(s)
+	if err != nil {
+		return err
+	}
+
+	// Start a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Execute each query
+	for _, query := range strutil.ParseArbitraryStringSlice(role.RotationSQL, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		stmt, err := tx.Prepare(Query(query, map[string]string{
+			"name":     role.Username,
+			"password": dbutil.EscapeLiteral(password),
+		}))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec(); err != nil {
+			return err
+		}
+	}
+
+	// Store the new password before committing the transaction, so that a
+	// storage failure cannot leave the user with a password Vault doesn't
+	// know. If the commit fails, the previous password is restored.
+	previousPassword, previousRotation := role.Password, role.LastVaultRotation
+	role.Password = password
+	role.LastVaultRotation = time.Now().UTC()
+	if err := b.putStaticRole(s, name, role); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		role.Password, role.LastVaultRotation = previousPassword, previousRotation
+		if putErr := b.putStaticRole(s, name, role); putErr != nil {
+			err = multierror.Append(err, putErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// periodicFunc rotates the root password and the passwords of the static
+// roles whose rotation period elapsed
+func (b *backend) periodicFunc(req *logical.Request) error {
+	var errs error
+	if err := b.rotateRootIfDue(req.Storage); err != nil {
+		errs = multierror.Append(errs, errwrap.Wrapf("failed to rotate the root password: {{err}}", err))
+	}
+
+	names, err := req.Storage.List("static-role/")
+	if err != nil {
+		return multierror.Append(errs, err)
+	}
+	if len(names) == 0 {
+		return errs
+	}
+
+	b.staticRolesLock.Lock()
+	defer b.staticRolesLock.Unlock()
+
+	now := time.Now()
+	for _, name := range names {
+		role, err := b.StaticRole(req.Storage, name)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if role == nil || role.NextRotation().After(now) {
+			continue
+		}
+
+		if err := b.rotateStaticRole(req.Storage, name, role); err != nil {
+			errs = multierror.Append(errs, errwrap.Wrapf(
+				fmt.Sprintf("failed to rotate static role %q: {{err}}", name), err))
+		}
+	}
+
+	return errs
+}
+
+const pathStaticCredsHelpSyn = `
+Request the current credentials of a static role.
+`
+
+const pathStaticCredsHelpDesc = `
+This path reads the username and the current password of the database user
+managed by a static role. The password is valid until the next rotation, in
+"ttl" seconds.
+`
+
+const pathRotateRoleHelpSyn = `
+Rotate the password of a static role.
+`
+
+const pathRotateRoleHelpDesc = `
+This path sets a new password for the database user managed by a static role,
+immediately. The next scheduled rotation happens "rotation_period" after this
+one.
+`
@@ -0,0 +1,251 @@
+package postgresql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// minStaticRoleRotationPeriod is the shortest rotation period of the static
+// roles, so that the periodic function is able to keep up
+const minStaticRoleRotationPeriod = 5 * time.Second
+
+const defaultStaticRoleRotationSQL = `ALTER ROLE "{{name}}" WITH PASSWORD '{{password}}';`
+
+func pathListStaticRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathStaticRoleList,
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func pathStaticRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+
+			"username": {
+				Type: framework.TypeString,
+				Description: `Name of the existing database user managed by the
+static role. Cannot be changed once the role is created.`,
+			},
+
+			"rotation_period": {
+				Type: framework.TypeDurationSecond,
+				Description: `Period after which the password of the user is
+rotated. Must be at least 5 seconds.`,
+			},
+
+			"rotation_sql": {
+				Type: framework.TypeString,
+				Description: `SQL statements to be executed to set the password of
+the user. Must be a semicolon-separated string, a base64-encoded
+semicolon-separated string, a serialized JSON string array, or a
+base64-encoded serialized JSON string array. The '{{name}}' and
+'{{password}}' values will be substituted.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathStaticRoleRead,
+			logical.UpdateOperation: b.pathStaticRoleWrite,
+			logical.DeleteOperation: b.pathStaticRoleDelete,
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func (b *backend) StaticRole(s logical.Storage, n string) (*staticRoleEntry, error) {
+	entry, err := s.Get("static-role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result staticRoleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) putStaticRole(s logical.Storage, n string, role *staticRoleEntry) error {
+	entry, err := logical.StorageEntryJSON("static-role/"+n, role)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(entry)
+}
+
+func (b *backend) pathStaticRoleDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.staticRolesLock.Lock()
+	defer b.staticRolesLock.Unlock()
+
+	// The database user is left in place, with its current password
+	err := req.Storage.Delete("static-role/" + data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathStaticRoleRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.StaticRole(req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"username":            role.Username,
+			"rotation_period":     int64(role.RotationPeriod / time.Second),
+			"rotation_sql":        role.RotationSQL,
+			"last_vault_rotation": role.LastVaultRotation,
+		},
+	}, nil
+}
+
+func (b *backend) pathStaticRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("static-role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathStaticRoleWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	b.staticRolesLock.Lock()
+	defer b.staticRolesLock.Unlock()
+
+	role, err := b.StaticRole(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	created := role == nil
+	if created {
+		role = &staticRoleEntry{
+			RotationSQL: defaultStaticRoleRotationSQL,
+		}
+	}
+
+	if usernameRaw, ok := data.GetOk("username"); ok {
+		username := usernameRaw.(string)
+		if !created && username != role.Username {
+			return logical.ErrorResponse("the username of a static role cannot be changed"), nil
+		}
+		role.Username = username
+	}
+	if role.Username == "" {
+		return logical.ErrorResponse("missing username"), nil
+	}
+
+	if rotationPeriodRaw, ok := data.GetOk("rotation_period"); ok {
+		role.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+	}
+	if role.RotationPeriod < minStaticRoleRotationPeriod {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"rotation_period must be at least %s", minStaticRoleRotationPeriod)), nil
+	}
+
+	if rotationSQLRaw, ok := data.GetOk("rotation_sql"); ok {
+		role.RotationSQL = rotationSQLRaw.(string)
+	}
+
+	// Get our connection
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test the query by trying to prepare it
+	for _, query := range strutil.ParseArbitraryStringSlice(role.RotationSQL, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		stmt, err := db.Prepare(Query(query, map[string]string{
+			"name":     role.Username,
+			"password": "bar",
+		}))
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error testing query: %s", err)), nil
+		}
+		stmt.Close()
+	}
+
+	// A new static role takes over the password of the user right away, so
+	// that the password is only known to Vault
+	if created {
+		return nil, b.rotateStaticRole(req.Storage, name, role)
+	}
+
+	return nil, b.putStaticRole(req.Storage, name, role)
+}
+
+type staticRoleEntry struct {
+	Username          string        `json:"username" mapstructure:"username" structs:"username"`
+	RotationPeriod    time.Duration `json:"rotation_period" mapstructure:"rotation_period" structs:"rotation_period"`
+	RotationSQL       string        `json:"rotation_sql" mapstructure:"rotation_sql" structs:"rotation_sql"`
+	Password          string        `json:"password" mapstructure:"password" structs:"password"`
+	LastVaultRotation time.Time     `json:"last_vault_rotation" mapstructure:"last_vault_rotation" structs:"last_vault_rotation"`
+}
+
+// NextRotation returns the time at which the password of the static role is
+// due to be rotated
+func (r *staticRoleEntry) NextRotation() time.Time {
+	return r.LastVaultRotation.Add(r.RotationPeriod)
+}
+
+const pathStaticRoleHelpSyn = `
+Manage the static roles that rotate the passwords of existing database users.
+`
+
+const pathStaticRoleHelpDesc = `
+This path lets you manage the static roles of this backend. Unlike a regular
+role, which creates a new database user for each credential, a static role is
+bound to an existing database user and rotates its password every
+"rotation_period". The current password is read from the "static-creds/"
+path, and can be rotated on demand with the "rotate-role/" path.
+
+The password of the user is rotated as soon as the static role is created.
+Deleting the static role leaves the user in place, with its last password.
+
+The "rotation_sql" parameter customizes the SQL string used to set the
+password of the user. The "{{name}}" and "{{password}}" values will be
+substituted. It defaults to:
+
+	ALTER ROLE "{{name}}" WITH PASSWORD '{{password}}';
+`
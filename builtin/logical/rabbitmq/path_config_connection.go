@@ -29,6 +29,10 @@ func pathConfigConnection(b *backend) *framework.Path {
 				Default:     true,
 				Description: `If set, connection_uri is verified by actually connecting to the RabbitMQ management API`,
 			},
+			"password_policy": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate the passwords of the users. Defaults to random UUIDs.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -56,6 +60,14 @@ func (b *backend) pathConnectionUpdate(req *logical.Request, data *framework.Fie
 		return logical.ErrorResponse("missing password"), nil
 	}
 
+	// Make sure the password policy exists and can generate passwords
+	passwordPolicy := data.Get("password_policy").(string)
+	if passwordPolicy != "" {
+		if _, err := b.System().GeneratePasswordFromPolicy(passwordPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to validate the password policy: %s", err)), nil
+		}
+	}
+
 	// Don't check the connection_url if verification is disabled
 	verifyConnection := data.Get("verify_connection").(bool)
 	if verifyConnection {
@@ -73,9 +85,10 @@ func (b *backend) pathConnectionUpdate(req *logical.Request, data *framework.Fie
 
 	// Store it
 	entry, err := logical.StorageEntryJSON("config/connection", connectionConfig{
-		URI:      uri,
-		Username: username,
-		Password: password,
+		URI:            uri,
+		Username:       username,
+		Password:       password,
+		PasswordPolicy: passwordPolicy,
 	})
 	if err != nil {
 		return nil, err
@@ -100,6 +113,10 @@ type connectionConfig struct {
 
 	// Password for the Username
 	Password string `json:"password"`
+
+	// PasswordPolicy is the name of the password policy used to generate the
+	// passwords of the users
+	PasswordPolicy string `json:"password_policy"`
 }
 
 const pathConfigConnectionHelpSyn = `
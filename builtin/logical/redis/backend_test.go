@@ -0,0 +1,287 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+	"github.com/mitchellh/mapstructure"
+)
+
+// testServer is a fake of the ACL commands of Redis
+type testServer struct {
+	sync.Mutex
+
+	listener net.Listener
+	users    map[string][]string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		listener: listener,
+		users:    make(map[string][]string),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testServer) Close() {
+	s.listener.Close()
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		command, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range command.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		reply := s.handle(args, &authenticated)
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *testServer) handle(args []string, authenticated *bool) string {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case strings.ToUpper(args[0]) == "AUTH":
+		if !reflect.DeepEqual(args[1:], []string{"vault", "secret"}) {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		*authenticated = true
+		return "+OK\r\n"
+	case !*authenticated:
+		return "-NOAUTH Authentication required.\r\n"
+	case strings.ToUpper(args[0]) == "PING":
+		return "+PONG\r\n"
+	case len(args) >= 3 && strings.ToUpper(args[0]) == "ACL" && strings.ToUpper(args[1]) == "SETUSER":
+		s.users[args[2]] = args[3:]
+		return "+OK\r\n"
+	case len(args) >= 3 && strings.ToUpper(args[0]) == "ACL" && strings.ToUpper(args[1]) == "DELUSER":
+		deleted := 0
+		for _, name := range args[2:] {
+			if _, ok := s.users[name]; ok {
+				delete(s.users, name)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestBackend_config_connection(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	server := newTestServer(t)
+	defer server.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfigPassword(t, server.Port(), "wrong", true),
+			testAccStepConfig(t, server.Port()),
+			testAccStepReadConfig(t),
+		},
+	})
+}
+
+func TestBackend_roleCrud(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	server := newTestServer(t)
+	defer server.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, server.Port()),
+			testAccStepRole(t, "web", map[string]interface{}{}, true),
+			testAccStepRole(t, "web", map[string]interface{}{
+				"acl_rules": "~cache:* +@read nopass",
+			}, true),
+			testAccStepRole(t, "web", map[string]interface{}{
+				"acl_rules": "~cache:* >known",
+			}, true),
+			testAccStepRole(t, "web", map[string]interface{}{
+				"acl_rules": "~cache:*  +@read",
+			}, false),
+			testAccStepReadRole(t, "web", map[string]interface{}{
+				"acl_rules": []string{"~cache:*", "+@read"},
+			}),
+			testAccStepDeleteRole(t, "web"),
+			testAccStepReadRole(t, "web", nil),
+		},
+	})
+}
+
+func TestBackend_creds(t *testing.T) {
+	b, _ := Factory(logical.TestBackendConfig())
+	server := newTestServer(t)
+	defer server.Close()
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t, server.Port()),
+			testAccStepRole(t, "web", map[string]interface{}{
+				"acl_rules": "~cache:* +@read",
+			}, false),
+			testAccStepReadCreds(t, server, "web"),
+		},
+	})
+
+	// Revoking the secret deletes the user
+	if len(server.users) != 0 {
+		t.Fatalf("bad: users: %#v", server.users)
+	}
+}
+
+func testAccStepConfig(t *testing.T, port int) logicaltest.TestStep {
+	return testAccStepConfigPassword(t, port, "secret", false)
+}
+
+func testAccStepConfigPassword(t *testing.T, port int, password string, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config/connection",
+		Data: map[string]interface{}{
+			"host":     "127.0.0.1",
+			"port":     port,
+			"username": "vault",
+			"password": password,
+		},
+		ErrorOk: expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadConfig(t *testing.T) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "config/connection",
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				return fmt.Errorf("missing response")
+			}
+			if _, ok := resp.Data["password"]; ok {
+				return fmt.Errorf("password should not be returned: %#v", resp.Data)
+			}
+			if resp.Data["username"] != "vault" || resp.Data["host"] != "127.0.0.1" {
+				return fmt.Errorf("bad: %#v", resp.Data)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepRole(t *testing.T, name string, data map[string]interface{}, expectError bool) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/" + name,
+		Data:      data,
+		ErrorOk:   expectError,
+		Check: func(resp *logical.Response) error {
+			if expectError && (resp == nil || !resp.IsError()) {
+				return fmt.Errorf("expected error, got %#v", resp)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepDeleteRole(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.DeleteOperation,
+		Path:      "roles/" + name,
+	}
+}
+
+func testAccStepReadRole(t *testing.T, name string, expected map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "roles/" + name,
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				if expected == nil {
+					return nil
+				}
+				return fmt.Errorf("bad: %#v", resp)
+			}
+			if !reflect.DeepEqual(resp.Data, expected) {
+				return fmt.Errorf("bad: expected: %#v got: %#v", expected, resp.Data)
+			}
+			return nil
+		},
+	}
+}
+
+func testAccStepReadCreds(t *testing.T, server *testServer, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + name,
+		Check: func(resp *logical.Response) error {
+			var d struct {
+				Username string `mapstructure:"username"`
+				Password string `mapstructure:"password"`
+			}
+			if err := mapstructure.Decode(resp.Data, &d); err != nil {
+				return err
+			}
+			if !strings.HasPrefix(d.Username, "root-") || d.Password == "" {
+				return fmt.Errorf("bad: %#v", resp.Data)
+			}
+
+			server.Lock()
+			defer server.Unlock()
+
+			// The user is reset, enabled with the password, then granted the
+			// rules of the role
+			rules, ok := server.users[d.Username]
+			if !ok {
+				return fmt.Errorf("user %q was not created", d.Username)
+			}
+			if !reflect.DeepEqual(rules, []string{"reset", "on", ">" + d.Password, "~cache:*", "+@read"}) {
+				return fmt.Errorf("bad: %#v", rules)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,205 @@
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// connectTimeout bounds the time spent connecting to Redis and running a
+// command
+const connectTimeout = 10 * time.Second
+
+// client manages the ACL users of Redis. It speaks the Redis serialization
+// protocol over a new connection for each command, since commands are only
+// sent when credentials are issued or revoked.
+type client struct {
+	address   string
+	username  string
+	password  string
+	tlsConfig *tls.Config
+}
+
+// redisError is an error reply of Redis
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func newClient(config *connectionConfig) (*client, error) {
+	c := &client{
+		address:  net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		username: config.Username,
+		password: config.Password,
+	}
+
+	if config.TLS {
+		c.tlsConfig = &tls.Config{
+			ServerName:         config.Host,
+			InsecureSkipVerify: config.InsecureTLS,
+		}
+		if config.CACert != "" {
+			caPool := x509.NewCertPool()
+			if ok := caPool.AppendCertsFromPEM([]byte(config.CACert)); !ok {
+				return nil, fmt.Errorf("could not parse the CA certificate")
+			}
+			c.tlsConfig.RootCAs = caPool
+		}
+	}
+
+	return c, nil
+}
+
+// Authenticate checks that the configured credentials are valid
+func (c *client) Authenticate() error {
+	_, err := c.do("PING")
+	return err
+}
+
+// SetUser creates or replaces the ACL user. The user is reset first, so that
+// only the given password and rules apply to it.
+func (c *client) SetUser(name, password string, rules []string) error {
+	args := append([]string{"ACL", "SETUSER", name, "reset", "on", ">" + password}, rules...)
+	_, err := c.do(args...)
+	return err
+}
+
+// DeleteUser deletes the ACL user. Deleting a user that doesn't exist is not
+// an error.
+func (c *client) DeleteUser(name string) error {
+	_, err := c.do("ACL", "DELUSER", name)
+	return err
+}
+
+// do connects and authenticates to Redis, then runs the command and returns
+// its reply
+func (c *client) do(args ...string) (interface{}, error) {
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	var conn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.address, c.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", c.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(connectTimeout)); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+
+	// The username is only understood from Redis 6, the password alone
+	// authenticates the default user
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := roundTrip(conn, r, auth); err != nil {
+			return nil, fmt.Errorf("failed to authenticate: %s", err)
+		}
+	}
+
+	reply, err := roundTrip(conn, r, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s", strings.Join(args[:commandNameLen(args)], " "), err)
+	}
+	return reply, nil
+}
+
+// commandNameLen returns the number of arguments naming the command, which
+// are the only ones included in errors since the others can hold passwords
+func commandNameLen(args []string) int {
+	if len(args) > 1 && strings.EqualFold(args[0], "ACL") {
+		return 2
+	}
+	return 1
+}
+
+func roundTrip(w io.Writer, r *bufio.Reader, args []string) (interface{}, error) {
+	if err := writeCommand(w, args); err != nil {
+		return nil, err
+	}
+	return readReply(r)
+}
+
+// writeCommand sends the command as an array of bulk strings
+func writeCommand(w io.Writer, args []string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// readReply reads a reply. Simple and bulk strings are returned as strings,
+// integers as int64 and arrays as []interface{}. Error replies are returned
+// as a redisError.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("malformed reply %q", line)
+}
@@ -0,0 +1,189 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigConnection(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/connection",
+		Fields: map[string]*framework.FieldSchema{
+			"host": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Host name or IP address of Redis",
+			},
+			"port": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     6379,
+				Description: "Port of Redis",
+			},
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of a Redis user allowed to manage ACL users. Defaults to the default user.",
+			},
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password of the provided Redis user",
+			},
+			"tls": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, the connection to Redis uses TLS",
+			},
+			"ca_cert": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM-encoded CA certificate used to verify the certificate of Redis",
+			},
+			"insecure_tls": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, the certificate of Redis is not verified",
+			},
+			"verify_connection": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Default:     true,
+				Description: `If set, the connection is verified by actually authenticating to Redis`,
+			},
+			"password_policy": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the password policy used to generate the passwords of the users. Defaults to random UUIDs.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConnectionRead,
+			logical.UpdateOperation: b.pathConnectionUpdate,
+		},
+
+		HelpSynopsis:    pathConfigConnectionHelpSyn,
+		HelpDescription: pathConfigConnectionHelpDesc,
+	}
+}
+
+func (b *backend) pathConnectionRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connConfig, err := b.connectionConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if connConfig == nil {
+		return nil, nil
+	}
+
+	// The password is not returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"host":            connConfig.Host,
+			"port":            connConfig.Port,
+			"username":        connConfig.Username,
+			"tls":             connConfig.TLS,
+			"ca_cert":         connConfig.CACert,
+			"insecure_tls":    connConfig.InsecureTLS,
+			"password_policy": connConfig.PasswordPolicy,
+		},
+	}, nil
+}
+
+func (b *backend) pathConnectionUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connConfig := &connectionConfig{
+		Host:           data.Get("host").(string),
+		Port:           data.Get("port").(int),
+		Username:       data.Get("username").(string),
+		Password:       data.Get("password").(string),
+		TLS:            data.Get("tls").(bool),
+		CACert:         data.Get("ca_cert").(string),
+		InsecureTLS:    data.Get("insecure_tls").(bool),
+		PasswordPolicy: data.Get("password_policy").(string),
+	}
+
+	switch {
+	case connConfig.Host == "":
+		return logical.ErrorResponse("missing host"), nil
+	case connConfig.Port <= 0 || connConfig.Port > 65535:
+		return logical.ErrorResponse(fmt.Sprintf("invalid port %d", connConfig.Port)), nil
+	case connConfig.Username != "" && connConfig.Password == "":
+		return logical.ErrorResponse("missing password"), nil
+	}
+
+	// Make sure the password policy exists and can generate passwords
+	if connConfig.PasswordPolicy != "" {
+		if _, err := b.System().GeneratePasswordFromPolicy(connConfig.PasswordPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to validate the password policy: %s", err)), nil
+		}
+	}
+
+	client, err := newClient(connConfig)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to create client: %s", err)), nil
+	}
+
+	// Don't check the connection if verification is disabled
+	verifyConnection := data.Get("verify_connection").(bool)
+	if verifyConnection {
+		if err := client.Authenticate(); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to validate the connection: %s", err)), nil
+		}
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config/connection", connConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	// Reset the client connection
+	b.resetClient()
+
+	return nil, nil
+}
+
+// connectionConfig contains the information required to make a connection to
+// Redis
+type connectionConfig struct {
+	// Host is the host name or IP address of Redis
+	Host string `json:"host"`
+
+	// Port is the port of Redis
+	Port int `json:"port"`
+
+	// Username of a user allowed to manage ACL users, the default user if
+	// empty
+	Username string `json:"username"`
+
+	// Password for the Username
+	Password string `json:"password"`
+
+	// TLS enables TLS on the connection
+	TLS bool `json:"tls"`
+
+	// CACert is the PEM-encoded CA certificate used to verify Redis
+	CACert string `json:"ca_cert"`
+
+	// InsecureTLS disables the verification of the certificate
+	InsecureTLS bool `json:"insecure_tls"`
+
+	// PasswordPolicy is the name of the password policy used to generate the
+	// passwords of the users
+	PasswordPolicy string `json:"password_policy"`
+}
+
+const pathConfigConnectionHelpSyn = `
+Configure the address and credentials to talk to Redis.
+`
+
+const pathConfigConnectionHelpDesc = `
+This path configures the connection properties used to connect to Redis 6 or
+later. The "host" and "port" parameters are the address of Redis. The
+"username" and "password" parameters are the credentials of a user allowed to
+manage ACL users, such as a user granted the "+acl" command. If "username" is
+not set, the password authenticates the default user.
+
+The "tls" parameter enables TLS on the connection. The "ca_cert" parameter is
+the PEM-encoded CA certificate used to verify the certificate of Redis, and
+"insecure_tls" disables this verification. The "verify_connection" parameter
+is a boolean that is used to verify whether the provided credentials are
+valid.
+`
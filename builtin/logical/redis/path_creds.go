@@ -0,0 +1,96 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/dbutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCredsRead,
+		},
+
+		HelpSynopsis:    pathCredsReadHelpSyn,
+		HelpDescription: pathCredsReadHelpDesc,
+	}
+}
+
+// Issues the credential based on the role name
+func (b *backend) pathCredsRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	// Get the role
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	// Ensure username is unique
+	uuidVal, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	username := fmt.Sprintf("%s-%s", req.DisplayName, uuidVal)
+
+	password, err := dbutil.GeneratePassword(req.Storage, b.System())
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the client configuration
+	client, err := b.Client(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.SetUser(username, password, role.ACLRules); err != nil {
+		return nil, fmt.Errorf("failed to create user %s: %s", username, err)
+	}
+
+	// Return the secret
+	resp := b.Secret(SecretCredsType).Response(map[string]interface{}{
+		"username": username,
+		"password": password,
+	}, map[string]interface{}{
+		"username": username,
+	})
+
+	// Determine if we have a lease
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease != nil {
+		resp.Secret.TTL = lease.TTL
+	}
+
+	return resp, nil
+}
+
+const pathCredsReadHelpSyn = `
+Request Redis credentials for a certain role.
+`
+
+const pathCredsReadHelpDesc = `
+This path reads Redis credentials for a certain role. The Redis ACL user will
+be generated on demand and will be automatically revoked when the lease is up.
+`
@@ -2,16 +2,35 @@ package ssh
 
 import (
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/vault/helper/awsutil"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
 )
 
 type backend struct {
 	*framework.Backend
 	view logical.Storage
 	salt *salt.Salt
+
+	// certsLock serializes updates to the records of issued certificates
+	certsLock sync.Mutex
+
+	// lastCertsTidy is the time of the last automatic tidy of the records
+	// of issued certificates, guarded by certsLock
+	lastCertsTidy time.Time
+
+	// caSigners holds the signers of the configured CAs by CA type, built
+	// on first use and dropped when the CA configuration changes
+	caSigners     map[string]ssh.Signer
+	caSignersLock sync.RWMutex
+
+	// kmsClient creates the clients of the KMS keys used as CA keys
+	kmsClient func(region string) (*awsutil.KMS, error)
 }
 
 func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
@@ -25,6 +44,8 @@ func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
 func Backend(conf *logical.BackendConfig) (*backend, error) {
 	var b backend
 	b.view = conf.StorageView
+	b.caSigners = make(map[string]ssh.Signer)
+	b.kmsClient = newKMSClient
 	b.Backend = &framework.Backend{
 		Help: strings.TrimSpace(backendHelp),
 
@@ -32,11 +53,18 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"host_public_key",
+				"krl",
 			},
 
 			LocalStorage: []string{
 				"otp/",
 			},
+
+			SealWrapStorage: []string{
+				"config/ca_bundle",
+				"config/host_ca_bundle",
+			},
 		},
 
 		Paths: []*framework.Path{
@@ -48,8 +76,16 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathLookup(&b),
 			pathVerify(&b),
 			pathConfigCA(&b),
+			pathConfigHostCA(&b),
 			pathSign(&b),
+			pathIssue(&b),
 			pathFetchPublicKey(&b),
+			pathFetchHostPublicKey(&b),
+			pathListCerts(&b),
+			pathCerts(&b),
+			pathRevoke(&b),
+			pathKRL(&b),
+			pathTidy(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -57,11 +93,22 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			secretOTP(&b),
 		},
 
-		Init: b.Initialize,
+		Init:         b.Initialize,
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.periodicFunc,
 	}
 	return &b, nil
 }
 
+func (b *backend) invalidate(key string) {
+	for _, caType := range []string{caTypeUser, caTypeHost} {
+		bundlePath, publicKeyPath := caStoragePaths(caType)
+		if key == bundlePath || key == publicKeyPath {
+			b.resetCASigner(caType)
+		}
+	}
+}
+
 func (b *backend) Initialize() error {
 	salt, err := salt.NewSalt(b.view, &salt.Config{
 		HashFunc: salt.SHA256Hash,
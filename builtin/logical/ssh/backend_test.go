@@ -1,6 +1,7 @@
 package ssh
 
 import (
+	"crypto/rsa"
 	"fmt"
 	"reflect"
 	"testing"
@@ -380,6 +381,33 @@ func TestSSHBackend_OTPCreate(t *testing.T) {
 	})
 }
 
+func TestSSHBackend_OTPCreateIPv6AndPorts(t *testing.T) {
+	testOTPRoleData := map[string]interface{}{
+		"key_type":          testOTPKeyType,
+		"default_user":      testUserName,
+		"cidr_list":         "127.0.0.1/32, 2001:db8::/32",
+		"exclude_cidr_list": "2001:db8:1::/48",
+		"allowed_ports":     "2222,2200-2210",
+	}
+	logicaltest.Test(t, logicaltest.TestCase{
+		Factory: testingFactory,
+		Steps: []logicaltest.TestStep{
+			testRoleWrite(t, testOTPRoleName, testOTPRoleData),
+			testCredsWrite(t, testOTPRoleName, map[string]interface{}{
+				"ip":   "2001:db8::10",
+				"port": 2205,
+			}, false),
+			testCredsWrite(t, testOTPRoleName, map[string]interface{}{
+				"ip": "2001:db8:1::10",
+			}, true),
+			testCredsWrite(t, testOTPRoleName, map[string]interface{}{
+				"ip":   "2001:db8::10",
+				"port": 2211,
+			}, true),
+		},
+	})
+}
+
 func TestSSHBackend_VerifyEcho(t *testing.T) {
 	verifyData := map[string]interface{}{
 		"otp": api.VerifyEchoRequest,
@@ -652,6 +680,138 @@ func TestBackend_OptionsOverrideDefaults(t *testing.T) {
 	logicaltest.Test(t, testCase)
 }
 
+func TestBackend_IssueGeneratesKeyPair(t *testing.T) {
+	config := logical.TestBackendConfig()
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	testCase := logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			configCaStep(),
+
+			createRoleStep("issuing", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"allowed_users":           "tuber",
+				"default_user":            "tuber",
+			}),
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "issue/issuing",
+				Data: map[string]interface{}{
+					"key_type": "ec",
+					"ttl":      "1h",
+				},
+				Check: func(resp *logical.Response) error {
+					privateKeyPEM := resp.Data["private_key"].(string)
+					signer, err := ssh.ParsePrivateKey([]byte(privateKeyPEM))
+					if err != nil {
+						return fmt.Errorf("unable to parse issued private key: %v", err)
+					}
+
+					signedKey := strings.TrimSpace(resp.Data["signed_key"].(string))
+					key, _ := base64.StdEncoding.DecodeString(strings.Split(signedKey, " ")[1])
+					parsedKey, err := ssh.ParsePublicKey(key)
+					if err != nil {
+						return err
+					}
+
+					cert := parsedKey.(*ssh.Certificate)
+					if !reflect.DeepEqual(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+						return errors.New("signed certificate does not match issued private key")
+					}
+
+					if cert.CertType != ssh.UserCert {
+						return fmt.Errorf("Incorrect CertType: %v", cert.CertType)
+					}
+
+					if !reflect.DeepEqual(cert.ValidPrincipals, []string{"tuber"}) {
+						return fmt.Errorf("Incorrect ValidPrincipals: %#v", cert.ValidPrincipals)
+					}
+
+					return nil
+				},
+			},
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "issue/issuing",
+				Data: map[string]interface{}{
+					"key_type": "rsa",
+					"key_bits": 1024,
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if !resp.IsError() {
+						return errors.New("expected an error for an unsupported key size")
+					}
+					return nil
+				},
+			},
+
+			createRoleStep("strong", map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"allowed_users":           "tuber",
+				"default_user":            "tuber",
+				"allowed_issue_key_types": "rsa",
+				"min_issue_key_bits":      3072,
+			}),
+
+			issueRefusedStep("strong", map[string]interface{}{
+				"key_type": "rsa",
+			}),
+
+			issueRefusedStep("strong", map[string]interface{}{
+				"key_type": "ec",
+				"key_bits": 521,
+			}),
+
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "issue/strong",
+				Data: map[string]interface{}{
+					"key_type": "rsa",
+					"key_bits": 3072,
+				},
+				Check: func(resp *logical.Response) error {
+					signer, err := ssh.ParsePrivateKey([]byte(resp.Data["private_key"].(string)))
+					if err != nil {
+						return fmt.Errorf("unable to parse issued private key: %v", err)
+					}
+					rsaKey, ok := signer.PublicKey().(ssh.CryptoPublicKey).CryptoPublicKey().(*rsa.PublicKey)
+					if !ok || rsaKey.N.BitLen() != 3072 {
+						return fmt.Errorf("unexpected issued key: %s", signer.PublicKey().Type())
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	logicaltest.Test(t, testCase)
+}
+
+func issueRefusedStep(role string, parameters map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "issue/" + role,
+		Data:      parameters,
+		ErrorOk:   true,
+		Check: func(resp *logical.Response) error {
+			if !resp.IsError() {
+				return fmt.Errorf("expected the role to refuse the key: %#v", parameters)
+			}
+			return nil
+		},
+	}
+}
+
 func configCaStep() logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.UpdateOperation,
@@ -962,3 +1122,292 @@ func testCredsWrite(t *testing.T, roleName string, data map[string]interface{},
 		},
 	}
 }
+
+func TestBackend_AllowedUsersTemplate(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Path:      "config/ca",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"public_key":  publicKey,
+			"private_key": privateKey,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp:%v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Path:      "roles/templated",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"key_type":                    "ca",
+			"allow_user_certificates":     true,
+			"allowed_users":               "{{identity.metadata.username}}",
+			"allowed_users_template":      true,
+			"default_extensions_template": true,
+			"default_extensions": map[string]interface{}{
+				"login@example.com": "{{identity.metadata.username}}",
+			},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp:%v", err, resp)
+	}
+
+	signReq := &logical.Request{
+		Path:             "sign/templated",
+		Operation:        logical.UpdateOperation,
+		Storage:          config.StorageView,
+		LoginDisplayName: "userpass-tuber",
+		ClientTokenMeta:  map[string]string{"username": "tuber"},
+		Data: map[string]interface{}{
+			"public_key":       publicKey2,
+			"valid_principals": "tuber",
+		},
+	}
+
+	resp, err = b.HandleRequest(signReq)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp:%v", err, resp)
+	}
+
+	signedKey := strings.TrimSpace(resp.Data["signed_key"].(string))
+	key, _ := base64.StdEncoding.DecodeString(strings.Split(signedKey, " ")[1])
+	parsedKey, err := ssh.ParsePublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := parsedKey.(*ssh.Certificate)
+	if cert.Permissions.Extensions["login@example.com"] != "tuber" {
+		t.Fatalf("bad: extensions: %#v", cert.Permissions.Extensions)
+	}
+
+	// A principal that does not match the caller's identity must be refused
+	signReq.Data["valid_principals"] = "root"
+	resp, err = b.HandleRequest(signReq)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response: err: %v, resp:%v", err, resp)
+	}
+
+	// Without a login identity the template is never resolved
+	signReq.LoginDisplayName = ""
+	signReq.Data["valid_principals"] = "tuber"
+	resp, err = b.HandleRequest(signReq)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response: err: %v, resp:%v", err, resp)
+	}
+
+	// Metadata holding a comma must not add principals
+	signReq.LoginDisplayName = "userpass-tuber"
+	signReq.ClientTokenMeta = map[string]string{"username": "tuber,root"}
+	for _, principals := range []string{"root", "tuber", "tuber,root"} {
+		signReq.Data["valid_principals"] = principals
+		resp, err = b.HandleRequest(signReq)
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error response for %q: err: %v, resp:%v", principals, err, resp)
+		}
+	}
+}
+
+func TestBackend_PrincipalMaxTTLsAndDefaultUserTemplate(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	request := func(path, displayName string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation:        logical.UpdateOperation,
+			Path:             path,
+			Storage:          config.StorageView,
+			LoginDisplayName: displayName,
+			Data:             data,
+		})
+		if err != nil {
+			t.Fatalf("bad: path: %s, err: %v", path, err)
+		}
+		return resp
+	}
+
+	request("config/ca", "", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+	resp := request("roles/capped", "", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"default_user":            "{{identity.display_name}}",
+		"default_user_template":   true,
+		"ttl":                     "2h",
+		"principal_max_ttls": map[string]interface{}{
+			"root": "15m",
+		},
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %v", resp)
+	}
+
+	parseCert := func(resp *logical.Response) *ssh.Certificate {
+		if resp == nil || resp.IsError() {
+			t.Fatalf("bad: %v", resp)
+		}
+		signedKey := strings.TrimSpace(resp.Data["signed_key"].(string))
+		key, _ := base64.StdEncoding.DecodeString(strings.Split(signedKey, " ")[1])
+		parsedKey, err := ssh.ParsePublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsedKey.(*ssh.Certificate)
+	}
+
+	// The role TTL is silently capped for root
+	cert := parseCert(request("sign/capped", "", map[string]interface{}{
+		"public_key":       publicKey2,
+		"valid_principals": "root",
+	}))
+	if ttl := time.Duration(cert.ValidBefore-cert.ValidAfter)*time.Second - 30*time.Second; ttl != 15*time.Minute {
+		t.Fatalf("bad: ttl: %v", ttl)
+	}
+
+	// An explicitly requested TTL above the cap is refused
+	resp = request("sign/capped", "", map[string]interface{}{
+		"public_key":       publicKey2,
+		"valid_principals": "root",
+		"ttl":              "1h",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a ttl above the principal cap")
+	}
+
+	// The default user is resolved from the display name of the token
+	cert = parseCert(request("sign/capped", "ldap-tuber", map[string]interface{}{
+		"public_key": publicKey2,
+	}))
+	if !reflect.DeepEqual(cert.ValidPrincipals, []string{"ldap-tuber"}) {
+		t.Fatalf("bad: principals: %#v", cert.ValidPrincipals)
+	}
+
+	// Without a login identity the default user cannot be resolved
+	resp = request("sign/capped", "", map[string]interface{}{
+		"public_key": publicKey2,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a default user without a login identity")
+	}
+
+	// A default user resolving to several principals is refused
+	resp = request("sign/capped", "tuber,root", map[string]interface{}{
+		"public_key": publicKey2,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a default user holding a comma")
+	}
+}
+
+func TestBackend_SignBatch(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	for path, data := range map[string]map[string]interface{}{
+		"config/ca": map[string]interface{}{
+			"public_key":  publicKey,
+			"private_key": privateKey,
+		},
+		"roles/batch": map[string]interface{}{
+			"key_type":                "ca",
+			"allow_user_certificates": true,
+			"allowed_users":           "tuber,bread",
+			"default_user":            "tuber",
+		},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s, err: %v, resp: %v", path, err, resp)
+		}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "sign/batch",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"ttl": "1h",
+			"batch_input": []interface{}{
+				map[string]interface{}{"public_key": publicKey2},
+				map[string]interface{}{"public_key": publicKey2, "valid_principals": "bread", "key_id": "bread-key"},
+				map[string]interface{}{"public_key": "invalid"},
+				map[string]interface{}{"public_key": publicKey2, "valid_principals": "root"},
+			},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	results := resp.Data["batch_results"].([]batchSignResponseItem)
+	if len(results) != 4 {
+		t.Fatalf("bad: results: %#v", results)
+	}
+	if results[0].SignedKey == "" || results[1].SignedKey == "" || results[0].Error != "" || results[1].Error != "" {
+		t.Fatalf("bad: results: %#v", results)
+	}
+	if results[2].Error == "" || results[3].Error == "" || results[2].SignedKey != "" || results[3].SignedKey != "" {
+		t.Fatalf("bad: results: %#v", results)
+	}
+
+	key, _ := base64.StdEncoding.DecodeString(strings.Split(strings.TrimSpace(results[1].SignedKey), " ")[1])
+	parsedKey, err := ssh.ParsePublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert := parsedKey.(*ssh.Certificate); cert.KeyId != "bread-key" || !reflect.DeepEqual(cert.ValidPrincipals, []string{"bread"}) {
+		t.Fatalf("bad: cert: %#v", cert)
+	}
+
+	// A batch input that is not a list is refused
+	_, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "sign/batch",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"batch_input": map[string]interface{}{"public_key": publicKey2},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a batch input that is not a list")
+	}
+
+	// The batch input is documented in the help of the path
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.HelpOperation,
+		Path:      "sign/batch",
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp == nil || !strings.Contains(resp.Data["help"].(string), "batch_input") {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+}
@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/awsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// newKMSClient creates the client of the AWS KMS service of the given region
+func newKMSClient(region string) (*awsutil.KMS, error) {
+	credsConfig := &awsutil.CredentialsConfig{
+		Region:     region,
+		HTTPClient: cleanhttp.DefaultClient(),
+	}
+
+	creds, err := credsConfig.GenerateCredentialChain()
+	if err != nil {
+		return nil, err
+	}
+
+	return awsutil.NewKMS(session.New(&aws.Config{
+		Credentials: creds,
+		Region:      aws.String(region),
+		HTTPClient:  cleanhttp.DefaultClient(),
+	})), nil
+}
+
+// managedKeySigner returns an SSH signer that delegates signing operations
+// to the AWS KMS key referenced by the bundle
+func (b *backend) managedKeySigner(bundle *signingBundle) (ssh.Signer, error) {
+	region := bundle.ManagedKeyRegion
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	client, err := b.kmsClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := awsutil.NewKMSSigner(client, bundle.ManagedKeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := signer.Public().(*ecdsa.PublicKey); !ok {
+		return nil, fmt.Errorf("managed key %q is not an ECDSA key", bundle.ManagedKeyName)
+	}
+
+	return ssh.NewSignerFromSigner(signer)
+}
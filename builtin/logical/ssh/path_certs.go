@@ -0,0 +1,480 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
+)
+
+// Constants of the OpenSSH key revocation list format, as described in
+// PROTOCOL.krl of the OpenSSH distribution
+const (
+	krlMagic                 uint64 = 0x5353484b524c0a00
+	krlFormatVersion         uint32 = 1
+	krlSectionCertificates   byte   = 1
+	krlSectionCertSerialList byte   = 0x20
+)
+
+const (
+	// certsTidyInterval is the interval between the automatic tidies of the
+	// records of expired certificates
+	certsTidyInterval = time.Hour
+
+	// certsTidySafetyBuffer is how long the records of expired certificates
+	// are kept by the automatic tidy
+	certsTidySafetyBuffer = 72 * time.Hour
+)
+
+// issuedCertificate is the record kept for every certificate signed by a
+// role that has "no_store" disabled
+type issuedCertificate struct {
+	SerialNumber    string    `json:"serial_number" structs:"serial_number" mapstructure:"serial_number"`
+	KeyId           string    `json:"key_id" structs:"key_id" mapstructure:"key_id"`
+	ValidPrincipals []string  `json:"valid_principals" structs:"valid_principals" mapstructure:"valid_principals"`
+	CertType        string    `json:"cert_type" structs:"cert_type" mapstructure:"cert_type"`
+	CAType          string    `json:"ca_type" structs:"ca_type" mapstructure:"ca_type"`
+	Role            string    `json:"role" structs:"role" mapstructure:"role"`
+	Expiration      time.Time `json:"expiration" structs:"expiration" mapstructure:"expiration"`
+	Revoked         bool      `json:"revoked" structs:"revoked" mapstructure:"revoked"`
+	RevocationTime  time.Time `json:"revocation_time" structs:"revocation_time" mapstructure:"revocation_time"`
+}
+
+func pathListCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathCertsList,
+		},
+
+		HelpSynopsis:    pathCertsHelpSyn,
+		HelpDescription: pathCertsHelpDesc,
+	}
+}
+
+func pathCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `certs/(?P<serial>[0-9a-fA-F]+)`,
+		Fields: map[string]*framework.FieldSchema{
+			"serial": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate, in hexadecimal.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCertsRead,
+		},
+
+		HelpSynopsis:    pathCertsHelpSyn,
+		HelpDescription: pathCertsHelpDesc,
+	}
+}
+
+func pathRevoke(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke",
+		Fields: map[string]*framework.FieldSchema{
+			"serial_number": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate to revoke, in hexadecimal.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokeWrite,
+		},
+
+		HelpSynopsis:    pathRevokeHelpSyn,
+		HelpDescription: pathRevokeHelpDesc,
+	}
+}
+
+func pathTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy",
+		Fields: map[string]*framework.FieldSchema{
+			"safety_buffer": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before its record is removed
+from the backend storage. Defaults to 72 hours.`,
+				Default: 259200, //72h, but TypeDurationSecond currently requires defaults to be int
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTidyWrite,
+		},
+
+		HelpSynopsis:    pathTidyHelpSyn,
+		HelpDescription: pathTidyHelpDesc,
+	}
+}
+
+func pathKRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "krl",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathKRLRead,
+		},
+
+		HelpSynopsis:    pathKRLHelpSyn,
+		HelpDescription: pathKRLHelpDesc,
+	}
+}
+
+// normalizeSerial returns the canonical form of a hexadecimal serial number,
+// as returned by the signing endpoints
+func normalizeSerial(serial string) (string, error) {
+	parsed, err := strconv.ParseUint(strings.Replace(serial, ":", "", -1), 16, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid serial number %q", serial)
+	}
+	return strconv.FormatUint(parsed, 16), nil
+}
+
+func (b *backend) getIssuedCertificate(s logical.Storage, serial string) (*issuedCertificate, error) {
+	entry, err := s.Get("certs/" + serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result issuedCertificate
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) putIssuedCertificate(s logical.Storage, cert *issuedCertificate) error {
+	entry, err := logical.StorageEntryJSON("certs/"+cert.SerialNumber, cert)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(entry)
+}
+
+// storeIssuedCertificate records the details of a signed certificate
+func (b *backend) storeIssuedCertificate(s logical.Storage, roleName, caType string, certificate *ssh.Certificate) error {
+	certType := "user"
+	if certificate.CertType == ssh.HostCert {
+		certType = "host"
+	}
+
+	return b.putIssuedCertificate(s, &issuedCertificate{
+		SerialNumber:    strconv.FormatUint(certificate.Serial, 16),
+		KeyId:           certificate.KeyId,
+		ValidPrincipals: certificate.ValidPrincipals,
+		CertType:        certType,
+		CAType:          caType,
+		Role:            roleName,
+		Expiration:      time.Unix(int64(certificate.ValidBefore), 0).UTC(),
+	})
+}
+
+func (b *backend) pathCertsList(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("certs/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathCertsRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	serial, err := normalizeSerial(d.Get("serial").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	cert, err := b.getIssuedCertificate(req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, nil
+	}
+
+	data := map[string]interface{}{
+		"serial_number":    cert.SerialNumber,
+		"key_id":           cert.KeyId,
+		"valid_principals": cert.ValidPrincipals,
+		"cert_type":        cert.CertType,
+		"role":             cert.Role,
+		"expiration":       cert.Expiration.Unix(),
+		"revoked":          cert.Revoked,
+	}
+	if cert.Revoked {
+		data["revocation_time"] = cert.RevocationTime.Unix()
+	}
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func (b *backend) pathRevokeWrite(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	serialRaw := d.Get("serial_number").(string)
+	if serialRaw == "" {
+		return logical.ErrorResponse("missing serial_number"), nil
+	}
+
+	serial, err := normalizeSerial(serialRaw)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	b.certsLock.Lock()
+	defer b.certsLock.Unlock()
+
+	cert, err := b.getIssuedCertificate(req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return logical.ErrorResponse(fmt.Sprintf("certificate with serial %s not found", serial)), nil
+	}
+
+	if !cert.Revoked {
+		cert.Revoked = true
+		cert.RevocationTime = time.Now().UTC()
+		if err := b.putIssuedCertificate(req.Storage, cert); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"revocation_time": cert.RevocationTime.Unix(),
+		},
+	}, nil
+}
+
+func (b *backend) pathTidyWrite(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	safetyBuffer := time.Duration(d.Get("safety_buffer").(int)) * time.Second
+
+	b.certsLock.Lock()
+	defer b.certsLock.Unlock()
+
+	deleted, err := b.tidyIssuedCertificates(req.Storage, safetyBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"deleted": deleted,
+		},
+	}, nil
+}
+
+// periodicFunc removes the records of the expired certificates, at most once
+// per certsTidyInterval
+func (b *backend) periodicFunc(req *logical.Request) error {
+	b.certsLock.Lock()
+	defer b.certsLock.Unlock()
+
+	if time.Since(b.lastCertsTidy) < certsTidyInterval {
+		return nil
+	}
+	b.lastCertsTidy = time.Now()
+
+	_, err := b.tidyIssuedCertificates(req.Storage, certsTidySafetyBuffer)
+	return err
+}
+
+// tidyIssuedCertificates removes the records of the certificates expired for
+// longer than the safety buffer, and returns the number of removed records.
+// The caller must hold certsLock.
+func (b *backend) tidyIssuedCertificates(s logical.Storage, safetyBuffer time.Duration) (int, error) {
+	serials, err := s.List("certs/")
+	if err != nil {
+		return 0, fmt.Errorf("error fetching list of certs: %v", err)
+	}
+
+	deleted := 0
+	now := time.Now()
+	for _, serial := range serials {
+		cert, err := b.getIssuedCertificate(s, serial)
+		if err != nil {
+			return deleted, fmt.Errorf("error fetching certificate %s: %v", serial, err)
+		}
+		if cert == nil || !now.After(cert.Expiration.Add(safetyBuffer)) {
+			continue
+		}
+
+		if err := s.Delete("certs/" + serial); err != nil {
+			return deleted, fmt.Errorf("error deleting certificate %s: %v", serial, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func (b *backend) pathKRLRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	krl, err := b.generateKRL(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     krl,
+			logical.HTTPStatusCode:  200,
+		},
+	}, nil
+}
+
+// generateKRL builds an OpenSSH key revocation list containing the serial
+// numbers of all the revoked certificates that have not yet expired, grouped
+// by the CA that signed them
+func (b *backend) generateKRL(s logical.Storage) ([]byte, error) {
+	serials, err := s.List("certs/")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	revoked := map[string][]uint64{}
+	for _, serial := range serials {
+		cert, err := b.getIssuedCertificate(s, serial)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil || !cert.Revoked || cert.Expiration.Before(now) {
+			continue
+		}
+
+		parsed, err := strconv.ParseUint(cert.SerialNumber, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stored serial number %q: %v", cert.SerialNumber, err)
+		}
+
+		caType := cert.CAType
+		if caType == "" {
+			caType = caTypeUser
+		}
+		revoked[caType] = append(revoked[caType], parsed)
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, krlMagic)
+	binary.Write(&buf, binary.BigEndian, krlFormatVersion)
+	binary.Write(&buf, binary.BigEndian, uint64(now.Unix()))
+	binary.Write(&buf, binary.BigEndian, uint64(now.Unix()))
+	binary.Write(&buf, binary.BigEndian, uint64(0))
+	writeKRLString(&buf, nil)
+	writeKRLString(&buf, []byte("Generated by Vault"))
+
+	for _, caType := range []string{caTypeUser, caTypeHost} {
+		if len(revoked[caType]) == 0 {
+			continue
+		}
+
+		_, publicKeyPath := caStoragePaths(caType)
+		entry, err := s.Get(publicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		caKey, err := parsePublicSSHKey(strings.TrimSpace(string(entry.Value)))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse stored CA public key: %v", err)
+		}
+
+		serialList := revoked[caType]
+		sort.Sort(uint64Slice(serialList))
+
+		var serialData bytes.Buffer
+		for _, serial := range serialList {
+			binary.Write(&serialData, binary.BigEndian, serial)
+		}
+
+		var section bytes.Buffer
+		writeKRLString(&section, caKey.Marshal())
+		writeKRLString(&section, nil)
+		section.WriteByte(krlSectionCertSerialList)
+		writeKRLString(&section, serialData.Bytes())
+
+		buf.WriteByte(krlSectionCertificates)
+		writeKRLString(&buf, section.Bytes())
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeKRLString(buf *bytes.Buffer, data []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+const pathCertsHelpSyn = `
+List and read the certificates signed by this backend.
+`
+
+const pathCertsHelpDesc = `
+Certificates signed by roles that have 'no_store' set to false are recorded
+with their serial number, key ID, principals and expiration. This path lists
+the recorded serial numbers and reads the details of a single certificate.
+The records of expired certificates are removed 72 hours after expiration, or
+earlier with the 'tidy' endpoint.
+`
+
+const pathRevokeHelpSyn = `
+Revoke a certificate signed by this backend.
+`
+
+const pathRevokeHelpDesc = `
+This path revokes a recorded certificate by its serial number. Revoked
+certificates are included in the key revocation list served at 'krl' until
+they expire.
+`
+
+const pathTidyHelpSyn = `
+Remove the records of expired certificates.
+`
+
+const pathTidyHelpDesc = `
+This path removes the records of the certificates that expired more than
+'safety_buffer' ago, and returns the number of removed records. Expired
+certificates are no longer listed in the key revocation list, so their
+records are not needed anymore. The records are also removed automatically
+72 hours after expiration.
+`
+
+const pathKRLHelpSyn = `
+Fetch the OpenSSH key revocation list.
+`
+
+const pathKRLHelpDesc = `
+This path returns an OpenSSH key revocation list (KRL) in binary format that
+contains the serial numbers of all the revoked certificates that have not yet
+expired. Hosts can fetch it periodically and reference it with the
+'RevokedKeys' option of sshd.
+`
@@ -0,0 +1,148 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestSSH_CertsRevokeAndKRL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s, err: %v, resp: %v", path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+	request(logical.UpdateOperation, "roles/stored", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "tuber",
+		"default_user":            "tuber",
+		"no_store":                false,
+	})
+
+	resp := request(logical.UpdateOperation, "sign/stored", map[string]interface{}{
+		"public_key": publicKey2,
+		"key_id":     "tuber-key",
+	})
+	serial := resp.Data["serial_number"].(string)
+
+	resp = request(logical.ListOperation, "certs/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != serial {
+		t.Fatalf("bad: keys: %#v", keys)
+	}
+
+	resp = request(logical.ReadOperation, "certs/"+serial, nil)
+	if resp.Data["key_id"] != "tuber-key" || resp.Data["revoked"] != false {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+
+	resp = request(logical.ReadOperation, "certs/"+serial, nil)
+	if resp.Data["revoked"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = request(logical.ReadOperation, "krl", nil)
+	krl := resp.Data[logical.HTTPRawBody].([]byte)
+	if !bytes.HasPrefix(krl, []byte("SSHKRL\n\x00")) {
+		t.Fatalf("bad KRL magic: %q", krl[:8])
+	}
+
+	parsedSerial, _ := strconv.ParseUint(serial, 16, 64)
+	serialBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(serialBytes, parsedSerial)
+	if !bytes.HasSuffix(krl, serialBytes) {
+		t.Fatalf("revoked serial not found in KRL")
+	}
+}
+
+func TestSSH_CertsTidy(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	for serial, expiration := range map[string]time.Time{
+		"1": now.Add(-100 * time.Hour),
+		"2": now.Add(-time.Hour),
+		"3": now.Add(time.Hour),
+	} {
+		if err := b.putIssuedCertificate(config.StorageView, &issuedCertificate{
+			SerialNumber: serial,
+			Expiration:   expiration,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listSerials := func() []string {
+		serials, err := config.StorageView.List("certs/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(serials)
+		return serials
+	}
+
+	// The automatic tidy keeps the records for the default safety buffer
+	if err := b.periodicFunc(&logical.Request{Storage: config.StorageView}); err != nil {
+		t.Fatal(err)
+	}
+	if serials := listSerials(); !reflect.DeepEqual(serials, []string{"2", "3"}) {
+		t.Fatalf("bad: serials: %v", serials)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"safety_buffer": "1m",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	if resp.Data["deleted"] != 1 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if serials := listSerials(); !reflect.DeepEqual(serials, []string{"3"}) {
+		t.Fatalf("bad: serials: %v", serials)
+	}
+}
@@ -1,39 +1,41 @@
 package ssh
 
 import (
-	"crypto/rand"
+	"bytes"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io"
 
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"golang.org/x/crypto/ssh"
 )
 
+const (
+	caTypeUser = "user"
+	caTypeHost = "host"
+)
+
+// caStoragePaths returns the storage locations of the signing bundle and the
+// public key for the given CA type
+func caStoragePaths(caType string) (bundlePath, publicKeyPath string) {
+	if caType == caTypeHost {
+		return "config/host_ca_bundle", "config/host_ca_public_key"
+	}
+	return "config/ca_bundle", "config/ca_public_key"
+}
+
 func pathConfigCA(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "config/ca",
-		Fields: map[string]*framework.FieldSchema{
-			"private_key": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Private half of the SSH key that will be used to sign certificates.`,
-			},
-			"public_key": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Public half of the SSH key that will be used to sign certificates.`,
-			},
-			"generate_signing_key": &framework.FieldSchema{
-				Type:        framework.TypeBool,
-				Description: `Generate SSH key pair internally rather than use the private_key and public_key fields.`,
-				Default:     true,
-			},
-		},
+		Fields:  configCAFields(),
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathConfigCAUpdate,
-			logical.DeleteOperation: b.pathConfigCADelete,
+			logical.UpdateOperation: b.pathConfigCAUpdate(caTypeUser),
+			logical.DeleteOperation: b.pathConfigCADelete(caTypeUser),
 		},
 
 		HelpSynopsis: `Set the SSH private key used for signing certificates.`,
@@ -44,21 +46,94 @@ For security reasons, the private key cannot be retrieved later.`,
 	}
 }
 
-func (b *backend) pathConfigCADelete(
-	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	if err := req.Storage.Delete("config/ca_bundle"); err != nil {
-		return nil, err
+func pathConfigHostCA(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/host_ca",
+		Fields:  configCAFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConfigCAUpdate(caTypeHost),
+			logical.DeleteOperation: b.pathConfigCADelete(caTypeHost),
+		},
+
+		HelpSynopsis: `Set the SSH private key used for signing host certificates.`,
+		HelpDescription: `This sets the CA information used for host certificates generated by
+roles of this mount that have 'use_host_ca' set. The fields must be in the
+standard private and public SSH format.
+
+For security reasons, the private key cannot be retrieved later.`,
 	}
-	if err := req.Storage.Delete("config/ca_public_key"); err != nil {
-		return nil, err
+}
+
+func configCAFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"private_key": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `Private half of the SSH key that will be used to sign certificates.`,
+		},
+		"private_key_passphrase": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: `Passphrase protecting private_key, if it is encrypted. Both the legacy
+PEM format and the OpenSSH format are supported. The key is decrypted when
+configured, and the passphrase is not stored.`,
+		},
+		"public_key": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: `Public half of the SSH key that will be used to sign certificates. With
+managed_key_name, it is optional and checked to be the public key of the
+managed key.`,
+		},
+		"generate_signing_key": &framework.FieldSchema{
+			Type:        framework.TypeBool,
+			Description: `Generate SSH key pair internally rather than use the private_key and public_key fields.`,
+			Default:     true,
+		},
+		"managed_key_name": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: `ID, ARN or alias of an AWS KMS key that holds the signing key. If set,
+certificates are signed by KMS and the private key is never stored in Vault.
+Only ECDSA keys are supported.`,
+		},
+		"managed_key_region": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `AWS region of the key given in managed_key_name. Defaults to the AWS_REGION environment variable or us-east-1.`,
+		},
 	}
-	return nil, nil
 }
 
-func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+func (b *backend) pathConfigCADelete(caType string) framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		bundlePath, publicKeyPath := caStoragePaths(caType)
+		defer b.resetCASigner(caType)
+		if err := req.Storage.Delete(bundlePath); err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Delete(publicKeyPath); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+}
+
+func (b *backend) pathConfigCAUpdate(caType string) framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		return b.configureCA(req, data, caType)
+	}
+}
+
+func (b *backend) configureCA(req *logical.Request, data *framework.FieldData, caType string) (*logical.Response, error) {
+	if data.Get("managed_key_name").(string) != "" {
+		return b.configureManagedCA(req, data, caType)
+	}
+
 	var err error
 	publicKey := data.Get("public_key").(string)
 	privateKey := data.Get("private_key").(string)
+	passphrase := data.Get("private_key_passphrase").(string)
+
+	if passphrase != "" && privateKey == "" {
+		return logical.ErrorResponse("private_key_passphrase must only be set along with private_key"), nil
+	}
 
 	var generateSigningKey bool
 
@@ -82,11 +157,21 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 			return logical.ErrorResponse("missing private_key"), nil
 		}
 
-		_, err := ssh.ParsePrivateKey([]byte(privateKey))
+		key, err := parseRawSSHPrivateKey([]byte(privateKey), passphrase)
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
 		}
 
+		// The key is decrypted once and stored without the passphrase,
+		// relying on the seal wrapping of the bundle
+		if passphrase != "" {
+			decrypted, err := marshalSSHPrivateKey(key)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("Unable to store private_key: %v", err)), nil
+			}
+			privateKey = string(decrypted)
+		}
+
 		_, err = parsePublicSSHKey(publicKey)
 		if err != nil {
 			return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
@@ -102,7 +187,7 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 	}
 
 	if generateSigningKey {
-		publicKey, privateKey, err = generateSSHKeyPair()
+		publicKey, privateKey, err = generateSSHKeyPair(b.GetRandomReader())
 		if err != nil {
 			return nil, err
 		}
@@ -112,14 +197,131 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 		return nil, fmt.Errorf("failed to generate or parse the keys")
 	}
 
-	publicKeyEntry, err := req.Storage.Get("config/ca_public_key")
+	return b.storeCA(req, caType, publicKey, signingBundle{
+		Certificate: privateKey,
+	})
+}
+
+// configureManagedCA configures the CA to sign with a key held in an
+// external key management service. Only the public key and the reference to
+// the managed key are stored.
+func (b *backend) configureManagedCA(req *logical.Request, data *framework.FieldData, caType string) (*logical.Response, error) {
+	if data.Get("private_key").(string) != "" || data.Get("private_key_passphrase").(string) != "" {
+		return logical.ErrorResponse("private_key and private_key_passphrase must not be set when managed_key_name is set"), nil
+	}
+
+	if generateSigningKey, ok := data.GetOk("generate_signing_key"); ok && generateSigningKey.(bool) {
+		return logical.ErrorResponse("generate_signing_key must not be set when managed_key_name is set"), nil
+	}
+
+	bundle := signingBundle{
+		ManagedKeyName:   data.Get("managed_key_name").(string),
+		ManagedKeyRegion: data.Get("managed_key_region").(string),
+	}
+
+	signer, err := b.managedKeySigner(&bundle)
 	if err != nil {
-		return nil, fmt.Errorf("failed while reading ca_public_key: %v", err)
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// When given, the public key must be the one of the managed key
+	if publicKey := data.Get("public_key").(string); publicKey != "" {
+		parsedPublicKey, err := parsePublicSSHKey(publicKey)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
+		}
+		if !bytes.Equal(parsedPublicKey.Marshal(), signer.PublicKey().Marshal()) {
+			return logical.ErrorResponse(fmt.Sprintf("public_key does not match the public key of managed key %q", bundle.ManagedKeyName)), nil
+		}
+	}
+
+	return b.storeCA(req, caType, string(ssh.MarshalAuthorizedKey(signer.PublicKey())), bundle)
+}
+
+// caSigner returns the signer of the given CA type, or nil if the CA is not
+// configured. The signer is built on first use and kept until the CA
+// configuration changes. Managed keys are checked to still match the public
+// key configured for the CA.
+func (b *backend) caSigner(s logical.Storage, caType string) (ssh.Signer, error) {
+	b.caSignersLock.RLock()
+	signer, ok := b.caSigners[caType]
+	b.caSignersLock.RUnlock()
+	if ok {
+		return signer, nil
+	}
+
+	b.caSignersLock.Lock()
+	defer b.caSignersLock.Unlock()
+
+	// Check again in case the signer was built while waiting for the lock
+	if signer, ok := b.caSigners[caType]; ok {
+		return signer, nil
 	}
 
-	privateKeyEntry, err := req.Storage.Get("config/ca_bundle")
+	bundlePath, publicKeyPath := caStoragePaths(caType)
+	storedBundle, err := s.Get(bundlePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed while reading ca_bundle: %v", err)
+		return nil, fmt.Errorf("unable to fetch local CA certificate/key: %v", err)
+	}
+	if storedBundle == nil {
+		return nil, nil
+	}
+
+	var bundle signingBundle
+	if err := storedBundle.DecodeJSON(&bundle); err != nil {
+		return nil, fmt.Errorf("unable to decode local CA certificate/key: %v", err)
+	}
+
+	if bundle.ManagedKeyName == "" {
+		signer, err = parseSSHPrivateKey([]byte(bundle.Certificate))
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("stored SSH signing key cannot be parsed: %v", err)}
+		}
+	} else {
+		signer, err = b.managedKeySigner(&bundle)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("unable to use managed signing key: %v", err)}
+		}
+
+		publicKeyEntry, err := s.Get(publicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed while reading %s: %v", publicKeyPath, err)
+		}
+		if publicKeyEntry == nil {
+			return nil, errutil.InternalError{Err: "public key of the managed signing key is missing"}
+		}
+		publicKey, err := parsePublicSSHKey(string(publicKeyEntry.Value))
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("stored SSH public key cannot be parsed: %v", err)}
+		}
+		if !bytes.Equal(publicKey.Marshal(), signer.PublicKey().Marshal()) {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("managed key %q does not match the configured public key", bundle.ManagedKeyName)}
+		}
+	}
+
+	b.caSigners[caType] = signer
+	return signer, nil
+}
+
+// resetCASigner drops the signer of the given CA type, which is built again
+// on next use
+func (b *backend) resetCASigner(caType string) {
+	b.caSignersLock.Lock()
+	defer b.caSignersLock.Unlock()
+	delete(b.caSigners, caType)
+}
+
+func (b *backend) storeCA(req *logical.Request, caType, publicKey string, bundle signingBundle) (*logical.Response, error) {
+	bundlePath, publicKeyPath := caStoragePaths(caType)
+
+	publicKeyEntry, err := req.Storage.Get(publicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed while reading %s: %v", publicKeyPath, err)
+	}
+
+	privateKeyEntry, err := req.Storage.Get(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed while reading %s: %v", bundlePath, err)
 	}
 
 	if publicKeyEntry != nil || privateKeyEntry != nil {
@@ -127,28 +329,25 @@ func (b *backend) pathConfigCAUpdate(req *logical.Request, data *framework.Field
 	}
 
 	err = req.Storage.Put(&logical.StorageEntry{
-		Key:   "config/ca_public_key",
+		Key:   publicKeyPath,
 		Value: []byte(publicKey),
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	bundle := signingBundle{
-		Certificate: privateKey,
-	}
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", bundle)
+	entry, err := logical.StorageEntryJSON(bundlePath, bundle)
 	if err != nil {
 		return nil, err
 	}
 
+	defer b.resetCASigner(caType)
 	err = req.Storage.Put(entry)
 	return nil, err
 }
 
-func generateSSHKeyPair() (string, string, error) {
-	privateSeed, err := rsa.GenerateKey(rand.Reader, 4096)
+func generateSSHKeyPair(randReader io.Reader) (string, string, error) {
+	privateSeed, err := rsa.GenerateKey(randReader, 4096)
 	if err != nil {
 		return "", "", err
 	}
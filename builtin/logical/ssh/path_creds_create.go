@@ -32,6 +32,10 @@ func pathCredsCreate(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "[Required] IP of the remote host",
 			},
+			"port": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "[Optional] Port of the remote host. Must be the port of the role or in its allowed ports",
+			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathCredsCreateWrite,
@@ -109,6 +113,21 @@ func (b *backend) pathCredsCreateWrite(
 		return logical.ErrorResponse(fmt.Sprintf("Error validating IP: %v", err)), nil
 	}
 
+	// Port is an optional parameter, defaulting to the port of the role
+	port := d.Get("port").(int)
+	if port == 0 {
+		port = role.Port
+	}
+	if port != role.Port {
+		allowed, err := portListContainsPort(port, role.AllowedPorts)
+		if err != nil {
+			return nil, fmt.Errorf("error validating port: %v", err)
+		}
+		if !allowed {
+			return logical.ErrorResponse(fmt.Sprintf("Port %d is not allowed by role", port)), nil
+		}
+	}
+
 	var result *logical.Response
 	if role.KeyType == KeyTypeOTP {
 		// Generate an OTP
@@ -130,14 +149,14 @@ func (b *backend) pathCredsCreateWrite(
 			"key":      otp,
 			"username": username,
 			"ip":       ip,
-			"port":     role.Port,
+			"port":     port,
 		}, map[string]interface{}{
 			"otp": otp,
 		})
 	} else if role.KeyType == KeyTypeDynamic {
 		// Generate an RSA key pair. This also installs the newly generated
 		// public key in the remote host.
-		dynamicPublicKey, dynamicPrivateKey, err := b.GenerateDynamicCredential(req, role, username, ip)
+		dynamicPublicKey, dynamicPrivateKey, err := b.GenerateDynamicCredential(req, role, username, ip, port)
 		if err != nil {
 			return nil, err
 		}
@@ -149,14 +168,14 @@ func (b *backend) pathCredsCreateWrite(
 			"key_type": role.KeyType,
 			"username": username,
 			"ip":       ip,
-			"port":     role.Port,
+			"port":     port,
 		}, map[string]interface{}{
 			"admin_user":         role.AdminUser,
 			"username":           username,
 			"ip":                 ip,
 			"host_key_name":      role.KeyName,
 			"dynamic_public_key": dynamicPublicKey,
-			"port":               role.Port,
+			"port":               port,
 			"install_script":     role.InstallScript,
 		})
 	} else {
@@ -167,7 +186,7 @@ func (b *backend) pathCredsCreateWrite(
 }
 
 // Generates a RSA key pair and installs it in the remote target
-func (b *backend) GenerateDynamicCredential(req *logical.Request, role *sshRole, username, ip string) (string, string, error) {
+func (b *backend) GenerateDynamicCredential(req *logical.Request, role *sshRole, username, ip string, port int) (string, string, error) {
 	// Fetch the host key to be used for dynamic key installation
 	keyEntry, err := req.Storage.Get(fmt.Sprintf("keys/%s", role.KeyName))
 	if err != nil {
@@ -194,7 +213,7 @@ func (b *backend) GenerateDynamicCredential(req *logical.Request, role *sshRole,
 	}
 
 	// Add the public key to authorized_keys file in target machine
-	err = b.installPublicKeyInTarget(role.AdminUser, username, ip, role.Port, hostKey.Key, dynamicPublicKey, role.InstallScript, true)
+	err = b.installPublicKeyInTarget(role.AdminUser, username, ip, port, hostKey.Key, dynamicPublicKey, role.InstallScript, true)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to add public key to authorized_keys file in target: %v", err)
 	}
@@ -10,7 +10,7 @@ func pathFetchPublicKey(b *backend) *framework.Path {
 		Pattern: `public_key`,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.ReadOperation: b.pathFetchPublicKey,
+			logical.ReadOperation: b.pathFetchPublicKey(caTypeUser),
 		},
 
 		HelpSynopsis:    `Retrieve the public key.`,
@@ -18,8 +18,28 @@ func pathFetchPublicKey(b *backend) *framework.Path {
 	}
 }
 
-func (b *backend) pathFetchPublicKey(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	entry, err := req.Storage.Get("config/ca_public_key")
+func pathFetchHostPublicKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `host_public_key`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchPublicKey(caTypeHost),
+		},
+
+		HelpSynopsis:    `Retrieve the host CA public key.`,
+		HelpDescription: `This allows the public key of the host CA, that this backend has been configured with, to be fetched.`,
+	}
+}
+
+func (b *backend) pathFetchPublicKey(caType string) framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		_, publicKeyPath := caStoragePaths(caType)
+		return fetchPublicKey(req, publicKeyPath)
+	}
+}
+
+func fetchPublicKey(req *logical.Request, publicKeyPath string) (*logical.Response, error) {
+	entry, err := req.Storage.Get(publicKeyPath)
 	if err != nil {
 		return nil, err
 	}
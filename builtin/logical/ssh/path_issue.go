@@ -0,0 +1,188 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
+)
+
+func pathIssue(b *backend) *framework.Path {
+	ret := &framework.Path{
+		Pattern: "issue/" + framework.GenericNameRegex("role"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssue,
+		},
+
+		HelpSynopsis:    pathIssueHelpSyn,
+		HelpDescription: pathIssueHelpDesc,
+	}
+
+	ret.Fields = addCertCommonFields(map[string]*framework.FieldSchema{})
+
+	ret.Fields["key_type"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Default:     "rsa",
+		Description: `Specifies the desired key type; must be "rsa" or "ec".`,
+	}
+
+	ret.Fields["key_bits"] = &framework.FieldSchema{
+		Type:    framework.TypeInt,
+		Default: 0,
+		Description: `Specifies the number of bits to use for the
+generated keys. If set to 0, the default for the
+key type is used: 2048 for "rsa" and 256 for "ec".`,
+	}
+
+	return ret
+}
+
+// pathIssue generates a key pair, signs the public half according to the
+// given role and returns both the private key and the signed certificate
+func (b *backend) pathIssue(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+
+	// Get the role
+	role, err := b.getRole(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("Unknown role: %s", roleName)), nil
+	}
+
+	if role.KeyType != KeyTypeCA {
+		return logical.ErrorResponse("role key type must be 'ca'"), nil
+	}
+
+	keyType := data.Get("key_type").(string)
+	keyBits := data.Get("key_bits").(int)
+	if keyBits == 0 {
+		keyBits = defaultIssueKeyBits[keyType]
+	}
+
+	if role.AllowedIssueKeyTypes != "" && !strutil.StrListContains(strings.Split(role.AllowedIssueKeyTypes, ","), keyType) {
+		return logical.ErrorResponse(fmt.Sprintf("key type %q is not allowed by the role", keyType)), nil
+	}
+	if keyBits < role.MinIssueKeyBits {
+		return logical.ErrorResponse(fmt.Sprintf("keys of %d bits are below the minimum of %d bits of the role", keyBits, role.MinIssueKeyBits)), nil
+	}
+
+	publicKey, privateKey, err := generateSSHKeyPairForIssue(keyType, keyBits)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	resp, err := b.signPublicKey(req, data, role, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.IsError() {
+		return resp, nil
+	}
+
+	resp.Data["private_key"] = privateKey
+	resp.Data["private_key_type"] = keyType
+
+	return resp, nil
+}
+
+// defaultIssueKeyBits holds the size of the keys generated for each key type
+// when no size is requested
+var defaultIssueKeyBits = map[string]int{
+	"rsa": 2048,
+	"ec":  256,
+}
+
+// generateSSHKeyPairForIssue generates a private key of the given type and
+// size, returning the SSH public key along with the PEM encoded private key
+func generateSSHKeyPairForIssue(keyType string, keyBits int) (ssh.PublicKey, string, error) {
+	var privateBlock *pem.Block
+	var publicKey ssh.PublicKey
+
+	switch keyType {
+	case "rsa":
+		switch keyBits {
+		case 2048, 3072, 4096:
+		default:
+			return nil, "", fmt.Errorf("unsupported bit length for RSA key: %d", keyBits)
+		}
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+		if err != nil {
+			return nil, "", fmt.Errorf("error generating RSA private key: %v", err)
+		}
+
+		publicKey, err = ssh.NewPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return nil, "", err
+		}
+
+		privateBlock = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}
+
+	case "ec":
+		var curve elliptic.Curve
+		switch keyBits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, "", fmt.Errorf("unsupported bit length for EC key: %d", keyBits)
+		}
+
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, "", fmt.Errorf("error generating EC private key: %v", err)
+		}
+
+		publicKey, err = ssh.NewPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return nil, "", err
+		}
+
+		marshaledKey, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, "", fmt.Errorf("error marshaling EC private key: %v", err)
+		}
+
+		privateBlock = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: marshaledKey,
+		}
+
+	default:
+		return nil, "", fmt.Errorf("unknown key type: %s", keyType)
+	}
+
+	return publicKey, string(pem.EncodeToMemory(privateBlock)), nil
+}
+
+const pathIssueHelpSyn = `
+Request a new key pair and a signed SSH certificate using a certain role.
+`
+
+const pathIssueHelpDesc = `
+This path generates a new key pair of the requested type and size, signs the
+public half according to the policy of the given role and returns the private
+key along with the signed certificate. The private key is not stored and cannot
+be retrieved later.
+
+The role can restrict the types of the generated keys with
+"allowed_issue_key_types" and their size with "min_issue_key_bits".
+`
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/helper/cidrutil"
+	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -22,28 +23,37 @@ const (
 // for both OTP and Dynamic roles. Not all the fields are mandatory for both type.
 // Some are applicable for one and not for other. It doesn't matter.
 type sshRole struct {
-	KeyType                string            `mapstructure:"key_type" json:"key_type"`
-	KeyName                string            `mapstructure:"key" json:"key"`
-	KeyBits                int               `mapstructure:"key_bits" json:"key_bits"`
-	AdminUser              string            `mapstructure:"admin_user" json:"admin_user"`
-	DefaultUser            string            `mapstructure:"default_user" json:"default_user"`
-	CIDRList               string            `mapstructure:"cidr_list" json:"cidr_list"`
-	ExcludeCIDRList        string            `mapstructure:"exclude_cidr_list" json:"exclude_cidr_list"`
-	Port                   int               `mapstructure:"port" json:"port"`
-	InstallScript          string            `mapstructure:"install_script" json:"install_script"`
-	AllowedUsers           string            `mapstructure:"allowed_users" json:"allowed_users"`
-	AllowedDomains         string            `mapstructure:"allowed_domains" json:"allowed_domains"`
-	KeyOptionSpecs         string            `mapstructure:"key_option_specs" json:"key_option_specs"`
-	MaxTTL                 string            `mapstructure:"max_ttl" json:"max_ttl"`
-	TTL                    string            `mapstructure:"ttl" json:"ttl"`
-	DefaultCriticalOptions map[string]string `mapstructure:"default_critical_options" json:"default_critical_options"`
-	DefaultExtensions      map[string]string `mapstructure:"default_extensions" json:"default_extensions"`
-	AllowedCriticalOptions string            `mapstructure:"allowed_critical_options" json:"allowed_critical_options"`
-	AllowedExtensions      string            `mapstructure:"allowed_extensions" json:"allowed_extensions"`
-	AllowUserCertificates  bool              `mapstructure:"allow_user_certificates" json:"allow_user_certificates"`
-	AllowHostCertificates  bool              `mapstructure:"allow_host_certificates" json:"allow_host_certificates"`
-	AllowBareDomains       bool              `mapstructure:"allow_bare_domains" json:"allow_bare_domains"`
-	AllowSubdomains        bool              `mapstructure:"allow_subdomains" json:"allow_subdomains"`
+	KeyType                   string            `mapstructure:"key_type" json:"key_type"`
+	KeyName                   string            `mapstructure:"key" json:"key"`
+	KeyBits                   int               `mapstructure:"key_bits" json:"key_bits"`
+	AdminUser                 string            `mapstructure:"admin_user" json:"admin_user"`
+	DefaultUser               string            `mapstructure:"default_user" json:"default_user"`
+	CIDRList                  string            `mapstructure:"cidr_list" json:"cidr_list"`
+	ExcludeCIDRList           string            `mapstructure:"exclude_cidr_list" json:"exclude_cidr_list"`
+	Port                      int               `mapstructure:"port" json:"port"`
+	AllowedPorts              string            `mapstructure:"allowed_ports" json:"allowed_ports"`
+	InstallScript             string            `mapstructure:"install_script" json:"install_script"`
+	AllowedUsers              string            `mapstructure:"allowed_users" json:"allowed_users"`
+	AllowedDomains            string            `mapstructure:"allowed_domains" json:"allowed_domains"`
+	KeyOptionSpecs            string            `mapstructure:"key_option_specs" json:"key_option_specs"`
+	MaxTTL                    string            `mapstructure:"max_ttl" json:"max_ttl"`
+	TTL                       string            `mapstructure:"ttl" json:"ttl"`
+	DefaultCriticalOptions    map[string]string `mapstructure:"default_critical_options" json:"default_critical_options"`
+	DefaultExtensions         map[string]string `mapstructure:"default_extensions" json:"default_extensions"`
+	AllowedCriticalOptions    string            `mapstructure:"allowed_critical_options" json:"allowed_critical_options"`
+	AllowedExtensions         string            `mapstructure:"allowed_extensions" json:"allowed_extensions"`
+	AllowUserCertificates     bool              `mapstructure:"allow_user_certificates" json:"allow_user_certificates"`
+	AllowHostCertificates     bool              `mapstructure:"allow_host_certificates" json:"allow_host_certificates"`
+	AllowBareDomains          bool              `mapstructure:"allow_bare_domains" json:"allow_bare_domains"`
+	AllowSubdomains           bool              `mapstructure:"allow_subdomains" json:"allow_subdomains"`
+	UseHostCA                 bool              `mapstructure:"use_host_ca" json:"use_host_ca"`
+	AllowedUsersTemplate      bool              `mapstructure:"allowed_users_template" json:"allowed_users_template"`
+	DefaultExtensionsTemplate bool              `mapstructure:"default_extensions_template" json:"default_extensions_template"`
+	NoStore                   *bool             `mapstructure:"no_store" json:"no_store,omitempty"`
+	DefaultUserTemplate       bool              `mapstructure:"default_user_template" json:"default_user_template"`
+	PrincipalMaxTTLs          map[string]string `mapstructure:"principal_max_ttls" json:"principal_max_ttls"`
+	AllowedIssueKeyTypes      string            `mapstructure:"allowed_issue_key_types" json:"allowed_issue_key_types"`
+	MinIssueKeyBits           int               `mapstructure:"min_issue_key_bits" json:"min_issue_key_bits"`
 }
 
 func pathListRoles(b *backend) *framework.Path {
@@ -118,6 +128,14 @@ func pathRoles(b *backend) *framework.Path {
 				to inform client about the port number to use. Port number will be
 				returned to client by Vault server along with OTP.`,
 			},
+			"allowed_ports": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Optional for Dynamic type] [Optional for OTP type] [Not applicable for CA type]
+				Comma separated list of ports and port ranges, e.g. "22,2200-2299", that
+				clients can request credentials for in addition to 'port'. Clients select
+				the port using the 'port' parameter of the 'creds/' endpoint.`,
+			},
 			"key_type": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `
@@ -251,6 +269,77 @@ func pathRoles(b *backend) *framework.Path {
 				If set, host certificates that are requested are allowed to use subdomains of those listed in "allowed_domains".
 				`,
 			},
+			"allowed_users_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, "allowed_users" can contain identity templates such as
+				"{{identity.metadata.username}}" that are resolved at signing time
+				against the login the client token was issued by, or created from.
+				`,
+			},
+			"default_extensions_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, the values of "default_extensions" can contain identity templates
+				such as "{{identity.metadata.username}}" that are resolved at signing
+				time against the login the client token was issued by, or created from.
+				`,
+			},
+			"default_user_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, "default_user" can contain an identity template such as
+				"{{identity.metadata.username}}" that is resolved at signing time
+				against the login the client token was issued by, or created from.
+				`,
+			},
+			"principal_max_ttls": &framework.FieldSchema{
+				Type: framework.TypeMap,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				Maximum TTLs of user certificates per principal, e.g. {"root": "15m"}.
+				A certificate that includes one of these principals cannot be valid for
+				longer than the principal's TTL, even if the role allows it.
+				`,
+			},
+			"allowed_issue_key_types": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				Comma-separated list of the key types, "rsa" or "ec", that the 'issue/'
+				endpoint may generate for this role. Defaults to all key types.
+				`,
+			},
+			"min_issue_key_bits": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				Minimum size in bits of the keys generated by the 'issue/' endpoint for
+				this role, whatever their type; EC keys have 256, 384 or 521 bits.
+				Defaults to 0, allowing every supported size.
+				`,
+			},
+			"no_store": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set to false, the serial number, key ID, principals and expiration of every
+				certificate signed by this role are stored so that they can be listed at 'certs/'
+				and revoked. Defaults to true.
+				`,
+				Default: true,
+			},
+			"use_host_ca": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, host certificates are signed with the key configured at 'config/host_ca'
+				instead of the one configured at 'config/ca'.
+				`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -302,6 +391,13 @@ func (b *backend) pathRoleWrite(req *logical.Request, d *framework.FieldData) (*
 		port = 22
 	}
 
+	allowedPorts := d.Get("allowed_ports").(string)
+	if allowedPorts != "" {
+		if _, err := parsePortRanges(allowedPorts); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to validate allowed_ports: %v", err)), nil
+		}
+	}
+
 	keyType := d.Get("key_type").(string)
 	if keyType == "" {
 		return logical.ErrorResponse("missing key type"), nil
@@ -329,6 +425,7 @@ func (b *backend) pathRoleWrite(req *logical.Request, d *framework.FieldData) (*
 			ExcludeCIDRList: excludeCidrList,
 			KeyType:         KeyTypeOTP,
 			Port:            port,
+			AllowedPorts:    allowedPorts,
 			AllowedUsers:    allowedUsers,
 		}
 	} else if keyType == KeyTypeDynamic {
@@ -379,6 +476,7 @@ func (b *backend) pathRoleWrite(req *logical.Request, d *framework.FieldData) (*
 			CIDRList:        cidrList,
 			ExcludeCIDRList: excludeCidrList,
 			Port:            port,
+			AllowedPorts:    allowedPorts,
 			KeyType:         KeyTypeDynamic,
 			KeyBits:         keyBits,
 			InstallScript:   installScript,
@@ -409,23 +507,71 @@ func (b *backend) pathRoleWrite(req *logical.Request, d *framework.FieldData) (*
 func (b *backend) createCARole(allowedUsers, defaultUser string, data *framework.FieldData) (*sshRole, *logical.Response) {
 
 	role := &sshRole{
-		MaxTTL: data.Get("max_ttl").(string),
-		TTL:    data.Get("ttl").(string),
-		AllowedCriticalOptions: data.Get("allowed_critical_options").(string),
-		AllowedExtensions:      data.Get("allowed_extensions").(string),
-		AllowUserCertificates:  data.Get("allow_user_certificates").(bool),
-		AllowHostCertificates:  data.Get("allow_host_certificates").(bool),
-		AllowedUsers:           allowedUsers,
-		AllowedDomains:         data.Get("allowed_domains").(string),
-		DefaultUser:            defaultUser,
-		AllowBareDomains:       data.Get("allow_bare_domains").(bool),
-		AllowSubdomains:        data.Get("allow_subdomains").(bool),
-		KeyType:                KeyTypeCA,
+		MaxTTL:                    data.Get("max_ttl").(string),
+		TTL:                       data.Get("ttl").(string),
+		AllowedCriticalOptions:    data.Get("allowed_critical_options").(string),
+		AllowedExtensions:         data.Get("allowed_extensions").(string),
+		AllowUserCertificates:     data.Get("allow_user_certificates").(bool),
+		AllowHostCertificates:     data.Get("allow_host_certificates").(bool),
+		AllowedUsers:              allowedUsers,
+		AllowedDomains:            data.Get("allowed_domains").(string),
+		DefaultUser:               defaultUser,
+		AllowBareDomains:          data.Get("allow_bare_domains").(bool),
+		AllowSubdomains:           data.Get("allow_subdomains").(bool),
+		UseHostCA:                 data.Get("use_host_ca").(bool),
+		AllowedUsersTemplate:      data.Get("allowed_users_template").(bool),
+		DefaultExtensionsTemplate: data.Get("default_extensions_template").(bool),
+		NoStore:                   new(bool),
+		DefaultUserTemplate:       data.Get("default_user_template").(bool),
+		AllowedIssueKeyTypes:      data.Get("allowed_issue_key_types").(string),
+		MinIssueKeyBits:           data.Get("min_issue_key_bits").(int),
+		KeyType:                   KeyTypeCA,
 	}
 
+	*role.NoStore = data.Get("no_store").(bool)
+
 	defaultCriticalOptions := convertMapToStringValue(data.Get("default_critical_options").(map[string]interface{}))
 	defaultExtensions := convertMapToStringValue(data.Get("default_extensions").(map[string]interface{}))
 
+	if role.DefaultUserTemplate {
+		if err := identity.ValidateTemplate(role.DefaultUser); err != nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("invalid template in default_user: %v", err))
+		}
+	}
+
+	principalMaxTTLs := convertMapToStringValue(data.Get("principal_max_ttls").(map[string]interface{}))
+	for principal, principalMaxTTL := range principalMaxTTLs {
+		if _, err := parseutil.ParseDurationSecond(principalMaxTTL); err != nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("invalid max ttl for principal %q: %s", principal, err))
+		}
+	}
+	role.PrincipalMaxTTLs = principalMaxTTLs
+
+	if role.AllowedIssueKeyTypes != "" {
+		for _, keyType := range strings.Split(role.AllowedIssueKeyTypes, ",") {
+			if keyType != "rsa" && keyType != "ec" {
+				return nil, logical.ErrorResponse(fmt.Sprintf("invalid key type in allowed_issue_key_types: %q", keyType))
+			}
+		}
+	}
+	if role.MinIssueKeyBits < 0 {
+		return nil, logical.ErrorResponse("min_issue_key_bits cannot be negative")
+	}
+
+	if role.AllowedUsersTemplate {
+		if err := identity.ValidateTemplate(role.AllowedUsers); err != nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("invalid template in allowed_users: %v", err))
+		}
+	}
+
+	if role.DefaultExtensionsTemplate {
+		for extension, value := range defaultExtensions {
+			if err := identity.ValidateTemplate(value); err != nil {
+				return nil, logical.ErrorResponse(fmt.Sprintf("invalid template in default extension %q: %v", extension, err))
+			}
+		}
+	}
+
 	var maxTTL time.Duration
 	maxSystemTTL := b.System().MaxLeaseTTL()
 	if len(role.MaxTTL) == 0 {
@@ -472,6 +618,12 @@ func (b *backend) createCARole(allowedUsers, defaultUser string, data *framework
 	return role, nil
 }
 
+// noStore returns whether certificates signed by the role are not recorded.
+// Roles created before the option existed never record certificates.
+func (r *sshRole) noStore() bool {
+	return r.NoStore == nil || *r.NoStore
+}
+
 func (b *backend) getRole(s logical.Storage, n string) (*sshRole, error) {
 	entry, err := s.Get("roles/" + n)
 	if err != nil {
@@ -516,26 +668,35 @@ func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*l
 				"exclude_cidr_list": role.ExcludeCIDRList,
 				"key_type":          role.KeyType,
 				"port":              role.Port,
+				"allowed_ports":     role.AllowedPorts,
 				"allowed_users":     role.AllowedUsers,
 			},
 		}, nil
 	} else if role.KeyType == KeyTypeCA {
 		return &logical.Response{
 			Data: map[string]interface{}{
-				"allowed_users":   role.AllowedUsers,
-				"allowed_domains": role.AllowedDomains,
-				"default_user":    role.DefaultUser,
-				"max_ttl":         role.MaxTTL,
-				"ttl":             role.TTL,
-				"allowed_critical_options": role.AllowedCriticalOptions,
-				"allowed_extensions":       role.AllowedExtensions,
-				"allow_user_certificates":  role.AllowUserCertificates,
-				"allow_host_certificates":  role.AllowHostCertificates,
-				"allow_bare_domains":       role.AllowBareDomains,
-				"allow_subdomains":         role.AllowSubdomains,
-				"key_type":                 role.KeyType,
-				"default_critical_options": role.DefaultCriticalOptions,
-				"default_extensions":       role.DefaultExtensions,
+				"allowed_users":               role.AllowedUsers,
+				"allowed_domains":             role.AllowedDomains,
+				"default_user":                role.DefaultUser,
+				"max_ttl":                     role.MaxTTL,
+				"ttl":                         role.TTL,
+				"allowed_critical_options":    role.AllowedCriticalOptions,
+				"allowed_extensions":          role.AllowedExtensions,
+				"allow_user_certificates":     role.AllowUserCertificates,
+				"allow_host_certificates":     role.AllowHostCertificates,
+				"allow_bare_domains":          role.AllowBareDomains,
+				"allow_subdomains":            role.AllowSubdomains,
+				"use_host_ca":                 role.UseHostCA,
+				"allowed_users_template":      role.AllowedUsersTemplate,
+				"default_extensions_template": role.DefaultExtensionsTemplate,
+				"no_store":                    role.noStore(),
+				"default_user_template":       role.DefaultUserTemplate,
+				"principal_max_ttls":          role.PrincipalMaxTTLs,
+				"allowed_issue_key_types":     role.AllowedIssueKeyTypes,
+				"min_issue_key_bits":          role.MinIssueKeyBits,
+				"key_type":                    role.KeyType,
+				"default_critical_options":    role.DefaultCriticalOptions,
+				"default_extensions":          role.DefaultExtensions,
 			},
 		}, nil
 	} else {
@@ -547,6 +708,7 @@ func (b *backend) pathRoleRead(req *logical.Request, d *framework.FieldData) (*l
 				"cidr_list":         role.CIDRList,
 				"exclude_cidr_list": role.ExcludeCIDRList,
 				"port":              role.Port,
+				"allowed_ports":     role.AllowedPorts,
 				"key_type":          role.KeyType,
 				"key_bits":          role.KeyBits,
 				"allowed_users":     role.AllowedUsers,
@@ -9,16 +9,20 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/helper/certutil"
-	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 	"golang.org/x/crypto/ssh"
 )
 
 type signingBundle struct {
-	Certificate string `json:"certificate" structs:"certificate" mapstructure:"certificate"`
+	Certificate      string `json:"certificate" structs:"certificate" mapstructure:"certificate"`
+	ManagedKeyName   string `json:"managed_key_name" structs:"managed_key_name" mapstructure:"managed_key_name"`
+	ManagedKeyRegion string `json:"managed_key_region" structs:"managed_key_region" mapstructure:"managed_key_region"`
 }
 
 type creationBundle struct {
@@ -27,63 +31,86 @@ type creationBundle struct {
 	PublicKey       ssh.PublicKey
 	CertificateType uint32
 	TTL             time.Duration
-	SigningBundle   signingBundle
+	Signer          ssh.Signer
 	Role            *sshRole
 	criticalOptions map[string]string
 	extensions      map[string]string
 }
 
 func pathSign(b *backend) *framework.Path {
-	return &framework.Path{
+	ret := &framework.Path{
 		Pattern: "sign/" + framework.GenericNameRegex("role"),
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathSign,
 		},
 
-		Fields: map[string]*framework.FieldSchema{
-			"role": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `The desired role with configuration for this request.`,
-			},
-			"ttl": &framework.FieldSchema{
-				Type: framework.TypeString,
-				Description: `The requested Time To Live for the SSH certificate;
+		HelpSynopsis:    `Request signing an SSH key using a certain role with the provided details.`,
+		HelpDescription: `This path allows SSH keys to be signed according to the policy of the given role.`,
+	}
+
+	ret.Fields = addCertCommonFields(map[string]*framework.FieldSchema{})
+
+	ret.Fields["public_key"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `SSH public key that should be signed.`,
+	}
+
+	ret.Fields["batch_input"] = &framework.FieldSchema{
+		Type: framework.TypeSlice,
+		Description: `List of items to sign in a single request, each holding a
+'public_key' and optionally 'valid_principals' and 'key_id'. The other
+parameters of the request apply to all the items. If set, 'public_key' is
+ignored and the result of each item is returned in 'batch_results'.`,
+	}
+
+	return ret
+}
+
+// addCertCommonFields adds the fields shared by the endpoints that produce
+// signed certificates
+func addCertCommonFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["role"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The desired role with configuration for this request.`,
+	}
+
+	fields["ttl"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `The requested Time To Live for the SSH certificate;
 sets the expiration date. If not specified
 the role default, backend default, or system
 default TTL is used, in that order. Cannot
 be later than the role max TTL.`,
-			},
-			"public_key": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `SSH public key that should be signed.`,
-			},
-			"valid_principals": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Valid principals, either usernames or hostnames, that the certificate should be signed for.`,
-			},
-			"cert_type": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Type of certificate to be created; either "user" or "host".`,
-				Default:     "user",
-			},
-			"key_id": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Key id that the created certificate should have. If not specified, the display name of the token will be used.`,
-			},
-			"critical_options": &framework.FieldSchema{
-				Type:        framework.TypeMap,
-				Description: `Critical options that the certificate should be signed for.`,
-			},
-			"extensions": &framework.FieldSchema{
-				Type:        framework.TypeMap,
-				Description: `Extensions that the certificate should be signed for.`,
-			},
-		},
+	}
 
-		HelpSynopsis:    `Request signing an SSH key using a certain role with the provided details.`,
-		HelpDescription: `This path allows SSH keys to be signed according to the policy of the given role.`,
+	fields["valid_principals"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Valid principals, either usernames or hostnames, that the certificate should be signed for.`,
+	}
+
+	fields["cert_type"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Type of certificate to be created; either "user" or "host".`,
+		Default:     "user",
+	}
+
+	fields["key_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Key id that the created certificate should have. If not specified, the display name of the token will be used.`,
+	}
+
+	fields["critical_options"] = &framework.FieldSchema{
+		Type:        framework.TypeMap,
+		Description: `Critical options that the certificate should be signed for.`,
+	}
+
+	fields["extensions"] = &framework.FieldSchema{
+		Type:        framework.TypeMap,
+		Description: `Extensions that the certificate should be signed for.`,
 	}
+
+	return fields
 }
 
 func (b *backend) pathSign(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -98,9 +125,86 @@ func (b *backend) pathSign(req *logical.Request, data *framework.FieldData) (*lo
 		return logical.ErrorResponse(fmt.Sprintf("Unknown role: %s", roleName)), nil
 	}
 
+	if data.Raw["batch_input"] != nil {
+		return b.pathSignBatch(req, data, role)
+	}
+
 	return b.pathSignCertificate(req, data, role)
 }
 
+// batchSignRequestItem represents a public key to be signed as part of a
+// batch signing request. Parameters that are not set in the item are taken
+// from the request itself.
+type batchSignRequestItem struct {
+	PublicKey       string `json:"public_key" structs:"public_key" mapstructure:"public_key"`
+	ValidPrincipals string `json:"valid_principals" structs:"valid_principals" mapstructure:"valid_principals"`
+	KeyId           string `json:"key_id" structs:"key_id" mapstructure:"key_id"`
+}
+
+// batchSignResponseItem represents the result of signing a single public key
+// of a batch signing request
+type batchSignResponseItem struct {
+	SerialNumber string `json:"serial_number,omitempty" structs:"serial_number" mapstructure:"serial_number"`
+	SignedKey    string `json:"signed_key,omitempty" structs:"signed_key" mapstructure:"signed_key"`
+
+	// Error, if set represents a failure encountered while signing the
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+// pathSignBatch signs every public key given in the batch input. If signing
+// of any item fails because of invalid input, the error is recorded in the
+// corresponding response item and the other items are still processed.
+func (b *backend) pathSignBatch(req *logical.Request, data *framework.FieldData, role *sshRole) (*logical.Response, error) {
+	var batchInputItems []batchSignRequestItem
+	if err := mapstructure.Decode(data.Get("batch_input"), &batchInputItems); err != nil {
+		return nil, fmt.Errorf("failed to parse batch input: %v", err)
+	}
+
+	if len(batchInputItems) == 0 {
+		return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+	}
+
+	batchResponseItems := make([]batchSignResponseItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		itemRaw := make(map[string]interface{}, len(data.Raw))
+		for k, v := range data.Raw {
+			if k != "batch_input" {
+				itemRaw[k] = v
+			}
+		}
+		itemRaw["public_key"] = item.PublicKey
+		if item.ValidPrincipals != "" {
+			itemRaw["valid_principals"] = item.ValidPrincipals
+		}
+		if item.KeyId != "" {
+			itemRaw["key_id"] = item.KeyId
+		}
+
+		resp, err := b.pathSignCertificate(req, &framework.FieldData{
+			Raw:    itemRaw,
+			Schema: data.Schema,
+		}, role)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.IsError() {
+			batchResponseItems[i].Error = resp.Data["error"].(string)
+			continue
+		}
+
+		batchResponseItems[i].SerialNumber = resp.Data["serial_number"].(string)
+		batchResponseItems[i].SignedKey = resp.Data["signed_key"].(string)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"batch_results": batchResponseItems,
+		},
+	}, nil
+}
+
 func (b *backend) pathSignCertificate(req *logical.Request, data *framework.FieldData, role *sshRole) (*logical.Response, error) {
 	publicKey := data.Get("public_key").(string)
 	if publicKey == "" {
@@ -112,6 +216,13 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 		return logical.ErrorResponse(fmt.Sprintf("unable to decode \"public_key\" as SSH key: %s", err)), nil
 	}
 
+	return b.signPublicKey(req, data, role, userPublicKey)
+}
+
+// signPublicKey signs the given public key according to the policy of the
+// role and the request parameters, returning the signed certificate in the
+// response.
+func (b *backend) signPublicKey(req *logical.Request, data *framework.FieldData, role *sshRole, userPublicKey ssh.PublicKey) (*logical.Response, error) {
 	keyId := data.Get("key_id").(string)
 	if keyId == "" {
 		keyId = req.DisplayName
@@ -131,13 +242,24 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 			return logical.ErrorResponse(err.Error()), nil
 		}
 	} else {
-		parsedPrincipals, err = b.calculateValidPrincipals(data, role.DefaultUser, role.AllowedUsers, strutil.StrListContains)
+		allowedUsers := role.AllowedUsers
+		if role.AllowedUsersTemplate {
+			allowedUsers = populateAllowedUsersTemplate(role.AllowedUsers, requestIdentity(req))
+		}
+		defaultUser := role.DefaultUser
+		if role.DefaultUserTemplate && defaultUser != "" && data.Get("valid_principals").(string) == "" {
+			defaultUser, err = populatePrincipalTemplate(role.DefaultUser, requestIdentity(req))
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to resolve default_user template: %v", err)), nil
+			}
+		}
+		parsedPrincipals, err = b.calculateValidPrincipals(data, defaultUser, allowedUsers, strutil.StrListContains)
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
 	}
 
-	ttl, err := b.calculateTTL(data, role)
+	ttl, err := b.calculateTTL(data, role, certificateType, parsedPrincipals)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
 	}
@@ -147,28 +269,31 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	extensions, err := b.calculateExtensions(data, role)
+	extensions, err := b.calculateExtensions(req, data, role)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	storedBundle, err := req.Storage.Get("config/ca_bundle")
+	caType := caTypeUser
+	if certificateType == ssh.HostCert && role.UseHostCA {
+		caType = caTypeHost
+	}
+
+	signer, err := b.caSigner(req.Storage, caType)
 	if err != nil {
-		return nil, fmt.Errorf("unable to fetch local CA certificate/key: %v", err)
+		return nil, err
 	}
-	if storedBundle == nil {
+	if signer == nil {
+		if caType == caTypeHost {
+			return logical.ErrorResponse("backend must be configured with a host CA certificate/key"), nil
+		}
 		return logical.ErrorResponse("backend must be configured with a CA certificate/key"), nil
 	}
 
-	var bundle signingBundle
-	if err := storedBundle.DecodeJSON(&bundle); err != nil {
-		return nil, fmt.Errorf("unable to decode local CA certificate/key: %v", err)
-	}
-
 	signingBundle := creationBundle{
 		KeyId:           keyId,
 		PublicKey:       userPublicKey,
-		SigningBundle:   bundle,
+		Signer:          signer,
 		ValidPrincipals: parsedPrincipals,
 		TTL:             ttl,
 		CertificateType: certificateType,
@@ -187,6 +312,12 @@ func (b *backend) pathSignCertificate(req *logical.Request, data *framework.Fiel
 		return nil, fmt.Errorf("error marshaling signed certificate")
 	}
 
+	if !role.noStore() {
+		if err := b.storeIssuedCertificate(req.Storage, data.Get("role").(string), caType, certificate); err != nil {
+			return nil, fmt.Errorf("unable to store certificate: %v", err)
+		}
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			"serial_number": strconv.FormatUint(certificate.Serial, 16),
@@ -291,9 +422,12 @@ func (b *backend) calculateCriticalOptions(data *framework.FieldData, role *sshR
 	return criticalOptions, nil
 }
 
-func (b *backend) calculateExtensions(data *framework.FieldData, role *sshRole) (map[string]string, error) {
+func (b *backend) calculateExtensions(req *logical.Request, data *framework.FieldData, role *sshRole) (map[string]string, error) {
 	unparsedExtensions := data.Get("extensions").(map[string]interface{})
 	if len(unparsedExtensions) == 0 {
+		if role.DefaultExtensionsTemplate {
+			return populateExtensionsTemplate(role.DefaultExtensions, requestIdentity(req)), nil
+		}
 		return role.DefaultExtensions, nil
 	}
 
@@ -317,7 +451,71 @@ func (b *backend) calculateExtensions(data *framework.FieldData, role *sshRole)
 	return extensions, nil
 }
 
-func (b *backend) calculateTTL(data *framework.FieldData, role *sshRole) (time.Duration, error) {
+// requestIdentity returns the identity of the caller that templated role
+// fields are resolved against. It is the identity of the login the client
+// token descends from, so that it cannot be chosen through the token store;
+// nil is returned when there is no such login.
+func requestIdentity(req *logical.Request) *identity.Identity {
+	if req.LoginDisplayName == "" {
+		return nil
+	}
+	return &identity.Identity{
+		DisplayName: req.LoginDisplayName,
+		Metadata:    req.ClientTokenMeta,
+	}
+}
+
+// populateAllowedUsersTemplate resolves the templates in the comma separated
+// list of allowed users. Entries that cannot be resolved for the caller are
+// dropped so that they never match a requested principal.
+func populateAllowedUsersTemplate(allowedUsers string, ident *identity.Identity) string {
+	if allowedUsers == "*" {
+		return allowedUsers
+	}
+
+	var populated []string
+	for _, user := range strings.Split(allowedUsers, ",") {
+		user, err := populatePrincipalTemplate(user, ident)
+		if err != nil {
+			continue
+		}
+		populated = append(populated, user)
+	}
+
+	return strings.Join(populated, ",")
+}
+
+// populatePrincipalTemplate resolves the templates of a single principal. The
+// result must remain a single principal: a value holding a comma would
+// otherwise add principals to the comma separated lists it is part of.
+func populatePrincipalTemplate(principal string, ident *identity.Identity) (string, error) {
+	populated, err := identity.PopulateString(principal, ident)
+	if err != nil {
+		return "", err
+	}
+	if populated != principal && strings.Contains(populated, ",") {
+		return "", fmt.Errorf("template %q resolves to more than one principal", principal)
+	}
+	return populated, nil
+}
+
+// populateExtensionsTemplate resolves the templates in the values of the
+// given extensions. Extensions whose value cannot be resolved for the caller
+// are left out of the certificate.
+func populateExtensionsTemplate(extensions map[string]string, ident *identity.Identity) map[string]string {
+	populated := make(map[string]string, len(extensions))
+	for extension, value := range extensions {
+		value, err := identity.PopulateString(value, ident)
+		if err != nil {
+			continue
+		}
+		populated[extension] = value
+	}
+
+	return populated
+}
+
+func (b *backend) calculateTTL(data *framework.FieldData, role *sshRole, certificateType uint32, principals []string) (time.Duration, error) {
 
 	var ttl, maxTTL time.Duration
 	var ttlField string
@@ -358,15 +556,33 @@ func (b *backend) calculateTTL(data *framework.FieldData, role *sshRole) (time.D
 		}
 	}
 
+	// Apply the caps of the requested principals; as above, only error if
+	// the TTL was specifically requested
+	if certificateType == ssh.UserCert {
+		for _, principal := range principals {
+			principalMaxTTLRaw, found := role.PrincipalMaxTTLs[principal]
+			if !found {
+				continue
+			}
+
+			principalMaxTTL, err := parseutil.ParseDurationSecond(principalMaxTTLRaw)
+			if err != nil {
+				return 0, fmt.Errorf("invalid max ttl for principal %q: %s", principal, err)
+			}
+
+			if ttl > principalMaxTTL {
+				if ok {
+					return 0, fmt.Errorf("ttl is larger than maximum allowed for principal %q (%d)", principal, principalMaxTTL/time.Second)
+				}
+				ttl = principalMaxTTL
+			}
+		}
+	}
+
 	return ttl, nil
 }
 
 func (b *creationBundle) sign() (*ssh.Certificate, error) {
-	signingKey, err := ssh.ParsePrivateKey([]byte(b.SigningBundle.Certificate))
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("stored SSH signing key cannot be parsed: %v", err)}
-	}
-
 	serialNumber, err := certutil.GenerateSerialNumber()
 	if err != nil {
 		return nil, err
@@ -388,7 +604,7 @@ func (b *creationBundle) sign() (*ssh.Certificate, error) {
 		},
 	}
 
-	err = certificate.SignCert(rand.Reader, signingKey)
+	err = certificate.SignCert(rand.Reader, b.Signer)
 	if err != nil {
 		return nil, errutil.InternalError{Err: "Failed to generate signed SSH key"}
 	}
@@ -0,0 +1,429 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/blowfish"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// parseSSHPrivateKey returns a signer for the given PEM encoded private key.
+// In addition to the formats supported by ssh.ParsePrivateKey, it handles
+// RSA and ECDSA keys in the OpenSSH format used by default by recent versions
+// of ssh-keygen.
+func parseSSHPrivateKey(pemBytes []byte) (ssh.Signer, error) {
+	key, err := parseRawSSHPrivateKey(pemBytes, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSignerFromKey(key)
+}
+
+// parseRawSSHPrivateKey returns the private key of the given PEM encoded
+// private key, decrypting it with the passphrase if it is protected with
+// one, both in the legacy PEM format and in the OpenSSH format.
+func parseRawSSHPrivateKey(pemBytes []byte, passphrase string) (interface{}, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no key found")
+	}
+
+	switch {
+	case block.Type == "OPENSSH PRIVATE KEY":
+		return parseOpenSSHPrivateKey(block.Bytes, passphrase)
+
+	case x509.IsEncryptedPEMBlock(block):
+		if passphrase == "" {
+			return nil, errors.New("private key is encrypted but no passphrase was given")
+		}
+
+		der, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt private key: %v", err)
+		}
+
+		return ssh.ParseRawPrivateKey(pem.EncodeToMemory(&pem.Block{
+			Type:  block.Type,
+			Bytes: der,
+		}))
+
+	default:
+		return ssh.ParseRawPrivateKey(pemBytes)
+	}
+}
+
+// marshalSSHPrivateKey encodes the private key in PEM, without encryption, in
+// a format parseSSHPrivateKey accepts: PKCS#1 for RSA keys, SEC 1 for ECDSA
+// keys and the OpenSSH format for Ed25519 keys.
+func marshalSSHPrivateKey(key interface{}) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(k),
+		}), nil
+
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}), nil
+
+	case *ed25519.PrivateKey:
+		return marshalSSHPrivateKey(*k)
+
+	case ed25519.PrivateKey:
+		publicKey, err := ssh.NewPublicKey(k.Public())
+		if err != nil {
+			return nil, err
+		}
+
+		// The private section is padded to the block size of the "none"
+		// cipher, 8 bytes
+		privKeyBlock := ssh.Marshal(struct {
+			Check1  uint32
+			Check2  uint32
+			Keytype string
+			Pub     []byte
+			Priv    []byte
+			Comment string
+		}{
+			Keytype: ssh.KeyAlgoED25519,
+			Pub:     []byte(k.Public().(ed25519.PublicKey)),
+			Priv:    []byte(k),
+		})
+		for i := 1; len(privKeyBlock)%8 != 0; i++ {
+			privKeyBlock = append(privKeyBlock, byte(i))
+		}
+
+		w := struct {
+			CipherName   string
+			KdfName      string
+			KdfOpts      string
+			NumKeys      uint32
+			PubKey       []byte
+			PrivKeyBlock []byte
+		}{
+			CipherName:   "none",
+			KdfName:      "none",
+			NumKeys:      1,
+			PubKey:       publicKey.Marshal(),
+			PrivKeyBlock: privKeyBlock,
+		}
+
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "OPENSSH PRIVATE KEY",
+			Bytes: append([]byte("openssh-key-v1\x00"), ssh.Marshal(w)...),
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// parseOpenSSHPrivateKey parses a private key in the format described in
+// PROTOCOL.key of the OpenSSH distribution, decrypting it with the given
+// passphrase if needed
+func parseOpenSSHPrivateKey(key []byte, passphrase string) (interface{}, error) {
+	magic := append([]byte("openssh-key-v1"), 0)
+	if !bytes.HasPrefix(key, magic) {
+		return nil, errors.New("invalid OpenSSH private key format")
+	}
+
+	var w struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}
+	if err := ssh.Unmarshal(key[len(magic):], &w); err != nil {
+		return nil, err
+	}
+
+	if w.NumKeys != 1 {
+		return nil, fmt.Errorf("expected a single key, found %d", w.NumKeys)
+	}
+
+	privKeyBlock, err := decryptOpenSSHPrivateKeyBlock(w.CipherName, w.KdfName, w.KdfOpts, w.PrivKeyBlock, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var pk struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Rest    []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(privKeyBlock, &pk); err != nil {
+		return nil, err
+	}
+
+	if pk.Check1 != pk.Check2 {
+		if w.CipherName != "none" {
+			return nil, errors.New("unable to decrypt private key: incorrect passphrase")
+		}
+		return nil, errors.New("checkint mismatch in private key")
+	}
+
+	switch pk.Keytype {
+	case ssh.KeyAlgoRSA:
+		var k struct {
+			N       *big.Int
+			E       *big.Int
+			D       *big.Int
+			Iqmp    *big.Int
+			P       *big.Int
+			Q       *big.Int
+			Comment string
+			Pad     []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(pk.Rest, &k); err != nil {
+			return nil, err
+		}
+
+		privateKey := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: k.N,
+				E: int(k.E.Int64()),
+			},
+			D:      k.D,
+			Primes: []*big.Int{k.P, k.Q},
+		}
+		if err := privateKey.Validate(); err != nil {
+			return nil, err
+		}
+		privateKey.Precompute()
+		return privateKey, nil
+
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		var k struct {
+			Curve   string
+			Pub     []byte
+			D       *big.Int
+			Comment string
+			Pad     []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(pk.Rest, &k); err != nil {
+			return nil, err
+		}
+
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "nistp256":
+			curve = elliptic.P256()
+		case "nistp384":
+			curve = elliptic.P384()
+		case "nistp521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+
+		x, y := elliptic.Unmarshal(curve, k.Pub)
+		if x == nil {
+			return nil, errors.New("invalid ECDSA public key")
+		}
+
+		return &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: curve,
+				X:     x,
+				Y:     y,
+			},
+			D: k.D,
+		}, nil
+
+	case ssh.KeyAlgoED25519:
+		var k struct {
+			Pub     []byte
+			Priv    []byte
+			Comment string
+			Pad     []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(pk.Rest, &k); err != nil {
+			return nil, err
+		}
+
+		if len(k.Priv) != ed25519.PrivateKeySize {
+			return nil, errors.New("private key unexpected length")
+		}
+
+		privateKey := make(ed25519.PrivateKey, ed25519.PrivateKeySize)
+		copy(privateKey, k.Priv)
+		return privateKey, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", pk.Keytype)
+}
+
+// decryptOpenSSHPrivateKeyBlock decrypts the private key section of an
+// OpenSSH private key. Keys are derived from the passphrase with
+// bcrypt_pbkdf, which is the only KDF used by OpenSSH.
+func decryptOpenSSHPrivateKeyBlock(cipherName, kdfName, kdfOpts string, block []byte, passphrase string) ([]byte, error) {
+	if cipherName == "none" {
+		return block, nil
+	}
+
+	if kdfName != "bcrypt" {
+		return nil, fmt.Errorf("unsupported KDF %q", kdfName)
+	}
+
+	if passphrase == "" {
+		return nil, errors.New("private key is encrypted but no passphrase was given")
+	}
+
+	var opts struct {
+		Salt   []byte
+		Rounds uint32
+	}
+	if err := ssh.Unmarshal([]byte(kdfOpts), &opts); err != nil {
+		return nil, err
+	}
+
+	var keyLen int
+	var ctr bool
+	switch cipherName {
+	case "aes128-ctr":
+		keyLen, ctr = 16, true
+	case "aes192-ctr":
+		keyLen, ctr = 24, true
+	case "aes256-ctr":
+		keyLen, ctr = 32, true
+	case "aes128-cbc":
+		keyLen = 16
+	case "aes192-cbc":
+		keyLen = 24
+	case "aes256-cbc":
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported cipher %q", cipherName)
+	}
+
+	derived, err := bcryptPBKDF([]byte(passphrase), opts.Salt, int(opts.Rounds), keyLen+aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := aes.NewCipher(derived[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	iv := derived[keyLen:]
+
+	if len(block)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid length of encrypted private key")
+	}
+
+	decrypted := make([]byte, len(block))
+	if ctr {
+		cipher.NewCTR(c, iv).XORKeyStream(decrypted, block)
+	} else {
+		cipher.NewCBCDecrypter(c, iv).CryptBlocks(decrypted, block)
+	}
+
+	return decrypted, nil
+}
+
+// bcryptPBKDF implements the bcrypt_pbkdf key derivation function of
+// OpenBSD, as used by OpenSSH to protect private keys
+func bcryptPBKDF(password, salt []byte, rounds, keyLen int) ([]byte, error) {
+	const blockSize = 32
+
+	if rounds < 1 {
+		return nil, errors.New("bcrypt_pbkdf: number of rounds is too small")
+	}
+	if len(password) == 0 {
+		return nil, errors.New("bcrypt_pbkdf: empty password")
+	}
+	if len(salt) == 0 || len(salt) > 1<<20 {
+		return nil, errors.New("bcrypt_pbkdf: bad salt length")
+	}
+	if keyLen > 1024 {
+		return nil, errors.New("bcrypt_pbkdf: keyLen is too large")
+	}
+
+	numBlocks := (keyLen + blockSize - 1) / blockSize
+	key := make([]byte, numBlocks*blockSize)
+
+	h := sha512.New()
+	h.Write(password)
+	shapass := h.Sum(nil)
+
+	shasalt := make([]byte, 0, sha512.Size)
+	cnt, tmp := make([]byte, 4), make([]byte, blockSize)
+	for block := 1; block <= numBlocks; block++ {
+		h.Reset()
+		h.Write(salt)
+		cnt[0] = byte(block >> 24)
+		cnt[1] = byte(block >> 16)
+		cnt[2] = byte(block >> 8)
+		cnt[3] = byte(block)
+		h.Write(cnt)
+		if err := bcryptHash(tmp, shapass, h.Sum(shasalt)); err != nil {
+			return nil, err
+		}
+
+		out := make([]byte, blockSize)
+		copy(out, tmp)
+		for i := 2; i <= rounds; i++ {
+			h.Reset()
+			h.Write(tmp)
+			if err := bcryptHash(tmp, shapass, h.Sum(shasalt)); err != nil {
+				return nil, err
+			}
+			for j := 0; j < len(out); j++ {
+				out[j] ^= tmp[j]
+			}
+		}
+
+		for i, v := range out {
+			key[i*numBlocks+(block-1)] = v
+		}
+	}
+
+	return key[:keyLen], nil
+}
+
+func bcryptHash(out, shapass, shasalt []byte) error {
+	c, err := blowfish.NewSaltedCipher(shapass, shasalt)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 64; i++ {
+		blowfish.ExpandKey(shasalt, c)
+		blowfish.ExpandKey(shapass, c)
+	}
+
+	copy(out, []byte("OxychromaticBlowfishSwatDynamite"))
+	for i := 0; i < 32; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(out[i:i+8], out[i:i+8])
+		}
+	}
+
+	// Swap bytes due to different endianness
+	for i := 0; i < 32; i += 4 {
+		out[i+3], out[i+2], out[i+1], out[i] = out[i], out[i+1], out[i+2], out[i+3]
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -141,7 +142,7 @@ func cidrListContainsIP(ip, cidrList string) (bool, error) {
 		return false, fmt.Errorf("IP does not belong to role")
 	}
 	for _, item := range strings.Split(cidrList, ",") {
-		_, cidrIPNet, err := net.ParseCIDR(item)
+		_, cidrIPNet, err := net.ParseCIDR(strings.TrimSpace(item))
 		if err != nil {
 			return false, fmt.Errorf("invalid CIDR entry %q", item)
 		}
@@ -166,7 +167,7 @@ func createSSHComm(logger log.Logger, username, ip string, port int, hostkey str
 	}
 
 	connfunc := func() (net.Conn, error) {
-		c, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ip, port), 15*time.Second)
+		c, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), 15*time.Second)
 		if err != nil {
 			return nil, err
 		}
@@ -186,7 +187,7 @@ func createSSHComm(logger log.Logger, username, ip string, port int, hostkey str
 		Logger:       logger,
 	}
 
-	return SSHCommNew(fmt.Sprintf("%s:%d", ip, port), config)
+	return SSHCommNew(net.JoinHostPort(ip, strconv.Itoa(port)), config)
 }
 
 func parsePublicSSHKey(key string) (ssh.PublicKey, error) {
@@ -211,3 +212,53 @@ func convertMapToStringValue(initial map[string]interface{}) map[string]string {
 	}
 	return result
 }
+
+// parsePortRanges parses a comma separated list of ports and port ranges,
+// such as "22,2200-2299", into a list of inclusive [low, high] pairs
+func parsePortRanges(portList string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, item := range strings.Split(portList, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(item, "-", 2)
+		low, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		high := low
+		if len(bounds) == 2 {
+			high, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid port range %q", item)
+			}
+		}
+
+		if low < 1 || high > 65535 || low > high {
+			return nil, fmt.Errorf("invalid port range %q", item)
+		}
+
+		ranges = append(ranges, [2]int{low, high})
+	}
+
+	return ranges, nil
+}
+
+// portListContainsPort checks if the given port is in the comma separated
+// list of ports and port ranges
+func portListContainsPort(port int, portList string) (bool, error) {
+	ranges, err := parsePortRanges(portList)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range ranges {
+		if port >= r[0] && port <= r[1] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -1,8 +1,12 @@
 package transit
 
 import (
+	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -21,28 +25,44 @@ func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
 func Backend(conf *logical.BackendConfig) *backend {
 	var b backend
 	b.Backend = &framework.Backend{
+		PathsSpecial: &logical.Paths{
+			SealWrapStorage: []string{
+				"policy/",
+				"archive/",
+			},
+		},
+
 		Paths: []*framework.Path{
 			// Rotate/Config needs to come before Keys
 			// as the handler is greedy
 			b.pathConfig(),
 			b.pathRotate(),
+			b.pathTrim(),
+			b.pathImport(),
 			b.pathRewrap(),
 			b.pathKeys(),
 			b.pathListKeys(),
 			b.pathExportKeys(),
 			b.pathEncrypt(),
 			b.pathDecrypt(),
+			b.pathEncode(),
+			b.pathDecode(),
+			b.pathStreamEncrypt(),
+			b.pathStreamDecrypt(),
 			b.pathDatakey(),
 			b.pathRandom(),
 			b.pathHash(),
 			b.pathHMAC(),
 			b.pathSign(),
 			b.pathVerify(),
+			b.pathWrappingKey(),
 		},
 
 		Secrets: []*framework.Secret{},
 
 		Invalidate: b.invalidate,
+
+		PeriodicFunc: b.periodicFunc,
 	}
 
 	b.lm = keysutil.NewLockManager(conf.System.CachingDisabled())
@@ -53,6 +73,64 @@ func Backend(conf *logical.BackendConfig) *backend {
 type backend struct {
 	*framework.Backend
 	lm *keysutil.LockManager
+
+	// Serializes the generation of the wrapping key of the imported keys
+	wrappingKeyLock sync.Mutex
+}
+
+// periodicFunc is invoked once a minute by the RollbackManager. It rotates
+// the keys whose automatic rotation is due.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	names, err := req.Storage.List("policy/")
+	if err != nil {
+		return err
+	}
+
+	var result error
+	for _, name := range names {
+		if err := b.autoRotateKey(req.Storage, name); err != nil {
+			result = multierror.Append(result, fmt.Errorf("error rotating key %s: %v", name, err))
+		}
+	}
+
+	return result
+}
+
+func (b *backend) autoRotateKey(storage logical.Storage, name string) error {
+	// Check under a shared lock first, so that the keys which are not due
+	// don't block the requests using them
+	p, lock, err := b.lm.GetPolicyShared(storage, name)
+	if err != nil {
+		if lock != nil {
+			lock.RUnlock()
+		}
+		return err
+	}
+	needsRotation := p != nil && p.NeedsAutoRotation(time.Now())
+	if lock != nil {
+		lock.RUnlock()
+	}
+	if !needsRotation {
+		return nil
+	}
+
+	p, lock, err = b.lm.GetPolicyExclusive(storage, name)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	// The key may have been rotated while the lock was released
+	if p == nil || !p.NeedsAutoRotation(time.Now()) {
+		return nil
+	}
+
+	if b.Logger().IsInfo() {
+		b.Logger().Info("transit: rotating key", "key", name)
+	}
+
+	return p.Rotate(storage, b.GetRandomReader())
 }
 
 func (b *backend) invalidate(key string) {
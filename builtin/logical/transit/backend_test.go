@@ -716,7 +716,7 @@ func testConvergentEncryptionCommon(t *testing.T, ver int) {
 		ConvergentVersion:    ver,
 	}
 
-	err = p.Rotate(storage)
+	err = p.Rotate(storage, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
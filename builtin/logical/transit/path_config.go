@@ -2,6 +2,7 @@ package transit
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -26,6 +27,14 @@ to be decrypted.`,
 				Type:        framework.TypeBool,
 				Description: "Whether to allow deletion of the key",
 			},
+
+			"auto_rotate_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `If set, the key is rotated automatically once this
+period elapsed since its latest version was created,
+plus a small random jitter. Must be at least one hour.
+Set to zero to disable automatic rotation.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -78,6 +87,10 @@ func (b *backend) pathConfigWrite(
 				return logical.ErrorResponse(
 					fmt.Sprintf("cannot set min decryption version of %d, latest key version is %d", minDecryptionVersion, p.LatestVersion)), nil
 			}
+			if minDecryptionVersion < p.MinAvailableVersion {
+				return logical.ErrorResponse(
+					fmt.Sprintf("cannot set min decryption version of %d, versions older than %d have been trimmed", minDecryptionVersion, p.MinAvailableVersion)), nil
+			}
 			p.MinDecryptionVersion = minDecryptionVersion
 			persistNeeded = true
 		}
@@ -92,6 +105,20 @@ func (b *backend) pathConfigWrite(
 		}
 	}
 
+	autoRotatePeriodRaw, ok := d.GetOk("auto_rotate_period")
+	if ok {
+		autoRotatePeriod := time.Duration(autoRotatePeriodRaw.(int)) * time.Second
+
+		if autoRotatePeriod < 0 || (autoRotatePeriod > 0 && autoRotatePeriod < minAutoRotatePeriod) {
+			return logical.ErrorResponse(fmt.Sprintf("auto rotate period must be zero or at least %s", minAutoRotatePeriod)), nil
+		}
+
+		if autoRotatePeriod != p.AutoRotatePeriod {
+			p.SetAutoRotatePeriod(autoRotatePeriod)
+			persistNeeded = true
+		}
+	}
+
 	// Add this as a guard here before persisting since we now require the min
 	// decryption version to start at 1; even if it's not explicitly set here,
 	// force the upgrade
@@ -111,10 +138,16 @@ func (b *backend) pathConfigWrite(
 	return resp, p.Persist(req.Storage)
 }
 
+// minAutoRotatePeriod is the shortest period of the automatic rotations of
+// the keys
+const minAutoRotatePeriod = time.Hour
+
 const pathConfigHelpSyn = `Configure a named encryption key`
 
 const pathConfigHelpDesc = `
 This path is used to configure the named key. Currently, this
 supports adjusting the minimum version of the key allowed to
-be used for decryption via the min_decryption_version paramter.
+be used for decryption via the min_decryption_version paramter,
+and rotating the key on a schedule via the auto_rotate_period
+parameter.
 `
@@ -0,0 +1,106 @@
+package transit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_AutoRotate(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	readKey := func() *logical.Response {
+		resp, err := request(logical.ReadOperation, "keys/test", nil)
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		return resp
+	}
+
+	if resp, err := request(logical.UpdateOperation, "keys/test", nil); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	resp := readKey()
+	if resp.Data["auto_rotate_period"].(int64) != 0 {
+		t.Fatalf("bad: auto rotate period: %#v", resp.Data["auto_rotate_period"])
+	}
+	if _, ok := resp.Data["next_rotation_time"]; ok {
+		t.Fatalf("bad: next rotation time without auto rotate period")
+	}
+
+	for _, period := range []string{"-1h", "30m"} {
+		resp, err := request(logical.UpdateOperation, "keys/test/config", map[string]interface{}{
+			"auto_rotate_period": period,
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for auto rotate period %s", period)
+		}
+	}
+
+	resp, err := request(logical.UpdateOperation, "keys/test/config", map[string]interface{}{
+		"auto_rotate_period": "1h",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	resp = readKey()
+	if resp.Data["auto_rotate_period"].(int64) != 3600 {
+		t.Fatalf("bad: auto rotate period: %#v", resp.Data["auto_rotate_period"])
+	}
+	nextRotation := resp.Data["next_rotation_time"].(int64)
+	now := time.Now().Unix()
+	if nextRotation < now+3600-5 || nextRotation > now+3600+360 {
+		t.Fatalf("bad: next rotation time %d at %d", nextRotation, now)
+	}
+
+	// The key is not rotated before it is due
+	if err := b.periodicFunc(&logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := readKey(); resp.Data["latest_version"].(int) != 1 {
+		t.Fatalf("bad: key rotated before due: %#v", resp.Data)
+	}
+
+	p, lock, err := b.lm.GetPolicyExclusive(storage, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.NextRotationTime = now - 1
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+
+	if err := b.periodicFunc(&logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	resp = readKey()
+	if resp.Data["latest_version"].(int) != 2 {
+		t.Fatalf("bad: key not rotated: %#v", resp.Data)
+	}
+	if resp.Data["next_rotation_time"].(int64) < now+3600-5 {
+		t.Fatalf("bad: next rotation time not rescheduled: %#v", resp.Data["next_rotation_time"])
+	}
+
+	// Disabling the automatic rotation clears the schedule
+	resp, err = request(logical.UpdateOperation, "keys/test/config", map[string]interface{}{
+		"auto_rotate_period": 0,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if _, ok := readKey().Data["next_rotation_time"]; ok {
+		t.Fatalf("bad: next rotation time after disabling automatic rotation")
+	}
+}
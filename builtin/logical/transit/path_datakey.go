@@ -1,9 +1,9 @@
 package transit
 
 import (
-	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"io"
 
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
@@ -111,7 +111,7 @@ func (b *backend) pathDatakeyWrite(
 	default:
 		return logical.ErrorResponse("invalid bit length"), logical.ErrInvalidRequest
 	}
-	_, err = rand.Read(newKey)
+	_, err = io.ReadFull(b.GetRandomReader(), newKey)
 	if err != nil {
 		return nil, err
 	}
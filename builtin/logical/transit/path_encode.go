@@ -0,0 +1,278 @@
+package transit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	digitsAlphabet       = "0123456789"
+	alphanumericAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// fpeTemplate describes the values handled with format-preserving encryption.
+// The characters of the alphabet are encrypted, while the other characters
+// allowed by the pattern, such as separators, are kept in place.
+type fpeTemplate struct {
+	Alphabet string
+	Pattern  *regexp.Regexp
+}
+
+var fpeTemplates = map[string]*fpeTemplate{
+	"numeric": &fpeTemplate{
+		Alphabet: digitsAlphabet,
+		Pattern:  regexp.MustCompile(`^[0-9]+$`),
+	},
+	"alphanumeric": &fpeTemplate{
+		Alphabet: alphanumericAlphabet,
+		Pattern:  regexp.MustCompile(`^[0-9A-Za-z]+$`),
+	},
+	"credit-card": &fpeTemplate{
+		Alphabet: digitsAlphabet,
+		Pattern:  regexp.MustCompile(`^([0-9][ -]?){12,18}[0-9]$`),
+	},
+	"ssn": &fpeTemplate{
+		Alphabet: digitsAlphabet,
+		Pattern:  regexp.MustCompile(`^[0-9]{3}-?[0-9]{2}-?[0-9]{4}$`),
+	},
+}
+
+// transform applies the function to the characters of the value that are
+// part of the alphabet of the template
+func (t *fpeTemplate) transform(value string, f func(string) (string, error)) (string, error) {
+	if !t.Pattern.MatchString(value) {
+		return "", errutil.UserError{Err: "value does not match the format of the template"}
+	}
+
+	var numerals bytes.Buffer
+	for _, r := range value {
+		if strings.ContainsRune(t.Alphabet, r) {
+			numerals.WriteRune(r)
+		}
+	}
+
+	transformed, err := f(numerals.String())
+	if err != nil {
+		return "", err
+	}
+
+	result := []rune(value)
+	transformedRunes := []rune(transformed)
+	for i, r := range result {
+		if strings.ContainsRune(t.Alphabet, r) {
+			result[i] = transformedRunes[0]
+			transformedRunes = transformedRunes[1:]
+		}
+	}
+
+	return string(result), nil
+}
+
+func fpeFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Name of the key",
+		},
+
+		"value": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "The value to transform",
+		},
+
+		"template": &framework.FieldSchema{
+			Type:    framework.TypeString,
+			Default: "numeric",
+			Description: `The format of the value. Valid values are:
+
+* numeric
+* alphanumeric
+* credit-card
+* ssn
+
+Defaults to "numeric".`,
+		},
+
+		"tweak": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: fmt.Sprintf(`Base64 encoded tweak of %d bytes. The same value encoded
+with different tweaks gives different results, and
+must be decoded with the same tweak. Defaults to
+zero bytes.`, keysutil.FF3TweakSize),
+		},
+	}
+}
+
+func (b *backend) pathEncode() *framework.Path {
+	return &framework.Path{
+		Pattern: "encode/" + framework.GenericNameRegex("name"),
+		Fields:  fpeFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathEncodeWrite,
+		},
+
+		HelpSynopsis:    pathEncodeHelpSyn,
+		HelpDescription: pathEncodeHelpDesc,
+	}
+}
+
+func (b *backend) pathDecode() *framework.Path {
+	fields := fpeFields()
+	fields["key_version"] = &framework.FieldSchema{
+		Type: framework.TypeInt,
+		Description: `The version of the key the value was encoded with.
+Defaults to the latest version.`,
+	}
+
+	return &framework.Path{
+		Pattern: "decode/" + framework.GenericNameRegex("name"),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathDecodeWrite,
+		},
+
+		HelpSynopsis:    pathDecodeHelpSyn,
+		HelpDescription: pathDecodeHelpDesc,
+	}
+}
+
+// fpeParams reads the template and the tweak of an encode or decode request
+func fpeParams(d *framework.FieldData) (*fpeTemplate, []byte, *logical.Response) {
+	templateName := d.Get("template").(string)
+	template, ok := fpeTemplates[templateName]
+	if !ok {
+		return nil, nil, logical.ErrorResponse(fmt.Sprintf("unknown template %s", templateName))
+	}
+
+	tweak := make([]byte, keysutil.FF3TweakSize)
+	if tweakB64 := d.Get("tweak").(string); tweakB64 != "" {
+		var err error
+		tweak, err = base64.StdEncoding.DecodeString(tweakB64)
+		if err != nil {
+			return nil, nil, logical.ErrorResponse(fmt.Sprintf("unable to decode tweak as base64: %s", err))
+		}
+	}
+
+	return template, tweak, nil
+}
+
+func (b *backend) pathEncodeWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	template, tweak, errResp := fpeParams(d)
+	if errResp != nil {
+		return errResp, logical.ErrInvalidRequest
+	}
+
+	// Get the policy
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("policy not found"), logical.ErrInvalidRequest
+	}
+
+	var version int
+	encoded, err := template.transform(d.Get("value").(string), func(numerals string) (string, error) {
+		var encoded string
+		var err error
+		encoded, version, err = p.Encode(template.Alphabet, numerals, tweak)
+		return encoded, err
+	})
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"encoded_value": encoded,
+			"key_version":   version,
+		},
+	}, nil
+}
+
+func (b *backend) pathDecodeWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	template, tweak, errResp := fpeParams(d)
+	if errResp != nil {
+		return errResp, logical.ErrInvalidRequest
+	}
+
+	// Get the policy
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("policy not found"), logical.ErrInvalidRequest
+	}
+
+	version := d.Get("key_version").(int)
+	if version == 0 {
+		version = p.LatestVersion
+	}
+
+	decoded, err := template.transform(d.Get("value").(string), func(numerals string) (string, error) {
+		return p.Decode(version, template.Alphabet, numerals, tweak)
+	})
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"decoded_value": decoded,
+		},
+	}, nil
+}
+
+const pathEncodeHelpSyn = `Encrypt a value while preserving its format`
+
+const pathEncodeHelpDesc = `
+This path uses the named "aes256-ff3-1" key to encrypt a value with FF3-1
+format-preserving encryption: the encoded value has the same length and format
+as the original one, as described by the template, so that it fits in the same
+fixed-width fields. The characters that are not part of the alphabet of the
+template, such as separators, are kept in place.
+
+Since the encoded value cannot carry the version of the key, the version used
+is returned and must be supplied when decoding after a rotation of the key.
+`
+
+const pathDecodeHelpSyn = `Decrypt a value encoded with format-preserving encryption`
+
+const pathDecodeHelpDesc = `
+This path uses the named "aes256-ff3-1" key to decrypt a value returned by the
+"encode" path. The template, the tweak and the version of the key must be the
+ones used to encode the value.
+`
@@ -0,0 +1,144 @@
+package transit
+
+import (
+	"encoding/base64"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_EncodeDecode(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	expectError := func(path string, data map[string]interface{}) {
+		resp, err := request(path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("path %s: expected error for %#v, got: %#v", path, data, resp)
+		}
+	}
+
+	mustRequest("keys/aes", nil)
+	mustRequest("keys/fpe", map[string]interface{}{
+		"type": "aes256-ff3-1",
+	})
+	expectError("keys/derived", map[string]interface{}{
+		"type":    "aes256-ff3-1",
+		"derived": true,
+	})
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/fpe",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["type"] != "aes256-ff3-1" || !resp.Data["supports_encoding"].(bool) || resp.Data["supports_encryption"].(bool) {
+		t.Fatalf("bad: key: %#v", resp.Data)
+	}
+
+	expectError("encrypt/fpe", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte("the quick brown fox")),
+	})
+	expectError("encode/aes", map[string]interface{}{
+		"value": "1234567890",
+	})
+
+	tweak := base64.StdEncoding.EncodeToString([]byte("tweak01"))
+	for _, tc := range []struct {
+		template, value string
+		format          *regexp.Regexp
+	}{
+		{"numeric", "0123456789", regexp.MustCompile(`^[0-9]{10}$`)},
+		{"alphanumeric", "Vault2017", regexp.MustCompile(`^[0-9A-Za-z]{9}$`)},
+		{"credit-card", "4111 1111 1111 1111", regexp.MustCompile(`^[0-9]{4} [0-9]{4} [0-9]{4} [0-9]{4}$`)},
+		{"ssn", "123-45-6789", regexp.MustCompile(`^[0-9]{3}-[0-9]{2}-[0-9]{4}$`)},
+	} {
+		resp := mustRequest("encode/fpe", map[string]interface{}{
+			"value":    tc.value,
+			"template": tc.template,
+			"tweak":    tweak,
+		})
+		encoded := resp.Data["encoded_value"].(string)
+		if encoded == tc.value || !tc.format.MatchString(encoded) || resp.Data["key_version"].(int) != 1 {
+			t.Fatalf("bad: %s value encoded as %q", tc.template, encoded)
+		}
+
+		// Encoding is deterministic for a given tweak
+		resp = mustRequest("encode/fpe", map[string]interface{}{
+			"value":    tc.value,
+			"template": tc.template,
+			"tweak":    tweak,
+		})
+		if resp.Data["encoded_value"] != encoded {
+			t.Fatalf("bad: %s value encoded as %q then %q", tc.template, encoded, resp.Data["encoded_value"])
+		}
+		resp = mustRequest("encode/fpe", map[string]interface{}{
+			"value":    tc.value,
+			"template": tc.template,
+		})
+		if resp.Data["encoded_value"] == encoded {
+			t.Fatalf("bad: %s value encoded as %q with different tweaks", tc.template, encoded)
+		}
+
+		resp = mustRequest("decode/fpe", map[string]interface{}{
+			"value":    encoded,
+			"template": tc.template,
+			"tweak":    tweak,
+		})
+		if resp.Data["decoded_value"] != tc.value {
+			t.Fatalf("bad: %s value %q decoded as %q", tc.template, encoded, resp.Data["decoded_value"])
+		}
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"value": "12345"},
+		{"value": "12345678a"},
+		{"value": "123-45-678", "template": "ssn"},
+		{"value": "123456789", "template": "unknown"},
+		{"value": "123456789", "tweak": base64.StdEncoding.EncodeToString([]byte("tweak"))},
+	} {
+		expectError("encode/fpe", data)
+	}
+
+	// Values encoded before a rotation are decoded with their key version
+	resp = mustRequest("encode/fpe", map[string]interface{}{
+		"value": "1234567890",
+	})
+	encoded := resp.Data["encoded_value"].(string)
+	mustRequest("keys/fpe/rotate", nil)
+	resp = mustRequest("decode/fpe", map[string]interface{}{
+		"value": encoded,
+	})
+	if resp.Data["decoded_value"] == "1234567890" {
+		t.Fatalf("bad: value decoded with the new key version")
+	}
+	resp = mustRequest("decode/fpe", map[string]interface{}{
+		"value":       encoded,
+		"key_version": 1,
+	})
+	if resp.Data["decoded_value"] != "1234567890" {
+		t.Fatalf("bad: value decoded as %q", resp.Data["decoded_value"])
+	}
+	expectError("decode/fpe", map[string]interface{}{
+		"value":       encoded,
+		"key_version": 3,
+	})
+}
@@ -84,8 +84,8 @@ encryption key) this nonce value is **never reused**.
 				Description: `
 This parameter is required when encryption key is expected to be created.
 When performing an upsert operation, the type of key to create. Currently,
-"aes256-gcm96" (symmetric) is the only type supported. Defaults to
-"aes256-gcm96".`,
+"aes256-gcm96" and "chacha20-poly1305" (symmetric) are supported. Defaults
+to "aes256-gcm96".`,
 			},
 
 			"convergent_encryption": &framework.FieldSchema{
@@ -209,17 +209,18 @@ func (b *backend) pathEncryptWrite(
 			Name:       name,
 			Derived:    contextSet,
 			Convergent: convergent,
+			RandReader: b.GetRandomReader(),
 		}
 
 		keyType := d.Get("type").(string)
-		switch keyType {
-		case "aes256-gcm96":
-			polReq.KeyType = keysutil.KeyType_AES256_GCM96
-		case "ecdsa-p256":
-			return logical.ErrorResponse(fmt.Sprintf("key type %v not supported for this operation", keyType)), logical.ErrInvalidRequest
-		default:
+		var ok bool
+		polReq.KeyType, ok = parseKeyType(keyType)
+		if !ok {
 			return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
 		}
+		if !polReq.KeyType.EncryptionSupported() {
+			return logical.ErrorResponse(fmt.Sprintf("key type %v not supported for this operation", keyType)), logical.ErrInvalidRequest
+		}
 
 		p, lock, upserted, err = b.lm.GetPolicyUpsert(polReq)
 
@@ -545,3 +545,114 @@ func TestTransit_BatchEncryptionCase12(t *testing.T) {
 		t.Fatalf("expected an error")
 	}
 }
+
+func TestTransit_ChaCha20Poly1305(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	plaintext := "dGhlIHF1aWNrIGJyb3duIGZveA=="
+	context := "dGhlIGxhenkgZG9n"
+
+	for _, keyData := range []map[string]interface{}{
+		{"type": "chacha20-poly1305"},
+		{"type": "chacha20-poly1305", "derived": true},
+		{"type": "chacha20-poly1305", "derived": true, "convergent_encryption": true},
+	} {
+		resp, err := request("keys/chacha", keyData)
+		if err != nil || resp != nil {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+
+		encryptData := map[string]interface{}{
+			"plaintext": plaintext,
+		}
+		if keyData["derived"] == true {
+			encryptData["context"] = context
+		}
+		resp, err = request("encrypt/chacha", encryptData)
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		ciphertext := resp.Data["ciphertext"].(string)
+
+		// Convergent encryption is deterministic
+		resp, err = request("encrypt/chacha", encryptData)
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		if (resp.Data["ciphertext"] == ciphertext) != (keyData["convergent_encryption"] == true) {
+			t.Fatalf("bad: ciphertexts %s and %s with key %#v", ciphertext, resp.Data["ciphertext"], keyData)
+		}
+
+		decryptData := map[string]interface{}{
+			"ciphertext": ciphertext,
+		}
+		if keyData["derived"] == true {
+			decryptData["context"] = context
+		}
+		resp, err = request("decrypt/chacha", decryptData)
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		if resp.Data["plaintext"] != plaintext {
+			t.Fatalf("bad: plaintext: %#v", resp.Data)
+		}
+
+		// Ciphertexts of AES-GCM keys don't decrypt
+		decryptData["ciphertext"] = "vault:v1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+		resp, err = request("decrypt/chacha", decryptData)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error decrypting an invalid ciphertext")
+		}
+
+		resp, err = b.HandleRequest(&logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "keys/chacha",
+			Storage:   storage,
+		})
+		if err != nil || resp == nil || resp.Data["type"] != "chacha20-poly1305" {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+
+		request("keys/chacha/config", map[string]interface{}{
+			"deletion_allowed": true,
+		})
+		if _, err := b.HandleRequest(&logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "keys/chacha",
+			Storage:   storage,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Keys can be created by upserts
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "encrypt/upserted",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"plaintext": plaintext,
+			"type":      "chacha20-poly1305",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/upserted",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.Data["type"] != "chacha20-poly1305" {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+}
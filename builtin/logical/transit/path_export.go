@@ -1,16 +1,22 @@
 package transit
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -22,6 +28,22 @@ const (
 	exportTypeHMACKey       = "hmac-key"
 )
 
+const (
+	// The key material is wrapped with an ephemeral AES-256 key using AES key
+	// wrap with padding, and the ephemeral key is encrypted with RSA-OAEP, as
+	// imported by AWS KMS (RSA_AES_KEY_WRAP_SHA_*) and GCP KMS
+	// (RSA_OAEP_*_SHA*_AES_256)
+	wrappingAlgorithmRSAAESKeyWrapSHA1   = "rsa-aes-key-wrap-sha1"
+	wrappingAlgorithmRSAAESKeyWrapSHA256 = "rsa-aes-key-wrap-sha256"
+
+	// The key material is encrypted directly with RSA-OAEP, as imported by
+	// AWS KMS (RSAES_OAEP_SHA_*)
+	wrappingAlgorithmRSAOAEPSHA1   = "rsa-oaep-sha1"
+	wrappingAlgorithmRSAOAEPSHA256 = "rsa-oaep-sha256"
+
+	minWrappingKeyBits = 2048
+)
+
 func (b *backend) pathExportKeys() *framework.Path {
 	return &framework.Path{
 		Pattern: "export/" + framework.GenericNameRegex("type") + "/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("version"),
@@ -38,10 +60,25 @@ func (b *backend) pathExportKeys() *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Version of the key",
 			},
+			"public_key": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM encoded RSA public key to wrap the exported keys
+with, such as the wrapping key of a cloud KMS. Required
+when writing to this path.`,
+			},
+			"wrapping_algorithm": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: wrappingAlgorithmRSAAESKeyWrapSHA256,
+				Description: `The algorithm used to wrap the exported keys. Valid
+values are "rsa-aes-key-wrap-sha256",
+"rsa-aes-key-wrap-sha1", "rsa-oaep-sha256" and
+"rsa-oaep-sha1". Defaults to "rsa-aes-key-wrap-sha256".`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.ReadOperation: b.pathPolicyExportRead,
+			logical.ReadOperation:   b.pathPolicyExportRead,
+			logical.UpdateOperation: b.pathPolicyExportRead,
 		},
 
 		HelpSynopsis:    pathExportHelpSyn,
@@ -63,6 +100,27 @@ func (b *backend) pathPolicyExportRead(
 		return logical.ErrorResponse(fmt.Sprintf("invalid export type: %s", exportType)), logical.ErrInvalidRequest
 	}
 
+	// Writing to the path exports the keys wrapped with the public key,
+	// instead of in plaintext
+	exportKeyFunc := getExportKey
+	wrappingAlgorithm := d.Get("wrapping_algorithm").(string)
+	if req.Operation == logical.UpdateOperation {
+		publicKey, err := parseWrappingPublicKey(d.Get("public_key").(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		switch wrappingAlgorithm {
+		case wrappingAlgorithmRSAAESKeyWrapSHA1, wrappingAlgorithmRSAAESKeyWrapSHA256,
+			wrappingAlgorithmRSAOAEPSHA1, wrappingAlgorithmRSAOAEPSHA256:
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("unknown wrapping algorithm %s", wrappingAlgorithm)), logical.ErrInvalidRequest
+		}
+
+		exportKeyFunc = func(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType string) (string, error) {
+			return getWrappedExportKey(policy, key, exportType, publicKey, wrappingAlgorithm)
+		}
+	}
+
 	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
 	if lock != nil {
 		defer lock.RUnlock()
@@ -80,7 +138,7 @@ func (b *backend) pathPolicyExportRead(
 
 	switch exportType {
 	case exportTypeEncryptionKey:
-		if !p.Type.EncryptionSupported() {
+		if !p.Type.EncryptionSupported() && !p.Type.EncodingSupported() {
 			return logical.ErrorResponse("encryption not supported for the key"), logical.ErrInvalidRequest
 		}
 	case exportTypeSigningKey:
@@ -93,9 +151,9 @@ func (b *backend) pathPolicyExportRead(
 	switch version {
 	case "":
 		for k, v := range p.Keys {
-			exportKey, err := getExportKey(p, &v, exportType)
+			exportKey, err := exportKeyFunc(p, &v, exportType)
 			if err != nil {
-				return nil, err
+				return handleExportError(err)
 			}
 			retKeys[strconv.Itoa(k)] = exportKey
 		}
@@ -120,9 +178,9 @@ func (b *backend) pathPolicyExportRead(
 			return logical.ErrorResponse("version does not exist or cannot be found"), logical.ErrInvalidRequest
 		}
 
-		exportKey, err := getExportKey(p, &key, exportType)
+		exportKey, err := exportKeyFunc(p, &key, exportType)
 		if err != nil {
-			return nil, err
+			return handleExportError(err)
 		}
 
 		retKeys[strconv.Itoa(versionValue)] = exportKey
@@ -135,10 +193,159 @@ func (b *backend) pathPolicyExportRead(
 			"keys": retKeys,
 		},
 	}
+	if req.Operation == logical.UpdateOperation {
+		resp.Data["wrapping_algorithm"] = wrappingAlgorithm
+	}
 
 	return resp, nil
 }
 
+func handleExportError(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+// parseWrappingPublicKey parses the PEM encoded RSA public key to wrap the
+// exported keys with
+func parseWrappingPublicKey(publicKeyPEM string) (*rsa.PublicKey, error) {
+	if publicKeyPEM == "" {
+		return nil, errors.New("missing public key to wrap the keys with")
+	}
+
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("unable to decode the public key as PEM")
+	}
+
+	parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		// Also accept PKCS#1 encoded keys
+		var pkcs1Err error
+		parsedKey, pkcs1Err = parsePKCS1PublicKey(block.Bytes)
+		if pkcs1Err != nil {
+			return nil, fmt.Errorf("error parsing the public key: %s", err)
+		}
+	}
+
+	publicKey, ok := parsedKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	if publicKey.N.BitLen() < minWrappingKeyBits {
+		return nil, fmt.Errorf("public key must be at least %d bits long", minWrappingKeyBits)
+	}
+
+	return publicKey, nil
+}
+
+// parsePKCS1PublicKey parses an RSA public key in its PKCS#1 encoding, which
+// the crypto/x509 package of Go 1.8 does not parse
+func parsePKCS1PublicKey(der []byte) (*rsa.PublicKey, error) {
+	var pub struct {
+		N *big.Int
+		E int
+	}
+	rest, err := asn1.Unmarshal(der, &pub)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after the PKCS#1 public key")
+	}
+	if pub.N == nil || pub.N.Sign() <= 0 || pub.E <= 0 {
+		return nil, errors.New("invalid PKCS#1 public key")
+	}
+
+	return &rsa.PublicKey{N: pub.N, E: pub.E}, nil
+}
+
+// getWrappedExportKey returns the key material wrapped with the public key.
+// AES and HMAC keys are wrapped as raw bytes, and signing keys as PKCS#8 DER
+// encoded private keys, as expected by the cloud KMS.
+func getWrappedExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType string, publicKey *rsa.PublicKey, wrappingAlgorithm string) (string, error) {
+	if policy == nil {
+		return "", errors.New("nil policy provided")
+	}
+
+	var keyMaterial []byte
+	switch exportType {
+	case exportTypeHMACKey:
+		keyMaterial = key.HMACKey
+
+	case exportTypeEncryptionKey:
+		switch policy.Type {
+		case keysutil.KeyType_AES256_GCM96, keysutil.KeyType_AES256_FF3_1, keysutil.KeyType_ChaCha20_Poly1305:
+			keyMaterial = key.AESKey
+		}
+
+	case exportTypeSigningKey:
+		switch policy.Type {
+		case keysutil.KeyType_ECDSA_P256:
+			var err error
+			keyMaterial, err = keysutil.MarshalPKCS8PrivateKey(&ecdsa.PrivateKey{
+				PublicKey: ecdsa.PublicKey{
+					Curve: elliptic.P256(),
+					X:     key.EC_X,
+					Y:     key.EC_Y,
+				},
+				D: key.EC_D,
+			})
+			if err != nil {
+				return "", err
+			}
+
+		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
+			var err error
+			keyMaterial, err = keysutil.MarshalPKCS8PrivateKey(key.RSAKey)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	if keyMaterial == nil {
+		return "", fmt.Errorf("unknown key type %v", policy.Type)
+	}
+
+	hash := crypto.SHA256
+	if wrappingAlgorithm == wrappingAlgorithmRSAAESKeyWrapSHA1 || wrappingAlgorithm == wrappingAlgorithmRSAOAEPSHA1 {
+		hash = crypto.SHA1
+	}
+
+	var wrapped []byte
+	switch wrappingAlgorithm {
+	case wrappingAlgorithmRSAOAEPSHA1, wrappingAlgorithmRSAOAEPSHA256:
+		var err error
+		wrapped, err = rsa.EncryptOAEP(hash.New(), rand.Reader, publicKey, keyMaterial, nil)
+		if err == rsa.ErrMessageTooLong {
+			return "", errutil.UserError{Err: fmt.Sprintf("key is too long to be wrapped with %s, use an RSA AES key wrap algorithm", wrappingAlgorithm)}
+		}
+		if err != nil {
+			return "", fmt.Errorf("error wrapping the key: %s", err)
+		}
+
+	default:
+		ephemeralKey := make([]byte, 32)
+		if _, err := rand.Read(ephemeralKey); err != nil {
+			return "", err
+		}
+		encryptedKey, err := rsa.EncryptOAEP(hash.New(), rand.Reader, publicKey, ephemeralKey, nil)
+		if err != nil {
+			return "", fmt.Errorf("error wrapping the ephemeral key: %s", err)
+		}
+		wrappedKey, err := keysutil.WrapKeyWithPadding(ephemeralKey, keyMaterial)
+		if err != nil {
+			return "", err
+		}
+		wrapped = append(encryptedKey, wrappedKey...)
+	}
+
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
 func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType string) (string, error) {
 	if policy == nil {
 		return "", errors.New("nil policy provided")
@@ -150,7 +357,7 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 
 	case exportTypeEncryptionKey:
 		switch policy.Type {
-		case keysutil.KeyType_AES256_GCM96:
+		case keysutil.KeyType_AES256_GCM96, keysutil.KeyType_AES256_FF3_1, keysutil.KeyType_ChaCha20_Poly1305:
 			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.AESKey)), nil
 		}
 
@@ -162,6 +369,13 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 				return "", err
 			}
 			return ecKey, nil
+
+		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
+			block := pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(key.RSAKey),
+			}
+			return strings.TrimSpace(string(pem.EncodeToMemory(&block))), nil
 		}
 	}
 
@@ -201,4 +415,10 @@ const pathExportHelpSyn = `Export named encryption or signing key`
 const pathExportHelpDesc = `
 This path is used to export the named keys that are configured as
 exportable.
+
+Writing to this path with an RSA public key, such as the wrapping key of a
+cloud KMS, returns the keys wrapped with that key instead of in plaintext,
+using either RSA-OAEP directly or RSA-OAEP combined with AES key wrap with
+padding (RFC 5649). Signing keys are then wrapped as PKCS#8 DER encoded
+private keys.
 `
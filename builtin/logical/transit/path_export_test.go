@@ -1,11 +1,21 @@
 package transit
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strconv"
 	"testing"
 
+	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -405,3 +415,175 @@ func TestTransit_Export_EncryptionKey_DoesNotExportHMACKey(t *testing.T) {
 		t.Fatal("Encryption key data matched hmac key data")
 	}
 }
+
+func TestTransit_Export_Wrapped(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derBytes, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}))
+
+	unwrap := func(wrappingAlgorithm, wrapped string) []byte {
+		wrappedBytes, err := base64.StdEncoding.DecodeString(wrapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := crypto.SHA256
+		if wrappingAlgorithm == "rsa-aes-key-wrap-sha1" || wrappingAlgorithm == "rsa-oaep-sha1" {
+			hash = crypto.SHA1
+		}
+		if wrappingAlgorithm == "rsa-oaep-sha1" || wrappingAlgorithm == "rsa-oaep-sha256" {
+			key, err := rsa.DecryptOAEP(hash.New(), rand.Reader, rsaKey, wrappedBytes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return key
+		}
+		ephemeralKey, err := rsa.DecryptOAEP(hash.New(), rand.Reader, rsaKey, wrappedBytes[:rsaKey.Size()], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := keysutil.UnwrapKeyWithPadding(ephemeralKey, wrappedBytes[rsaKey.Size():])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	mustRequest(logical.UpdateOperation, "keys/aes", map[string]interface{}{
+		"exportable": true,
+	})
+	mustRequest(logical.UpdateOperation, "keys/ec", map[string]interface{}{
+		"exportable": true,
+		"type":       "ecdsa-p256",
+	})
+
+	for _, wrappingAlgorithm := range []string{"rsa-aes-key-wrap-sha256", "rsa-aes-key-wrap-sha1", "rsa-oaep-sha256", "rsa-oaep-sha1"} {
+		for _, exportType := range []string{"encryption-key", "hmac-key"} {
+			plaintextKey := mustRequest(logical.ReadOperation, "export/"+exportType+"/aes/1", nil).Data["keys"].(map[string]string)["1"]
+			resp := mustRequest(logical.UpdateOperation, "export/"+exportType+"/aes/1", map[string]interface{}{
+				"public_key":         publicKey,
+				"wrapping_algorithm": wrappingAlgorithm,
+			})
+			if resp.Data["wrapping_algorithm"] != wrappingAlgorithm {
+				t.Fatalf("bad: wrapping algorithm: %#v", resp.Data)
+			}
+			key := unwrap(wrappingAlgorithm, resp.Data["keys"].(map[string]string)["1"])
+			if base64.StdEncoding.EncodeToString(key) != plaintextKey {
+				t.Fatalf("bad: %s wrapped with %s differs from the plaintext export", exportType, wrappingAlgorithm)
+			}
+		}
+
+		// Signing keys are wrapped in their PKCS#8 encoding
+		plaintextKey := mustRequest(logical.ReadOperation, "export/signing-key/ec/1", nil).Data["keys"].(map[string]string)["1"]
+		block, _ := pem.Decode([]byte(plaintextKey))
+		ecKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := mustRequest(logical.UpdateOperation, "export/signing-key/ec", map[string]interface{}{
+			"public_key":         publicKey,
+			"wrapping_algorithm": wrappingAlgorithm,
+		})
+		parsedKey, err := x509.ParsePKCS8PrivateKey(unwrap(wrappingAlgorithm, resp.Data["keys"].(map[string]string)["1"]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsedKey.(*ecdsa.PrivateKey).D.Cmp(ecKey.D) != 0 {
+			t.Fatalf("bad: signing key wrapped with %s differs from the plaintext export", wrappingAlgorithm)
+		}
+	}
+
+	// Keys exported with the wrapping key of a transit backend can be imported
+	// into it
+	wrappingKey := mustRequest(logical.ReadOperation, "wrapping_key", nil).Data["public_key"].(string)
+	resp := mustRequest(logical.UpdateOperation, "export/encryption-key/aes/1", map[string]interface{}{
+		"public_key": wrappingKey,
+	})
+	mustRequest(logical.UpdateOperation, "keys/aes-copy/import", map[string]interface{}{
+		"ciphertext": resp.Data["keys"].(map[string]string)["1"],
+		"exportable": true,
+	})
+	original := mustRequest(logical.ReadOperation, "export/encryption-key/aes/1", nil).Data["keys"].(map[string]string)["1"]
+	imported := mustRequest(logical.ReadOperation, "export/encryption-key/aes-copy/1", nil).Data["keys"].(map[string]string)["1"]
+	if original != imported {
+		t.Fatalf("bad: imported key differs from the exported one")
+	}
+
+	// PKCS#1 encoded public keys are accepted too
+	pkcs1Bytes, err := asn1.Marshal(struct {
+		N *big.Int
+		E int
+	}{rsaKey.N, rsaKey.E})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = mustRequest(logical.UpdateOperation, "export/encryption-key/aes/1", map[string]interface{}{
+		"public_key": string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PUBLIC KEY",
+			Bytes: pkcs1Bytes,
+		})),
+	})
+	key := unwrap("rsa-aes-key-wrap-sha256", resp.Data["keys"].(map[string]string)["1"])
+	if base64.StdEncoding.EncodeToString(key) != original {
+		t.Fatalf("bad: key wrapped with a PKCS#1 public key differs from the plaintext export")
+	}
+
+	// RSA signing keys are wrapped in their PKCS#8 encoding too
+	mustRequest(logical.UpdateOperation, "keys/rsa", map[string]interface{}{
+		"exportable": true,
+		"type":       "rsa-2048",
+	})
+	plaintextKey := mustRequest(logical.ReadOperation, "export/signing-key/rsa/1", nil).Data["keys"].(map[string]string)["1"]
+	block, _ := pem.Decode([]byte(plaintextKey))
+	signingKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = mustRequest(logical.UpdateOperation, "export/signing-key/rsa/1", map[string]interface{}{
+		"public_key": publicKey,
+	})
+	parsedKey, err := x509.ParsePKCS8PrivateKey(unwrap("rsa-aes-key-wrap-sha256", resp.Data["keys"].(map[string]string)["1"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedKey.(*rsa.PrivateKey).D.Cmp(signingKey.D) != 0 {
+		t.Fatalf("bad: wrapped RSA signing key differs from the plaintext export")
+	}
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"public_key": "not a key"},
+		{"public_key": publicKey, "wrapping_algorithm": "unknown"},
+	} {
+		resp, err := request(logical.UpdateOperation, "export/encryption-key/aes", data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for %#v", data)
+		}
+	}
+}
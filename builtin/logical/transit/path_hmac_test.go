@@ -124,7 +124,7 @@ func TestTransit_HMAC(t *testing.T) {
 	req.Data["input"] = "dGhlIHF1aWNrIGJyb3duIGZveA=="
 
 	// Rotate
-	err = p.Rotate(storage)
+	err = p.Rotate(storage, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,254 @@
+package transit
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	wrappingKeyPath = "config/wrapping_key"
+	wrappingKeyBits = 4096
+)
+
+// wrappingKeyEntry is the storage entry of the RSA key used to wrap the
+// imported keys
+type wrappingKeyEntry struct {
+	PrivateKey []byte `json:"private_key"`
+}
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathImport() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Base64 encoded key material, wrapped with an ephemeral
+AES-256 key using AES key wrap with padding (RFC 5649),
+preceded by the ephemeral key encrypted with RSA-OAEP
+using the public key returned by the wrapping_key path.`,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "sha2-256",
+				Description: `The hash function used by RSA-OAEP. Valid values are
+"sha2-256", "sha2-384" and "sha2-512". Defaults to
+"sha2-256".`,
+			},
+
+			"type": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "aes256-gcm96",
+				Description: `The type of the imported key. Currently,
+"aes256-gcm96", "chacha20-poly1305", "ecdsa-p256",
+"rsa-2048", "rsa-3072", "rsa-4096" and "aes256-ff3-1"
+are supported. Defaults to "aes256-gcm96".`,
+			},
+
+			"derived": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Enables key derivation mode.",
+			},
+
+			"convergent_encryption": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to support convergent encryption.
+This is only supported when using a key with
+key derivation enabled.`,
+			},
+
+			"exportable": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Enables the key to be exportable.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+// getWrappingKey returns the RSA wrapping key of the backend, generating it
+// the first time
+func (b *backend) getWrappingKey(storage logical.Storage) (*rsa.PrivateKey, error) {
+	b.wrappingKeyLock.Lock()
+	defer b.wrappingKeyLock.Unlock()
+
+	storageEntry, err := storage.Get(wrappingKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if storageEntry != nil {
+		var entry wrappingKeyEntry
+		if err := storageEntry.DecodeJSON(&entry); err != nil {
+			return nil, err
+		}
+		return x509.ParsePKCS1PrivateKey(entry.PrivateKey)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, wrappingKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("error generating wrapping key: %s", err)
+	}
+	storageEntry, err = logical.StorageEntryJSON(wrappingKeyPath, &wrappingKeyEntry{
+		PrivateKey: x509.MarshalPKCS1PrivateKey(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.Put(storageEntry); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (b *backend) pathWrappingKeyRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	key, err := b.getWrappingKey(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling public key: %s", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "PUBLIC KEY",
+				Bytes: derBytes,
+			})),
+		},
+	}, nil
+}
+
+func (b *backend) pathImportWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	derived := d.Get("derived").(bool)
+	convergent := d.Get("convergent_encryption").(bool)
+	keyType := d.Get("type").(string)
+
+	if !derived && convergent {
+		return logical.ErrorResponse("convergent encryption requires derivation to be enabled"), nil
+	}
+
+	var hash crypto.Hash
+	switch hashFunction := d.Get("hash_function").(string); hashFunction {
+	case "sha2-256":
+		hash = crypto.SHA256
+	case "sha2-384":
+		hash = crypto.SHA384
+	case "sha2-512":
+		hash = crypto.SHA512
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unsupported hash function %s", hashFunction)), logical.ErrInvalidRequest
+	}
+
+	polReq := keysutil.PolicyRequest{
+		Storage:    req.Storage,
+		Name:       name,
+		Derived:    derived,
+		Convergent: convergent,
+		Exportable: d.Get("exportable").(bool),
+	}
+	var ok bool
+	polReq.KeyType, ok = parseKeyType(keyType)
+	if !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(d.Get("ciphertext").(string))
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to decode ciphertext as base64: %s", err)), logical.ErrInvalidRequest
+	}
+
+	wrappingKey, err := b.getWrappingKey(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ephemeral AES key is followed by the key material it wraps
+	if len(ciphertext) <= wrappingKey.Size() {
+		return logical.ErrorResponse("ciphertext is too short"), logical.ErrInvalidRequest
+	}
+	ephemeralKey, err := rsa.DecryptOAEP(hash.New(), rand.Reader, wrappingKey, ciphertext[:wrappingKey.Size()], nil)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("error decrypting the ephemeral key: %s", err)), logical.ErrInvalidRequest
+	}
+	if len(ephemeralKey) != 32 {
+		return logical.ErrorResponse("ephemeral key must be an AES-256 key"), logical.ErrInvalidRequest
+	}
+	polReq.ImportKey, err = keysutil.UnwrapKeyWithPadding(ephemeralKey, ciphertext[wrappingKey.Size():])
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("error unwrapping the key: %s", err)), logical.ErrInvalidRequest
+	}
+
+	if err := b.lm.ImportPolicy(polReq); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key used to wrap imported keys`
+
+const pathWrappingKeyHelpDesc = `
+This path returns the PEM encoded public key of the 4096-bit RSA key used to
+wrap the key material imported with the "keys/<name>/import" path. The key is
+generated the first time it is requested.
+`
+
+const pathImportHelpSyn = `Imports externally generated key material as a new key`
+
+const pathImportHelpDesc = `
+This path creates the named key from key material generated outside of Vault,
+such as in an HSM, without sending the key in the clear. The key material is
+wrapped with an ephemeral AES-256 key using AES key wrap with padding
+(RFC 5649), and the ephemeral key is encrypted with RSA-OAEP using the public
+key returned by the "wrapping_key" path. The ciphertext is the concatenation of
+the encrypted ephemeral key and of the wrapped key material.
+
+Symmetric keys are imported from their 32 raw bytes, and ECDSA and RSA keys
+from their PKCS#8 DER encoding. The key must not exist yet.
+`
@@ -0,0 +1,158 @@
+package transit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_Import(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	expectError := func(path string, data map[string]interface{}) {
+		resp, err := request(logical.UpdateOperation, path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("path %s: expected error, got: %#v", path, resp)
+		}
+	}
+
+	resp := mustRequest(logical.ReadOperation, "wrapping_key", nil)
+	block, _ := pem.Decode([]byte(resp.Data["public_key"].(string)))
+	if block == nil {
+		t.Fatalf("bad: wrapping key: %#v", resp.Data)
+	}
+	parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappingKey := parsedKey.(*rsa.PublicKey)
+	if wrappingKey.N.BitLen() != 4096 {
+		t.Fatalf("bad: wrapping key of %d bits", wrappingKey.N.BitLen())
+	}
+
+	// The wrapping key is stable
+	resp = mustRequest(logical.ReadOperation, "wrapping_key", nil)
+	if string(pem.EncodeToMemory(block)) != resp.Data["public_key"] {
+		t.Fatalf("bad: wrapping key changed")
+	}
+
+	wrap := func(key []byte) string {
+		ephemeralKey := make([]byte, 32)
+		if _, err := rand.Read(ephemeralKey); err != nil {
+			t.Fatal(err)
+		}
+		encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, wrappingKey, ephemeralKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrappedKey, err := keysutil.WrapKeyWithPadding(ephemeralKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(append(encryptedKey, wrappedKey...))
+	}
+
+	// AES keys are imported from their raw bytes
+	aesKey := make([]byte, 32)
+	if _, err := rand.Read(aesKey); err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(logical.UpdateOperation, "keys/aes/import", map[string]interface{}{
+		"ciphertext": wrap(aesKey),
+		"exportable": true,
+	})
+	resp = mustRequest(logical.ReadOperation, "keys/aes", nil)
+	if resp.Data["type"] != "aes256-gcm96" || !resp.Data["imported_key"].(bool) {
+		t.Fatalf("bad: imported key: %#v", resp.Data)
+	}
+	resp = mustRequest(logical.ReadOperation, "export/encryption-key/aes/1", nil)
+	if resp.Data["keys"].(map[string]string)["1"] != base64.StdEncoding.EncodeToString(aesKey) {
+		t.Fatalf("bad: exported key: %#v", resp.Data)
+	}
+
+	// Keys cannot be imported over existing ones
+	expectError("keys/aes/import", map[string]interface{}{
+		"ciphertext": wrap(aesKey),
+	})
+
+	// ECDSA keys are imported from their PKCS#8 encoding
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKeyBytes, err := keysutil.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(logical.UpdateOperation, "keys/ec/import", map[string]interface{}{
+		"ciphertext": wrap(ecKeyBytes),
+		"type":       "ecdsa-p256",
+	})
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+	resp = mustRequest(logical.UpdateOperation, "sign/ec", map[string]interface{}{
+		"input": input,
+	})
+	resp = mustRequest(logical.UpdateOperation, "verify/ec", map[string]interface{}{
+		"input":     input,
+		"signature": resp.Data["signature"],
+	})
+	if !resp.Data["valid"].(bool) {
+		t.Fatalf("bad: signature of the imported key not valid")
+	}
+	p, lock, err := b.lm.GetPolicyShared(storage, "ec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := p.Keys[1]
+	lock.RUnlock()
+	if entry.EC_D.Cmp(ecKey.D) != 0 {
+		t.Fatalf("bad: imported key differs")
+	}
+	expectedPublicKey, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block, _ := pem.Decode([]byte(entry.FormattedPublicKey)); block == nil || string(block.Bytes) != string(expectedPublicKey) {
+		t.Fatalf("bad: public key of the imported key: %s", entry.FormattedPublicKey)
+	}
+
+	tampered, _ := base64.StdEncoding.DecodeString(wrap(aesKey))
+	tampered[len(tampered)-1] ^= 1
+	for _, data := range []map[string]interface{}{
+		{"ciphertext": base64.StdEncoding.EncodeToString(tampered)},
+		{"ciphertext": wrap(aesKey[:16])},
+		{"ciphertext": wrap(aesKey), "hash_function": "sha2-512"},
+		{"ciphertext": wrap(aesKey), "type": "ecdsa-p256"},
+		{"ciphertext": wrap(aesKey), "type": "unknown"},
+		{"ciphertext": "AAAA"},
+	} {
+		expectError("keys/bad/import", data)
+	}
+	if resp, err := request(logical.ReadOperation, "keys/bad", nil); err != nil || resp != nil {
+		t.Fatalf("bad: key created by failed imports: %#v, err: %v", resp, err)
+	}
+}
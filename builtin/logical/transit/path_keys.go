@@ -4,6 +4,7 @@ import (
 	"crypto/elliptic"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
@@ -36,8 +37,10 @@ func (b *backend) pathKeys() *framework.Path {
 				Type:    framework.TypeString,
 				Default: "aes256-gcm96",
 				Description: `The type of key to create. Currently,
-"aes256-gcm96" (symmetric) and "ecdsa-p256" (asymmetric) are
-supported. Defaults to "aes256-gcm96".`,
+"aes256-gcm96" and "chacha20-poly1305" (symmetric),
+"ecdsa-p256", "rsa-2048", "rsa-3072" and "rsa-4096"
+(asymmetric) and "aes256-ff3-1" (format-preserving
+encryption) are supported. Defaults to "aes256-gcm96".`,
 			},
 
 			"derived": &framework.FieldSchema{
@@ -110,13 +113,11 @@ func (b *backend) pathPolicyWrite(
 		Derived:    derived,
 		Convergent: convergent,
 		Exportable: exportable,
+		RandReader: b.GetRandomReader(),
 	}
-	switch keyType {
-	case "aes256-gcm96":
-		polReq.KeyType = keysutil.KeyType_AES256_GCM96
-	case "ecdsa-p256":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P256
-	default:
+	var ok bool
+	polReq.KeyType, ok = parseKeyType(keyType)
+	if !ok {
 		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
 	}
 
@@ -139,6 +140,27 @@ func (b *backend) pathPolicyWrite(
 	return nil, nil
 }
 
+// parseKeyType returns the key type of the name
+func parseKeyType(keyType string) (keysutil.KeyType, bool) {
+	switch keyType {
+	case "aes256-gcm96":
+		return keysutil.KeyType_AES256_GCM96, true
+	case "chacha20-poly1305":
+		return keysutil.KeyType_ChaCha20_Poly1305, true
+	case "ecdsa-p256":
+		return keysutil.KeyType_ECDSA_P256, true
+	case "rsa-2048":
+		return keysutil.KeyType_RSA2048, true
+	case "rsa-3072":
+		return keysutil.KeyType_RSA3072, true
+	case "rsa-4096":
+		return keysutil.KeyType_RSA4096, true
+	case "aes256-ff3-1":
+		return keysutil.KeyType_AES256_FF3_1, true
+	}
+	return 0, false
+}
+
 func (b *backend) pathPolicyRead(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
@@ -162,15 +184,23 @@ func (b *backend) pathPolicyRead(
 			"derived":                p.Derived,
 			"deletion_allowed":       p.DeletionAllowed,
 			"min_decryption_version": p.MinDecryptionVersion,
+			"min_available_version":  p.MinAvailableVersion,
 			"latest_version":         p.LatestVersion,
 			"exportable":             p.Exportable,
 			"supports_encryption":    p.Type.EncryptionSupported(),
 			"supports_decryption":    p.Type.DecryptionSupported(),
 			"supports_signing":       p.Type.SigningSupported(),
+			"supports_encoding":      p.Type.EncodingSupported(),
 			"supports_derivation":    p.Type.DerivationSupported(),
+			"auto_rotate_period":     int64(p.AutoRotatePeriod / time.Second),
+			"imported_key":           p.Imported,
 		},
 	}
 
+	if p.NextRotationTime > 0 {
+		resp.Data["next_rotation_time"] = p.NextRotationTime
+	}
+
 	if p.Derived {
 		switch p.KDF {
 		case keysutil.Kdf_hmac_sha256_counter:
@@ -185,8 +215,12 @@ func (b *backend) pathPolicyRead(
 		}
 	}
 
+	type asymmetricKey struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"public_key"`
+	}
 	switch p.Type {
-	case keysutil.KeyType_AES256_GCM96:
+	case keysutil.KeyType_AES256_GCM96, keysutil.KeyType_AES256_FF3_1, keysutil.KeyType_ChaCha20_Poly1305:
 		retKeys := map[string]int64{}
 		for k, v := range p.Keys {
 			retKeys[strconv.Itoa(k)] = v.CreationTime
@@ -194,18 +228,24 @@ func (b *backend) pathPolicyRead(
 		resp.Data["keys"] = retKeys
 
 	case keysutil.KeyType_ECDSA_P256:
-		type ecdsaKey struct {
-			Name      string `json:"name"`
-			PublicKey string `json:"public_key"`
-		}
-		retKeys := map[string]ecdsaKey{}
+		retKeys := map[string]asymmetricKey{}
 		for k, v := range p.Keys {
-			retKeys[strconv.Itoa(k)] = ecdsaKey{
+			retKeys[strconv.Itoa(k)] = asymmetricKey{
 				Name:      elliptic.P256().Params().Name,
 				PublicKey: v.FormattedPublicKey,
 			}
 		}
 		resp.Data["keys"] = retKeys
+
+	case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
+		retKeys := map[string]asymmetricKey{}
+		for k, v := range p.Keys {
+			retKeys[strconv.Itoa(k)] = asymmetricKey{
+				Name:      p.Type.String(),
+				PublicKey: v.FormattedPublicKey,
+			}
+		}
+		resp.Data["keys"] = retKeys
 	}
 
 	return resp, nil
@@ -1,17 +1,65 @@
 package transit
 
 import (
-	"crypto/sha256"
-	"crypto/sha512"
+	"crypto"
 	"encoding/base64"
 	"fmt"
-	"hash"
 
 	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 )
 
+// SignBatchRequestItem represents a request item for batch signing and
+// verification
+type SignBatchRequestItem struct {
+	// Base64 encoded input to sign or verify
+	Input string `json:"input" structs:"input" mapstructure:"input"`
+
+	// Signature to verify
+	Signature string `json:"signature" structs:"signature" mapstructure:"signature"`
+}
+
+// SignBatchResponseItem represents a response item for batch signing
+type SignBatchResponseItem struct {
+	// Signature of the input present in the corresponding batch request item
+	Signature string `json:"signature,omitempty" structs:"signature" mapstructure:"signature"`
+
+	// Error, if set represents a failure encountered while signing a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+// VerifyBatchResponseItem represents a response item for batch verification
+type VerifyBatchResponseItem struct {
+	// Whether the signature of the corresponding batch request item is valid
+	Valid bool `json:"valid" structs:"valid" mapstructure:"valid"`
+
+	// Error, if set represents a failure encountered while verifying a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+// signingHashes are the hash functions of the input of the signatures
+var signingHashes = map[string]crypto.Hash{
+	"sha2-224": crypto.SHA224,
+	"sha2-256": crypto.SHA256,
+	"sha2-384": crypto.SHA384,
+	"sha2-512": crypto.SHA512,
+}
+
+func signatureAlgorithmSchema() *framework.FieldSchema {
+	return &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: keysutil.SignatureAlgorithmRSAPSS,
+		Description: `The padding scheme of the signatures of RSA keys,
+"pss" or "pkcs1v15". Ignored for the other key types.
+Defaults to "pss".`,
+	}
+}
+
 func (b *backend) pathSign() *framework.Path {
 	return &framework.Path{
 		Pattern: "sign/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -43,6 +91,8 @@ Defaults to "sha2-256".`,
 				Type:        framework.TypeString,
 				Description: `Hash algorithm to use (POST URL parameter)`,
 			},
+
+			"signature_algorithm": signatureAlgorithmSchema(),
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -95,6 +145,8 @@ func (b *backend) pathVerify() *framework.Path {
 
 Defaults to "sha2-256".`,
 			},
+
+			"signature_algorithm": signatureAlgorithmSchema(),
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -109,32 +161,34 @@ Defaults to "sha2-256".`,
 func (b *backend) pathSignWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	hashFunc, ok := signingHashes[algorithm]
+	if !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
 	}
 
-	var hf hash.Hash
-	switch algorithm {
-	case "sha2-224":
-		hf = sha256.New224()
-	case "sha2-256":
-		hf = sha256.New()
-	case "sha2-384":
-		hf = sha512.New384()
-	case "sha2-512":
-		hf = sha512.New()
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []SignBatchRequestItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = []SignBatchRequestItem{
+			{
+				Input: d.Get("input").(string),
+			},
+		}
 	}
-	hf.Write(input)
-	hashedInput := hf.Sum(nil)
 
 	// Get the policy
 	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
@@ -152,29 +206,68 @@ func (b *backend) pathSignWrite(
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
 	}
 
-	sig, err := p.Sign(hashedInput)
-	if err != nil {
-		return nil, err
-	}
-	if sig == "" {
-		return nil, fmt.Errorf("signature could not be computed")
+	signatureAlgorithm := d.Get("signature_algorithm").(string)
+
+	// Process batch request items. If signing of any request item fails,
+	// respectively mark the error in the response collection and continue to
+	// process other items.
+	batchResponseItems := make([]SignBatchResponseItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		input, err := base64.StdEncoding.DecodeString(item.Input)
+		if err != nil {
+			batchResponseItems[i].Error = fmt.Sprintf("unable to decode input as base64: %s", err)
+			continue
+		}
+
+		hf := hashFunc.New()
+		hf.Write(input)
+
+		sig, err := p.Sign(hf.Sum(nil), hashFunc, signatureAlgorithm)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				return nil, err
+			}
+		}
+		if sig == "" {
+			return nil, fmt.Errorf("signature could not be computed for input item %d", i)
+		}
+
+		batchResponseItems[i].Signature = sig
 	}
 
 	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"signature": sig,
-		},
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+	} else {
+		if batchResponseItems[0].Error != "" {
+			return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+		}
+		resp.Data = map[string]interface{}{
+			"signature": batchResponseItems[0].Signature,
+		}
 	}
 	return resp, nil
 }
 
 func (b *backend) pathVerifyWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	batchInputRaw := d.Raw["batch_input"]
 
 	sig := d.Get("signature").(string)
 	hmac := d.Get("hmac").(string)
 	switch {
+	case batchInputRaw != nil:
+		if sig != "" || hmac != "" {
+			return logical.ErrorResponse("provide either a batch input or a 'signature' or 'hmac'"), logical.ErrInvalidRequest
+		}
+
 	case sig != "" && hmac != "":
 		return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
 
@@ -186,32 +279,34 @@ func (b *backend) pathVerifyWrite(
 	}
 
 	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	hashFunc, ok := signingHashes[algorithm]
+	if !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
 	}
 
-	var hf hash.Hash
-	switch algorithm {
-	case "sha2-224":
-		hf = sha256.New224()
-	case "sha2-256":
-		hf = sha256.New()
-	case "sha2-384":
-		hf = sha512.New384()
-	case "sha2-512":
-		hf = sha512.New()
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	var batchInputItems []SignBatchRequestItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = []SignBatchRequestItem{
+			{
+				Input:     d.Get("input").(string),
+				Signature: sig,
+			},
+		}
 	}
-	hf.Write(input)
-	hashedInput := hf.Sum(nil)
 
 	// Get the policy
 	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
@@ -225,34 +320,66 @@ func (b *backend) pathVerifyWrite(
 		return logical.ErrorResponse("policy not found"), logical.ErrInvalidRequest
 	}
 
-	valid, err := p.VerifySignature(hashedInput, sig)
-	if err != nil {
-		switch err.(type) {
-		case errutil.UserError:
-			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-		case errutil.InternalError:
-			return nil, err
-		default:
-			return nil, err
+	signatureAlgorithm := d.Get("signature_algorithm").(string)
+
+	batchResponseItems := make([]VerifyBatchResponseItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		if item.Signature == "" {
+			batchResponseItems[i].Error = "missing signature to verify"
+			continue
+		}
+
+		input, err := base64.StdEncoding.DecodeString(item.Input)
+		if err != nil {
+			batchResponseItems[i].Error = fmt.Sprintf("unable to decode input as base64: %s", err)
+			continue
 		}
+
+		hf := hashFunc.New()
+		hf.Write(input)
+
+		valid, err := p.VerifySignature(hf.Sum(nil), item.Signature, hashFunc, signatureAlgorithm)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				return nil, err
+			}
+		}
+
+		batchResponseItems[i].Valid = valid
 	}
 
 	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"valid": valid,
-		},
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+	} else {
+		if batchResponseItems[0].Error != "" {
+			return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+		}
+		resp.Data = map[string]interface{}{
+			"valid": batchResponseItems[0].Valid,
+		}
 	}
 	return resp, nil
 }
 
-const pathSignHelpSyn = `Generate a signature for input data using the named key`
+const pathSignHelpSyn = `Generate a signature for input data or a batch of
+inputs using the named key`
 
 const pathSignHelpDesc = `
-Generates a signature of the input data using the named key and the given hash algorithm.
+Generates a signature of the input data, or of each input of a batch, using
+the named key and the given hash algorithm.
 `
-const pathVerifyHelpSyn = `Verify a signature or HMAC for input data created using the named key`
+const pathVerifyHelpSyn = `Verify a signature or HMAC for input data, or a
+batch of signatures, created using the named key`
 
 const pathVerifyHelpDesc = `
-Verifies a signature or HMAC of the input data using the named key and the given hash algorithm.
+Verifies a signature or HMAC of the input data, or each signature of a batch,
+using the named key and the given hash algorithm.
 `
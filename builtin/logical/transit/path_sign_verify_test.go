@@ -1,6 +1,13 @@
 package transit
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
@@ -177,11 +184,11 @@ func TestTransit_SignVerify(t *testing.T) {
 	signRequest(req, true, "")
 
 	// Rotate and set min decryption version
-	err = p.Rotate(storage)
+	err = p.Rotate(storage, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = p.Rotate(storage)
+	err = p.Rotate(storage, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -199,3 +206,226 @@ func TestTransit_SignVerify(t *testing.T) {
 	// Now try the v1
 	verifyRequest(req, true, "", v1sig)
 }
+
+func TestTransit_SignVerify_RSA(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	input := []byte("the quick brown fox")
+	inputB64 := base64.StdEncoding.EncodeToString(input)
+
+	for _, keyType := range []string{"rsa-2048", "rsa-3072"} {
+		request("keys/"+keyType, map[string]interface{}{
+			"type": keyType,
+		})
+
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "keys/" + keyType,
+			Storage:   storage,
+		})
+		if err != nil || resp == nil {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		if resp.Data["type"] != keyType || !resp.Data["supports_signing"].(bool) || resp.Data["supports_encryption"].(bool) {
+			t.Fatalf("bad: key: %#v", resp.Data)
+		}
+		publicKeyPEM := reflect.ValueOf(resp.Data["keys"]).MapIndex(reflect.ValueOf("1")).FieldByName("PublicKey").String()
+		block, _ := pem.Decode([]byte(publicKeyPEM))
+		if block == nil {
+			t.Fatalf("bad: public key: %q", publicKeyPEM)
+		}
+		parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey := parsedKey.(*rsa.PublicKey)
+
+		for _, tc := range []struct {
+			algorithm, signatureAlgorithm string
+			hash                          crypto.Hash
+		}{
+			{"sha2-256", "pss", crypto.SHA256},
+			{"sha2-512", "pss", crypto.SHA512},
+			{"sha2-256", "pkcs1v15", crypto.SHA256},
+			{"sha2-384", "pkcs1v15", crypto.SHA384},
+		} {
+			resp := request("sign/"+keyType+"/"+tc.algorithm, map[string]interface{}{
+				"input":               inputB64,
+				"signature_algorithm": tc.signatureAlgorithm,
+			})
+			sig := resp.Data["signature"].(string)
+			if !strings.HasPrefix(sig, "vault:v1:") {
+				t.Fatalf("bad: signature: %s", sig)
+			}
+
+			// The signature is verified by the backend and by the standard
+			// library
+			resp = request("verify/"+keyType+"/"+tc.algorithm, map[string]interface{}{
+				"input":               inputB64,
+				"signature":           sig,
+				"signature_algorithm": tc.signatureAlgorithm,
+			})
+			if !resp.Data["valid"].(bool) {
+				t.Fatalf("bad: %s %s signature not valid", tc.algorithm, tc.signatureAlgorithm)
+			}
+			sigBytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sig, "vault:v1:"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			hf := tc.hash.New()
+			hf.Write(input)
+			if tc.signatureAlgorithm == "pss" {
+				err = rsa.VerifyPSS(publicKey, tc.hash, hf.Sum(nil), sigBytes, nil)
+			} else {
+				err = rsa.VerifyPKCS1v15(publicKey, tc.hash, hf.Sum(nil), sigBytes)
+			}
+			if err != nil {
+				t.Fatalf("bad: %s %s signature: %v", tc.algorithm, tc.signatureAlgorithm, err)
+			}
+
+			// Other padding schemes, hash functions and inputs don't verify
+			otherSignatureAlgorithm := "pss"
+			if tc.signatureAlgorithm == "pss" {
+				otherSignatureAlgorithm = "pkcs1v15"
+			}
+			for _, data := range []map[string]interface{}{
+				{"signature_algorithm": otherSignatureAlgorithm},
+				{"algorithm": "sha2-224"},
+				{"input": base64.StdEncoding.EncodeToString([]byte("the lazy dog"))},
+			} {
+				verifyData := map[string]interface{}{
+					"input":               inputB64,
+					"signature":           sig,
+					"signature_algorithm": tc.signatureAlgorithm,
+					"algorithm":           tc.algorithm,
+				}
+				for k, v := range data {
+					verifyData[k] = v
+				}
+				resp = request("verify/"+keyType, verifyData)
+				if resp.Data["valid"].(bool) {
+					t.Fatalf("bad: %s %s signature valid with %#v", tc.algorithm, tc.signatureAlgorithm, data)
+				}
+			}
+		}
+
+		resp, err = b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "sign/" + keyType,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"input":               inputB64,
+				"signature_algorithm": "unknown",
+			},
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for an unknown signature algorithm")
+		}
+	}
+}
+
+func TestTransit_SignVerify_Batch(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	request("keys/foo", map[string]interface{}{
+		"type": "ecdsa-p256",
+	})
+
+	inputs := []string{
+		base64.StdEncoding.EncodeToString([]byte("the quick brown fox")),
+		base64.StdEncoding.EncodeToString([]byte("jumps over the lazy dog")),
+	}
+
+	resp := request("sign/foo", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": inputs[0]},
+			map[string]interface{}{"input": "not base64"},
+			map[string]interface{}{"input": inputs[1]},
+		},
+	})
+	signItems := resp.Data["batch_results"].([]SignBatchResponseItem)
+	if len(signItems) != 3 {
+		t.Fatalf("bad: batch results: %#v", signItems)
+	}
+	if signItems[1].Error == "" || signItems[1].Signature != "" {
+		t.Fatalf("bad: expected error for item 1: %#v", signItems[1])
+	}
+	for _, i := range []int{0, 2} {
+		if signItems[i].Error != "" || !strings.HasPrefix(signItems[i].Signature, "vault:v1:") {
+			t.Fatalf("bad: item %d: %#v", i, signItems[i])
+		}
+	}
+
+	// The signatures of the batch are verified individually and in a batch
+	resp = request("verify/foo", map[string]interface{}{
+		"input":     inputs[1],
+		"signature": signItems[2].Signature,
+	})
+	if !resp.Data["valid"].(bool) {
+		t.Fatalf("bad: signature not valid")
+	}
+
+	resp = request("verify/foo", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": inputs[0], "signature": signItems[0].Signature},
+			map[string]interface{}{"input": inputs[1], "signature": signItems[2].Signature},
+			map[string]interface{}{"input": inputs[0], "signature": signItems[2].Signature},
+			map[string]interface{}{"input": inputs[0]},
+		},
+	})
+	verifyItems := resp.Data["batch_results"].([]VerifyBatchResponseItem)
+	if len(verifyItems) != 4 {
+		t.Fatalf("bad: batch results: %#v", verifyItems)
+	}
+	if !verifyItems[0].Valid || !verifyItems[1].Valid || verifyItems[0].Error != "" || verifyItems[1].Error != "" {
+		t.Fatalf("bad: batch results: %#v", verifyItems)
+	}
+	if verifyItems[2].Valid || verifyItems[2].Error != "" {
+		t.Fatalf("bad: item 2: %#v", verifyItems[2])
+	}
+	if verifyItems[3].Valid || verifyItems[3].Error == "" {
+		t.Fatalf("bad: item 3: %#v", verifyItems[3])
+	}
+
+	// Empty batches and batches mixed with a single signature are rejected
+	for _, data := range []map[string]interface{}{
+		{"batch_input": []interface{}{}},
+		{"batch_input": []interface{}{map[string]interface{}{"input": inputs[0], "signature": signItems[0].Signature}}, "signature": signItems[0].Signature},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "verify/foo",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for %#v, got: %#v", data, resp)
+		}
+	}
+}
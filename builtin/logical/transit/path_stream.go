@@ -0,0 +1,223 @@
+package transit
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func streamFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Name of the key",
+		},
+
+		"stream_header": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: `The header of the stream, returned when encrypting
+its first chunk. It must be supplied with all the other
+chunks of the stream.`,
+		},
+
+		"index": &framework.FieldSchema{
+			Type:        framework.TypeInt,
+			Description: "The index of the chunk in the stream, starting at 0",
+		},
+
+		"final": &framework.FieldSchema{
+			Type:        framework.TypeBool,
+			Description: "Whether the chunk is the last one of the stream",
+		},
+
+		"context": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Base64 encoded context for key derivation. Required if key derivation is enabled",
+		},
+	}
+}
+
+func (b *backend) pathStreamEncrypt() *framework.Path {
+	fields := streamFields()
+	fields["plaintext"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base64 encoded plaintext chunk",
+	}
+
+	return &framework.Path{
+		Pattern: "stream/encrypt/" + framework.GenericNameRegex("name"),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathStreamEncryptWrite,
+		},
+
+		HelpSynopsis:    pathStreamEncryptHelpSyn,
+		HelpDescription: pathStreamEncryptHelpDesc,
+	}
+}
+
+func (b *backend) pathStreamDecrypt() *framework.Path {
+	fields := streamFields()
+	fields["ciphertext"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Base64 encoded ciphertext chunk",
+	}
+
+	return &framework.Path{
+		Pattern: "stream/decrypt/" + framework.GenericNameRegex("name"),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathStreamDecryptWrite,
+		},
+
+		HelpSynopsis:    pathStreamDecryptHelpSyn,
+		HelpDescription: pathStreamDecryptHelpDesc,
+	}
+}
+
+// streamParams reads the index and the context of a chunk request
+func streamParams(d *framework.FieldData) (uint64, []byte, *logical.Response) {
+	index := d.Get("index").(int)
+	if index < 0 {
+		return 0, nil, logical.ErrorResponse("index cannot be negative")
+	}
+
+	var context []byte
+	if contextB64 := d.Get("context").(string); contextB64 != "" {
+		var err error
+		context, err = base64.StdEncoding.DecodeString(contextB64)
+		if err != nil {
+			return 0, nil, logical.ErrorResponse(fmt.Sprintf("unable to decode context as base64: %s", err))
+		}
+	}
+
+	return uint64(index), context, nil
+}
+
+func (b *backend) pathStreamEncryptWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	index, context, errResp := streamParams(d)
+	if errResp != nil {
+		return errResp, logical.ErrInvalidRequest
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(d.Get("plaintext").(string))
+	if err != nil {
+		return logical.ErrorResponse("failed to base64-decode plaintext"), logical.ErrInvalidRequest
+	}
+
+	header := d.Get("stream_header").(string)
+	if header == "" && index != 0 {
+		return logical.ErrorResponse("missing stream header for a chunk other than the first one"), logical.ErrInvalidRequest
+	}
+
+	// Get the policy
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, d.Get("name").(string))
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("policy not found"), logical.ErrInvalidRequest
+	}
+
+	if header == "" {
+		header, err = p.NewStreamHeader()
+		if err != nil {
+			return handleStreamError(err)
+		}
+	}
+
+	ciphertext, err := p.EncryptChunk(context, header, index, d.Get("final").(bool), plaintext)
+	if err != nil {
+		return handleStreamError(err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"stream_header": header,
+			"ciphertext":    base64.StdEncoding.EncodeToString(ciphertext),
+		},
+	}, nil
+}
+
+func (b *backend) pathStreamDecryptWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	index, context, errResp := streamParams(d)
+	if errResp != nil {
+		return errResp, logical.ErrInvalidRequest
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(d.Get("ciphertext").(string))
+	if err != nil {
+		return logical.ErrorResponse("failed to base64-decode ciphertext"), logical.ErrInvalidRequest
+	}
+
+	header := d.Get("stream_header").(string)
+	if header == "" {
+		return logical.ErrorResponse("missing stream header"), logical.ErrInvalidRequest
+	}
+
+	// Get the policy
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, d.Get("name").(string))
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("policy not found"), logical.ErrInvalidRequest
+	}
+
+	plaintext, err := p.DecryptChunk(context, header, index, d.Get("final").(bool), ciphertext)
+	if err != nil {
+		return handleStreamError(err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+		},
+	}, nil
+}
+
+func handleStreamError(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+const pathStreamEncryptHelpSyn = `Encrypt a large payload chunk by chunk`
+
+const pathStreamEncryptHelpDesc = `
+This path encrypts payloads too large for a single request, such as backups,
+one chunk at a time. The first chunk is sent without a stream header, and the
+returned header must be sent with the following chunks, along with their index
+in the stream. The last chunk must have the final flag set.
+
+Each stream is encrypted with its own key, derived from the version of the
+named key recorded in the header. Each chunk is encrypted with a random nonce
+and authenticated along with the header, its index and the final flag, so that
+reordered, dropped or truncated chunks are detected when decrypting. Only the header and the chunks need to be stored
+with the encrypted payload.
+`
+
+const pathStreamDecryptHelpSyn = `Decrypt a large payload chunk by chunk`
+
+const pathStreamDecryptHelpDesc = `
+This path decrypts the chunks returned by the "stream/encrypt" path, given the
+stream header, the index of the chunk and whether it is the last one. The
+chunks must be decrypted in order, and the payload is complete only once the
+chunk with the final flag set was decrypted.
+`
@@ -0,0 +1,148 @@
+package transit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_StreamEncryptDecrypt(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	expectError := func(path string, data map[string]interface{}) {
+		resp, err := request(path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("path %s: expected error for %#v, got: %#v", path, data, resp)
+		}
+	}
+
+	mustRequest("keys/test", nil)
+
+	chunks := [][]byte{
+		bytes.Repeat([]byte("a"), 1024),
+		bytes.Repeat([]byte("b"), 1024),
+		[]byte("the end"),
+	}
+
+	var header string
+	var ciphertexts []string
+	for i, chunk := range chunks {
+		resp := mustRequest("stream/encrypt/test", map[string]interface{}{
+			"plaintext":     base64.StdEncoding.EncodeToString(chunk),
+			"stream_header": header,
+			"index":         i,
+			"final":         i == len(chunks)-1,
+		})
+		if header == "" {
+			header = resp.Data["stream_header"].(string)
+		} else if resp.Data["stream_header"] != header {
+			t.Fatalf("bad: stream header changed from %s to %s", header, resp.Data["stream_header"])
+		}
+		ciphertexts = append(ciphertexts, resp.Data["ciphertext"].(string))
+	}
+
+	// Identical chunks at different indexes are encrypted differently
+	if ciphertexts[0] == ciphertexts[1] {
+		t.Fatalf("bad: identical chunks encrypted identically")
+	}
+
+	// Encrypting again under the header of a stream never reuses the
+	// keystream of its chunks, so that it can't be used to decrypt them
+	zeros := base64.StdEncoding.EncodeToString(make([]byte, len(chunks[0])))
+	var keystreams [][]byte
+	for i := 0; i < 2; i++ {
+		resp := mustRequest("stream/encrypt/test", map[string]interface{}{
+			"plaintext":     zeros,
+			"stream_header": header,
+			"index":         0,
+		})
+		ciphertext, err := base64.StdEncoding.DecodeString(resp.Data["ciphertext"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keystreams = append(keystreams, ciphertext[12:12+len(chunks[0])])
+	}
+	if bytes.Equal(keystreams[0], keystreams[1]) {
+		t.Fatalf("bad: same keystream used twice for a chunk")
+	}
+	victim, err := base64.StdEncoding.DecodeString(ciphertexts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, keystream := range keystreams {
+		recovered := make([]byte, len(keystream))
+		for i := range keystream {
+			recovered[i] = keystream[i] ^ victim[12+i]
+		}
+		if bytes.Equal(recovered, chunks[0]) {
+			t.Fatalf("bad: chunk decrypted with the keystream of another encryption")
+		}
+	}
+
+	// The stream can still be decrypted after a rotation
+	mustRequest("keys/test/rotate", nil)
+
+	for i, ciphertext := range ciphertexts {
+		resp := mustRequest("stream/decrypt/test", map[string]interface{}{
+			"ciphertext":    ciphertext,
+			"stream_header": header,
+			"index":         i,
+			"final":         i == len(chunks)-1,
+		})
+		if resp.Data["plaintext"] != base64.StdEncoding.EncodeToString(chunks[i]) {
+			t.Fatalf("bad: chunk %d decrypted as %s", i, resp.Data["plaintext"])
+		}
+	}
+
+	// Reordered and truncated streams, and chunks of other streams, fail to
+	// decrypt
+	resp := mustRequest("stream/encrypt/test", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(chunks[0]),
+	})
+	if resp.Data["stream_header"] == header || resp.Data["stream_header"].(string)[:8] != "vault:v2" {
+		t.Fatalf("bad: stream header of a new stream: %s", resp.Data["stream_header"])
+	}
+	for _, data := range []map[string]interface{}{
+		{"ciphertext": ciphertexts[1], "stream_header": header, "index": 0},
+		{"ciphertext": ciphertexts[1], "stream_header": header, "index": 1, "final": true},
+		{"ciphertext": ciphertexts[2], "stream_header": header, "index": 2},
+		{"ciphertext": ciphertexts[0], "stream_header": resp.Data["stream_header"], "index": 0},
+		{"ciphertext": ciphertexts[0], "index": 0},
+		{"ciphertext": ciphertexts[0], "stream_header": "vault:v3:stream:AAAAAAAAAAAAAAAAAAAAAA==", "index": 0},
+		{"ciphertext": ciphertexts[0], "stream_header": header, "index": -1},
+	} {
+		expectError("stream/decrypt/test", data)
+	}
+	expectError("stream/encrypt/test", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(chunks[1]),
+		"index":     1,
+	})
+
+	// Streams encrypted with versions below the minimum decryption version
+	// cannot be decrypted anymore
+	mustRequest("keys/test/config", map[string]interface{}{
+		"min_decryption_version": 2,
+	})
+	expectError("stream/decrypt/test", map[string]interface{}{
+		"ciphertext":    ciphertexts[0],
+		"stream_header": header,
+		"index":         0,
+	})
+}
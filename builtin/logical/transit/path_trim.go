@@ -0,0 +1,84 @@
+package transit
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathTrim() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/trim",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"min_available_version": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The minimum version of the key to keep. Older
+versions are permanently deleted. Cannot be greater than
+the minimum decryption version of the key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTrimWrite,
+		},
+
+		HelpSynopsis:    pathTrimHelpSyn,
+		HelpDescription: pathTrimHelpDesc,
+	}
+}
+
+func (b *backend) pathTrimWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	// Get the policy
+	p, lock, err := b.lm.GetPolicyExclusive(req.Storage, name)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+
+	minAvailableVersionRaw, ok := d.GetOk("min_available_version")
+	if !ok {
+		return logical.ErrorResponse("missing min_available_version"), logical.ErrInvalidRequest
+	}
+	minAvailableVersion := minAvailableVersionRaw.(int)
+
+	switch {
+	case minAvailableVersion < 1:
+		return logical.ErrorResponse("min available version cannot be less than 1"), logical.ErrInvalidRequest
+	case minAvailableVersion > p.MinDecryptionVersion:
+		return logical.ErrorResponse(
+			fmt.Sprintf("cannot set min available version of %d, min decryption version is %d", minAvailableVersion, p.MinDecryptionVersion)), logical.ErrInvalidRequest
+	case minAvailableVersion < p.MinAvailableVersion:
+		return logical.ErrorResponse(
+			fmt.Sprintf("cannot set min available version of %d, versions older than %d have already been trimmed", minAvailableVersion, p.MinAvailableVersion)), logical.ErrInvalidRequest
+	case minAvailableVersion == p.MinAvailableVersion:
+		return nil, nil
+	}
+
+	p.MinAvailableVersion = minAvailableVersion
+
+	return nil, p.Persist(req.Storage)
+}
+
+const pathTrimHelpSyn = `Trim old versions of a named encryption key`
+
+const pathTrimHelpDesc = `
+This path is used to permanently delete the versions of the named key older
+than the min_available_version parameter, for instance once all the data
+encrypted with them has been rewrapped. The minimum available version cannot
+be greater than the minimum decryption version of the key, and the deleted
+versions cannot be restored.
+`
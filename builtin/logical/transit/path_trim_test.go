@@ -0,0 +1,101 @@
+package transit
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_Trim(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	expectError := func(path string, data map[string]interface{}) {
+		resp, err := request(path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("path %s: expected error for %#v, got: %#v", path, data, resp)
+		}
+	}
+
+	mustRequest("keys/aes", nil)
+	plaintext := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+	var ciphertexts []string
+	for i := 1; i <= 5; i++ {
+		resp := mustRequest("encrypt/aes", map[string]interface{}{
+			"plaintext": plaintext,
+		})
+		ciphertexts = append(ciphertexts, resp.Data["ciphertext"].(string))
+		mustRequest("keys/aes/rotate", nil)
+	}
+
+	// Versions allowed to decrypt cannot be trimmed
+	expectError("keys/aes/trim", nil)
+	expectError("keys/aes/trim", map[string]interface{}{
+		"min_available_version": 0,
+	})
+	expectError("keys/aes/trim", map[string]interface{}{
+		"min_available_version": 2,
+	})
+	expectError("keys/missing/trim", map[string]interface{}{
+		"min_available_version": 1,
+	})
+
+	mustRequest("keys/aes/config", map[string]interface{}{
+		"min_decryption_version": 4,
+	})
+	mustRequest("keys/aes/trim", map[string]interface{}{
+		"min_available_version": 3,
+	})
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/aes",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["min_available_version"].(int) != 3 {
+		t.Fatalf("bad: key: %#v", resp.Data)
+	}
+
+	// Trimmed versions cannot be restored
+	expectError("keys/aes/trim", map[string]interface{}{
+		"min_available_version": 2,
+	})
+	expectError("keys/aes/config", map[string]interface{}{
+		"min_decryption_version": 2,
+	})
+
+	// The versions that were kept can still be used to decrypt
+	mustRequest("keys/aes/config", map[string]interface{}{
+		"min_decryption_version": 3,
+	})
+	for i, ciphertext := range ciphertexts {
+		resp, err := request("decrypt/aes", map[string]interface{}{
+			"ciphertext": ciphertext,
+		})
+		trimmed := i+1 < 3
+		if trimmed != (err != nil || (resp != nil && resp.IsError())) {
+			t.Fatalf("bad: decrypting version %d: err: %v, resp: %#v", i+1, err, resp)
+		}
+		if !trimmed && resp.Data["plaintext"] != plaintext {
+			t.Fatalf("bad: plaintext of version %d: %#v", i+1, resp.Data)
+		}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"os"
 
 	auditFile "github.com/hashicorp/vault/builtin/audit/file"
+	auditKafka "github.com/hashicorp/vault/builtin/audit/kafka"
 	auditSocket "github.com/hashicorp/vault/builtin/audit/socket"
 	auditSyslog "github.com/hashicorp/vault/builtin/audit/syslog"
+	auditWebhook "github.com/hashicorp/vault/builtin/audit/webhook"
 	"github.com/hashicorp/vault/version"
 
 	credAppId "github.com/hashicorp/vault/builtin/credential/app-id"
@@ -13,20 +15,27 @@ import (
 	credAwsEc2 "github.com/hashicorp/vault/builtin/credential/aws-ec2"
 	credCert "github.com/hashicorp/vault/builtin/credential/cert"
 	credGitHub "github.com/hashicorp/vault/builtin/credential/github"
+	credKubernetes "github.com/hashicorp/vault/builtin/credential/kubernetes"
 	credLdap "github.com/hashicorp/vault/builtin/credential/ldap"
+	credOIDC "github.com/hashicorp/vault/builtin/credential/oidc"
 	credOkta "github.com/hashicorp/vault/builtin/credential/okta"
 	credRadius "github.com/hashicorp/vault/builtin/credential/radius"
 	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
 
 	"github.com/hashicorp/vault/builtin/logical/aws"
+	"github.com/hashicorp/vault/builtin/logical/azure"
 	"github.com/hashicorp/vault/builtin/logical/cassandra"
 	"github.com/hashicorp/vault/builtin/logical/consul"
+	"github.com/hashicorp/vault/builtin/logical/elasticsearch"
+	"github.com/hashicorp/vault/builtin/logical/gcp"
+	"github.com/hashicorp/vault/builtin/logical/kubernetes"
 	"github.com/hashicorp/vault/builtin/logical/mongodb"
 	"github.com/hashicorp/vault/builtin/logical/mssql"
 	"github.com/hashicorp/vault/builtin/logical/mysql"
 	"github.com/hashicorp/vault/builtin/logical/pki"
 	"github.com/hashicorp/vault/builtin/logical/postgresql"
 	"github.com/hashicorp/vault/builtin/logical/rabbitmq"
+	"github.com/hashicorp/vault/builtin/logical/redis"
 	"github.com/hashicorp/vault/builtin/logical/ssh"
 	"github.com/hashicorp/vault/builtin/logical/transit"
 
@@ -60,37 +69,53 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"agent": func() (cli.Command, error) {
+			return &command.AgentCommand{
+				Meta:       *metaPtr,
+				ShutdownCh: command.MakeShutdownCh(),
+			}, nil
+		},
+
 		"server": func() (cli.Command, error) {
 			return &command.ServerCommand{
 				Meta: *metaPtr,
 				AuditBackends: map[string]audit.Factory{
-					"file":   auditFile.Factory,
-					"syslog": auditSyslog.Factory,
-					"socket": auditSocket.Factory,
+					"file":    auditFile.Factory,
+					"syslog":  auditSyslog.Factory,
+					"socket":  auditSocket.Factory,
+					"webhook": auditWebhook.Factory,
+					"kafka":   auditKafka.Factory,
 				},
 				CredentialBackends: map[string]logical.Factory{
-					"approle":  credAppRole.Factory,
-					"cert":     credCert.Factory,
-					"aws-ec2":  credAwsEc2.Factory,
-					"app-id":   credAppId.Factory,
-					"github":   credGitHub.Factory,
-					"userpass": credUserpass.Factory,
-					"ldap":     credLdap.Factory,
-					"okta":     credOkta.Factory,
-					"radius":   credRadius.Factory,
+					"approle":    credAppRole.Factory,
+					"cert":       credCert.Factory,
+					"aws-ec2":    credAwsEc2.Factory,
+					"app-id":     credAppId.Factory,
+					"github":     credGitHub.Factory,
+					"userpass":   credUserpass.Factory,
+					"ldap":       credLdap.Factory,
+					"okta":       credOkta.Factory,
+					"kubernetes": credKubernetes.Factory,
+					"oidc":       credOIDC.Factory,
+					"radius":     credRadius.Factory,
 				},
 				LogicalBackends: map[string]logical.Factory{
-					"aws":        aws.Factory,
-					"consul":     consul.Factory,
-					"postgresql": postgresql.Factory,
-					"cassandra":  cassandra.Factory,
-					"pki":        pki.Factory,
-					"transit":    transit.Factory,
-					"mongodb":    mongodb.Factory,
-					"mssql":      mssql.Factory,
-					"mysql":      mysql.Factory,
-					"ssh":        ssh.Factory,
-					"rabbitmq":   rabbitmq.Factory,
+					"aws":           aws.Factory,
+					"consul":        consul.Factory,
+					"postgresql":    postgresql.Factory,
+					"cassandra":     cassandra.Factory,
+					"pki":           pki.Factory,
+					"transit":       transit.Factory,
+					"mongodb":       mongodb.Factory,
+					"mssql":         mssql.Factory,
+					"mysql":         mysql.Factory,
+					"ssh":           ssh.Factory,
+					"rabbitmq":      rabbitmq.Factory,
+					"elasticsearch": elasticsearch.Factory,
+					"azure":         azure.Factory,
+					"gcp":           gcp.Factory,
+					"kubernetes":    kubernetes.Factory,
+					"redis":         redis.Factory,
 				},
 				ShutdownCh: command.MakeShutdownCh(),
 				SighupCh:   command.MakeSighupCh(),
@@ -113,12 +138,14 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			return &command.AuthCommand{
 				Meta: *metaPtr,
 				Handlers: map[string]command.AuthHandler{
-					"github":   &credGitHub.CLIHandler{},
-					"userpass": &credUserpass.CLIHandler{DefaultMount: "userpass"},
-					"ldap":     &credLdap.CLIHandler{},
-					"okta":     &credOkta.CLIHandler{},
-					"cert":     &credCert.CLIHandler{},
-					"radius":   &credUserpass.CLIHandler{DefaultMount: "radius"},
+					"github":     &credGitHub.CLIHandler{},
+					"userpass":   &credUserpass.CLIHandler{DefaultMount: "userpass"},
+					"ldap":       &credLdap.CLIHandler{},
+					"okta":       &credOkta.CLIHandler{},
+					"oidc":       &credOIDC.CLIHandler{},
+					"kubernetes": &credKubernetes.CLIHandler{},
+					"cert":       &credCert.CLIHandler{},
+					"radius":     &credUserpass.CLIHandler{DefaultMount: "radius"},
 				},
 			}, nil
 		},
@@ -159,6 +186,12 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"usage": func() (cli.Command, error) {
+			return &command.UsageCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+
 		"policies": func() (cli.Command, error) {
 			return &command.PolicyListCommand{
 				Meta: *metaPtr,
@@ -261,6 +294,13 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"migrate": func() (cli.Command, error) {
+			return &command.MigrateCommand{
+				Meta:       *metaPtr,
+				ShutdownCh: command.MakeShutdownCh(),
+			}, nil
+		},
+
 		"mounts": func() (cli.Command, error) {
 			return &command.MountsCommand{
 				Meta: *metaPtr,
@@ -0,0 +1,345 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	colorable "github.com/mattn/go-colorable"
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/command/agent/cache"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/meta"
+)
+
+// AgentCommand is a Command that starts the Vault agent.
+type AgentCommand struct {
+	meta.Meta
+
+	ShutdownCh chan struct{}
+
+	logger log.Logger
+
+	tokenLock sync.RWMutex
+	token     string
+}
+
+func (c *AgentCommand) Run(args []string) int {
+	var configPath, logLevel string
+	flags := c.Meta.FlagSet("agent", meta.FlagSetDefault)
+	flags.StringVar(&configPath, "config", "", "")
+	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if configPath == "" {
+		flags.Usage()
+		c.Ui.Error("\nAgent expects a configuration file given with -config")
+		return 1
+	}
+
+	var level int
+	switch logLevel {
+	case "trace":
+		level = log.LevelTrace
+	case "debug":
+		level = log.LevelDebug
+	case "info":
+		level = log.LevelInfo
+	case "notice":
+		level = log.LevelNotice
+	case "warn":
+		level = log.LevelWarn
+	case "err":
+		level = log.LevelError
+	default:
+		c.Ui.Output(fmt.Sprintf("Unknown log level %s", logLevel))
+		return 1
+	}
+	logWriter := colorable.NewColorable(os.Stderr)
+	c.logger = logformat.NewVaultLoggerWithWriter(logWriter, level)
+
+	agentConfig, err := config.LoadConfig(configPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading configuration from %s: %s", configPath, err))
+		return 1
+	}
+
+	// The auth handler and the sink server set the tokens of their clients,
+	// so each gets its own
+	var method auth.AuthMethod
+	var authClient, sinkClient *api.Client
+	var sinks []*sink.SinkConfig
+	if agentConfig.AutoAuth != nil {
+		if authClient, err = c.agentClient(agentConfig); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+			return 1
+		}
+		if sinkClient, err = c.agentClient(agentConfig); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+			return 1
+		}
+
+		method, err = auth.NewAuthMethod(agentConfig.AutoAuth.Method.Type, &auth.AuthConfig{
+			Logger:    c.logger,
+			MountPath: agentConfig.AutoAuth.Method.MountPath,
+			Config:    agentConfig.AutoAuth.Method.Config,
+		})
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error creating %s auth method: %s", agentConfig.AutoAuth.Method.Type, err))
+			return 1
+		}
+
+		for _, sc := range agentConfig.AutoAuth.Sinks {
+			s := &sink.SinkConfig{
+				Logger:  c.logger,
+				WrapTTL: sc.WrapTTL,
+				DHType:  sc.DHType,
+				DHPath:  sc.DHPath,
+				AAD:     sc.AAD,
+			}
+			if s.Sink, err = sink.NewSink(sc.Type, s, sc.Config); err != nil {
+				c.Ui.Error(fmt.Sprintf("Error creating %s sink: %s", sc.Type, err))
+				return 1
+			}
+			sinks = append(sinks, s)
+		}
+	}
+
+	var proxyClient *api.Client
+	var listeners []net.Listener
+	defer func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}()
+	if agentConfig.Cache != nil {
+		if proxyClient, err = c.agentClient(agentConfig); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+			return 1
+		}
+		if listeners, err = c.cacheListeners(agentConfig, logWriter); err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+	}
+
+	if err := c.storePidFile(agentConfig.PidFile); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error storing PID: %s", err))
+		return 1
+	}
+	defer c.removePidFile(agentConfig.PidFile)
+
+	c.Ui.Output("==> Vault agent started! Log data will stream in below:\n")
+
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+
+	if agentConfig.AutoAuth != nil {
+		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
+			Logger: c.logger,
+			Client: authClient,
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ah.Run(method, stopCh)
+		}()
+
+		// The tokens go through the agent when the cache uses them
+		tokenCh := ah.OutputCh
+		if agentConfig.Cache != nil && agentConfig.Cache.UseAutoAuthToken {
+			var sinkCh chan string
+			if len(sinks) > 0 {
+				sinkCh = make(chan string, 1)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.trackToken(ah.OutputCh, sinkCh, stopCh)
+			}()
+			tokenCh = sinkCh
+		}
+
+		if len(sinks) > 0 {
+			ss := sink.NewSinkServer(&sink.SinkServerConfig{
+				Logger: c.logger,
+				Client: sinkClient,
+			})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ss.Run(tokenCh, sinks, stopCh)
+			}()
+		}
+	}
+
+	if agentConfig.Cache != nil {
+		leaseCache := cache.NewLeaseCache(&cache.LeaseCacheConfig{
+			Proxier: cache.NewAPIProxy(proxyClient),
+			Logger:  c.logger,
+		})
+
+		var tokenFunc func() string
+		if agentConfig.Cache.UseAutoAuthToken {
+			tokenFunc = c.autoAuthToken
+		}
+		handler := cache.ProxyHandler(leaseCache, c.logger, tokenFunc)
+		for _, ln := range listeners {
+			go (&http.Server{Handler: handler}).Serve(ln)
+		}
+	}
+
+	<-c.ShutdownCh
+	c.Ui.Output("==> Vault agent shutdown triggered")
+	close(stopCh)
+	wg.Wait()
+
+	return 0
+}
+
+// cacheListeners creates the listeners of the cache
+func (c *AgentCommand) cacheListeners(agentConfig *config.Config, logWriter io.Writer) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, lnConfig := range agentConfig.Listeners {
+		var ln net.Listener
+		var props map[string]string
+		var err error
+		if lnConfig.Type == "tcp" || lnConfig.Type == "unix" {
+			ln, props, _, err = server.NewListener(lnConfig.Type, lnConfig.Config, logWriter)
+		} else {
+			err = fmt.Errorf("unsupported listener type")
+		}
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("Error initializing listener of type %s: %s", lnConfig.Type, err)
+		}
+		c.logger.Info("agent: cache listening", "type", lnConfig.Type, "address", props["addr"])
+		listeners = append(listeners, ln)
+	}
+
+	return listeners, nil
+}
+
+// trackToken records the tokens of the auth handler for the cache, and
+// passes them on to the sinks if out is set
+func (c *AgentCommand) trackToken(in <-chan string, out chan<- string, stopCh <-chan struct{}) {
+	for {
+		var token string
+		select {
+		case <-stopCh:
+			return
+		case token = <-in:
+		}
+
+		c.tokenLock.Lock()
+		c.token = token
+		c.tokenLock.Unlock()
+
+		if out == nil {
+			continue
+		}
+		select {
+		case <-stopCh:
+			return
+		case out <- token:
+		}
+	}
+}
+
+// autoAuthToken returns the last token of the auth handler
+func (c *AgentCommand) autoAuthToken() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+	return c.token
+}
+
+// agentClient returns a client without token, using the address of the
+// configuration if set
+func (c *AgentCommand) agentClient(agentConfig *config.Config) (*api.Client, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	client.ClearToken()
+
+	if agentConfig.Vault != nil && agentConfig.Vault.Address != "" {
+		if err := client.SetAddress(agentConfig.Vault.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// storePidFile writes the PID of the agent to the given path, if any
+func (c *AgentCommand) storePidFile(pidPath string) error {
+	if pidPath == "" {
+		return nil
+	}
+
+	pid := fmt.Sprintf("%d", os.Getpid())
+	return ioutil.WriteFile(pidPath, []byte(pid), 0644)
+}
+
+// removePidFile removes the PID file, if any
+func (c *AgentCommand) removePidFile(pidPath string) {
+	if pidPath == "" {
+		return
+	}
+
+	if err := os.Remove(pidPath); err != nil {
+		c.logger.Error("agent: error removing PID file", "error", err)
+	}
+}
+
+func (c *AgentCommand) Synopsis() string {
+	return "Start a Vault agent"
+}
+
+func (c *AgentCommand) Help() string {
+	helpText := `
+Usage: vault agent [options]
+
+  Start a Vault agent.
+
+  The agent authenticates to Vault on behalf of an application with the
+  auth method of its configuration, keeps the resulting token renewed, and
+  authenticates again when the token can no longer be renewed. Each token
+  is written to the sinks of the configuration, optionally response-wrapped
+  and encrypted, from which the application reads it.
+
+  The agent may also serve a caching proxy of the Vault API on the listeners
+  of the configuration. The tokens and leased secrets returned through the
+  proxy are cached and renewed, so that identical requests share them.
+
+  The connection to Vault uses the general options below, and the address
+  given in the "vault" block of the configuration if any.
+
+General Options:
+` + meta.GeneralOptionsUsage() + `
+Agent Options:
+
+  -config=<path>          Path to the agent configuration file.
+
+  -log-level=info         Log verbosity. Defaults to "info", will be output to
+                          stderr. Supported values: "trace", "debug", "info",
+                          "warn", "err"
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+type appRoleMethod struct {
+	logger    log.Logger
+	mountPath string
+
+	roleIDFilePath                 string
+	secretIDFilePath               string
+	removeSecretIDFileAfterReading bool
+
+	cachedSecretID string
+}
+
+// NewAppRoleAuthMethod returns an auth method logging in with the role ID
+// and secret ID read from files. The secret ID file is removed once read
+// unless remove_secret_id_file_after_reading is false, in which case it is
+// read again on each login.
+func NewAppRoleAuthMethod(conf *AuthConfig) (AuthMethod, error) {
+	a := &appRoleMethod{
+		logger:                         conf.Logger,
+		mountPath:                      conf.MountPath,
+		removeSecretIDFileAfterReading: true,
+	}
+
+	roleIDFilePathRaw, ok := conf.Config["role_id_file_path"]
+	if !ok {
+		return nil, fmt.Errorf("missing 'role_id_file_path' value")
+	}
+	if a.roleIDFilePath, ok = roleIDFilePathRaw.(string); !ok || a.roleIDFilePath == "" {
+		return nil, fmt.Errorf("'role_id_file_path' must be a non-empty string")
+	}
+
+	if secretIDFilePathRaw, ok := conf.Config["secret_id_file_path"]; ok {
+		if a.secretIDFilePath, ok = secretIDFilePathRaw.(string); !ok {
+			return nil, fmt.Errorf("'secret_id_file_path' must be a string")
+		}
+	}
+
+	if removeRaw, ok := conf.Config["remove_secret_id_file_after_reading"]; ok {
+		remove, err := parseutil.ParseBool(removeRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for 'remove_secret_id_file_after_reading': %v", err)
+		}
+		a.removeSecretIDFileAfterReading = remove
+	}
+
+	return a, nil
+}
+
+func (a *appRoleMethod) Authenticate(client *api.Client) (string, map[string]interface{}, error) {
+	roleID, err := ioutil.ReadFile(a.roleIDFilePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading role ID file: %v", err)
+	}
+	data := map[string]interface{}{
+		"role_id": strings.TrimSpace(string(roleID)),
+	}
+
+	if a.secretIDFilePath != "" {
+		secretID, err := a.secretID()
+		if err != nil {
+			return "", nil, err
+		}
+		data["secret_id"] = secretID
+	}
+
+	return fmt.Sprintf("%s/login", a.mountPath), data, nil
+}
+
+// secretID reads the secret ID file, falling back to the last secret ID
+// read once the file was removed
+func (a *appRoleMethod) secretID() (string, error) {
+	secretID, err := ioutil.ReadFile(a.secretIDFilePath)
+	if err != nil {
+		if os.IsNotExist(err) && a.cachedSecretID != "" {
+			return a.cachedSecretID, nil
+		}
+		return "", fmt.Errorf("error reading secret ID file: %v", err)
+	}
+
+	a.cachedSecretID = strings.TrimSpace(string(secretID))
+	if a.removeSecretIDFileAfterReading {
+		if err := os.Remove(a.secretIDFilePath); err != nil {
+			a.logger.Error("auth.approle: error removing secret ID file", "error", err)
+		}
+	}
+
+	return a.cachedSecretID, nil
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	initialBackoff = 1 * time.Second
+	maxBackoff     = 5 * time.Minute
+)
+
+// AuthMethod is an auth method the agent logs in with. Authenticate
+// returns the login path and the login data, and is called again whenever
+// the agent needs a new token.
+type AuthMethod interface {
+	Authenticate(*api.Client) (string, map[string]interface{}, error)
+}
+
+// AuthConfig is the configuration of an auth method
+type AuthConfig struct {
+	Logger    log.Logger
+	MountPath string
+	Config    map[string]interface{}
+}
+
+// NewAuthMethod returns the auth method of the given type
+func NewAuthMethod(methodType string, conf *AuthConfig) (AuthMethod, error) {
+	if conf.MountPath == "" {
+		conf.MountPath = "auth/" + methodType
+		if methodType == "aws" {
+			conf.MountPath = "auth/aws-ec2"
+		}
+	}
+	if conf.Config == nil {
+		conf.Config = make(map[string]interface{})
+	}
+
+	switch methodType {
+	case "approle":
+		return NewAppRoleAuthMethod(conf)
+	case "aws":
+		return NewAWSAuthMethod(conf)
+	case "cert":
+		return NewCertAuthMethod(conf)
+	default:
+		return nil, fmt.Errorf("unknown auth method %q", methodType)
+	}
+}
+
+// AuthHandler logs in with an auth method, keeps the resulting token
+// renewed and logs in again when the token can no longer be renewed. Each
+// new token is sent on OutputCh.
+type AuthHandler struct {
+	OutputCh chan string
+
+	logger log.Logger
+	client *api.Client
+}
+
+// AuthHandlerConfig is the configuration of an AuthHandler
+type AuthHandlerConfig struct {
+	Logger log.Logger
+	Client *api.Client
+}
+
+// NewAuthHandler returns an AuthHandler. The client must not be used
+// elsewhere as the handler sets its token.
+func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
+	return &AuthHandler{
+		OutputCh: make(chan string, 1),
+		logger:   conf.Logger,
+		client:   conf.Client,
+	}
+}
+
+// Run logs in and renews the tokens until stopCh is closed
+func (ah *AuthHandler) Run(am AuthMethod, stopCh <-chan struct{}) {
+	ah.logger.Info("auth: starting auth handler")
+	defer ah.logger.Info("auth: auth handler stopped")
+
+	backoff := initialBackoff
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		secret, err := ah.authenticate(am)
+		if err != nil {
+			ah.logger.Error("auth: error authenticating", "error", err, "backoff", backoff)
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = initialBackoff
+
+		ah.logger.Info("auth: authentication successful, sending token to sinks")
+		ah.client.SetToken(secret.Auth.ClientToken)
+		select {
+		case <-stopCh:
+			return
+		case ah.OutputCh <- secret.Auth.ClientToken:
+		}
+
+		if !ah.renew(secret.Auth, stopCh) {
+			return
+		}
+	}
+}
+
+func (ah *AuthHandler) authenticate(am AuthMethod) (*api.Secret, error) {
+	path, data, err := am.Authenticate(ah.client)
+	if err != nil {
+		return nil, fmt.Errorf("error getting login data: %v", err)
+	}
+
+	ah.client.ClearToken()
+	secret, err := ah.client.Logical().Write(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("no token returned by %s", path)
+	}
+
+	return secret, nil
+}
+
+// renew keeps the token renewed until it must be replaced, returning false
+// when stopCh is closed. The token is renewed when two thirds of its TTL
+// have elapsed. A renewal returning a shorter TTL means the token reached
+// its maximum TTL, so a new token is requested before it expires.
+func (ah *AuthHandler) renew(auth *api.SecretAuth, stopCh <-chan struct{}) bool {
+	// Tokens without TTL never need to be replaced
+	if auth.LeaseDuration == 0 {
+		<-stopCh
+		return false
+	}
+
+	ttl := auth.LeaseDuration
+	renewable := auth.Renewable
+	for {
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(renewInterval(ttl)):
+		}
+
+		if !renewable {
+			ah.logger.Info("auth: token is not renewable, re-authenticating")
+			return true
+		}
+
+		secret, err := ah.client.Auth().Token().RenewSelf(0)
+		if err != nil {
+			ah.logger.Error("auth: error renewing token, re-authenticating", "error", err)
+			return true
+		}
+		if secret == nil || secret.Auth == nil {
+			ah.logger.Error("auth: no auth returned when renewing token, re-authenticating")
+			return true
+		}
+		ah.logger.Trace("auth: renewed token", "ttl", secret.Auth.LeaseDuration)
+
+		if secret.Auth.LeaseDuration < ttl {
+			renewable = false
+		}
+		ttl = secret.Auth.LeaseDuration
+		if ttl == 0 {
+			return true
+		}
+	}
+}
+
+func renewInterval(ttl int) time.Duration {
+	return time.Duration(ttl) * time.Second * 2 / 3
+}
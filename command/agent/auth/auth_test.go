@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/logformat"
+)
+
+func TestAuthHandler(t *testing.T) {
+	var l sync.Mutex
+	var logins, renewals int
+	var renewedTokens []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		l.Lock()
+		defer l.Unlock()
+		logins++
+		fmt.Fprintf(w, `{"auth": {"client_token": "token-%d", "lease_duration": 1, "renewable": true}}`, logins)
+	})
+	mux.HandleFunc("/v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		l.Lock()
+		defer l.Unlock()
+		token := r.Header.Get("X-Vault-Token")
+		renewals++
+		renewedTokens = append(renewedTokens, token)
+
+		// The first token is renewed once, then refused
+		if token == "token-1" && renewals > 1 {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"auth": {"client_token": %q, "lease_duration": 1, "renewable": true}}`, token)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ah := NewAuthHandler(&AuthHandlerConfig{
+		Logger: logformat.NewVaultLogger(log.LevelTrace),
+		Client: client,
+	})
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		ah.Run(&testMethod{}, stopCh)
+		close(doneCh)
+	}()
+
+	for _, expected := range []string{"token-1", "token-2"} {
+		select {
+		case token := <-ah.OutputCh:
+			if token != expected {
+				t.Fatalf("expected %q, got %q", expected, token)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout waiting for %q", expected)
+		}
+	}
+
+	close(stopCh)
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("auth handler did not stop")
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if len(renewedTokens) < 2 || renewedTokens[0] != "token-1" || renewedTokens[1] != "token-1" {
+		t.Fatalf("bad: %v", renewedTokens)
+	}
+}
+
+func TestAppRoleAuthMethod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "approle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	roleIDPath := filepath.Join(dir, "role-id")
+	secretIDPath := filepath.Join(dir, "secret-id")
+	if err := ioutil.WriteFile(roleIDPath, []byte("foo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(secretIDPath, []byte("bar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewAuthMethod("approle", &AuthConfig{}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	am, err := NewAuthMethod("approle", &AuthConfig{
+		Logger: logformat.NewVaultLogger(log.LevelTrace),
+		Config: map[string]interface{}{
+			"role_id_file_path":   roleIDPath,
+			"secret_id_file_path": secretIDPath,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The secret ID is still sent once its file is removed
+	for i := 0; i < 2; i++ {
+		path, data, err := am.Authenticate(nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if path != "auth/approle/login" || data["role_id"] != "foo" || data["secret_id"] != "bar" {
+			t.Fatalf("bad: %s %#v", path, data)
+		}
+		if _, err := os.Stat(secretIDPath); !os.IsNotExist(err) {
+			t.Fatalf("secret ID file not removed: %v", err)
+		}
+	}
+}
+
+type testMethod struct{}
+
+func (testMethod) Authenticate(*api.Client) (string, map[string]interface{}, error) {
+	return "auth/approle/login", map[string]interface{}{"role_id": "foo"}, nil
+}
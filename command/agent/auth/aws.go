@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/api"
+)
+
+type awsMethod struct {
+	mountPath string
+	role      string
+	nonce     string
+}
+
+// NewAWSAuthMethod returns an auth method logging in with the PKCS#7
+// signature of the identity document of the EC2 instance. The same nonce
+// must be sent on each login, so it is generated once unless configured.
+func NewAWSAuthMethod(conf *AuthConfig) (AuthMethod, error) {
+	a := &awsMethod{
+		mountPath: conf.MountPath,
+	}
+
+	if roleRaw, ok := conf.Config["role"]; ok {
+		if a.role, ok = roleRaw.(string); !ok {
+			return nil, fmt.Errorf("'role' must be a string")
+		}
+	}
+
+	if nonceRaw, ok := conf.Config["nonce"]; ok {
+		if a.nonce, ok = nonceRaw.(string); !ok || a.nonce == "" {
+			return nil, fmt.Errorf("'nonce' must be a non-empty string")
+		}
+	} else {
+		nonce, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, fmt.Errorf("error generating nonce: %v", err)
+		}
+		a.nonce = nonce
+	}
+
+	return a, nil
+}
+
+func (a *awsMethod) Authenticate(client *api.Client) (string, map[string]interface{}, error) {
+	metadataSvc := ec2metadata.New(session.New())
+	pkcs7, err := metadataSvc.GetDynamicData("/instance-identity/pkcs7")
+	if err != nil {
+		return "", nil, fmt.Errorf("error fetching PKCS#7 signature from the metadata service: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"pkcs7": strings.Replace(strings.TrimSpace(pkcs7), "\n", "", -1),
+		"nonce": a.nonce,
+	}
+	if a.role != "" {
+		data["role"] = a.role
+	}
+
+	return fmt.Sprintf("%s/login", a.mountPath), data, nil
+}
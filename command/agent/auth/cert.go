@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+)
+
+type certMethod struct {
+	mountPath string
+	name      string
+}
+
+// NewCertAuthMethod returns an auth method logging in with the client
+// certificate of the connection to Vault, optionally against a named
+// certificate role
+func NewCertAuthMethod(conf *AuthConfig) (AuthMethod, error) {
+	c := &certMethod{
+		mountPath: conf.MountPath,
+	}
+
+	if nameRaw, ok := conf.Config["name"]; ok {
+		if c.name, ok = nameRaw.(string); !ok {
+			return nil, fmt.Errorf("'name' must be a string")
+		}
+	}
+
+	return c, nil
+}
+
+func (c *certMethod) Authenticate(client *api.Client) (string, map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	if c.name != "" {
+		data["name"] = c.name
+	}
+
+	return fmt.Sprintf("%s/login", c.mountPath), data, nil
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+// ProxyHandler returns the handler of the agent listeners, sending the
+// requests to Vault through the proxier. When tokenFunc is set, the
+// requests without token are made with the token it returns.
+func ProxyHandler(proxier Proxier, logger log.Logger, tokenFunc func() string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Vault-Token")
+		if token == "" && tokenFunc != nil {
+			token = tokenFunc()
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("error reading request body: %v", err))
+			return
+		}
+
+		resp, err := proxier.Send(&SendRequest{
+			Token:       token,
+			Request:     r,
+			RequestBody: body,
+		})
+		if err != nil {
+			logger.Error("cache: error proxying request", "path", r.URL.Path, "error", err)
+			respondError(w, http.StatusBadGateway, fmt.Errorf("error proxying request: %v", err))
+			return
+		}
+
+		for k, v := range resp.Response.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.Response.StatusCode)
+		w.Write(resp.ResponseBody)
+	})
+}
+
+func respondError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	body, _ := jsonutil.EncodeJSON(map[string][]string{
+		"errors": []string{err.Error()},
+	})
+	w.Write(body)
+}
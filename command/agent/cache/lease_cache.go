@@ -0,0 +1,387 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+// LeaseCache is a Proxier caching the responses carrying a lease or a
+// token, so that identical requests share the secret rather than each
+// creating their own. The cached secrets are renewed until they can no
+// longer be, and evicted when the cache sees them revoked.
+type LeaseCache struct {
+	proxier Proxier
+	logger  log.Logger
+
+	l       sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is a cached response. token is the token of the request,
+// while leaseID, or authToken and accessor, identify the secret of the
+// response.
+type cacheEntry struct {
+	key       string
+	token     string
+	leaseID   string
+	authToken string
+	accessor  string
+
+	statusCode int
+	header     http.Header
+	body       []byte
+
+	stopCh chan struct{}
+}
+
+// LeaseCacheConfig is the configuration of a LeaseCache
+type LeaseCacheConfig struct {
+	Proxier Proxier
+	Logger  log.Logger
+}
+
+// NewLeaseCache returns a LeaseCache sending the requests which are not
+// cached to the given Proxier
+func NewLeaseCache(conf *LeaseCacheConfig) *LeaseCache {
+	return &LeaseCache{
+		proxier: conf.Proxier,
+		logger:  conf.Logger,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+func (c *LeaseCache) Send(req *SendRequest) (*SendResponse, error) {
+	key := cacheKey(req)
+
+	c.l.RLock()
+	entry, ok := c.entries[key]
+	c.l.RUnlock()
+	if ok {
+		c.logger.Trace("cache: returning cached response", "path", req.Request.URL.Path)
+		return entry.sendResponse(), nil
+	}
+
+	resp, err := c.proxier.Send(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Response.StatusCode >= 400 {
+		return resp, nil
+	}
+
+	c.handleRevocation(req)
+
+	// Renewals return the secret they renew, which is already cached if it
+	// was created through the cache
+	if p := requestPath(req); strings.HasPrefix(p, "sys/leases/renew") ||
+		strings.HasPrefix(p, "sys/renew") || strings.HasPrefix(p, "auth/token/renew") {
+		return resp, nil
+	}
+
+	secret, err := api.ParseSecret(bytes.NewReader(resp.ResponseBody))
+	if err != nil || secret == nil || secret.WrapInfo != nil {
+		return resp, nil
+	}
+
+	entry = &cacheEntry{
+		key:        key,
+		token:      req.Token,
+		statusCode: resp.Response.StatusCode,
+		header:     resp.Response.Header,
+		body:       resp.ResponseBody,
+		stopCh:     make(chan struct{}),
+	}
+
+	var ttl int
+	var renewable bool
+	switch {
+	case secret.Auth != nil && secret.Auth.ClientToken != "":
+		entry.authToken = secret.Auth.ClientToken
+		entry.accessor = secret.Auth.Accessor
+		ttl, renewable = secret.Auth.LeaseDuration, secret.Auth.Renewable
+	case secret.LeaseID != "":
+		entry.leaseID = secret.LeaseID
+		ttl, renewable = secret.LeaseDuration, secret.Renewable
+	default:
+		return resp, nil
+	}
+
+	c.l.Lock()
+	if _, ok := c.entries[key]; ok {
+		// A concurrent identical request was cached first
+		c.l.Unlock()
+		return resp, nil
+	}
+	c.entries[key] = entry
+	c.l.Unlock()
+
+	c.logger.Debug("cache: cached response", "path", req.Request.URL.Path)
+	go c.renew(entry, ttl, renewable)
+
+	return resp, nil
+}
+
+// renew keeps the secret of the entry renewed, and evicts the entry once
+// the secret cannot be renewed anymore or is about to expire. Secrets
+// without TTL are kept until they are revoked.
+func (c *LeaseCache) renew(entry *cacheEntry, ttl int, renewable bool) {
+	if ttl == 0 {
+		return
+	}
+
+	for {
+		select {
+		case <-entry.stopCh:
+			return
+		case <-time.After(renewInterval(ttl)):
+		}
+
+		if !renewable {
+			c.logger.Debug("cache: secret expiring, evicting", "path", entry.key)
+			c.evict(entry)
+			return
+		}
+
+		newTTL, err := c.renewEntry(entry)
+		if err != nil {
+			c.logger.Debug("cache: error renewing secret, evicting", "error", err)
+			c.evict(entry)
+			return
+		}
+
+		// A shorter TTL means the secret reached its maximum TTL
+		if newTTL < ttl {
+			renewable = false
+		}
+		ttl = newTTL
+		if ttl == 0 {
+			c.evict(entry)
+			return
+		}
+	}
+}
+
+// renewEntry renews the secret of the entry, returning its new TTL
+func (c *LeaseCache) renewEntry(entry *cacheEntry) (int, error) {
+	var path, token string
+	var data map[string]interface{}
+	if entry.authToken != "" {
+		path, token = "/v1/auth/token/renew-self", entry.authToken
+	} else {
+		path, token = "/v1/sys/leases/renew", entry.token
+		data = map[string]interface{}{"lease_id": entry.leaseID}
+	}
+
+	req, err := newSendRequest("PUT", path, token, data)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.proxier.Send(req)
+	if err != nil {
+		return 0, err
+	}
+	if resp.Response.StatusCode >= 400 {
+		return 0, fmt.Errorf("renewal returned status %d", resp.Response.StatusCode)
+	}
+
+	secret, err := api.ParseSecret(bytes.NewReader(resp.ResponseBody))
+	if err != nil {
+		return 0, err
+	}
+	if secret == nil {
+		return 0, fmt.Errorf("no secret returned by renewal")
+	}
+	if entry.authToken != "" {
+		if secret.Auth == nil {
+			return 0, fmt.Errorf("no auth returned by renewal")
+		}
+		return secret.Auth.LeaseDuration, nil
+	}
+	return secret.LeaseDuration, nil
+}
+
+// handleRevocation evicts the entries of the secrets revoked by a
+// successful request
+func (c *LeaseCache) handleRevocation(req *SendRequest) {
+	if req.Request.Method != "PUT" && req.Request.Method != "POST" {
+		return
+	}
+
+	var data map[string]interface{}
+	if len(req.RequestBody) > 0 {
+		if err := jsonutil.DecodeJSON(req.RequestBody, &data); err != nil {
+			return
+		}
+	}
+	value := func(key, prefix string) string {
+		if v := strings.TrimPrefix(requestPath(req), prefix); v != requestPath(req) && v != "" {
+			return v
+		}
+		s, _ := data[key].(string)
+		return s
+	}
+
+	p := requestPath(req)
+	switch {
+	case p == "sys/leases/revoke" || strings.HasPrefix(p, "sys/leases/revoke/") ||
+		p == "sys/revoke" || strings.HasPrefix(p, "sys/revoke/"):
+		prefix := "sys/leases/revoke/"
+		if strings.HasPrefix(p, "sys/revoke") {
+			prefix = "sys/revoke/"
+		}
+		leaseID := value("lease_id", prefix)
+		c.evictMatching(func(e *cacheEntry) bool {
+			return leaseID != "" && e.leaseID == leaseID
+		})
+
+	case strings.HasPrefix(p, "sys/leases/revoke-prefix/") || strings.HasPrefix(p, "sys/revoke-prefix/") ||
+		strings.HasPrefix(p, "sys/leases/revoke-force/") || strings.HasPrefix(p, "sys/revoke-force/"):
+		prefix := p[strings.Index(p, "revoke-")+len("revoke-"):]
+		prefix = prefix[strings.Index(prefix, "/")+1:]
+		c.evictMatching(func(e *cacheEntry) bool {
+			return e.leaseID != "" && strings.HasPrefix(e.leaseID, prefix)
+		})
+
+	case p == "auth/token/revoke-self":
+		c.evictToken(req.Token, true)
+
+	case p == "auth/token/revoke" || strings.HasPrefix(p, "auth/token/revoke/"):
+		c.evictToken(value("token", "auth/token/revoke/"), true)
+
+	case p == "auth/token/revoke-orphan" || strings.HasPrefix(p, "auth/token/revoke-orphan/"):
+		c.evictToken(value("token", "auth/token/revoke-orphan/"), false)
+
+	case p == "auth/token/revoke-accessor" || strings.HasPrefix(p, "auth/token/revoke-accessor/"):
+		accessor := value("accessor", "auth/token/revoke-accessor/")
+		c.l.RLock()
+		var token string
+		for _, e := range c.entries {
+			if accessor != "" && e.accessor == accessor {
+				token = e.authToken
+			}
+		}
+		c.l.RUnlock()
+		c.evictToken(token, true)
+	}
+}
+
+// evictToken evicts the entry of the token, and the entries of the requests
+// made with the token, whose leases are revoked along with the token. The
+// child tokens are evicted as well unless the token is revoked alone.
+func (c *LeaseCache) evictToken(token string, tree bool) {
+	if token == "" {
+		return
+	}
+
+	var children []string
+	c.evictMatching(func(e *cacheEntry) bool {
+		if e.authToken == token {
+			return true
+		}
+		if e.token != token {
+			return false
+		}
+		if e.authToken != "" {
+			if !tree {
+				return false
+			}
+			children = append(children, e.authToken)
+		}
+		return true
+	})
+
+	for _, child := range children {
+		c.evictToken(child, tree)
+	}
+}
+
+func (c *LeaseCache) evictMatching(match func(*cacheEntry) bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for key, e := range c.entries {
+		if match(e) {
+			delete(c.entries, key)
+			close(e.stopCh)
+		}
+	}
+}
+
+func (c *LeaseCache) evict(entry *cacheEntry) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if c.entries[entry.key] == entry {
+		delete(c.entries, entry.key)
+	}
+}
+
+func (e *cacheEntry) sendResponse() *SendResponse {
+	return &SendResponse{
+		Response: &api.Response{
+			Response: &http.Response{
+				StatusCode: e.statusCode,
+				Header:     e.header,
+				Body:       ioutil.NopCloser(bytes.NewReader(e.body)),
+			},
+		},
+		ResponseBody: e.body,
+	}
+}
+
+// cacheKey identifies the requests returning the same response
+func cacheKey(req *SendRequest) string {
+	h := sha256.New()
+	h.Write([]byte(req.Token))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Request.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Request.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Request.URL.Query().Encode()))
+	h.Write([]byte{0})
+	h.Write(req.RequestBody)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func requestPath(req *SendRequest) string {
+	return strings.Trim(strings.TrimPrefix(req.Request.URL.Path, "/v1/"), "/")
+}
+
+func newSendRequest(method, p, token string, data map[string]interface{}) (*SendRequest, error) {
+	var body []byte
+	if data != nil {
+		var err error
+		if body, err = jsonutil.EncodeJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	httpReq := &http.Request{
+		Method: method,
+		URL:    &url.URL{Path: p},
+		Header: make(http.Header),
+	}
+	return &SendRequest{
+		Token:       token,
+		Request:     httpReq,
+		RequestBody: body,
+	}, nil
+}
+
+func renewInterval(ttl int) time.Duration {
+	return time.Duration(ttl) * time.Second * 2 / 3
+}
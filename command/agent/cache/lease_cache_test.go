@@ -0,0 +1,302 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/logformat"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+)
+
+// mockProxier returns the responses of the paths and records the requests
+type mockProxier struct {
+	l         sync.Mutex
+	responses map[string]func(*SendRequest) (int, string)
+	requests  []string
+}
+
+func (p *mockProxier) Send(req *SendRequest) (*SendResponse, error) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	path := requestPath(req)
+	p.requests = append(p.requests, req.Token+" "+path)
+
+	respFunc, ok := p.responses[path]
+	if !ok {
+		return nil, fmt.Errorf("unexpected request to %s", path)
+	}
+	status, body := respFunc(req)
+	return &SendResponse{
+		Response: &api.Response{
+			Response: &http.Response{
+				StatusCode: status,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			},
+		},
+		ResponseBody: []byte(body),
+	}, nil
+}
+
+func (p *mockProxier) count() int {
+	p.l.Lock()
+	defer p.l.Unlock()
+	return len(p.requests)
+}
+
+func testRequest(t *testing.T, method, path, token, body string) *SendRequest {
+	r := httptest.NewRequest(method, path, nil)
+	return &SendRequest{
+		Token:       token,
+		Request:     r,
+		RequestBody: []byte(body),
+	}
+}
+
+func TestLeaseCache_leases(t *testing.T) {
+	var leases int
+	proxier := &mockProxier{
+		responses: map[string]func(*SendRequest) (int, string){
+			"database/creds/foo": func(*SendRequest) (int, string) {
+				leases++
+				return 200, fmt.Sprintf(`{"lease_id": "database/creds/foo/%d", "lease_duration": 3600, "renewable": true, "data": {"username": "user-%d"}}`, leases, leases)
+			},
+			"secret/foo": func(*SendRequest) (int, string) {
+				return 200, `{"data": {"foo": "bar"}}`
+			},
+			"sys/leases/revoke": func(*SendRequest) (int, string) {
+				return 204, ""
+			},
+			"sys/leases/revoke-prefix/database/creds": func(*SendRequest) (int, string) {
+				return 204, ""
+			},
+		},
+	}
+	c := NewLeaseCache(&LeaseCacheConfig{
+		Proxier: proxier,
+		Logger:  logformat.NewVaultLogger(log.LevelTrace),
+	})
+
+	send := func(req *SendRequest) string {
+		resp, err := c.Send(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return string(resp.ResponseBody)
+	}
+
+	first := send(testRequest(t, "GET", "/v1/database/creds/foo", "t1", ""))
+	if second := send(testRequest(t, "GET", "/v1/database/creds/foo", "t1", "")); second != first {
+		t.Fatalf("expected cached response, got %s", second)
+	}
+	if proxier.count() != 1 {
+		t.Fatalf("bad: %v", proxier.requests)
+	}
+
+	// Other tokens do not share the lease
+	if other := send(testRequest(t, "GET", "/v1/database/creds/foo", "t2", "")); other == first {
+		t.Fatalf("expected new lease")
+	}
+
+	// Responses without lease are not cached
+	send(testRequest(t, "GET", "/v1/secret/foo", "t1", ""))
+	send(testRequest(t, "GET", "/v1/secret/foo", "t1", ""))
+	if proxier.count() != 4 {
+		t.Fatalf("bad: %v", proxier.requests)
+	}
+
+	// Revoking the lease evicts it
+	send(testRequest(t, "PUT", "/v1/sys/leases/revoke", "t1", `{"lease_id": "database/creds/foo/1"}`))
+	if third := send(testRequest(t, "GET", "/v1/database/creds/foo", "t1", "")); third == first {
+		t.Fatalf("expected new lease")
+	}
+	if proxier.count() != 6 {
+		t.Fatalf("bad: %v", proxier.requests)
+	}
+
+	// Revoking a prefix evicts all its leases
+	send(testRequest(t, "PUT", "/v1/sys/leases/revoke-prefix/database/creds", "t1", ""))
+	c.l.RLock()
+	entries := len(c.entries)
+	c.l.RUnlock()
+	if entries != 0 {
+		t.Fatalf("bad: %d entries", entries)
+	}
+}
+
+func TestLeaseCache_tokens(t *testing.T) {
+	var tokens int
+	proxier := &mockProxier{
+		responses: map[string]func(*SendRequest) (int, string){
+			"auth/token/create": func(*SendRequest) (int, string) {
+				tokens++
+				return 200, fmt.Sprintf(`{"auth": {"client_token": "token-%d", "accessor": "accessor-%d", "lease_duration": 3600, "renewable": true}}`, tokens, tokens)
+			},
+			"database/creds/foo": func(*SendRequest) (int, string) {
+				return 200, `{"lease_id": "database/creds/foo/1", "lease_duration": 3600, "renewable": true}`
+			},
+			"auth/token/revoke": func(*SendRequest) (int, string) {
+				return 204, ""
+			},
+			"auth/token/revoke-accessor": func(*SendRequest) (int, string) {
+				return 204, ""
+			},
+		},
+	}
+	c := NewLeaseCache(&LeaseCacheConfig{
+		Proxier: proxier,
+		Logger:  logformat.NewVaultLogger(log.LevelTrace),
+	})
+
+	cached := func() int {
+		c.l.RLock()
+		defer c.l.RUnlock()
+		return len(c.entries)
+	}
+	send := func(req *SendRequest) {
+		if _, err := c.Send(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// token-1 is created by root, token-2 by token-1, which reads a lease
+	send(testRequest(t, "POST", "/v1/auth/token/create", "root", `{"policies": ["foo"]}`))
+	send(testRequest(t, "POST", "/v1/auth/token/create", "token-1", ""))
+	send(testRequest(t, "GET", "/v1/database/creds/foo", "token-1", ""))
+	if cached() != 3 {
+		t.Fatalf("bad: %d entries", cached())
+	}
+
+	// Revoking token-1 evicts its child and its lease
+	send(testRequest(t, "PUT", "/v1/auth/token/revoke", "root", `{"token": "token-1"}`))
+	if cached() != 0 {
+		t.Fatalf("bad: %d entries", cached())
+	}
+
+	// Tokens are also evicted when revoked by accessor
+	send(testRequest(t, "POST", "/v1/auth/token/create", "root", `{"policies": ["foo"]}`))
+	if cached() != 1 {
+		t.Fatalf("bad: %d entries", cached())
+	}
+	send(testRequest(t, "PUT", "/v1/auth/token/revoke-accessor", "root", `{"accessor": "accessor-3"}`))
+	if cached() != 0 {
+		t.Fatalf("bad: %d entries", cached())
+	}
+}
+
+func TestLeaseCache_renew(t *testing.T) {
+	var renewals int
+	proxier := &mockProxier{
+		responses: map[string]func(*SendRequest) (int, string){
+			"database/creds/foo": func(*SendRequest) (int, string) {
+				return 200, `{"lease_id": "database/creds/foo/1", "lease_duration": 1, "renewable": true}`
+			},
+			"sys/leases/renew": func(req *SendRequest) (int, string) {
+				renewals++
+				if renewals > 1 {
+					return 400, `{"errors": ["lease not found or lease is not renewable"]}`
+				}
+				return 200, `{"lease_id": "database/creds/foo/1", "lease_duration": 1, "renewable": true}`
+			},
+		},
+	}
+	c := NewLeaseCache(&LeaseCacheConfig{
+		Proxier: proxier,
+		Logger:  logformat.NewVaultLogger(log.LevelTrace),
+	})
+
+	if _, err := c.Send(testRequest(t, "GET", "/v1/database/creds/foo", "t1", "")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The lease is renewed once, then evicted when the renewal fails
+	for i := 0; i < 50; i++ {
+		c.l.RLock()
+		entries := len(c.entries)
+		c.l.RUnlock()
+		if entries == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	proxier.l.Lock()
+	defer proxier.l.Unlock()
+	if renewals != 2 || len(proxier.requests) != 3 || proxier.requests[1] != "t1 sys/leases/renew" {
+		t.Fatalf("bad: %d %v", renewals, proxier.requests)
+	}
+	c.l.RLock()
+	defer c.l.RUnlock()
+	if len(c.entries) != 0 {
+		t.Fatalf("entry not evicted")
+	}
+}
+
+func TestProxyHandler(t *testing.T) {
+	core, _, root := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+	defer ln.Close()
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	c := NewLeaseCache(&LeaseCacheConfig{
+		Proxier: NewAPIProxy(client),
+		Logger:  logger,
+	})
+	server := httptest.NewServer(ProxyHandler(c, logger, func() string { return root }))
+	defer server.Close()
+
+	agentConfig := api.DefaultConfig()
+	agentConfig.Address = server.URL
+	agentClient, err := api.NewClient(agentConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentClient.ClearToken()
+
+	// The token of the agent is used, and the created token is shared
+	create := func() string {
+		secret, err := agentClient.Auth().Token().Create(&api.TokenCreateRequest{
+			Policies: []string{"default"},
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return secret.Auth.ClientToken
+	}
+	token := create()
+	if other := create(); other != token {
+		t.Fatalf("expected cached token, got %s and %s", token, other)
+	}
+
+	// Errors are sent back as they are
+	if _, err := agentClient.Logical().Write("sys/mounts/foo", map[string]interface{}{"type": "nope"}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	agentClient.SetToken(token)
+	if err := agentClient.Auth().Token().RevokeSelf(""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	agentClient.ClearToken()
+	if other := create(); other == token {
+		t.Fatalf("expected new token")
+	}
+}
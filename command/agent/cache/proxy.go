@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+// SendRequest is a request to Vault made through the agent
+type SendRequest struct {
+	Token       string
+	Request     *http.Request
+	RequestBody []byte
+}
+
+// SendResponse is the response of Vault to a SendRequest, with its body
+// read so that it can be cached
+type SendResponse struct {
+	Response     *api.Response
+	ResponseBody []byte
+}
+
+// Proxier sends the requests of the agent listeners to Vault
+type Proxier interface {
+	Send(*SendRequest) (*SendResponse, error)
+}
+
+// NewSendResponse reads the body of the response
+func NewSendResponse(resp *api.Response) (*SendResponse, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return &SendResponse{
+		Response:     resp,
+		ResponseBody: body,
+	}, nil
+}
+
+// APIProxy is the Proxier sending the requests to Vault with an API client
+type APIProxy struct {
+	client *api.Client
+}
+
+// NewAPIProxy returns an APIProxy. The token of the client is never set,
+// as each request carries its own, so it may be shared by the listeners.
+func NewAPIProxy(client *api.Client) *APIProxy {
+	client.ClearToken()
+	client.SetWrappingLookupFunc(func(operation, path string) string {
+		return ""
+	})
+
+	return &APIProxy{
+		client: client,
+	}
+}
+
+func (ap *APIProxy) Send(req *SendRequest) (*SendResponse, error) {
+	fwReq := ap.client.NewRequest(req.Request.Method, req.Request.URL.Path)
+	fwReq.ClientToken = req.Token
+	fwReq.WrapTTL = req.Request.Header.Get("X-Vault-Wrap-TTL")
+	fwReq.Params = req.Request.URL.Query()
+
+	// Vault only takes JSON bodies, which are decoded so that they can be
+	// sent again on redirects
+	if len(req.RequestBody) > 0 {
+		var obj interface{}
+		if err := jsonutil.DecodeJSON(req.RequestBody, &obj); err != nil {
+			return nil, fmt.Errorf("error decoding request body: %v", err)
+		}
+		if err := fwReq.SetJSONBody(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := ap.client.RawRequestWithContext(req.Request.Context(), fwReq)
+	if resp == nil {
+		return nil, err
+	}
+
+	// Error responses are sent back as they are
+	return NewSendResponse(resp)
+}
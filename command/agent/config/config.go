@@ -0,0 +1,368 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+// Config is the configuration for the vault agent.
+type Config struct {
+	AutoAuth  *AutoAuth   `hcl:"-"`
+	Cache     *Cache      `hcl:"-"`
+	Listeners []*Listener `hcl:"-"`
+	Vault     *Vault      `hcl:"-"`
+
+	PidFile string `hcl:"pid_file"`
+}
+
+// Vault contains the settings of the connection to the Vault server
+type Vault struct {
+	Address string `hcl:"address"`
+}
+
+// Cache is the configuration of the caching proxy served on the listeners
+type Cache struct {
+	UseAutoAuthToken bool `hcl:"use_auto_auth_token"`
+}
+
+// Listener is a listener of the caching proxy, configured as the listeners
+// of the server
+type Listener struct {
+	Type   string
+	Config map[string]string
+}
+
+// AutoAuth is the configured authentication method and the sinks its
+// tokens are written to
+type AutoAuth struct {
+	Method *Method `hcl:"-"`
+	Sinks  []*Sink `hcl:"-"`
+}
+
+// Method is the auth method the agent logs in with
+type Method struct {
+	Type      string
+	MountPath string                 `hcl:"mount_path"`
+	Config    map[string]interface{} `hcl:"config"`
+}
+
+// Sink is a destination of the tokens
+type Sink struct {
+	Type       string
+	WrapTTLRaw interface{}            `hcl:"wrap_ttl"`
+	WrapTTL    time.Duration          `hcl:"-"`
+	DHType     string                 `hcl:"dh_type"`
+	DHPath     string                 `hcl:"dh_path"`
+	AAD        string                 `hcl:"aad"`
+	Config     map[string]interface{} `hcl:"config"`
+}
+
+// LoadConfig loads the configuration at the given path
+func LoadConfig(path string) (*Config, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(string(d))
+}
+
+// ParseConfig parses the agent configuration from a string
+func ParseConfig(d string) (*Config, error) {
+	obj, err := hcl.Parse(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Config
+	if err := hcl.DecodeObject(&result, obj); err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: file doesn't contain a root object")
+	}
+
+	valid := []string{
+		"pid_file",
+		"vault",
+		"auto_auth",
+		"cache",
+		"listener",
+	}
+	if err := checkHCLKeys(list, valid); err != nil {
+		return nil, err
+	}
+
+	if o := list.Filter("vault"); len(o.Items) > 0 {
+		if err := parseVault(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'vault': %s", err)
+		}
+	}
+
+	if o := list.Filter("cache"); len(o.Items) > 0 {
+		if err := parseCache(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'cache': %s", err)
+		}
+	}
+
+	if o := list.Filter("listener"); len(o.Items) > 0 {
+		if err := parseListeners(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'listener': %s", err)
+		}
+	}
+
+	if o := list.Filter("auto_auth"); len(o.Items) > 0 {
+		if err := parseAutoAuth(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'auto_auth': %s", err)
+		}
+	}
+
+	switch {
+	case result.AutoAuth == nil && result.Cache == nil:
+		return nil, fmt.Errorf("at least one of 'auto_auth' and 'cache' must be configured")
+	case result.Cache != nil && len(result.Listeners) == 0:
+		return nil, fmt.Errorf("at least one 'listener' must be configured for the cache")
+	case result.Cache == nil && len(result.Listeners) > 0:
+		return nil, fmt.Errorf("'listener' requires 'cache' to be configured")
+	case result.Cache != nil && result.Cache.UseAutoAuthToken && result.AutoAuth == nil:
+		return nil, fmt.Errorf("'use_auto_auth_token' requires 'auto_auth' to be configured")
+	case result.AutoAuth != nil && len(result.AutoAuth.Sinks) == 0 &&
+		(result.Cache == nil || !result.Cache.UseAutoAuthToken):
+		return nil, fmt.Errorf("at least one 'sink' must be configured unless the cache uses the auto-auth token")
+	}
+
+	return &result, nil
+}
+
+func parseCache(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'cache' block is permitted")
+	}
+
+	item := list.Items[0]
+
+	valid := []string{
+		"use_auto_auth_token",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, "cache:")
+	}
+
+	var c Cache
+	if err := hcl.DecodeObject(&c, item.Val); err != nil {
+		return multierror.Prefix(err, "cache:")
+	}
+
+	result.Cache = &c
+	return nil
+}
+
+func parseListeners(result *Config, list *ast.ObjectList) error {
+	listeners := make([]*Listener, 0, len(list.Items))
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			return fmt.Errorf("listener type must be specified")
+		}
+		key := item.Keys[0].Token.Value().(string)
+
+		var m map[string]string
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("listener.%s:", key))
+		}
+
+		listeners = append(listeners, &Listener{
+			Type:   strings.ToLower(key),
+			Config: m,
+		})
+	}
+
+	result.Listeners = listeners
+	return nil
+}
+
+func parseVault(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'vault' block is permitted")
+	}
+
+	item := list.Items[0]
+
+	valid := []string{
+		"address",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, "vault:")
+	}
+
+	var v Vault
+	if err := hcl.DecodeObject(&v, item.Val); err != nil {
+		return multierror.Prefix(err, "vault:")
+	}
+
+	result.Vault = &v
+	return nil
+}
+
+func parseAutoAuth(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'auto_auth' block is permitted")
+	}
+
+	item := list.Items[0]
+
+	valid := []string{
+		"method",
+		"sink",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, "auto_auth:")
+	}
+
+	var subList *ast.ObjectList
+	switch n := item.Val.(type) {
+	case *ast.ObjectType:
+		subList = n.List
+	default:
+		return fmt.Errorf("'auto_auth' must be a block")
+	}
+
+	var a AutoAuth
+	result.AutoAuth = &a
+
+	if o := subList.Filter("method"); len(o.Items) > 0 {
+		if err := parseMethod(&a, o); err != nil {
+			return fmt.Errorf("error parsing 'method': %s", err)
+		}
+	} else {
+		return fmt.Errorf("an auth 'method' must be configured")
+	}
+
+	if o := subList.Filter("sink"); len(o.Items) > 0 {
+		if err := parseSinks(&a, o); err != nil {
+			return fmt.Errorf("error parsing 'sink': %s", err)
+		}
+	}
+
+	return nil
+}
+
+func parseMethod(result *AutoAuth, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'method' block is permitted")
+	}
+
+	item := list.Items[0]
+	if len(item.Keys) == 0 {
+		return fmt.Errorf("method type must be specified")
+	}
+	key := item.Keys[0].Token.Value().(string)
+
+	valid := []string{
+		"mount_path",
+		"config",
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("method.%s:", key))
+	}
+
+	var m Method
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("method.%s:", key))
+	}
+	m.Type = strings.ToLower(key)
+
+	result.Method = &m
+	return nil
+}
+
+func parseSinks(result *AutoAuth, list *ast.ObjectList) error {
+	sinks := make([]*Sink, 0, len(list.Items))
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			return fmt.Errorf("sink type must be specified")
+		}
+		key := item.Keys[0].Token.Value().(string)
+
+		valid := []string{
+			"wrap_ttl",
+			"dh_type",
+			"dh_path",
+			"aad",
+			"config",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("sink.%s:", key))
+		}
+
+		var s Sink
+		if err := hcl.DecodeObject(&s, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("sink.%s:", key))
+		}
+		s.Type = strings.ToLower(key)
+
+		if s.WrapTTLRaw != nil {
+			var err error
+			if s.WrapTTL, err = parseutil.ParseDurationSecond(s.WrapTTLRaw); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("sink.%s:", key))
+			}
+		}
+
+		switch s.DHType {
+		case "":
+			if s.DHPath != "" {
+				return fmt.Errorf("sink.%s: 'dh_path' requires 'dh_type' to be set", key)
+			}
+			if s.AAD != "" {
+				return fmt.Errorf("sink.%s: 'aad' requires 'dh_type' to be set", key)
+			}
+		case "curve25519":
+			if s.DHPath == "" {
+				return fmt.Errorf("sink.%s: 'dh_path' must be set when 'dh_type' is set", key)
+			}
+		default:
+			return fmt.Errorf("sink.%s: invalid 'dh_type' %q", key, s.DHType)
+		}
+
+		sinks = append(sinks, &s)
+	}
+
+	result.Sinks = sinks
+	return nil
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key '%s' on line %d", key, item.Assign.Line))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:      "approle",
+				MountPath: "auth/approle-test",
+				Config: map[string]interface{}{
+					"role_id_file_path":   "/tmp/role-id",
+					"secret_id_file_path": "/tmp/secret-id",
+				},
+			},
+			Sinks: []*Sink{
+				&Sink{
+					Type: "file",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-foo",
+					},
+				},
+				&Sink{
+					Type:       "file",
+					WrapTTLRaw: "5m",
+					WrapTTL:    5 * time.Minute,
+					DHType:     "curve25519",
+					DHPath:     "/tmp/file-foo-dhpath",
+					AAD:        "foobar",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-bar",
+						"mode": "0600",
+					},
+				},
+			},
+		},
+		Vault: &Vault{
+			Address: "https://127.0.0.1:8200",
+		},
+		PidFile: "./pidfile",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config.AutoAuth, expected.AutoAuth)
+	}
+}
+
+func TestLoadConfig_cache(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-cache.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type: "cert",
+				Config: map[string]interface{}{
+					"name": "web",
+				},
+			},
+		},
+		Cache: &Cache{
+			UseAutoAuthToken: true,
+		},
+		Listeners: []*Listener{
+			&Listener{
+				Type: "unix",
+				Config: map[string]string{
+					"address":     "/tmp/vault-agent.sock",
+					"tls_disable": "true",
+				},
+			},
+			&Listener{
+				Type: "tcp",
+				Config: map[string]string{
+					"address":     "127.0.0.1:8300",
+					"tls_disable": "true",
+				},
+			},
+		},
+		PidFile: "./pidfile",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config, expected)
+	}
+}
+
+func TestParseConfig_invalid(t *testing.T) {
+	cases := map[string]string{
+		"no auto_auth": `pid_file = "foo"`,
+		"no method": `
+auto_auth {
+    sink "file" {}
+}`,
+		"no sink": `
+auto_auth {
+    method "approle" {}
+}`,
+		"bad key": `
+auto_auth {
+    method "approle" {
+        foo = "bar"
+    }
+    sink "file" {}
+}`,
+		"dh_path without dh_type": `
+auto_auth {
+    method "approle" {}
+    sink "file" {
+        dh_path = "/tmp/foo"
+    }
+}`,
+		"cache without listener": `
+cache {}`,
+		"listener without cache": `
+auto_auth {
+    method "approle" {}
+    sink "file" {}
+}
+listener "tcp" {
+    address = "127.0.0.1:8300"
+}`,
+		"auto-auth token without auto_auth": `
+cache {
+    use_auto_auth_token = true
+}
+listener "tcp" {
+    address = "127.0.0.1:8300"
+}`,
+		"bad dh_type": `
+auto_auth {
+    method "approle" {}
+    sink "file" {
+        dh_type = "p256"
+        dh_path = "/tmp/foo"
+    }
+}`,
+	}
+
+	for name, c := range cases {
+		if _, err := ParseConfig(strings.TrimSpace(c)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
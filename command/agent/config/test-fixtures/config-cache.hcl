@@ -0,0 +1,23 @@
+pid_file = "./pidfile"
+
+auto_auth {
+    method "cert" {
+        config = {
+            name = "web"
+        }
+    }
+}
+
+cache {
+    use_auto_auth_token = true
+}
+
+listener "unix" {
+    address = "/tmp/vault-agent.sock"
+    tls_disable = "true"
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = "true"
+}
@@ -0,0 +1,32 @@
+pid_file = "./pidfile"
+
+vault {
+    address = "https://127.0.0.1:8200"
+}
+
+auto_auth {
+    method "approle" {
+        mount_path = "auth/approle-test"
+        config = {
+            role_id_file_path = "/tmp/role-id"
+            secret_id_file_path = "/tmp/secret-id"
+        }
+    }
+
+    sink "file" {
+        config = {
+            path = "/tmp/file-foo"
+        }
+    }
+
+    sink "file" {
+        wrap_ttl = "5m"
+        dh_type = "curve25519"
+        dh_path = "/tmp/file-foo-dhpath"
+        aad = "foobar"
+        config = {
+            path = "/tmp/file-bar"
+            mode = "0600"
+        }
+    }
+}
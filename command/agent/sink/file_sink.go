@@ -0,0 +1,71 @@
+package sink
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type fileSink struct {
+	path string
+	mode os.FileMode
+}
+
+// NewFileSink returns a sink writing the tokens to the file at 'path',
+// with the octal permissions given in 'mode', by default 0640. Each token
+// is written to a temporary file which is then renamed, so that readers
+// never see a partial token.
+func NewFileSink(conf *SinkConfig, config map[string]interface{}) (Sink, error) {
+	f := &fileSink{
+		mode: 0640,
+	}
+
+	pathRaw, ok := config["path"]
+	if !ok {
+		return nil, fmt.Errorf("'path' must be specified")
+	}
+	if f.path, ok = pathRaw.(string); !ok || f.path == "" {
+		return nil, fmt.Errorf("'path' must be a non-empty string")
+	}
+
+	if modeRaw, ok := config["mode"]; ok {
+		modeStr, ok := modeRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("'mode' must be a string")
+		}
+		mode, err := strconv.ParseUint(modeStr, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for 'mode': %v", err)
+		}
+		f.mode = os.FileMode(mode)
+	}
+
+	return f, nil
+}
+
+func (f *fileSink) WriteToken(token string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(f.mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting file mode: %v", err)
+	}
+	if _, err := tmp.WriteString(token); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing token: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("error renaming temporary file: %v", err)
+	}
+	return nil
+}
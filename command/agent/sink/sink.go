@@ -0,0 +1,189 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/dhutil"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+const (
+	retryInterval = 5 * time.Second
+)
+
+// Sink is a destination of the tokens
+type Sink interface {
+	WriteToken(string) error
+}
+
+// SinkConfig is a sink along with the transformations applied to the
+// tokens before they are written to it: response-wrapping when WrapTTL is
+// set, then encryption to the public key found at DHPath when DHType is set
+type SinkConfig struct {
+	Sink
+
+	Logger  log.Logger
+	WrapTTL time.Duration
+	DHType  string
+	DHPath  string
+	AAD     string
+}
+
+// NewSink returns the sink of the given type
+func NewSink(sinkType string, conf *SinkConfig, config map[string]interface{}) (Sink, error) {
+	switch sinkType {
+	case "file":
+		return NewFileSink(conf, config)
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sinkType)
+	}
+}
+
+// SinkServer writes the tokens it receives to the sinks. A sink failing to
+// write a token is retried until it succeeds or a new token arrives.
+type SinkServer struct {
+	logger log.Logger
+	client *api.Client
+}
+
+// SinkServerConfig is the configuration of a SinkServer
+type SinkServerConfig struct {
+	Logger log.Logger
+	Client *api.Client
+}
+
+// NewSinkServer returns a SinkServer. The client is used to wrap the
+// tokens and must not be used elsewhere as the server sets its token.
+func NewSinkServer(conf *SinkServerConfig) *SinkServer {
+	return &SinkServer{
+		logger: conf.Logger,
+		client: conf.Client,
+	}
+}
+
+// Run writes the tokens of incoming to the sinks until stopCh is closed
+func (ss *SinkServer) Run(incoming <-chan string, sinks []*SinkConfig, stopCh <-chan struct{}) {
+	ss.logger.Info("sink: starting sink server")
+	defer ss.logger.Info("sink: sink server stopped")
+
+	var token string
+	var pending []*SinkConfig
+	var retryCh <-chan time.Time
+	for {
+		select {
+		case <-stopCh:
+			return
+
+		case token = <-incoming:
+			pending = sinks
+
+		case <-retryCh:
+		}
+
+		pending = ss.writeToken(token, pending)
+		retryCh = nil
+		if len(pending) > 0 {
+			retryCh = time.After(retryInterval)
+		}
+	}
+}
+
+// writeToken writes the token to the sinks, returning the sinks that
+// failed
+func (ss *SinkServer) writeToken(token string, sinks []*SinkConfig) []*SinkConfig {
+	var failed []*SinkConfig
+	for _, sc := range sinks {
+		if err := ss.writeSinkToken(token, sc); err != nil {
+			ss.logger.Error("sink: error writing token, will retry", "error", err, "backoff", retryInterval)
+			failed = append(failed, sc)
+		}
+	}
+	return failed
+}
+
+func (ss *SinkServer) writeSinkToken(token string, sc *SinkConfig) error {
+	var err error
+	if sc.WrapTTL > 0 {
+		if token, err = ss.wrapToken(token, sc.WrapTTL); err != nil {
+			return err
+		}
+	}
+
+	if sc.DHType != "" {
+		if token, err = encryptToken(token, sc); err != nil {
+			return err
+		}
+	}
+
+	return sc.WriteToken(token)
+}
+
+// wrapToken returns the JSON wrapping information of a response-wrapping
+// token holding the token
+func (ss *SinkServer) wrapToken(token string, wrapTTL time.Duration) (string, error) {
+	ss.client.SetToken(token)
+	ss.client.SetWrappingLookupFunc(func(operation, path string) string {
+		return wrapTTL.String()
+	})
+
+	secret, err := ss.client.Logical().Write("sys/wrapping/wrap", map[string]interface{}{
+		"token": token,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error wrapping token: %v", err)
+	}
+	if secret == nil || secret.WrapInfo == nil {
+		return "", fmt.Errorf("no wrapping information returned when wrapping token")
+	}
+
+	wrapInfo, err := json.Marshal(secret.WrapInfo)
+	if err != nil {
+		return "", fmt.Errorf("error encoding wrapping information: %v", err)
+	}
+	return string(wrapInfo), nil
+}
+
+// encryptToken returns the JSON envelope of the token encrypted to the
+// public key found at the DH path of the sink. A new key pair is generated
+// for each token.
+func encryptToken(token string, sc *SinkConfig) (string, error) {
+	buf, err := ioutil.ReadFile(sc.DHPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading public key file: %v", err)
+	}
+
+	var pkInfo dhutil.PublicKeyInfo
+	if err := jsonutil.DecodeJSON(buf, &pkInfo); err != nil {
+		return "", fmt.Errorf("error decoding public key file: %v", err)
+	}
+
+	pub, pri, err := dhutil.GeneratePublicPrivateKey()
+	if err != nil {
+		return "", fmt.Errorf("error generating key pair: %v", err)
+	}
+	key, err := dhutil.GenerateSharedKey(pri, pkInfo.Curve25519PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("error generating shared key: %v", err)
+	}
+
+	ciphertext, nonce, err := dhutil.EncryptAES(key, []byte(token), []byte(sc.AAD))
+	if err != nil {
+		return "", fmt.Errorf("error encrypting token: %v", err)
+	}
+
+	envelope, err := json.Marshal(&dhutil.Envelope{
+		Curve25519PublicKey: pub,
+		Nonce:               nonce,
+		EncryptedPayload:    ciphertext,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding envelope: %v", err)
+	}
+	return string(envelope), nil
+}
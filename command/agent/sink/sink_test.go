@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/dhutil"
+	"github.com/hashicorp/vault/helper/logformat"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	if _, err := NewFileSink(&SinkConfig{}, map[string]interface{}{}); err == nil {
+		t.Fatalf("expected error")
+	}
+	s, err := NewFileSink(&SinkConfig{}, map[string]interface{}{
+		"path": path,
+		"mode": "0600",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, token := range []string{"foo", "bar"} {
+		if err := s.WriteToken(token); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if string(buf) != token {
+			t.Fatalf("expected %q, got %q", token, buf)
+		}
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("bad mode: %v", fi.Mode())
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("temporary files left: %v", files)
+	}
+}
+
+func TestSinkServer_wrapAndEncrypt(t *testing.T) {
+	core, _, root := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+	defer ln.Close()
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The reader of the sink holds the private key
+	pub, pri, err := dhutil.GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkInfo, err := json.Marshal(&dhutil.PublicKeyInfo{Curve25519PublicKey: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dhPath := filepath.Join(dir, "dh")
+	if err := ioutil.WriteFile(dhPath, pkInfo, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	path := filepath.Join(dir, "token")
+	sc := &SinkConfig{
+		Logger:  logger,
+		WrapTTL: 5 * time.Minute,
+		DHType:  "curve25519",
+		DHPath:  dhPath,
+		AAD:     "foobar",
+	}
+	if sc.Sink, err = NewSink("file", sc, map[string]interface{}{"path": path}); err != nil {
+		t.Fatal(err)
+	}
+
+	ss := NewSinkServer(&SinkServerConfig{
+		Logger: logger,
+		Client: client,
+	})
+	incoming := make(chan string)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go ss.Run(incoming, []*SinkConfig{sc}, stopCh)
+	incoming <- root
+
+	var buf []byte
+	for i := 0; i < 50; i++ {
+		if buf, err = ioutil.ReadFile(path); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("token not written: %v", err)
+	}
+
+	var envelope dhutil.Envelope
+	if err := json.Unmarshal(buf, &envelope); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, err := dhutil.GenerateSharedKey(pri, envelope.Curve25519PublicKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext, err := dhutil.DecryptAES(key, envelope.EncryptedPayload, envelope.Nonce, []byte("foobar"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wrapInfo api.SecretWrapInfo
+	if err := json.Unmarshal(plaintext, &wrapInfo); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if wrapInfo.TTL != 300 {
+		t.Fatalf("bad: %#v", wrapInfo)
+	}
+
+	unwrapConfig := api.DefaultConfig()
+	unwrapConfig.Address = addr
+	unwrapClient, err := api.NewClient(unwrapConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapClient.SetToken(wrapInfo.Token)
+	secret, err := unwrapClient.Logical().Unwrap("")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if secret == nil || secret.Data["token"] != root {
+		t.Fatalf("bad: %#v", secret)
+	}
+}
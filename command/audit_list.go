@@ -15,11 +15,16 @@ type AuditListCommand struct {
 }
 
 func (c *AuditListCommand) Run(args []string) int {
+	var format string
 	flags := c.Meta.FlagSet("audit-list", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
+	if !validateFormat(c.Ui, format) {
+		return 1
+	}
 
 	client, err := c.Client()
 	if err != nil {
@@ -35,6 +40,10 @@ func (c *AuditListCommand) Run(args []string) int {
 		return 2
 	}
 
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, audits)
+	}
+
 	if len(audits) == 0 {
 		c.Ui.Error(fmt.Sprintf(
 			"No audit backends are enabled. Use `vault audit-enable` to\n" +
@@ -82,6 +91,11 @@ Usage: vault audit-list [options]
   only a root Vault user can view this.
 
 General Options:
-` + meta.GeneralOptionsUsage()
+` + meta.GeneralOptionsUsage() + `
+Audit List Options:
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+`
 	return strings.TrimSpace(helpText)
 }
@@ -36,9 +36,10 @@ type AuthCommand struct {
 }
 
 func (c *AuthCommand) Run(args []string) int {
-	var method, authPath string
+	var method, authPath, format string
 	var methods, methodHelp, noVerify bool
 	flags := c.Meta.FlagSet("auth", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
 	flags.BoolVar(&methods, "methods", false, "")
 	flags.BoolVar(&methodHelp, "method-help", false, "")
 	flags.BoolVar(&noVerify, "no-verify", false, "")
@@ -50,7 +51,10 @@ func (c *AuthCommand) Run(args []string) int {
 	}
 
 	if methods {
-		return c.listMethods()
+		if !validateFormat(c.Ui, format) {
+			return 1
+		}
+		return c.listMethods(format)
 	}
 
 	args = flags.Args()
@@ -260,7 +264,7 @@ func (c *AuthCommand) Run(args []string) int {
 
 }
 
-func (c *AuthCommand) listMethods() int {
+func (c *AuthCommand) listMethods(format string) int {
 	client, err := c.Client()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
@@ -275,6 +279,10 @@ func (c *AuthCommand) listMethods() int {
 		return 1
 	}
 
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, auth)
+	}
+
 	paths := make([]string, 0, len(auth))
 	for path := range auth {
 		paths = append(paths, path)
@@ -344,6 +352,9 @@ General Options:
 
 Auth Options:
 
+  -format=table     The format for the output of -methods. By default it is a
+                    whitespace-delimited table. This can also be json or yaml.
+
   -method=name      Outputs help for the authentication method with the given
                     name for the remote server. If this authentication method
                     is not available, exit with code 1.
@@ -13,11 +13,16 @@ type CapabilitiesCommand struct {
 }
 
 func (c *CapabilitiesCommand) Run(args []string) int {
+	var format string
 	flags := c.Meta.FlagSet("capabilities", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
+	if !validateFormat(c.Ui, format) {
+		return 1
+	}
 
 	args = flags.Args()
 	if len(args) > 2 {
@@ -60,6 +65,10 @@ func (c *CapabilitiesCommand) Run(args []string) int {
 		return 1
 	}
 
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, capabilities)
+	}
+
 	c.Ui.Output(fmt.Sprintf("Capabilities: %s", capabilities))
 	return 0
 }
@@ -82,6 +91,11 @@ Usage: vault capabilities [options] [token] path
   is invalid, this command will respond with a ["deny"].
 
 General Options:
-` + meta.GeneralOptionsUsage()
+` + meta.GeneralOptionsUsage() + `
+Capabilities Options:
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+`
 	return strings.TrimSpace(helpText)
 }
@@ -18,6 +18,10 @@ const (
 	// ConfigPathEnv is the environment variable that can be used to
 	// override where the Vault configuration is.
 	ConfigPathEnv = "VAULT_CONFIG_PATH"
+
+	// ProfileEnv is the environment variable that selects the profile of
+	// the configuration to use.
+	ProfileEnv = "VAULT_PROFILE"
 )
 
 // Config is the CLI configuration for Vault that can be specified via
@@ -28,6 +32,36 @@ type DefaultConfig struct {
 	// is not specified, then vault's internal token store will be used, which
 	// stores the token on disk unencrypted.
 	TokenHelper string `hcl:"token_helper"`
+
+	// Profiles are named settings that override the ones above when
+	// selected with the VAULT_PROFILE environment variable.
+	Profiles map[string]*Profile `hcl:"-"`
+}
+
+// Profile is a named set of settings of the CLI configuration.
+type Profile struct {
+	// TokenHelper is the token helper of the profile. If this is not
+	// specified, the token helper of the configuration is used.
+	TokenHelper string `hcl:"token_helper"`
+}
+
+// TokenHelperPath returns the token helper configured for the given
+// profile, or the default token helper if the profile is empty. An empty
+// path means the internal token store is used.
+func (c *DefaultConfig) TokenHelperPath(profile string) (string, error) {
+	if profile == "" {
+		return c.TokenHelper, nil
+	}
+
+	p, ok := c.Profiles[profile]
+	if !ok {
+		return "", fmt.Errorf("Unknown profile %q", profile)
+	}
+	if p.TokenHelper != "" {
+		return p.TokenHelper, nil
+	}
+
+	return c.TokenHelper, nil
 }
 
 // Config loads the configuration and returns it. If the configuration
@@ -82,6 +116,7 @@ func ParseConfig(contents string) (*DefaultConfig, error) {
 
 	valid := []string{
 		"token_helper",
+		"profile",
 	}
 	if err := checkHCLKeys(list, valid); err != nil {
 		return nil, err
@@ -91,9 +126,45 @@ func ParseConfig(contents string) (*DefaultConfig, error) {
 	if err := hcl.DecodeObject(&c, list); err != nil {
 		return nil, err
 	}
+
+	if o := list.Filter("profile"); len(o.Items) > 0 {
+		if err := parseProfiles(&c, o); err != nil {
+			return nil, fmt.Errorf("Error parsing 'profile': %s", err)
+		}
+	}
+
 	return &c, nil
 }
 
+func parseProfiles(result *DefaultConfig, list *ast.ObjectList) error {
+	profiles := make(map[string]*Profile, len(list.Items))
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			return fmt.Errorf("profile name must be specified")
+		}
+		name := item.Keys[0].Token.Value().(string)
+		if _, ok := profiles[name]; ok {
+			return fmt.Errorf("profile %q is defined more than once", name)
+		}
+
+		valid := []string{
+			"token_helper",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("profile.%s:", name))
+		}
+
+		var p Profile
+		if err := hcl.DecodeObject(&p, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("profile.%s:", name))
+		}
+		profiles[name] = &p
+	}
+
+	result.Profiles = profiles
+	return nil
+}
+
 func checkHCLKeys(node ast.Node, valid []string) error {
 	var list *ast.ObjectList
 	switch n := node.(type) {
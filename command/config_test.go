@@ -47,3 +47,55 @@ nope = "true"
 		t.Errorf("bad error: %s", err.Error())
 	}
 }
+
+func TestLoadConfig_profiles(t *testing.T) {
+	config, err := LoadConfig(filepath.Join(FixturePath, "config-profiles.hcl"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &DefaultConfig{
+		TokenHelper: "foo",
+		Profiles: map[string]*Profile{
+			"work": &Profile{TokenHelper: "bar"},
+			"dev":  &Profile{},
+		},
+	}
+	if !reflect.DeepEqual(expected, config) {
+		t.Fatalf("bad: %#v", config)
+	}
+
+	cases := map[string]string{
+		"":     "foo",
+		"work": "bar",
+		"dev":  "foo",
+	}
+	for profile, expected := range cases {
+		path, err := config.TokenHelperPath(profile)
+		if err != nil {
+			t.Fatalf("%s: err: %s", profile, err)
+		}
+		if path != expected {
+			t.Fatalf("%s: bad: %s", profile, path)
+		}
+	}
+
+	if _, err := config.TokenHelperPath("nope"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseConfig_badProfile(t *testing.T) {
+	_, err := ParseConfig(`
+profile "work" {
+  nope = "true"
+}
+`)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !strings.Contains(err.Error(), "invalid key 'nope' on line 3") {
+		t.Errorf("bad error: %s", err.Error())
+	}
+}
@@ -25,6 +25,28 @@ func OutputList(ui cli.Ui, format string, secret *api.Secret) int {
 	return outputWithFormat(ui, format, secret, secret.Data["keys"])
 }
 
+// OutputData outputs a value which is not a secret, such as the response
+// of a sys endpoint, in the json or yaml format. The commands render the
+// table format of such values themselves.
+func OutputData(ui cli.Ui, format string, data interface{}) int {
+	return outputWithFormat(ui, format, nil, data)
+}
+
+// isTableFormat returns whether the format is the table format
+func isTableFormat(format string) bool {
+	return strings.ToLower(format) == "table"
+}
+
+// validateFormat checks that the format is known, so that the commands
+// rendering the table format themselves reject unknown formats upfront
+func validateFormat(ui cli.Ui, format string) bool {
+	if _, ok := Formatters[strings.ToLower(format)]; !ok {
+		ui.Error(fmt.Sprintf("Invalid output format: %s", format))
+		return false
+	}
+	return true
+}
+
 func outputWithFormat(ui cli.Ui, format string, secret *api.Secret, data interface{}) int {
 	formatter, ok := Formatters[strings.ToLower(format)]
 	if !ok {
@@ -181,6 +203,9 @@ func (t TableFormatter) OutputSecret(ui cli.Ui, secret, s *api.Secret) error {
 		input = append(input, fmt.Sprintf("wrapping_token: %s %s", config.Delim, s.WrapInfo.Token))
 		input = append(input, fmt.Sprintf("wrapping_token_ttl: %s %s", config.Delim, (time.Second*time.Duration(s.WrapInfo.TTL)).String()))
 		input = append(input, fmt.Sprintf("wrapping_token_creation_time: %s %s", config.Delim, s.WrapInfo.CreationTime.String()))
+		if s.WrapInfo.CreationPath != "" {
+			input = append(input, fmt.Sprintf("wrapping_token_creation_path: %s %s", config.Delim, s.WrapInfo.CreationPath))
+		}
 		if s.WrapInfo.WrappedAccessor != "" {
 			input = append(input, fmt.Sprintf("wrapped_accessor: %s %s", config.Delim, s.WrapInfo.WrappedAccessor))
 		}
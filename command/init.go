@@ -322,9 +322,9 @@ Init Options:
   -key-threshold=3          The number of key shares required to reconstruct
                             the master key.
 
-  -stored-shares=0          The number of unseal keys to store. Only used with 
-                            Vault HSM. Must currently be equivalent to the
-                            number of shares.
+  -stored-shares=0          The number of unseal keys to store. Only used with
+                            Vault HSM or a seal. Must currently be equivalent
+                            to the number of shares.
 
   -pgp-keys                 If provided, must be a comma-separated list of
                             files on disk containing binary- or base64-format
@@ -348,13 +348,15 @@ Init Options:
                             to base64-decode and decrypt the result.
 
   -recovery-shares=5        The number of key shares to split the recovery key
-                            into. Only used with Vault HSM.
+                            into. Only used with Vault HSM or a seal.
 
   -recovery-threshold=3     The number of key shares required to reconstruct
-                            the recovery key. Only used with Vault HSM.
+                            the recovery key. Only used with Vault HSM or a
+                            seal.
 
   -recovery-pgp-keys        If provided, behaves like "pgp-keys" but for the
-                            recovery key shares. Only used with Vault HSM.
+                            recovery key shares. Only used with Vault HSM or a
+                            seal.
 
   -auto                     If set, performs service discovery using Consul. 
                             When all the nodes of a Vault cluster are
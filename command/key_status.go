@@ -13,11 +13,16 @@ type KeyStatusCommand struct {
 }
 
 func (c *KeyStatusCommand) Run(args []string) int {
+	var format string
 	flags := c.Meta.FlagSet("key-status", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
+	if !validateFormat(c.Ui, format) {
+		return 1
+	}
 
 	client, err := c.Client()
 	if err != nil {
@@ -33,8 +38,16 @@ func (c *KeyStatusCommand) Run(args []string) int {
 		return 2
 	}
 
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, status)
+	}
+
 	c.Ui.Output(fmt.Sprintf("Key Term: %d", status.Term))
 	c.Ui.Output(fmt.Sprintf("Installation Time: %v", status.InstallTime))
+	c.Ui.Output(fmt.Sprintf("Encryptions: %d", status.Encryptions))
+	if !status.NextRotationTime.IsZero() {
+		c.Ui.Output(fmt.Sprintf("Estimated Next Rotation: %v", status.NextRotationTime))
+	}
 	return 0
 }
 
@@ -47,9 +60,16 @@ func (c *KeyStatusCommand) Help() string {
 Usage: vault key-status [options]
 
   Provides information about the active encryption key. Specifically,
-  the current key term and the key installation time.
+  the current key term, the key installation time, the number of values
+  encrypted with the key and the estimated time of its next automatic
+  rotation, which is configured at sys/rotate/config.
 
 General Options:
-` + meta.GeneralOptionsUsage()
+` + meta.GeneralOptionsUsage() + `
+Key Status Options:
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+`
 	return strings.TrimSpace(helpText)
 }
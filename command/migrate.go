@@ -0,0 +1,446 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/physical"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	// migrationCheckpointPath is where the progress of a migration is stored
+	// in the destination, so that an interrupted migration can be resumed
+	migrationCheckpointPath = "core/migration"
+
+	// migrationCoreLockPath is the HA lock of the Vault servers, held on the
+	// source during the migration so that no server can become active
+	migrationCoreLockPath = "core/lock"
+
+	// migrationKeyringPath is the barrier keyring, whose presence means that
+	// a storage holds an initialized Vault
+	migrationKeyringPath = "core/keyring"
+
+	// migrationBatchSize is the number of keys copied between checkpoints
+	migrationBatchSize = 1000
+)
+
+// MigrateCommand is a Command that copies the data of a Vault from one
+// storage backend to another.
+type MigrateCommand struct {
+	meta.Meta
+
+	// ShutdownCh interrupts the migration once its current batch is copied
+	ShutdownCh chan struct{}
+}
+
+// migrateConfig is the configuration of a storage migration
+type migrateConfig struct {
+	Source      *migrateStorage
+	Destination *migrateStorage
+}
+
+// migrateStorage is a storage backend of a migration
+type migrateStorage struct {
+	Type   string
+	Config map[string]string
+}
+
+// migrationCheckpoint tracks the progress of a migration. Keys are copied
+// in lexical order, so every key up to LastKey has been copied.
+type migrationCheckpoint struct {
+	StartTime time.Time `json:"start_time"`
+	LastKey   string    `json:"last_key"`
+	Copied    int       `json:"copied"`
+}
+
+func (c *MigrateCommand) Run(args []string) int {
+	var configPath, logLevel string
+	var parallel int
+	var reset bool
+	flags := c.Meta.FlagSet("migrate", meta.FlagSetNone)
+	flags.StringVar(&configPath, "config", "", "")
+	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.IntVar(&parallel, "parallel", 10, "")
+	flags.BoolVar(&reset, "reset", false, "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if configPath == "" {
+		c.Ui.Error("A migration configuration file must be specified with -config")
+		flags.Usage()
+		return 1
+	}
+	if parallel < 1 {
+		c.Ui.Error("The number of parallel workers must be at least 1")
+		return 1
+	}
+
+	config, err := loadMigrateConfig(configPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading migration configuration: %s", err))
+		return 1
+	}
+
+	var level int
+	switch strings.ToLower(strings.TrimSpace(logLevel)) {
+	case "trace":
+		level = log.LevelTrace
+	case "debug":
+		level = log.LevelDebug
+	case "info":
+		level = log.LevelInfo
+	case "warn":
+		level = log.LevelWarn
+	case "err":
+		level = log.LevelError
+	default:
+		c.Ui.Error(fmt.Sprintf("Unknown log level %s", logLevel))
+		return 1
+	}
+	logger := logformat.NewVaultLogger(level)
+
+	source, err := physical.NewBackend(config.Source.Type, logger, config.Source.Config)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing source storage of type %s: %s", config.Source.Type, err))
+		return 1
+	}
+	destination, err := physical.NewBackend(config.Destination.Type, logger, config.Destination.Config)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing destination storage of type %s: %s", config.Destination.Type, err))
+		return 1
+	}
+
+	if err := c.migrate(source, destination, parallel, reset); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error migrating storage: %s", err))
+		return 1
+	}
+	return 0
+}
+
+// migrate copies every key of the source to the destination, resuming from
+// the checkpoint of a previous migration unless reset is set
+func (c *MigrateCommand) migrate(source, destination physical.Backend, parallel int, reset bool) error {
+	// Hold the lock of the Vault servers so that none of them writes to the
+	// source while it is copied
+	if ha, ok := source.(physical.HABackend); ok && ha.HAEnabled() {
+		lock, err := ha.LockWith(migrationCoreLockPath, "migrate")
+		if err != nil {
+			return errwrap.Wrapf("failed to create lock on source: {{err}}", err)
+		}
+		held, _, err := lock.Value()
+		if err != nil {
+			return errwrap.Wrapf("failed to check lock on source: {{err}}", err)
+		}
+		if held {
+			return fmt.Errorf("source storage is in use by an active Vault server")
+		}
+		if _, err := lock.Lock(c.ShutdownCh); err != nil {
+			return errwrap.Wrapf("failed to acquire lock on source: {{err}}", err)
+		}
+		defer lock.Unlock()
+	}
+
+	checkpoint, err := readMigrationCheckpoint(destination)
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil || reset {
+		keyring, err := destination.Get(migrationKeyringPath)
+		if err != nil {
+			return errwrap.Wrapf("failed to check destination storage: {{err}}", err)
+		}
+		if keyring != nil && !reset {
+			return fmt.Errorf("destination storage already holds an initialized Vault")
+		}
+		checkpoint = &migrationCheckpoint{
+			StartTime: time.Now(),
+		}
+	} else {
+		c.Ui.Output(fmt.Sprintf(
+			"Resuming migration started at %s after key %q",
+			checkpoint.StartTime.Format(time.RFC3339), checkpoint.LastKey))
+	}
+
+	batch := make([]string, 0, migrationBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := copyMigrationKeys(source, destination, batch, parallel); err != nil {
+			return err
+		}
+		checkpoint.LastKey = batch[len(batch)-1]
+		checkpoint.Copied += len(batch)
+		if err := writeMigrationCheckpoint(destination, checkpoint); err != nil {
+			return err
+		}
+		c.Ui.Output(fmt.Sprintf("Copied %d keys (last key %q)", checkpoint.Copied, checkpoint.LastKey))
+		batch = batch[:0]
+
+		select {
+		case <-c.ShutdownCh:
+			return fmt.Errorf("migration interrupted, run the command again to resume it")
+		default:
+		}
+		return nil
+	}
+
+	err = walkMigrationKeys(source, "", checkpoint.LastKey, func(key string) error {
+		switch key {
+		case migrationCheckpointPath, migrationCoreLockPath:
+			return nil
+		}
+		batch = append(batch, key)
+		if len(batch) == migrationBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := destination.Delete(migrationCheckpointPath); err != nil {
+		return errwrap.Wrapf("failed to remove migration checkpoint: {{err}}", err)
+	}
+	c.Ui.Output(fmt.Sprintf("Success! Migrated %d keys.", checkpoint.Copied))
+	return nil
+}
+
+// walkMigrationKeys calls fn with every key under the prefix in lexical
+// order, skipping the keys up to and including after
+func walkMigrationKeys(b physical.Backend, prefix, after string, fn func(string) error) error {
+	keys, err := b.List(prefix)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed to list %q: {{err}}", prefix), err)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		key = prefix + key
+		if strings.HasSuffix(key, "/") {
+			// Every key of the folder sorts before after unless after is
+			// in the folder
+			if after != "" && key < after && !strings.HasPrefix(after, key) {
+				continue
+			}
+			if err := walkMigrationKeys(b, key, after, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if key <= after {
+			continue
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyMigrationKeys copies the keys from the source to the destination
+// using the given number of workers
+func copyMigrationKeys(source, destination physical.Backend, keys []string, parallel int) error {
+	keyCh := make(chan string)
+	var l sync.Mutex
+	var retErr *multierror.Error
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keyCh {
+				if err := copyMigrationKey(source, destination, key); err != nil {
+					l.Lock()
+					retErr = multierror.Append(retErr, err)
+					l.Unlock()
+				}
+			}
+		}()
+	}
+	for _, key := range keys {
+		keyCh <- key
+	}
+	close(keyCh)
+	wg.Wait()
+	return retErr.ErrorOrNil()
+}
+
+// copyMigrationKey copies a key from the source to the destination
+func copyMigrationKey(source, destination physical.Backend, key string) error {
+	entry, err := source.Get(key)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed to read %q: {{err}}", key), err)
+	}
+	// The key was removed since it was listed
+	if entry == nil {
+		return nil
+	}
+	if err := destination.Put(entry); err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed to write %q: {{err}}", key), err)
+	}
+	return nil
+}
+
+// readMigrationCheckpoint returns the checkpoint of a previous migration to
+// the destination, or nil if there is none
+func readMigrationCheckpoint(destination physical.Backend) (*migrationCheckpoint, error) {
+	entry, err := destination.Get(migrationCheckpointPath)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read migration checkpoint: {{err}}", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	var checkpoint migrationCheckpoint
+	if err := json.Unmarshal(entry.Value, &checkpoint); err != nil {
+		return nil, errwrap.Wrapf("failed to decode migration checkpoint: {{err}}", err)
+	}
+	return &checkpoint, nil
+}
+
+// writeMigrationCheckpoint persists the progress of the migration in the
+// destination
+func writeMigrationCheckpoint(destination physical.Backend, checkpoint *migrationCheckpoint) error {
+	value, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errwrap.Wrapf("failed to encode migration checkpoint: {{err}}", err)
+	}
+	if err := destination.Put(&physical.Entry{
+		Key:   migrationCheckpointPath,
+		Value: value,
+	}); err != nil {
+		return errwrap.Wrapf("failed to write migration checkpoint: {{err}}", err)
+	}
+	return nil
+}
+
+// loadMigrateConfig loads the migration configuration from the given file
+func loadMigrateConfig(path string) (*migrateConfig, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := hcl.Parse(string(d))
+	if err != nil {
+		return nil, err
+	}
+	list, ok := obj.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: file doesn't contain a root object")
+	}
+
+	var result migrateConfig
+	for _, name := range []string{"storage_source", "storage_destination"} {
+		o := list.Filter(name)
+		if len(o.Items) == 0 {
+			return nil, fmt.Errorf("missing %q block", name)
+		}
+		storage, err := parseMigrateStorage(o, name)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing '%s': %s", name, err)
+		}
+		if name == "storage_source" {
+			result.Source = storage
+		} else {
+			result.Destination = storage
+		}
+	}
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		switch key := item.Keys[0].Token.Value().(string); key {
+		case "storage_source", "storage_destination":
+		default:
+			return nil, fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return &result, nil
+}
+
+func parseMigrateStorage(list *ast.ObjectList, name string) (*migrateStorage, error) {
+	if len(list.Items) > 1 {
+		return nil, fmt.Errorf("only one %q block is permitted", name)
+	}
+
+	item := list.Items[0]
+	if len(item.Keys) == 0 {
+		return nil, fmt.Errorf("%q block requires a storage type", name)
+	}
+	key := item.Keys[0].Token.Value().(string)
+
+	var m map[string]string
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return nil, multierror.Prefix(err, fmt.Sprintf("%s.%s:", name, key))
+	}
+	return &migrateStorage{
+		Type:   strings.ToLower(key),
+		Config: m,
+	}, nil
+}
+
+func (c *MigrateCommand) Synopsis() string {
+	return "Migrate the data of Vault to another storage backend"
+}
+
+func (c *MigrateCommand) Help() string {
+	helpText := `
+Usage: vault migrate [options]
+
+  Copy all the data of Vault from one storage backend to another.
+
+  The storage backends are read from a configuration file with a
+  "storage_source" and a "storage_destination" block, which take the same
+  parameters as the "storage" block of the server configuration:
+
+      storage_source "file" {
+        path = "/var/lib/vault"
+      }
+
+      storage_destination "consul" {
+        address = "127.0.0.1:8500"
+        path    = "vault"
+      }
+
+  Vault must not be running while its data is migrated. If the source
+  supports high availability, the migration holds the lock of the Vault
+  servers, and fails if a server is active.
+
+  The progress is recorded in the destination after every batch of keys, and
+  an interrupted migration resumes where it stopped when it is run again.
+  The destination must not hold an initialized Vault unless -reset is given.
+
+Migrate Options:
+
+  -config=<path>          Path to the migration configuration file.
+
+  -parallel=<count>       Number of keys copied concurrently. Defaults to 10.
+
+  -reset                  Discard the progress of a previous migration and
+                          copy every key again, overwriting the data of the
+                          destination.
+
+  -log-level=<level>      Log verbosity of the storage backends. Defaults to
+                          "info".
+`
+	return strings.TrimSpace(helpText)
+}
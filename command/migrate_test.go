@@ -0,0 +1,134 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/physical"
+	log "github.com/mgutz/logxi/v1"
+	"github.com/mitchellh/cli"
+)
+
+func testMigrateConfig(t *testing.T) (string, physical.Backend, physical.Backend, func()) {
+	dir, err := ioutil.TempDir("", "vault-migrate")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := fmt.Sprintf(`
+storage_source "file" {
+  path = "%s"
+}
+
+storage_destination "file" {
+  path = "%s"
+}
+`, filepath.Join(dir, "source"), filepath.Join(dir, "destination"))
+	configPath := filepath.Join(dir, "migrate.hcl")
+	if err := ioutil.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	source, err := physical.NewBackend("file", logger, map[string]string{"path": filepath.Join(dir, "source")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	destination, err := physical.NewBackend("file", logger, map[string]string{"path": filepath.Join(dir, "destination")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return configPath, source, destination, func() { os.RemoveAll(dir) }
+}
+
+func TestMigrate(t *testing.T) {
+	configPath, source, destination, cleanup := testMigrateConfig(t)
+	defer cleanup()
+
+	var keys []string
+	for i := 0; i < migrationBatchSize+10; i++ {
+		keys = append(keys, fmt.Sprintf("logical/%d/%d", i%7, i))
+	}
+	keys = append(keys, "core/keyring", "core/master", "sys/token/id/foo")
+	for _, key := range keys {
+		if err := source.Put(&physical.Entry{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	ui := new(cli.MockUi)
+	c := &MigrateCommand{
+		Meta: meta.Meta{
+			Ui: ui,
+		},
+	}
+	if code := c.Run([]string{"-config", configPath, "-parallel", "4"}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	for _, key := range keys {
+		entry, err := destination.Get(key)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if entry == nil || string(entry.Value) != key {
+			t.Fatalf("bad entry for %q: %#v", key, entry)
+		}
+	}
+	if entry, _ := destination.Get(migrationCheckpointPath); entry != nil {
+		t.Fatalf("checkpoint not removed")
+	}
+
+	// The destination now holds an initialized Vault
+	ui = new(cli.MockUi)
+	c.Meta.Ui = ui
+	if code := c.Run([]string{"-config", configPath}); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if code := c.Run([]string{"-config", configPath, "-reset"}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestMigrate_resume(t *testing.T) {
+	configPath, source, destination, cleanup := testMigrateConfig(t)
+	defer cleanup()
+
+	keys := []string{"a", "b/a", "b/b", "b/c/a", "c"}
+	for _, key := range keys {
+		if err := source.Put(&physical.Entry{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// A previous migration copied the keys up to b/b
+	if err := writeMigrationCheckpoint(destination, &migrationCheckpoint{LastKey: "b/b", Copied: 3}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &MigrateCommand{
+		Meta: meta.Meta{
+			Ui: ui,
+		},
+	}
+	if code := c.Run([]string{"-config", configPath}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	for _, key := range keys {
+		entry, err := destination.Get(key)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		copied := key == "b/c/a" || key == "c"
+		if (entry != nil) != copied {
+			t.Fatalf("bad entry for %q: %#v", key, entry)
+		}
+	}
+}
@@ -15,7 +15,7 @@ type MountCommand struct {
 
 func (c *MountCommand) Run(args []string) int {
 	var description, path, defaultLeaseTTL, maxLeaseTTL string
-	var local, forceNoCache bool
+	var local, forceNoCache, sealWrap bool
 	flags := c.Meta.FlagSet("mount", meta.FlagSetDefault)
 	flags.StringVar(&description, "description", "", "")
 	flags.StringVar(&path, "path", "", "")
@@ -23,6 +23,7 @@ func (c *MountCommand) Run(args []string) int {
 	flags.StringVar(&maxLeaseTTL, "max-lease-ttl", "", "")
 	flags.BoolVar(&forceNoCache, "force-no-cache", false, "")
 	flags.BoolVar(&local, "local", false, "")
+	flags.BoolVar(&sealWrap, "seal-wrap", false, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -58,7 +59,8 @@ func (c *MountCommand) Run(args []string) int {
 			MaxLeaseTTL:     maxLeaseTTL,
 			ForceNoCache:    forceNoCache,
 		},
-		Local: local,
+		Local:    local,
+		SealWrap: sealWrap,
 	}
 
 	if err := client.Sys().Mount(path, mountInfo); err != nil {
@@ -116,6 +118,11 @@ Mount Options:
                                  are not replicated nor (if a secondary)
                                  removed by replication.
 
+  -seal-wrap                     Encrypt the sensitive values stored by the
+                                 backend, such as private keys, with the seal
+                                 in addition to the barrier. This requires an
+                                 auto-unseal seal.
+
 `
 	return strings.TrimSpace(helpText)
 }
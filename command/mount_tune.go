@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/flag-slice"
 	"github.com/hashicorp/vault/meta"
 )
 
@@ -16,9 +17,12 @@ type MountTuneCommand struct {
 
 func (c *MountTuneCommand) Run(args []string) int {
 	var defaultLeaseTTL, maxLeaseTTL string
+	var auditNonHMACRequestKeys, auditNonHMACResponseKeys []string
 	flags := c.Meta.FlagSet("mount-tune", meta.FlagSetDefault)
 	flags.StringVar(&defaultLeaseTTL, "default-lease-ttl", "", "")
 	flags.StringVar(&maxLeaseTTL, "max-lease-ttl", "", "")
+	flags.Var((*sliceflag.StringFlag)(&auditNonHMACRequestKeys), "audit-non-hmac-request-keys", "")
+	flags.Var((*sliceflag.StringFlag)(&auditNonHMACResponseKeys), "audit-non-hmac-response-keys", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -35,8 +39,10 @@ func (c *MountTuneCommand) Run(args []string) int {
 	path := args[0]
 
 	mountConfig := api.MountConfigInput{
-		DefaultLeaseTTL: defaultLeaseTTL,
-		MaxLeaseTTL:     maxLeaseTTL,
+		DefaultLeaseTTL:          defaultLeaseTTL,
+		MaxLeaseTTL:              maxLeaseTTL,
+		AuditNonHMACRequestKeys:  auditNonHMACRequestKeys,
+		AuditNonHMACResponseKeys: auditNonHMACResponseKeys,
 	}
 
 	client, err := c.Client()
@@ -84,6 +90,16 @@ Mount Options:
                                  the previously set value. Set to 'system' to
                                  explicitly set it to use the system default.
 
+  -audit-non-hmac-request-keys=<key>
+                                 Key of the request data the audit backends
+                                 log without hashing. This can be specified
+                                 multiple times.
+
+  -audit-non-hmac-response-keys=<key>
+                                 Key of the response data the audit backends
+                                 log without hashing. This can be specified
+                                 multiple times.
+
 `
 	return strings.TrimSpace(helpText)
 }
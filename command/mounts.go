@@ -16,11 +16,16 @@ type MountsCommand struct {
 }
 
 func (c *MountsCommand) Run(args []string) int {
+	var format string
 	flags := c.Meta.FlagSet("mounts", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
+	if !validateFormat(c.Ui, format) {
+		return 1
+	}
 
 	client, err := c.Client()
 	if err != nil {
@@ -36,6 +41,10 @@ func (c *MountsCommand) Run(args []string) int {
 		return 2
 	}
 
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, mounts)
+	}
+
 	paths := make([]string, 0, len(mounts))
 	for path := range mounts {
 		paths = append(paths, path)
@@ -91,6 +100,11 @@ Usage: vault mounts [options]
   A TTL of 'system' indicates that the system default is being used.
 
 General Options:
-` + meta.GeneralOptionsUsage()
+` + meta.GeneralOptionsUsage() + `
+Mounts Options:
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+`
 	return strings.TrimSpace(helpText)
 }
@@ -13,17 +13,22 @@ type PolicyListCommand struct {
 }
 
 func (c *PolicyListCommand) Run(args []string) int {
+	var format string
 	flags := c.Meta.FlagSet("policy-list", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
+	if !validateFormat(c.Ui, format) {
+		return 1
+	}
 
 	args = flags.Args()
 	if len(args) == 1 {
-		return c.read(args[0])
+		return c.read(args[0], format)
 	} else if len(args) == 0 {
-		return c.list()
+		return c.list(format)
 	} else {
 		flags.Usage()
 		c.Ui.Error(fmt.Sprintf(
@@ -32,7 +37,7 @@ func (c *PolicyListCommand) Run(args []string) int {
 	}
 }
 
-func (c *PolicyListCommand) list() int {
+func (c *PolicyListCommand) list(format string) int {
 	client, err := c.Client()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
@@ -47,6 +52,10 @@ func (c *PolicyListCommand) list() int {
 		return 1
 	}
 
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, policies)
+	}
+
 	for _, p := range policies {
 		c.Ui.Output(p)
 	}
@@ -54,7 +63,7 @@ func (c *PolicyListCommand) list() int {
 	return 0
 }
 
-func (c *PolicyListCommand) read(n string) int {
+func (c *PolicyListCommand) read(n, format string) int {
 	client, err := c.Client()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
@@ -69,6 +78,13 @@ func (c *PolicyListCommand) read(n string) int {
 		return 1
 	}
 
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, map[string]string{
+			"name":  n,
+			"rules": rules,
+		})
+	}
+
 	c.Ui.Output(rules)
 	return 0
 }
@@ -87,6 +103,12 @@ Usage: vault policies [options] [name]
   If a name of a policy is specified, that policy is outputted.
 
 General Options:
-` + meta.GeneralOptionsUsage()
+` + meta.GeneralOptionsUsage() + `
+Policies Options:
+
+  -format=table           The format for output. By default the policy names
+                          or the policy rules are output as they are. This can
+                          also be json or yaml.
+`
 	return strings.TrimSpace(helpText)
 }
@@ -90,7 +90,10 @@ Read Options:
                           delimited table. This can also be json or yaml.
 
   -field=field            If included, the raw value of the specified field
-                          will be output raw to stdout.
+                          will be output raw to stdout. Nested values are
+                          selected with dotted paths, such as
+                          data.nested.key, and output as JSON when they are
+                          objects or lists.
 
 `
 	return strings.TrimSpace(helpText)
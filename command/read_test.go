@@ -97,6 +97,56 @@ func TestRead_field(t *testing.T) {
 	}
 }
 
+func TestRead_field_nested(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	ui := new(cli.MockUi)
+	c := &ReadCommand{
+		Meta: meta.Meta{
+			ClientToken: token,
+			Ui:          ui,
+		},
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetAddress(addr)
+
+	data := map[string]interface{}{
+		"value": map[string]interface{}{
+			"nested": []interface{}{"a", "b"},
+		},
+	}
+	if _, err := client.Logical().Write("secret/foo", data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string]string{
+		"value.nested.1": "b\n",
+		"value.nested":   "[\"a\",\"b\"]\n",
+		"data.value":     "{\"nested\":[\"a\",\"b\"]}\n",
+	}
+	for field, expected := range cases {
+		ui := new(cli.MockUi)
+		c.Ui = ui
+		args := []string{
+			"-address", addr,
+			"-field", field,
+			"secret/foo",
+		}
+		if code := c.Run(args); code != 0 {
+			t.Fatalf("%s: bad: %d\n\n%s", field, code, ui.ErrorWriter.String())
+		}
+		if output := ui.OutputWriter.String(); output != expected {
+			t.Fatalf("%s: unexpected output:\n%s", field, output)
+		}
+	}
+}
+
 func TestRead_field_notFound(t *testing.T) {
 	core, _, token := vault.TestCoreUnsealed(t)
 	ln, addr := http.TestServer(t, core)
@@ -414,7 +414,7 @@ Rekey Options:
                           'sys/rekey/backup' endpoint.
 
   -recovery-key=false     Whether to rekey the recovery key instead of the
-                          barrier key. Only used with Vault HSM.
+                          barrier key. Only used with Vault HSM or a seal.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -29,15 +29,20 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/helper/entropy"
 	"github.com/hashicorp/vault/helper/flag-slice"
 	"github.com/hashicorp/vault/helper/gated-writer"
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/mlock"
 	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/meta"
 	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/vault"
+	sealpkg "github.com/hashicorp/vault/vault/seal"
+	"github.com/hashicorp/vault/vault/seal/awskms"
+	"github.com/hashicorp/vault/vault/seal/gcpckms"
 	"github.com/hashicorp/vault/version"
 )
 
@@ -187,7 +192,8 @@ func (c *ServerCommand) Run(args []string) int {
 		c.Ui.Output("  Vault on an mlockall(2) enabled system is much more secure.\n")
 	}
 
-	if err := c.setupTelemetry(config); err != nil {
+	metricsHelper, err := c.setupTelemetry(config)
+	if err != nil {
 		c.Ui.Output(fmt.Sprintf("Error initializing telemetry: %s", err))
 		return 1
 	}
@@ -205,13 +211,53 @@ func (c *ServerCommand) Run(args []string) int {
 	infoKeys := make([]string, 0, 10)
 	info := make(map[string]string)
 
+	// With a seal configured, a Vault sealed by Shamir keys is migrated to
+	// the seal; with the seal disabled, a Vault sealed by the seal is
+	// migrated back to Shamir keys
 	var seal vault.Seal = &vault.DefaultSeal{}
+	var unwrapSeal vault.Seal
+	var sealAccess sealpkg.Access
+	if config.Seal != nil {
+		autoSeal, access, err := configureSeal(config.Seal, c.logger)
+		if err != nil {
+			c.Ui.Output(fmt.Sprintf(
+				"Error initializing seal of type %s: %s",
+				config.Seal.Type, err))
+			return 1
+		}
+		if config.Seal.Disabled {
+			unwrapSeal = autoSeal
+			info["seal"] = fmt.Sprintf("shamir (migrating from %s)", config.Seal.Type)
+		} else {
+			seal = autoSeal
+			unwrapSeal = &vault.DefaultSeal{}
+			sealAccess = access
+			info["seal"] = config.Seal.Type
+		}
+		infoKeys = append(infoKeys, "seal")
+	}
+
+	// The keys generated by the Vault may be augmented with randomness from
+	// the key management service of the seal
+	var entropySource entropy.Sourcer
+	if config.Entropy != nil {
+		sourcer, ok := sealAccess.(entropy.Sourcer)
+		if !ok {
+			c.Ui.Output("Entropy augmentation requires an enabled seal able to generate random bytes")
+			return 1
+		}
+		entropySource = sourcer
+		info["entropy"] = fmt.Sprintf("%s (%s)", config.Entropy.Type, config.Entropy.Mode)
+		infoKeys = append(infoKeys, "entropy")
+	}
 
 	// Ensure that the seal finalizer is called, even if using verify-only
 	defer func() {
-		if seal != nil {
-			err = seal.Finalize()
-			if err != nil {
+		for _, s := range []vault.Seal{seal, unwrapSeal} {
+			if s == nil {
+				continue
+			}
+			if err := s.Finalize(); err != nil {
 				c.Ui.Error(fmt.Sprintf("Error finalizing seals: %v", err))
 			}
 		}
@@ -227,6 +273,8 @@ func (c *ServerCommand) Run(args []string) int {
 		RedirectAddr:       config.Storage.RedirectAddr,
 		HAPhysical:         nil,
 		Seal:               seal,
+		UnwrapSeal:         unwrapSeal,
+		EntropySource:      entropySource,
 		AuditBackends:      c.AuditBackends,
 		CredentialBackends: c.CredentialBackends,
 		LogicalBackends:    c.LogicalBackends,
@@ -237,6 +285,7 @@ func (c *ServerCommand) Run(args []string) int {
 		DefaultLeaseTTL:    config.DefaultLeaseTTL,
 		ClusterName:        config.ClusterName,
 		CacheSize:          config.CacheSize,
+		MetricsHelper:      metricsHelper,
 	}
 	if dev {
 		coreConfig.DevToken = devRootTokenID
@@ -413,6 +462,9 @@ CLUSTER_SYNTHESIS_COMPLETE:
 	// Initialize the listeners
 	c.reloadFuncsLock.Lock()
 	lns := make([]net.Listener, 0, len(config.Listeners))
+	lnForwardedFor := make([]*vaulthttp.ForwardedForConfig, 0, len(config.Listeners))
+	lnLimits := make([]*vaulthttp.RequestLimits, 0, len(config.Listeners))
+	lnUnauthenticatedMetrics := make([]bool, 0, len(config.Listeners))
 	for i, lnConfig := range config.Listeners {
 		if lnConfig.Type == "atlas" {
 			if config.ClusterName == "" {
@@ -431,7 +483,42 @@ CLUSTER_SYNTHESIS_COMPLETE:
 			return 1
 		}
 
+		forwardedFor, err := server.ParseForwardedFor(lnConfig.Config)
+		if err != nil {
+			ln.Close()
+			c.Ui.Output(fmt.Sprintf(
+				"Error configuring listener of type %s: %s",
+				lnConfig.Type, err))
+			return 1
+		}
+		if forwardedFor != nil {
+			props["x_forwarded_for_authorized_addrs"] = lnConfig.Config["x_forwarded_for_authorized_addrs"]
+		}
+		limits, err := server.ParseRequestLimits(lnConfig.Config)
+		if err != nil {
+			ln.Close()
+			c.Ui.Output(fmt.Sprintf(
+				"Error configuring listener of type %s: %s",
+				lnConfig.Type, err))
+			return 1
+		}
+		var unauthenticatedMetrics bool
+		if v, ok := lnConfig.Config["unauthenticated_metrics_access"]; ok {
+			unauthenticatedMetrics, err = strconv.ParseBool(v)
+			if err != nil {
+				ln.Close()
+				c.Ui.Output(fmt.Sprintf(
+					"Error configuring listener of type %s: invalid value for 'unauthenticated_metrics_access': %q",
+					lnConfig.Type, v))
+				return 1
+			}
+			props["unauthenticated_metrics_access"] = v
+		}
+
 		lns = append(lns, ln)
+		lnForwardedFor = append(lnForwardedFor, forwardedFor)
+		lnLimits = append(lnLimits, limits)
+		lnUnauthenticatedMetrics = append(lnUnauthenticatedMetrics, unauthenticatedMetrics)
 
 		if reloadFunc != nil {
 			relSlice := (*c.reloadFuncs)["listener|"+lnConfig.Type]
@@ -597,14 +684,25 @@ CLUSTER_SYNTHESIS_COMPLETE:
 		))
 	}
 
-	// Initialize the HTTP server
-	server := &http.Server{}
-	if err := http2.ConfigureServer(server, nil); err != nil {
-		c.Ui.Output(fmt.Sprintf("Error configuring server for HTTP/2: %s", err))
-		return 1
-	}
-	server.Handler = handler
-	for _, ln := range lns {
+	// Initialize the HTTP servers. The listeners trusting proxies, with their
+	// own request limits or serving the metrics without authentication get
+	// their own handler.
+	for i, ln := range lns {
+		server := &http.Server{}
+		if err := http2.ConfigureServer(server, nil); err != nil {
+			c.Ui.Output(fmt.Sprintf("Error configuring server for HTTP/2: %s", err))
+			return 1
+		}
+		server.Handler = handler
+		if lnForwardedFor[i] != nil {
+			server.Handler = vaulthttp.WrapForwardedForHandler(server.Handler, lnForwardedFor[i])
+		}
+		if lnUnauthenticatedMetrics[i] {
+			server.Handler = vaulthttp.WrapUnauthenticatedMetricsHandler(server.Handler, core)
+		}
+		if lnLimits[i] != nil {
+			server.Handler = vaulthttp.WrapRequestLimitsHandler(server.Handler, lnLimits[i])
+		}
 		go server.Serve(ln)
 	}
 
@@ -746,6 +844,35 @@ func (c *ServerCommand) enableDev(core *vault.Core, rootTokenID string) (*vault.
 	return init, nil
 }
 
+// configureSeal creates the auto-unseal seal of the given configuration,
+// and checks that its key is usable. The key management service of the seal
+// is returned along with it.
+func configureSeal(config *server.Seal, logger log.Logger) (vault.Seal, sealpkg.Access, error) {
+	var access sealpkg.Access
+	switch config.Type {
+	case "awskms":
+		kms, err := awskms.NewSeal(config.Config, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		access = kms
+	case "gcpckms":
+		kms, err := gcpckms.NewSeal(config.Config, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		access = kms
+	default:
+		return nil, nil, fmt.Errorf("unknown seal type %q", config.Type)
+	}
+
+	seal := vault.NewAutoSeal(access)
+	if err := seal.Init(); err != nil {
+		return nil, nil, err
+	}
+	return seal, access, nil
+}
+
 // detectRedirect is used to attempt redirect address detection
 func (c *ServerCommand) detectRedirect(detect physical.RedirectDetect,
 	config *server.Config) (string, error) {
@@ -816,8 +943,9 @@ func (c *ServerCommand) detectRedirect(detect physical.RedirectDetect,
 	return url.String(), nil
 }
 
-// setupTelemetry is used to setup the telemetry sub-systems
-func (c *ServerCommand) setupTelemetry(config *server.Config) error {
+// setupTelemetry is used to setup the telemetry sub-systems, returning the
+// helper reading back the metrics
+func (c *ServerCommand) setupTelemetry(config *server.Config) (*metricsutil.MetricsHelper, error) {
 	/* Setup telemetry
 	Aggregate on 10 second intervals for 1 minute. Expose the
 	metrics over stderr when there is a SIGUSR1 received.
@@ -840,7 +968,7 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 	if telConfig.StatsiteAddr != "" {
 		sink, err := metrics.NewStatsiteSink(telConfig.StatsiteAddr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fanout = append(fanout, sink)
 	}
@@ -849,7 +977,7 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 	if telConfig.StatsdAddr != "" {
 		sink, err := metrics.NewStatsdSink(telConfig.StatsdAddr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fanout = append(fanout, sink)
 	}
@@ -885,21 +1013,34 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 
 		sink, err := circonus.NewCirconusSink(cfg)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		sink.Start()
 		fanout = append(fanout, sink)
 	}
 
-	// Initialize the global sink
-	if len(fanout) > 0 {
-		fanout = append(fanout, inm)
-		metrics.NewGlobal(metricsConf, fanout)
-	} else {
+	// Configure the Prometheus sink, read back on sys/metrics
+	prometheusRetention := metricsutil.DefaultPrometheusRetention
+	if telConfig.PrometheusRetentionTimeRaw != nil {
+		prometheusRetention = telConfig.PrometheusRetentionTime
+	}
+	var prometheus *metricsutil.PrometheusSink
+	if prometheusRetention != 0 {
+		prometheus = metricsutil.NewPrometheusSink(prometheusRetention)
+	}
+
+	// Initialize the global sink. The host name is only added for the
+	// external sinks.
+	if len(fanout) == 0 {
 		metricsConf.EnableHostname = false
-		metrics.NewGlobal(metricsConf, inm)
 	}
-	return nil
+	fanout = append(fanout, inm)
+	if prometheus != nil {
+		fanout = append(fanout, prometheus)
+	}
+	metrics.NewGlobal(metricsConf, fanout)
+
+	return metricsutil.NewMetricsHelper(inm, prometheus), nil
 }
 
 func (c *ServerCommand) Reload(configPath []string) error {
@@ -942,15 +1083,16 @@ func (c *ServerCommand) Reload(configPath []string) error {
 	}
 
 audit:
-	// file audit reload funcs
+	// audit reload funcs, keyed by "audit_<type>|<path>"
 	for k, relFuncs := range *c.reloadFuncs {
-		if !strings.HasPrefix(k, "audit_file|") {
+		if !strings.HasPrefix(k, "audit_") {
 			continue
 		}
+		backend := strings.SplitN(strings.TrimPrefix(k, "audit_"), "|", 2)
 		for _, relFunc := range relFuncs {
 			if relFunc != nil {
 				if err := relFunc(nil); err != nil {
-					reloadErrors = multierror.Append(reloadErrors, fmt.Errorf("Error encountered reloading file audit backend at path %s: %v", strings.TrimPrefix(k, "audit_file|"), err))
+					reloadErrors = multierror.Append(reloadErrors, fmt.Errorf("Error encountered reloading %s audit backend at path %s: %v", backend[0], backend[len(backend)-1], err))
 				}
 			}
 		}
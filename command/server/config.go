@@ -24,7 +24,9 @@ type Config struct {
 	Storage   *Storage    `hcl:"-"`
 	HAStorage *Storage    `hcl:"-"`
 
-	HSM *HSM `hcl:"-"`
+	HSM     *HSM     `hcl:"-"`
+	Seal    *Seal    `hcl:"-"`
+	Entropy *Entropy `hcl:"-"`
 
 	CacheSize       int         `hcl:"cache_size"`
 	DisableCache    bool        `hcl:"-"`
@@ -118,6 +120,33 @@ func (h *HSM) GoString() string {
 	return fmt.Sprintf("*%#v", *h)
 }
 
+// Seal contains the auto-unseal seal configuration for the server. A
+// disabled seal is only used to migrate the Vault back to Shamir keys.
+type Seal struct {
+	Type     string
+	Disabled bool
+	Config   map[string]string
+}
+
+func (s *Seal) GoString() string {
+	return fmt.Sprintf("*%#v", *s)
+}
+
+// EntropyModeAugmentation mixes the randomness of the entropy source with
+// the randomness of the host
+const EntropyModeAugmentation = "augmentation"
+
+// Entropy configures an external source of randomness for the keys
+// generated by the server. The only source is the seal.
+type Entropy struct {
+	Type string
+	Mode string
+}
+
+func (e *Entropy) GoString() string {
+	return fmt.Sprintf("*%#v", *e)
+}
+
 // Telemetry is the telemetry configuration for the server
 type Telemetry struct {
 	StatsiteAddr string `hcl:"statsite_address"`
@@ -125,6 +154,13 @@ type Telemetry struct {
 
 	DisableHostname bool `hcl:"disable_hostname"`
 
+	// PrometheusRetentionTime is how long the metrics no longer updated are
+	// exposed in the Prometheus format on sys/metrics. Zero disables the
+	// Prometheus format.
+	// Default: 24h
+	PrometheusRetentionTime    time.Duration `hcl:"-"`
+	PrometheusRetentionTimeRaw interface{}   `hcl:"prometheus_retention_time"`
+
 	// Circonus: see https://github.com/circonus-labs/circonus-gometrics
 	// for more details on the various configuration options.
 	// Valid configuration combinations:
@@ -230,6 +266,16 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.HSM = c2.HSM
 	}
 
+	result.Seal = c.Seal
+	if c2.Seal != nil {
+		result.Seal = c2.Seal
+	}
+
+	result.Entropy = c.Entropy
+	if c2.Entropy != nil {
+		result.Entropy = c2.Entropy
+	}
+
 	result.Telemetry = c.Telemetry
 	if c2.Telemetry != nil {
 		result.Telemetry = c2.Telemetry
@@ -354,6 +400,8 @@ func ParseConfig(d string, logger log.Logger) (*Config, error) {
 		"backend",
 		"ha_backend",
 		"hsm",
+		"seal",
+		"entropy",
 		"listener",
 		"cache_size",
 		"disable_cache",
@@ -399,6 +447,18 @@ func ParseConfig(d string, logger log.Logger) (*Config, error) {
 		}
 	}
 
+	if o := list.Filter("seal"); len(o.Items) > 0 {
+		if err := parseSeal(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'seal': %s", err)
+		}
+	}
+
+	if o := list.Filter("entropy"); len(o.Items) > 0 {
+		if err := parseEntropy(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'entropy': %s", err)
+		}
+	}
+
 	if o := list.Filter("listener"); len(o.Items) > 0 {
 		if err := parseListeners(&result, o); err != nil {
 			return nil, fmt.Errorf("error parsing 'listener': %s", err)
@@ -640,6 +700,108 @@ func parseHSMs(result *Config, list *ast.ObjectList) error {
 	return nil
 }
 
+func parseSeal(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'seal' block is permitted")
+	}
+
+	// Get our item
+	item := list.Items[0]
+
+	if len(item.Keys) == 0 {
+		return fmt.Errorf("seal type must be specified")
+	}
+	key := item.Keys[0].Token.Value().(string)
+
+	var valid []string
+	switch strings.ToLower(key) {
+	case "awskms":
+		valid = []string{
+			"region",
+			"access_key",
+			"secret_key",
+			"session_token",
+			"kms_key_id",
+			"endpoint",
+			"disabled",
+		}
+	case "gcpckms":
+		valid = []string{
+			"credentials",
+			"project",
+			"region",
+			"key_ring",
+			"crypto_key",
+			"disabled",
+		}
+	default:
+		return fmt.Errorf("invalid seal type %q", key)
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("seal.%s:", key))
+	}
+
+	var m map[string]string
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("seal.%s:", key))
+	}
+
+	var disabled bool
+	if raw, ok := m["disabled"]; ok {
+		var err error
+		if disabled, err = parseutil.ParseBool(raw); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("seal.%s:", key))
+		}
+		delete(m, "disabled")
+	}
+
+	result.Seal = &Seal{
+		Type:     strings.ToLower(key),
+		Disabled: disabled,
+		Config:   m,
+	}
+
+	return nil
+}
+
+func parseEntropy(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'entropy' block is permitted")
+	}
+
+	// Get our item
+	item := list.Items[0]
+
+	if len(item.Keys) == 0 {
+		return fmt.Errorf("entropy source type must be specified")
+	}
+	key := strings.ToLower(item.Keys[0].Token.Value().(string))
+	if key != "seal" {
+		return fmt.Errorf("invalid entropy source type %q", key)
+	}
+
+	if err := checkHCLKeys(item.Val, []string{"mode"}); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("entropy.%s:", key))
+	}
+
+	var m map[string]string
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("entropy.%s:", key))
+	}
+
+	mode := strings.ToLower(m["mode"])
+	if mode != EntropyModeAugmentation {
+		return fmt.Errorf("entropy.%s: invalid mode %q, must be %q", key, m["mode"], EntropyModeAugmentation)
+	}
+
+	result.Entropy = &Entropy{
+		Type: key,
+		Mode: mode,
+	}
+
+	return nil
+}
+
 func parseListeners(result *Config, list *ast.ObjectList) error {
 	var foundAtlas bool
 
@@ -655,15 +817,27 @@ func parseListeners(result *Config, list *ast.ObjectList) error {
 			"cluster_address",
 			"endpoint",
 			"infrastructure",
+			"max_request_duration",
+			"max_request_size",
 			"node_id",
+			"socket_mode",
+			"socket_user",
+			"socket_group",
 			"tls_disable",
 			"tls_cert_file",
 			"tls_key_file",
+			"tls_sni_cert_files",
+			"tls_sni_key_files",
 			"tls_min_version",
 			"tls_cipher_suites",
 			"tls_prefer_server_cipher_suites",
 			"tls_require_and_verify_client_cert",
 			"token",
+			"unauthenticated_metrics_access",
+			"x_forwarded_for_authorized_addrs",
+			"x_forwarded_for_hop_skips",
+			"x_forwarded_for_reject_not_authorized",
+			"x_forwarded_for_reject_not_present",
 		}
 		if err := checkHCLKeys(item.Val, valid); err != nil {
 			return multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
@@ -727,6 +901,7 @@ func parseTelemetry(result *Config, list *ast.ObjectList) error {
 		"circonus_broker_id",
 		"circonus_broker_select_tag",
 		"disable_hostname",
+		"prometheus_retention_time",
 		"statsd_address",
 		"statsite_address",
 	}
@@ -746,6 +921,13 @@ func parseTelemetry(result *Config, list *ast.ObjectList) error {
 	if err := hcl.DecodeObject(&result.Telemetry, item.Val); err != nil {
 		return multierror.Prefix(err, "telemetry:")
 	}
+
+	if result.Telemetry.PrometheusRetentionTimeRaw != nil {
+		var err error
+		if result.Telemetry.PrometheusRetentionTime, err = parseutil.ParseDurationSecond(result.Telemetry.PrometheusRetentionTimeRaw); err != nil {
+			return multierror.Prefix(err, "telemetry.prometheus_retention_time:")
+		}
+	}
 	return nil
 }
 
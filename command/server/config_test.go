@@ -328,3 +328,123 @@ telemetry {
 		t.Errorf("bad error: %q", err)
 	}
 }
+
+func TestParseConfig_prometheusRetentionTime(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	config, err := ParseConfig(strings.TrimSpace(`
+telemetry {
+	prometheus_retention_time = "12h"
+}
+`), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Telemetry.PrometheusRetentionTime != 12*time.Hour {
+		t.Fatalf("bad: %s", config.Telemetry.PrometheusRetentionTime)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+telemetry {
+	prometheus_retention_time = "soon"
+}
+`), logger)
+	if err == nil || !strings.Contains(err.Error(), "telemetry.prometheus_retention_time:") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestParseConfig_seal(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	config, err := ParseConfig(strings.TrimSpace(`
+seal "awskms" {
+	region     = "us-east-1"
+	kms_key_id = "alias/vault"
+}
+`), logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Seal{
+		Type: "awskms",
+		Config: map[string]string{
+			"region":     "us-east-1",
+			"kms_key_id": "alias/vault",
+		},
+	}
+	if !reflect.DeepEqual(config.Seal, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config.Seal, expected)
+	}
+
+	config, err = ParseConfig(strings.TrimSpace(`
+seal "gcpckms" {
+	project  = "vault"
+	disabled = "true"
+}
+`), logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !config.Seal.Disabled || !reflect.DeepEqual(config.Seal.Config, map[string]string{"project": "vault"}) {
+		t.Fatalf("bad: %#v", config.Seal)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+seal "gcpckms" {
+	project = "vault"
+	bad     = "one"
+}
+`), logger)
+	if err == nil || !strings.Contains(err.Error(), "seal.gcpckms: invalid key 'bad' on line 3") {
+		t.Errorf("bad error: %v", err)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+seal "nope" {
+}
+`), logger)
+	if err == nil || !strings.Contains(err.Error(), `invalid seal type "nope"`) {
+		t.Errorf("bad error: %v", err)
+	}
+}
+
+func TestParseConfig_entropy(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	config, err := ParseConfig(strings.TrimSpace(`
+entropy "seal" {
+	mode = "augmentation"
+}
+`), logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Entropy{
+		Type: "seal",
+		Mode: EntropyModeAugmentation,
+	}
+	if !reflect.DeepEqual(config.Entropy, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config.Entropy, expected)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+entropy "seal" {
+	mode = "replacement"
+}
+`), logger)
+	if err == nil || !strings.Contains(err.Error(), `invalid mode "replacement"`) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+entropy "hsm" {
+	mode = "augmentation"
+}
+`), logger)
+	if err == nil || !strings.Contains(err.Error(), `invalid entropy source type "hsm"`) {
+		t.Errorf("bad error: %v", err)
+	}
+}
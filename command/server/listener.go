@@ -5,13 +5,17 @@ import (
 	// certificates that use it can be parsed.
 	_ "crypto/sha512"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/tlsutil"
+	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/vault"
 )
 
@@ -21,6 +25,7 @@ type ListenerFactory func(map[string]string, io.Writer) (net.Listener, map[strin
 // BuiltinListeners is the list of built-in listener types.
 var BuiltinListeners = map[string]ListenerFactory{
 	"tcp":   tcpListenerFactory,
+	"unix":  unixListenerFactory,
 	"atlas": atlasListenerFactory,
 }
 
@@ -112,11 +117,102 @@ func listenerWrapTLS(
 	return ln, props, cg.reload, nil
 }
 
+// splitList splits a comma separated list, ignoring empty entries
+func splitList(v string) []string {
+	var values []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// ParseForwardedFor returns the X-Forwarded-For configuration of the
+// listener, or nil if no proxy is trusted
+func ParseForwardedFor(config map[string]string) (*vaulthttp.ForwardedForConfig, error) {
+	v, ok := config["x_forwarded_for_authorized_addrs"]
+	if !ok {
+		return nil, nil
+	}
+
+	ff := &vaulthttp.ForwardedForConfig{
+		RejectNotAuthorized: true,
+		RejectNotPresent:    true,
+	}
+	for _, addr := range splitList(v) {
+		_, n, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for 'x_forwarded_for_authorized_addrs': %v", err)
+		}
+		ff.AuthorizedAddrs = append(ff.AuthorizedAddrs, n)
+	}
+	if len(ff.AuthorizedAddrs) == 0 {
+		return nil, fmt.Errorf("'x_forwarded_for_authorized_addrs' must not be empty")
+	}
+
+	if v, ok := config["x_forwarded_for_hop_skips"]; ok {
+		hopSkips, err := strconv.Atoi(v)
+		if err != nil || hopSkips < 0 {
+			return nil, fmt.Errorf("invalid value for 'x_forwarded_for_hop_skips': %q", v)
+		}
+		ff.HopSkips = hopSkips
+	}
+	if v, ok := config["x_forwarded_for_reject_not_authorized"]; ok {
+		reject, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for 'x_forwarded_for_reject_not_authorized': %v", err)
+		}
+		ff.RejectNotAuthorized = reject
+	}
+	if v, ok := config["x_forwarded_for_reject_not_present"]; ok {
+		reject, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for 'x_forwarded_for_reject_not_present': %v", err)
+		}
+		ff.RejectNotPresent = reject
+	}
+
+	return ff, nil
+}
+
+// ParseRequestLimits returns the request limits of the listener, or nil if
+// it uses the defaults
+func ParseRequestLimits(config map[string]string) (*vaulthttp.RequestLimits, error) {
+	var limits *vaulthttp.RequestLimits
+	if v, ok := config["max_request_size"]; ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid value for 'max_request_size': %q", v)
+		}
+		limits = &vaulthttp.RequestLimits{
+			MaxRequestSize: size,
+		}
+	}
+	if v, ok := config["max_request_duration"]; ok {
+		d, err := parseutil.ParseDurationSecond(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid value for 'max_request_duration': %q", v)
+		}
+		if limits == nil {
+			limits = &vaulthttp.RequestLimits{}
+		}
+		limits.MaxRequestDuration = d
+	}
+	return limits, nil
+}
+
 type certificateGetter struct {
 	sync.RWMutex
 
+	// cert is the certificate served to the clients which do not request a
+	// name of the SNI certificates
 	cert *tls.Certificate
 
+	// sniCerts are the additional certificates by the DNS names they are
+	// valid for, which may be wildcards
+	sniCerts map[string]*tls.Certificate
+
 	id string
 }
 
@@ -130,10 +226,40 @@ func (cg *certificateGetter) reload(config map[string]string) error {
 		return err
 	}
 
+	certFiles := splitList(config["tls_sni_cert_files"])
+	keyFiles := splitList(config["tls_sni_key_files"])
+	if len(certFiles) != len(keyFiles) {
+		return fmt.Errorf("'tls_sni_cert_files' and 'tls_sni_key_files' must have the same number of files")
+	}
+
+	sniCerts := make(map[string]*tls.Certificate)
+	for i := range certFiles {
+		sniCert, err := tls.LoadX509KeyPair(certFiles[i], keyFiles[i])
+		if err != nil {
+			return fmt.Errorf("error loading SNI certificate %s: %v", certFiles[i], err)
+		}
+		leaf, err := x509.ParseCertificate(sniCert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("error parsing SNI certificate %s: %v", certFiles[i], err)
+		}
+
+		names := leaf.DNSNames
+		if len(names) == 0 && leaf.Subject.CommonName != "" {
+			names = []string{leaf.Subject.CommonName}
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("SNI certificate %s has no DNS names", certFiles[i])
+		}
+		for _, name := range names {
+			sniCerts[strings.ToLower(name)] = &sniCert
+		}
+	}
+
 	cg.Lock()
 	defer cg.Unlock()
 
 	cg.cert = &cert
+	cg.sniCerts = sniCerts
 
 	return nil
 }
@@ -146,5 +272,19 @@ func (cg *certificateGetter) getCertificate(clientHello *tls.ClientHelloInfo) (*
 		return nil, fmt.Errorf("nil certificate")
 	}
 
+	// Select the SNI certificate matching the requested name exactly, or
+	// with a wildcard in place of its first label
+	name := strings.ToLower(strings.TrimSuffix(clientHello.ServerName, "."))
+	if name != "" && len(cg.sniCerts) > 0 {
+		if cert, ok := cg.sniCerts[name]; ok {
+			return cert, nil
+		}
+		if i := strings.Index(name, "."); i > 0 {
+			if cert, ok := cg.sniCerts["*"+name[i:]]; ok {
+				return cert, nil
+			}
+		}
+	}
+
 	return cg.cert, nil
 }
@@ -72,3 +72,65 @@ func TestTCPListener_tls(t *testing.T) {
 
 	testListenerImpl(t, ln, connFn, "foo.example.com")
 }
+
+// TestTCPListener_tlsSNI tests the selection of the certificate by the
+// requested server name, and its reload
+func TestTCPListener_tlsSNI(t *testing.T) {
+	wd, _ := os.Getwd()
+	wd += "/test-fixtures/reload/"
+
+	inBytes, _ := ioutil.ReadFile(wd + "reload_ca.pem")
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(inBytes) {
+		t.Fatal("not ok when appending CA cert")
+	}
+
+	config := map[string]string{
+		"address":            "127.0.0.1:0",
+		"tls_cert_file":      wd + "reload_foo.pem",
+		"tls_key_file":       wd + "reload_foo.key",
+		"tls_sni_cert_files": wd + "reload_bar.pem",
+		"tls_sni_key_files":  wd + "reload_bar.key",
+	}
+	ln, _, reloadFunc, err := tcpListenerFactory(config, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	connFn := func(serverName string) testListenerConnFn {
+		return func(lnReal net.Listener) (net.Conn, error) {
+			conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+				RootCAs:    certPool,
+				ServerName: serverName,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err = conn.Handshake(); err != nil {
+				return nil, err
+			}
+			return conn, nil
+		}
+	}
+
+	testListenerImpl(t, ln, connFn("bar.example.com"), "bar.example.com")
+	testListenerImpl(t, ln, connFn("foo.example.com"), "foo.example.com")
+	testListenerImpl(t, ln, connFn("127.0.0.1"), "foo.example.com")
+
+	// Swap the certificates
+	config["tls_cert_file"] = wd + "reload_bar.pem"
+	config["tls_key_file"] = wd + "reload_bar.key"
+	config["tls_sni_cert_files"] = wd + "reload_foo.pem"
+	config["tls_sni_key_files"] = wd + "reload_foo.key"
+	if err := reloadFunc(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testListenerImpl(t, ln, connFn("foo.example.com"), "foo.example.com")
+	testListenerImpl(t, ln, connFn("127.0.0.1"), "bar.example.com")
+
+	// The files must be paired
+	config["tls_sni_key_files"] = ""
+	if err := reloadFunc(config); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"testing"
+	"time"
 )
 
 type testListenerConnFn func(net.Listener) (net.Conn, error)
@@ -62,3 +63,60 @@ func testListenerImpl(t *testing.T, ln net.Listener, connFn testListenerConnFn,
 		t.Fatalf("bad: %v", buf.String())
 	}
 }
+
+func TestParseForwardedFor(t *testing.T) {
+	ff, err := ParseForwardedFor(map[string]string{})
+	if err != nil || ff != nil {
+		t.Fatalf("bad: %#v %v", ff, err)
+	}
+
+	ff, err = ParseForwardedFor(map[string]string{
+		"x_forwarded_for_authorized_addrs":   "10.0.0.0/8, 192.168.1.0/24",
+		"x_forwarded_for_hop_skips":          "1",
+		"x_forwarded_for_reject_not_present": "false",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(ff.AuthorizedAddrs) != 2 || ff.HopSkips != 1 || !ff.RejectNotAuthorized || ff.RejectNotPresent {
+		t.Fatalf("bad: %#v", ff)
+	}
+
+	for _, config := range []map[string]string{
+		{"x_forwarded_for_authorized_addrs": "10.0.0.1"},
+		{"x_forwarded_for_authorized_addrs": ""},
+		{"x_forwarded_for_authorized_addrs": "10.0.0.0/8", "x_forwarded_for_hop_skips": "-1"},
+	} {
+		if _, err := ParseForwardedFor(config); err == nil {
+			t.Fatalf("expected error for %v", config)
+		}
+	}
+}
+
+func TestParseRequestLimits(t *testing.T) {
+	limits, err := ParseRequestLimits(map[string]string{})
+	if err != nil || limits != nil {
+		t.Fatalf("bad: %#v %v", limits, err)
+	}
+
+	limits, err = ParseRequestLimits(map[string]string{
+		"max_request_size":     "1048576",
+		"max_request_duration": "2m",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if limits.MaxRequestSize != 1048576 || limits.MaxRequestDuration != 2*time.Minute {
+		t.Fatalf("bad: %#v", limits)
+	}
+
+	for _, config := range []map[string]string{
+		{"max_request_size": "0"},
+		{"max_request_size": "big"},
+		{"max_request_duration": "forever"},
+	} {
+		if _, err := ParseRequestLimits(config); err == nil {
+			t.Fatalf("expected error for %v", config)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/hashicorp/vault/vault"
+)
+
+func unixListenerFactory(config map[string]string, _ io.Writer) (net.Listener, map[string]string, vault.ReloadFunc, error) {
+	addr, ok := config["address"]
+	if !ok || addr == "" {
+		return nil, nil, nil, fmt.Errorf("'address' must be set to the path of the socket")
+	}
+
+	// Remove the socket left by a previous run, but nothing else
+	if fi, err := os.Lstat(addr); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, nil, nil, fmt.Errorf("%s exists and is not a socket", addr)
+		}
+		if err := os.Remove(addr); err != nil {
+			return nil, nil, nil, fmt.Errorf("error removing existing socket: %v", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := setSocketPermissions(addr, config); err != nil {
+		ln.Close()
+		return nil, nil, nil, err
+	}
+
+	// The socket is only reachable from the node, so TLS is disabled unless
+	// it is explicitly enabled
+	tlsConfig := make(map[string]string, len(config)+1)
+	for k, v := range config {
+		tlsConfig[k] = v
+	}
+	if _, ok := tlsConfig["tls_disable"]; !ok {
+		tlsConfig["tls_disable"] = "true"
+	}
+
+	props := map[string]string{"addr": addr}
+	return listenerWrapTLS(ln, props, tlsConfig)
+}
+
+// setSocketPermissions applies the socket_mode, socket_user and
+// socket_group options to the socket. The user and group may be given by
+// name or numeric id.
+func setSocketPermissions(path string, config map[string]string) error {
+	if v, ok := config["socket_mode"]; ok {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'socket_mode': %v", err)
+		}
+		if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+			return fmt.Errorf("error setting socket mode: %v", err)
+		}
+	}
+
+	uid, gid := -1, -1
+	if v, ok := config["socket_user"]; ok {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			u, err := user.Lookup(v)
+			if err != nil {
+				return fmt.Errorf("invalid value for 'socket_user': %v", err)
+			}
+			if id, err = strconv.Atoi(u.Uid); err != nil {
+				return fmt.Errorf("invalid uid for 'socket_user': %v", err)
+			}
+		}
+		uid = id
+	}
+	if v, ok := config["socket_group"]; ok {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			g, err := user.LookupGroup(v)
+			if err != nil {
+				return fmt.Errorf("invalid value for 'socket_group': %v", err)
+			}
+			if id, err = strconv.Atoi(g.Gid); err != nil {
+				return fmt.Errorf("invalid gid for 'socket_group': %v", err)
+			}
+		}
+		gid = id
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("error setting socket owner: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-listener")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "vault.sock")
+	config := map[string]string{
+		"address":     socket,
+		"socket_mode": "0660",
+	}
+	ln, props, _, err := unixListenerFactory(config, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if props["tls"] != "disabled" {
+		t.Fatalf("bad: %v", props)
+	}
+
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0660 {
+		t.Fatalf("bad mode: %v", fi.Mode())
+	}
+
+	connFn := func(lnReal net.Listener) (net.Conn, error) {
+		return net.Dial("unix", socket)
+	}
+	testListenerImpl(t, ln, connFn, "")
+
+	// The socket left by a previous run is replaced, but not other files
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if ln, _, _, err = unixListenerFactory(config, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ln.Close()
+
+	config["address"] = filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(config["address"], []byte("foo"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, _, _, err := unixListenerFactory(config, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
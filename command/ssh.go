@@ -32,7 +32,8 @@ type SSHCredentialResp struct {
 
 func (c *SSHCommand) Run(args []string) int {
 	var role, mountPoint, format, userKnownHostsFile, strictHostKeyChecking string
-	var noExec bool
+	var mode, publicKeyPath, privateKeyPath, certDir string
+	var noExec, sshConfig, proxy bool
 	var sshCmdArgs []string
 	flags := c.Meta.FlagSet("ssh", meta.FlagSetDefault)
 	flags.StringVar(&strictHostKeyChecking, "strict-host-key-checking", "", "")
@@ -41,6 +42,12 @@ func (c *SSHCommand) Run(args []string) int {
 	flags.StringVar(&role, "role", "", "")
 	flags.StringVar(&mountPoint, "mount-point", "ssh", "")
 	flags.BoolVar(&noExec, "no-exec", false, "")
+	flags.StringVar(&mode, "mode", "", "")
+	flags.StringVar(&publicKeyPath, "public-key-path", "~/.ssh/id_rsa.pub", "")
+	flags.StringVar(&privateKeyPath, "private-key-path", "~/.ssh/id_rsa", "")
+	flags.StringVar(&certDir, "cert-dir", "~/.vault-ssh", "")
+	flags.BoolVar(&sshConfig, "ssh-config", false, "")
+	flags.BoolVar(&proxy, "proxy", false, "")
 
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
@@ -73,35 +80,47 @@ func (c *SSHCommand) Run(args []string) int {
 		return 1
 	}
 
+	switch mode {
+	case "", "ca":
+	default:
+		c.Ui.Error(fmt.Sprintf("Invalid mode: %q", mode))
+		return 1
+	}
+	if (sshConfig || proxy) && mode != "ca" {
+		c.Ui.Error("-ssh-config and -proxy require -mode=ca")
+		return 1
+	}
+
 	client, err := c.Client()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error initializing client: %v", err))
 		return 1
 	}
 
-	// split the parameter username@ip
-	input := strings.Split(args[0], "@")
-	var username string
-	var ipAddr string
+	username, ipAddr, err := parseSSHTarget(args[0])
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
 
-	// If only IP is mentioned and username is skipped, assume username to
-	// be the current username. Vault SSH role's default username could have
-	// been used, but in order to retain the consistency with SSH command,
-	// current username is employed.
-	if len(input) == 1 {
-		u, err := user.Current()
-		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error fetching username: %v", err))
-			return 1
+	if mode == "ca" {
+		opts := &sshCAOptions{
+			role:                  role,
+			mountPoint:            mountPoint,
+			publicKeyPath:         publicKeyPath,
+			privateKeyPath:        privateKeyPath,
+			certDir:               certDir,
+			userKnownHostsFile:    userKnownHostsFile,
+			strictHostKeyChecking: strictHostKeyChecking,
+		}
+		switch {
+		case sshConfig:
+			return c.printSSHConfig(client, opts, username, ipAddr)
+		case proxy:
+			return c.runProxy(client, opts, username, ipAddr, args[1:])
+		default:
+			return c.runCA(client, opts, username, ipAddr, args[1:])
 		}
-		username = u.Username
-		ipAddr = input[0]
-	} else if len(input) == 2 {
-		username = input[0]
-		ipAddr = input[1]
-	} else {
-		c.Ui.Error(fmt.Sprintf("Invalid parameter: %q", args[0]))
-		return 1
 	}
 
 	// Resolving domain names to IP address on the client side.
@@ -231,6 +250,27 @@ func (c *SSHCommand) Run(args []string) int {
 	return 0
 }
 
+// parseSSHTarget splits the username@host argument of the command. If only
+// the host is mentioned and username is skipped, assume username to be the
+// current username. Vault SSH role's default username could have been used,
+// but in order to retain the consistency with SSH command, current username
+// is employed.
+func parseSSHTarget(target string) (string, string, error) {
+	input := strings.Split(target, "@")
+	switch len(input) {
+	case 1:
+		u, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("Error fetching username: %v", err)
+		}
+		return u.Username, input[0], nil
+	case 2:
+		return input[0], input[1], nil
+	default:
+		return "", "", fmt.Errorf("Invalid parameter: %q", target)
+	}
+}
+
 // If user did not provide the role with which SSH connection has
 // to be established and if there is only one role associated with
 // the IP, it is used by default.
@@ -276,7 +316,7 @@ func (c *SSHCommand) Synopsis() string {
 
 func (c *SSHCommand) Help() string {
 	helpText := `
-Usage: vault ssh [options] username@ip
+Usage: vault ssh [options] username@ip [ssh arguments]
 
   Establishes an SSH connection with the target machine.
 
@@ -289,6 +329,19 @@ Usage: vault ssh [options] username@ip
   of agent in target machines is required. 
   See [https://github.com/hashicorp/vault-ssh-agent]
 
+  With -mode=ca, the local public key is signed by the CA of the backend
+  with the given role, and the certificate is used to connect to the
+  target machine, which must trust the CA. The certificate is cached per
+  role and signed again when it is about to expire. The arguments after
+  the target, such as -L, -J or -o options, are passed to ssh untouched.
+
+  In CA mode, -ssh-config outputs an ssh_config entry for the target whose
+  ProxyCommand runs "vault ssh -proxy", which keeps the certificate signed
+  and connects ssh to the target, so that plain ssh, scp and sftp can be
+  used:
+
+      $ vault ssh -mode=ca -role=dev -ssh-config user@host >> ~/.ssh/config
+
 General Options:
 ` + meta.GeneralOptionsUsage() + `
 SSH Options:
@@ -325,6 +378,25 @@ SSH Options:
 					warnings and host key checking can be avoided while establishing the
 					connection. Defaults to "~/.ssh/known_hosts". Can also be specified
 					with VAULT_SSH_USER_KNOWN_HOSTS_FILE environment variable.
+
+	-mode				Set to "ca" to sign the local public key with the CA of the
+					backend instead of requesting a key or an OTP from the role.
+
+	-public-key-path		Path to the public key to sign in CA mode. Defaults to
+					"~/.ssh/id_rsa.pub".
+
+	-private-key-path		Path to the private key of the public key signed in CA mode.
+					Defaults to "~/.ssh/id_rsa".
+
+	-cert-dir			Directory in which the certificates signed in CA mode are
+					cached. Defaults to "~/.vault-ssh".
+
+	-ssh-config			Outputs an ssh_config entry for the target instead of
+					connecting to it. Requires -mode=ca.
+
+	-proxy				Signs the certificate if needed and connects stdin and stdout
+					to the port given after the target, for use as the
+					ProxyCommand of ssh. Requires -mode=ca.
 `
 	return strings.TrimSpace(helpText)
 }
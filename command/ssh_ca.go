@@ -0,0 +1,252 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshCAOptions are the settings of the CA mode of the ssh command
+type sshCAOptions struct {
+	role                  string
+	mountPoint            string
+	publicKeyPath         string
+	privateKeyPath        string
+	certDir               string
+	userKnownHostsFile    string
+	strictHostKeyChecking string
+}
+
+// runCA connects to the host with the certificate of the public key, passing
+// the remaining arguments to ssh
+func (c *SSHCommand) runCA(client *api.Client, opts *sshCAOptions, username, host string, sshArgs []string) int {
+	certPath, err := c.caCertificate(client, opts, username)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	privateKeyPath, err := homedir.Expand(opts.privateKeyPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error expanding private key path: %v", err))
+		return 1
+	}
+
+	sshCmdArgs := []string{
+		"-i", privateKeyPath,
+		"-o", "CertificateFile=" + certPath,
+		"-o", "UserKnownHostsFile=" + opts.userKnownHostsFile,
+		"-o", "StrictHostKeyChecking=" + opts.strictHostKeyChecking,
+		username + "@" + host,
+	}
+	sshCmdArgs = append(sshCmdArgs, sshArgs...)
+
+	sshCmd := exec.Command("ssh", sshCmdArgs...)
+	sshCmd.Stdin = os.Stdin
+	sshCmd.Stdout = os.Stdout
+	sshCmd.Stderr = os.Stderr
+	if err := sshCmd.Run(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error while running ssh command: %q", err))
+		return 1
+	}
+
+	return 0
+}
+
+// runProxy signs the certificate if needed and connects stdin and stdout to
+// the port of the host given in args. Nothing but the connection is written
+// to stdout, which is read by ssh.
+func (c *SSHCommand) runProxy(client *api.Client, opts *sshCAOptions, username, host string, args []string) int {
+	port := "22"
+	if len(args) > 0 {
+		port = args[0]
+	}
+
+	if _, err := c.caCertificate(client, opts, username); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to %s: %v", host, err))
+		return 1
+	}
+	defer conn.Close()
+
+	// ssh closes our stdin when it is done, and the host closes the
+	// connection when the session ends, either of which ends the proxy
+	doneCh := make(chan struct{}, 2)
+	go func() {
+		io.Copy(conn, os.Stdin)
+		doneCh <- struct{}{}
+	}()
+	go func() {
+		io.Copy(os.Stdout, conn)
+		doneCh <- struct{}{}
+	}()
+	<-doneCh
+
+	return 0
+}
+
+// printSSHConfig outputs an ssh_config entry for the host whose ProxyCommand
+// is this command in proxy mode
+func (c *SSHCommand) printSSHConfig(client *api.Client, opts *sshCAOptions, username, host string) int {
+	if opts.role == "" {
+		c.Ui.Error("-role must be set in CA mode")
+		return 1
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error finding the vault executable: %v", err))
+		return 1
+	}
+
+	var paths [4]string
+	for i, path := range []string{opts.publicKeyPath, opts.privateKeyPath, opts.certDir, c.certPath(opts)} {
+		if paths[i], err = homedir.Expand(path); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error expanding path %s: %v", path, err))
+			return 1
+		}
+	}
+
+	proxyCommand := []string{
+		executable, "ssh",
+		"-address=" + client.Address(),
+		"-mode=ca",
+		"-mount-point=" + opts.mountPoint,
+		"-role=" + opts.role,
+		"-public-key-path=" + paths[0],
+		"-cert-dir=" + paths[2],
+		"-proxy",
+		"%r@%h", "%p",
+	}
+
+	c.Ui.Output(fmt.Sprintf(`Host %s
+    User %s
+    IdentityFile %s
+    CertificateFile %s
+    ProxyCommand %s`, host, username, paths[1], paths[3], strings.Join(proxyCommand, " ")))
+	return 0
+}
+
+// certPath returns the path of the cached certificate of the role
+func (c *SSHCommand) certPath(opts *sshCAOptions) string {
+	mount := strings.Replace(strings.Trim(opts.mountPoint, "/"), "/", "_", -1)
+	return filepath.Join(opts.certDir, fmt.Sprintf("%s-%s-cert.pub", mount, opts.role))
+}
+
+// caCertificate returns the path of the certificate of the public key signed
+// with the role for the username. The cached certificate is used unless it
+// is about to expire or was signed for another key or username.
+func (c *SSHCommand) caCertificate(client *api.Client, opts *sshCAOptions, username string) (string, error) {
+	if opts.role == "" {
+		return "", fmt.Errorf("-role must be set in CA mode")
+	}
+
+	publicKeyPath, err := homedir.Expand(opts.publicKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("Error expanding public key path: %v", err)
+	}
+	publicKey, err := ioutil.ReadFile(publicKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("Error reading public key: %v", err)
+	}
+
+	certPath, err := homedir.Expand(c.certPath(opts))
+	if err != nil {
+		return "", fmt.Errorf("Error expanding certificate path: %v", err)
+	}
+	if cert, err := ioutil.ReadFile(certPath); err == nil && sshCertValid(cert, publicKey, username, time.Now()) {
+		return certPath, nil
+	}
+
+	secret, err := client.SSHWithMountPoint(opts.mountPoint).SignKey(opts.role, map[string]interface{}{
+		"public_key":       string(publicKey),
+		"valid_principals": username,
+		"cert_type":        "user",
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error signing public key: %v", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("Error signing public key: empty response")
+	}
+	signedKey, ok := secret.Data["signed_key"].(string)
+	if !ok || signedKey == "" {
+		return "", fmt.Errorf("Error signing public key: no signed key in response")
+	}
+
+	// Write to a temporary file first so that concurrent commands never
+	// read a partial certificate
+	if err := os.MkdirAll(filepath.Dir(certPath), 0700); err != nil {
+		return "", fmt.Errorf("Error creating certificate directory: %v", err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(certPath), ".vault-ssh-")
+	if err != nil {
+		return "", fmt.Errorf("Error storing certificate: %v", err)
+	}
+	_, err = f.WriteString(signedKey)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), certPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("Error storing certificate: %v", err)
+	}
+
+	return certPath, nil
+}
+
+// sshCertValid returns whether the certificate is for the public key and the
+// username, and is not in the last fifth of its validity at the given time
+func sshCertValid(certBytes, publicKeyBytes []byte, username string, now time.Time) bool {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return false
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return false
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(publicKeyBytes)
+	if err != nil || !bytes.Equal(cert.Key.Marshal(), publicKey.Marshal()) {
+		return false
+	}
+
+	validPrincipal := false
+	for _, principal := range cert.ValidPrincipals {
+		if principal == username {
+			validPrincipal = true
+			break
+		}
+	}
+	if !validPrincipal {
+		return false
+	}
+
+	if cert.ValidBefore == ssh.CertTimeInfinity {
+		return true
+	}
+	validAfter := time.Unix(int64(cert.ValidAfter), 0)
+	validBefore := time.Unix(int64(cert.ValidBefore), 0)
+	renewAt := validBefore.Add(-validBefore.Sub(validAfter) / 5)
+	return !now.Before(validAfter) && now.Before(renewAt)
+}
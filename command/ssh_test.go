@@ -2,17 +2,24 @@ package command
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/vault/api"
 	logicalssh "github.com/hashicorp/vault/builtin/logical/ssh"
 	"github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/meta"
 	"github.com/hashicorp/vault/vault"
 	"github.com/mitchellh/cli"
+	"golang.org/x/crypto/ssh"
 )
 
 const (
@@ -197,3 +204,131 @@ func testSSH(t *testing.T) {
 		t.Fatalf("err: username mismatch")
 	}
 }
+
+func TestSSH_ca(t *testing.T) {
+	if err := vault.AddTestLogicalBackend("ssh", logicalssh.Factory); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	dir, err := ioutil.TempDir("", "vault-ssh")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ui := new(cli.MockUi)
+	c := &SSHCommand{
+		Meta: meta.Meta{
+			ClientToken: token,
+			Ui:          ui,
+		},
+	}
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetAddress(addr)
+
+	if err := client.Sys().Mount("ssh", &api.MountInput{Type: "ssh"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for path, data := range map[string]map[string]interface{}{
+		"ssh/config/ca": map[string]interface{}{
+			"generate_signing_key": true,
+		},
+		"ssh/roles/dev": map[string]interface{}{
+			"key_type":                "ca",
+			"allow_user_certificates": true,
+			"allowed_users":           "*",
+			"ttl":                     "1h",
+		},
+	} {
+		if _, err := client.Logical().Write(path, data); err != nil {
+			t.Fatalf("%s: err: %s", path, err)
+		}
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	publicKeyPath := filepath.Join(dir, "id_rsa.pub")
+	if err := ioutil.WriteFile(publicKeyPath, ssh.MarshalAuthorizedKey(publicKey), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	opts := &sshCAOptions{
+		role:          "dev",
+		mountPoint:    "ssh",
+		publicKeyPath: publicKeyPath,
+		certDir:       filepath.Join(dir, "certs"),
+	}
+	sign := func(username string) []byte {
+		certPath, err := c.caCertificate(client, opts, username)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if certPath != filepath.Join(dir, "certs", "ssh-dev-cert.pub") {
+			t.Fatalf("bad: %s", certPath)
+		}
+		cert, err := ioutil.ReadFile(certPath)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return cert
+	}
+
+	// The certificate is cached until it is signed for another username
+	cert := sign("alice")
+	if !sshCertValid(cert, ssh.MarshalAuthorizedKey(publicKey), "alice", time.Now()) {
+		t.Fatalf("bad: %s", cert)
+	}
+	if cached := sign("alice"); !bytes.Equal(cached, cert) {
+		t.Fatalf("expected cached certificate")
+	}
+	if other := sign("bob"); bytes.Equal(other, cert) {
+		t.Fatalf("expected new certificate")
+	}
+
+	// Certificates are signed again in the last fifth of their validity
+	cert = sign("bob")
+	if !sshCertValid(cert, ssh.MarshalAuthorizedKey(publicKey), "bob", time.Now().Add(45*time.Minute)) {
+		t.Fatalf("expected valid certificate")
+	}
+	if sshCertValid(cert, ssh.MarshalAuthorizedKey(publicKey), "bob", time.Now().Add(50*time.Minute)) {
+		t.Fatalf("expected certificate to be renewed")
+	}
+
+	args := []string{
+		"-address", addr,
+		"-mode=ca",
+		"-role=dev",
+		"-public-key-path=" + publicKeyPath,
+		"-private-key-path=" + filepath.Join(dir, "id_rsa"),
+		"-cert-dir=" + filepath.Join(dir, "certs"),
+		"-ssh-config",
+		"alice@example.com",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	output := ui.OutputWriter.String()
+	for _, expected := range []string{
+		"Host example.com\n",
+		"User alice\n",
+		"CertificateFile " + filepath.Join(dir, "certs", "ssh-dev-cert.pub") + "\n",
+		"-mode=ca -mount-point=ssh -role=dev",
+		"-proxy %r@%h %p",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, output)
+		}
+	}
+}
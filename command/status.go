@@ -15,11 +15,16 @@ type StatusCommand struct {
 }
 
 func (c *StatusCommand) Run(args []string) int {
+	var format string
 	flags := c.Meta.FlagSet("status", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
+	if !validateFormat(c.Ui, format) {
+		return 1
+	}
 
 	client, err := c.Client()
 	if err != nil {
@@ -49,11 +54,17 @@ func (c *StatusCommand) Run(args []string) int {
 		sealStatus.Nonce,
 		sealStatus.Version)
 
+	if sealStatus.Migration {
+		outStr = fmt.Sprintf("%s\nSeal Migration in Progress: true", outStr)
+	}
+
 	if sealStatus.ClusterName != "" && sealStatus.ClusterID != "" {
 		outStr = fmt.Sprintf("%s\nCluster Name: %s\nCluster ID: %s", outStr, sealStatus.ClusterName, sealStatus.ClusterID)
 	}
 
-	c.Ui.Output(outStr)
+	if isTableFormat(format) {
+		c.Ui.Output(outStr)
+	}
 
 	// Mask the 'Vault is sealed' error, since this means HA is enabled,
 	// but that we cannot query for the leader since we are sealed.
@@ -68,6 +79,31 @@ func (c *StatusCommand) Run(args []string) int {
 		return 1
 	}
 
+	if !isTableFormat(format) {
+		if ret := OutputData(c.Ui, format, &statusOutput{
+			SealStatusResponse: sealStatus,
+			LeaderResponse:     leaderStatus,
+		}); ret != 0 {
+			return ret
+		}
+	} else {
+		c.outputHAStatus(sealStatus, leaderStatus)
+	}
+
+	if sealStatus.Sealed {
+		return 2
+	} else {
+		return 0
+	}
+}
+
+// statusOutput is the status in the json and yaml formats
+type statusOutput struct {
+	*api.SealStatusResponse
+	*api.LeaderResponse
+}
+
+func (c *StatusCommand) outputHAStatus(sealStatus *api.SealStatusResponse, leaderStatus *api.LeaderResponse) {
 	// Output if HA is enabled
 	c.Ui.Output("")
 	c.Ui.Output(fmt.Sprintf("High-Availability Enabled: %v", leaderStatus.HAEnabled))
@@ -87,12 +123,6 @@ func (c *StatusCommand) Run(args []string) int {
 			c.Ui.Output(fmt.Sprintf("\tLeader: %s", leaderStatus.LeaderAddress))
 		}
 	}
-
-	if sealStatus.Sealed {
-		return 2
-	} else {
-		return 0
-	}
 }
 
 func (c *StatusCommand) Synopsis() string {
@@ -109,6 +139,11 @@ Usage: vault status [options]
   code also reflects the seal status (0 unsealed, 2 sealed, 1 error).
 
 General Options:
-` + meta.GeneralOptionsUsage()
+` + meta.GeneralOptionsUsage() + `
+Status Options:
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+`
 	return strings.TrimSpace(helpText)
 }
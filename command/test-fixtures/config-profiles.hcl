@@ -0,0 +1,7 @@
+token_helper = "foo"
+
+profile "work" {
+  token_helper = "bar"
+}
+
+profile "dev" {}
@@ -41,13 +41,20 @@ func ExternalTokenHelperPath(path string) (string, error) {
 // BinaryPath is executed within a shell with environment Env. The last argument
 // appended will be the operation, which is:
 //
-//   * "get" - Read the value of the token and write it to stdout.
+//   * "get" - Read the value of the token and write it to stdout. Output
+//       nothing and exit successfully if no token is stored. Surrounding
+//       whitespace is trimmed from the output.
 //   * "store" - Store the value of the token which is on stdin. Output
 //       nothing.
 //   * "erase" - Erase the contents stored. Output nothing.
 //
 // Any errors can be written on stdout. If the helper exits with a non-zero
-// exit code then the stderr will be made part of the error value.
+// exit code then the stderr will be made part of the error value. If Env is
+// nil, the helper inherits the environment of Vault, in which VAULT_PROFILE
+// names the selected profile so that helpers can store a token per profile.
+//
+// Reference helpers for the macOS Keychain and the Linux secret service are
+// in scripts/token-helpers.
 type ExternalTokenHelper struct {
 	BinaryPath string
 	Env        []string
@@ -80,7 +87,7 @@ func (h *ExternalTokenHelper) Get() (string, error) {
 			"Error: %s\n\n%s", err, stderr.String())
 	}
 
-	return buf.String(), nil
+	return strings.TrimSpace(buf.String()), nil
 }
 
 // Store stores the token value into the helper.
@@ -122,6 +122,9 @@ func TestExternalTokenHelperProcess(*testing.T) {
 			}
 			defer f.Close()
 			io.Copy(os.Stdout, f)
+
+			// The output of helpers is trimmed
+			fmt.Fprintln(os.Stdout)
 		case "store":
 			f, err := os.Create(path)
 			if err != nil {
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/helper/password"
 	"github.com/hashicorp/vault/meta"
 )
@@ -19,9 +20,10 @@ type UnsealCommand struct {
 }
 
 func (c *UnsealCommand) Run(args []string) int {
-	var reset bool
+	var reset, migrate bool
 	flags := c.Meta.FlagSet("unseal", meta.FlagSetDefault)
 	flags.BoolVar(&reset, "reset", false, "")
+	flags.BoolVar(&migrate, "migrate", false, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -71,7 +73,10 @@ func (c *UnsealCommand) Run(args []string) int {
 				return 1
 			}
 		}
-		sealStatus, err = client.Sys().Unseal(strings.TrimSpace(value))
+		sealStatus, err = client.Sys().UnsealWithOptions(&api.UnsealOpts{
+			Key:     strings.TrimSpace(value),
+			Migrate: migrate,
+		})
 	}
 
 	if err != nil {
@@ -92,6 +97,9 @@ func (c *UnsealCommand) Run(args []string) int {
 		sealStatus.Progress,
 		sealStatus.Nonce,
 	))
+	if sealStatus.Migration {
+		c.Ui.Output("Seal Migration in Progress: true")
+	}
 
 	return 0
 }
@@ -123,6 +131,12 @@ Unseal Options:
   -reset                  Reset the unsealing process by throwing away
                           prior keys in process to unseal the vault.
 
+  -migrate                Migrate the vault to the seal of its configuration
+                          from the seal it was sealed by. The keys entered
+                          are the unseal keys when migrating from Shamir
+                          keys, or the recovery keys when migrating from an
+                          auto-unseal seal.
+
 `
 	return strings.TrimSpace(helpText)
 }
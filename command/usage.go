@@ -0,0 +1,115 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/meta"
+	"github.com/ryanuber/columnize"
+)
+
+// UsageCommand is a Command that reports the distinct clients of the auth
+// mounts.
+type UsageCommand struct {
+	meta.Meta
+}
+
+func (c *UsageCommand) Run(args []string) int {
+	var format, startRaw, endRaw string
+	flags := c.Meta.FlagSet("usage", meta.FlagSetDefault)
+	flags.StringVar(&format, "format", "table", "")
+	flags.StringVar(&startRaw, "start-time", "", "")
+	flags.StringVar(&endRaw, "end-time", "", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if !validateFormat(c.Ui, format) {
+		return 1
+	}
+
+	var start, end time.Time
+	for _, t := range []struct {
+		name  string
+		raw   string
+		value *time.Time
+	}{
+		{"start-time", startRaw, &start},
+		{"end-time", endRaw, &end},
+	} {
+		if t.raw == "" {
+			continue
+		}
+		var err error
+		if *t.value, err = time.Parse(time.RFC3339, t.raw); err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Invalid -%s %q, expected an RFC3339 time such as 2006-01-02T15:04:05Z", t.name, t.raw))
+			return 1
+		}
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error initializing client: %s", err))
+		return 2
+	}
+
+	activity, err := client.Sys().Activity(start, end)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error reading usage: %s", err))
+		return 2
+	}
+
+	if !isTableFormat(format) {
+		return OutputData(c.Ui, format, activity)
+	}
+
+	c.Ui.Output(fmt.Sprintf("Period: %s to %s\n",
+		activity.StartTime.Format(time.RFC3339), activity.EndTime.Format(time.RFC3339)))
+
+	columns := []string{"Mount | Distinct Entities | Non-Entity Tokens | Clients"}
+	for _, m := range activity.ByMount {
+		columns = append(columns, fmt.Sprintf("%s | %d | %d | %d",
+			m.MountPath, m.Counts.DistinctEntities, m.Counts.NonEntityTokens, m.Counts.Clients))
+	}
+	columns = append(columns, fmt.Sprintf("Total | %d | %d | %d",
+		activity.Total.DistinctEntities, activity.Total.NonEntityTokens, activity.Total.Clients))
+
+	c.Ui.Output(columnize.SimpleFormat(columns))
+	return 0
+}
+
+func (c *UsageCommand) Synopsis() string {
+	return "Reports the distinct clients of the auth mounts"
+}
+
+func (c *UsageCommand) Help() string {
+	helpText := `
+Usage: vault usage [options]
+
+  Reports the distinct clients of the auth mounts.
+
+  Each token which made a request during the period counts once, for the
+  auth mount it was created with. The clients are counted per UTC day, so
+  the period is rounded to whole days. By default the period ends now and
+  covers the default report period of the server, 30 days unless configured
+  with sys/internal/counters/config.
+
+General Options:
+` + meta.GeneralOptionsUsage() + `
+Usage Options:
+
+  -start-time=<time>      The start of the period, as an RFC3339 time such as
+                          2006-01-02T15:04:05Z.
+
+  -end-time=<time>        The end of the period, as an RFC3339 time. Defaults
+                          to now.
+
+  -format=table           The format for output. By default it is a whitespace-
+                          delimited table. This can also be json or yaml.
+`
+	return strings.TrimSpace(helpText)
+}
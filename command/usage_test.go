@@ -0,0 +1,60 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/vault"
+	"github.com/mitchellh/cli"
+)
+
+func TestUsage(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	newCommand := func() (*UsageCommand, *cli.MockUi) {
+		ui := new(cli.MockUi)
+		return &UsageCommand{
+			Meta: meta.Meta{
+				ClientToken: token,
+				Ui:          ui,
+			},
+		}, ui
+	}
+
+	c, ui := newCommand()
+	args := []string{
+		"-address", addr,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "auth/token/") || !strings.Contains(output, "Total") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	c, ui = newCommand()
+	args = []string{
+		"-address", addr,
+		"-format", "json",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if output := ui.OutputWriter.String(); !strings.Contains(output, `"non_entity_tokens": 1`) {
+		t.Fatalf("bad: %s", output)
+	}
+
+	c, ui = newCommand()
+	args = []string{
+		"-address", addr,
+		"-start-time", "yesterday",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
@@ -1,24 +1,32 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/command/token"
+	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/mitchellh/cli"
 )
 
-// DefaultTokenHelper returns the token helper that is configured for Vault.
+// DefaultTokenHelper returns the token helper that is configured for Vault,
+// for the profile selected with the VAULT_PROFILE environment variable if any.
 func DefaultTokenHelper() (token.TokenHelper, error) {
 	config, err := LoadConfig("")
 	if err != nil {
 		return nil, err
 	}
 
-	path := config.TokenHelper
+	path, err := config.TokenHelperPath(os.Getenv(ProfileEnv))
+	if err != nil {
+		return nil, err
+	}
 	if path == "" {
 		return &token.InternalTokenHelper{}, nil
 	}
@@ -57,6 +65,8 @@ func PrintRawField(ui cli.Ui, secret *api.Secret, field string) int {
 			val = secret.WrapInfo.TTL
 		case "wrapping_token_creation_time":
 			val = secret.WrapInfo.CreationTime.Format(time.RFC3339Nano)
+		case "wrapping_token_creation_path":
+			val = secret.WrapInfo.CreationPath
 		case "wrapped_accessor":
 			val = secret.WrapInfo.WrappedAccessor
 		default:
@@ -72,6 +82,22 @@ func PrintRawField(ui cli.Ui, secret *api.Secret, field string) int {
 		}
 	}
 
+	// Dotted paths select nested values, which are output as JSON when they
+	// are objects or lists
+	if val == nil && strings.Contains(field, ".") {
+		val = nestedField(secret, field)
+		switch val.(type) {
+		case map[string]interface{}, []interface{}:
+			buf, err := json.Marshal(val)
+			if err != nil {
+				ui.Error(fmt.Sprintf(
+					"Error encoding field %s: %s", field, err))
+				return 1
+			}
+			val = string(buf)
+		}
+	}
+
 	if val != nil {
 		// c.Ui.Output() prints a CR character which in this case is
 		// not desired. Since Vault CLI currently only uses BasicUi,
@@ -90,3 +116,41 @@ func PrintRawField(ui cli.Ui, secret *api.Secret, field string) int {
 		return 1
 	}
 }
+
+// nestedField resolves a dotted path, such as data.nested.key, within the
+// data of the secret, or else within the JSON representation of the whole
+// secret. List elements are selected by their index.
+func nestedField(secret *api.Secret, field string) interface{} {
+	parts := strings.Split(field, ".")
+	if val := walkField(secret.Data, parts); val != nil {
+		return val
+	}
+
+	buf, err := json.Marshal(secret)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := jsonutil.DecodeJSON(buf, &m); err != nil {
+		return nil
+	}
+	return walkField(m, parts)
+}
+
+func walkField(val interface{}, parts []string) interface{} {
+	for _, p := range parts {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			val = v[p]
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			val = v[i]
+		default:
+			return nil
+		}
+	}
+	return val
+}
@@ -0,0 +1,258 @@
+package awsutil
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+)
+
+// KMS is a minimal client for the AWS Key Management Service, covering the
+// operations Vault needs to delegate cryptographic operations to keys that
+// never leave KMS.
+type KMS struct {
+	*client.Client
+}
+
+// NewKMS creates a new KMS client from the given configuration provider,
+// usually an AWS session.
+func NewKMS(p client.ConfigProvider, cfgs ...*aws.Config) *KMS {
+	c := p.ClientConfig("kms", cfgs...)
+
+	svc := &KMS{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "kms",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2014-11-01",
+				JSONVersion:   "1.1",
+				TargetPrefix:  "TrentService",
+			},
+			c.Handlers,
+		),
+	}
+
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+
+	return svc
+}
+
+func (c *KMS) send(name string, input, output interface{}) error {
+	op := &request.Operation{
+		Name:       name,
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+
+	return c.NewRequest(op, input, output).Send()
+}
+
+type kmsGetPublicKeyInput struct {
+	_     struct{} `type:"structure"`
+	KeyId *string  `min:"1" type:"string" required:"true"`
+}
+
+type kmsGetPublicKeyOutput struct {
+	_         struct{} `type:"structure"`
+	KeyId     *string  `type:"string"`
+	PublicKey []byte   `type:"blob"`
+}
+
+// GetPublicKey returns the public half of the given asymmetric KMS key
+func (c *KMS) GetPublicKey(keyID string) (crypto.PublicKey, error) {
+	output := &kmsGetPublicKeyOutput{}
+	if err := c.send("GetPublicKey", &kmsGetPublicKeyInput{KeyId: aws.String(keyID)}, output); err != nil {
+		return nil, err
+	}
+
+	return x509.ParsePKIXPublicKey(output.PublicKey)
+}
+
+type kmsSignInput struct {
+	_                struct{} `type:"structure"`
+	KeyId            *string  `min:"1" type:"string" required:"true"`
+	Message          []byte   `min:"1" type:"blob" required:"true"`
+	MessageType      *string  `type:"string"`
+	SigningAlgorithm *string  `type:"string" required:"true"`
+}
+
+type kmsSignOutput struct {
+	_         struct{} `type:"structure"`
+	KeyId     *string  `type:"string"`
+	Signature []byte   `type:"blob"`
+}
+
+// Sign signs the given digest with the given asymmetric KMS key and signing
+// algorithm, e.g. "ECDSA_SHA_256"
+func (c *KMS) Sign(keyID string, digest []byte, algorithm string) ([]byte, error) {
+	output := &kmsSignOutput{}
+	err := c.send("Sign", &kmsSignInput{
+		KeyId:            aws.String(keyID),
+		Message:          digest,
+		MessageType:      aws.String("DIGEST"),
+		SigningAlgorithm: aws.String(algorithm),
+	}, output)
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Signature, nil
+}
+
+type kmsDescribeKeyInput struct {
+	_     struct{} `type:"structure"`
+	KeyId *string  `min:"1" type:"string" required:"true"`
+}
+
+type kmsDescribeKeyOutput struct {
+	_           struct{} `type:"structure"`
+	KeyMetadata *struct {
+		_     struct{} `type:"structure"`
+		Arn   *string  `type:"string"`
+		KeyId *string  `type:"string"`
+	} `type:"structure"`
+}
+
+// DescribeKey returns the ARN of the given KMS key, resolving aliases to the
+// key they currently point to
+func (c *KMS) DescribeKey(keyID string) (string, error) {
+	output := &kmsDescribeKeyOutput{}
+	if err := c.send("DescribeKey", &kmsDescribeKeyInput{KeyId: aws.String(keyID)}, output); err != nil {
+		return "", err
+	}
+	if output.KeyMetadata == nil || output.KeyMetadata.Arn == nil {
+		return "", fmt.Errorf("no metadata returned for KMS key %q", keyID)
+	}
+
+	return *output.KeyMetadata.Arn, nil
+}
+
+type kmsEncryptInput struct {
+	_         struct{} `type:"structure"`
+	KeyId     *string  `min:"1" type:"string" required:"true"`
+	Plaintext []byte   `min:"1" type:"blob" required:"true"`
+}
+
+type kmsEncryptOutput struct {
+	_              struct{} `type:"structure"`
+	CiphertextBlob []byte   `min:"1" type:"blob"`
+	KeyId          *string  `type:"string"`
+}
+
+// Encrypt encrypts the plaintext, of at most 4KB, with the given symmetric
+// KMS key. The ARN of the key which encrypted it is returned along with the
+// ciphertext.
+func (c *KMS) Encrypt(keyID string, plaintext []byte) ([]byte, string, error) {
+	output := &kmsEncryptOutput{}
+	err := c.send("Encrypt", &kmsEncryptInput{
+		KeyId:     aws.String(keyID),
+		Plaintext: plaintext,
+	}, output)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return output.CiphertextBlob, aws.StringValue(output.KeyId), nil
+}
+
+type kmsDecryptInput struct {
+	_              struct{} `type:"structure"`
+	CiphertextBlob []byte   `min:"1" type:"blob" required:"true"`
+}
+
+type kmsDecryptOutput struct {
+	_         struct{} `type:"structure"`
+	KeyId     *string  `type:"string"`
+	Plaintext []byte   `min:"1" type:"blob"`
+}
+
+// Decrypt decrypts a ciphertext returned by Encrypt. The key which
+// encrypted it is identified by the ciphertext, so values encrypted before
+// an alias was moved to another key can still be decrypted.
+func (c *KMS) Decrypt(ciphertext []byte) ([]byte, error) {
+	output := &kmsDecryptOutput{}
+	if err := c.send("Decrypt", &kmsDecryptInput{CiphertextBlob: ciphertext}, output); err != nil {
+		return nil, err
+	}
+
+	return output.Plaintext, nil
+}
+
+type kmsGenerateRandomInput struct {
+	_             struct{} `type:"structure"`
+	NumberOfBytes *int64   `min:"1" type:"integer"`
+}
+
+type kmsGenerateRandomOutput struct {
+	_         struct{} `type:"structure"`
+	Plaintext []byte   `min:"1" type:"blob"`
+}
+
+// GenerateRandom returns the given number of random bytes, at most 1024,
+// generated by the HSMs of KMS
+func (c *KMS) GenerateRandom(bytes int) ([]byte, error) {
+	output := &kmsGenerateRandomOutput{}
+	if err := c.send("GenerateRandom", &kmsGenerateRandomInput{NumberOfBytes: aws.Int64(int64(bytes))}, output); err != nil {
+		return nil, err
+	}
+
+	return output.Plaintext, nil
+}
+
+// KMSSigner is a crypto.Signer whose private key is held in AWS KMS
+type KMSSigner struct {
+	client    *KMS
+	keyID     string
+	publicKey crypto.PublicKey
+}
+
+// NewKMSSigner returns a crypto.Signer backed by the given asymmetric KMS key
+func NewKMSSigner(c *KMS, keyID string) (*KMSSigner, error) {
+	publicKey, err := c.GetPublicKey(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching public key of KMS key %q: %v", keyID, err)
+	}
+
+	return &KMSSigner{
+		client:    c,
+		keyID:     keyID,
+		publicKey: publicKey,
+	}, nil
+}
+
+// Public returns the public key of the KMS key
+func (s *KMSSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the digest using KMS. Only ECDSA keys are supported; the
+// signature is returned ASN.1 encoded as for ecdsa.PrivateKey.
+func (s *KMSSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var algorithm string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		algorithm = "ECDSA_SHA_256"
+	case crypto.SHA384:
+		algorithm = "ECDSA_SHA_384"
+	case crypto.SHA512:
+		algorithm = "ECDSA_SHA_512"
+	default:
+		return nil, fmt.Errorf("unsupported hash function for KMS signing: %v", opts.HashFunc())
+	}
+
+	return s.client.Sign(s.keyID, digest, algorithm)
+}
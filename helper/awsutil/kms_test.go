@@ -0,0 +1,93 @@
+package awsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestKMSSigner(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Fatal(err)
+		}
+		if input["KeyId"] != "alias/ssh-ca" {
+			t.Fatalf("bad key id: %q", input["KeyId"])
+		}
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			json.NewEncoder(w).Encode(map[string]string{
+				"KeyId":     input["KeyId"],
+				"PublicKey": base64.StdEncoding.EncodeToString(publicKeyDER),
+			})
+		case "TrentService.Sign":
+			if input["SigningAlgorithm"] != "ECDSA_SHA_256" || input["MessageType"] != "DIGEST" {
+				t.Fatalf("bad sign input: %#v", input)
+			}
+			digest, _ := base64.StdEncoding.DecodeString(input["Message"])
+			signature, err := privateKey.Sign(rand.Reader, digest, crypto.SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			json.NewEncoder(w).Encode(map[string]string{
+				"KeyId":     input["KeyId"],
+				"Signature": base64.StdEncoding.EncodeToString(signature),
+			})
+		default:
+			t.Fatalf("unexpected target: %q", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewKMS(session.New(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+	}))
+
+	signer, err := NewKMSSigner(client, "alias/ssh-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("data"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.Verify(signer.Public().(*ecdsa.PublicKey), digest[:], sig.R, sig.S) {
+		t.Fatalf("signature did not verify")
+	}
+}
@@ -0,0 +1,50 @@
+// +build go1.13
+
+package certutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"fmt"
+	"io"
+)
+
+// The Ed25519 keys use crypto/ed25519 and its support in crypto/x509, which
+// are only available from Go 1.13
+
+func init() {
+	ed25519Supported = true
+}
+
+func generateEd25519Key(randReader io.Reader) (crypto.Signer, []byte, error) {
+	_, privateKey, err := ed25519.GenerateKey(randReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, privateKeyBytes, nil
+}
+
+func isEd25519PrivateKey(key interface{}) bool {
+	_, ok := key.(ed25519.PrivateKey)
+	return ok
+}
+
+func isEd25519PublicKey(key interface{}) bool {
+	_, ok := key.(ed25519.PublicKey)
+	return ok
+}
+
+func compareEd25519PublicKeys(key1Iface, key2Iface crypto.PublicKey) (bool, error) {
+	key1 := key1Iface.(ed25519.PublicKey)
+	key2, ok := key2Iface.(ed25519.PublicKey)
+	if !ok {
+		return false, fmt.Errorf("key types do not match: %T and %T", key1Iface, key2Iface)
+	}
+	return bytes.Equal(key1, key2), nil
+}
@@ -0,0 +1,29 @@
+// +build !go1.13
+
+package certutil
+
+import (
+	"crypto"
+	"errors"
+	"io"
+)
+
+func init() {
+	ed25519Supported = false
+}
+
+func generateEd25519Key(randReader io.Reader) (crypto.Signer, []byte, error) {
+	return nil, nil, errors.New("Ed25519 keys require Go 1.13 or later")
+}
+
+func isEd25519PrivateKey(key interface{}) bool {
+	return false
+}
+
+func isEd25519PublicKey(key interface{}) bool {
+	return false
+}
+
+func compareEd25519PublicKeys(key1Iface, key2Iface crypto.PublicKey) (bool, error) {
+	return false, errors.New("Ed25519 keys require Go 1.13 or later")
+}
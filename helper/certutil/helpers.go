@@ -11,6 +11,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"math/big"
 	"strconv"
 	"strings"
@@ -154,6 +155,12 @@ func ParsePEMBundle(pemBundle string) (*ParsedCertBundle, error) {
 				parsedBundle.PrivateKey = signer
 				parsedBundle.PrivateKeyType = ECPrivateKey
 				parsedBundle.PrivateKeyBytes = pemBlock.Bytes
+			default:
+				if isEd25519PrivateKey(signer) {
+					parsedBundle.PrivateKey = signer.(crypto.Signer)
+					parsedBundle.PrivateKeyType = Ed25519PrivateKey
+					parsedBundle.PrivateKeyBytes = pemBlock.Bytes
+				}
 			}
 		} else if certificates, err := x509.ParseCertificates(pemBlock.Bytes); err == nil {
 			certPath = append(certPath, &CertBlock{
@@ -179,8 +186,23 @@ func ParsePEMBundle(pemBundle string) (*ParsedCertBundle, error) {
 	return parsedBundle, nil
 }
 
+// ed25519Supported is set if the build supports the Ed25519 keys
+var ed25519Supported bool
+
+// Ed25519Supported returns true if the Ed25519 keys are supported, which
+// requires building with Go 1.13 or later
+func Ed25519Supported() bool {
+	return ed25519Supported
+}
+
 // GeneratePrivateKey generates a private key with the specified type and key bits
 func GeneratePrivateKey(keyType string, keyBits int, container ParsedPrivateKeyContainer) error {
+	return GeneratePrivateKeyWithRandomSource(keyType, keyBits, container, rand.Reader)
+}
+
+// GeneratePrivateKeyWithRandomSource generates a private key with the
+// specified type and key bits, using randReader as the source of randomness
+func GeneratePrivateKeyWithRandomSource(keyType string, keyBits int, container ParsedPrivateKeyContainer, randReader io.Reader) error {
 	var err error
 	var privateKeyType PrivateKeyType
 	var privateKeyBytes []byte
@@ -189,7 +211,7 @@ func GeneratePrivateKey(keyType string, keyBits int, container ParsedPrivateKeyC
 	switch keyType {
 	case "rsa":
 		privateKeyType = RSAPrivateKey
-		privateKey, err = rsa.GenerateKey(rand.Reader, keyBits)
+		privateKey, err = rsa.GenerateKey(randReader, keyBits)
 		if err != nil {
 			return errutil.InternalError{Err: fmt.Sprintf("error generating RSA private key: %v", err)}
 		}
@@ -209,7 +231,7 @@ func GeneratePrivateKey(keyType string, keyBits int, container ParsedPrivateKeyC
 		default:
 			return errutil.UserError{Err: fmt.Sprintf("unsupported bit length for EC key: %d", keyBits)}
 		}
-		privateKey, err = ecdsa.GenerateKey(curve, rand.Reader)
+		privateKey, err = ecdsa.GenerateKey(curve, randReader)
 		if err != nil {
 			return errutil.InternalError{Err: fmt.Sprintf("error generating EC private key: %v", err)}
 		}
@@ -217,6 +239,16 @@ func GeneratePrivateKey(keyType string, keyBits int, container ParsedPrivateKeyC
 		if err != nil {
 			return errutil.InternalError{Err: fmt.Sprintf("error marshalling EC private key: %v", err)}
 		}
+	case "ed25519":
+		// The key size is fixed
+		if !ed25519Supported {
+			return errutil.UserError{Err: "Ed25519 keys are not supported by this build"}
+		}
+		privateKeyType = Ed25519PrivateKey
+		privateKey, privateKeyBytes, err = generateEd25519Key(randReader)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error generating Ed25519 private key: %v", err)}
+		}
 	default:
 		return errutil.UserError{Err: fmt.Sprintf("unknown key type: %s", keyType)}
 	}
@@ -272,6 +304,9 @@ func ComparePublicKeys(key1Iface, key2Iface crypto.PublicKey) (bool, error) {
 		return true, nil
 
 	default:
+		if isEd25519PublicKey(key1Iface) {
+			return compareEd25519PublicKeys(key1Iface, key2Iface)
+		}
 		return false, fmt.Errorf("cannot compare key with type %T", key1Iface)
 	}
 }
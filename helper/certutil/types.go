@@ -29,9 +29,9 @@ type Secret struct {
 	Data map[string]interface{} `json:"data"`
 }
 
-// PrivateKeyType holds a string representation of the type of private key (ec
-// or rsa) referenced in CertBundle and ParsedCertBundle. This uses colloquial
-// names rather than official names, to eliminate confusion
+// PrivateKeyType holds a string representation of the type of private key (ec,
+// rsa or ed25519) referenced in CertBundle and ParsedCertBundle. This uses
+// colloquial names rather than official names, to eliminate confusion
 type PrivateKeyType string
 
 //Well-known PrivateKeyTypes
@@ -39,6 +39,7 @@ const (
 	UnknownPrivateKey PrivateKeyType = ""
 	RSAPrivateKey     PrivateKeyType = "rsa"
 	ECPrivateKey      PrivateKeyType = "ec"
+	Ed25519PrivateKey PrivateKeyType = "ed25519"
 )
 
 // TLSUsage controls whether the intended usage of a *tls.Config
@@ -169,6 +170,8 @@ func (c *CertBundle) ToParsedCertBundle() (*ParsedCertBundle, error) {
 				c.PrivateKeyType = ECPrivateKey
 			case RSAPrivateKey:
 				c.PrivateKeyType = RSAPrivateKey
+			case Ed25519PrivateKey:
+				c.PrivateKeyType = Ed25519PrivateKey
 			}
 		default:
 			return nil, errutil.UserError{fmt.Sprintf("Unsupported key block type: %s", pemBlock.Type)}
@@ -276,6 +279,8 @@ func (p *ParsedCertBundle) ToCertBundle() (*CertBundle, error) {
 				block.Type = string(ECBlock)
 			case RSAPrivateKey:
 				block.Type = string(PKCS1Block)
+			case Ed25519PrivateKey:
+				block.Type = string(PKCS8Block)
 			}
 		}
 
@@ -365,12 +370,15 @@ func (p *ParsedCertBundle) getSigner() (crypto.Signer, error) {
 			case *rsa.PrivateKey, *ecdsa.PrivateKey:
 				return k.(crypto.Signer), nil
 			default:
+				if isEd25519PrivateKey(k) {
+					return k.(crypto.Signer), nil
+				}
 				return nil, errutil.UserError{"Found unknown private key type in pkcs#8 wrapping"}
 			}
 		}
 		return nil, errutil.UserError{fmt.Sprintf("Failed to parse pkcs#8 key: %v", err)}
 	default:
-		return nil, errutil.UserError{"Unable to determine type of private key; only RSA and EC are supported"}
+		return nil, errutil.UserError{"Unable to determine type of private key; only RSA, EC and Ed25519 are supported"}
 	}
 	return signer, nil
 }
@@ -394,6 +402,9 @@ func getPKCS8Type(bs []byte) (PrivateKeyType, error) {
 	case *rsa.PrivateKey:
 		return RSAPrivateKey, nil
 	default:
+		if isEd25519PrivateKey(k) {
+			return Ed25519PrivateKey, nil
+		}
 		return UnknownPrivateKey, errutil.UserError{"Found unknown private key type in pkcs#8 wrapping"}
 	}
 }
@@ -417,6 +428,12 @@ func (c *CSRBundle) ToParsedCSRBundle() (*ParsedCSRBundle, error) {
 			result.PrivateKeyType = ECPrivateKey
 		case PKCS1Block:
 			result.PrivateKeyType = RSAPrivateKey
+		case PKCS8Block:
+			t, err := getPKCS8Type(pemBlock.Bytes)
+			if err != nil || t != Ed25519PrivateKey {
+				return nil, errutil.UserError{Err: fmt.Sprintf("Unknown private key type in bundle: %s", c.PrivateKeyType)}
+			}
+			result.PrivateKeyType = Ed25519PrivateKey
 		default:
 			// Try to figure it out and correct
 			if _, err := x509.ParseECPrivateKey(pemBlock.Bytes); err == nil {
@@ -473,6 +490,9 @@ func (p *ParsedCSRBundle) ToCSRBundle() (*CSRBundle, error) {
 		case ECPrivateKey:
 			result.PrivateKeyType = "ec"
 			block.Type = "EC PRIVATE KEY"
+		case Ed25519PrivateKey:
+			result.PrivateKeyType = "ed25519"
+			block.Type = "PRIVATE KEY"
 		default:
 			return nil, errutil.InternalError{"Could not determine private key type when creating block"}
 		}
@@ -507,8 +527,18 @@ func (p *ParsedCSRBundle) getSigner() (crypto.Signer, error) {
 			return nil, errutil.UserError{fmt.Sprintf("Unable to parse CA's private RSA key: %s", err)}
 		}
 
+	case Ed25519PrivateKey:
+		key, err := x509.ParsePKCS8PrivateKey(p.PrivateKeyBytes)
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("Unable to parse CA's private Ed25519 key: %s", err)}
+		}
+		if !isEd25519PrivateKey(key) {
+			return nil, errutil.UserError{Err: "Unable to parse CA's private Ed25519 key: not an Ed25519 key"}
+		}
+		signer = key.(crypto.Signer)
+
 	default:
-		return nil, errutil.UserError{"Unable to determine type of private key; only RSA and EC are supported"}
+		return nil, errutil.UserError{"Unable to determine type of private key; only RSA, EC and Ed25519 are supported"}
 	}
 	return signer, nil
 }
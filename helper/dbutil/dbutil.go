@@ -0,0 +1,46 @@
+package dbutil
+
+import (
+	"strings"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+)
+
+// GeneratePassword returns a new password for a database user, generated
+// from the password policy named in the "password_policy" field of the
+// connection configuration stored at "config/connection", or a UUID if no
+// policy is configured
+func GeneratePassword(s logical.Storage, sys logical.SystemView) (string, error) {
+	entry, err := s.Get("config/connection")
+	if err != nil {
+		return "", err
+	}
+
+	var connConfig struct {
+		PasswordPolicy string `json:"password_policy"`
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(&connConfig); err != nil {
+			return "", err
+		}
+	}
+
+	if connConfig.PasswordPolicy == "" {
+		return uuid.GenerateUUID()
+	}
+
+	return sys.GeneratePasswordFromPolicy(connConfig.PasswordPolicy)
+}
+
+// EscapeLiteral escapes a value substituted in a single-quoted SQL or CQL
+// string literal, by doubling its single quotes
+func EscapeLiteral(s string) string {
+	return strings.Replace(s, "'", "''", -1)
+}
+
+// EscapeMySQLLiteral escapes a value substituted in a single or double-quoted
+// MySQL string literal, with backslashes as MySQL interprets them by default
+func EscapeMySQLLiteral(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,66 @@
+package dbutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	s := &logical.InmemStorage{}
+	sys := logical.StaticSystemView{
+		PasswordPolicies: map[string]string{
+			"digits": `length = 12
+rule "charset" {
+  charset = "0123456789"
+}`,
+		},
+	}
+
+	// Without a connection or a policy, passwords are UUIDs
+	password, err := GeneratePassword(s, sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != 36 {
+		t.Fatalf("bad: %q", password)
+	}
+
+	entry, err := logical.StorageEntryJSON("config/connection", map[string]interface{}{
+		"connection_url":  "user:pass@tcp(127.0.0.1:3306)/",
+		"password_policy": "digits",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	password, err = GeneratePassword(s, sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != 12 || strings.Trim(password, "0123456789") != "" {
+		t.Fatalf("bad: %q", password)
+	}
+}
+
+func TestEscapeLiteral(t *testing.T) {
+	for _, tc := range []struct {
+		in, out, mysql string
+	}{
+		{"abc", "abc", "abc"},
+		{"a'b", "a''b", `a\'b`},
+		{`a\'; DROP ROLE x; --`, `a\''; DROP ROLE x; --`, `a\\\'; DROP ROLE x; --`},
+		{`a"b`, `a"b`, `a\"b`},
+	} {
+		if out := EscapeLiteral(tc.in); out != tc.out {
+			t.Fatalf("EscapeLiteral(%q): expected %q, got %q", tc.in, tc.out, out)
+		}
+		if out := EscapeMySQLLiteral(tc.in); out != tc.mysql {
+			t.Fatalf("EscapeMySQLLiteral(%q): expected %q, got %q", tc.in, tc.mysql, out)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package dhutil implements the Diffie-Hellman key exchange used to encrypt
+// values, such as the tokens written by the agent sinks, to the holder of a
+// curve25519 private key.
+package dhutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// PublicKeyInfo is the JSON format of a file holding a public key
+type PublicKeyInfo struct {
+	Curve25519PublicKey []byte `json:"curve25519_public_key"`
+}
+
+// Envelope is the JSON format of an encrypted value. It carries the public
+// key of the sender, from which the recipient derives the shared key.
+type Envelope struct {
+	Curve25519PublicKey []byte `json:"curve25519_public_key"`
+	Nonce               []byte `json:"nonce"`
+	EncryptedPayload    []byte `json:"encrypted_payload"`
+}
+
+// GeneratePublicPrivateKey returns a new curve25519 public and private key
+func GeneratePublicPrivateKey() ([]byte, []byte, error) {
+	var scalar, public [32]byte
+
+	if _, err := io.ReadFull(rand.Reader, scalar[:]); err != nil {
+		return nil, nil, err
+	}
+
+	curve25519.ScalarBaseMult(&public, &scalar)
+	return public[:], scalar[:], nil
+}
+
+// GenerateSharedKey derives an AES-256 key from our private key and their
+// public key
+func GenerateSharedKey(ourPrivate, theirPublic []byte) ([]byte, error) {
+	if len(ourPrivate) != 32 {
+		return nil, fmt.Errorf("invalid private key length: %d", len(ourPrivate))
+	}
+	if len(theirPublic) != 32 {
+		return nil, fmt.Errorf("invalid public key length: %d", len(theirPublic))
+	}
+
+	var scalar, pub, secret [32]byte
+	copy(scalar[:], ourPrivate)
+	copy(pub[:], theirPublic)
+	curve25519.ScalarMult(&secret, &scalar, &pub)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret[:], nil, nil), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncryptAES encrypts the plaintext with AES-GCM, returning the ciphertext
+// and the nonce
+func EncryptAES(key, plaintext, aad []byte) ([]byte, []byte, error) {
+	aesgcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aesgcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return aesgcm.Seal(nil, nonce, plaintext, aad), nonce, nil
+}
+
+// DecryptAES decrypts a ciphertext produced by EncryptAES
+func DecryptAES(key, ciphertext, nonce, aad []byte) ([]byte, error) {
+	aesgcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != aesgcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length: %d", len(nonce))
+	}
+	return aesgcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,50 @@
+package dhutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDHUtil_SharedKey(t *testing.T) {
+	pub1, pri1, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pub2, pri2, err := GeneratePublicPrivateKey()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	key1, err := GenerateSharedKey(pri1, pub2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key2, err := GenerateSharedKey(pri2, pub1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatalf("shared keys differ")
+	}
+
+	ciphertext, nonce, err := EncryptAES(key1, []byte("foobar"), []byte("aad"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext, err := DecryptAES(key2, ciphertext, nonce, []byte("aad"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(plaintext) != "foobar" {
+		t.Fatalf("bad: %q", plaintext)
+	}
+
+	// The additional data is authenticated
+	if _, err := DecryptAES(key2, ciphertext, nonce, []byte("other")); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if _, err := GenerateSharedKey(pri1, pub2[:16]); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,70 @@
+package entropy
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/xor"
+)
+
+// MaxRequestBytes is the largest number of bytes requested from a Sourcer
+// at once, as the key management services limit the size of their random
+// values
+const MaxRequestBytes = 1024
+
+// Sourcer is a source of randomness external to the host, such as an HSM or
+// a key management service
+type Sourcer interface {
+	// GetRandom returns the given number of random bytes, at most
+	// MaxRequestBytes
+	GetRandom(bytes int) ([]byte, error)
+}
+
+// Reader is an io.Reader returning the random bytes of crypto/rand mixed
+// with those of a Sourcer, so that the generated keys are at least as
+// random as either of them
+type Reader struct {
+	source Sourcer
+}
+
+var _ io.Reader = (*Reader)(nil)
+
+// NewReader returns a reader augmenting crypto/rand with the source
+func NewReader(source Sourcer) *Reader {
+	return &Reader{
+		source: source,
+	}
+}
+
+// Read fills p with random bytes. It fails if the source fails, rather
+// than falling back to crypto/rand alone.
+func (r *Reader) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		size := len(p) - n
+		if size > MaxRequestBytes {
+			size = MaxRequestBytes
+		}
+
+		external, err := r.source.GetRandom(size)
+		if err != nil {
+			return n, errwrap.Wrapf("error reading from the entropy source: {{err}}", err)
+		}
+		if len(external) != size {
+			return n, fmt.Errorf("entropy source returned %d bytes, expected %d", len(external), size)
+		}
+
+		local := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, local); err != nil {
+			return n, err
+		}
+
+		mixed, err := xor.XORBytes(local, external)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], mixed)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,48 @@
+package entropy
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// testSourcer returns zero bytes, so that the mixed bytes are those of
+// crypto/rand, and records the sizes of the requests
+type testSourcer struct {
+	requests []int
+	err      error
+}
+
+func (s *testSourcer) GetRandom(bytes int) ([]byte, error) {
+	s.requests = append(s.requests, bytes)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return make([]byte, bytes), nil
+}
+
+func TestReader(t *testing.T) {
+	source := &testSourcer{}
+	r := NewReader(source)
+
+	buf := make([]byte, 2*MaxRequestBytes+10)
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if n != len(buf) {
+		t.Fatalf("bad: %d", n)
+	}
+	if bytes.Equal(buf, make([]byte, len(buf))) {
+		t.Fatalf("bytes were not mixed with crypto/rand")
+	}
+	if len(source.requests) != 3 || source.requests[0] != MaxRequestBytes || source.requests[2] != 10 {
+		t.Fatalf("bad requests: %v", source.requests)
+	}
+
+	// Errors of the source are not ignored
+	source.err = fmt.Errorf("unavailable")
+	if _, err := r.Read(buf); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,126 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrUnknownTemplateParameter is returned when a template references a
+	// parameter that is not understood
+	ErrUnknownTemplateParameter = errors.New("unknown template parameter")
+
+	// ErrTemplateValueNotFound is returned when a template references a
+	// parameter that has no value for the given identity
+	ErrTemplateValueNotFound = errors.New("no value could be found for template parameter")
+
+	templateRegex = regexp.MustCompile(`{{\s*([^{}]*?)\s*}}`)
+)
+
+// Identity holds the information about the authenticated caller that
+// templates are resolved against. It is derived from the token issued at the
+// login of the caller.
+type Identity struct {
+	// DisplayName is the display name of the login token
+	DisplayName string
+
+	// Metadata is the metadata that the credential backend attached to the
+	// login token, such as the username or the organization
+	Metadata map[string]string
+}
+
+// ContainsTemplate returns true if the given string contains at least one
+// template parameter
+func ContainsTemplate(tpl string) bool {
+	return templateRegex.MatchString(tpl)
+}
+
+// ValidateTemplate verifies that all the template parameters in the given
+// string are understood, without resolving them
+func ValidateTemplate(tpl string) error {
+	for _, match := range templateRegex.FindAllStringSubmatch(tpl, -1) {
+		if _, err := parseParameter(match[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PopulateString replaces all the template parameters in the given string
+// with values taken from the identity. The following parameters are
+// supported:
+//
+//	{{identity.display_name}}     The display name of the client token
+//	{{identity.metadata.<key>}}   The value of the given token metadata key
+//
+// If any parameter cannot be resolved, an error is returned.
+func PopulateString(tpl string, ident *Identity) (string, error) {
+	var retErr error
+	result := templateRegex.ReplaceAllStringFunc(tpl, func(match string) string {
+		if retErr != nil {
+			return ""
+		}
+
+		param, err := parseParameter(templateRegex.FindStringSubmatch(match)[1])
+		if err != nil {
+			retErr = err
+			return ""
+		}
+
+		value, err := param.resolve(ident)
+		if err != nil {
+			retErr = err
+			return ""
+		}
+
+		return value
+	})
+	if retErr != nil {
+		return "", retErr
+	}
+
+	return result, nil
+}
+
+type templateParameter struct {
+	field string
+	key   string
+}
+
+func parseParameter(raw string) (*templateParameter, error) {
+	switch {
+	case raw == "identity.display_name":
+		return &templateParameter{field: "display_name"}, nil
+
+	case strings.HasPrefix(raw, "identity.metadata."):
+		key := strings.TrimPrefix(raw, "identity.metadata.")
+		if key == "" {
+			return nil, fmt.Errorf("%v: %q", ErrUnknownTemplateParameter, raw)
+		}
+		return &templateParameter{field: "metadata", key: key}, nil
+	}
+
+	return nil, fmt.Errorf("%v: %q", ErrUnknownTemplateParameter, raw)
+}
+
+func (p *templateParameter) resolve(ident *Identity) (string, error) {
+	if ident == nil {
+		return "", ErrTemplateValueNotFound
+	}
+
+	var value string
+	switch p.field {
+	case "display_name":
+		value = ident.DisplayName
+	case "metadata":
+		value = ident.Metadata[p.key]
+	}
+
+	if value == "" {
+		return "", ErrTemplateValueNotFound
+	}
+
+	return value, nil
+}
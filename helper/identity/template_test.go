@@ -0,0 +1,59 @@
+package identity
+
+import "testing"
+
+func TestPopulateString(t *testing.T) {
+	ident := &Identity{
+		DisplayName: "github-jeff",
+		Metadata: map[string]string{
+			"username": "jeff",
+			"org":      "hashicorp",
+		},
+	}
+
+	tcases := []struct {
+		input    string
+		expected string
+		err      bool
+	}{
+		{"plain", "plain", false},
+		{"{{identity.display_name}}", "github-jeff", false},
+		{"{{ identity.metadata.username }}", "jeff", false},
+		{"{{identity.metadata.org}}-{{identity.metadata.username}}", "hashicorp-jeff", false},
+		{"{{identity.metadata.missing}}", "", true},
+		{"{{identity.metadata.}}", "", true},
+		{"{{identity.unknown}}", "", true},
+	}
+
+	for _, tc := range tcases {
+		actual, err := PopulateString(tc.input, ident)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("expected an error for input %q", tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("input %q: err: %v", tc.input, err)
+		}
+		if actual != tc.expected {
+			t.Fatalf("input %q: expected %q, got %q", tc.input, tc.expected, actual)
+		}
+	}
+
+	if _, err := PopulateString("{{identity.display_name}}", nil); err != ErrTemplateValueNotFound {
+		t.Fatalf("expected ErrTemplateValueNotFound, got %v", err)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	if err := ValidateTemplate("{{identity.metadata.username}},admin"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ValidateTemplate("{{identity.entity.id}}"); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !ContainsTemplate("a{{identity.display_name}}") || ContainsTemplate("admin") {
+		t.Fatalf("bad ContainsTemplate result")
+	}
+}
@@ -0,0 +1,208 @@
+package keysutil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"math"
+	"math/big"
+	"unicode/utf8"
+)
+
+// FF3-1 format-preserving encryption, as specified in NIST SP 800-38G
+// Revision 1. The numerals of the input are mapped to an alphabet whose size
+// is the radix, and the output is made of the same alphabet and has the same
+// length as the input.
+
+const (
+	// FF3TweakSize is the size in bytes of the tweaks of FF3-1
+	FF3TweakSize = 7
+
+	ff3Rounds = 8
+
+	// The numeral strings must have at least a million possible values
+	ff3MinDomainSize = 1000000
+)
+
+type ff3Cipher struct {
+	block    cipher.Block
+	alphabet []rune
+	indexes  map[rune]int
+	radix    *big.Int
+	minLen   int
+	maxLen   int
+}
+
+// newFF3Cipher returns a cipher for the AES key and the alphabet of the
+// numerals
+func newFF3Cipher(key []byte, alphabet string) (*ff3Cipher, error) {
+	c := &ff3Cipher{
+		alphabet: []rune(alphabet),
+		indexes:  map[rune]int{},
+	}
+	for i, r := range c.alphabet {
+		if _, ok := c.indexes[r]; ok {
+			return nil, fmt.Errorf("alphabet contains the character %q more than once", r)
+		}
+		c.indexes[r] = i
+	}
+	radix := len(c.alphabet)
+	if radix < 2 || radix > 1<<16 {
+		return nil, fmt.Errorf("alphabet must contain between 2 and 65536 characters")
+	}
+	c.radix = big.NewInt(int64(radix))
+
+	c.minLen = int(math.Ceil(math.Log(ff3MinDomainSize) / math.Log(float64(radix))))
+	if c.minLen < 2 {
+		c.minLen = 2
+	}
+	c.maxLen = 2 * int(math.Floor(96/math.Log2(float64(radix))))
+
+	// FF3 uses the key with its bytes reversed
+	revKey := make([]byte, len(key))
+	for i := range key {
+		revKey[i] = key[len(key)-1-i]
+	}
+	block, err := aes.NewCipher(revKey)
+	if err != nil {
+		return nil, err
+	}
+	c.block = block
+
+	return c, nil
+}
+
+// Encrypt encrypts the value with the 56-bit tweak
+func (c *ff3Cipher) Encrypt(value string, tweak []byte) (string, error) {
+	return c.cipher(value, tweak, true)
+}
+
+// Decrypt decrypts the value with the 56-bit tweak
+func (c *ff3Cipher) Decrypt(value string, tweak []byte) (string, error) {
+	return c.cipher(value, tweak, false)
+}
+
+func (c *ff3Cipher) cipher(value string, tweak []byte, encrypt bool) (string, error) {
+	if len(tweak) != FF3TweakSize {
+		return "", fmt.Errorf("tweak must be %d bytes long", FF3TweakSize)
+	}
+
+	// FF3-1 derives the 64-bit tweak of FF3 from the 56-bit one
+	var tweak64 [8]byte
+	tweak64[0] = tweak[0]
+	tweak64[1] = tweak[1]
+	tweak64[2] = tweak[2]
+	tweak64[3] = tweak[3] & 0xf0
+	tweak64[4] = tweak[4]
+	tweak64[5] = tweak[5]
+	tweak64[6] = tweak[6]
+	tweak64[7] = tweak[3] << 4
+
+	return c.cipher64(value, tweak64[:], encrypt)
+}
+
+// cipher64 runs the FF3 rounds with a 64-bit tweak
+func (c *ff3Cipher) cipher64(value string, tweak []byte, encrypt bool) (string, error) {
+	numerals := make([]int, 0, utf8.RuneCountInString(value))
+	for _, r := range value {
+		index, ok := c.indexes[r]
+		if !ok {
+			return "", fmt.Errorf("value contains the character %q, which is not part of the alphabet", r)
+		}
+		numerals = append(numerals, index)
+	}
+	n := len(numerals)
+	if n < c.minLen || n > c.maxLen {
+		return "", fmt.Errorf("value must be between %d and %d characters long", c.minLen, c.maxLen)
+	}
+
+	u := (n + 1) / 2
+	v := n - u
+	a := numerals[:u]
+	b := numerals[u:]
+
+	tl := tweak[:4]
+	tr := tweak[4:]
+
+	for j := 0; j < ff3Rounds; j++ {
+		i := j
+		if !encrypt {
+			i = ff3Rounds - 1 - j
+		}
+
+		m, w := u, tr
+		if i%2 == 1 {
+			m, w = v, tl
+		}
+
+		// The encryption rounds feed B into the cipher and update A, the
+		// decryption rounds do the opposite
+		in, out := b, a
+		if !encrypt {
+			in, out = a, b
+		}
+
+		var p [16]byte
+		copy(p[:4], w)
+		p[3] ^= byte(i)
+		// NUM(B) is left-padded with zeros to the 12 remaining bytes
+		num := c.num(in).Bytes()
+		copy(p[16-len(num):], num)
+
+		// S = REVB(CIPH(REVB(P)))
+		reverseBytes(p[:])
+		c.block.Encrypt(p[:], p[:])
+		reverseBytes(p[:])
+		y := new(big.Int).SetBytes(p[:])
+
+		modulus := new(big.Int).Exp(c.radix, big.NewInt(int64(m)), nil)
+		result := c.num(out)
+		if encrypt {
+			result.Add(result, y)
+		} else {
+			result.Sub(result, y)
+		}
+		result.Mod(result, modulus)
+
+		if encrypt {
+			a, b = b, c.str(result, m)
+		} else {
+			b, a = a, c.str(result, m)
+		}
+	}
+
+	var result bytes.Buffer
+	for _, numeral := range append(append([]int{}, a...), b...) {
+		result.WriteRune(c.alphabet[numeral])
+	}
+	return result.String(), nil
+}
+
+// num returns the number of the reversed numerals
+func (c *ff3Cipher) num(numerals []int) *big.Int {
+	result := new(big.Int)
+	for i := len(numerals) - 1; i >= 0; i-- {
+		result.Mul(result, c.radix)
+		result.Add(result, big.NewInt(int64(numerals[i])))
+	}
+	return result
+}
+
+// str returns the reversed numerals of the number, in m numerals
+func (c *ff3Cipher) str(x *big.Int, m int) []int {
+	numerals := make([]int, m)
+	x = new(big.Int).Set(x)
+	remainder := new(big.Int)
+	for i := 0; i < m; i++ {
+		x.DivMod(x, c.radix, remainder)
+		numerals[i] = int(remainder.Int64())
+	}
+	return numerals
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -0,0 +1,71 @@
+package keysutil
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestFF3_Vectors(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	// Samples of FF3 with 64-bit tweaks, from which FF3-1 only differs by
+	// the tweak expansion
+	for _, tc := range []struct {
+		key, tweak, plaintext, ciphertext string
+	}{
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "D8E7920AFA330A73", "890121234567890000", "750918814058654607"},
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "9A768A92F60E12D8", "890121234567890000", "018989839189395384"},
+	} {
+		c, err := newFF3Cipher(decode(tc.key), "0123456789")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext, err := c.cipher64(tc.plaintext, decode(tc.tweak), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ciphertext != tc.ciphertext {
+			t.Fatalf("bad: ciphertext of %s: expected %s, got %s", tc.plaintext, tc.ciphertext, ciphertext)
+		}
+		plaintext, err := c.cipher64(ciphertext, decode(tc.tweak), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext != tc.plaintext {
+			t.Fatalf("bad: plaintext of %s: expected %s, got %s", ciphertext, tc.plaintext, plaintext)
+		}
+	}
+
+	// Sample of FF3-1 with a 56-bit tweak
+	c, err := newFF3Cipher(decode("2DE79D232DF5585D68CE47882AE256D6"), "0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := c.Encrypt("3992520240", decode("CBD09280979564"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext != "8901801106" {
+		t.Fatalf("bad: FF3-1 ciphertext: %s", ciphertext)
+	}
+	plaintext, err := c.Decrypt(ciphertext, decode("CBD09280979564"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "3992520240" {
+		t.Fatalf("bad: FF3-1 plaintext: %s", plaintext)
+	}
+
+	// Values outside of the domain are rejected
+	for _, value := range []string{"12345", "12345678901234567890123456789012345678901234567890123456789", "12345a"} {
+		if _, err := c.Encrypt(value, decode("CBD09280979564")); err == nil {
+			t.Fatalf("expected error encrypting %q", value)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// AES key wrap with padding, as specified in RFC 5649. It is used to transport
+// key material of any length encrypted with another AES key.
+
+var kwpAIVPrefix = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+// WrapKeyWithPadding wraps the key with the AES key encryption key
+func WrapKeyWithPadding(kek, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key to wrap is empty")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	var aiv [8]byte
+	copy(aiv[:4], kwpAIVPrefix)
+	binary.BigEndian.PutUint32(aiv[4:], uint32(len(key)))
+
+	padded := make([]byte, (len(key)+7)/8*8)
+	copy(padded, key)
+
+	// A single block is encrypted directly
+	if len(padded) == 8 {
+		result := make([]byte, 16)
+		copy(result, aiv[:])
+		copy(result[8:], padded)
+		block.Encrypt(result, result)
+		return result, nil
+	}
+
+	n := len(padded) / 8
+	result := make([]byte, 8+len(padded))
+	copy(result, aiv[:])
+	copy(result[8:], padded)
+
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], result[:8])
+			copy(b[8:], result[8*i:8*i+8])
+			block.Encrypt(b[:], b[:])
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(result[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(result[8*i:8*i+8], b[8:])
+		}
+	}
+
+	return result, nil
+}
+
+// UnwrapKeyWithPadding unwraps the key with the AES key encryption key,
+// checking its integrity
+func UnwrapKeyWithPadding(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("wrapped key must be a multiple of 8 bytes and at least 16 bytes long")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, len(wrapped))
+	n := len(wrapped)/8 - 1
+
+	if n == 1 {
+		block.Decrypt(result, wrapped)
+	} else {
+		copy(result, wrapped)
+
+		var b [16]byte
+		for j := 5; j >= 0; j-- {
+			for i := n; i >= 1; i-- {
+				t := uint64(n*j + i)
+				binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(result[:8])^t)
+				copy(b[8:], result[8*i:8*i+8])
+				block.Decrypt(b[:], b[:])
+
+				copy(result[:8], b[:8])
+				copy(result[8*i:8*i+8], b[8:])
+			}
+		}
+	}
+
+	// Check the integrity of the alternative initial value and of the
+	// padding
+	length := int(binary.BigEndian.Uint32(result[4:8]))
+	valid := subtle.ConstantTimeCompare(result[:4], kwpAIVPrefix) == 1 &&
+		length > 8*(n-1) && length <= 8*n
+	if valid {
+		for _, p := range result[8+length:] {
+			valid = valid && p == 0
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("wrapped key failed the integrity check")
+	}
+
+	return result[8 : 8+length], nil
+}
@@ -0,0 +1,51 @@
+package keysutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKeyWrapWithPadding(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	// Samples of RFC 5649
+	kek := decode("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	for _, tc := range []struct {
+		key, wrapped string
+	}{
+		{"c37b7e6492584340bed12207808941155068f738", "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a"},
+		{"466f7250617369", "afbeb0f07dfbf5419200f2ccb50bb24f"},
+	} {
+		wrapped, err := WrapKeyWithPadding(kek, decode(tc.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(wrapped) != tc.wrapped {
+			t.Fatalf("bad: key %s wrapped as %x", tc.key, wrapped)
+		}
+		key, err := UnwrapKeyWithPadding(kek, wrapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(key, decode(tc.key)) {
+			t.Fatalf("bad: key %s unwrapped as %x", tc.key, key)
+		}
+	}
+
+	// Tampered keys fail the integrity check
+	wrapped := decode("138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a")
+	wrapped[20] ^= 1
+	if _, err := UnwrapKeyWithPadding(kek, wrapped); err == nil {
+		t.Fatalf("expected error unwrapping a tampered key")
+	}
+	if _, err := UnwrapKeyWithPadding(kek[:16], decode("afbeb0f07dfbf5419200f2ccb50bb24f")); err == nil {
+		t.Fatalf("expected error unwrapping with the wrong key")
+	}
+}
@@ -3,8 +3,10 @@ package keysutil
 import (
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -41,6 +43,14 @@ type PolicyRequest struct {
 
 	// Whether to upsert
 	Upsert bool
+
+	// If set, the key material of the policy when it is created, instead of
+	// generating it
+	ImportKey []byte
+
+	// The source of randomness of the generated key material; crypto/rand
+	// is used if it is nil
+	RandReader io.Reader
 }
 
 type LockManager struct {
@@ -203,6 +213,31 @@ func (lm *LockManager) GetPolicyUpsert(req PolicyRequest) (*Policy, *sync.RWMute
 
 // When the function returns, a lock will be held on the policy if err == nil.
 // It is the caller's responsibility to unlock.
+// ImportPolicy creates a policy from the key material of the request. It fails
+// if the policy already exists.
+func (lm *LockManager) ImportPolicy(req PolicyRequest) error {
+	if req.ImportKey == nil {
+		return fmt.Errorf("no key material to import")
+	}
+	req.Upsert = true
+
+	p, lock, upserted, err := lm.getPolicyCommon(req, exclusive)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("error importing key: returned policy was nil")
+	}
+	if !upserted {
+		return errutil.UserError{Err: fmt.Sprintf("key %s already exists", req.Name)}
+	}
+
+	return nil
+}
+
 func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Policy, *sync.RWMutex, bool, error) {
 	lock := lm.policyLock(req.Name, lockType)
 
@@ -241,17 +276,26 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 		}
 
 		switch req.KeyType {
-		case KeyType_AES256_GCM96:
+		case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 			if req.Convergent && !req.Derived {
+				lm.UnlockPolicy(lock, lockType)
 				return nil, nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
 			}
 
-		case KeyType_ECDSA_P256:
+		case KeyType_ECDSA_P256, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
 			if req.Derived || req.Convergent {
-				return nil, nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %s", KeyType_ECDSA_P256)
+				lm.UnlockPolicy(lock, lockType)
+				return nil, nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %s", req.KeyType)
+			}
+
+		case KeyType_AES256_FF3_1:
+			if req.Derived || req.Convergent {
+				lm.UnlockPolicy(lock, lockType)
+				return nil, nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %s", req.KeyType)
 			}
 
 		default:
+			lm.UnlockPolicy(lock, lockType)
 			return nil, nil, false, fmt.Errorf("unsupported key type %v", req.KeyType)
 		}
 
@@ -267,7 +311,11 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 			p.ConvergentVersion = 2
 		}
 
-		err = p.Rotate(req.Storage)
+		if req.ImportKey != nil {
+			err = p.Import(req.Storage, req.ImportKey)
+		} else {
+			err = p.Rotate(req.Storage, req.RandReader)
+		}
 		if err != nil {
 			lm.UnlockPolicy(lock, lockType)
 			return nil, nil, false, err
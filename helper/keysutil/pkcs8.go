@@ -0,0 +1,77 @@
+package keysutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+// PKCS#8 encoding of the private keys, as specified in RFC 5208. The
+// crypto/x509 package of Go 1.8 only parses it.
+
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+var (
+	oidPublicKeyRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+	oidNamedCurveP224 = asn1.ObjectIdentifier{1, 3, 132, 0, 33}
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// MarshalPKCS8PrivateKey returns the PKCS#8 encoding of the RSA or ECDSA
+// private key
+func MarshalPKCS8PrivateKey(key interface{}) ([]byte, error) {
+	var privKey pkcs8
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		privKey.Algo = pkix.AlgorithmIdentifier{
+			Algorithm: oidPublicKeyRSA,
+			// The parameters are an ASN.1 NULL
+			Parameters: asn1.RawValue{Tag: 5},
+		}
+		privKey.PrivateKey = x509.MarshalPKCS1PrivateKey(k)
+
+	case *ecdsa.PrivateKey:
+		var oid asn1.ObjectIdentifier
+		switch k.Curve {
+		case elliptic.P224():
+			oid = oidNamedCurveP224
+		case elliptic.P256():
+			oid = oidNamedCurveP256
+		case elliptic.P384():
+			oid = oidNamedCurveP384
+		case elliptic.P521():
+			oid = oidNamedCurveP521
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+		oidBytes, err := asn1.Marshal(oid)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling the curve OID: %v", err)
+		}
+		privKey.Algo = pkix.AlgorithmIdentifier{
+			Algorithm:  oidPublicKeyECDSA,
+			Parameters: asn1.RawValue{FullBytes: oidBytes},
+		}
+		if privKey.PrivateKey, err = x509.MarshalECPrivateKey(k); err != nil {
+			return nil, fmt.Errorf("error marshalling the EC key: %v", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return asn1.Marshal(privKey)
+}
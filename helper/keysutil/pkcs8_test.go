@@ -0,0 +1,52 @@
+package keysutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+)
+
+func TestMarshalPKCS8PrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []interface{}{rsaKey}
+	for _, curve := range []elliptic.Curve{elliptic.P224(), elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		ecKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, ecKey)
+	}
+
+	for _, key := range keys {
+		der, err := MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("%T: %v", key, err)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			t.Fatalf("%T: %v", key, err)
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			parsed, ok := parsed.(*rsa.PrivateKey)
+			if !ok || parsed.N.Cmp(key.N) != 0 || parsed.D.Cmp(key.D) != 0 {
+				t.Fatalf("parsed RSA key does not match")
+			}
+		case *ecdsa.PrivateKey:
+			parsed, ok := parsed.(*ecdsa.PrivateKey)
+			if !ok || parsed.Curve != key.Curve || parsed.D.Cmp(key.D) != 0 {
+				t.Fatalf("parsed %s key does not match", key.Curve.Params().Name)
+			}
+		}
+	}
+
+	if _, err := MarshalPKCS8PrivateKey("foo"); err == nil {
+		t.Fatalf("expected error for an unsupported key")
+	}
+}
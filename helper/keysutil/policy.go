@@ -2,12 +2,14 @@ package keysutil
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/asn1"
@@ -17,10 +19,12 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	mathrand "math/rand"
 	"strconv"
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/crypto/hkdf"
 
 	uuid "github.com/hashicorp/go-uuid"
@@ -41,8 +45,24 @@ const (
 const (
 	KeyType_AES256_GCM96 = iota
 	KeyType_ECDSA_P256
+	KeyType_AES256_FF3_1
+	KeyType_ChaCha20_Poly1305
+	KeyType_RSA2048
+	KeyType_RSA3072
+	KeyType_RSA4096
 )
 
+// The padding schemes of the RSA signatures
+const (
+	SignatureAlgorithmRSAPSS      = "pss"
+	SignatureAlgorithmRSAPKCS1v15 = "pkcs1v15"
+)
+
+// The scheduled rotations are delayed by a random jitter of up to a tenth of
+// the period, capped, so that the keys configured at the same time don't all
+// rotate together
+const maxAutoRotateJitter = time.Hour
+
 const ErrTooOld = "ciphertext or signature version is disallowed by policy (too old)"
 
 type ecdsaSignature struct {
@@ -53,7 +73,7 @@ type KeyType int
 
 func (kt KeyType) EncryptionSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 		return true
 	}
 	return false
@@ -61,7 +81,7 @@ func (kt KeyType) EncryptionSupported() bool {
 
 func (kt KeyType) DecryptionSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 		return true
 	}
 	return false
@@ -69,7 +89,15 @@ func (kt KeyType) DecryptionSupported() bool {
 
 func (kt KeyType) SigningSupported() bool {
 	switch kt {
-	case KeyType_ECDSA_P256:
+	case KeyType_ECDSA_P256, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		return true
+	}
+	return false
+}
+
+func (kt KeyType) EncodingSupported() bool {
+	switch kt {
+	case KeyType_AES256_FF3_1:
 		return true
 	}
 	return false
@@ -77,18 +105,42 @@ func (kt KeyType) SigningSupported() bool {
 
 func (kt KeyType) DerivationSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 		return true
 	}
 	return false
 }
 
+// RSAKeyBits returns the size of the keys of the RSA key types, and zero for
+// the other types
+func (kt KeyType) RSAKeyBits() int {
+	switch kt {
+	case KeyType_RSA2048:
+		return 2048
+	case KeyType_RSA3072:
+		return 3072
+	case KeyType_RSA4096:
+		return 4096
+	}
+	return 0
+}
+
 func (kt KeyType) String() string {
 	switch kt {
 	case KeyType_AES256_GCM96:
 		return "aes256-gcm96"
 	case KeyType_ECDSA_P256:
 		return "ecdsa-p256"
+	case KeyType_AES256_FF3_1:
+		return "aes256-ff3-1"
+	case KeyType_ChaCha20_Poly1305:
+		return "chacha20-poly1305"
+	case KeyType_RSA2048:
+		return "rsa-2048"
+	case KeyType_RSA3072:
+		return "rsa-3072"
+	case KeyType_RSA4096:
+		return "rsa-4096"
 	}
 
 	return "[unknown]"
@@ -103,6 +155,8 @@ type KeyEntry struct {
 	EC_Y               *big.Int `json:"ec_y"`
 	EC_D               *big.Int `json:"ec_d"`
 	FormattedPublicKey string   `json:"public_key"`
+
+	RSAKey *rsa.PrivateKey `json:"rsa_key"`
 }
 
 // keyEntryMap is used to allow JSON marshal/unmarshal
@@ -161,6 +215,12 @@ type Policy struct {
 	// a max.
 	ArchiveVersion int `json:"archive_version"`
 
+	// The minimum version of the key kept in storage; older versions have
+	// been trimmed. ArchiveMinVersion is the version of the first key in the
+	// archive, which is zero until the archive is first trimmed.
+	MinAvailableVersion int `json:"min_available_version"`
+	ArchiveMinVersion   int `json:"archive_min_version"`
+
 	// Whether the key is allowed to be deleted
 	DeletionAllowed bool `json:"deletion_allowed"`
 
@@ -169,6 +229,14 @@ type Policy struct {
 
 	// The type of key
 	Type KeyType `json:"type"`
+
+	// The period after which the key is rotated automatically, zero if
+	// disabled, and the Unix time of the next automatic rotation
+	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
+	NextRotationTime int64         `json:"next_rotation_time"`
+
+	// Whether the key material of a version of the key was imported
+	Imported bool `json:"imported"`
 }
 
 // ArchivedKeys stores old keys. This is used to keep the key loading time sane
@@ -223,7 +291,9 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	// that now need to be accessible back here.
 	//
 	// For safety, because there isn't really a good reason to, we never delete
-	// keys from the archive even when we move them back.
+	// keys from the archive even when we move them back. The only exception
+	// is trimming, which deletes the versions older than the minimum
+	// available version.
 
 	// Check if we have the latest minimum version in the current set of keys
 	_, keysContainsMinimum := p.Keys[p.MinDecryptionVersion]
@@ -242,6 +312,9 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	case p.MinDecryptionVersion > p.LatestVersion:
 		return fmt.Errorf("minimum decryption version of %d is greater than the latest version %d",
 			p.MinDecryptionVersion, p.LatestVersion)
+	case p.MinAvailableVersion > p.MinDecryptionVersion:
+		return fmt.Errorf("minimum available version of %d is greater than the minimum decryption version %d",
+			p.MinAvailableVersion, p.MinDecryptionVersion)
 	}
 
 	archive, err := p.LoadArchive(storage)
@@ -253,7 +326,7 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 		// Need to move keys *from* archive
 
 		for i := p.MinDecryptionVersion; i <= p.LatestVersion; i++ {
-			p.Keys[i] = archive.Keys[i]
+			p.Keys[i] = archive.Keys[i-p.ArchiveMinVersion]
 		}
 
 		return nil
@@ -263,10 +336,10 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 
 	// We need a size that is equivalent to the latest version (number of keys)
 	// but adding one since slice numbering starts at 0 and we're indexing by
-	// key version
-	if len(archive.Keys) < p.LatestVersion+1 {
+	// key version, offset by the first version in the archive
+	if len(archive.Keys)+p.ArchiveMinVersion < p.LatestVersion+1 {
 		// Increase the size of the archive slice
-		newKeys := make([]KeyEntry, p.LatestVersion+1)
+		newKeys := make([]KeyEntry, p.LatestVersion-p.ArchiveMinVersion+1)
 		copy(newKeys, archive.Keys)
 		archive.Keys = newKeys
 	}
@@ -274,10 +347,16 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	// We are storing all keys in the archive, so we ensure that it is up to
 	// date up to p.LatestVersion
 	for i := p.ArchiveVersion + 1; i <= p.LatestVersion; i++ {
-		archive.Keys[i] = p.Keys[i]
+		archive.Keys[i-p.ArchiveMinVersion] = p.Keys[i]
 		p.ArchiveVersion = i
 	}
 
+	// Drop the trimmed versions from the archive
+	if p.ArchiveMinVersion < p.MinAvailableVersion {
+		archive.Keys = archive.Keys[p.MinAvailableVersion-p.ArchiveMinVersion:]
+		p.ArchiveMinVersion = p.MinAvailableVersion
+	}
+
 	err = p.storeArchive(archive, storage)
 	if err != nil {
 		return err
@@ -466,13 +545,6 @@ func (p *Policy) Encrypt(context, nonce []byte, value string) (string, error) {
 		return "", errutil.UserError{Err: fmt.Sprintf("message encryption not supported for key type %v", p.Type)}
 	}
 
-	// Guard against a potentially invalid key type
-	switch p.Type {
-	case KeyType_AES256_GCM96:
-	default:
-		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
-	}
-
 	// Decode the plaintext value
 	plaintext, err := base64.StdEncoding.DecodeString(value)
 	if err != nil {
@@ -485,23 +557,10 @@ func (p *Policy) Encrypt(context, nonce []byte, value string) (string, error) {
 		return "", err
 	}
 
-	// Guard against a potentially invalid key type
-	switch p.Type {
-	case KeyType_AES256_GCM96:
-	default:
-		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
-	}
-
-	// Setup the cipher
-	aesCipher, err := aes.NewCipher(key)
-	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
-	}
-
-	// Setup the GCM AEAD
-	gcm, err := cipher.NewGCM(aesCipher)
+	// Setup the AEAD
+	gcm, err := p.aead(key)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return "", err
 	}
 
 	if p.ConvergentEncryption {
@@ -542,6 +601,32 @@ func (p *Policy) Encrypt(context, nonce []byte, value string) (string, error) {
 	return encoded, nil
 }
 
+// aead returns the AEAD of the encryption keys of the policy
+func (p *Policy) aead(key []byte) (cipher.AEAD, error) {
+	var aead cipher.AEAD
+	var err error
+	switch p.Type {
+	case KeyType_AES256_GCM96:
+		var aesCipher cipher.Block
+		aesCipher, err = aes.NewCipher(key)
+		if err != nil {
+			return nil, errutil.InternalError{Err: err.Error()}
+		}
+		aead, err = cipher.NewGCM(aesCipher)
+
+	case KeyType_ChaCha20_Poly1305:
+		aead, err = chacha20poly1305.New(key)
+
+	default:
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
+	}
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+
+	return aead, nil
+}
+
 func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 	if !p.Type.DecryptionSupported() {
 		return "", errutil.UserError{Err: fmt.Sprintf("message decryption not supported for key type %v", p.Type)}
@@ -586,29 +671,16 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 		return "", err
 	}
 
-	// Guard against a potentially invalid key type
-	switch p.Type {
-	case KeyType_AES256_GCM96:
-	default:
-		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
-	}
-
 	// Decode the base64
 	decoded, err := base64.StdEncoding.DecodeString(splitVerCiphertext[1])
 	if err != nil {
 		return "", errutil.UserError{Err: "invalid ciphertext: could not decode base64"}
 	}
 
-	// Setup the cipher
-	aesCipher, err := aes.NewCipher(key)
-	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
-	}
-
-	// Setup the GCM AEAD
-	gcm, err := cipher.NewGCM(aesCipher)
+	// Setup the AEAD
+	gcm, err := p.aead(key)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return "", err
 	}
 
 	// Extract the nonce and ciphertext
@@ -616,6 +688,9 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 	if p.ConvergentEncryption && p.ConvergentVersion < 2 {
 		ciphertext = decoded
 	} else {
+		if len(decoded) < gcm.NonceSize() {
+			return "", errutil.UserError{Err: "invalid ciphertext: too short"}
+		}
 		nonce = decoded[:gcm.NonceSize()]
 		ciphertext = decoded[gcm.NonceSize():]
 	}
@@ -629,6 +704,62 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 	return base64.StdEncoding.EncodeToString(plain), nil
 }
 
+// Encode encrypts the value with FF3-1 and the latest version of the key, so
+// that the result has the same length and alphabet as the value. Unlike the
+// ciphertexts of Encrypt, the result does not include the version of the key,
+// which is returned separately.
+func (p *Policy) Encode(alphabet, value string, tweak []byte) (string, int, error) {
+	c, err := p.ff3Cipher(p.LatestVersion, alphabet, tweak)
+	if err != nil {
+		return "", 0, err
+	}
+
+	encoded, err := c.Encrypt(value, tweak)
+	if err != nil {
+		return "", 0, errutil.UserError{Err: err.Error()}
+	}
+
+	return encoded, p.LatestVersion, nil
+}
+
+// Decode decrypts a value encoded with the given version of the key
+func (p *Policy) Decode(ver int, alphabet, value string, tweak []byte) (string, error) {
+	if p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion {
+		return "", errutil.UserError{Err: ErrTooOld}
+	}
+
+	c, err := p.ff3Cipher(ver, alphabet, tweak)
+	if err != nil {
+		return "", err
+	}
+
+	decoded, err := c.Decrypt(value, tweak)
+	if err != nil {
+		return "", errutil.UserError{Err: err.Error()}
+	}
+
+	return decoded, nil
+}
+
+func (p *Policy) ff3Cipher(ver int, alphabet string, tweak []byte) (*ff3Cipher, error) {
+	if !p.Type.EncodingSupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("format-preserving encryption not supported for key type %v", p.Type)}
+	}
+	if ver <= 0 || ver > p.LatestVersion {
+		return nil, errutil.UserError{Err: "invalid key version"}
+	}
+	if len(tweak) != FF3TweakSize {
+		return nil, errutil.UserError{Err: fmt.Sprintf("tweak must be %d bytes long", FF3TweakSize)}
+	}
+
+	c, err := newFF3Cipher(p.Keys[ver].AESKey, alphabet)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+
+	return c, nil
+}
+
 func (p *Policy) HMACKey(version int) ([]byte, error) {
 	if version < p.MinDecryptionVersion {
 		return nil, fmt.Errorf("key version disallowed by policy (minimum is %d)", p.MinDecryptionVersion)
@@ -645,7 +776,10 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	return p.Keys[version].HMACKey, nil
 }
 
-func (p *Policy) Sign(hashedInput []byte) (string, error) {
+// Sign signs the input hashed with the hash function. The signature algorithm
+// selects the padding scheme of RSA keys, PSS if empty, and is ignored for
+// the other key types.
+func (p *Policy) Sign(hashedInput []byte, hash crypto.Hash, signatureAlgorithm string) (string, error) {
 	if !p.Type.SigningSupported() {
 		return "", fmt.Errorf("message signing not supported for key type %v", p.Type)
 	}
@@ -675,6 +809,22 @@ func (p *Policy) Sign(hashedInput []byte) (string, error) {
 		}
 		sig = marshaledSig
 
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		key := p.Keys[p.LatestVersion].RSAKey
+
+		var err error
+		switch signatureAlgorithm {
+		case SignatureAlgorithmRSAPSS, "":
+			sig, err = rsa.SignPSS(rand.Reader, key, hash, hashedInput, nil)
+		case SignatureAlgorithmRSAPKCS1v15:
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key, hash, hashedInput)
+		default:
+			return "", errutil.UserError{Err: fmt.Sprintf("unsupported signature algorithm %s", signatureAlgorithm)}
+		}
+		if err != nil {
+			return "", err
+		}
+
 	default:
 		return "", fmt.Errorf("unsupported key type %v", p.Type)
 	}
@@ -688,7 +838,9 @@ func (p *Policy) Sign(hashedInput []byte) (string, error) {
 	return encoded, nil
 }
 
-func (p *Policy) VerifySignature(hashedInput []byte, sig string) (bool, error) {
+// VerifySignature verifies the signature of the input hashed with the hash
+// function, with the same signature algorithm as Sign
+func (p *Policy) VerifySignature(hashedInput []byte, sig string, hash crypto.Hash, signatureAlgorithm string) (bool, error) {
 	if !p.Type.SigningSupported() {
 		return false, errutil.UserError{Err: fmt.Sprintf("message verification not supported for key type %v", p.Type)}
 	}
@@ -740,6 +892,25 @@ func (p *Policy) VerifySignature(hashedInput []byte, sig string) (bool, error) {
 		}
 
 		return ecdsa.Verify(key, hashedInput, ecdsaSig.R, ecdsaSig.S), nil
+
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		sigBytes, err := base64.StdEncoding.DecodeString(splitVerSig[1])
+		if err != nil {
+			return false, errutil.UserError{Err: "invalid base64 signature value"}
+		}
+
+		key := &p.Keys[ver].RSAKey.PublicKey
+		switch signatureAlgorithm {
+		case SignatureAlgorithmRSAPSS, "":
+			err = rsa.VerifyPSS(key, hash, hashedInput, sigBytes, nil)
+		case SignatureAlgorithmRSAPKCS1v15:
+			err = rsa.VerifyPKCS1v15(key, hash, hashedInput, sigBytes)
+		default:
+			return false, errutil.UserError{Err: fmt.Sprintf("unsupported signature algorithm %s", signatureAlgorithm)}
+		}
+
+		return err == nil, nil
+
 	default:
 		return false, errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
@@ -747,36 +918,43 @@ func (p *Policy) VerifySignature(hashedInput []byte, sig string) (bool, error) {
 	return false, errutil.InternalError{Err: "no valid key type found"}
 }
 
-func (p *Policy) Rotate(storage logical.Storage) error {
-	if p.Keys == nil {
-		// This is an initial key rotation when generating a new policy. We
-		// don't need to call migrate here because if we've called getPolicy to
-		// get the policy in the first place it will have been run.
-		p.Keys = keyEntryMap{}
+// Rotate adds a new version to the policy, whose key material is generated
+// with randReader, or crypto/rand if it is nil
+func (p *Policy) Rotate(storage logical.Storage, randReader io.Reader) error {
+	if randReader == nil {
+		randReader = rand.Reader
 	}
-
-	p.LatestVersion += 1
 	entry := KeyEntry{
 		CreationTime: time.Now().Unix(),
 	}
 
-	hmacKey, err := uuid.GenerateRandomBytes(32)
-	if err != nil {
+	hmacKey := make([]byte, 32)
+	if _, err := io.ReadFull(randReader, hmacKey); err != nil {
 		return err
 	}
 	entry.HMACKey = hmacKey
 
+	var err error
 	switch p.Type {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_AES256_FF3_1, KeyType_ChaCha20_Poly1305:
 		// Generate a 256bit key
-		newKey, err := uuid.GenerateRandomBytes(32)
-		if err != nil {
+		newKey := make([]byte, 32)
+		if _, err := io.ReadFull(randReader, newKey); err != nil {
 			return err
 		}
 		entry.AESKey = newKey
 
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		entry.RSAKey, err = rsa.GenerateKey(randReader, p.Type.RSAKeyBits())
+		if err != nil {
+			return err
+		}
+		if err := entry.formatRSAPublicKey(); err != nil {
+			return err
+		}
+
 	case KeyType_ECDSA_P256:
-		privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		privKey, err := ecdsa.GenerateKey(elliptic.P256(), randReader)
 		if err != nil {
 			return err
 		}
@@ -798,7 +976,100 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 		entry.FormattedPublicKey = string(pemBytes)
 	}
 
+	return p.addKeyEntry(storage, entry)
+}
+
+// Import adds a new version to the policy from externally generated key
+// material: the raw bytes of the key for AES keys, or a PKCS#8 DER encoded
+// private key for ECDSA keys
+func (p *Policy) Import(storage logical.Storage, key []byte) error {
+	entry := KeyEntry{
+		CreationTime: time.Now().Unix(),
+	}
+
+	hmacKey, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return err
+	}
+	entry.HMACKey = hmacKey
+
+	switch p.Type {
+	case KeyType_AES256_GCM96, KeyType_AES256_FF3_1, KeyType_ChaCha20_Poly1305:
+		if len(key) != 32 {
+			return errutil.UserError{Err: fmt.Sprintf("keys of type %s must be 32 bytes long, got %d bytes", p.Type, len(key))}
+		}
+		entry.AESKey = key
+
+	case KeyType_ECDSA_P256:
+		parsedKey, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("error parsing PKCS#8 private key: %s", err)}
+		}
+		privKey, ok := parsedKey.(*ecdsa.PrivateKey)
+		if !ok || privKey.Curve != elliptic.P256() {
+			return errutil.UserError{Err: "key is not an ECDSA P-256 private key"}
+		}
+		entry.EC_D = privKey.D
+		entry.EC_X = privKey.X
+		entry.EC_Y = privKey.Y
+		derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+		if err != nil {
+			return fmt.Errorf("error marshaling public key: %s", err)
+		}
+		entry.FormattedPublicKey = string(pem.EncodeToMemory(&pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: derBytes,
+		}))
+
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		parsedKey, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("error parsing PKCS#8 private key: %s", err)}
+		}
+		privKey, ok := parsedKey.(*rsa.PrivateKey)
+		if !ok || privKey.N.BitLen() != p.Type.RSAKeyBits() {
+			return errutil.UserError{Err: fmt.Sprintf("key is not a %d-bit RSA private key", p.Type.RSAKeyBits())}
+		}
+		entry.RSAKey = privKey
+		if err := entry.formatRSAPublicKey(); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported key type %v", p.Type)
+	}
+
+	p.Imported = true
+
+	return p.addKeyEntry(storage, entry)
+}
+
+// formatRSAPublicKey sets the PEM encoded public key of an RSA key
+func (k *KeyEntry) formatRSAPublicKey() error {
+	derBytes, err := x509.MarshalPKIXPublicKey(k.RSAKey.Public())
+	if err != nil {
+		return fmt.Errorf("error marshaling public key: %s", err)
+	}
+	k.FormattedPublicKey = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}))
+	return nil
+}
+
+// addKeyEntry adds the key as the latest version of the policy and persists
+// it
+func (p *Policy) addKeyEntry(storage logical.Storage, entry KeyEntry) error {
+	if p.Keys == nil {
+		// This is an initial key rotation when generating a new policy. We
+		// don't need to call migrate here because if we've called getPolicy to
+		// get the policy in the first place it will have been run.
+		p.Keys = keyEntryMap{}
+	}
+
+	p.LatestVersion += 1
 	p.Keys[p.LatestVersion] = entry
+	p.scheduleRotation(time.Unix(entry.CreationTime, 0))
 
 	// This ensures that with new key creations min decryption version is set
 	// to 1 rather than the int default of 0, since keys start at 1 (either
@@ -810,6 +1081,36 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 	return p.Persist(storage)
 }
 
+// SetAutoRotatePeriod sets the period of the automatic rotations of the key,
+// scheduling the next one from the creation of the latest key version. A zero
+// period disables them.
+func (p *Policy) SetAutoRotatePeriod(period time.Duration) {
+	p.AutoRotatePeriod = period
+	p.scheduleRotation(time.Unix(p.Keys[p.LatestVersion].CreationTime, 0))
+}
+
+func (p *Policy) scheduleRotation(from time.Time) {
+	if p.AutoRotatePeriod <= 0 {
+		p.NextRotationTime = 0
+		return
+	}
+
+	jitter := time.Duration(0)
+	if maxJitter := p.AutoRotatePeriod / 10; maxJitter > 0 {
+		if maxJitter > maxAutoRotateJitter {
+			maxJitter = maxAutoRotateJitter
+		}
+		jitter = time.Duration(mathrand.Int63n(int64(maxJitter)))
+	}
+
+	p.NextRotationTime = from.Add(p.AutoRotatePeriod + jitter).Unix()
+}
+
+// NeedsAutoRotation returns true if the automatic rotation of the key is due
+func (p *Policy) NeedsAutoRotation(now time.Time) bool {
+	return p.AutoRotatePeriod > 0 && p.NextRotationTime > 0 && now.Unix() >= p.NextRotationTime
+}
+
 func (p *Policy) MigrateKeyToKeysMap() {
 	p.Keys = keyEntryMap{
 		1: KeyEntry{
@@ -89,7 +89,7 @@ func testArchivingUpgradeCommon(t *testing.T, lm *LockManager) {
 	checkKeys(t, p, storage, "initial", 1, 1, 1)
 
 	for i := 2; i <= 10; i++ {
-		err = p.Rotate(storage)
+		err = p.Rotate(storage, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -223,7 +223,7 @@ func testArchivingCommon(t *testing.T, lm *LockManager) {
 	checkKeys(t, p, storage, "initial", 1, 1, 1)
 
 	for i := 2; i <= 10; i++ {
-		err = p.Rotate(storage)
+		err = p.Rotate(storage, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -341,3 +341,80 @@ func checkKeys(t *testing.T,
 		}
 	}
 }
+
+func Test_Trimming(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	lm := NewLockManager(false)
+	p, lock, _, err := lm.GetPolicyUpsert(PolicyRequest{
+		Storage: storage,
+		KeyType: KeyType_AES256_GCM96,
+		Name:    "test",
+	})
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil {
+		t.Fatal("nil policy")
+	}
+
+	keys := []KeyEntry{KeyEntry{}, p.Keys[1]}
+	for i := 2; i <= 10; i++ {
+		if err := p.Rotate(storage, nil); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, p.Keys[i])
+	}
+
+	checkArchive := func(minVersion int) {
+		archive, err := p.LoadArchive(storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ArchiveMinVersion != minVersion || len(archive.Keys) != p.LatestVersion-minVersion+1 {
+			t.Fatalf("bad: archive min version %d with %d keys, expected %d", p.ArchiveMinVersion, len(archive.Keys), minVersion)
+		}
+		for i, entry := range archive.Keys {
+			if !reflect.DeepEqual(entry, keys[i+minVersion]) {
+				t.Fatalf("bad: archived key version %d", i+minVersion)
+			}
+		}
+	}
+
+	// Versions newer than the minimum decryption version cannot be trimmed
+	p.MinAvailableVersion = 5
+	if err := p.Persist(storage); err == nil {
+		t.Fatal("expected error trimming versions newer than the minimum decryption version")
+	}
+
+	p.MinDecryptionVersion = 6
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	checkArchive(5)
+
+	// The archive keeps working with the offset after rotations and moving
+	// the minimum decryption version back down
+	if err := p.Rotate(storage, nil); err != nil {
+		t.Fatal(err)
+	}
+	keys = append(keys, p.Keys[11])
+	checkArchive(5)
+
+	p.MinDecryptionVersion = 5
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Keys) != 7 || !reflect.DeepEqual(p.Keys[5], keys[5]) {
+		t.Fatalf("bad: keys after moving the minimum decryption version down: %d", len(p.Keys))
+	}
+
+	p.MinDecryptionVersion = 9
+	p.MinAvailableVersion = 8
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	checkArchive(8)
+}
@@ -0,0 +1,141 @@
+package keysutil
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/errutil"
+)
+
+// Chunked encryption of large payloads. Each stream is encrypted with its own
+// key, derived from a version of the policy key and a random salt carried in
+// the stream header. Every chunk is encrypted with a fresh random nonce,
+// prepended to its ciphertext, and authenticated along with the header, its
+// index and a flag marking the final chunk, so that reordered, dropped or
+// truncated chunks and chunks of other streams fail to decrypt. The header is
+// chosen by the caller, so the nonces are never derived from it: encrypting
+// again under the header of another stream, or retrying a chunk with other
+// data, never reuses a nonce.
+
+const (
+	streamHeaderPrefix = "stream:"
+	streamSaltSize     = 16
+	streamKeyInfo      = "vault-transit-stream"
+)
+
+// NewStreamHeader returns the header of a new stream encrypted with the
+// latest version of the key
+func (p *Policy) NewStreamHeader() (string, error) {
+	if !p.Type.EncryptionSupported() {
+		return "", errutil.UserError{Err: fmt.Sprintf("message encryption not supported for key type %v", p.Type)}
+	}
+
+	salt, err := uuid.GenerateRandomBytes(streamSaltSize)
+	if err != nil {
+		return "", errutil.InternalError{Err: err.Error()}
+	}
+
+	return "vault:v" + strconv.Itoa(p.LatestVersion) + ":" + streamHeaderPrefix + base64.StdEncoding.EncodeToString(salt), nil
+}
+
+// EncryptChunk encrypts the chunk at the index of the stream
+func (p *Policy) EncryptChunk(context []byte, header string, index uint64, final bool, plaintext []byte) ([]byte, error) {
+	aead, err := p.streamAEAD(context, header)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := uuid.GenerateRandomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, streamAdditionalData(header, index, final)), nil
+}
+
+// DecryptChunk decrypts the chunk at the index of the stream. The final flag
+// must be set for the last chunk only, which the caller must check to detect
+// truncated streams.
+func (p *Policy) DecryptChunk(context []byte, header string, index uint64, final bool, ciphertext []byte) ([]byte, error) {
+	aead, err := p.streamAEAD(context, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errutil.UserError{Err: "invalid ciphertext: too short"}
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], streamAdditionalData(header, index, final))
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+	}
+
+	return plaintext, nil
+}
+
+// streamAEAD returns the cipher of the stream from its header
+func (p *Policy) streamAEAD(context []byte, header string) (cipher.AEAD, error) {
+	if !p.Type.EncryptionSupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("message encryption not supported for key type %v", p.Type)}
+	}
+
+	// Verify the prefix
+	if !strings.HasPrefix(header, "vault:v") {
+		return nil, errutil.UserError{Err: "invalid stream header: no prefix"}
+	}
+	splitVerHeader := strings.SplitN(strings.TrimPrefix(header, "vault:v"), ":", 2)
+	if len(splitVerHeader) != 2 || !strings.HasPrefix(splitVerHeader[1], streamHeaderPrefix) {
+		return nil, errutil.UserError{Err: "invalid stream header: wrong number of fields"}
+	}
+	ver, err := strconv.Atoi(splitVerHeader[0])
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid stream header: version number could not be decoded"}
+	}
+	if ver > p.LatestVersion {
+		return nil, errutil.UserError{Err: "invalid stream header: version is too new"}
+	}
+	if p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion {
+		return nil, errutil.UserError{Err: ErrTooOld}
+	}
+	salt, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(splitVerHeader[1], streamHeaderPrefix))
+	if err != nil || len(salt) != streamSaltSize {
+		return nil, errutil.UserError{Err: "invalid stream header: salt could not be decoded"}
+	}
+
+	key, err := p.DeriveKey(context, ver)
+	if err != nil {
+		return nil, err
+	}
+
+	streamKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte(streamKeyInfo)), streamKey); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error deriving stream key: %v", err)}
+	}
+
+	return p.aead(streamKey)
+}
+
+// streamAdditionalData returns the data authenticated along with the chunk at
+// the index of a stream
+func streamAdditionalData(header string, index uint64, final bool) []byte {
+	data := make([]byte, 0, len(header)+9)
+	data = append(data, header...)
+
+	var position [9]byte
+	binary.BigEndian.PutUint64(position[:8], index)
+	if final {
+		position[8] = 1
+	}
+	return append(data, position[:]...)
+}
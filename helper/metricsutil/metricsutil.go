@@ -0,0 +1,120 @@
+package metricsutil
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// JSONMetricsFormat returns the metrics of the in-memory sink
+	JSONMetricsFormat = "json"
+
+	// PrometheusMetricsFormat returns the metrics of the Prometheus sink
+	PrometheusMetricsFormat = "prometheus"
+)
+
+// MetricsHelper gives access to the metrics sinks the server reads back
+type MetricsHelper struct {
+	inmem      *metrics.InmemSink
+	prometheus *PrometheusSink
+}
+
+// NewMetricsHelper returns a helper reading the given sinks. The Prometheus
+// sink may be nil if it is disabled.
+func NewMetricsHelper(inmem *metrics.InmemSink, prometheus *PrometheusSink) *MetricsHelper {
+	return &MetricsHelper{
+		inmem:      inmem,
+		prometheus: prometheus,
+	}
+}
+
+// ResponseForFormat returns the metrics in the given format, JSON by default
+func (m *MetricsHelper) ResponseForFormat(format string) (*logical.Response, error) {
+	switch format {
+	case "", JSONMetricsFormat:
+		return m.jsonResponse(), nil
+	case PrometheusMetricsFormat:
+		if m.prometheus == nil {
+			return logical.ErrorResponse("prometheus metrics are not enabled"), logical.ErrInvalidRequest
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPContentType: PrometheusContentType,
+				logical.HTTPRawBody:     m.prometheus.Format(),
+				logical.HTTPStatusCode:  http.StatusOK,
+			},
+		}, nil
+	}
+	return logical.ErrorResponse(fmt.Sprintf("unsupported metrics format %q", format)), logical.ErrInvalidRequest
+}
+
+// jsonResponse returns the metrics of the current interval of the in-memory
+// sink
+func (m *MetricsHelper) jsonResponse() *logical.Response {
+	gauges := []map[string]interface{}{}
+	counters := []map[string]interface{}{}
+	samples := []map[string]interface{}{}
+
+	var timestamp string
+	if data := m.inmem.Data(); len(data) > 0 {
+		interval := data[len(data)-1]
+		interval.RLock()
+		timestamp = interval.Interval.UTC().Format(time.RFC3339)
+		for _, name := range sortedKeys(interval.Gauges) {
+			gauges = append(gauges, map[string]interface{}{
+				"name":  name,
+				"value": interval.Gauges[name],
+			})
+		}
+		for _, name := range sortedKeys(interval.Counters) {
+			counters = append(counters, aggregateSampleData(name, interval.Counters[name]))
+		}
+		for _, name := range sortedKeys(interval.Samples) {
+			samples = append(samples, aggregateSampleData(name, interval.Samples[name]))
+		}
+		interval.RUnlock()
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"timestamp": timestamp,
+			"gauges":    gauges,
+			"counters":  counters,
+			"samples":   samples,
+		},
+	}
+}
+
+func aggregateSampleData(name string, s *metrics.AggregateSample) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   name,
+		"count":  s.Count,
+		"sum":    s.Sum,
+		"min":    s.Min,
+		"max":    s.Max,
+		"mean":   s.Mean(),
+		"stddev": s.Stddev(),
+	}
+}
+
+// sortedKeys returns the keys of a map of metrics, sorted
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]float32:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*metrics.AggregateSample:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
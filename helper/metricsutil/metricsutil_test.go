@@ -0,0 +1,63 @@
+package metricsutil
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestMetricsHelper_ResponseForFormat(t *testing.T) {
+	inm := metrics.NewInmemSink(10*time.Second, time.Minute)
+	inm.SetGauge([]string{"vault", "gauge"}, 7)
+	inm.IncrCounter([]string{"vault", "counter"}, 2)
+	inm.AddSample([]string{"vault", "sample"}, 3)
+	prom := NewPrometheusSink(time.Hour)
+	prom.SetGauge([]string{"vault", "gauge"}, 7)
+
+	m := NewMetricsHelper(inm, prom)
+
+	for _, format := range []string{"", JSONMetricsFormat} {
+		resp, err := m.ResponseForFormat(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gauges := resp.Data["gauges"].([]map[string]interface{})
+		if len(gauges) != 1 || gauges[0]["name"] != "vault.gauge" || gauges[0]["value"] != float32(7) {
+			t.Fatalf("bad: %#v", gauges)
+		}
+		counters := resp.Data["counters"].([]map[string]interface{})
+		if len(counters) != 1 || counters[0]["sum"] != float64(2) {
+			t.Fatalf("bad: %#v", counters)
+		}
+		samples := resp.Data["samples"].([]map[string]interface{})
+		if len(samples) != 1 || samples[0]["count"] != 1 {
+			t.Fatalf("bad: %#v", samples)
+		}
+		if resp.Data["timestamp"] == "" {
+			t.Fatal("missing timestamp")
+		}
+	}
+
+	resp, err := m.ResponseForFormat(PrometheusMetricsFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data[logical.HTTPContentType] != PrometheusContentType ||
+		resp.Data[logical.HTTPStatusCode] != http.StatusOK ||
+		string(resp.Data[logical.HTTPRawBody].([]byte)) != "# TYPE vault_gauge gauge\nvault_gauge 7\n" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	if _, err := m.ResponseForFormat("xml"); err != logical.ErrInvalidRequest {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The Prometheus format is an error when the sink is disabled
+	m = NewMetricsHelper(inm, nil)
+	if _, err := m.ResponseForFormat(PrometheusMetricsFormat); err != logical.ErrInvalidRequest {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package metricsutil
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PrometheusContentType is the content type of the Prometheus text
+	// exposition format
+	PrometheusContentType = "text/plain; version=0.0.4"
+
+	// DefaultPrometheusRetention is how long the metrics which are no longer
+	// updated are exposed by default
+	DefaultPrometheusRetention = 24 * time.Hour
+)
+
+// promSeries is the state of a metric exposed to Prometheus
+type promSeries struct {
+	value       float64
+	count       int64
+	lastUpdated time.Time
+}
+
+// PrometheusSink is a metrics.MetricSink keeping the metrics in the form
+// Prometheus scrapes them: the gauges keep their last value, the counters and
+// the sums and counts of the samples accumulate since the start. The metrics
+// not updated within the retention time are dropped.
+type PrometheusSink struct {
+	retention time.Duration
+
+	l        sync.Mutex
+	gauges   map[string]*promSeries
+	counters map[string]*promSeries
+	samples  map[string]*promSeries
+}
+
+// NewPrometheusSink returns a sink dropping the metrics not updated within
+// the given retention time
+func NewPrometheusSink(retention time.Duration) *PrometheusSink {
+	return &PrometheusSink{
+		retention: retention,
+		gauges:    make(map[string]*promSeries),
+		counters:  make(map[string]*promSeries),
+		samples:   make(map[string]*promSeries),
+	}
+}
+
+func (s *PrometheusSink) SetGauge(key []string, val float32) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	series := s.series(s.gauges, key)
+	series.value = float64(val)
+}
+
+// EmitKey records the key/value pairs as gauges, since Prometheus only sees
+// the last value between two scrapes
+func (s *PrometheusSink) EmitKey(key []string, val float32) {
+	s.SetGauge(key, val)
+}
+
+func (s *PrometheusSink) IncrCounter(key []string, val float32) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	series := s.series(s.counters, key)
+	series.value += float64(val)
+}
+
+func (s *PrometheusSink) AddSample(key []string, val float32) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	series := s.series(s.samples, key)
+	series.value += float64(val)
+	series.count++
+}
+
+// series returns the series of the key, creating it if needed, and marks it
+// as updated
+func (s *PrometheusSink) series(m map[string]*promSeries, key []string) *promSeries {
+	name := prometheusName(key)
+	series, ok := m[name]
+	if !ok {
+		series = &promSeries{}
+		m[name] = series
+	}
+	series.lastUpdated = time.Now()
+	return series
+}
+
+// Format returns the metrics in the Prometheus text exposition format
+func (s *PrometheusSink) Format() []byte {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	var buf bytes.Buffer
+	now := time.Now()
+	for _, kind := range []struct {
+		typ    string
+		series map[string]*promSeries
+	}{
+		{"gauge", s.gauges},
+		{"counter", s.counters},
+		{"summary", s.samples},
+	} {
+		for _, name := range s.liveNames(kind.series, now) {
+			series := kind.series[name]
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", name, kind.typ)
+			if kind.typ == "summary" {
+				fmt.Fprintf(&buf, "%s_sum %g\n%s_count %d\n", name, series.value, name, series.count)
+			} else {
+				fmt.Fprintf(&buf, "%s %g\n", name, series.value)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// liveNames drops the expired series and returns the names of the others,
+// sorted
+func (s *PrometheusSink) liveNames(m map[string]*promSeries, now time.Time) []string {
+	names := make([]string, 0, len(m))
+	for name, series := range m {
+		if s.retention > 0 && now.Sub(series.lastUpdated) > s.retention {
+			delete(m, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prometheusName joins the parts of a key into a valid Prometheus metric
+// name, replacing the invalid characters with underscores
+func prometheusName(key []string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, strings.Join(key, "_"))
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package metricsutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusSink_Format(t *testing.T) {
+	s := NewPrometheusSink(time.Hour)
+	s.SetGauge([]string{"vault", "expire", "num_leases"}, 5)
+	s.SetGauge([]string{"vault", "expire", "num_leases"}, 3)
+	s.IncrCounter([]string{"vault", "route", "create", "secret-"}, 1)
+	s.IncrCounter([]string{"vault", "route", "create", "secret-"}, 2)
+	s.AddSample([]string{"vault", "core", "handle_request"}, 1.5)
+	s.AddSample([]string{"vault", "core", "handle_request"}, 2.5)
+
+	expected := strings.Join([]string{
+		"# TYPE vault_expire_num_leases gauge",
+		"vault_expire_num_leases 3",
+		"# TYPE vault_route_create_secret_ counter",
+		"vault_route_create_secret_ 3",
+		"# TYPE vault_core_handle_request summary",
+		"vault_core_handle_request_sum 4",
+		"vault_core_handle_request_count 2",
+		"",
+	}, "\n")
+	if actual := string(s.Format()); actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestPrometheusSink_Retention(t *testing.T) {
+	s := NewPrometheusSink(time.Hour)
+	s.IncrCounter([]string{"old"}, 1)
+	s.IncrCounter([]string{"new"}, 1)
+	s.counters["old"].lastUpdated = time.Now().Add(-2 * time.Hour)
+
+	if actual := string(s.Format()); strings.Contains(actual, "old") || !strings.Contains(actual, "new 1") {
+		t.Fatalf("bad:\n%s", actual)
+	}
+	if _, ok := s.counters["old"]; ok {
+		t.Fatal("expired series should be dropped")
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	cases := map[string][]string{
+		"vault_core_unseal":    {"vault", "core", "unseal"},
+		"vault_host_name_test": {"vault", "host.name-test"},
+		"_1st":                 {"1st"},
+	}
+	for expected, key := range cases {
+		if actual := prometheusName(key); actual != expected {
+			t.Fatalf("%v: expected %q, got %q", key, expected, actual)
+		}
+	}
+}
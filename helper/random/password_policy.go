@@ -0,0 +1,214 @@
+package random
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+const (
+	// MinLength and MaxLength bound the length of the generated passwords
+	MinLength = 4
+	MaxLength = 100
+)
+
+// PasswordPolicy describes how passwords are generated: their length and
+// the characters they are made of. It is parsed from HCL such as:
+//
+//	length = 20
+//	rule "charset" {
+//	  charset = "abcdefghijklmnopqrstuvwxyz"
+//	  min-chars = 1
+//	}
+//	rule "charset" {
+//	  charset = "0123456789"
+//	  min-chars = 1
+//	}
+//
+// The passwords are made of the characters of all the charsets, and contain
+// at least min-chars characters of each of them.
+type PasswordPolicy struct {
+	Length int            `hcl:"length"`
+	Rules  []*CharsetRule `hcl:"-"`
+
+	// charset is the union of the charsets of the rules
+	charset []rune
+}
+
+// CharsetRule requires the passwords to contain at least MinChars characters
+// of Charset
+type CharsetRule struct {
+	Charset  string `hcl:"charset"`
+	MinChars int    `hcl:"min-chars"`
+}
+
+// ParsePolicy parses and validates the HCL or JSON definition of a password
+// policy
+func ParsePolicy(raw string) (*PasswordPolicy, error) {
+	root, err := hcl.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse password policy: %s", err)
+	}
+
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse password policy: does not contain a root object")
+	}
+
+	if err := checkHCLKeys(list, []string{"length", "rule"}); err != nil {
+		return nil, fmt.Errorf("failed to parse password policy: %s", err)
+	}
+
+	var p PasswordPolicy
+	if err := hcl.DecodeObject(&p, list); err != nil {
+		return nil, fmt.Errorf("failed to parse password policy: %s", err)
+	}
+
+	for _, item := range list.Filter("rule").Items {
+		ruleType := ""
+		if len(item.Keys) > 0 {
+			ruleType = item.Keys[0].Token.Value().(string)
+		}
+		if ruleType != "charset" {
+			return nil, fmt.Errorf("failed to parse password policy: unknown rule type %q", ruleType)
+		}
+		if err := checkHCLKeys(item.Val, []string{"charset", "min-chars"}); err != nil {
+			return nil, fmt.Errorf("failed to parse password policy: rule %q: %s", ruleType, err)
+		}
+
+		var rule CharsetRule
+		if err := hcl.DecodeObject(&rule, item.Val); err != nil {
+			return nil, fmt.Errorf("failed to parse password policy: rule %q: %s", ruleType, err)
+		}
+		p.Rules = append(p.Rules, &rule)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *PasswordPolicy) validate() error {
+	if p.Length < MinLength || p.Length > MaxLength {
+		return fmt.Errorf("length must be between %d and %d", MinLength, MaxLength)
+	}
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("at least one charset rule is required")
+	}
+
+	seen := make(map[rune]struct{})
+	minChars := 0
+	for _, rule := range p.Rules {
+		if rule.Charset == "" {
+			return fmt.Errorf("charset rules cannot have an empty charset")
+		}
+		if rule.MinChars < 0 {
+			return fmt.Errorf("min-chars cannot be negative")
+		}
+		minChars += rule.MinChars
+
+		for _, r := range rule.Charset {
+			if _, ok := seen[r]; !ok {
+				seen[r] = struct{}{}
+				p.charset = append(p.charset, r)
+			}
+		}
+	}
+	if minChars > p.Length {
+		return fmt.Errorf("the min-chars of the rules add up to %d, more than the length of %d", minChars, p.Length)
+	}
+
+	return nil
+}
+
+// Generate returns a new password satisfying the policy, using the given
+// source of randomness, or crypto/rand if nil
+func (p *PasswordPolicy) Generate(rng io.Reader) (string, error) {
+	if rng == nil {
+		rng = rand.Reader
+	}
+
+	// First pick the characters required by each rule, then fill the
+	// password from the characters of all the rules, and shuffle the result
+	// so that the required characters are not at predictable positions
+	password := make([]rune, 0, p.Length)
+	for _, rule := range p.Rules {
+		charset := []rune(rule.Charset)
+		for i := 0; i < rule.MinChars; i++ {
+			r, err := pick(rng, charset)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, r)
+		}
+	}
+	for len(password) < p.Length {
+		r, err := pick(rng, p.charset)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, r)
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(rng, i+1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+func pick(rng io.Reader, charset []rune) (rune, error) {
+	i, err := randomInt(rng, len(charset))
+	if err != nil {
+		return 0, err
+	}
+	return charset[i], nil
+}
+
+// randomInt returns a uniform random integer in [0, n)
+func randomInt(rng io.Reader, n int) (int, error) {
+	i, err := rand.Int(rng, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key '%s' on line %d", key, item.Assign.Line))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,95 @@
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy_Generate(t *testing.T) {
+	policy, err := ParsePolicy(`
+length = 12
+rule "charset" {
+  charset = "abcdefghijklmnopqrstuvwxyz"
+}
+rule "charset" {
+  charset = "0123456789"
+  min-chars = 3
+}
+rule "charset" {
+  charset = "!@#"
+  min-chars = 1
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		password, err := policy.Generate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 12 || seen[password] {
+			t.Fatalf("bad: password %q", password)
+		}
+		seen[password] = true
+
+		digits, symbols := 0, 0
+		for _, r := range password {
+			switch {
+			case strings.ContainsRune("0123456789", r):
+				digits++
+			case strings.ContainsRune("!@#", r):
+				symbols++
+			case !strings.ContainsRune("abcdefghijklmnopqrstuvwxyz", r):
+				t.Fatalf("bad: password %q contains %q", password, r)
+			}
+		}
+		if digits < 3 || symbols < 1 {
+			t.Fatalf("bad: password %q", password)
+		}
+	}
+
+	// Unicode charsets are supported
+	policy, err = ParsePolicy(`{"length": 8, "rule": {"charset": {"charset": "αβγ"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := policy.Generate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len([]rune(password)) != 8 || strings.Trim(password, "αβγ") != "" {
+		t.Fatalf("bad: password %q", password)
+	}
+}
+
+func TestPasswordPolicy_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		`length = 20`,
+		`length = 3
+rule "charset" { charset = "abc" }`,
+		`length = 101
+rule "charset" { charset = "abc" }`,
+		`length = 20
+rule "charset" { charset = "" }`,
+		`length = 20
+rule "charset" { charset = "abc" min-chars = -1 }`,
+		`length = 4
+rule "charset" { charset = "abc" min-chars = 3 }
+rule "charset" { charset = "123" min-chars = 2 }`,
+		`length = 20
+rule "unknown" { charset = "abc" }`,
+		`length = 20
+rule "charset" { charset = "abc" max-chars = 3 }`,
+		`length = 20
+unknown = true
+rule "charset" { charset = "abc" }`,
+		`length = `,
+	} {
+		if _, err := ParsePolicy(raw); err == nil {
+			t.Fatalf("expected error parsing %q", raw)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package templateutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+const randomCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// UsernameData is the data available to the username templates of the
+// secret backends, as {{.DisplayName}} and {{.RoleName}}
+type UsernameData struct {
+	// DisplayName is the display name of the token requesting the credentials
+	DisplayName string
+
+	// RoleName is the name of the role the credentials are requested for
+	RoleName string
+}
+
+// usernameFuncs are the functions available to the username templates:
+//
+//	random N        N random alphanumeric characters
+//	uuid            a random UUID
+//	unix_time       the current unix time, in seconds
+//	truncate N s    the first N characters of s
+//	lowercase s     s in lower case
+//	uppercase s     s in upper case
+//	replace a b s   s with all the occurrences of a replaced with b
+var usernameFuncs = template.FuncMap{
+	"random":    randomString,
+	"uuid":      uuid.GenerateUUID,
+	"unix_time": func() int64 { return time.Now().Unix() },
+	"truncate": func(n int, s string) (string, error) {
+		if n < 0 {
+			return "", fmt.Errorf("cannot truncate to a negative length")
+		}
+		if len(s) > n {
+			s = s[:n]
+		}
+		return s, nil
+	},
+	"lowercase": strings.ToLower,
+	"uppercase": strings.ToUpper,
+	"replace": func(old, new, s string) string {
+		return strings.Replace(s, old, new, -1)
+	},
+}
+
+// ValidateUsernameTemplate checks that the username template parses and
+// renders to a non-empty username
+func ValidateUsernameTemplate(tmpl string) error {
+	_, err := RenderUsername(tmpl, UsernameData{
+		DisplayName: "token",
+		RoleName:    "role",
+	}, 0)
+	return err
+}
+
+// RenderUsername renders the username template with the given data. If
+// maxLength is positive, the username is truncated to maxLength characters.
+func RenderUsername(tmpl string, data UsernameData, maxLength int) (string, error) {
+	t, err := template.New("username").Funcs(usernameFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse username template: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render username template: %s", err)
+	}
+
+	username := strings.TrimSpace(buf.String())
+	if username == "" {
+		return "", fmt.Errorf("username template rendered an empty username")
+	}
+	if maxLength > 0 && len(username) > maxLength {
+		username = username[:maxLength]
+	}
+
+	return username, nil
+}
+
+func randomString(n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("cannot generate a negative number of random characters")
+	}
+
+	max := big.NewInt(int64(len(randomCharset)))
+	result := make([]byte, n)
+	for i := range result {
+		j, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = randomCharset[j.Int64()]
+	}
+
+	return string(result), nil
+}
@@ -0,0 +1,55 @@
+package templateutil
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRenderUsername(t *testing.T) {
+	data := UsernameData{
+		DisplayName: "Token-Alice",
+		RoleName:    "readonly",
+	}
+
+	cases := []struct {
+		tmpl      string
+		maxLength int
+		expected  string
+	}{
+		{`{{.RoleName}}-{{.DisplayName}}`, 0, `^readonly-Token-Alice$`},
+		{`v_{{.RoleName}}_{{lowercase .DisplayName | replace "-" "_"}}`, 0, `^v_readonly_token_alice$`},
+		{`{{uppercase (truncate 3 .RoleName)}}-{{random 8}}`, 0, `^REA-[a-zA-Z0-9]{8}$`},
+		{`{{.DisplayName}}-{{unix_time}}`, 0, `^Token-Alice-[0-9]+$`},
+		{`{{.DisplayName}}-{{uuid}}`, 16, `^Token-Alice-[0-9a-f]{4}$`},
+	}
+
+	for _, tc := range cases {
+		username, err := RenderUsername(tc.tmpl, data, tc.maxLength)
+		if err != nil {
+			t.Fatalf("template %q: %s", tc.tmpl, err)
+		}
+		if !regexp.MustCompile(tc.expected).MatchString(username) {
+			t.Fatalf("template %q: bad username %q", tc.tmpl, username)
+		}
+	}
+}
+
+func TestValidateUsernameTemplate(t *testing.T) {
+	if err := ValidateUsernameTemplate(`{{.RoleName}}-{{random 20}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tmpl := range []string{
+		``,
+		`  `,
+		`{{.RoleName`,
+		`{{.Unknown}}`,
+		`{{unknown}}`,
+		`{{random -1}}`,
+		`{{truncate -1 .RoleName}}`,
+	} {
+		if err := ValidateUsernameTemplate(tmpl); err == nil {
+			t.Fatalf("expected error validating %q", tmpl)
+		}
+	}
+}
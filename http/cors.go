@@ -0,0 +1,64 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/vault"
+)
+
+// allowedMethods are the methods the browsers may use on the API
+var allowedMethods = []string{
+	http.MethodDelete,
+	http.MethodGet,
+	http.MethodOptions,
+	http.MethodPost,
+	http.MethodPut,
+	"LIST",
+}
+
+// wrapCORSHandler enforces the CORS configuration of the core. Requests
+// from origins which are not allowed are rejected, and the preflight
+// requests of the allowed origins are answered without reaching the API.
+func wrapCORSHandler(h http.Handler, core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		config := core.CORSConfig()
+
+		// Requests which are not cross-origin, or with CORS disabled, are
+		// left to the same-origin policy of the browsers
+		if origin == "" || !config.Enabled {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		if !config.IsValidOrigin(origin) {
+			respondError(w, http.StatusForbidden, fmt.Errorf("origin not allowed"))
+			return
+		}
+
+		if req.Method == http.MethodOptions && !strutil.StrListContains(allowedMethods, req.Header.Get("Access-Control-Request-Method")) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if config.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// Answer the preflight requests
+		if req.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ","))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(config.AllowedHeadersList(), ","))
+			w.Header().Set("Access-Control-Max-Age", "300")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestHandler_CORS(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	request := func(method, origin string) *http.Response {
+		req, err := http.NewRequest(method, addr+"/v1/sys/config/cors", nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		req.Header.Set("X-Vault-Token", token)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		resp, err := cleanhttp.DefaultClient().Do(req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return resp
+	}
+
+	// Without a configuration, CORS is left to the browsers
+	resp := request(http.MethodGet, "https://app.example.com")
+	testResponseStatus(t, resp, 200)
+	if h := resp.Header.Get("Access-Control-Allow-Origin"); h != "" {
+		t.Fatalf("bad: %q", h)
+	}
+
+	// Credentials cannot be allowed for all origins
+	resp = testHttpPut(t, token, addr+"/v1/sys/config/cors", map[string]interface{}{
+		"allowed_origins":   "*",
+		"allow_credentials": true,
+	})
+	testResponseStatus(t, resp, 400)
+
+	resp = testHttpPut(t, token, addr+"/v1/sys/config/cors", map[string]interface{}{
+		"allowed_origins":   []string{"https://app.example.com"},
+		"allowed_headers":   "x-custom-header",
+		"allow_credentials": true,
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpGet(t, token, addr+"/v1/sys/config/cors")
+	testResponseStatus(t, resp, 200)
+	var actual map[string]interface{}
+	testResponseBody(t, resp, &actual)
+	expected := map[string]interface{}{
+		"enabled":           true,
+		"allowed_origins":   []interface{}{"https://app.example.com"},
+		"allowed_headers":   []interface{}{},
+		"allow_credentials": true,
+	}
+	for _, h := range append(vault.StdAllowedHeaders, "X-Custom-Header") {
+		expected["allowed_headers"] = append(expected["allowed_headers"].([]interface{}), h)
+	}
+	if !reflect.DeepEqual(actual["data"], expected) {
+		t.Fatalf("bad:\nexpected: %#v\nactual: %#v", expected, actual["data"])
+	}
+
+	// The preflight requests of the allowed origins are answered
+	resp = request(http.MethodOptions, "https://app.example.com")
+	testResponseStatus(t, resp, 204)
+	if h := resp.Header.Get("Access-Control-Allow-Origin"); h != "https://app.example.com" {
+		t.Fatalf("bad: %q", h)
+	}
+	if h := resp.Header.Get("Access-Control-Allow-Credentials"); h != "true" {
+		t.Fatalf("bad: %q", h)
+	}
+	if h := resp.Header.Get("Access-Control-Allow-Headers"); h == "" {
+		t.Fatalf("missing allowed headers")
+	}
+
+	resp = request(http.MethodGet, "https://app.example.com")
+	testResponseStatus(t, resp, 200)
+	if h := resp.Header.Get("Access-Control-Allow-Origin"); h != "https://app.example.com" {
+		t.Fatalf("bad: %q", h)
+	}
+
+	// Other origins are rejected
+	resp = request(http.MethodGet, "https://evil.example.com")
+	testResponseStatus(t, resp, 403)
+
+	// Deleting the configuration disables CORS
+	resp = testHttpDelete(t, token, addr+"/v1/sys/config/cors")
+	testResponseStatus(t, resp, 204)
+	resp = request(http.MethodGet, "https://evil.example.com")
+	testResponseStatus(t, resp, 200)
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedForConfig configures which proxies are trusted to report the
+// address of the clients in the X-Forwarded-For header
+type ForwardedForConfig struct {
+	// AuthorizedAddrs are the networks of the trusted proxies
+	AuthorizedAddrs []*net.IPNet
+
+	// HopSkips is the number of addresses to skip from the end of the
+	// header, for the trusted proxies which append their own address
+	HopSkips int
+
+	// RejectNotAuthorized rejects the requests carrying the header from
+	// other addresses, rather than ignoring the header
+	RejectNotAuthorized bool
+
+	// RejectNotPresent rejects the requests which do not carry the header,
+	// so that the listener is only reachable through the proxies
+	RejectNotPresent bool
+}
+
+// WrapForwardedForHandler replaces the remote address of the requests of
+// the trusted proxies with the client address of their X-Forwarded-For
+// header, so that the CIDR restrictions and the audit logs apply to the
+// clients rather than to the proxies
+func WrapForwardedForHandler(h http.Handler, config *ForwardedForConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers, ok := r.Header[http.CanonicalHeaderKey("X-Forwarded-For")]
+		if !ok {
+			if config.RejectNotPresent {
+				respondError(w, http.StatusForbidden, fmt.Errorf("missing x-forwarded-for header and configured to reject when not present"))
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		host, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("error parsing client address: %v", err))
+			return
+		}
+
+		authorized := false
+		if ip := net.ParseIP(host); ip != nil {
+			for _, n := range config.AuthorizedAddrs {
+				if n.Contains(ip) {
+					authorized = true
+					break
+				}
+			}
+		}
+		if !authorized {
+			if config.RejectNotAuthorized {
+				respondError(w, http.StatusForbidden, fmt.Errorf("client address not authorized for x-forwarded-for"))
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// The header may be repeated, and each one may hold a list of
+		// addresses, the last ones being appended by the closest proxies
+		var addrs []string
+		for _, header := range headers {
+			for _, addr := range strings.Split(header, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					addrs = append(addrs, addr)
+				}
+			}
+		}
+
+		index := len(addrs) - 1 - config.HopSkips
+		if index < 0 {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("malformed x-forwarded-for configuration or request, hops to skip would skip before the client address"))
+			return
+		}
+		if net.ParseIP(addrs[index]) == nil {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("invalid client address %q in x-forwarded-for header", addrs[index]))
+			return
+		}
+
+		r.RemoteAddr = net.JoinHostPort(addrs[index], port)
+		h.ServeHTTP(w, r)
+	})
+}
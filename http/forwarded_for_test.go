@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrapForwardedForHandler(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})
+
+	cases := map[string]struct {
+		config     ForwardedForConfig
+		remoteAddr string
+		headers    []string
+		status     int
+		addr       string
+	}{
+		"client of a proxy": {
+			config:     ForwardedForConfig{},
+			remoteAddr: "10.1.2.3:4567",
+			headers:    []string{"192.168.1.1"},
+			status:     200,
+			addr:       "192.168.1.1:4567",
+		},
+		"hop skipped": {
+			config:     ForwardedForConfig{HopSkips: 2},
+			remoteAddr: "10.1.2.3:4567",
+			headers:    []string{"192.168.1.1, 10.3.3.3", "10.2.2.2"},
+			status:     200,
+			addr:       "192.168.1.1:4567",
+		},
+		"too many hops": {
+			config:     ForwardedForConfig{HopSkips: 2},
+			remoteAddr: "10.1.2.3:4567",
+			headers:    []string{"192.168.1.1"},
+			status:     400,
+		},
+		"not a proxy": {
+			config:     ForwardedForConfig{},
+			remoteAddr: "172.16.1.1:4567",
+			headers:    []string{"192.168.1.1"},
+			status:     200,
+			addr:       "172.16.1.1:4567",
+		},
+		"not a proxy, rejected": {
+			config:     ForwardedForConfig{RejectNotAuthorized: true},
+			remoteAddr: "172.16.1.1:4567",
+			headers:    []string{"192.168.1.1"},
+			status:     403,
+		},
+		"missing header": {
+			config:     ForwardedForConfig{},
+			remoteAddr: "10.1.2.3:4567",
+			status:     200,
+			addr:       "10.1.2.3:4567",
+		},
+		"missing header, rejected": {
+			config:     ForwardedForConfig{RejectNotPresent: true},
+			remoteAddr: "10.1.2.3:4567",
+			status:     403,
+		},
+	}
+
+	for name, tc := range cases {
+		tc.config.AuthorizedAddrs = []*net.IPNet{proxies}
+		req := httptest.NewRequest("GET", "/v1/sys/health", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for _, h := range tc.headers {
+			req.Header.Add("X-Forwarded-For", h)
+		}
+
+		w := httptest.NewRecorder()
+		WrapForwardedForHandler(echo, &tc.config).ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Fatalf("%s: bad status: %d", name, w.Code)
+		}
+		if tc.status == 200 && w.Body.String() != tc.addr {
+			t.Fatalf("%s: bad address: %s", name, w.Body.String())
+		}
+	}
+}
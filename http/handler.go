@@ -32,9 +32,10 @@ const (
 	// not to use request forwarding
 	NoRequestForwardingHeaderName = "X-Vault-No-Request-Forwarding"
 
-	// MaxRequestSize is the maximum accepted request size. This is to prevent
-	// a denial of service attack where no Content-Length is provided and the server
-	// is fed ever more data until it exhausts memory.
+	// MaxRequestSize is the default maximum accepted request size. This is to
+	// prevent a denial of service attack where no Content-Length is provided and
+	// the server is fed ever more data until it exhausts memory. Listeners may
+	// set their own maximum with RequestLimits.
 	MaxRequestSize = 32 * 1024 * 1024
 )
 
@@ -72,7 +73,11 @@ func Handler(core *vault.Core) http.Handler {
 	// handler
 	genericWrappedHandler := wrapGenericHandler(helpWrappedHandler)
 
-	return genericWrappedHandler
+	// Wrap the generic handler with the enforcement of the CORS
+	// configuration, so that the preflight requests are answered first
+	corsWrappedHandler := wrapCORSHandler(genericWrappedHandler, core)
+
+	return corsWrappedHandler
 }
 
 // wrapGenericHandler wraps the handler with an extra layer of handler where
@@ -123,9 +128,9 @@ func stripPrefix(prefix, path string) (string, bool) {
 }
 
 func parseRequest(r *http.Request, w http.ResponseWriter, out interface{}) error {
-	// Limit the maximum number of bytes to MaxRequestSize to protect
-	// against an indefinite amount of data being read.
-	limit := http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	// Limit the maximum number of bytes to the maximum request size of the
+	// listener to protect against an indefinite amount of data being read.
+	limit := http.MaxBytesReader(w, r.Body, maxRequestSize(r))
 	err := jsonutil.DecodeJSONFromReader(limit, out)
 	if err != nil && err != io.EOF {
 		return errwrap.Wrapf("failed to parse JSON input: {{err}}", err)
@@ -82,7 +82,8 @@ func TestSysMounts_headerAuth(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"sys/": map[string]interface{}{
 				"description": "system endpoints used for control, policy and debugging",
@@ -92,7 +93,8 @@ func TestSysMounts_headerAuth(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"cubbyhole/": map[string]interface{}{
 				"description": "per-token private secret storage",
@@ -102,7 +104,8 @@ func TestSysMounts_headerAuth(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": true,
+				"local":     true,
+				"seal_wrap": false,
 			},
 		},
 		"secret/": map[string]interface{}{
@@ -113,7 +116,8 @@ func TestSysMounts_headerAuth(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"description": "system endpoints used for control, policy and debugging",
@@ -123,7 +127,8 @@ func TestSysMounts_headerAuth(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -133,7 +138,8 @@ func TestSysMounts_headerAuth(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 	testResponseStatus(t, resp, 200)
@@ -173,7 +179,8 @@ func TestSysMounts_headerAuth_Wrapped(t *testing.T) {
 		"lease_duration": json.Number("0"),
 		"data":           nil,
 		"wrap_info": map[string]interface{}{
-			"ttl": json.Number("60"),
+			"ttl":           json.Number("60"),
+			"creation_path": "sys/mounts",
 		},
 		"warnings": nil,
 		"auth":     nil,
@@ -2,8 +2,10 @@ package http
 
 import (
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -28,6 +30,7 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 
 	// Determine the operation
 	var op logical.Operation
+	var query url.Values
 	switch r.Method {
 	case "DELETE":
 		op = logical.DeleteOperation
@@ -45,6 +48,13 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 				op = logical.ListOperation
 			}
 		}
+
+		// The backends take the query parameters they declare as fields
+		if op == logical.ReadOperation {
+			query = queryVals
+		}
+	case "HEAD":
+		op = logical.HeadOperation
 	case "POST", "PUT":
 		op = logical.UpdateOperation
 	case "LIST":
@@ -61,7 +71,15 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 
 	// Parse the request if we can
 	var data map[string]interface{}
-	if op == logical.UpdateOperation {
+	if op == logical.UpdateOperation && isRawRequest(r) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize(r)))
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		data = map[string]interface{}{
+			logical.HTTPRawBody: body,
+		}
+	} else if op == logical.UpdateOperation {
 		err := parseRequest(r, w, &data)
 		if err == io.EOF {
 			data = nil
@@ -83,6 +101,7 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 		Operation:  op,
 		Path:       path,
 		Data:       data,
+		Query:      query,
 		Connection: getConnection(r),
 		Headers:    r.Header,
 	})
@@ -95,6 +114,16 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	return req, 0, nil
 }
 
+// isRawRequest returns true if the body of the request uses a binary format
+// that is passed as is to the backend rather than being parsed as JSON
+func isRawRequest(r *http.Request) bool {
+	switch r.Header.Get("Content-Type") {
+	case "application/ocsp-request", "application/pkcs10", "application/x-pki-message":
+		return true
+	}
+	return false
+}
+
 func handleLogical(core *vault.Core, dataOnly bool, prepareRequestCallback PrepareRequestFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		req, statusCode, err := buildLogicalRequest(core, w, r)
@@ -153,6 +182,8 @@ func respondLogical(w http.ResponseWriter, r *http.Request, req *logical.Request
 					TTL:             int(resp.WrapInfo.TTL.Seconds()),
 					CreationTime:    resp.WrapInfo.CreationTime.Format(time.RFC3339Nano),
 					WrappedAccessor: resp.WrapInfo.WrappedAccessor,
+					Accessor:        resp.WrapInfo.Accessor,
+					CreationPath:    resp.WrapInfo.CreationPath,
 				},
 			}
 		} else {
@@ -210,7 +241,7 @@ func respondRaw(w http.ResponseWriter, r *http.Request, resp *logical.Response)
 
 	// Get the content type header; don't require it if the body is empty
 	contentTypeRaw, ok := resp.Data[logical.HTTPContentType]
-	if !ok && !nonEmpty {
+	if !ok && nonEmpty {
 		retErr(w, "no content type given")
 		return
 	}
@@ -236,6 +267,20 @@ func respondRaw(w http.ResponseWriter, r *http.Request, resp *logical.Response)
 		}
 	}
 
+	// Get any additional headers
+	if headersRaw, ok := resp.Data[logical.HTTPRawHeaders]; ok {
+		headers, ok := headersRaw.(map[string][]string)
+		if !ok {
+			retErr(w, "cannot decode headers")
+			return
+		}
+		for name, values := range headers {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+	}
+
 	// Write the response
 	if contentType != "" {
 		w.Header().Set("Content-Type", contentType)
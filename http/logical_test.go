@@ -14,6 +14,7 @@ import (
 	log "github.com/mgutz/logxi/v1"
 
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/vault"
 )
@@ -152,6 +153,7 @@ func TestLogical_StandbyRedirect(t *testing.T) {
 			"ttl":              json.Number("0"),
 			"creation_ttl":     json.Number("0"),
 			"explicit_max_ttl": json.Number("0"),
+			"type":             "service",
 		},
 		"warnings":  nilWarnings,
 		"wrap_info": nil,
@@ -293,3 +295,29 @@ func TestLogical_ListSuffix(t *testing.T) {
 		t.Fatal("trailing slash not found on path")
 	}
 }
+
+func TestLogical_ReadQuery(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8200/v1/secret/foo?format=prometheus&key=a&key=b", nil)
+	lreq, _, err := buildLogicalRequest(core, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"format": []string{"prometheus"},
+		"key":    []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(lreq.Query, expected) || lreq.Data != nil {
+		t.Fatalf("bad: %#v", lreq)
+	}
+
+	// The query parameters are only passed to the reads
+	req, _ = http.NewRequest("GET", "http://127.0.0.1:8200/v1/secret/?list=true&format=prometheus", nil)
+	lreq, _, err = buildLogicalRequest(core, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lreq.Operation != logical.ListOperation || lreq.Query != nil {
+		t.Fatalf("bad: %#v", lreq)
+	}
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// maxRequestSizeContextKey is the context key of the maximum request size
+// of the listener a request was received on
+type maxRequestSizeContextKey struct{}
+
+// RequestLimits are the limits a listener applies to its requests
+type RequestLimits struct {
+	// MaxRequestSize is the maximum accepted request size in bytes. It
+	// defaults to MaxRequestSize if it is zero.
+	MaxRequestSize int64
+
+	// MaxRequestDuration is the duration after which the requests are
+	// answered with an error, or zero for no limit
+	MaxRequestDuration time.Duration
+}
+
+// WrapRequestLimitsHandler applies the limits of a listener to its requests
+func WrapRequestLimitsHandler(h http.Handler, limits *RequestLimits) http.Handler {
+	if limits.MaxRequestDuration > 0 {
+		h = http.TimeoutHandler(h, limits.MaxRequestDuration, `{"errors":["request exceeded the maximum duration"]}`)
+	}
+	if limits.MaxRequestSize == 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), maxRequestSizeContextKey{}, limits.MaxRequestSize)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// maxRequestSize returns the maximum size of the request, which is the one
+// of its listener if it sets one
+func maxRequestSize(r *http.Request) int64 {
+	if size, ok := r.Context().Value(maxRequestSizeContextKey{}).(int64); ok {
+		return size
+	}
+	return MaxRequestSize
+}
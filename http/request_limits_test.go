@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestWrapRequestLimitsHandler_size(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	server := httptest.NewServer(WrapRequestLimitsHandler(Handler(core), &RequestLimits{
+		MaxRequestSize: 1024,
+	}))
+	defer server.Close()
+	addr := server.URL
+
+	resp := testHttpPut(t, token, addr+"/v1/secret/foo", map[string]interface{}{
+		"data": strings.Repeat("a", 512),
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpPut(t, token, addr+"/v1/secret/foo", map[string]interface{}{
+		"data": strings.Repeat("a", 2048),
+	})
+	testResponseStatus(t, resp, 413)
+}
+
+func TestWrapRequestLimitsHandler_duration(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(WrapRequestLimitsHandler(slow, &RequestLimits{
+		MaxRequestDuration: 50 * time.Millisecond,
+	}))
+	defer server.Close()
+
+	resp, err := cleanhttp.DefaultClient().Get(server.URL + "/v1/sys/health")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testResponseStatus(t, resp, 503)
+}
@@ -312,6 +312,7 @@ func TestSysGenerateRoot_Update_OTP(t *testing.T) {
 		"ttl":              json.Number("0"),
 		"path":             "auth/token/root",
 		"explicit_max_ttl": json.Number("0"),
+		"type":             "service",
 	}
 
 	resp = testHttpGet(t, newRootToken, addr+"/v1/auth/token/lookup-self")
@@ -401,6 +402,7 @@ func TestSysGenerateRoot_Update_PGP(t *testing.T) {
 		"ttl":              json.Number("0"),
 		"path":             "auth/token/root",
 		"explicit_max_ttl": json.Number("0"),
+		"type":             "service",
 	}
 
 	resp = testHttpGet(t, newRootToken, addr+"/v1/auth/token/lookup-self")
@@ -144,6 +144,12 @@ func getSysHealth(core *vault.Core, r *http.Request) (int, *HealthResponse, erro
 		ClusterName:   clusterName,
 		ClusterID:     clusterID,
 	}
+	if restoring, restored, total := core.LeaseRestoreProgress(); restoring {
+		body.LeaseRestore = &HealthLeaseRestore{
+			Restored: restored,
+			Total:    total,
+		}
+	}
 	return code, body, nil
 }
 
@@ -155,4 +161,14 @@ type HealthResponse struct {
 	Version       string `json:"version"`
 	ClusterName   string `json:"cluster_name,omitempty"`
 	ClusterID     string `json:"cluster_id,omitempty"`
+
+	// LeaseRestore is only set while the leases are being restored
+	LeaseRestore *HealthLeaseRestore `json:"lease_restore,omitempty"`
+}
+
+// HealthLeaseRestore is the progress of the restore of the leases after
+// unseal
+type HealthLeaseRestore struct {
+	Restored int64 `json:"restored"`
+	Total    int64 `json:"total"`
 }
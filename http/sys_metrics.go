@@ -0,0 +1,33 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+// WrapUnauthenticatedMetricsHandler serves sys/metrics without requiring a
+// token, for the listeners scraped by monitoring systems
+func WrapUnauthenticatedMetricsHandler(h http.Handler, core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/metrics" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != "GET" {
+			respondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "sys/metrics",
+		}
+		resp, err := core.MetricsResponse(r.URL.Query().Get("format"))
+		if respondErrorCommon(w, req, resp, err) {
+			return
+		}
+		respondLogical(w, r, req, false, resp)
+	})
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestWrapUnauthenticatedMetricsHandler(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+	handler := WrapUnauthenticatedMetricsHandler(Handler(core), core)
+
+	// sys/metrics is answered without a token, here that the test core does
+	// not collect metrics
+	req, _ := http.NewRequest("GET", "/v1/sys/metrics?format=prometheus", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("bad: %d %s", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest("PUT", "/v1/sys/metrics", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("bad: %d", w.Code)
+	}
+
+	// The other paths still require a token
+	req, _ = http.NewRequest("GET", "/v1/sys/mounts", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest && w.Code != http.StatusForbidden {
+		t.Fatalf("bad: %d", w.Code)
+	}
+}
@@ -34,7 +34,8 @@ func TestSysMounts(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"sys/": map[string]interface{}{
 				"description": "system endpoints used for control, policy and debugging",
@@ -44,7 +45,8 @@ func TestSysMounts(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"cubbyhole/": map[string]interface{}{
 				"description": "per-token private secret storage",
@@ -54,7 +56,8 @@ func TestSysMounts(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": true,
+				"local":     true,
+				"seal_wrap": false,
 			},
 		},
 		"secret/": map[string]interface{}{
@@ -65,7 +68,8 @@ func TestSysMounts(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"description": "system endpoints used for control, policy and debugging",
@@ -75,7 +79,8 @@ func TestSysMounts(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -85,7 +90,8 @@ func TestSysMounts(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 	testResponseStatus(t, resp, 200)
@@ -127,7 +133,8 @@ func TestSysMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"secret/": map[string]interface{}{
 				"description": "generic secret storage",
@@ -137,7 +144,8 @@ func TestSysMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"sys/": map[string]interface{}{
 				"description": "system endpoints used for control, policy and debugging",
@@ -147,7 +155,8 @@ func TestSysMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"cubbyhole/": map[string]interface{}{
 				"description": "per-token private secret storage",
@@ -157,7 +166,8 @@ func TestSysMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": true,
+				"local":     true,
+				"seal_wrap": false,
 			},
 		},
 		"foo/": map[string]interface{}{
@@ -168,7 +178,8 @@ func TestSysMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"secret/": map[string]interface{}{
 			"description": "generic secret storage",
@@ -178,7 +189,8 @@ func TestSysMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"description": "system endpoints used for control, policy and debugging",
@@ -188,7 +200,8 @@ func TestSysMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -198,7 +211,8 @@ func TestSysMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 	testResponseStatus(t, resp, 200)
@@ -262,7 +276,8 @@ func TestSysRemount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"secret/": map[string]interface{}{
 				"description": "generic secret storage",
@@ -272,7 +287,8 @@ func TestSysRemount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"sys/": map[string]interface{}{
 				"description": "system endpoints used for control, policy and debugging",
@@ -282,7 +298,8 @@ func TestSysRemount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"cubbyhole/": map[string]interface{}{
 				"description": "per-token private secret storage",
@@ -292,7 +309,8 @@ func TestSysRemount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": true,
+				"local":     true,
+				"seal_wrap": false,
 			},
 		},
 		"bar/": map[string]interface{}{
@@ -303,7 +321,8 @@ func TestSysRemount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"secret/": map[string]interface{}{
 			"description": "generic secret storage",
@@ -313,7 +332,8 @@ func TestSysRemount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"description": "system endpoints used for control, policy and debugging",
@@ -323,7 +343,8 @@ func TestSysRemount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -333,7 +354,8 @@ func TestSysRemount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 	testResponseStatus(t, resp, 200)
@@ -378,7 +400,8 @@ func TestSysUnmount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"sys/": map[string]interface{}{
 				"description": "system endpoints used for control, policy and debugging",
@@ -388,7 +411,8 @@ func TestSysUnmount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"cubbyhole/": map[string]interface{}{
 				"description": "per-token private secret storage",
@@ -398,7 +422,8 @@ func TestSysUnmount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": true,
+				"local":     true,
+				"seal_wrap": false,
 			},
 		},
 		"secret/": map[string]interface{}{
@@ -409,7 +434,8 @@ func TestSysUnmount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"description": "system endpoints used for control, policy and debugging",
@@ -419,7 +445,8 @@ func TestSysUnmount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -429,7 +456,8 @@ func TestSysUnmount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 	testResponseStatus(t, resp, 200)
@@ -471,7 +499,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"secret/": map[string]interface{}{
 				"description": "generic secret storage",
@@ -481,7 +510,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"sys/": map[string]interface{}{
 				"description": "system endpoints used for control, policy and debugging",
@@ -491,7 +521,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"cubbyhole/": map[string]interface{}{
 				"description": "per-token private secret storage",
@@ -501,7 +532,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": true,
+				"local":     true,
+				"seal_wrap": false,
 			},
 		},
 		"foo/": map[string]interface{}{
@@ -512,7 +544,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"secret/": map[string]interface{}{
 			"description": "generic secret storage",
@@ -522,7 +555,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"description": "system endpoints used for control, policy and debugging",
@@ -532,7 +566,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -542,7 +577,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 	testResponseStatus(t, resp, 200)
@@ -605,7 +641,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("259200000"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"secret/": map[string]interface{}{
 				"description": "generic secret storage",
@@ -615,7 +652,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"sys/": map[string]interface{}{
 				"description": "system endpoints used for control, policy and debugging",
@@ -625,7 +663,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": false,
+				"local":     false,
+				"seal_wrap": false,
 			},
 			"cubbyhole/": map[string]interface{}{
 				"description": "per-token private secret storage",
@@ -635,7 +674,8 @@ func TestSysTuneMount(t *testing.T) {
 					"max_lease_ttl":     json.Number("0"),
 					"force_no_cache":    false,
 				},
-				"local": true,
+				"local":     true,
+				"seal_wrap": false,
 			},
 		},
 		"foo/": map[string]interface{}{
@@ -646,7 +686,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("259200000"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"secret/": map[string]interface{}{
 			"description": "generic secret storage",
@@ -656,7 +697,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"description": "system endpoints used for control, policy and debugging",
@@ -666,7 +708,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -676,7 +719,8 @@ func TestSysTuneMount(t *testing.T) {
 				"max_lease_ttl":     json.Number("0"),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 
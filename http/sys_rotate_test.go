@@ -43,6 +43,16 @@ func TestSysRotate(t *testing.T) {
 	expected["data"].(map[string]interface{})["install_time"] = actualInstallTime
 	expected["install_time"] = actualInstallTime
 
+	// The encryptions depend on the requests made so far
+	for _, key := range []string{"encryptions", "next_rotation_time"} {
+		value, ok := actual["data"].(map[string]interface{})[key]
+		if !ok {
+			t.Fatalf("%s missing in data", key)
+		}
+		expected["data"].(map[string]interface{})[key] = value
+		expected[key] = value
+	}
+
 	expected["request_id"] = actual["request_id"]
 
 	if !reflect.DeepEqual(actual, expected) {
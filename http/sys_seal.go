@@ -120,10 +120,16 @@ func handleSysUnseal(core *vault.Core) http.Handler {
 				}
 			}
 
-			// Attempt the unseal
-			if _, err := core.Unseal(key); err != nil {
+			// Attempt the unseal, migrating the seal if requested
+			unseal := core.Unseal
+			if req.Migrate {
+				unseal = core.UnsealMigrate
+			}
+			if _, err := unseal(key); err != nil {
 				switch {
 				case errwrap.ContainsType(err, new(vault.ErrInvalidKey)):
+				case errwrap.Contains(err, vault.ErrSealMigrationRequired.Error()):
+				case errwrap.Contains(err, vault.ErrNoSealMigration.Error()):
 				case errwrap.Contains(err, vault.ErrBarrierInvalidKey.Error()):
 				case errwrap.Contains(err, vault.ErrBarrierNotInit.Error()):
 				case errwrap.Contains(err, vault.ErrBarrierSealed.Error()):
@@ -160,7 +166,14 @@ func handleSysSealStatusRaw(core *vault.Core, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	sealConfig, err := core.SealAccess().BarrierConfig()
+	// During a seal migration, the keys of the previous seal are entered
+	migration := core.SealMigrationInProgress()
+	var sealConfig *vault.SealConfig
+	if migration {
+		sealConfig, err = core.SealMigrationKeyConfig()
+	} else {
+		sealConfig, err = core.SealAccess().BarrierConfig()
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
@@ -195,6 +208,7 @@ func handleSysSealStatusRaw(core *vault.Core, w http.ResponseWriter, r *http.Req
 		N:           sealConfig.SecretShares,
 		Progress:    progress,
 		Nonce:       nonce,
+		Migration:   migration,
 		Version:     version.GetVersion().VersionNumber(),
 		ClusterName: clusterName,
 		ClusterID:   clusterID,
@@ -207,12 +221,14 @@ type SealStatusResponse struct {
 	N           int    `json:"n"`
 	Progress    int    `json:"progress"`
 	Nonce       string `json:"nonce"`
+	Migration   bool   `json:"migration,omitempty"`
 	Version     string `json:"version"`
 	ClusterName string `json:"cluster_name,omitempty"`
 	ClusterID   string `json:"cluster_id,omitempty"`
 }
 
 type UnsealRequest struct {
-	Key   string
-	Reset bool
+	Key     string
+	Reset   bool
+	Migrate bool
 }
@@ -131,6 +131,9 @@ func TestHTTP_Wrapping(t *testing.T) {
 		if secret.Data["creation_time"].(string) != wrapInfo.CreationTime.Format(time.RFC3339Nano) {
 			t.Fatalf("mistmatched creation times: %d vs %d", secret.Data["creation_time"].(string), wrapInfo.CreationTime.Format(time.RFC3339Nano))
 		}
+		if secret.Data["creation_path"].(string) != "secret/foo" || wrapInfo.CreationPath != "secret/foo" {
+			t.Fatalf("bad creation paths: %s vs %s", secret.Data["creation_path"], wrapInfo.CreationPath)
+		}
 	}
 
 	//
@@ -281,6 +284,9 @@ func TestHTTP_Wrapping(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if secret.Data != nil || secret.WrapInfo.CreationPath != "sys/wrapping/wrap" {
+		t.Fatalf("bad: %#v %#v", secret.Data, secret.WrapInfo)
+	}
 	secret, err = client.Logical().Unwrap(secret.WrapInfo.Token)
 	if err != nil {
 		t.Fatal(err)
@@ -315,6 +321,20 @@ func TestHTTP_Wrapping(t *testing.T) {
 		t.Fatal("expected err")
 	}
 
+	// The rewrapped token keeps the creation path
+	if secret.WrapInfo.CreationPath != "secret/foo" {
+		t.Fatalf("bad creation path: %s", secret.WrapInfo.CreationPath)
+	}
+	lookup, err := client.Logical().Write("sys/wrapping/lookup", map[string]interface{}{
+		"token": secret.WrapInfo.Token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookup.Data["creation_path"] != "secret/foo" {
+		t.Fatalf("bad creation path: %v", lookup.Data["creation_path"])
+	}
+
 	// Attempt unwrapping the rewrapped token
 	wrapToken := secret.WrapInfo.Token
 	secret, err = client.Logical().Unwrap(wrapToken)
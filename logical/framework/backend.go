@@ -1,8 +1,10 @@
 package framework
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"regexp"
 	"sort"
@@ -13,6 +15,7 @@ import (
 	log "github.com/mgutz/logxi/v1"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/entropy"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/logformat"
@@ -181,8 +184,16 @@ func (b *Backend) HandleRequest(req *logical.Request) (*logical.Response, error)
 	}
 
 	// Build up the data for the route, with the URL taking priority
-	// for the fields over the PUT data.
+	// for the fields over the PUT data, and the PUT data over the query
+	// parameters of the reads.
 	raw := make(map[string]interface{}, len(path.Fields))
+	if req.Operation == logical.ReadOperation {
+		for k, schema := range path.Fields {
+			if v := req.Query[k]; schema.Query && len(v) > 0 {
+				raw[k] = v[0]
+			}
+		}
+	}
 	for k, v := range req.Data {
 		raw[k] = v
 	}
@@ -269,6 +280,16 @@ func (b *Backend) System() logical.SystemView {
 	return b.system
 }
 
+// GetRandomReader returns the source of randomness to use to generate key
+// material. If the system view has access to an external entropy source,
+// its randomness is mixed with crypto/rand; otherwise crypto/rand is used.
+func (b *Backend) GetRandomReader() io.Reader {
+	if sourcer, ok := b.system.(entropy.Sourcer); ok {
+		return entropy.NewReader(sourcer)
+	}
+	return rand.Reader
+}
+
 // This method takes in the TTL and MaxTTL values provided by the user,
 // compares those with the SystemView values. If they are empty a value of 0 is
 // set, which will cause initial secret or LeaseExtend operations to use the
@@ -530,6 +551,10 @@ type FieldSchema struct {
 	Type        FieldType
 	Default     interface{}
 	Description string
+
+	// Query, if set, allows the field to be given as a query parameter of
+	// read requests. The request data and the URL take priority over it.
+	Query bool
 }
 
 // DefaultOrZero returns the default value if it is set, or otherwise
@@ -587,6 +612,10 @@ func (t FieldType) Zero() interface{} {
 		return map[string]interface{}{}
 	case TypeDurationSecond:
 		return 0
+	case TypeCommaStringSlice:
+		return []string{}
+	case TypeSlice:
+		return []interface{}{}
 	default:
 		panic("unknown type: " + t.String())
 	}
@@ -77,6 +77,76 @@ func TestBackendHandleRequest(t *testing.T) {
 	}
 }
 
+func TestBackendHandleRequest_query(t *testing.T) {
+	callback := func(req *logical.Request, data *FieldData) (*logical.Response, error) {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"value": data.Get("value"),
+				"other": data.Get("other"),
+			},
+		}, nil
+	}
+
+	b := &Backend{
+		Paths: []*Path{
+			&Path{
+				Pattern: "foo/bar",
+				Fields: map[string]*FieldSchema{
+					"value": &FieldSchema{Type: TypeInt, Query: true},
+					"other": &FieldSchema{Type: TypeString},
+				},
+				Callbacks: map[logical.Operation]OperationFunc{
+					logical.ReadOperation:   callback,
+					logical.UpdateOperation: callback,
+				},
+			},
+		},
+	}
+
+	// Only the declared query parameters are taken, and only by the reads
+	query := map[string][]string{
+		"value": []string{"42"},
+		"other": []string{"foo"},
+	}
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "foo/bar",
+		Query:     query,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.Data["value"] != 42 || resp.Data["other"] != "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "foo/bar",
+		Query:     query,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.Data["value"] != 0 {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The request data takes priority
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "foo/bar",
+		Data:      map[string]interface{}{"value": "7"},
+		Query:     query,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.Data["value"] != 7 {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
 func TestBackendHandleRequest_badwrite(t *testing.T) {
 	callback := func(req *logical.Request, data *FieldData) (*logical.Response, error) {
 		return &logical.Response{
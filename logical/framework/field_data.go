@@ -3,6 +3,7 @@ package framework
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/mitchellh/mapstructure"
@@ -30,7 +31,7 @@ func (d *FieldData) Validate() error {
 		}
 
 		switch schema.Type {
-		case TypeBool, TypeInt, TypeMap, TypeDurationSecond, TypeString:
+		case TypeBool, TypeInt, TypeMap, TypeDurationSecond, TypeString, TypeCommaStringSlice, TypeSlice:
 			_, _, err := d.getPrimitive(field, schema)
 			if err != nil {
 				return fmt.Errorf("Error converting input %v for field %s: %s", value, field, err)
@@ -105,7 +106,7 @@ func (d *FieldData) GetOkErr(k string) (interface{}, bool, error) {
 	}
 
 	switch schema.Type {
-	case TypeBool, TypeInt, TypeMap, TypeDurationSecond, TypeString:
+	case TypeBool, TypeInt, TypeMap, TypeDurationSecond, TypeString, TypeCommaStringSlice, TypeSlice:
 		return d.getPrimitive(k, schema)
 	default:
 		return nil, false,
@@ -177,6 +178,37 @@ func (d *FieldData) getPrimitive(
 		}
 		return result, true, nil
 
+	case TypeCommaStringSlice:
+		var result []string
+		config := &mapstructure.DecoderConfig{
+			Result:           &result,
+			WeaklyTypedInput: true,
+			DecodeHook:       mapstructure.StringToSliceHookFunc(","),
+		}
+		decoder, err := mapstructure.NewDecoder(config)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := decoder.Decode(raw); err != nil {
+			return nil, false, err
+		}
+
+		// Drop the spaces around the separators and the empty values
+		trimmed := make([]string, 0, len(result))
+		for _, v := range result {
+			if v = strings.TrimSpace(v); v != "" {
+				trimmed = append(trimmed, v)
+			}
+		}
+		return trimmed, true, nil
+
+	case TypeSlice:
+		var result []interface{}
+		if err := mapstructure.WeakDecode(raw, &result); err != nil {
+			return nil, true, err
+		}
+		return result, true, nil
+
 	default:
 		panic(fmt.Sprintf("Unknown type: %s", schema.Type))
 	}
@@ -146,6 +146,57 @@ func TestFieldDataGet(t *testing.T) {
 			"foo",
 			0,
 		},
+
+		"comma string slice type, comma string value": {
+			map[string]*FieldSchema{
+				"foo": &FieldSchema{Type: TypeCommaStringSlice},
+			},
+			map[string]interface{}{
+				"foo": "a, b,,c",
+			},
+			"foo",
+			[]string{"a", "b", "c"},
+		},
+
+		"comma string slice type, slice value": {
+			map[string]*FieldSchema{
+				"foo": &FieldSchema{Type: TypeCommaStringSlice},
+			},
+			map[string]interface{}{
+				"foo": []interface{}{"a", "b"},
+			},
+			"foo",
+			[]string{"a", "b"},
+		},
+
+		"comma string slice type, not supplied": {
+			map[string]*FieldSchema{
+				"foo": &FieldSchema{Type: TypeCommaStringSlice},
+			},
+			map[string]interface{}{},
+			"foo",
+			[]string{},
+		},
+
+		"slice type, slice value": {
+			map[string]*FieldSchema{
+				"foo": &FieldSchema{Type: TypeSlice},
+			},
+			map[string]interface{}{
+				"foo": []interface{}{map[string]interface{}{"a": "b"}, "c"},
+			},
+			"foo",
+			[]interface{}{map[string]interface{}{"a": "b"}, "c"},
+		},
+
+		"slice type, not supplied": {
+			map[string]*FieldSchema{
+				"foo": &FieldSchema{Type: TypeSlice},
+			},
+			map[string]interface{}{},
+			"foo",
+			[]interface{}{},
+		},
 	}
 
 	for name, tc := range cases {
@@ -13,6 +13,14 @@ const (
 	// TypeDurationSecond represent as seconds, this can be either an
 	// integer or go duration format string (e.g. 24h)
 	TypeDurationSecond
+
+	// TypeCommaStringSlice represent a list of strings, this can be either
+	// an array or a comma separated string
+	TypeCommaStringSlice
+
+	// TypeSlice represent a list of values of any type, e.g. the items of a
+	// batch request
+	TypeSlice
 )
 
 func (t FieldType) String() string {
@@ -27,6 +35,10 @@ func (t FieldType) String() string {
 		return "map"
 	case TypeDurationSecond:
 		return "duration (sec)"
+	case TypeCommaStringSlice:
+		return "comma-separated string slice"
+	case TypeSlice:
+		return "slice"
 	default:
 		return "unknown type"
 	}
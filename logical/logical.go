@@ -79,4 +79,9 @@ type Paths struct {
 	// LocalStorage are paths (prefixes) that are local to this instance; this
 	// indicates that these paths should not be replicated
 	LocalStorage []string
+
+	// SealWrapStorage are storage paths (prefixes) holding sensitive values,
+	// such as private keys, which are also encrypted by the seal when the
+	// mount enables seal wrapping
+	SealWrapStorage []string
 }
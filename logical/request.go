@@ -52,6 +52,11 @@ type Request struct {
 	// to represent the auth that was returned prior.
 	Auth *Auth `json:"auth" structs:"auth" mapstructure:"auth"`
 
+	// Query will contain the query parameters of the http request of a read.
+	// The backends only take the parameters they declare, see
+	// framework.FieldSchema.
+	Query map[string][]string `json:"query" structs:"query" mapstructure:"query"`
+
 	// Headers will contain the http headers from the request. This value will
 	// be used in the audit broker to ensure we are auditing only the allowed
 	// headers.
@@ -77,6 +82,18 @@ type Request struct {
 	// name, but is useful for operators.
 	DisplayName string `json:"display_name" structs:"display_name" mapstructure:"display_name"`
 
+	// ClientTokenMeta is the metadata attached by the credential backend to
+	// the token issued at login that the client token is, or was created
+	// from. It is provided to the logical backend so that information about
+	// the authenticated identity can be used when handling the request. The
+	// metadata of tokens created through the token store is never used.
+	ClientTokenMeta map[string]string `json:"client_token_meta" structs:"client_token_meta" mapstructure:"client_token_meta"`
+
+	// LoginDisplayName is the display name of the same login token as
+	// ClientTokenMeta. It is empty when the client token does not descend
+	// from a login.
+	LoginDisplayName string `json:"login_display_name" structs:"login_display_name" mapstructure:"login_display_name"`
+
 	// MountPoint is provided so that a logical backend can generate
 	// paths relative to itself. The `Path` is effectively the client
 	// request path with the MountPoint trimmed off.
@@ -85,6 +102,18 @@ type Request struct {
 	// WrapInfo contains requested response wrapping parameters
 	WrapInfo *RequestWrapInfo `json:"wrap_info" structs:"wrap_info" mapstructure:"wrap_info"`
 
+	// ControlGroupAccessor is set when a request held by a control group is
+	// replayed once authorized. It is the accessor of the token returned to
+	// the requester.
+	ControlGroupAccessor string `json:"control_group_accessor" structs:"control_group_accessor" mapstructure:"control_group_accessor"`
+
+	// AuditNonHMACRequestKeys and AuditNonHMACResponseKeys are the keys of
+	// the request and response data the audit backends log without hashing.
+	// They are set by the core from the configuration of the mount handling
+	// the request.
+	AuditNonHMACRequestKeys  []string `json:"-"`
+	AuditNonHMACResponseKeys []string `json:"-"`
+
 	// For replication, contains the last WAL on the remote side after handling
 	// the request, used for best-effort avoidance of stale read-after-write
 	lastRemoteWAL uint64
@@ -172,6 +201,10 @@ const (
 	ListOperation             = "list"
 	HelpOperation             = "help"
 
+	// HeadOperation is only served by the paths that register it, such as
+	// the new-nonce endpoint of the ACME server of the PKI backend
+	HeadOperation Operation = "head"
+
 	// The operations below are called globally, the path is less relevant.
 	RevokeOperation   Operation = "revoke"
 	RenewOperation              = "renew"
@@ -192,4 +225,12 @@ var (
 
 	// ErrPermissionDenied is returned if the client is not authorized
 	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrRateLimitQuotaExceeded is returned if the request exceeds a rate
+	// limit quota
+	ErrRateLimitQuotaExceeded = errors.New("rate limit quota exceeded")
+
+	// ErrLeaseCountQuotaExceeded is returned if the request may create a
+	// lease past a lease count quota
+	ErrLeaseCountQuotaExceeded = errors.New("lease count quota exceeded")
 )
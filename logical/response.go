@@ -20,12 +20,20 @@ const (
 	// HTTPRawBody is the raw content of the HTTP body that goes with the HTTPContentType.
 	// This can only be specified for non-secrets, and should should be similarly
 	// avoided like the HTTPContentType. The value must be a byte slice.
+	// It also holds the body of requests using a binary content type, such
+	// as OCSP requests, which the HTTP front end doesn't parse as JSON.
 	HTTPRawBody = "http_raw_body"
 
 	// HTTPStatusCode is the response code of the HTTP body that goes with the HTTPContentType.
 	// This can only be specified for non-secrets, and should should be similarly
 	// avoided like the HTTPContentType. The value must be an integer.
 	HTTPStatusCode = "http_status_code"
+
+	// HTTPRawHeaders holds additional headers to set on a raw response that
+	// uses HTTPStatusCode. This can only be specified for non-secrets, and
+	// should be similarly avoided like the HTTPContentType. The value must be
+	// a map[string][]string.
+	HTTPRawHeaders = "http_raw_headers"
 )
 
 type ResponseWrapInfo struct {
@@ -44,6 +52,14 @@ type ResponseWrapInfo struct {
 	// created token's accessor will be accessible here
 	WrappedAccessor string `json:"wrapped_accessor" structs:"wrapped_accessor" mapstructure:"wrapped_accessor"`
 
+	// The accessor of the wrapping token, set for the requests held by
+	// control groups so that they can be authorized by their accessor
+	Accessor string `json:"accessor" structs:"accessor" mapstructure:"accessor"`
+
+	// The path of the request whose response is wrapped. Rewrapping keeps
+	// the path of the original request.
+	CreationPath string `json:"creation_path" structs:"creation_path" mapstructure:"creation_path"`
+
 	// The format to use. This doesn't get returned, it's only internal.
 	Format string `json:"format" structs:"format" mapstructure:"format"`
 }
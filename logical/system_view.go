@@ -1,9 +1,11 @@
 package logical
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/random"
 )
 
 // SystemView exposes system configuration information in a safe way
@@ -37,6 +39,10 @@ type SystemView interface {
 
 	// ReplicationState indicates the state of cluster replication
 	ReplicationState() consts.ReplicationState
+
+	// GeneratePasswordFromPolicy generates a password satisfying the named
+	// password policy, configured in sys/policies/password
+	GeneratePasswordFromPolicy(policyName string) (string, error)
 }
 
 type StaticSystemView struct {
@@ -47,6 +53,7 @@ type StaticSystemView struct {
 	CachingDisabledVal  bool
 	Primary             bool
 	ReplicationStateVal consts.ReplicationState
+	PasswordPolicies    map[string]string
 }
 
 func (d StaticSystemView) DefaultLeaseTTL() time.Duration {
@@ -72,3 +79,17 @@ func (d StaticSystemView) CachingDisabled() bool {
 func (d StaticSystemView) ReplicationState() consts.ReplicationState {
 	return d.ReplicationStateVal
 }
+
+func (d StaticSystemView) GeneratePasswordFromPolicy(policyName string) (string, error) {
+	raw, ok := d.PasswordPolicies[policyName]
+	if !ok {
+		return "", fmt.Errorf("password policy %q not found", policyName)
+	}
+
+	policy, err := random.ParsePolicy(raw)
+	if err != nil {
+		return "", err
+	}
+
+	return policy.Generate(nil)
+}
@@ -92,6 +92,8 @@ type HTTPWrapInfo struct {
 	TTL             int    `json:"ttl"`
 	CreationTime    string `json:"creation_time"`
 	WrappedAccessor string `json:"wrapped_accessor,omitempty"`
+	Accessor        string `json:"accessor,omitempty"`
+	CreationPath    string `json:"creation_path,omitempty"`
 }
 
 type HTTPSysInjector struct {
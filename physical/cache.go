@@ -1,7 +1,6 @@
 package physical
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/hashicorp/golang-lru"
@@ -19,11 +18,17 @@ const (
 // Vault are for policy objects so there is a large read reduction
 // by using a simple write-through cache.
 type Cache struct {
-	backend       Backend
+	backend Backend
+	lru     *lru.TwoQueueCache
+	locks   []*locksutil.LockEntry
+	logger  log.Logger
+}
+
+// TransactionalCache is a Cache of a Transactional backend, which passes
+// the transactions through to the backend.
+type TransactionalCache struct {
+	*Cache
 	transactional Transactional
-	lru           *lru.TwoQueueCache
-	locks         []*locksutil.LockEntry
-	logger        log.Logger
 }
 
 // NewCache returns a physical cache of the given size.
@@ -42,12 +47,16 @@ func NewCache(b Backend, size int, logger log.Logger) *Cache {
 		locks:   locksutil.CreateLocks(),
 		logger:  logger,
 	}
+	return c
+}
 
-	if txnl, ok := c.backend.(Transactional); ok {
-		c.transactional = txnl
+// NewTransactionalCache returns a physical cache of the given size for a
+// backend which must implement Transactional.
+func NewTransactionalCache(b Backend, size int, logger log.Logger) *TransactionalCache {
+	return &TransactionalCache{
+		Cache:         NewCache(b, size, logger),
+		transactional: b.(Transactional),
 	}
-
-	return c
 }
 
 // Purge is used to clear the cache
@@ -128,11 +137,7 @@ func (c *Cache) List(prefix string) ([]string, error) {
 	return c.backend.List(prefix)
 }
 
-func (c *Cache) Transaction(txns []TxnEntry) error {
-	if c.transactional == nil {
-		return fmt.Errorf("physical/cache: underlying backend does not support transactions")
-	}
-
+func (c *TransactionalCache) Transaction(txns []TxnEntry) error {
 	// Lock the world
 	for _, lock := range c.locks {
 		lock.Lock()
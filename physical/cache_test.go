@@ -16,6 +16,42 @@ func TestCache(t *testing.T) {
 	testBackend_ListPrefix(t, cache)
 }
 
+func TestTransactionalCache(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	inm := NewTransactionalInmem(logger)
+	cache := NewTransactionalCache(inm, 0, logger)
+	testBackend(t, cache)
+	testBackend_ListPrefix(t, cache)
+
+	if err := cache.Put(&Entry{Key: "foo", Value: []byte("bar")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := cache.Get("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	err := cache.Transaction([]TxnEntry{
+		{Operation: PutOperation, Entry: &Entry{Key: "zip", Value: []byte("zap")}},
+		{Operation: DeleteOperation, Entry: &Entry{Key: "foo"}},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The cache reflects the transaction
+	if out, err := cache.Get("foo"); err != nil || out != nil {
+		t.Fatalf("bad: %#v %v", out, err)
+	}
+	out, err := cache.Get("zip")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || string(out.Value) != "zap" {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
 func TestCache_Purge(t *testing.T) {
 	logger := logformat.NewVaultLogger(log.LevelTrace)
 
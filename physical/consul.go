@@ -60,6 +60,10 @@ const (
 	// consistencyModeStrong is the configuration value used to tell
 	// consul to use strong consistency.
 	consistencyModeStrong = "strong"
+
+	// consulMaxTxnOps is the maximum number of operations Consul accepts in
+	// a single transaction
+	consulMaxTxnOps = 64
 )
 
 type notifyEvent struct{}
@@ -298,9 +302,13 @@ func setupTLSConfig(conf map[string]string) (*tls.Config, error) {
 
 // Used to run multiple entries via a transaction
 func (c *ConsulBackend) Transaction(txns []TxnEntry) error {
+	defer metrics.MeasureSince([]string{"consul", "transaction"}, time.Now())
 	if len(txns) == 0 {
 		return nil
 	}
+	if len(txns) > consulMaxTxnOps {
+		return fmt.Errorf("transaction of %d operations exceeds the maximum of %d", len(txns), consulMaxTxnOps)
+	}
 
 	ops := make([]*api.KVTxnOp, 0, len(txns))
 
@@ -321,6 +329,9 @@ func (c *ConsulBackend) Transaction(txns []TxnEntry) error {
 		ops = append(ops, cop)
 	}
 
+	c.permitPool.Acquire()
+	defer c.permitPool.Release()
+
 	ok, resp, _, err := c.kv.Txn(ops, nil)
 	if err != nil {
 		return err
@@ -3,17 +3,42 @@ package physical
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/mgutz/logxi/v1"
 
 	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-uuid"
 	"github.com/lib/pq"
 )
 
+const (
+	// The lock TTL matches the default that Consul API uses, 15 seconds.
+	PostgreSQLLockTTL = 15 * time.Second
+
+	// The amount of time to wait between the lock renewals
+	PostgreSQLLockRenewInterval = 5 * time.Second
+
+	// PostgreSQLLockRetryInterval is the amount of time to wait
+	// if a lock fails before trying again.
+	PostgreSQLLockRetryInterval = time.Second
+
+	// PostgreSQLWatchRetryMax is the number of times to re-try a
+	// failed watch before signaling that leadership is lost.
+	PostgreSQLWatchRetryMax = 5
+
+	// PostgreSQLWatchRetryInterval is the amount of time to wait
+	// if a watch fails before trying again.
+	PostgreSQLWatchRetryInterval = 5 * time.Second
+)
+
 // PostgreSQL Backend is a physical backend that stores data
-// within a PostgreSQL database.
+// within a PostgreSQL database. It can be run in high-availability
+// mode using a table of locks whose rows expire unless renewed.
 type PostgreSQLBackend struct {
 	table        string
 	client       *sql.DB
@@ -22,6 +47,28 @@ type PostgreSQLBackend struct {
 	delete_query string
 	list_query   string
 	logger       log.Logger
+
+	haEnabled       bool
+	ha_lock_query   string
+	ha_renew_query  string
+	ha_value_query  string
+	ha_unlock_query string
+}
+
+// PostgreSQLLock implements a lock using a row of the lock table,
+// which is held until its valid_until time unless renewed.
+type PostgreSQLLock struct {
+	backend    *PostgreSQLBackend
+	value, key string
+	identity   string
+	held       bool
+	lock       sync.Mutex
+	// stopCh is closed by Unlock to stop renewing and watching the lock
+	stopCh chan struct{}
+	// Allow modifying the Lock durations for ease of unit testing.
+	renewInterval      time.Duration
+	ttl                time.Duration
+	watchRetryInterval time.Duration
 }
 
 // newPostgreSQLBackend constructs a PostgreSQL backend using the given
@@ -63,6 +110,24 @@ func newPostgreSQLBackend(conf map[string]string, logger log.Logger) (Backend, e
 			" UPDATE SET (parent_path, path, key, value) = ($1, $2, $3, $4)"
 	}
 
+	haEnabled := false
+	if haEnabledStr, ok := conf["ha_enabled"]; ok {
+		haEnabled, err = strconv.ParseBool(haEnabledStr)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing ha_enabled parameter: {{err}}", err)
+		}
+	}
+	// The lock queries rely on the native upsert
+	if haEnabled && upsert_required {
+		return nil, fmt.Errorf("high availability requires PostgreSQL 9.5 or later")
+	}
+
+	unquoted_ha_table, ok := conf["ha_table"]
+	if !ok {
+		unquoted_ha_table = "vault_ha_locks"
+	}
+	quoted_ha_table := pq.QuoteIdentifier(unquoted_ha_table)
+
 	// Setup the backend.
 	m := &PostgreSQLBackend{
 		table:        quoted_table,
@@ -73,7 +138,23 @@ func newPostgreSQLBackend(conf map[string]string, logger log.Logger) (Backend, e
 		list_query: "SELECT key FROM " + quoted_table + " WHERE path = $1" +
 			"UNION SELECT DISTINCT substring(substr(path, length($1)+1) from '^.*?/') FROM " +
 			quoted_table + " WHERE parent_path LIKE concat($1, '%')",
-		logger: logger,
+		logger:    logger,
+		haEnabled: haEnabled,
+		// The lock is taken if it is free or expired, or renewed if it is
+		// held by the same identity. The time of the database is used so
+		// that the clocks of the Vault servers don't matter.
+		ha_lock_query: "INSERT INTO " + quoted_ha_table + " AS t (ha_identity, ha_key, ha_value, valid_until)" +
+			" VALUES ($1, $2, $3, now() + $4 * interval '1 second')" +
+			" ON CONFLICT (ha_key) DO" +
+			" UPDATE SET (ha_identity, ha_value, valid_until) = ($1, $3, now() + $4 * interval '1 second')" +
+			" WHERE t.valid_until < now() OR t.ha_identity = $1",
+		// Renewals never re-create the row, so that a lock released or
+		// taken over is not taken back by its former holder
+		ha_renew_query: "UPDATE " + quoted_ha_table +
+			" SET ha_value = $3, valid_until = now() + $4 * interval '1 second'" +
+			" WHERE ha_identity = $1 AND ha_key = $2",
+		ha_value_query:  "SELECT ha_identity, ha_value FROM " + quoted_ha_table + " WHERE ha_key = $1 AND now() <= valid_until",
+		ha_unlock_query: "DELETE FROM " + quoted_ha_table + " WHERE ha_identity = $1 AND ha_key = $2",
 	}
 
 	return m, nil
@@ -175,3 +256,225 @@ func (m *PostgreSQLBackend) List(prefix string) ([]string, error) {
 
 	return keys, nil
 }
+
+// LockWith is used for mutual exclusion based on the given key.
+func (m *PostgreSQLBackend) LockWith(key, value string) (Lock, error) {
+	identity, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	return &PostgreSQLLock{
+		backend:            m,
+		key:                key,
+		value:              value,
+		identity:           identity,
+		renewInterval:      PostgreSQLLockRenewInterval,
+		ttl:                PostgreSQLLockTTL,
+		watchRetryInterval: PostgreSQLWatchRetryInterval,
+	}, nil
+}
+
+func (m *PostgreSQLBackend) HAEnabled() bool {
+	return m.haEnabled
+}
+
+// Lock tries to acquire the lock by repeatedly trying to create
+// or take over an expired row of the lock table. It will block until
+// either the stop channel is closed or the lock could be acquired
+// successfully. The returned channel will be closed once the lock
+// expires or is taken by another instance.
+func (l *PostgreSQLLock) Lock(stopCh <-chan struct{}) (doneCh <-chan struct{}, retErr error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.held {
+		return nil, fmt.Errorf("lock already held")
+	}
+
+	done := make(chan struct{})
+	// close done channel even in case of error
+	defer func() {
+		if retErr != nil {
+			close(done)
+		}
+	}()
+
+	var (
+		stop    = make(chan struct{})
+		success = make(chan struct{})
+		errors  = make(chan error)
+		leader  = make(chan struct{})
+	)
+	// try to acquire the lock asynchronously
+	go l.tryToLock(stop, success, errors)
+
+	select {
+	case <-success:
+		l.held = true
+		l.stopCh = make(chan struct{})
+		// after acquiring it successfully, we must renew the lock periodically,
+		// and watch the lock in order to close the leader channel
+		// once it is lost.
+		renewFailed := make(chan struct{})
+		go l.periodicallyRenewLock(l.stopCh, renewFailed)
+		go l.watch(leader, l.stopCh, renewFailed)
+	case retErr = <-errors:
+		close(stop)
+		return nil, retErr
+	case <-stopCh:
+		close(stop)
+		return nil, nil
+	}
+
+	return leader, retErr
+}
+
+// Unlock releases the lock by deleting its row from the lock table, once
+// it is not renewed anymore.
+func (l *PostgreSQLLock) Unlock() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !l.held {
+		return nil
+	}
+
+	l.held = false
+	close(l.stopCh)
+	if _, err := l.backend.client.Exec(l.backend.ha_unlock_query, l.identity, l.key); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Value checks whether or not the lock is held by any instance of
+// PostgreSQLLock, including this one, and returns the current value.
+func (l *PostgreSQLLock) Value() (bool, string, error) {
+	_, value, err := l.current()
+	if err == sql.ErrNoRows {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	return true, value, nil
+}
+
+// current returns the identity and the value of the instance holding the
+// lock, or sql.ErrNoRows if the lock is not held.
+func (l *PostgreSQLLock) current() (string, string, error) {
+	var identity, value string
+	err := l.backend.client.QueryRow(l.backend.ha_value_query, l.key).Scan(&identity, &value)
+	return identity, value, err
+}
+
+// tryToLock tries to take the row of the lock every
+// `PostgreSQLLockRetryInterval`. As long as the row is held by
+// another instance, it will be retried. If the operation fails due
+// to an error, it is sent to the errors channel.
+// When the lock could be acquired successfully, the success
+// channel is closed.
+func (l *PostgreSQLLock) tryToLock(stop, success chan struct{}, errors chan error) {
+	ticker := time.NewTicker(PostgreSQLLockRetryInterval)
+
+	for {
+		select {
+		case <-stop:
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			acquired, err := l.writeItem()
+			if err != nil {
+				ticker.Stop()
+				select {
+				case errors <- err:
+				case <-stop:
+				}
+				return
+			}
+			if acquired {
+				ticker.Stop()
+				close(success)
+				return
+			}
+		}
+	}
+}
+
+// periodicallyRenewLock renews the row of the lock every `renewInterval`
+// until the stop channel is closed. If the row is not held by this instance
+// anymore, the failed channel is closed.
+func (l *PostgreSQLLock) periodicallyRenewLock(stop, failed chan struct{}) {
+	ticker := time.NewTicker(l.renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			res, err := l.backend.client.Exec(l.backend.ha_renew_query,
+				l.identity, l.key, l.value, l.ttl.Seconds())
+			if err != nil {
+				// The row expires unless a later renewal succeeds
+				continue
+			}
+			if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+				close(failed)
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// writeItem attempts to take the row of the lock, and returns whether this
+// instance holds the lock.
+func (l *PostgreSQLLock) writeItem() (bool, error) {
+	res, err := l.backend.client.Exec(l.backend.ha_lock_query,
+		l.identity, l.key, l.value, l.ttl.Seconds())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// watch checks whether the lock has changed in the lock table
+// and closes the leader channel if so. The interval is set by
+// `PostgreSQLWatchRetryInterval`. If an error occurs during the
+// check, watch will retry the operation for `PostgreSQLWatchRetryMax`
+// times and close the leader channel if it can't succeed. The leader
+// channel is also closed when a renewal finds the lock lost, or when the
+// lock is released.
+func (l *PostgreSQLLock) watch(lost, stop, renewFailed chan struct{}) {
+	retries := PostgreSQLWatchRetryMax
+
+	ticker := time.NewTicker(l.watchRetryInterval)
+WatchLoop:
+	for {
+		select {
+		case <-stop:
+			break WatchLoop
+		case <-renewFailed:
+			break WatchLoop
+		case <-ticker.C:
+			identity, _, err := l.current()
+			if err == sql.ErrNoRows {
+				break WatchLoop
+			}
+			if err != nil {
+				retries--
+				if retries == 0 {
+					break WatchLoop
+				}
+				continue
+			}
+			if identity != l.identity {
+				break WatchLoop
+			}
+		}
+	}
+
+	ticker.Stop()
+	close(lost)
+}
@@ -3,11 +3,12 @@ package physical
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/helper/logformat"
 	log "github.com/mgutz/logxi/v1"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 func TestPostgreSQLBackend(t *testing.T) {
@@ -45,3 +46,153 @@ func TestPostgreSQLBackend(t *testing.T) {
 	testBackend_ListPrefix(t, b)
 
 }
+
+func TestPostgreSQLBackend_HA(t *testing.T) {
+	connURL := os.Getenv("PGURL")
+	if connURL == "" {
+		t.SkipNow()
+	}
+
+	table := os.Getenv("PGTABLE")
+	if table == "" {
+		table = "vault_kv_store"
+	}
+
+	haTable := os.Getenv("PGHATABLE")
+	if haTable == "" {
+		haTable = "vault_ha_locks"
+	}
+
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	conf := map[string]string{
+		"connection_url": connURL,
+		"table":          table,
+		"ha_enabled":     "true",
+		"ha_table":       haTable,
+	}
+	b, err := NewBackend("postgresql", logger, conf)
+	if err != nil {
+		t.Fatalf("Failed to create new backend: %v", err)
+	}
+	b2, err := NewBackend("postgresql", logger, conf)
+	if err != nil {
+		t.Fatalf("Failed to create new backend: %v", err)
+	}
+
+	defer func() {
+		pg := b.(*PostgreSQLBackend)
+		_, err := pg.client.Exec("TRUNCATE TABLE " + pq.QuoteIdentifier(haTable))
+		if err != nil {
+			t.Fatalf("Failed to truncate table: %v", err)
+		}
+	}()
+
+	ha, ok := b.(HABackend)
+	if !ok || !ha.HAEnabled() {
+		t.Fatalf("postgresql does not implement HABackend")
+	}
+	testHABackend(t, ha, b2.(HABackend))
+	testPostgreSQLLockTTL(t, ha)
+	testPostgreSQLLockUnlock(t, ha)
+}
+
+// testPostgreSQLLockTTL checks that a lock which is not renewed expires,
+// and is then taken by another instance.
+func testPostgreSQLLockTTL(t *testing.T, ha HABackend) {
+	lockTTL := time.Second * 3
+	renewInterval := time.Second * 1
+	watchInterval := time.Second * 1
+
+	lock, err := ha.LockWith("postgresqlttl", "bar")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// The lock is never renewed within its TTL
+	lock.(*PostgreSQLLock).renewInterval = lockTTL * 10
+	lock.(*PostgreSQLLock).ttl = lockTTL
+	lock.(*PostgreSQLLock).watchRetryInterval = watchInterval
+
+	leaderCh, err := lock.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh == nil {
+		t.Fatalf("failed to get leader ch")
+	}
+
+	lock2, err := ha.LockWith("postgresqlttl", "baz")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lock2.(*PostgreSQLLock).renewInterval = renewInterval
+	lock2.(*PostgreSQLLock).ttl = lockTTL
+	lock2.(*PostgreSQLLock).watchRetryInterval = watchInterval
+
+	leaderCh2, err := lock2.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh2 == nil {
+		t.Fatalf("failed to get leader ch")
+	}
+
+	// The first lock was lost when its row expired
+	select {
+	case <-leaderCh:
+	case <-time.After(lockTTL * 2):
+		t.Fatalf("lock not lost")
+	}
+
+	held, val, err := lock2.Value()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !held || val != "baz" {
+		t.Fatalf("bad value: %v %v", held, val)
+	}
+
+	lock2.Unlock()
+}
+
+// testPostgreSQLLockUnlock checks that a released lock is not taken back
+// by the renewals of its former holder.
+func testPostgreSQLLockUnlock(t *testing.T, ha HABackend) {
+	renewInterval := time.Second * 1
+
+	lock, err := ha.LockWith("postgresqlunlock", "bar")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lock.(*PostgreSQLLock).renewInterval = renewInterval
+	lock.(*PostgreSQLLock).ttl = time.Second * 3
+	lock.(*PostgreSQLLock).watchRetryInterval = renewInterval
+
+	leaderCh, err := lock.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leaderCh == nil {
+		t.Fatalf("failed to get leader ch")
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	select {
+	case <-leaderCh:
+	case <-time.After(renewInterval * 2):
+		t.Fatalf("leader ch not closed after unlock")
+	}
+
+	// Let renewals run if any were left
+	time.Sleep(renewInterval * 2)
+
+	held, val, err := lock.Value()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if held {
+		t.Fatalf("lock taken back after unlock: %v", val)
+	}
+}
@@ -18,6 +18,10 @@ type ACL struct {
 	// globRules contains the path policies that glob
 	globRules *radix.Tree
 
+	// segmentWildcardRules contains the path policies with "+" segments,
+	// keyed by their patterns, which end with "*" if they glob
+	segmentWildcardRules *radix.Tree
+
 	// root is enabled if the "root" named policy is present.
 	root bool
 }
@@ -26,9 +30,10 @@ type ACL struct {
 func NewACL(policies []*Policy) (*ACL, error) {
 	// Initialize
 	a := &ACL{
-		exactRules: radix.New(),
-		globRules:  radix.New(),
-		root:       false,
+		exactRules:           radix.New(),
+		globRules:            radix.New(),
+		segmentWildcardRules: radix.New(),
+		root:                 false,
 	}
 
 	// Inject each policy
@@ -43,15 +48,22 @@ func NewACL(policies []*Policy) (*ACL, error) {
 		}
 		for _, pc := range policy.Paths {
 			// Check which tree to use
+			key := pc.Prefix
 			tree := a.exactRules
-			if pc.Glob {
+			switch {
+			case pc.HasSegmentWildcards:
+				tree = a.segmentWildcardRules
+				if pc.Glob {
+					key += "*"
+				}
+			case pc.Glob:
 				tree = a.globRules
 			}
 
 			// Check for an existing policy
-			raw, ok := tree.Get(pc.Prefix)
+			raw, ok := tree.Get(key)
 			if !ok {
-				tree.Insert(pc.Prefix, pc.Permissions)
+				tree.Insert(key, pc.Permissions)
 				continue
 			}
 
@@ -69,6 +81,7 @@ func NewACL(policies []*Policy) (*ACL, error) {
 				pc.Permissions.CapabilitiesBitmap = DenyCapabilityInt
 				pc.Permissions.AllowedParameters = nil
 				pc.Permissions.DeniedParameters = nil
+				pc.Permissions.ControlGroup = nil
 				goto INSERT
 
 			default:
@@ -135,9 +148,13 @@ func NewACL(policies []*Policy) (*ACL, error) {
 				}
 			}
 
+			if existingPerms.ControlGroup != nil {
+				pc.Permissions.ControlGroup = mergeControlGroups(existingPerms.ControlGroup, pc.Permissions.ControlGroup)
+			}
+
 		INSERT:
 
-			tree.Insert(pc.Prefix, pc.Permissions)
+			tree.Insert(key, pc.Permissions)
 
 		}
 	}
@@ -150,26 +167,13 @@ func (a *ACL) Capabilities(path string) (pathCapabilities []string) {
 		return []string{RootCapability}
 	}
 
-	// Find an exact matching rule, look for glob if no match
-	var capabilities uint32
-	raw, ok := a.exactRules.Get(path)
-
-	if ok {
-		perm := raw.(*Permissions)
-		capabilities = perm.CapabilitiesBitmap
-		goto CHECK
-	}
-
-	// Find a glob rule, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	// Find the matching rule, default deny if no match
+	perm := a.matchingPermissions(path)
+	if perm == nil {
 		return []string{DenyCapability}
-	} else {
-		perm := raw.(*Permissions)
-		capabilities = perm.CapabilitiesBitmap
 	}
+	capabilities := perm.CapabilitiesBitmap
 
-CHECK:
 	if capabilities&SudoCapabilityInt > 0 {
 		pathCapabilities = append(pathCapabilities, SudoCapability)
 	}
@@ -197,6 +201,81 @@ CHECK:
 	return
 }
 
+// ControlGroup returns the control group holding the requests on the path,
+// or nil if they are not held. The requests of root tokens are never held.
+func (a *ACL) ControlGroup(path string) *ControlGroup {
+	if a.root {
+		return nil
+	}
+	permissions := a.matchingPermissions(path)
+	if permissions == nil {
+		return nil
+	}
+	return permissions.ControlGroup
+}
+
+// AllowedUnderPrefix returns whether a rule grants capabilities on a path
+// under the prefix, such as the path of a mount. Root tokens are allowed
+// everywhere.
+func (a *ACL) AllowedUnderPrefix(prefix string) bool {
+	if a.root {
+		return true
+	}
+
+	allowed := func(raw interface{}) bool {
+		capabilities := raw.(*Permissions).CapabilitiesBitmap
+		return capabilities&DenyCapabilityInt == 0 && capabilities != 0
+	}
+
+	// The rules on paths under the prefix
+	var found bool
+	walkFn := func(key string, raw interface{}) bool {
+		found = allowed(raw)
+		return found
+	}
+	a.exactRules.WalkPrefix(prefix, walkFn)
+	if !found {
+		a.globRules.WalkPrefix(prefix, walkFn)
+	}
+	if found {
+		return true
+	}
+
+	// The glob rule matching the prefix itself, and so the paths under it
+	if _, raw, ok := a.globRules.LongestPrefix(prefix); ok && allowed(raw) {
+		return true
+	}
+
+	a.segmentWildcardRules.Walk(func(key string, raw interface{}) bool {
+		found = segmentWildcardMatchesUnder(key, prefix) && allowed(raw)
+		return found
+	})
+	return found
+}
+
+// segmentWildcardMatchesUnder returns whether the pattern, whose "+"
+// segments match any single segment, may match a path under the prefix
+func segmentWildcardMatchesUnder(pattern, prefix string) bool {
+	glob := strings.HasSuffix(pattern, "*")
+	patternSegments := strings.Split(strings.TrimSuffix(pattern, "*"), "/")
+	prefixSegments := strings.Split(strings.TrimSuffix(prefix, "/"), "/")
+
+	for i, segment := range prefixSegments {
+		if i >= len(patternSegments) {
+			return false
+		}
+		p := patternSegments[i]
+		if glob && i == len(patternSegments)-1 {
+			// The glob matches any path segments after this one
+			return p == "+" || strings.HasPrefix(segment, p)
+		}
+		if p != "+" && p != segment {
+			return false
+		}
+	}
+	return len(patternSegments) > len(prefixSegments) || glob
+}
+
 // AllowOperation is used to check if the given operation is permitted. The
 // first bool indicates if an op is allowed, the second whether sudo priviliges
 // exist for that op and path.
@@ -213,34 +292,20 @@ func (a *ACL) AllowOperation(req *logical.Request) (bool, bool) {
 		return true, false
 	}
 
-	var permissions *Permissions
-
-	// Find an exact matching rule, look for glob if no match
-	var capabilities uint32
-	raw, ok := a.exactRules.Get(path)
-	if ok {
-		permissions = raw.(*Permissions)
-		capabilities = permissions.CapabilitiesBitmap
-		goto CHECK
-	}
-
-	// Find a glob rule, default deny if no match
-	_, raw, ok = a.globRules.LongestPrefix(path)
-	if !ok {
+	// Find the matching rule, default deny if no match
+	permissions := a.matchingPermissions(path)
+	if permissions == nil {
 		return false, false
-	} else {
-		permissions = raw.(*Permissions)
-		capabilities = permissions.CapabilitiesBitmap
 	}
+	capabilities := permissions.CapabilitiesBitmap
 
-CHECK:
 	// Check if the minimum permissions are met
 	// If "deny" has been explicitly set, only deny will be in the map, so we
 	// only need to check for the existence of other values
 	sudo := capabilities&SudoCapabilityInt > 0
 	operationAllowed := false
 	switch op {
-	case logical.ReadOperation:
+	case logical.ReadOperation, logical.HeadOperation:
 		operationAllowed = capabilities&ReadCapabilityInt > 0
 	case logical.ListOperation:
 		operationAllowed = capabilities&ListCapabilityInt > 0
@@ -338,6 +403,110 @@ CHECK:
 	return true, sudo
 }
 
+// matchingPermissions returns the permissions of the rule matching the path,
+// or nil if no rule matches. An exact rule is used if any; otherwise the most
+// specific of the rules with "+" segments and the longest glob rule matching
+// the path is used.
+func (a *ACL) matchingPermissions(path string) *Permissions {
+	if raw, ok := a.exactRules.Get(path); ok {
+		return raw.(*Permissions)
+	}
+
+	var pattern string
+	var permissions *Permissions
+	if prefix, raw, ok := a.globRules.LongestPrefix(path); ok {
+		pattern = prefix + "*"
+		permissions = raw.(*Permissions)
+	}
+
+	a.segmentWildcardRules.Walk(func(key string, raw interface{}) bool {
+		if segmentWildcardMatch(key, path) &&
+			(permissions == nil || morePreciseWildcardPattern(key, pattern)) {
+			pattern = key
+			permissions = raw.(*Permissions)
+		}
+		return false
+	})
+
+	return permissions
+}
+
+// segmentWildcardMatch returns whether the path matches the pattern, whose
+// "+" segments match any single segment. Glob patterns end with "*", and
+// their last segment matches as a prefix.
+func segmentWildcardMatch(pattern, path string) bool {
+	glob := strings.HasSuffix(pattern, "*")
+	patternSegments := strings.Split(strings.TrimSuffix(pattern, "*"), "/")
+	pathSegments := strings.Split(path, "/")
+
+	if len(pathSegments) < len(patternSegments) ||
+		(!glob && len(pathSegments) != len(patternSegments)) {
+		return false
+	}
+
+	last := len(patternSegments) - 1
+	for i, segment := range patternSegments {
+		switch {
+		case segment == "+":
+		case glob && i == last:
+			if !strings.HasPrefix(pathSegments[i], segment) {
+				return false
+			}
+		case segment != pathSegments[i]:
+			return false
+		}
+	}
+	return true
+}
+
+// morePreciseWildcardPattern returns whether the pattern a takes precedence
+// over the pattern b, both matching a path. The pattern whose first wildcard
+// comes later wins, then the pattern not ending with a glob, then the pattern
+// with the fewest "+" segments, then the longest pattern, then the
+// lexicographically greatest pattern.
+func morePreciseWildcardPattern(a, b string) bool {
+	if aw, bw := firstWildcardIndex(a), firstWildcardIndex(b); aw != bw {
+		return aw > bw
+	}
+	if ag, bg := strings.HasSuffix(a, "*"), strings.HasSuffix(b, "*"); ag != bg {
+		return !ag
+	}
+	if as, bs := segmentWildcardCount(a), segmentWildcardCount(b); as != bs {
+		return as < bs
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+// firstWildcardIndex returns the index of the first "+" segment or glob of the
+// pattern, or its length if it has none
+func firstWildcardIndex(pattern string) int {
+	index := 0
+	for _, segment := range strings.Split(strings.TrimSuffix(pattern, "*"), "/") {
+		if segment == "+" {
+			return index
+		}
+		index += len(segment) + 1
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return len(pattern) - 1
+	}
+	return len(pattern)
+}
+
+// segmentWildcardCount returns the number of "+" segments of the pattern
+func segmentWildcardCount(pattern string) int {
+	count := 0
+	for _, segment := range strings.Split(strings.TrimSuffix(pattern, "*"), "/") {
+		if segment == "+" {
+			count++
+		}
+	}
+	return count
+}
+
 func valueInParameterList(v interface{}, list []interface{}) bool {
 	// Empty list is equivalent to the item always existing in the list
 	if len(list) == 0 {
@@ -363,3 +532,25 @@ func valueInSlice(v interface{}, list []interface{}) bool {
 
 	return false
 }
+
+// mergeControlGroups returns the control group of a path granted by several
+// policies. The larger number of approvals and the shorter TTL apply, and
+// the approver policies of both control groups may authorize the requests.
+func mergeControlGroups(a, b *ControlGroup) *ControlGroup {
+	if b == nil {
+		return a
+	}
+
+	merged := &ControlGroup{
+		Approvals:        a.Approvals,
+		ApproverPolicies: strutil.RemoveDuplicates(append(append([]string{}, a.ApproverPolicies...), b.ApproverPolicies...)),
+		TTL:              a.TTL,
+	}
+	if b.Approvals > merged.Approvals {
+		merged.Approvals = b.Approvals
+	}
+	if b.TTL < merged.TTL {
+		merged.TTL = b.TTL
+	}
+	return merged
+}
@@ -415,6 +415,193 @@ func TestACL_ValuePermissions(t *testing.T) {
 	}
 }
 
+func TestACL_SegmentWildcard(t *testing.T) {
+	policy, err := Parse(segmentWildcardPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	tcases := []struct {
+		path     string
+		expected []string
+	}{
+		{"transit/keys/foo/config", []string{"read", "update"}},
+		{"transit/keys/bar/config", []string{"read", "update"}},
+		{"transit/keys/foo", []string{"deny"}},
+		{"transit/keys/foo/config/more", []string{"deny"}},
+		{"transit/keys/foo/bar/config", []string{"deny"}},
+		{"transit/keys/secret/config", []string{"deny"}},
+		{"secret/a/b/c", []string{"read"}},
+		{"secret/a/b", []string{"deny"}},
+		{"secret/a/shared/x", []string{"read", "list"}},
+		{"secret/a/shared", []string{"deny"}},
+		{"kv/team/alpha", []string{"list"}},
+		{"kv/team/alpha/beta", []string{"read"}},
+		{"kv/team/alpha/beta/gamma", []string{"read"}},
+		{"kv/team", []string{"read"}},
+	}
+	for _, tc := range tcases {
+		actual := acl.Capabilities(tc.path)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Fatalf("bad: path:%s\ngot\n%#v\nexpected\n%#v\n", tc.path, actual, tc.expected)
+		}
+	}
+
+	request := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "transit/keys/foo/config",
+	}
+	if allowed, _ := acl.AllowOperation(request); !allowed {
+		t.Fatalf("update of %s not allowed", request.Path)
+	}
+	request.Operation = logical.DeleteOperation
+	if allowed, _ := acl.AllowOperation(request); allowed {
+		t.Fatalf("delete of %s allowed", request.Path)
+	}
+}
+
+func TestACL_ControlGroup(t *testing.T) {
+	policy1, err := Parse(`
+path "pki/root/sign-intermediate" {
+	capabilities = ["update"]
+	control_group {
+		approvals = 1
+		approver_policies = ["security"]
+		ttl = "1h"
+	}
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policy2, err := Parse(`
+path "pki/root/sign-intermediate" {
+	capabilities = ["read"]
+	control_group {
+		approvals = 2
+		approver_policies = ["ops"]
+		ttl = "2h"
+	}
+}
+path "secret/*" {
+	capabilities = ["read"]
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	acl, err := NewACL([]*Policy{policy1, policy2})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := &ControlGroup{
+		Approvals:        2,
+		ApproverPolicies: []string{"ops", "security"},
+		TTL:              time.Hour,
+	}
+	if cg := acl.ControlGroup("pki/root/sign-intermediate"); !reflect.DeepEqual(cg, expected) {
+		t.Fatalf("bad: %#v", cg)
+	}
+	if cg := acl.ControlGroup("secret/foo"); cg != nil {
+		t.Fatalf("bad: %#v", cg)
+	}
+
+	// Root tokens are never held
+	root, err := NewACL([]*Policy{&Policy{Name: "root"}, policy1})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if cg := root.ControlGroup("pki/root/sign-intermediate"); cg != nil {
+		t.Fatalf("bad: %#v", cg)
+	}
+}
+
+func TestACL_AllowedUnderPrefix(t *testing.T) {
+	policy, err := Parse(`
+path "secret/foo" {
+	capabilities = ["read"]
+}
+path "transit/encrypt/*" {
+	capabilities = ["update"]
+}
+path "pk*" {
+	capabilities = ["read"]
+}
+path "auth/+/login" {
+	capabilities = ["create"]
+}
+path "kv/+/dat*" {
+	capabilities = ["read"]
+}
+path "database/*" {
+	capabilities = ["deny"]
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]bool{
+		"secret/":        true,
+		"transit/":       true,
+		"pki/":           true,
+		"pki-int/":       true,
+		"auth/userpass/": true,
+		"kv/":            true,
+		"database/":      false,
+		"ssh/":           false,
+		"auth/token/x/":  false,
+	}
+	for prefix, expected := range cases {
+		if actual := acl.AllowedUnderPrefix(prefix); actual != expected {
+			t.Fatalf("%s: expected %t", prefix, expected)
+		}
+	}
+
+	root, err := NewACL([]*Policy{&Policy{Name: "root"}})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !root.AllowedUnderPrefix("database/") {
+		t.Fatal("root should be allowed")
+	}
+}
+
+func TestACL_MorePreciseWildcardPattern(t *testing.T) {
+	tcases := []struct {
+		a, b string
+	}{
+		// The first wildcard comes later
+		{"secret/a/+", "secret/+/b"},
+		{"secret/ab*", "secret/+/b"},
+		// No glob
+		{"secret/+", "secret/*"},
+		// Fewer "+" segments
+		{"secret/+/b/c", "secret/+/+/c"},
+		// Longer
+		{"secret/+/b/c*", "secret/+/b/*"},
+		// Lexicographically greater
+		{"secret/+/b", "secret/+/a"},
+	}
+	for _, tc := range tcases {
+		if !morePreciseWildcardPattern(tc.a, tc.b) {
+			t.Fatalf("%q should take precedence over %q", tc.a, tc.b)
+		}
+		if morePreciseWildcardPattern(tc.b, tc.a) {
+			t.Fatalf("%q should not take precedence over %q", tc.b, tc.a)
+		}
+	}
+}
+
 var tokenCreationPolicy = `
 name = "tokenCreation"
 path "auth/token/create*" {
@@ -733,3 +920,25 @@ path "test/star" {
 	}
 }
 `
+
+var segmentWildcardPolicy = `
+name = "segments"
+path "transit/keys/+/config" {
+	capabilities = ["read", "update"]
+}
+path "transit/keys/secret/config" {
+	capabilities = ["deny"]
+}
+path "secret/+/b/*" {
+	capabilities = ["read"]
+}
+path "secret/+/shared/*" {
+	capabilities = ["read", "list"]
+}
+path "kv/*" {
+	capabilities = ["read"]
+}
+path "kv/+/alpha" {
+	capabilities = ["list"]
+}
+`
@@ -0,0 +1,370 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// activitySubPath is the sub-path used for the activity log view. This is
+	// nested under the system view.
+	activitySubPath = "counters/activity/"
+
+	// activityConfigPath is the path of the configuration in the view
+	activityConfigPath = "config"
+
+	// activitySegmentPrefix is the prefix of the daily segments in the view
+	activitySegmentPrefix = "log/"
+
+	// activitySegmentFormat is the format of the UTC day of the segments
+	activitySegmentFormat = "2006-01-02"
+
+	// activityFlushInterval is how often the clients of the current day are
+	// persisted
+	activityFlushInterval = time.Minute
+
+	// The defaults of the configuration
+	defaultActivityRetentionDays     = 365
+	defaultActivityDefaultReportDays = 30
+)
+
+// activityConfig is the configuration of the activity log
+type activityConfig struct {
+	Enabled           bool `json:"enabled"`
+	RetentionDays     int  `json:"retention_days"`
+	DefaultReportDays int  `json:"default_report_days"`
+}
+
+// activitySegment is the storage entry of the clients seen during a day.
+// Clients maps the auth mounts to the salted IDs of their tokens.
+type activitySegment struct {
+	Clients map[string][]string `json:"clients"`
+}
+
+// activityCounts are the numbers of distinct clients. Tokens are not tied to
+// entities, so every client is counted as a non-entity token.
+type activityCounts struct {
+	distinctEntities int
+	nonEntityTokens  int
+	clients          int
+}
+
+// data returns the counts as response data
+func (c *activityCounts) data() map[string]interface{} {
+	return map[string]interface{}{
+		"distinct_entities": c.distinctEntities,
+		"non_entity_tokens": c.nonEntityTokens,
+		"clients":           c.clients,
+	}
+}
+
+// mountActivity are the counts of the clients of an auth mount
+type mountActivity struct {
+	mountPath string
+	counts    *activityCounts
+}
+
+// activityLog counts the distinct clients of each auth mount. The clients of
+// the current day are kept in memory and flushed to their segment
+// periodically and on seal.
+type activityLog struct {
+	l sync.Mutex
+
+	view   *BarrierView
+	config *activityConfig
+
+	day     string
+	clients map[string]map[string]struct{}
+	dirty   bool
+
+	doneCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// setupActivityLog is invoked after we've loaded the mount table to load the
+// activity log configuration and the clients of the current day
+func (c *Core) setupActivityLog() error {
+	a := &activityLog{
+		view: c.systemBarrierView.SubView(activitySubPath),
+		config: &activityConfig{
+			Enabled:           true,
+			RetentionDays:     defaultActivityRetentionDays,
+			DefaultReportDays: defaultActivityDefaultReportDays,
+		},
+		day:     time.Now().UTC().Format(activitySegmentFormat),
+		clients: make(map[string]map[string]struct{}),
+		doneCh:  make(chan struct{}),
+	}
+
+	raw, err := a.view.Get(activityConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read activity log config: %v", err)
+	}
+	if raw != nil {
+		if err := raw.DecodeJSON(a.config); err != nil {
+			return fmt.Errorf("failed to decode activity log config: %v", err)
+		}
+	}
+
+	segment, err := a.segment(a.day)
+	if err != nil {
+		return err
+	}
+	if segment != nil {
+		for mount, ids := range segment.Clients {
+			a.clients[mount] = make(map[string]struct{}, len(ids))
+			for _, id := range ids {
+				a.clients[mount][id] = struct{}{}
+			}
+		}
+	}
+
+	a.wg.Add(1)
+	go a.run(c)
+
+	c.activityLog = a
+	return nil
+}
+
+// teardownActivityLog stops the activity log and flushes the clients of the
+// current day before sealing the Vault
+func (c *Core) teardownActivityLog() error {
+	a := c.activityLog
+	if a == nil {
+		return nil
+	}
+	close(a.doneCh)
+	a.wg.Wait()
+	c.activityLog = nil
+	return a.flush()
+}
+
+// run periodically flushes the clients and prunes the expired segments
+func (a *activityLog) run(c *Core) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(activityFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.doneCh:
+			return
+		case now := <-ticker.C:
+			if err := a.rotate(now); err != nil {
+				c.logger.Error("core: failed to flush activity log", "error", err)
+			}
+		}
+	}
+}
+
+// recordToken records a request of the token. The token is counted as a
+// client of the auth mount it was created with.
+func (c *Core) recordToken(te *TokenEntry) {
+	a := c.activityLog
+	if a == nil || te == nil || te.ID == "" {
+		return
+	}
+	mount := c.router.MatchingMount(te.Path)
+	if mount == "" {
+		return
+	}
+	a.record(mount, c.tokenStore.SaltID(te.ID))
+}
+
+// record adds the client to the clients of the mount for the current day
+func (a *activityLog) record(mount, id string) {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	if !a.config.Enabled {
+		return
+	}
+
+	// The requests made after midnight are counted on the previous day until
+	// the next rotation
+	clients, ok := a.clients[mount]
+	if !ok {
+		clients = make(map[string]struct{})
+		a.clients[mount] = clients
+	}
+	if _, ok := clients[id]; !ok {
+		clients[id] = struct{}{}
+		a.dirty = true
+	}
+}
+
+// rotate flushes the clients, and starts a new segment and prunes the
+// expired ones once the day of now is over
+func (a *activityLog) rotate(now time.Time) error {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	if err := a.flushLocked(); err != nil {
+		return err
+	}
+
+	day := now.UTC().Format(activitySegmentFormat)
+	if day == a.day {
+		return nil
+	}
+	a.day = day
+	a.clients = make(map[string]map[string]struct{})
+	a.dirty = false
+	return a.prune(now)
+}
+
+// flush persists the clients of the current day if they changed
+func (a *activityLog) flush() error {
+	a.l.Lock()
+	defer a.l.Unlock()
+	return a.flushLocked()
+}
+
+// flushLocked persists the clients of the current day if they changed. The
+// lock must be held.
+func (a *activityLog) flushLocked() error {
+	if !a.dirty {
+		return nil
+	}
+
+	segment := &activitySegment{
+		Clients: make(map[string][]string, len(a.clients)),
+	}
+	for mount, clients := range a.clients {
+		ids := make([]string, 0, len(clients))
+		for id := range clients {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		segment.Clients[mount] = ids
+	}
+
+	se, err := logical.StorageEntryJSON(activitySegmentPrefix+a.day, segment)
+	if err != nil {
+		return err
+	}
+	if err := a.view.Put(se); err != nil {
+		return fmt.Errorf("failed to save activity log segment: %v", err)
+	}
+	a.dirty = false
+	return nil
+}
+
+// prune deletes the segments older than the retention period. The lock must
+// be held.
+func (a *activityLog) prune(now time.Time) error {
+	oldest := now.UTC().AddDate(0, 0, -a.config.RetentionDays).Format(activitySegmentFormat)
+
+	days, err := a.view.List(activitySegmentPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list activity log segments: %v", err)
+	}
+	for _, day := range days {
+		// The days sort as strings
+		if day < oldest {
+			if err := a.view.Delete(activitySegmentPrefix + day); err != nil {
+				return fmt.Errorf("failed to delete activity log segment %q: %v", day, err)
+			}
+		}
+	}
+	return nil
+}
+
+// segment returns the stored segment of the day, or nil if there is none
+func (a *activityLog) segment(day string) (*activitySegment, error) {
+	raw, err := a.view.Get(activitySegmentPrefix + day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read activity log segment %q: %v", day, err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	var segment activitySegment
+	if err := raw.DecodeJSON(&segment); err != nil {
+		return nil, fmt.Errorf("failed to decode activity log segment %q: %v", day, err)
+	}
+	return &segment, nil
+}
+
+// getConfig returns a copy of the configuration
+func (a *activityLog) getConfig() activityConfig {
+	a.l.Lock()
+	defer a.l.Unlock()
+	return *a.config
+}
+
+// setConfig persists and applies the configuration
+func (a *activityLog) setConfig(config *activityConfig) error {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	se, err := logical.StorageEntryJSON(activityConfigPath, config)
+	if err != nil {
+		return err
+	}
+	if err := a.view.Put(se); err != nil {
+		return fmt.Errorf("failed to save activity log config: %v", err)
+	}
+	a.config = config
+	return nil
+}
+
+// counts returns the total and per mount counts of the distinct clients of
+// the days from start to end, inclusive
+func (a *activityLog) counts(start, end time.Time) (*activityCounts, []*mountActivity, error) {
+	if err := a.flush(); err != nil {
+		return nil, nil, err
+	}
+
+	clients := make(map[string]map[string]struct{})
+	first := start.UTC().Format(activitySegmentFormat)
+	last := end.UTC().Format(activitySegmentFormat)
+	days, err := a.view.List(activitySegmentPrefix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list activity log segments: %v", err)
+	}
+	for _, day := range days {
+		if day < first || day > last {
+			continue
+		}
+		segment, err := a.segment(day)
+		if err != nil {
+			return nil, nil, err
+		}
+		if segment == nil {
+			continue
+		}
+		for mount, ids := range segment.Clients {
+			if clients[mount] == nil {
+				clients[mount] = make(map[string]struct{}, len(ids))
+			}
+			for _, id := range ids {
+				clients[mount][id] = struct{}{}
+			}
+		}
+	}
+
+	// A token belongs to a single mount, so the total is the sum
+	total := &activityCounts{}
+	byMount := make([]*mountActivity, 0, len(clients))
+	for mount, ids := range clients {
+		byMount = append(byMount, &mountActivity{
+			mountPath: mount,
+			counts: &activityCounts{
+				nonEntityTokens: len(ids),
+				clients:         len(ids),
+			},
+		})
+		total.nonEntityTokens += len(ids)
+		total.clients += len(ids)
+	}
+	sort.Slice(byMount, func(i, j int) bool {
+		return byMount[i].mountPath < byMount[j].mountPath
+	})
+
+	return total, byMount, nil
+}
@@ -0,0 +1,191 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_ActivityLog(t *testing.T) {
+	c, keys, root := TestCoreUnsealed(t)
+
+	// Each token counts once, however many requests it makes
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		req := logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil || resp == nil || resp.Auth == nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		tokens = append(tokens, resp.Auth.ClientToken)
+	}
+	for _, token := range append(tokens, tokens...) {
+		req := logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup-self")
+		req.ClientToken = token
+		if resp, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+	}
+
+	checkClients := func(req *logical.Request, expected int) {
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		total := resp.Data["total"].(map[string]interface{})
+		if total["clients"] != expected || total["non_entity_tokens"] != expected {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+		byMount := resp.Data["by_mount"].([]interface{})
+		if expected == 0 {
+			if len(byMount) != 0 {
+				t.Fatalf("bad: %#v", resp.Data)
+			}
+			return
+		}
+		mount := byMount[0].(map[string]interface{})
+		if len(byMount) != 1 || mount["mount_path"] != "auth/token/" ||
+			mount["counts"].(map[string]interface{})["clients"] != expected {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+	}
+
+	// The root token is counted along with the created ones
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/internal/counters/activity")
+	req.ClientToken = root
+	checkClients(req, 3)
+
+	// The counts survive a seal
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(c, TestKeyCopy(key)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	checkClients(req, 3)
+
+	// The days outside of the period are not counted. The period is given
+	// in the query parameters, as the API client does.
+	req.Query = map[string][]string{
+		"start_time": []string{time.Now().AddDate(0, 0, -10).Format(time.RFC3339)},
+		"end_time":   []string{time.Now().AddDate(0, 0, -5).Format(time.RFC3339)},
+	}
+	checkClients(req, 0)
+
+	req.Query["start_time"] = []string{"yesterday"}
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("expected error for invalid start_time")
+	}
+
+	// Disabling the counting keeps the recorded days
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/internal/counters/config")
+	req.ClientToken = root
+	req.Data["enabled"] = false
+	if resp, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup-self")
+	req.ClientToken = resp.Auth.ClientToken
+	if resp, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/internal/counters/activity")
+	req.ClientToken = root
+	checkClients(req, 3)
+}
+
+func TestCore_ActivityLogConfig(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/internal/counters/config")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["enabled"] != true || resp.Data["retention_days"] != defaultActivityRetentionDays ||
+		resp.Data["default_report_days"] != defaultActivityDefaultReportDays {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req.Operation = logical.UpdateOperation
+	req.Data["retention_days"] = 10
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("expected error for a report period longer than the retention")
+	}
+	req.Data["default_report_days"] = 7
+	if resp, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/internal/counters/config")
+	req.ClientToken = root
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["retention_days"] != 10 || resp.Data["default_report_days"] != 7 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestActivityLog_rotate(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	a := c.activityLog
+
+	// Clients recorded before midnight are kept in their day
+	a.record("auth/userpass/", "a")
+	a.record("auth/userpass/", "b")
+	a.record("auth/approle/", "c")
+	now := time.Now().UTC()
+	tomorrow := now.AddDate(0, 0, 1)
+	if err := a.rotate(tomorrow); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	a.record("auth/userpass/", "a")
+	a.record("auth/userpass/", "d")
+
+	// The clients of several days are counted once
+	total, byMount, err := a.counts(now, tomorrow)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if total.clients != 4 || len(byMount) != 2 ||
+		byMount[0].mountPath != "auth/approle/" || byMount[0].counts.clients != 1 ||
+		byMount[1].mountPath != "auth/userpass/" || byMount[1].counts.clients != 3 {
+		t.Fatalf("bad: %#v %#v", total, byMount)
+	}
+
+	total, _, err = a.counts(tomorrow, tomorrow)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if total.clients != 2 {
+		t.Fatalf("bad: %#v", total)
+	}
+
+	// The days past the retention are pruned on rotation
+	if err := a.rotate(now.AddDate(0, 0, defaultActivityRetentionDays+1)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	segment, err := a.segment(now.Format(activitySegmentFormat))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if segment != nil {
+		t.Fatalf("expected expired segment to be deleted")
+	}
+	if segment, err := a.segment(tomorrow.Format(activitySegmentFormat)); err != nil || segment == nil {
+		t.Fatalf("expected segment to be kept, err: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +18,8 @@ import (
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"github.com/mitchellh/copystructure"
 )
 
 const (
@@ -36,6 +39,14 @@ const (
 	// auditTableType is the value we expect to find for the audit table and
 	// corresponding entries
 	auditTableType = "audit"
+
+	// auditModeRequired is the mode of the audit backends which must log
+	// every request they select, or the request is rejected
+	auditModeRequired = "required"
+
+	// auditModeBestEffort is the mode of the audit backends which never
+	// reject requests. The entries they fail to log are retried.
+	auditModeBestEffort = "best_effort"
 )
 
 var (
@@ -82,6 +93,21 @@ func (c *Core) enableAudit(entry *MountEntry) error {
 	viewPath := auditBarrierPrefix + entry.UUID + "/"
 	view := NewBarrierView(c.barrier, viewPath)
 
+	// Parse the filter before creating the backend, which may hold resources
+	filter, err := auditFilter(entry)
+	if err != nil {
+		return err
+	}
+
+	// Ensure at least one backend is not best effort
+	mode, err := auditMode(entry)
+	if err != nil {
+		return err
+	}
+	if mode == auditModeBestEffort && !hasDurableAudit(c.audit.Entries) {
+		return fmt.Errorf("a best effort audit backend requires another audit backend which is not best effort")
+	}
+
 	// Lookup the new backend
 	backend, err := c.newAuditBackend(entry, view, entry.Options)
 	if err != nil {
@@ -94,13 +120,15 @@ func (c *Core) enableAudit(entry *MountEntry) error {
 	newTable := c.audit.shallowClone()
 	newTable.Entries = append(newTable.Entries, entry)
 	if err := c.persistAudit(newTable, entry.Local); err != nil {
+		c.removeAuditReloadFunc(entry)
+		c.auditBroker.closeBackend(entry.Path, backend)
 		return errors.New("failed to update audit table")
 	}
 
 	c.audit = newTable
 
 	// Register the backend
-	c.auditBroker.Register(entry.Path, backend, view)
+	c.auditBroker.Register(entry.Path, backend, view, filter, mode)
 	if c.logger.IsInfo() {
 		c.logger.Info("core: enabled audit backend", "path", entry.Path, "type", entry.Type)
 	}
@@ -126,6 +154,11 @@ func (c *Core) disableAudit(path string) (bool, error) {
 		return false, fmt.Errorf("no matching backend")
 	}
 
+	// Ensure the best effort backends are not left alone
+	if len(newTable.Entries) > 0 && !hasDurableAudit(newTable.Entries) {
+		return true, fmt.Errorf("cannot disable the last audit backend which is not best effort while best effort backends remain")
+	}
+
 	c.removeAuditReloadFunc(entry)
 
 	// When unmounting all entries the JSON code will load back up from storage
@@ -246,6 +279,9 @@ func (c *Core) persistAudit(table *MountTable, localOnly bool) error {
 		}
 	}
 
+	// The tables are written together, so that they are consistent with
+	// each other if the physical backend supports transactions
+	var txns []TxnEntry
 	if !localOnly {
 		// Marshal the table
 		compressedBytes, err := jsonutil.EncodeJSONAndCompress(nonLocalAudit, nil)
@@ -254,17 +290,13 @@ func (c *Core) persistAudit(table *MountTable, localOnly bool) error {
 			return err
 		}
 
-		// Create an entry
-		entry := &Entry{
-			Key:   coreAuditConfigPath,
-			Value: compressedBytes,
-		}
-
-		// Write to the physical backend
-		if err := c.barrier.Put(entry); err != nil {
-			c.logger.Error("core: failed to persist audit table", "error", err)
-			return err
-		}
+		txns = append(txns, TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &Entry{
+				Key:   coreAuditConfigPath,
+				Value: compressedBytes,
+			},
+		})
 	}
 
 	// Repeat with local audit
@@ -274,13 +306,17 @@ func (c *Core) persistAudit(table *MountTable, localOnly bool) error {
 		return err
 	}
 
-	entry := &Entry{
-		Key:   coreLocalAuditConfigPath,
-		Value: compressedBytes,
-	}
+	txns = append(txns, TxnEntry{
+		Operation: physical.PutOperation,
+		Entry: &Entry{
+			Key:   coreLocalAuditConfigPath,
+			Value: compressedBytes,
+		},
+	})
 
-	if err := c.barrier.Put(entry); err != nil {
-		c.logger.Error("core: failed to persist local audit table", "error", err)
+	// Write to the physical backend
+	if err := c.barrier.Transaction(txns); err != nil {
+		c.logger.Error("core: failed to persist audit table", "error", err)
 		return err
 	}
 
@@ -291,6 +327,7 @@ func (c *Core) persistAudit(table *MountTable, localOnly bool) error {
 // initialize the audit backends
 func (c *Core) setupAudits() error {
 	broker := NewAuditBroker(c.logger)
+	broker.mountPath = c.router.MatchingMount
 
 	c.auditLock.Lock()
 	defer c.auditLock.Unlock()
@@ -302,6 +339,17 @@ func (c *Core) setupAudits() error {
 		viewPath := auditBarrierPrefix + entry.UUID + "/"
 		view := NewBarrierView(c.barrier, viewPath)
 
+		filter, err := auditFilter(entry)
+		if err != nil {
+			c.logger.Error("core: failed to parse audit filter", "path", entry.Path, "error", err)
+			continue
+		}
+		mode, err := auditMode(entry)
+		if err != nil {
+			c.logger.Error("core: failed to parse audit mode", "path", entry.Path, "error", err)
+			continue
+		}
+
 		// Initialize the backend
 		backend, err := c.newAuditBackend(entry, view, entry.Options)
 		if err != nil {
@@ -314,7 +362,7 @@ func (c *Core) setupAudits() error {
 		}
 
 		// Mount the backend
-		broker.Register(entry.Path, backend, view)
+		broker.Register(entry.Path, backend, view, filter, mode)
 
 		successCount += 1
 	}
@@ -339,17 +387,29 @@ func (c *Core) teardownAudits() error {
 		}
 	}
 
+	if c.auditBroker != nil {
+		c.auditBroker.Close()
+	}
+
 	c.audit = nil
 	c.auditBroker = nil
 	return nil
 }
 
+// auditReloadKey returns the key of the reload func of the audit backend,
+// or an empty string if the backend type is not reloaded on SIGHUP
+func auditReloadKey(entry *MountEntry) string {
+	switch entry.Type {
+	case "file", "webhook", "kafka":
+		return "audit_" + entry.Type + "|" + entry.Path
+	}
+	return ""
+}
+
 // removeAuditReloadFunc removes the reload func from the working set. The
 // audit lock needs to be held before calling this.
 func (c *Core) removeAuditReloadFunc(entry *MountEntry) {
-	switch entry.Type {
-	case "file":
-		key := "audit_file|" + entry.Path
+	if key := auditReloadKey(entry); key != "" {
 		c.reloadFuncsLock.Lock()
 
 		if c.logger.IsDebug() {
@@ -387,10 +447,7 @@ func (c *Core) newAuditBackend(entry *MountEntry, view logical.Storage, conf map
 		return nil, fmt.Errorf("nil backend returned from %q factory function", entry.Type)
 	}
 
-	switch entry.Type {
-	case "file":
-		key := "audit_file|" + entry.Path
-
+	if key := auditReloadKey(entry); key != "" {
 		c.reloadFuncsLock.Lock()
 
 		if c.logger.IsDebug() {
@@ -399,7 +456,7 @@ func (c *Core) newAuditBackend(entry *MountEntry, view logical.Storage, conf map
 
 		c.reloadFuncs[key] = append(c.reloadFuncs[key], func(map[string]string) error {
 			if c.logger.IsInfo() {
-				c.logger.Info("audit: reloading file audit backend", "path", entry.Path)
+				c.logger.Info("audit: reloading audit backend", "path", entry.Path, "type", entry.Type)
 			}
 			return be.Reload()
 		})
@@ -410,6 +467,43 @@ func (c *Core) newAuditBackend(entry *MountEntry, view logical.Storage, conf map
 	return be, err
 }
 
+// auditFilter parses the filter option of an audit entry, which selects the
+// requests logged by the backend. It returns nil if the option is not set.
+func auditFilter(entry *MountEntry) (*audit.Filter, error) {
+	expr, ok := entry.Options["filter"]
+	if !ok {
+		return nil, nil
+	}
+	filter, err := audit.ParseFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	return filter, nil
+}
+
+// auditMode returns the mode option of an audit entry. The backends without
+// a mode are required as a group: a request is rejected if none of them logs
+// it.
+func auditMode(entry *MountEntry) (string, error) {
+	switch mode := entry.Options["mode"]; mode {
+	case "", auditModeRequired, auditModeBestEffort:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid mode %q, must be %q or %q", mode, auditModeRequired, auditModeBestEffort)
+	}
+}
+
+// hasDurableAudit returns whether one of the audit entries is not best
+// effort
+func hasDurableAudit(entries []*MountEntry) bool {
+	for _, entry := range entries {
+		if entry.Options["mode"] != auditModeBestEffort {
+			return true
+		}
+	}
+	return false
+}
+
 // defaultAuditTable creates a default audit table
 func defaultAuditTable() *MountTable {
 	table := &MountTable{
@@ -421,6 +515,11 @@ func defaultAuditTable() *MountTable {
 type backendEntry struct {
 	backend audit.Backend
 	view    *BarrierView
+	filter  *audit.Filter
+	mode    string
+
+	// retry holds the entries a best effort backend failed to log
+	retry *auditRetryBuffer
 }
 
 // AuditBroker is used to provide a single ingest interface to auditable
@@ -429,6 +528,9 @@ type AuditBroker struct {
 	sync.RWMutex
 	backends map[string]backendEntry
 	logger   log.Logger
+
+	// mountPath returns the mount of a request path for the filters
+	mountPath func(string) string
 }
 
 // NewAuditBroker creates a new audit broker
@@ -440,21 +542,65 @@ func NewAuditBroker(log log.Logger) *AuditBroker {
 	return b
 }
 
-// Register is used to add new audit backend to the broker
-func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView) {
-	a.Lock()
-	defer a.Unlock()
-	a.backends[name] = backendEntry{
+// Register is used to add new audit backend to the broker. A nil filter
+// logs every request to the backend. The mode is empty, required or best
+// effort.
+func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView, filter *audit.Filter, mode string) {
+	be := backendEntry{
 		backend: b,
 		view:    v,
+		filter:  filter,
+		mode:    mode,
+	}
+	if mode == auditModeBestEffort {
+		be.retry = newAuditRetryBuffer(name, a.logger, auditRetryBufferSize)
 	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.backends[name] = be
 }
 
-// Deregister is used to remove an audit backend from the broker
+// Deregister is used to remove an audit backend from the broker. The
+// backends holding resources, such as buffered entries, are closed.
 func (a *AuditBroker) Deregister(name string) {
 	a.Lock()
-	defer a.Unlock()
+	be, ok := a.backends[name]
 	delete(a.backends, name)
+	a.Unlock()
+
+	if ok {
+		if be.retry != nil {
+			be.retry.stop()
+		}
+		a.closeBackend(name, be.backend)
+	}
+}
+
+// Close deregisters all the audit backends
+func (a *AuditBroker) Close() {
+	a.Lock()
+	backends := a.backends
+	a.backends = make(map[string]backendEntry)
+	a.Unlock()
+
+	for name, be := range backends {
+		if be.retry != nil {
+			be.retry.stop()
+		}
+		a.closeBackend(name, be.backend)
+	}
+}
+
+// closeBackend closes the audit backend if it holds resources
+func (a *AuditBroker) closeBackend(name string, b audit.Backend) {
+	closer, ok := b.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		a.logger.Error("audit: failed to close backend", "path", name, "error", err)
+	}
 }
 
 // IsRegistered is used to check if a given audit backend is registered
@@ -477,8 +623,52 @@ func (a *AuditBroker) GetHash(name string, input string) (string, error) {
 	return be.backend.GetHash(input), nil
 }
 
+// filterInput returns the fields of the request the filters are evaluated
+// against
+func (a *AuditBroker) filterInput(auth *logical.Auth, req *logical.Request) *audit.FilterInput {
+	in := &audit.FilterInput{
+		Path:        req.Path,
+		MountPath:   req.MountPoint,
+		Operation:   string(req.Operation),
+		DisplayName: req.DisplayName,
+	}
+	if a.mountPath != nil {
+		in.MountPath = a.mountPath(req.Path)
+	}
+	if auth != nil {
+		in.DisplayName = auth.DisplayName
+	}
+	return in
+}
+
+// copyAuditEntry copies the entry a best effort backend failed to log, to
+// log it again once the request is handled
+func copyAuditEntry(auth *logical.Auth, req *logical.Request, resp *logical.Response) (*logical.Auth, *logical.Request, *logical.Response, error) {
+	if auth != nil {
+		cp, err := copystructure.Copy(auth)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		auth = cp.(*logical.Auth)
+	}
+	cp, err := copystructure.Copy(req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	req = cp.(*logical.Request)
+	if resp != nil {
+		cp, err := copystructure.Copy(resp)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		resp = cp.(*logical.Response)
+	}
+	return auth, req, resp, nil
+}
+
 // LogRequest is used to ensure all the audit backends have an opportunity to
-// log the given request and that *at least one* succeeds.
+// log the given request, that the required ones succeed, and that *at least
+// one* of the others which are not best effort succeeds.
 func (a *AuditBroker) LogRequest(auth *logical.Auth, req *logical.Request, headersConfig *AuditedHeadersConfig, outerErr error) (retErr error) {
 	defer metrics.MeasureSince([]string{"audit", "log_request"}, time.Now())
 	a.RLock()
@@ -502,22 +692,42 @@ func (a *AuditBroker) LogRequest(auth *logical.Auth, req *logical.Request, heade
 		req.Headers = headers
 	}()
 
-	// Ensure at least one backend logs
-	anyLogged := false
+	// Ensure at least one backend logs, if any is selected by its filter
+	filterInput := a.filterInput(auth, req)
+	anyLogged, anySelected := false, false
 	for name, be := range a.backends {
+		if !be.filter.Match(filterInput) {
+			continue
+		}
+
 		req.Headers = nil
 		req.Headers = headersConfig.ApplyConfig(headers, be.backend.GetHash)
 
+		if be.mode == auditModeBestEffort {
+			a.logBestEffort(name, "log_request", be, auth, req, nil, func(auth *logical.Auth, req *logical.Request, _ *logical.Response) error {
+				return be.backend.LogRequest(auth, req, outerErr)
+			})
+			continue
+		}
+		anySelected = true
+
 		start := time.Now()
 		err := be.backend.LogRequest(auth, req, outerErr)
 		metrics.MeasureSince([]string{"audit", name, "log_request"}, start)
-		if err != nil {
-			a.logger.Error("audit: backend failed to log request", "backend", name, "error", err)
-		} else {
+		switch {
+		case err == nil:
 			anyLogged = true
+		case be.mode == auditModeRequired:
+			a.logger.Error("audit: required backend failed to log request", "backend", name, "error", err)
+			retErr = multierror.Append(retErr, fmt.Errorf("required audit backend %q failed to log the request", name))
+		default:
+			a.logger.Error("audit: backend failed to log request", "backend", name, "error", err)
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if retErr != nil {
+		return
+	}
+	if !anyLogged && anySelected {
 		retErr = multierror.Append(retErr, fmt.Errorf("no audit backend succeeded in logging the request"))
 		return
 	}
@@ -525,7 +735,8 @@ func (a *AuditBroker) LogRequest(auth *logical.Auth, req *logical.Request, heade
 }
 
 // LogResponse is used to ensure all the audit backends have an opportunity to
-// log the given response and that *at least one* succeeds.
+// log the given response, that the required ones succeed, and that *at least
+// one* of the others which are not best effort succeeds.
 func (a *AuditBroker) LogResponse(auth *logical.Auth, req *logical.Request,
 	resp *logical.Response, headersConfig *AuditedHeadersConfig, err error) (reterr error) {
 	defer metrics.MeasureSince([]string{"audit", "log_response"}, time.Now())
@@ -543,23 +754,70 @@ func (a *AuditBroker) LogResponse(auth *logical.Auth, req *logical.Request,
 		req.Headers = headers
 	}()
 
-	// Ensure at least one backend logs
-	anyLogged := false
+	// Ensure at least one backend logs, if any is selected by its filter
+	filterInput := a.filterInput(auth, req)
+	anyLogged, anySelected := false, false
+	var requiredErr error
 	for name, be := range a.backends {
+		if !be.filter.Match(filterInput) {
+			continue
+		}
+
 		req.Headers = nil
 		req.Headers = headersConfig.ApplyConfig(headers, be.backend.GetHash)
 
+		if be.mode == auditModeBestEffort {
+			outerErr := err
+			a.logBestEffort(name, "log_response", be, auth, req, resp, func(auth *logical.Auth, req *logical.Request, resp *logical.Response) error {
+				return be.backend.LogResponse(auth, req, resp, outerErr)
+			})
+			continue
+		}
+		anySelected = true
+
 		start := time.Now()
 		err := be.backend.LogResponse(auth, req, resp, err)
 		metrics.MeasureSince([]string{"audit", name, "log_response"}, start)
-		if err != nil {
-			a.logger.Error("audit: backend failed to log response", "backend", name, "error", err)
-		} else {
+		switch {
+		case err == nil:
 			anyLogged = true
+		case be.mode == auditModeRequired:
+			a.logger.Error("audit: required backend failed to log response", "backend", name, "error", err)
+			requiredErr = multierror.Append(requiredErr, fmt.Errorf("required audit backend %q failed to log the response", name))
+		default:
+			a.logger.Error("audit: backend failed to log response", "backend", name, "error", err)
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if requiredErr != nil {
+		return requiredErr
+	}
+	if !anyLogged && anySelected {
 		return fmt.Errorf("no audit backend succeeded in logging the response")
 	}
 	return nil
 }
+
+// logBestEffort logs an entry to a best effort backend, buffering it to log
+// it again later if it fails. The entries are buffered without being logged
+// while older ones are waiting, to keep them in order.
+func (a *AuditBroker) logBestEffort(name, op string, be backendEntry, auth *logical.Auth, req *logical.Request,
+	resp *logical.Response, logFunc func(*logical.Auth, *logical.Request, *logical.Response) error) {
+	if !be.retry.pending() {
+		start := time.Now()
+		err := logFunc(auth, req, resp)
+		metrics.MeasureSince([]string{"audit", name, op}, start)
+		if err == nil {
+			return
+		}
+		a.logger.Warn("audit: best effort backend failed to log entry, buffering it", "backend", name, "error", err)
+	}
+
+	auth, req, resp, err := copyAuditEntry(auth, req, resp)
+	if err != nil {
+		a.logger.Error("audit: failed to copy entry of best effort backend", "backend", name, "error", err)
+		return
+	}
+	be.retry.add(func() error {
+		return logFunc(auth, req, resp)
+	})
+}
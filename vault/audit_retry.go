@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	// auditRetryBufferSize is the number of entries a best effort audit
+	// backend keeps to log again once it recovers
+	auditRetryBufferSize = 10000
+)
+
+var (
+	// auditRetryMinWait and auditRetryMaxWait bound the wait between the
+	// attempts to log the buffered entries, doubled after each failure
+	auditRetryMinWait = time.Second
+	auditRetryMaxWait = time.Minute
+)
+
+// auditRetryBuffer keeps the entries a best effort audit backend failed to
+// log, and logs them again in order until they succeed. The entries arriving
+// while the buffer is not empty are buffered as well, so that they are not
+// logged before the older ones. Once the buffer is full the new entries are
+// dropped.
+type auditRetryBuffer struct {
+	name   string
+	logger log.Logger
+	size   int
+
+	l       sync.Mutex
+	entries []func() error
+
+	notifyCh chan struct{}
+	doneCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newAuditRetryBuffer creates the buffer of the named backend and starts
+// logging the entries added to it
+func newAuditRetryBuffer(name string, logger log.Logger, size int) *auditRetryBuffer {
+	r := &auditRetryBuffer{
+		name:     name,
+		logger:   logger,
+		size:     size,
+		notifyCh: make(chan struct{}, 1),
+		doneCh:   make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// pending returns whether entries are waiting to be logged
+func (r *auditRetryBuffer) pending() bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return len(r.entries) > 0
+}
+
+// add buffers an entry, logging it when called
+func (r *auditRetryBuffer) add(entry func() error) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if len(r.entries) >= r.size {
+		metrics.IncrCounter([]string{"audit", r.name, "dropped"}, 1)
+		r.logger.Error("audit: retry buffer is full, dropping entry", "backend", r.name, "buffered", len(r.entries))
+		return
+	}
+	r.entries = append(r.entries, entry)
+
+	select {
+	case r.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// run logs the buffered entries, waiting longer between the attempts while
+// the backend keeps failing
+func (r *auditRetryBuffer) run() {
+	defer r.wg.Done()
+
+	retryWait := auditRetryMinWait
+	var retryCh <-chan time.Time
+	for {
+		select {
+		case <-r.doneCh:
+			return
+		case <-r.notifyCh:
+			// A failure already scheduled the next attempt
+			if retryCh != nil {
+				continue
+			}
+		case <-retryCh:
+		}
+
+		retryCh = nil
+		if err := r.flush(); err != nil {
+			r.logger.Warn("audit: failed to log buffered entries", "backend", r.name, "retry_in", retryWait, "error", err)
+			retryCh = time.After(retryWait)
+			retryWait *= 2
+			if retryWait > auditRetryMaxWait {
+				retryWait = auditRetryMaxWait
+			}
+			continue
+		}
+		retryWait = auditRetryMinWait
+	}
+}
+
+// flush logs the buffered entries in order, removing them once they are
+// logged. It stops at the first failure.
+func (r *auditRetryBuffer) flush() error {
+	for {
+		r.l.Lock()
+		if len(r.entries) == 0 {
+			r.l.Unlock()
+			return nil
+		}
+		entry := r.entries[0]
+		r.l.Unlock()
+
+		if err := entry(); err != nil {
+			return err
+		}
+
+		r.l.Lock()
+		r.entries[0] = nil
+		r.entries = r.entries[1:]
+		r.l.Unlock()
+	}
+}
+
+// stop stops logging the buffered entries, which are dropped
+func (r *auditRetryBuffer) stop() {
+	close(r.doneCh)
+	r.wg.Wait()
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	if len(r.entries) > 0 {
+		r.logger.Warn("audit: dropping buffered entries of removed backend", "backend", r.name, "buffered", len(r.entries))
+		r.entries = nil
+	}
+}
@@ -3,6 +3,8 @@ package vault
 import (
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -114,6 +116,120 @@ func TestCore_EnableAudit(t *testing.T) {
 	}
 }
 
+func TestCore_EnableAudit_Filter(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		return &NoopAudit{
+			Config: config,
+		}, nil
+	}
+
+	me := &MountEntry{
+		Table:   auditTableType,
+		Path:    "bad",
+		Type:    "noop",
+		Options: map[string]string{"filter": `path = "sys/*"`},
+	}
+	if err := c.enableAudit(me); err == nil {
+		t.Fatalf("expected error for invalid filter")
+	}
+
+	me = &MountEntry{
+		Table:   auditTableType,
+		Path:    "sensitive",
+		Type:    "noop",
+		Options: map[string]string{"filter": `mount_path == "sys/" or mount_path == "auth/*"`},
+	}
+	if err := c.enableAudit(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	me = &MountEntry{
+		Table: auditTableType,
+		Path:  "all",
+		Type:  "noop",
+	}
+	if err := c.enableAudit(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, path := range []string{"secret/foo", "sys/mounts"} {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        path,
+			ClientToken: root,
+		}
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	sensitive := c.auditBroker.backends["sensitive/"].backend.(*NoopAudit)
+	all := c.auditBroker.backends["all/"].backend.(*NoopAudit)
+	if len(sensitive.Req) != 1 || sensitive.Req[0].Path != "sys/mounts" {
+		t.Fatalf("bad: %#v", sensitive.Req)
+	}
+	if len(all.Req) != 2 {
+		t.Fatalf("bad: %#v", all.Req)
+	}
+}
+
+func TestCore_EnableAudit_Mode(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		return &NoopAudit{
+			Config: config,
+		}, nil
+	}
+
+	me := &MountEntry{
+		Table:   auditTableType,
+		Path:    "bad",
+		Type:    "noop",
+		Options: map[string]string{"mode": "sometimes"},
+	}
+	if err := c.enableAudit(me); err == nil || !strings.Contains(err.Error(), "invalid mode") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A best effort backend requires a durable one
+	me = &MountEntry{
+		Table:   auditTableType,
+		Path:    "syslog",
+		Type:    "noop",
+		Options: map[string]string{"mode": auditModeBestEffort},
+	}
+	if err := c.enableAudit(me); err == nil || !strings.Contains(err.Error(), "requires another audit backend") {
+		t.Fatalf("err: %v", err)
+	}
+
+	durable := &MountEntry{
+		Table:   auditTableType,
+		Path:    "file",
+		Type:    "noop",
+		Options: map[string]string{"mode": auditModeRequired},
+	}
+	if err := c.enableAudit(durable); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.enableAudit(me); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if be := c.auditBroker.backends["syslog/"]; be.mode != auditModeBestEffort || be.retry == nil {
+		t.Fatalf("bad: %#v", be)
+	}
+
+	// The durable backend cannot be disabled while the best effort one remains
+	if _, err := c.disableAudit("file"); err == nil || !strings.Contains(err.Error(), "best effort backends remain") {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.disableAudit("syslog"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.disableAudit("file"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestCore_EnableAudit_MixedFailures(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
 	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
@@ -363,8 +479,8 @@ func TestAuditBroker_LogRequest(t *testing.T) {
 	b := NewAuditBroker(l)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil)
-	b.Register("bar", a2, nil)
+	b.Register("foo", a1, nil, nil, "")
+	b.Register("bar", a2, nil, nil, "")
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -440,8 +556,8 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 	b := NewAuditBroker(l)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil)
-	b.Register("bar", a2, nil)
+	b.Register("foo", a1, nil, nil, "")
+	b.Register("bar", a2, nil, nil, "")
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -526,6 +642,168 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 	}
 }
 
+func TestAuditBroker_Filter(t *testing.T) {
+	l := logformat.NewVaultLogger(log.LevelTrace)
+	b := NewAuditBroker(l)
+	b.mountPath = func(path string) string {
+		return strings.SplitAfterN(path, "/", 2)[0]
+	}
+	filter, err := audit.ParseFilter(`mount_path == "sys/" and operation != "read"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a1 := &NoopAudit{}
+	b.Register("foo", a1, nil, filter, "")
+
+	headersConf := &AuditedHeadersConfig{
+		Headers: make(map[string]*auditedHeaderSettings),
+	}
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "sys/mounts",
+	}
+
+	// Requests filtered out of every backend are not rejected
+	a1.ReqErr = fmt.Errorf("failed")
+	a1.RespErr = fmt.Errorf("failed")
+	if err := b.LogRequest(nil, req, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.LogResponse(nil, req, nil, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a1.Req) != 0 || len(a1.Resp) != 0 {
+		t.Fatalf("bad: %#v %#v", a1.Req, a1.Resp)
+	}
+
+	req.Operation = logical.UpdateOperation
+	if err := b.LogRequest(nil, req, headersConf, nil); !errwrap.Contains(err, "no audit backend succeeded in logging the request") {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.LogResponse(nil, req, nil, headersConf, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// Other backends still log the requests filtered out of one
+	a2 := &NoopAudit{}
+	b.Register("bar", a2, nil, nil, "")
+	req.Operation = logical.ReadOperation
+	if err := b.LogRequest(nil, req, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a2.Req) != 1 {
+		t.Fatalf("bad: %#v", a2.Req)
+	}
+}
+
+// flakyAudit is an audit backend failing while it is down, safe to use from
+// the retry buffers
+type flakyAudit struct {
+	NoopAudit
+
+	l     sync.Mutex
+	down  bool
+	paths []string
+}
+
+func (f *flakyAudit) setDown(down bool) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.down = down
+}
+
+func (f *flakyAudit) logged() []string {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return append([]string(nil), f.paths...)
+}
+
+func (f *flakyAudit) LogRequest(a *logical.Auth, r *logical.Request, err error) error {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.down {
+		return fmt.Errorf("down")
+	}
+	f.paths = append(f.paths, r.Path)
+	return nil
+}
+
+func TestAuditBroker_Mode(t *testing.T) {
+	minWait := auditRetryMinWait
+	auditRetryMinWait = 10 * time.Millisecond
+	defer func() {
+		auditRetryMinWait = minWait
+	}()
+
+	l := logformat.NewVaultLogger(log.LevelTrace)
+	b := NewAuditBroker(l)
+	defer b.Close()
+	required := &NoopAudit{}
+	other := &NoopAudit{}
+	flaky := &flakyAudit{}
+	b.Register("required", required, nil, nil, auditModeRequired)
+	b.Register("other", other, nil, nil, "")
+	b.Register("flaky", flaky, nil, nil, auditModeBestEffort)
+
+	headersConf := &AuditedHeadersConfig{
+		Headers: make(map[string]*auditedHeaderSettings),
+	}
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "sys/mounts",
+	}
+
+	// A failing required backend rejects the request, even if another one
+	// logs it
+	required.ReqErr = fmt.Errorf("failed")
+	required.RespErr = fmt.Errorf("failed")
+	if err := b.LogRequest(nil, req, headersConf, nil); !errwrap.Contains(err, `required audit backend "required" failed to log the request`) {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.LogResponse(nil, req, nil, headersConf, nil); err == nil || !strings.Contains(err.Error(), `required audit backend "required" failed`) {
+		t.Fatalf("err: %v", err)
+	}
+	required.ReqErr = nil
+	required.RespErr = nil
+
+	// A failing best effort backend does not, and logs the entries again in
+	// order once it recovers
+	flaky.setDown(true)
+	for _, path := range []string{"sys/a", "sys/b"} {
+		req.Path = path
+		if err := b.LogRequest(nil, req, headersConf, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if !b.backends["flaky"].retry.pending() {
+		t.Fatal("expected buffered entries")
+	}
+	flaky.setDown(false)
+	req.Path = "sys/c"
+	if err := b.LogRequest(nil, req, headersConf, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for b.backends["flaky"].retry.pending() {
+		if time.Now().After(deadline) {
+			t.Fatal("buffered entries were not logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if logged := flaky.logged(); !reflect.DeepEqual(logged, []string{"sys/mounts", "sys/a", "sys/b", "sys/c"}) {
+		t.Fatalf("bad: %v", logged)
+	}
+
+	// The best effort backends do not count as logging the request
+	other.ReqErr = fmt.Errorf("failed")
+	required.ReqErr = fmt.Errorf("failed")
+	b.Deregister("required")
+	if err := b.LogRequest(nil, req, headersConf, nil); !errwrap.Contains(err, "no audit backend succeeded in logging the request") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestAuditBroker_AuditHeaders(t *testing.T) {
 	logger := logformat.NewVaultLogger(log.LevelTrace)
 	b := NewAuditBroker(logger)
@@ -533,8 +811,8 @@ func TestAuditBroker_AuditHeaders(t *testing.T) {
 	view := NewBarrierView(barrier, "headers/")
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil)
-	b.Register("bar", a2, nil)
+	b.Register("foo", a1, nil, nil, "")
+	b.Register("bar", a2, nil, nil, "")
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -8,6 +8,7 @@ import (
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
 )
 
 const (
@@ -325,6 +326,9 @@ func (c *Core) persistAuth(table *MountTable, localOnly bool) error {
 		}
 	}
 
+	// The tables are written together, so that they are consistent with
+	// each other if the physical backend supports transactions
+	var txns []TxnEntry
 	if !localOnly {
 		// Marshal the table
 		compressedBytes, err := jsonutil.EncodeJSONAndCompress(nonLocalAuth, nil)
@@ -333,17 +337,13 @@ func (c *Core) persistAuth(table *MountTable, localOnly bool) error {
 			return err
 		}
 
-		// Create an entry
-		entry := &Entry{
-			Key:   coreAuthConfigPath,
-			Value: compressedBytes,
-		}
-
-		// Write to the physical backend
-		if err := c.barrier.Put(entry); err != nil {
-			c.logger.Error("core: failed to persist auth table", "error", err)
-			return err
-		}
+		txns = append(txns, TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &Entry{
+				Key:   coreAuthConfigPath,
+				Value: compressedBytes,
+			},
+		})
 	}
 
 	// Repeat with local auth
@@ -353,13 +353,17 @@ func (c *Core) persistAuth(table *MountTable, localOnly bool) error {
 		return err
 	}
 
-	entry := &Entry{
-		Key:   coreLocalAuthConfigPath,
-		Value: compressedBytes,
-	}
+	txns = append(txns, TxnEntry{
+		Operation: physical.PutOperation,
+		Entry: &Entry{
+			Key:   coreLocalAuthConfigPath,
+			Value: compressedBytes,
+		},
+	})
 
-	if err := c.barrier.Put(entry); err != nil {
-		c.logger.Error("core: failed to persist local auth table", "error", err)
+	// Write to the physical backend
+	if err := c.barrier.Transaction(txns); err != nil {
+		c.logger.Error("core: failed to persist auth table", "error", err)
 		return err
 	}
 
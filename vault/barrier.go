@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
 )
 
 var (
@@ -148,6 +149,18 @@ type BarrierStorage interface {
 	// List is used ot list all the keys under a given
 	// prefix, up to the next prefix.
 	List(prefix string) ([]string, error)
+
+	// Transaction is used to apply several puts and deletes together. They
+	// are applied atomically, in a single request, if the physical backend
+	// supports transactions, and one after the other otherwise.
+	Transaction(txns []TxnEntry) error
+}
+
+// TxnEntry is a put or a delete applied as part of a barrier transaction.
+// The entry of a delete only needs a key.
+type TxnEntry struct {
+	Operation physical.Operation
+	Entry     *Entry
 }
 
 // BarrierEncryptor is the in memory only interface that does not actually
@@ -176,4 +189,8 @@ func (e *Entry) Logical() *logical.StorageEntry {
 type KeyInfo struct {
 	Term        int
 	InstallTime time.Time
+
+	// Encryptions is the number of values encrypted with the key since it
+	// was installed or the barrier was unsealed
+	Encryptions uint64
 }
@@ -7,8 +7,10 @@ import (
 	"crypto/subtle"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -43,6 +45,11 @@ type barrierInit struct {
 // bit. AES-GCM is high performance, and provides both confidentiality
 // and integrity.
 type AESGCMBarrier struct {
+	// encryptions is the number of values encrypted with the active key
+	// since it was installed or the barrier was unsealed. It is accessed
+	// atomically, and first in the struct for alignment.
+	encryptions uint64
+
 	backend physical.Backend
 
 	l      sync.RWMutex
@@ -61,6 +68,9 @@ type AESGCMBarrier struct {
 	// future versioning of barrier implementations. It's var instead
 	// of const to allow for testing
 	currentAESGCMVersionByte byte
+
+	// randReader is the source of randomness of the generated keys
+	randReader io.Reader
 }
 
 // NewAESGCMBarrier is used to construct a new barrier that uses
@@ -71,6 +81,7 @@ func NewAESGCMBarrier(physical physical.Backend) (*AESGCMBarrier, error) {
 		sealed:  true,
 		cache:   make(map[uint32]cipher.AEAD),
 		currentAESGCMVersionByte: byte(AESGCMVersion2),
+		randReader:               rand.Reader,
 	}
 	return b, nil
 }
@@ -194,7 +205,7 @@ func (b *AESGCMBarrier) persistKeyring(keyring *Keyring) error {
 func (b *AESGCMBarrier) GenerateKey() ([]byte, error) {
 	// Generate a 256bit key
 	buf := make([]byte, 2*aes.BlockSize)
-	_, err := rand.Read(buf)
+	_, err := io.ReadFull(b.randReader, buf)
 	return buf, err
 }
 
@@ -421,6 +432,7 @@ func (b *AESGCMBarrier) Seal() error {
 	b.keyring.Zeroize(true)
 	b.keyring = nil
 	b.sealed = true
+	atomic.StoreUint64(&b.encryptions, 0)
 	return nil
 }
 
@@ -460,6 +472,7 @@ func (b *AESGCMBarrier) Rotate() (uint32, error) {
 
 	// Swap the keyrings
 	b.keyring = newKeyring
+	atomic.StoreUint64(&b.encryptions, 0)
 	return newTerm, nil
 }
 
@@ -566,6 +579,7 @@ func (b *AESGCMBarrier) ActiveKeyInfo() (*KeyInfo, error) {
 	info := &KeyInfo{
 		Term:        int(term),
 		InstallTime: key.InstallTime,
+		Encryptions: atomic.LoadUint64(&b.encryptions),
 	}
 	return info, nil
 }
@@ -645,6 +659,7 @@ func (b *AESGCMBarrier) Put(entry *Entry) error {
 		Key:   entry.Key,
 		Value: b.encrypt(entry.Key, term, primary, entry.Value),
 	}
+	atomic.AddUint64(&b.encryptions, 1)
 	return b.backend.Put(pe)
 }
 
@@ -691,6 +706,57 @@ func (b *AESGCMBarrier) Delete(key string) error {
 	return b.backend.Delete(key)
 }
 
+// Transaction is used to apply several puts and deletes together
+func (b *AESGCMBarrier) Transaction(txns []TxnEntry) error {
+	defer metrics.MeasureSince([]string{"barrier", "transaction"}, time.Now())
+	b.l.RLock()
+	defer b.l.RUnlock()
+	if b.sealed {
+		return ErrBarrierSealed
+	}
+
+	term := b.keyring.ActiveTerm()
+	primary, err := b.aeadForTerm(term)
+	if err != nil {
+		return err
+	}
+
+	ptxns := make([]physical.TxnEntry, 0, len(txns))
+	for _, txn := range txns {
+		pe := &physical.Entry{
+			Key: txn.Entry.Key,
+		}
+		switch txn.Operation {
+		case physical.PutOperation:
+			pe.Value = b.encrypt(txn.Entry.Key, term, primary, txn.Entry.Value)
+			atomic.AddUint64(&b.encryptions, 1)
+		case physical.DeleteOperation:
+		default:
+			return fmt.Errorf("%q is not a supported transaction operation", txn.Operation)
+		}
+		ptxns = append(ptxns, physical.TxnEntry{
+			Operation: txn.Operation,
+			Entry:     pe,
+		})
+	}
+
+	if txnl, ok := b.backend.(physical.Transactional); ok {
+		return txnl.Transaction(ptxns)
+	}
+
+	for _, txn := range ptxns {
+		if txn.Operation == physical.PutOperation {
+			err = b.backend.Put(txn.Entry)
+		} else {
+			err = b.backend.Delete(txn.Entry.Key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // List is used ot list all the keys under a given
 // prefix, up to the next prefix.
 func (b *AESGCMBarrier) List(prefix string) ([]string, error) {
@@ -856,6 +922,7 @@ func (b *AESGCMBarrier) Encrypt(key string, plaintext []byte) ([]byte, error) {
 	}
 
 	ciphertext := b.encrypt(key, term, primary, plaintext)
+	atomic.AddUint64(&b.encryptions, 1)
 	return ciphertext, nil
 }
 
@@ -375,6 +375,61 @@ func TestAESGCMBarrier_UpgradeV1toV2(t *testing.T) {
 	}
 }
 
+func TestAESGCMBarrier_Transaction(t *testing.T) {
+	backends := []physical.Backend{
+		physical.NewInmem(logger),
+		physical.NewTransactionalInmem(logger),
+	}
+	for _, inm := range backends {
+		b, err := NewAESGCMBarrier(inm)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		key, _ := b.GenerateKey()
+		b.Initialize(key)
+		b.Unseal(key)
+
+		if err := b.Put(&Entry{Key: "foo", Value: []byte("bar")}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		err = b.Transaction([]TxnEntry{
+			{Operation: physical.PutOperation, Entry: &Entry{Key: "zip", Value: []byte("zap")}},
+			{Operation: physical.DeleteOperation, Entry: &Entry{Key: "foo"}},
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		out, err := b.Get("zip")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if out == nil || string(out.Value) != "zap" {
+			t.Fatalf("bad: %#v", out)
+		}
+		if out, err := b.Get("foo"); err != nil || out != nil {
+			t.Fatalf("bad: %#v %v", out, err)
+		}
+
+		// The values are encrypted in the physical backend
+		pe, err := inm.Get("zip")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if pe == nil || bytes.Contains(pe.Value, []byte("zap")) {
+			t.Fatalf("bad: %#v", pe)
+		}
+
+		err = b.Transaction([]TxnEntry{
+			{Operation: physical.GetOperation, Entry: &Entry{Key: "zip"}},
+		})
+		if err == nil {
+			t.Fatalf("expected error for unsupported operation")
+		}
+	}
+}
+
 func TestEncrypt_Unique(t *testing.T) {
 
 	inm := physical.NewInmem(logger)
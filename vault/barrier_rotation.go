@@ -0,0 +1,375 @@
+package vault
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/jsonutil"
+)
+
+const (
+	// keyRotationConfigPath is the path of the automatic rotation policy of
+	// the barrier key
+	keyRotationConfigPath = "core/rotation-config"
+
+	// keyRotationStatusPath is the path where the number of encryptions with
+	// the active key is saved, so that it survives seals and restarts
+	keyRotationStatusPath = "core/rotation-status"
+
+	// maxKeyRotationOperations is the default and largest number of
+	// encryptions before the barrier key is rotated. With random 96 bit
+	// nonces, NIST recommends that an AES-GCM key encrypts at most 2^32
+	// values; this leaves a margin for the encryptions of the standbys and
+	// those made between two checks.
+	maxKeyRotationOperations = 3865470566
+
+	// minKeyRotationOperations is the smallest number of encryptions before
+	// the barrier key is rotated, so that the keyring does not grow too fast
+	minKeyRotationOperations = 1000000
+
+	// minKeyRotationInterval is the smallest interval between two automatic
+	// rotations of the barrier key
+	minKeyRotationInterval = 24 * time.Hour
+)
+
+// keyRotationCheckInterval is how often the policy is checked against the
+// active key. It's a var to allow for testing.
+var keyRotationCheckInterval = time.Minute
+
+// KeyRotationConfig is the automatic rotation policy of the barrier key.
+// The key is rotated once it has encrypted MaxOperations values, or once
+// it is older than Interval if it is set.
+type KeyRotationConfig struct {
+	Disabled      bool          `json:"disabled"`
+	MaxOperations uint64        `json:"max_operations"`
+	Interval      time.Duration `json:"interval"`
+}
+
+// defaultKeyRotationConfig returns the policy used until one is configured
+func defaultKeyRotationConfig() *KeyRotationConfig {
+	return &KeyRotationConfig{
+		MaxOperations: maxKeyRotationOperations,
+	}
+}
+
+// Validate checks that the policy is within the supported bounds
+func (c *KeyRotationConfig) Validate() error {
+	switch {
+	case c.MaxOperations < minKeyRotationOperations || c.MaxOperations > maxKeyRotationOperations:
+		return fmt.Errorf("max_operations must be between %d and %d", minKeyRotationOperations, maxKeyRotationOperations)
+	case c.Interval != 0 && c.Interval < minKeyRotationInterval:
+		return fmt.Errorf("interval must be 0 or at least %s", minKeyRotationInterval)
+	}
+	return nil
+}
+
+// keyRotationStatus is the saved number of encryptions of a key term
+type keyRotationStatus struct {
+	Term        uint32 `json:"term"`
+	Encryptions uint64 `json:"encryptions"`
+}
+
+// keyRotationManager applies the rotation policy of the barrier key on the
+// active node
+type keyRotationManager struct {
+	l      sync.Mutex
+	config *KeyRotationConfig
+
+	// status holds the encryptions with the active key saved before the
+	// barrier was unsealed, which the barrier does not count
+	status keyRotationStatus
+
+	stopCh chan struct{}
+}
+
+// setupKeyRotation loads the rotation policy and starts applying it
+func (c *Core) setupKeyRotation() error {
+	config := defaultKeyRotationConfig()
+	entry, err := c.barrier.Get(keyRotationConfigPath)
+	if err != nil {
+		return errwrap.Wrapf("failed to read key rotation config: {{err}}", err)
+	}
+	if entry != nil {
+		if err := jsonutil.DecodeJSON(entry.Value, config); err != nil {
+			return errwrap.Wrapf("failed to decode key rotation config: {{err}}", err)
+		}
+	}
+
+	info, err := c.barrier.ActiveKeyInfo()
+	if err != nil {
+		return err
+	}
+	m := &keyRotationManager{
+		config: config,
+		status: keyRotationStatus{
+			Term: uint32(info.Term),
+		},
+		stopCh: make(chan struct{}),
+	}
+
+	entry, err = c.barrier.Get(keyRotationStatusPath)
+	if err != nil {
+		return errwrap.Wrapf("failed to read key rotation status: {{err}}", err)
+	}
+	if entry != nil {
+		var status keyRotationStatus
+		if err := jsonutil.DecodeJSON(entry.Value, &status); err != nil {
+			return errwrap.Wrapf("failed to decode key rotation status: {{err}}", err)
+		}
+		if status.Term == m.status.Term {
+			m.status.Encryptions = status.Encryptions
+		}
+	}
+
+	c.keyRotation = m
+	go c.runKeyRotation(m)
+	return nil
+}
+
+// teardownKeyRotation stops applying the rotation policy and saves the
+// number of encryptions with the active key
+func (c *Core) teardownKeyRotation() error {
+	m := c.keyRotation
+	if m == nil {
+		return nil
+	}
+	close(m.stopCh)
+	c.keyRotation = nil
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	return c.saveKeyRotationStatus(m)
+}
+
+// runKeyRotation periodically checks the active key against the policy
+// until the manager is stopped
+func (c *Core) runKeyRotation(m *keyRotationManager) {
+	ticker := time.NewTicker(keyRotationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.checkKeyRotation(m); err != nil {
+				c.logger.Error("core: failed to check barrier key rotation", "error", err)
+			}
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// checkKeyRotation rotates the barrier key if it exceeds the policy, and
+// saves the number of encryptions with the active key otherwise
+func (c *Core) checkKeyRotation(m *keyRotationManager) error {
+	if c.ReplicationState() == consts.ReplicationSecondary {
+		return nil
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	info, encryptions, err := c.activeKeyEncryptions(m)
+	if err != nil {
+		return err
+	}
+
+	var reason string
+	switch {
+	case m.config.Disabled:
+	case encryptions >= m.config.MaxOperations:
+		reason = "max_operations"
+	case m.config.Interval > 0 && time.Since(info.InstallTime) >= m.config.Interval:
+		reason = "interval"
+	}
+	if reason == "" {
+		return c.saveKeyRotationStatus(m)
+	}
+
+	c.logger.Info("core: rotating barrier key", "term", info.Term, "encryptions", encryptions, "reason", reason)
+	_, err = c.rotateBarrierKeyLocked(m)
+	return err
+}
+
+// activeKeyEncryptions returns the active key along with the number of
+// values it has encrypted, including those before the last unseal. The
+// lock of the manager must be held.
+func (c *Core) activeKeyEncryptions(m *keyRotationManager) (*KeyInfo, uint64, error) {
+	info, err := c.barrier.ActiveKeyInfo()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The key was rotated by another node
+	if uint32(info.Term) != m.status.Term {
+		m.status = keyRotationStatus{
+			Term: uint32(info.Term),
+		}
+	}
+	return info, m.status.Encryptions + info.Encryptions, nil
+}
+
+// saveKeyRotationStatus persists the number of encryptions with the active
+// key. The lock of the manager must be held.
+func (c *Core) saveKeyRotationStatus(m *keyRotationManager) error {
+	info, encryptions, err := c.activeKeyEncryptions(m)
+	if err != nil {
+		return err
+	}
+
+	buf, err := jsonutil.EncodeJSON(&keyRotationStatus{
+		Term:        uint32(info.Term),
+		Encryptions: encryptions,
+	})
+	if err != nil {
+		return errwrap.Wrapf("failed to encode key rotation status: {{err}}", err)
+	}
+	if err := c.barrier.Put(&Entry{
+		Key:   keyRotationStatusPath,
+		Value: buf,
+	}); err != nil {
+		return errwrap.Wrapf("failed to save key rotation status: {{err}}", err)
+	}
+	return nil
+}
+
+// rotateBarrierKey installs a new barrier key and returns its term
+func (c *Core) rotateBarrierKey() (uint32, error) {
+	m := c.keyRotation
+	if m == nil {
+		return 0, ErrBarrierSealed
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	return c.rotateBarrierKeyLocked(m)
+}
+
+// rotateBarrierKeyLocked installs a new barrier key. The lock of the
+// manager must be held.
+func (c *Core) rotateBarrierKeyLocked(m *keyRotationManager) (uint32, error) {
+	// Rotate to the new term
+	newTerm, err := c.barrier.Rotate()
+	if err != nil {
+		c.logger.Error("core: failed to create new encryption key", "error", err)
+		return 0, err
+	}
+	c.logger.Info("core: installed new encryption key", "term", newTerm)
+	m.status = keyRotationStatus{
+		Term: newTerm,
+	}
+
+	// In HA mode, we need to an upgrade path for the standby instances
+	if c.ha != nil {
+		// Create the upgrade path to the new term
+		if err := c.barrier.CreateUpgrade(newTerm); err != nil {
+			c.logger.Error("core: failed to create new upgrade", "term", newTerm, "error", err)
+		}
+
+		// Schedule the destroy of the upgrade path
+		time.AfterFunc(keyRotateGracePeriod, func() {
+			if err := c.barrier.DestroyUpgrade(newTerm); err != nil {
+				c.logger.Error("core: failed to destroy upgrade", "term", newTerm, "error", err)
+			}
+		})
+	}
+
+	// Write to the canary path, which will force a synchronous truing during
+	// replication
+	if err := c.barrier.Put(&Entry{
+		Key:   coreKeyringCanaryPath,
+		Value: []byte(fmt.Sprintf("new-rotation-term-%d", newTerm)),
+	}); err != nil {
+		c.logger.Error("core: error saving keyring canary", "error", err)
+		return 0, fmt.Errorf("failed to save keyring canary: %v", err)
+	}
+
+	if err := c.saveKeyRotationStatus(m); err != nil {
+		c.logger.Error("core: error saving key rotation status", "error", err)
+	}
+	return newTerm, nil
+}
+
+// keyRotationConfig returns a copy of the rotation policy
+func (c *Core) keyRotationConfig() (*KeyRotationConfig, error) {
+	m := c.keyRotation
+	if m == nil {
+		return nil, ErrBarrierSealed
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	config := *m.config
+	return &config, nil
+}
+
+// setKeyRotationConfig persists and applies a new rotation policy
+func (c *Core) setKeyRotationConfig(config *KeyRotationConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	m := c.keyRotation
+	if m == nil {
+		return ErrBarrierSealed
+	}
+
+	buf, err := jsonutil.EncodeJSON(config)
+	if err != nil {
+		return errwrap.Wrapf("failed to encode key rotation config: {{err}}", err)
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	if err := c.barrier.Put(&Entry{
+		Key:   keyRotationConfigPath,
+		Value: buf,
+	}); err != nil {
+		return errwrap.Wrapf("failed to save key rotation config: {{err}}", err)
+	}
+	m.config = config
+	return nil
+}
+
+// keyRotationStatusInfo returns the number of encryptions with the active
+// key, and the estimated time of its next automatic rotation. The estimate
+// is zero if the rotation is disabled.
+func (c *Core) keyRotationStatusInfo() (*KeyInfo, uint64, time.Time, error) {
+	m := c.keyRotation
+	if m == nil {
+		return nil, 0, time.Time{}, ErrBarrierSealed
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	info, encryptions, err := c.activeKeyEncryptions(m)
+	if err != nil {
+		return nil, 0, time.Time{}, err
+	}
+	if m.config.Disabled {
+		return info, encryptions, time.Time{}, nil
+	}
+
+	var next time.Time
+	if m.config.Interval > 0 {
+		next = info.InstallTime.Add(m.config.Interval)
+	}
+
+	// Extrapolate the rate of encryptions since the key was installed
+	now := time.Now()
+	if elapsed := now.Sub(info.InstallTime); encryptions > 0 && elapsed > 0 {
+		var remaining uint64
+		if encryptions < m.config.MaxOperations {
+			remaining = m.config.MaxOperations - encryptions
+		}
+		if d := float64(elapsed) * float64(remaining) / float64(encryptions); d < math.MaxInt64 {
+			estimate := now.Add(time.Duration(d))
+			if next.IsZero() || estimate.Before(next) {
+				next = estimate
+			}
+		}
+	}
+	return info, encryptions, next, nil
+}
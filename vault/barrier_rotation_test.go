@@ -0,0 +1,141 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_KeyRotationConfig(t *testing.T) {
+	c, keys, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/rotate/config")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["enabled"] != true || resp.Data["max_operations"] != uint64(maxKeyRotationOperations) || resp.Data["interval"] != int64(0) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Out of bounds policies are rejected
+	for _, data := range []map[string]interface{}{
+		{"max_operations": 10},
+		{"max_operations": int64(maxKeyRotationOperations) + 1},
+		{"interval": "1h"},
+	} {
+		req = logical.TestRequest(t, logical.UpdateOperation, "sys/rotate/config")
+		req.ClientToken = root
+		req.Data = data
+		if resp, err := c.HandleRequest(req); err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %v, got: %v %#v", data, err, resp)
+		}
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/rotate/config")
+	req.ClientToken = root
+	req.Data["max_operations"] = minKeyRotationOperations
+	req.Data["interval"] = "48h"
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The policy survives a seal
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(c, TestKeyCopy(key)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	config, err := c.keyRotationConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if config.Disabled || config.MaxOperations != minKeyRotationOperations || config.Interval != 48*time.Hour {
+		t.Fatalf("bad: %#v", config)
+	}
+}
+
+func TestCore_KeyRotation(t *testing.T) {
+	c, keys, root := TestCoreUnsealed(t)
+
+	if err := c.barrier.Put(&Entry{Key: "foo", Value: []byte("bar")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	info, err := c.barrier.ActiveKeyInfo()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Encryptions == 0 {
+		t.Fatalf("encryptions not counted")
+	}
+
+	// The key is not rotated below the policy
+	if err := c.checkKeyRotation(c.keyRotation); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info, _ := c.barrier.ActiveKeyInfo(); info.Term != 1 {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	// The encryptions are counted across seals
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(c, TestKeyCopy(key)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	_, encryptions, next, err := c.keyRotationStatusInfo()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if encryptions <= info.Encryptions {
+		t.Fatalf("bad: %d <= %d", encryptions, info.Encryptions)
+	}
+	if next.IsZero() {
+		t.Fatalf("next rotation not estimated")
+	}
+
+	// The key is rotated once it exceeds the policy
+	c.keyRotation.l.Lock()
+	c.keyRotation.status.Encryptions = maxKeyRotationOperations
+	c.keyRotation.l.Unlock()
+	if err := c.checkKeyRotation(c.keyRotation); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/key-status")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["term"] != 2 || resp.Data["encryptions"].(uint64) >= maxKeyRotationOperations {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Nothing is rotated once disabled
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/rotate/config")
+	req.ClientToken = root
+	req.Data["enabled"] = false
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.keyRotation.l.Lock()
+	c.keyRotation.status.Encryptions = maxKeyRotationOperations
+	c.keyRotation.l.Unlock()
+	if err := c.checkKeyRotation(c.keyRotation); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info, _ := c.barrier.ActiveKeyInfo(); info.Term != 2 {
+		t.Fatalf("bad: %#v", info)
+	}
+	if _, _, next, _ := c.keyRotationStatusInfo(); !next.IsZero() {
+		t.Fatalf("bad: %v", next)
+	}
+}
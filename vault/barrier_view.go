@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
 )
 
 // BarrierView wraps a SecurityBarrier and ensures all access is automatically
@@ -18,6 +19,10 @@ type BarrierView struct {
 	barrier  BarrierStorage
 	prefix   string
 	readonly bool
+
+	// sealWrap is set if the sensitive values of the view are also
+	// encrypted by the seal
+	sealWrap *sealWrapper
 }
 
 // NewBarrierView takes an underlying security barrier and returns
@@ -60,6 +65,11 @@ func (v *BarrierView) Get(key string) (*logical.StorageEntry, error) {
 	if entry != nil {
 		entry.Key = v.truncateKey(entry.Key)
 	}
+	if v.sealWrap != nil {
+		if entry.Value, err = v.sealWrap.unwrap(entry.Value); err != nil {
+			return nil, err
+		}
+	}
 
 	return &logical.StorageEntry{
 		Key:   entry.Key,
@@ -83,6 +93,12 @@ func (v *BarrierView) Put(entry *logical.StorageEntry) error {
 		Key:   expandedKey,
 		Value: entry.Value,
 	}
+	if v.sealWrap != nil {
+		var err error
+		if nested.Value, err = v.sealWrap.wrap(expandedKey, entry.Value); err != nil {
+			return err
+		}
+	}
 	return v.barrier.Put(nested)
 }
 
@@ -102,10 +118,42 @@ func (v *BarrierView) Delete(key string) error {
 	return v.barrier.Delete(expandedKey)
 }
 
+// Transaction applies the puts and deletes of the entries, whose keys are
+// relative to the view, together
+func (v *BarrierView) Transaction(txns []TxnEntry) error {
+	if v.readonly {
+		return logical.ErrReadOnly
+	}
+
+	nested := make([]TxnEntry, 0, len(txns))
+	for _, txn := range txns {
+		if err := v.sanityCheck(txn.Entry.Key); err != nil {
+			return err
+		}
+		entry := &Entry{
+			Key: v.expandKey(txn.Entry.Key),
+		}
+		if txn.Operation == physical.PutOperation {
+			entry.Value = txn.Entry.Value
+			if v.sealWrap != nil {
+				var err error
+				if entry.Value, err = v.sealWrap.wrap(entry.Key, txn.Entry.Value); err != nil {
+					return err
+				}
+			}
+		}
+		nested = append(nested, TxnEntry{
+			Operation: txn.Operation,
+			Entry:     entry,
+		})
+	}
+	return v.barrier.Transaction(nested)
+}
+
 // SubView constructs a nested sub-view using the given prefix
 func (v *BarrierView) SubView(prefix string) *BarrierView {
 	sub := v.expandKey(prefix)
-	return &BarrierView{barrier: v.barrier, prefix: sub, readonly: v.readonly}
+	return &BarrierView{barrier: v.barrier, prefix: sub, readonly: v.readonly, sealWrap: v.sealWrap}
 }
 
 // expandKey is used to expand to the full key path with the prefix
@@ -28,12 +28,20 @@ func (c *Core) Capabilities(token, path string) ([]string, error) {
 		return []string{DenyCapability}, nil
 	}
 
+	loginEntry, err := c.loginTokenEntry(te)
+	if err != nil {
+		return nil, err
+	}
+
 	var policies []*Policy
 	for _, tePolicy := range te.Policies {
 		policy, err := c.policyStore.GetPolicy(tePolicy)
 		if err != nil {
 			return nil, err
 		}
+		if policy != nil {
+			policy = policy.render(loginEntry)
+		}
 		policies = append(policies, policy)
 	}
 
@@ -0,0 +1,368 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// controlGroupSubPath is the sub-path used for the control group view.
+	// This is nested under the system view.
+	controlGroupSubPath = "control-group/"
+
+	// controlGroupCubbyholePath is where a held request is stored in the
+	// cubbyhole of the token returned to its requester, so that the request
+	// is destroyed with the token
+	controlGroupCubbyholePath = "cubbyhole/control-group"
+
+	// defaultControlGroupTTL is how long the requests are held by the
+	// control groups which do not set a TTL
+	defaultControlGroupTTL = 24 * time.Hour
+)
+
+// controlGroupRequestEntry is the storage entry tracking the authorizations
+// of a held request, keyed by the accessor of its token. The requester and
+// the approvers are identified by their login identities.
+type controlGroupRequestEntry struct {
+	RequestPath      string                       `json:"request_path"`
+	RequestOperation logical.Operation            `json:"request_operation"`
+	Requester        string                       `json:"requester"`
+	RequesterID      string                       `json:"requester_id"`
+	Approvals        int                          `json:"approvals"`
+	ApproverPolicies []string                     `json:"approver_policies"`
+	Authorizations   []*controlGroupAuthorization `json:"authorizations"`
+	CreationTime     time.Time                    `json:"creation_time"`
+	ExpireTime       time.Time                    `json:"expire_time"`
+}
+
+// controlGroupAuthorization is the authorization of a held request by an
+// approver
+type controlGroupAuthorization struct {
+	Approver   string    `json:"approver"`
+	ApproverID string    `json:"approver_id"`
+	Time       time.Time `json:"time"`
+}
+
+// heldRequest is a request held by a control group, replayed on behalf of
+// its requester once authorized. The requester token is referenced by its
+// accessor, so that the token itself is not stored.
+type heldRequest struct {
+	Path           string                 `json:"path"`
+	Operation      logical.Operation      `json:"operation"`
+	Data           map[string]interface{} `json:"data"`
+	ClientAccessor string                 `json:"client_accessor"`
+}
+
+// approved returns whether the held request has enough authorizations
+func (e *controlGroupRequestEntry) approved() bool {
+	return len(e.Authorizations) >= e.Approvals
+}
+
+// authorizedBy returns whether the approver with the login identity
+// authorized the held request
+func (e *controlGroupRequestEntry) authorizedBy(approverID string) bool {
+	for _, authz := range e.Authorizations {
+		if authz.ApproverID == approverID {
+			return true
+		}
+	}
+	return false
+}
+
+// status returns the state of the held request, as returned by the
+// control group endpoints
+func (e *controlGroupRequestEntry) status() map[string]interface{} {
+	approvers := make([]string, 0, len(e.Authorizations))
+	for _, authz := range e.Authorizations {
+		approvers = append(approvers, authz.Approver)
+	}
+	return map[string]interface{}{
+		"approved":          e.approved(),
+		"request_path":      e.RequestPath,
+		"request_operation": string(e.RequestOperation),
+		"requester":         e.Requester,
+		"approvals":         e.Approvals,
+		"approver_policies": e.ApproverPolicies,
+		"authorizations":    approvers,
+		"creation_time":     e.CreationTime,
+		"expire_time":       e.ExpireTime,
+	}
+}
+
+// loginIdentity returns the identity of the user behind the token, taken
+// from the entry returned by loginTokenEntry: an ID made of the UUID of the
+// auth mount and of the user name the backend gave at login, and a readable
+// name made of the mount path and the user name. Empty strings are returned
+// if the token has no login identity.
+func (c *Core) loginIdentity(te *TokenEntry) (string, string, error) {
+	te, err := c.loginTokenEntry(te)
+	if err != nil || te == nil {
+		return "", "", err
+	}
+	mountPath := c.router.MatchingMount(te.Path)
+	mountEntry := c.router.MatchingMountEntry(te.Path)
+	if mountEntry == nil {
+		return "", "", nil
+	}
+	mountPath = strings.TrimPrefix(mountPath, credentialRoutePrefix)
+
+	// The display name of the token is the user name prefixed with the
+	// mount path at login
+	source := strings.Replace(mountPath, "/", "-", -1)
+	if !strings.HasPrefix(te.DisplayName, source) || te.DisplayName == source {
+		return "", "", nil
+	}
+	user := strings.TrimPrefix(te.DisplayName, source)
+
+	return mountEntry.UUID + "/" + user, mountPath + user, nil
+}
+
+// controlGroupView returns the view of the held requests
+func (c *Core) controlGroupView() *BarrierView {
+	return c.systemBarrierView.SubView(controlGroupSubPath)
+}
+
+// controlGroupRequest returns the held request of the token accessor, or
+// nil if it doesn't exist or expired
+func (c *Core) controlGroupRequest(accessor string) (*controlGroupRequestEntry, error) {
+	if accessor == "" {
+		return nil, nil
+	}
+	raw, err := c.controlGroupView().Get(accessor)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	var entry controlGroupRequestEntry
+	if err := raw.DecodeJSON(&entry); err != nil {
+		return nil, err
+	}
+
+	// The token of an expired request is revoked by the expiration manager,
+	// so only its entry is left to clean up
+	if time.Now().After(entry.ExpireTime) {
+		if err := c.controlGroupView().Delete(accessor); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// setControlGroupRequest persists the held request of the token accessor
+func (c *Core) setControlGroupRequest(accessor string, entry *controlGroupRequestEntry) error {
+	se, err := logical.StorageEntryJSON(accessor, entry)
+	if err != nil {
+		return err
+	}
+	return c.controlGroupView().Put(se)
+}
+
+// holdControlGroupRequest holds the request until it is authorized by the
+// control group. The requester gets a response-wrapping token, whose
+// accessor is given to the approvers and which is unwrapped to replay the
+// request once authorized.
+func (c *Core) holdControlGroupRequest(req *logical.Request, requester *TokenEntry, cg *ControlGroup) (*logical.Response, error) {
+	// The requester must be known so that it cannot authorize its own
+	// request, and its token must have an accessor to replay the request
+	requesterID, requesterName, err := c.loginIdentity(requester)
+	if err != nil {
+		c.logger.Error("core: failed to look up control group requester", "error", err)
+		return nil, ErrInternalError
+	}
+	if requesterID == "" || requester.Accessor == "" {
+		return logical.ErrorResponse("requests held by control groups require a service token issued by a login"), logical.ErrPermissionDenied
+	}
+
+	creationTime := time.Now()
+	te := TokenEntry{
+		Path:           req.Path,
+		Policies:       []string{responseWrappingPolicyName},
+		CreationTime:   creationTime.Unix(),
+		TTL:            cg.TTL,
+		ExplicitMaxTTL: cg.TTL,
+	}
+	if err := c.tokenStore.create(&te); err != nil {
+		c.logger.Error("core: failed to create control group token", "error", err)
+		return nil, ErrInternalError
+	}
+
+	held, err := json.Marshal(&heldRequest{
+		Path:           req.Path,
+		Operation:      req.Operation,
+		Data:           req.Data,
+		ClientAccessor: requester.Accessor,
+	})
+	if err != nil {
+		c.tokenStore.Revoke(te.ID)
+		c.logger.Error("core: failed to encode held request", "error", err)
+		return nil, ErrInternalError
+	}
+	cubbyResp, err := c.router.Route(&logical.Request{
+		Operation:   logical.CreateOperation,
+		Path:        controlGroupCubbyholePath,
+		ClientToken: te.ID,
+		Data: map[string]interface{}{
+			"request": string(held),
+		},
+	})
+	if err == nil && cubbyResp != nil && cubbyResp.IsError() {
+		err = fmt.Errorf("%v", cubbyResp.Data["error"])
+	}
+	if err != nil {
+		c.tokenStore.Revoke(te.ID)
+		c.logger.Error("core: failed to store held request", "error", err)
+		return nil, ErrInternalError
+	}
+
+	entry := &controlGroupRequestEntry{
+		RequestPath:      req.Path,
+		RequestOperation: req.Operation,
+		Requester:        requesterName,
+		RequesterID:      requesterID,
+		Approvals:        cg.Approvals,
+		ApproverPolicies: cg.ApproverPolicies,
+		CreationTime:     creationTime,
+		ExpireTime:       creationTime.Add(cg.TTL),
+	}
+	if err := c.setControlGroupRequest(te.Accessor, entry); err != nil {
+		c.tokenStore.Revoke(te.ID)
+		c.logger.Error("core: failed to persist control group request", "error", err)
+		return nil, ErrInternalError
+	}
+
+	auth := &logical.Auth{
+		ClientToken: te.ID,
+		Policies:    te.Policies,
+		LeaseOptions: logical.LeaseOptions{
+			TTL:       te.TTL,
+			Renewable: false,
+		},
+	}
+	if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
+		c.tokenStore.Revoke(te.ID)
+		c.logger.Error("core: failed to register control group token lease", "request_path", req.Path, "error", err)
+		return nil, ErrInternalError
+	}
+
+	return &logical.Response{
+		WrapInfo: &logical.ResponseWrapInfo{
+			Token:        te.ID,
+			Accessor:     te.Accessor,
+			TTL:          cg.TTL,
+			CreationTime: creationTime,
+		},
+	}, nil
+}
+
+// unwrapControlGroupRequest replays the request held for the token once it
+// is authorized, and returns its response as an unwrapped response. The
+// returned bool is false if the token does not hold a request.
+func (c *Core) unwrapControlGroupRequest(token string) (*logical.Response, bool, error) {
+	cubbyResp, err := c.router.Route(&logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        controlGroupCubbyholePath,
+		ClientToken: token,
+	})
+	if err != nil {
+		return nil, true, fmt.Errorf("error looking up held request: %v", err)
+	}
+	if cubbyResp == nil || cubbyResp.IsError() || cubbyResp.Data == nil {
+		return nil, false, nil
+	}
+	rawRequest, ok := cubbyResp.Data["request"].(string)
+	if !ok {
+		return nil, true, fmt.Errorf("could not decode held request")
+	}
+
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		return nil, true, err
+	}
+	if te == nil {
+		return nil, true, logical.ErrPermissionDenied
+	}
+	entry, err := c.controlGroupRequest(te.Accessor)
+	if err != nil {
+		return nil, true, err
+	}
+	if entry == nil {
+		return logical.ErrorResponse("control group request not found"), true, logical.ErrInvalidRequest
+	}
+	if !entry.approved() {
+		return logical.ErrorResponse("request has not been authorized"), true, logical.ErrPermissionDenied
+	}
+
+	var held heldRequest
+	if err := jsonutil.DecodeJSON([]byte(rawRequest), &held); err != nil {
+		return nil, true, fmt.Errorf("could not decode held request: %v", err)
+	}
+
+	// The request is replayed with the requester token, which must still be
+	// valid
+	accessorEntry, err := c.tokenStore.lookupByAccessor(held.ClientAccessor)
+	if _, ok := err.(*logical.StatusBadRequest); err != nil && !ok {
+		return nil, true, err
+	}
+	if accessorEntry.TokenID == "" {
+		return logical.ErrorResponse("requester token not found"), true, logical.ErrPermissionDenied
+	}
+
+	// The request is replayed once
+	if err := c.tokenStore.Revoke(token); err != nil {
+		return nil, true, err
+	}
+	if err := c.controlGroupView().Delete(te.Accessor); err != nil {
+		return nil, true, err
+	}
+
+	requestID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, true, err
+	}
+	replay := &logical.Request{
+		ID:                   requestID,
+		Operation:            held.Operation,
+		Path:                 held.Path,
+		Data:                 held.Data,
+		ClientToken:          accessorEntry.TokenID,
+		ControlGroupAccessor: te.Accessor,
+	}
+	resp, _, err := c.handleRequest(replay)
+	if err != nil {
+		return resp, true, err
+	}
+
+	unwrapped := &logical.Response{
+		Data: map[string]interface{}{},
+	}
+	if resp == nil {
+		unwrapped.Data[logical.HTTPStatusCode] = 204
+		return unwrapped, true, nil
+	}
+	if resp.Secret != nil {
+		resp.Secret.InternalData = nil
+	}
+	if resp.Auth != nil {
+		resp.Auth.InternalData = nil
+	}
+	httpResp := logical.LogicalResponseToHTTPResponse(resp)
+	httpResp.RequestID = replay.ID
+	body, err := json.Marshal(httpResp)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to encode replayed response: %v", err)
+	}
+	unwrapped.Data[logical.HTTPStatusCode] = 200
+	unwrapped.Data[logical.HTTPRawBody] = body
+	unwrapped.Data[logical.HTTPContentType] = "application/json"
+	return unwrapped, true, nil
+}
@@ -0,0 +1,208 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestCore_ControlGroup(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	policies := map[string]string{
+		"requester": `
+path "secret/foo" {
+	capabilities = ["create", "read", "update"]
+	control_group {
+		approvals = 2
+		approver_policies = ["security"]
+	}
+}
+
+path "auth/token/create" {
+	capabilities = ["update"]
+}`,
+		"security": `
+path "sys/control-group/authorize" {
+	capabilities = ["update"]
+}`,
+		"sealer": `
+path "sys/seal" {
+	capabilities = ["update", "sudo"]
+	control_group {
+		approvals = 1
+		approver_policies = ["security"]
+	}
+}`,
+	}
+	for name, rules := range policies {
+		req := logical.TestRequest(t, logical.UpdateOperation, "sys/policy/"+name)
+		req.ClientToken = root
+		req.Data["rules"] = rules
+		if resp, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+	}
+
+	noop := &NoopBackend{
+		Login: []string{"login"},
+	}
+	c.credentialBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+	for _, mount := range []string{"userpass", "ldap"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, "sys/auth/"+mount)
+		req.ClientToken = root
+		req.Data["type"] = "noop"
+		if resp, err := c.HandleRequest(req); err != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+	}
+
+	login := func(mount, user string, policies []string) string {
+		noop.Response = &logical.Response{
+			Auth: &logical.Auth{
+				DisplayName: user,
+				Policies:    policies,
+			},
+		}
+		resp, err := c.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "auth/" + mount + "/login",
+		})
+		if err != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp.Auth.ClientToken
+	}
+	createToken := func(parent, name string, policies []string) string {
+		req := logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+		req.ClientToken = parent
+		req.Data["display_name"] = name
+		req.Data["policies"] = policies
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp.Auth.ClientToken
+	}
+	alice := login("userpass", "alice", []string{"requester", "security"})
+	bob := login("userpass", "bob", []string{"security"})
+	bobAgain := login("userpass", "bob", []string{"security"})
+	carol := login("ldap", "carol", []string{"security"})
+
+	// The display names of the tokens created through the token store are
+	// chosen by their creators, so they can't identify approvers
+	spoofed := createToken(root, "userpass-carol", []string{"security"})
+
+	// The requests of tokens without a login identity aren't held
+	req := logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.ClientToken = createToken(root, "userpass-alice", []string{"requester"})
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("expected error holding request of token without login identity")
+	}
+
+	// The write is held until it is authorized
+	req = logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.ClientToken = createToken(alice, "alice", []string{"requester"})
+	req.Data["bar"] = "baz"
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp == nil || resp.WrapInfo == nil || resp.WrapInfo.Token == "" || resp.WrapInfo.Accessor == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	wrapToken, accessor := resp.WrapInfo.Token, resp.WrapInfo.Accessor
+
+	read := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	read.ClientToken = root
+	if resp, err := c.HandleRequest(read); err != nil || resp != nil {
+		t.Fatalf("secret written before authorization: err:%v resp:%#v", err, resp)
+	}
+
+	status := logical.TestRequest(t, logical.UpdateOperation, "sys/control-group/request")
+	status.ClientToken = alice
+	status.Data["accessor"] = accessor
+	resp, err = c.HandleRequest(status)
+	if err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["approved"] != false || resp.Data["request_path"] != "secret/foo" || resp.Data["requester"] != "userpass/alice" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	unwrap := logical.TestRequest(t, logical.UpdateOperation, "sys/wrapping/unwrap")
+	unwrap.ClientToken = wrapToken
+	if _, err := c.HandleRequest(unwrap); err == nil {
+		t.Fatalf("expected error unwrapping unauthorized request")
+	}
+
+	authorize := func(token string) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.UpdateOperation, "sys/control-group/authorize")
+		req.ClientToken = token
+		req.Data["accessor"] = accessor
+		return c.HandleRequest(req)
+	}
+
+	// Requesters cannot authorize their own requests, including those made
+	// with the tokens they created
+	if _, err := authorize(alice); err == nil {
+		t.Fatalf("expected error authorizing own request")
+	}
+	if _, err := authorize(createToken(alice, "userpass-bob", []string{"security"})); err == nil {
+		t.Fatalf("expected error authorizing own request with child token")
+	}
+	if _, err := authorize(spoofed); err == nil {
+		t.Fatalf("expected error authorizing with token store token")
+	}
+
+	// Authorizations are counted once per user, whatever the token
+	for _, token := range []string{bob, bobAgain} {
+		resp, err = authorize(token)
+		if err != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		if resp.Data["approved"] != false {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+	}
+	resp, err = authorize(carol)
+	if err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["approved"] != true || !reflect.DeepEqual(resp.Data["authorizations"], []string{"userpass/bob", "ldap/carol"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Unwrapping replays the request
+	unwrap = logical.TestRequest(t, logical.UpdateOperation, "sys/wrapping/unwrap")
+	unwrap.ClientToken = wrapToken
+	resp, err = c.HandleRequest(unwrap)
+	if err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	resp, err = c.HandleRequest(read)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["bar"] != "baz" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The request is replayed once
+	unwrap = logical.TestRequest(t, logical.UpdateOperation, "sys/wrapping/unwrap")
+	unwrap.ClientToken = wrapToken
+	if _, err := c.HandleRequest(unwrap); err == nil {
+		t.Fatalf("expected error unwrapping twice")
+	}
+
+	// Sealing can't be held, so the control group denies it
+	if err := c.Seal(login("userpass", "dave", []string{"sealer"})); err == nil {
+		t.Fatalf("expected error sealing under a control group")
+	}
+	if sealed, err := c.Sealed(); err != nil || sealed {
+		t.Fatalf("sealed: %v err: %v", sealed, err)
+	}
+}
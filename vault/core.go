@@ -3,10 +3,12 @@ package vault
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/rand"
 	"crypto/subtle"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -23,10 +25,13 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/cidrutil"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/entropy"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/mlock"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/physical"
@@ -77,6 +82,14 @@ var (
 	// is attempted to be unsealed.
 	ErrNotInit = errors.New("Vault is not initialized")
 
+	// ErrSealMigrationRequired is returned if the Vault is unsealed
+	// without migrating the seal it was sealed by
+	ErrSealMigrationRequired = errors.New("Vault must be unsealed with the migrate option to migrate its seal")
+
+	// ErrNoSealMigration is returned if the Vault is unsealed with the
+	// migrate option without a seal to migrate from
+	ErrNoSealMigration = errors.New("Vault has no seal to migrate from")
+
 	// ErrInternalError is returned when we don't want to leak
 	// any information about an internal error
 	ErrInternalError = errors.New("internal error")
@@ -162,9 +175,21 @@ type Core struct {
 	// Our Seal, for seal configuration information
 	seal Seal
 
+	// migrationSeal is the seal the Vault is being migrated from, until it
+	// is unsealed with the keys of that seal
+	migrationSeal Seal
+	migrationLock sync.RWMutex
+
 	// barrier is the security barrier wrapping the physical backend
 	barrier SecurityBarrier
 
+	// entropySource is the external source of randomness augmenting the
+	// randomness of the host when generating keys, or nil if not configured
+	entropySource entropy.Sourcer
+
+	// secureRandomReader is the source of randomness of the generated keys
+	secureRandomReader io.Reader
+
 	// router is responsible for managing the mount points for logical backends.
 	router *Router
 
@@ -220,6 +245,26 @@ type Core struct {
 	// change underneath a calling function
 	authLock sync.RWMutex
 
+	// userLockouts tracks the failed logins of the users of the auth
+	// mounts
+	userLockouts *userLockouts
+
+	// usedTOTPCodes tracks the TOTP codes used for login MFA, to prevent
+	// their replay
+	usedTOTPCodes *usedTOTPCodes
+
+	// quotas holds the rate limit and lease count quotas. It is loaded after
+	// unseal.
+	quotas *quotaManager
+
+	// activityLog counts the distinct clients of the auth mounts. It is
+	// loaded after unseal.
+	activityLog *activityLog
+
+	// metricsHelper reads back the metrics for sys/metrics, or is nil if the
+	// server does not collect them
+	metricsHelper *metricsutil.MetricsHelper
+
 	// audit is loaded after unseal since it is a protected
 	// configuration
 	audit *MountTable
@@ -252,6 +297,15 @@ type Core struct {
 	// token store is used to manage authentication tokens
 	tokenStore *TokenStore
 
+	// corsConfig is the CORS configuration enforced by the HTTP handler. It
+	// is loaded after unseal.
+	corsConfig *CORSConfig
+	corsLock   sync.RWMutex
+
+	// keyRotation applies the automatic rotation policy of the barrier key.
+	// It is loaded after unseal on the active node.
+	keyRotation *keyRotationManager
+
 	// metricsCh is used to stop the metrics streaming
 	metricsCh chan struct{}
 
@@ -349,6 +403,14 @@ type CoreConfig struct {
 
 	Seal Seal `json:"seal" structs:"seal" mapstructure:"seal"`
 
+	// UnwrapSeal is the seal to migrate from, if the Vault was sealed by
+	// another seal than Seal. May be nil.
+	UnwrapSeal Seal `json:"unwrap_seal" structs:"unwrap_seal" mapstructure:"unwrap_seal"`
+
+	// EntropySource, if set, augments the randomness of the host with
+	// randomness from an HSM or key management service when generating keys
+	EntropySource entropy.Sourcer `json:"-" structs:"-" mapstructure:"-"`
+
 	Logger log.Logger `json:"logger" structs:"logger" mapstructure:"logger"`
 
 	// Disables the LRU cache on the physical backend
@@ -374,6 +436,9 @@ type CoreConfig struct {
 
 	EnableUI bool `json:"ui" structs:"ui" mapstructure:"ui"`
 
+	// MetricsHelper, if set, serves the metrics of the server on sys/metrics
+	MetricsHelper *metricsutil.MetricsHelper `json:"-" structs:"-" mapstructure:"-"`
+
 	ReloadFuncs     *map[string][]ReloadFunc
 	ReloadFuncsLock *sync.RWMutex
 }
@@ -420,6 +485,8 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		redirectAddr:                     conf.RedirectAddr,
 		clusterAddr:                      conf.ClusterAddr,
 		seal:                             conf.Seal,
+		entropySource:                    conf.EntropySource,
+		secureRandomReader:               rand.Reader,
 		router:                           NewRouter(),
 		sealed:                           true,
 		standby:                          true,
@@ -430,11 +497,21 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		clusterName:                      conf.ClusterName,
 		clusterListenerShutdownCh:        make(chan struct{}),
 		clusterListenerShutdownSuccessCh: make(chan struct{}),
-	}
-
-	// Wrap the physical backend in a cache layer if enabled and not already wrapped
-	if _, isCache := conf.Physical.(*physical.Cache); !conf.DisableCache && !isCache {
-		c.physical = physical.NewCache(conf.Physical, conf.CacheSize, conf.Logger)
+		userLockouts:                     newUserLockouts(),
+		usedTOTPCodes:                    newUsedTOTPCodes(),
+		metricsHelper:                    conf.MetricsHelper,
+	}
+
+	// Wrap the physical backend in a cache layer if enabled and not already
+	// wrapped, keeping the support of transactions
+	_, isCache := conf.Physical.(*physical.Cache)
+	_, isTxnCache := conf.Physical.(*physical.TransactionalCache)
+	if !conf.DisableCache && !isCache && !isTxnCache {
+		if _, ok := conf.Physical.(physical.Transactional); ok {
+			c.physical = physical.NewTransactionalCache(conf.Physical, conf.CacheSize, conf.Logger)
+		} else {
+			c.physical = physical.NewCache(conf.Physical, conf.CacheSize, conf.Logger)
+		}
 	}
 
 	if !conf.DisableMlock {
@@ -453,12 +530,17 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		}
 	}
 
+	if conf.EntropySource != nil {
+		c.secureRandomReader = entropy.NewReader(conf.EntropySource)
+	}
+
 	// Construct a new AES-GCM barrier
-	var err error
-	c.barrier, err = NewAESGCMBarrier(c.physical)
+	barrier, err := NewAESGCMBarrier(c.physical)
 	if err != nil {
 		return nil, fmt.Errorf("barrier setup failed: %v", err)
 	}
+	barrier.randReader = c.secureRandomReader
+	c.barrier = barrier
 
 	if conf.HAPhysical != nil && conf.HAPhysical.HAEnabled() {
 		c.ha = conf.HAPhysical
@@ -507,6 +589,10 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	}
 	c.seal.SetCore(c)
 
+	if err := c.checkSealMigration(conf.UnwrapSeal); err != nil {
+		return nil, err
+	}
+
 	// Attempt unsealing with stored keys; if there are no stored keys this
 	// returns nil, otherwise returns nil or an error
 	storedKeyErr := c.UnsealWithStoredKeys()
@@ -580,8 +666,24 @@ func (c *Core) fetchACLandTokenEntry(req *logical.Request) (*ACL, *TokenEntry, e
 		return nil, nil, logical.ErrPermissionDenied
 	}
 
+	// Ensure the token is used from its bound CIDR blocks
+	if len(te.BoundCIDRs) > 0 {
+		if req.Connection == nil || req.Connection.RemoteAddr == "" {
+			return nil, nil, logical.ErrPermissionDenied
+		}
+		valid, err := cidrutil.IPBelongsToCIDRBlocksSlice(req.Connection.RemoteAddr, te.BoundCIDRs)
+		if err != nil || !valid {
+			return nil, nil, logical.ErrPermissionDenied
+		}
+	}
+
 	// Construct the corresponding ACL object
-	acl, err := c.policyStore.ACL(te.Policies...)
+	loginEntry, err := c.loginTokenEntry(te)
+	if err != nil {
+		c.logger.Error("core: failed to look up login token", "error", err)
+		return nil, nil, ErrInternalError
+	}
+	acl, err := c.policyStore.TokenACL(loginEntry, te.Policies...)
 	if err != nil {
 		c.logger.Error("core: failed to construct ACL", "error", err)
 		return nil, nil, ErrInternalError
@@ -590,12 +692,14 @@ func (c *Core) fetchACLandTokenEntry(req *logical.Request) (*ACL, *TokenEntry, e
 	return acl, te, nil
 }
 
-func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, error) {
+// checkToken validates the token of the request against the ACLs. It also
+// returns the control group holding the request, if any.
+func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, *ControlGroup, error) {
 	defer metrics.MeasureSince([]string{"core", "check_token"}, time.Now())
 
 	acl, te, err := c.fetchACLandTokenEntry(req)
 	if err != nil {
-		return nil, te, err
+		return nil, te, nil, err
 	}
 
 	// Check if this is a root protected path
@@ -617,9 +721,9 @@ func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, err
 		default:
 			c.logger.Error("core: failed to run existence check", "error", err)
 			if _, ok := err.(errutil.UserError); ok {
-				return nil, nil, err
+				return nil, nil, nil, err
 			} else {
-				return nil, nil, ErrInternalError
+				return nil, nil, nil, ErrInternalError
 			}
 		}
 
@@ -642,10 +746,10 @@ func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, err
 	// allowed so we can decrement the use count.
 	allowed, rootPrivs := acl.AllowOperation(req)
 	if !allowed {
-		return nil, te, logical.ErrPermissionDenied
+		return nil, te, nil, logical.ErrPermissionDenied
 	}
 	if rootPath && !rootPrivs {
-		return nil, te, logical.ErrPermissionDenied
+		return nil, te, nil, logical.ErrPermissionDenied
 	}
 
 	// Create the auth response
@@ -655,7 +759,22 @@ func (c *Core) checkToken(req *logical.Request) (*logical.Auth, *TokenEntry, err
 		Metadata:    te.Meta,
 		DisplayName: te.DisplayName,
 	}
-	return auth, te, nil
+
+	var controlGroup *ControlGroup
+	if req.Operation != logical.HelpOperation {
+		controlGroup = acl.ControlGroup(req.Path)
+	}
+	return auth, te, controlGroup, nil
+}
+
+// MetricsResponse returns the metrics of the server in the given format. It
+// does not require the Vault to be unsealed, so that the listeners allowing
+// it can serve them without authentication.
+func (c *Core) MetricsResponse(format string) (*logical.Response, error) {
+	if c.metricsHelper == nil {
+		return logical.ErrorResponse("metrics are not collected by this server"), logical.ErrUnsupportedPath
+	}
+	return c.metricsHelper.ResponseForFormat(format)
 }
 
 // Sealed checks if the Vault is current sealed
@@ -805,13 +924,12 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	defer metrics.MeasureSince([]string{"core", "unseal"}, time.Now())
 
 	// Verify the key length
-	min, max := c.barrier.KeyLength()
-	max += shamir.ShareOverhead
-	if len(key) < min {
-		return false, &ErrInvalidKey{fmt.Sprintf("key is shorter than minimum %d bytes", min)}
+	if err := c.checkKeyLength(key); err != nil {
+		return false, err
 	}
-	if len(key) > max {
-		return false, &ErrInvalidKey{fmt.Sprintf("key is longer than maximum %d bytes", max)}
+
+	if c.sealMigration() != nil {
+		return false, ErrSealMigrationRequired
 	}
 
 	// Get the seal configuration
@@ -844,6 +962,19 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	return false, nil
 }
 
+// checkKeyLength verifies the length of a key part given to unseal
+func (c *Core) checkKeyLength(key []byte) error {
+	min, max := c.barrier.KeyLength()
+	max += shamir.ShareOverhead
+	if len(key) < min {
+		return &ErrInvalidKey{fmt.Sprintf("key is shorter than minimum %d bytes", min)}
+	}
+	if len(key) > max {
+		return &ErrInvalidKey{fmt.Sprintf("key is longer than maximum %d bytes", max)}
+	}
+	return nil
+}
+
 func (c *Core) unsealPart(config *SealConfig, key []byte) ([]byte, error) {
 	// Check if we already have this piece
 	if c.unlockInfo != nil {
@@ -1068,6 +1199,13 @@ func (c *Core) sealInitCommon(req *logical.Request) (retErr error) {
 		return retErr
 	}
 
+	// The operation can't be held until a control group authorizes it, so
+	// the control groups set on the path deny it
+	if acl.ControlGroup(req.Path) != nil {
+		retErr = multierror.Append(retErr, logical.ErrPermissionDenied)
+		return retErr
+	}
+
 	//Seal the Vault
 	err = c.sealInternal()
 	if err != nil {
@@ -1153,6 +1291,13 @@ func (c *Core) StepDown(req *logical.Request) (retErr error) {
 		return retErr
 	}
 
+	// The operation can't be held until a control group authorizes it, so
+	// the control groups set on the path deny it
+	if acl.ControlGroup(req.Path) != nil {
+		retErr = multierror.Append(retErr, logical.ErrPermissionDenied)
+		return retErr
+	}
+
 	select {
 	case c.manualStepDownCh <- struct{}{}:
 	default:
@@ -1280,6 +1425,18 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupAuditedHeadersConfig(); err != nil {
 		return err
 	}
+	if err := c.setupQuotas(); err != nil {
+		return err
+	}
+	if err := c.setupActivityLog(); err != nil {
+		return err
+	}
+	if err := c.setupKeyRotation(); err != nil {
+		return err
+	}
+	if err := c.loadCORSConfig(); err != nil {
+		return err
+	}
 	if c.ha != nil {
 		if err := c.startClusterListener(); err != nil {
 			return err
@@ -1311,6 +1468,15 @@ func (c *Core) preSeal() error {
 
 	c.stopClusterListener()
 
+	if err := c.teardownKeyRotation(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down key rotation: {{err}}", err))
+	}
+	if err := c.teardownActivityLog(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down activity log: {{err}}", err))
+	}
+	if err := c.teardownQuotas(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down quotas: {{err}}", err))
+	}
 	if err := c.teardownAudits(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error tearing down audits: {{err}}", err))
 	}
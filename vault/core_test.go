@@ -2074,3 +2074,41 @@ func TestCore_Standby_Rotate(t *testing.T) {
 		t.Fatalf("bad: %#v", resp)
 	}
 }
+
+// testEntropySource is an entropy source recording how many bytes were
+// requested from it
+type testEntropySource struct {
+	requested int
+}
+
+func (s *testEntropySource) GetRandom(bytes int) ([]byte, error) {
+	s.requested += bytes
+	return make([]byte, bytes), nil
+}
+
+func TestCore_EntropySource(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	conf := testCoreConfig(t, physical.NewInmem(logger), logger)
+	source := &testEntropySource{}
+	conf.EntropySource = source
+	c, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The master key and the first barrier key are augmented
+	TestCoreInit(t, c)
+	if source.requested < 64 {
+		t.Fatalf("bad: %d", source.requested)
+	}
+
+	// Backends get the bytes of the source through their system view
+	requested := source.requested
+	random, err := (dynamicSystemView{core: c}).GetRandom(16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(random) != 16 || source.requested != requested+16 {
+		t.Fatalf("bad: %d %d", len(random), source.requested)
+	}
+}
@@ -0,0 +1,137 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/strutil"
+)
+
+const (
+	// coreCORSConfigPath is the path of the CORS configuration
+	coreCORSConfigPath = "core/cors"
+)
+
+// StdAllowedHeaders are the headers the browsers may always send to the API
+var StdAllowedHeaders = []string{
+	"Content-Type",
+	"X-Requested-With",
+	"X-Vault-No-Request-Forwarding",
+	"X-Vault-Token",
+	"X-Vault-Wrap-Format",
+	"X-Vault-Wrap-TTL",
+}
+
+// CORSConfig is the cross-origin resource sharing configuration, allowing
+// the browser applications of the listed origins to call the API
+type CORSConfig struct {
+	Enabled          bool     `json:"enabled"`
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+}
+
+// Validate checks that the origins are either a wildcard or a list
+func (c *CORSConfig) Validate() error {
+	if len(c.AllowedOrigins) == 0 {
+		return fmt.Errorf("at least one allowed origin must be given")
+	}
+	if strutil.StrListContains(c.AllowedOrigins, "*") {
+		if len(c.AllowedOrigins) > 1 {
+			return fmt.Errorf("to allow all origins, '*' must be the only allowed origin")
+		}
+		if c.AllowCredentials {
+			return fmt.Errorf("credentials cannot be allowed for all origins")
+		}
+	}
+	return nil
+}
+
+// IsValidOrigin returns whether requests from the origin are allowed
+func (c *CORSConfig) IsValidOrigin(origin string) bool {
+	if !c.Enabled || origin == "" {
+		return false
+	}
+	if len(c.AllowedOrigins) == 1 && c.AllowedOrigins[0] == "*" {
+		return true
+	}
+	return strutil.StrListContains(c.AllowedOrigins, origin)
+}
+
+// AllowedHeadersList returns the standard and configured headers the
+// browsers may send
+func (c *CORSConfig) AllowedHeadersList() []string {
+	headers := append([]string{}, StdAllowedHeaders...)
+	for _, h := range c.AllowedHeaders {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if h != "" && !strutil.StrListContains(headers, h) {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+// CORSConfig returns the CORS configuration, which is disabled until it is
+// loaded or set. It must not be modified.
+func (c *Core) CORSConfig() *CORSConfig {
+	c.corsLock.RLock()
+	defer c.corsLock.RUnlock()
+	if c.corsConfig == nil {
+		return &CORSConfig{}
+	}
+	return c.corsConfig
+}
+
+// loadCORSConfig loads the CORS configuration from storage
+func (c *Core) loadCORSConfig() error {
+	entry, err := c.barrier.Get(coreCORSConfigPath)
+	if err != nil {
+		return errwrap.Wrapf("failed to read CORS config: {{err}}", err)
+	}
+
+	config := &CORSConfig{}
+	if entry != nil {
+		if err := jsonutil.DecodeJSON(entry.Value, config); err != nil {
+			return errwrap.Wrapf("failed to decode CORS config: {{err}}", err)
+		}
+	}
+
+	c.corsLock.Lock()
+	c.corsConfig = config
+	c.corsLock.Unlock()
+	return nil
+}
+
+// setCORSConfig persists and applies a CORS configuration. A nil
+// configuration disables CORS.
+func (c *Core) setCORSConfig(config *CORSConfig) error {
+	c.corsLock.Lock()
+	defer c.corsLock.Unlock()
+
+	if config == nil {
+		if err := c.barrier.Delete(coreCORSConfigPath); err != nil {
+			return errwrap.Wrapf("failed to delete CORS config: {{err}}", err)
+		}
+		c.corsConfig = &CORSConfig{}
+		return nil
+	}
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	buf, err := jsonutil.EncodeJSON(config)
+	if err != nil {
+		return errwrap.Wrapf("failed to encode CORS config: {{err}}", err)
+	}
+	if err := c.barrier.Put(&Entry{
+		Key:   coreCORSConfigPath,
+		Value: buf,
+	}); err != nil {
+		return errwrap.Wrapf("failed to save CORS config: {{err}}", err)
+	}
+	c.corsConfig = config
+	return nil
+}
@@ -1,9 +1,13 @@
 package vault
 
 import (
+	"crypto/rand"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/entropy"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -12,6 +16,8 @@ type dynamicSystemView struct {
 	mountEntry *MountEntry
 }
 
+var _ entropy.Sourcer = dynamicSystemView{}
+
 func (d dynamicSystemView) DefaultLeaseTTL() time.Duration {
 	def, _ := d.fetchTTLs()
 	return def
@@ -37,7 +43,12 @@ func (d dynamicSystemView) SudoPrivilege(path string, token string) bool {
 	}
 
 	// Construct the corresponding ACL object
-	acl, err := d.core.policyStore.ACL(te.Policies...)
+	loginEntry, err := d.core.loginTokenEntry(te)
+	if err != nil {
+		d.core.logger.Error("core: failed to look up login token", "error", err)
+		return false
+	}
+	acl, err := d.core.policyStore.TokenACL(loginEntry, te.Policies...)
 	if err != nil {
 		d.core.logger.Error("failed to retrieve ACL for token's policies", "token_policies", te.Policies, "error", err)
 		return false
@@ -87,3 +98,32 @@ func (d dynamicSystemView) ReplicationState() consts.ReplicationState {
 	d.core.clusterParamsLock.RUnlock()
 	return state
 }
+
+// GeneratePasswordFromPolicy generates a password from the named password
+// policy
+func (d dynamicSystemView) GeneratePasswordFromPolicy(policyName string) (string, error) {
+	policy, err := d.core.passwordPolicy(policyName)
+	if err != nil {
+		return "", err
+	}
+	if policy == nil {
+		return "", fmt.Errorf("password policy %q not found", policyName)
+	}
+
+	return policy.Generate(nil)
+}
+
+// GetRandom returns random bytes from the external entropy source of the
+// Vault, or from the host if none is configured. Backends mix them with the
+// randomness of the host to generate keys.
+func (d dynamicSystemView) GetRandom(bytes int) ([]byte, error) {
+	if d.core.entropySource != nil {
+		return d.core.entropySource.GetRandom(bytes)
+	}
+
+	buf := make([]byte, bytes)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -6,6 +6,7 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -15,6 +16,7 @@ import (
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
 )
 
 const (
@@ -50,6 +52,7 @@ const (
 // the ExpirationManager will handle doing automatic revocation.
 type ExpirationManager struct {
 	router     *Router
+	view       *BarrierView
 	idView     *BarrierView
 	tokenView  *BarrierView
 	tokenStore *TokenStore
@@ -57,6 +60,29 @@ type ExpirationManager struct {
 
 	pending     map[string]*time.Timer
 	pendingLock sync.Mutex
+
+	// leaseCounts are the numbers of pending leases under the paths of the
+	// lease count quotas, kept up to date with pending
+	leaseCounts map[string]int
+
+	// quitCh stops the restore of the leases, which runs in the background
+	// on unseal. The restores in progress are tracked by restoreWG.
+	quitCh    chan struct{}
+	restoreWG sync.WaitGroup
+
+	// restoring, restoreLoaded and restoreTotal track the progress of the
+	// restore, and are accessed atomically
+	restoring     int32
+	restoreLoaded int64
+	restoreTotal  int64
+
+	// revokeJobs are the revocations of leases by prefix running in the
+	// background, or recently finished. The running jobs, and the ones
+	// revoking synchronously, are tracked by revokeJobsWG and stopped with
+	// quitCh.
+	revokeJobs     map[string]*revokeJob
+	revokeJobsLock sync.Mutex
+	revokeJobsWG   sync.WaitGroup
 }
 
 // NewExpirationManager creates a new ExpirationManager that is backed
@@ -67,12 +93,16 @@ func NewExpirationManager(router *Router, view *BarrierView, ts *TokenStore, log
 
 	}
 	exp := &ExpirationManager{
-		router:     router,
-		idView:     view.SubView(leaseViewPrefix),
-		tokenView:  view.SubView(tokenViewPrefix),
-		tokenStore: ts,
-		logger:     logger,
-		pending:    make(map[string]*time.Timer),
+		router:      router,
+		view:        view,
+		idView:      view.SubView(leaseViewPrefix),
+		tokenView:   view.SubView(tokenViewPrefix),
+		tokenStore:  ts,
+		logger:      logger,
+		pending:     make(map[string]*time.Timer),
+		leaseCounts: make(map[string]int),
+		quitCh:      make(chan struct{}),
+		revokeJobs:  make(map[string]*revokeJob),
 	}
 	return exp
 }
@@ -92,14 +122,36 @@ func (c *Core) setupExpiration() error {
 	// Link the token store to this
 	c.tokenStore.SetExpirationManager(mgr)
 
-	// Restore the existing state
+	// Restore the existing state in the background, so that the Vault is
+	// usable while there are many leases to restore. The leases which are
+	// not restored yet are loaded from storage when they are used.
 	c.logger.Info("expiration: restoring leases")
-	if err := c.expiration.Restore(); err != nil {
-		return fmt.Errorf("expiration state restore failed: %v", err)
-	}
+	stopCh := mgr.startRestore()
+	go func() {
+		err := mgr.restore(stopCh)
+		mgr.restoreWG.Done()
+		if err != nil {
+			c.logger.Error("expiration: lease restore failed, sealing", "error", err)
+			if err := c.Shutdown(); err != nil {
+				c.logger.Error("expiration: failed to seal", "error", err)
+			}
+		}
+	}()
 	return nil
 }
 
+// LeaseRestoreProgress returns whether the leases are being restored by the
+// active node, along with the number of leases restored and the number of
+// leases to restore
+func (c *Core) LeaseRestoreProgress() (bool, int64, int64) {
+	c.metricsMutex.Lock()
+	defer c.metricsMutex.Unlock()
+	if c.expiration == nil {
+		return false, 0, 0
+	}
+	return c.expiration.RestoreProgress()
+}
+
 // stopExpiration is used to stop the expiration manager before
 // sealing the Vault.
 func (c *Core) stopExpiration() error {
@@ -115,10 +167,31 @@ func (c *Core) stopExpiration() error {
 }
 
 // Restore is used to recover the lease states when starting.
-// This is used after starting the vault.
+// This is used after starting the vault. The leases used while they are
+// restored are not affected, and Stop interrupts the restore.
 func (m *ExpirationManager) Restore() error {
+	stopCh := m.startRestore()
+	defer m.restoreWG.Done()
+	return m.restore(stopCh)
+}
+
+// startRestore registers a restore with the manager, and returns the
+// channel which is closed when the restore must stop. The caller must mark
+// the restore as done on restoreWG.
+func (m *ExpirationManager) startRestore() chan struct{} {
 	m.pendingLock.Lock()
 	defer m.pendingLock.Unlock()
+	m.restoreWG.Add(1)
+	return m.quitCh
+}
+
+// restore loads the leases and sets up their revocation timers until
+// stopCh is closed
+func (m *ExpirationManager) restore(stopCh chan struct{}) error {
+	atomic.StoreInt64(&m.restoreLoaded, 0)
+	atomic.StoreInt64(&m.restoreTotal, 0)
+	atomic.StoreInt32(&m.restoring, 1)
+	defer atomic.StoreInt32(&m.restoring, 0)
 
 	// Accumulate existing leases
 	m.logger.Debug("expiration: collecting leases")
@@ -127,6 +200,7 @@ func (m *ExpirationManager) Restore() error {
 		return fmt.Errorf("failed to scan for leases: %v", err)
 	}
 	m.logger.Debug("expiration: leases collected", "num_existing", len(existing))
+	atomic.StoreInt64(&m.restoreTotal, int64(len(existing)))
 
 	// Make the channels used for the worker pool
 	broker := make(chan string)
@@ -182,8 +256,7 @@ func (m *ExpirationManager) Restore() error {
 			case <-quit:
 				return
 
-			default:
-				broker <- leaseID
+			case broker <- leaseID:
 			}
 		}
 
@@ -200,52 +273,93 @@ func (m *ExpirationManager) Restore() error {
 
 			return err
 
-		case le := <-result:
-
-			// If there is no entry, nothing to restore
-			if le == nil {
-				continue
-			}
-
-			// If there is no expiry time, don't do anything
-			if le.ExpireTime.IsZero() {
-				continue
-			}
-
-			// Determine the remaining time to expiration
-			expires := le.ExpireTime.Sub(time.Now())
-			if expires <= 0 {
-				expires = minRevokeDelay
-			}
+		case <-stopCh:
+			close(quit)
+			m.logger.Debug("expiration: lease restore stopped")
+			return nil
 
-			// Setup revocation timer
-			m.pending[le.LeaseID] = time.AfterFunc(expires, func() {
-				m.expireID(le.LeaseID)
-			})
+		case le := <-result:
+			atomic.AddInt64(&m.restoreLoaded, 1)
+			m.restoreEntry(le)
 		}
 	}
 
 	// Let all go routines finish
 	wg.Wait()
 
-	if len(m.pending) > 0 {
+	m.pendingLock.Lock()
+	restored := len(m.pending)
+	m.pendingLock.Unlock()
+	if restored > 0 {
 		if m.logger.IsInfo() {
-			m.logger.Info("expire: leases restored", "restored_lease_count", len(m.pending))
+			m.logger.Info("expire: leases restored", "restored_lease_count", restored)
 		}
 	}
 
 	return nil
 }
 
+// restoreEntry sets up the revocation timer of a restored lease
+func (m *ExpirationManager) restoreEntry(le *leaseEntry) {
+	// If there is no entry, nothing to restore
+	if le == nil {
+		return
+	}
+
+	// If there is no expiry time, don't do anything
+	if le.ExpireTime.IsZero() {
+		return
+	}
+
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+
+	// The lease was renewed since it was loaded, so its timer is up to date
+	if _, ok := m.pending[le.LeaseID]; ok {
+		return
+	}
+
+	// Determine the remaining time to expiration
+	expires := le.ExpireTime.Sub(time.Now())
+	if expires <= 0 {
+		expires = minRevokeDelay
+	}
+
+	// Setup revocation timer
+	m.addPending(le.LeaseID, time.AfterFunc(expires, func() {
+		m.expireID(le.LeaseID)
+	}))
+}
+
+// RestoreProgress returns whether the leases are being restored, along with
+// the number of leases restored and the number of leases to restore
+func (m *ExpirationManager) RestoreProgress() (bool, int64, int64) {
+	return atomic.LoadInt32(&m.restoring) == 1,
+		atomic.LoadInt64(&m.restoreLoaded),
+		atomic.LoadInt64(&m.restoreTotal)
+}
+
 // Stop is used to prevent further automatic revocations.
 // This must be called before sealing the view.
 func (m *ExpirationManager) Stop() error {
+	// Stop the restore, which would otherwise set up timers again, and the
+	// revocation jobs
+	m.pendingLock.Lock()
+	close(m.quitCh)
+	m.quitCh = make(chan struct{})
+	m.pendingLock.Unlock()
+	m.restoreWG.Wait()
+	m.revokeJobsWG.Wait()
+
 	// Stop all the pending expiration timers
 	m.pendingLock.Lock()
 	for _, timer := range m.pending {
 		timer.Stop()
 	}
 	m.pending = make(map[string]*time.Timer)
+	for prefix := range m.leaseCounts {
+		m.leaseCounts[prefix] = 0
+	}
 	m.pendingLock.Unlock()
 	return nil
 }
@@ -285,23 +399,27 @@ func (m *ExpirationManager) revokeCommon(leaseID string, force, skipToken bool)
 		}
 	}
 
-	// Delete the entry
-	if err := m.deleteEntry(leaseID); err != nil {
-		return err
+	// Delete the entry along with the secondary index, but only if it's a
+	// leased secret (not auth)
+	txns := []TxnEntry{{
+		Operation: physical.DeleteOperation,
+		Entry:     &Entry{Key: leaseViewPrefix + leaseID},
+	}}
+	if le.Secret != nil && le.ClientToken != "" {
+		txns = append(txns, TxnEntry{
+			Operation: physical.DeleteOperation,
+			Entry:     &Entry{Key: tokenViewPrefix + m.indexKey(le.ClientToken, le.LeaseID)},
+		})
 	}
-
-	// Delete the secondary index, but only if it's a leased secret (not auth)
-	if le.Secret != nil {
-		if err := m.removeIndexByToken(le.ClientToken, le.LeaseID); err != nil {
-			return err
-		}
+	if err := m.view.Transaction(txns); err != nil {
+		return fmt.Errorf("failed to delete lease entry: %v", err)
 	}
 
 	// Clear the expiration handler
 	m.pendingLock.Lock()
 	if timer, ok := m.pending[leaseID]; ok {
 		timer.Stop()
-		delete(m.pending, leaseID)
+		m.removePending(leaseID)
 	}
 	m.pendingLock.Unlock()
 	return nil
@@ -360,28 +478,15 @@ func (m *ExpirationManager) RevokeByToken(te *TokenEntry) error {
 	return nil
 }
 
+// revokePrefixCommon revokes the leases under the prefix in parallel, and
+// returns once they are revoked
 func (m *ExpirationManager) revokePrefixCommon(prefix string, force bool) error {
-	// Ensure there is a trailing slash
-	if !strings.HasSuffix(prefix, "/") {
-		prefix = prefix + "/"
-	}
-
-	// Accumulate existing leases
-	sub := m.idView.SubView(prefix)
-	existing, err := logical.CollectKeys(sub)
-	if err != nil {
-		return fmt.Errorf("failed to scan for leases: %v", err)
-	}
+	job := newRevokeJob(prefix, force)
+	stopCh := m.startRevokeJob()
+	defer m.revokeJobsWG.Done()
 
-	// Revoke all the keys
-	for idx, suffix := range existing {
-		leaseID := prefix + suffix
-		if err := m.revokeCommon(leaseID, force, false); err != nil {
-			return fmt.Errorf("failed to revoke '%s' (%d / %d): %v",
-				leaseID, idx+1, len(existing), err)
-		}
-	}
-	return nil
+	m.runRevokeJob(job, stopCh)
+	return job.error()
 }
 
 // Renew is used to renew a secret using the given leaseID
@@ -515,9 +620,23 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 	if err != nil {
 		return "", err
 	}
+	// The leases of batch tokens are tracked by their parents, since batch
+	// tokens are not revoked
+	clientToken := req.ClientToken
+	if isBatchToken(clientToken) {
+		te, err := m.tokenStore.Lookup(clientToken)
+		if err != nil {
+			return "", err
+		}
+		if te == nil {
+			return "", fmt.Errorf("batch token not found")
+		}
+		clientToken = te.Parent
+	}
+
 	le := leaseEntry{
 		LeaseID:     path.Join(req.Path, leaseUUID),
-		ClientToken: req.ClientToken,
+		ClientToken: clientToken,
 		Path:        req.Path,
 		Data:        resp.Data,
 		Secret:      resp.Secret,
@@ -526,13 +645,27 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 	}
 
 	// Encode the entry
-	if err := m.persistEntry(&le); err != nil {
-		return "", err
+	buf, err := le.encode()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode lease entry: %v", err)
+	}
+
+	// Persist the entry along with the secondary index by token
+	txns := []TxnEntry{{
+		Operation: physical.PutOperation,
+		Entry:     &Entry{Key: leaseViewPrefix + le.LeaseID, Value: buf},
+	}}
+	if le.ClientToken != "" {
+		txns = append(txns, TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &Entry{
+				Key:   tokenViewPrefix + m.indexKey(le.ClientToken, le.LeaseID),
+				Value: []byte(le.LeaseID),
+			},
+		})
 	}
-
-	// Maintain secondary index by token
-	if err := m.createIndexByToken(le.ClientToken, le.LeaseID); err != nil {
-		return "", err
+	if err := m.view.Transaction(txns); err != nil {
+		return "", fmt.Errorf("failed to persist lease entry: %v", err)
 	}
 
 	// Setup revocation timer if there is a lease
@@ -612,6 +745,65 @@ func (m *ExpirationManager) FetchLeaseTimes(leaseID string) (*leaseEntry, error)
 	return ret, nil
 }
 
+// setLeaseCountPrefixes sets the prefixes under which the pending leases are
+// counted. The leases are only scanned for the prefixes not counted yet.
+func (m *ExpirationManager) setLeaseCountPrefixes(prefixes []string) {
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+
+	leaseCounts := make(map[string]int, len(prefixes))
+	for _, prefix := range prefixes {
+		if count, ok := m.leaseCounts[prefix]; ok {
+			leaseCounts[prefix] = count
+			continue
+		}
+		count := 0
+		for leaseID := range m.pending {
+			if strings.HasPrefix(leaseID, prefix) {
+				count++
+			}
+		}
+		leaseCounts[prefix] = count
+	}
+	m.leaseCounts = leaseCounts
+}
+
+// leaseCount returns the number of the pending leases under the prefix,
+// which must have been set with setLeaseCountPrefixes
+func (m *ExpirationManager) leaseCount(prefix string) int {
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+
+	return m.leaseCounts[prefix]
+}
+
+// addPending sets the expiration timer of a lease, counting the lease if it
+// is new. The caller must hold pendingLock.
+func (m *ExpirationManager) addPending(leaseID string, timer *time.Timer) {
+	if _, ok := m.pending[leaseID]; !ok {
+		for prefix := range m.leaseCounts {
+			if strings.HasPrefix(leaseID, prefix) {
+				m.leaseCounts[prefix]++
+			}
+		}
+	}
+	m.pending[leaseID] = timer
+}
+
+// removePending forgets the expiration timer of a lease. The caller must
+// hold pendingLock.
+func (m *ExpirationManager) removePending(leaseID string) {
+	if _, ok := m.pending[leaseID]; !ok {
+		return
+	}
+	delete(m.pending, leaseID)
+	for prefix := range m.leaseCounts {
+		if strings.HasPrefix(leaseID, prefix) {
+			m.leaseCounts[prefix]--
+		}
+	}
+}
+
 // updatePending is used to update a pending invocation for a lease
 func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Duration) {
 	m.pendingLock.Lock()
@@ -625,14 +817,14 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 		timer := time.AfterFunc(leaseTotal, func() {
 			m.expireID(le.LeaseID)
 		})
-		m.pending[le.LeaseID] = timer
+		m.addPending(le.LeaseID, timer)
 		return
 	}
 
 	// Delete the timer if the expiration time is zero
 	if ok && leaseTotal == 0 {
 		timer.Stop()
-		delete(m.pending, le.LeaseID)
+		m.removePending(le.LeaseID)
 		return
 	}
 
@@ -646,7 +838,7 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 func (m *ExpirationManager) expireID(leaseID string) {
 	// Clear from the pending expiration
 	m.pendingLock.Lock()
-	delete(m.pending, leaseID)
+	m.removePending(leaseID)
 	m.pendingLock.Unlock()
 
 	for attempt := uint(0); attempt < maxRevokeAttempts; attempt++ {
@@ -763,10 +955,16 @@ func (m *ExpirationManager) deleteEntry(leaseID string) error {
 	return nil
 }
 
+// indexKey returns the key of the secondary index from the token to a lease
+// entry, relative to the token view
+func (m *ExpirationManager) indexKey(token, leaseID string) string {
+	return m.tokenStore.SaltID(token) + "/" + m.tokenStore.SaltID(leaseID)
+}
+
 // createIndexByToken creates a secondary index from the token to a lease entry
 func (m *ExpirationManager) createIndexByToken(token, leaseID string) error {
 	ent := logical.StorageEntry{
-		Key:   m.tokenStore.SaltID(token) + "/" + m.tokenStore.SaltID(leaseID),
+		Key:   m.indexKey(token, leaseID),
 		Value: []byte(leaseID),
 	}
 	if err := m.tokenView.Put(&ent); err != nil {
@@ -777,7 +975,7 @@ func (m *ExpirationManager) createIndexByToken(token, leaseID string) error {
 
 // indexByToken looks up the secondary index from the token to a lease entry
 func (m *ExpirationManager) indexByToken(token, leaseID string) (*logical.StorageEntry, error) {
-	key := m.tokenStore.SaltID(token) + "/" + m.tokenStore.SaltID(leaseID)
+	key := m.indexKey(token, leaseID)
 	entry, err := m.tokenView.Get(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to look up secondary index entry")
@@ -785,15 +983,6 @@ func (m *ExpirationManager) indexByToken(token, leaseID string) (*logical.Storag
 	return entry, nil
 }
 
-// removeIndexByToken removes the secondary index from the token to a lease entry
-func (m *ExpirationManager) removeIndexByToken(token, leaseID string) error {
-	key := m.tokenStore.SaltID(token) + "/" + m.tokenStore.SaltID(leaseID)
-	if err := m.tokenView.Delete(key); err != nil {
-		return fmt.Errorf("failed to delete lease index entry: %v", err)
-	}
-	return nil
-}
-
 // lookupByToken is used to lookup all the leaseID's via the
 func (m *ExpirationManager) lookupByToken(token string) ([]string, error) {
 	// Scan via the index for sub-leases
@@ -0,0 +1,277 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// revokeWorkers is the number of leases a prefix revocation revokes in
+	// parallel
+	revokeWorkers = 16
+
+	// maxRevokeJobFailures is the number of failed leases a revocation job
+	// reports, the others being only counted
+	maxRevokeJobFailures = 100
+
+	// revokeJobRetention is how long the finished revocation jobs are
+	// reported
+	revokeJobRetention = 24 * time.Hour
+)
+
+const (
+	revokeJobRunning   = "running"
+	revokeJobCompleted = "completed"
+	revokeJobFailed    = "failed"
+	revokeJobCanceled  = "canceled"
+)
+
+// revokeJob is the revocation of the leases under a prefix. The revocations
+// which are not forced stop at the first failure.
+type revokeJob struct {
+	id        string
+	prefix    string
+	force     bool
+	startTime time.Time
+
+	// total, revoked and failed count the leases, and are accessed
+	// atomically
+	total   int64
+	revoked int64
+	failed  int64
+
+	l        sync.Mutex
+	status   string
+	endTime  time.Time
+	err      error
+	failures map[string]string
+}
+
+// newRevokeJob creates the job revoking the leases under the prefix
+func newRevokeJob(prefix string, force bool) *revokeJob {
+	// Ensure there is a trailing slash
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+	return &revokeJob{
+		prefix:    prefix,
+		force:     force,
+		startTime: time.Now(),
+		status:    revokeJobRunning,
+		failures:  make(map[string]string),
+	}
+}
+
+// recordFailure records a lease the job failed to revoke
+func (j *revokeJob) recordFailure(leaseID string, err error) {
+	atomic.AddInt64(&j.failed, 1)
+
+	j.l.Lock()
+	defer j.l.Unlock()
+	if j.err == nil {
+		j.err = fmt.Errorf("failed to revoke '%s': %v", leaseID, err)
+	}
+	if len(j.failures) < maxRevokeJobFailures {
+		j.failures[leaseID] = err.Error()
+	}
+}
+
+// stopped returns whether a failure stops the job
+func (j *revokeJob) stopped() bool {
+	return !j.force && atomic.LoadInt64(&j.failed) > 0
+}
+
+// finish records the end of the job, with the error stopping it if any
+func (j *revokeJob) finish(status string, err error) {
+	j.l.Lock()
+	defer j.l.Unlock()
+	j.status = status
+	j.endTime = time.Now()
+	if err != nil {
+		j.err = err
+	}
+}
+
+// error returns the first error of the job
+func (j *revokeJob) error() error {
+	j.l.Lock()
+	defer j.l.Unlock()
+	return j.err
+}
+
+// finishedBefore returns whether the job finished before the given time
+func (j *revokeJob) finishedBefore(t time.Time) bool {
+	j.l.Lock()
+	defer j.l.Unlock()
+	return j.status != revokeJobRunning && j.endTime.Before(t)
+}
+
+// data returns the status of the job
+func (j *revokeJob) data() map[string]interface{} {
+	j.l.Lock()
+	defer j.l.Unlock()
+
+	failures := make(map[string]interface{}, len(j.failures))
+	for leaseID, err := range j.failures {
+		failures[leaseID] = err
+	}
+	data := map[string]interface{}{
+		"id":         j.id,
+		"prefix":     j.prefix,
+		"force":      j.force,
+		"status":     j.status,
+		"start_time": j.startTime.UTC().Format(time.RFC3339),
+		"end_time":   "",
+		"total":      atomic.LoadInt64(&j.total),
+		"revoked":    atomic.LoadInt64(&j.revoked),
+		"failed":     atomic.LoadInt64(&j.failed),
+		"failures":   failures,
+		"error":      "",
+	}
+	if !j.endTime.IsZero() {
+		data["end_time"] = j.endTime.UTC().Format(time.RFC3339)
+	}
+	if j.err != nil {
+		data["error"] = j.err.Error()
+	}
+	return data
+}
+
+// startRevokeJob registers a revocation job with the manager, and returns
+// the channel which is closed when the job must stop. The caller must mark
+// the job as done on revokeJobsWG.
+func (m *ExpirationManager) startRevokeJob() chan struct{} {
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+	m.revokeJobsWG.Add(1)
+	return m.quitCh
+}
+
+// RevokePrefixAsync starts revoking the leases under the prefix in the
+// background, and returns the identifier of the job reporting its progress
+func (m *ExpirationManager) RevokePrefixAsync(prefix string, force bool) (string, error) {
+	job := newRevokeJob(prefix, force)
+	jobID, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	job.id = jobID
+
+	m.revokeJobsLock.Lock()
+	m.pruneRevokeJobsLocked()
+	m.revokeJobs[job.id] = job
+	m.revokeJobsLock.Unlock()
+
+	stopCh := m.startRevokeJob()
+	go func() {
+		defer m.revokeJobsWG.Done()
+		m.runRevokeJob(job, stopCh)
+		if err := job.error(); err != nil {
+			m.logger.Error("expiration: revocation job failed", "job_id", job.id, "prefix", job.prefix, "error", err)
+		}
+	}()
+	return job.id, nil
+}
+
+// RevokeJob returns the status of a revocation job, or nil if it is unknown
+func (m *ExpirationManager) RevokeJob(jobID string) map[string]interface{} {
+	m.revokeJobsLock.Lock()
+	defer m.revokeJobsLock.Unlock()
+	m.pruneRevokeJobsLocked()
+
+	job, ok := m.revokeJobs[jobID]
+	if !ok {
+		return nil
+	}
+	return job.data()
+}
+
+// RevokeJobs returns the identifiers of the revocation jobs, sorted
+func (m *ExpirationManager) RevokeJobs() []string {
+	m.revokeJobsLock.Lock()
+	defer m.revokeJobsLock.Unlock()
+	m.pruneRevokeJobsLocked()
+
+	jobIDs := make([]string, 0, len(m.revokeJobs))
+	for jobID := range m.revokeJobs {
+		jobIDs = append(jobIDs, jobID)
+	}
+	sort.Strings(jobIDs)
+	return jobIDs
+}
+
+// pruneRevokeJobsLocked removes the jobs finished for longer than the
+// retention. The caller must hold revokeJobsLock.
+func (m *ExpirationManager) pruneRevokeJobsLocked() {
+	cutoff := time.Now().Add(-revokeJobRetention)
+	for jobID, job := range m.revokeJobs {
+		if job.finishedBefore(cutoff) {
+			delete(m.revokeJobs, jobID)
+		}
+	}
+}
+
+// runRevokeJob revokes the leases of the job with a pool of workers until
+// they are all revoked, a failure stops the job, or stopCh is closed
+func (m *ExpirationManager) runRevokeJob(job *revokeJob, stopCh chan struct{}) {
+	// Accumulate existing leases
+	sub := m.idView.SubView(job.prefix)
+	existing, err := logical.CollectKeys(sub)
+	if err != nil {
+		job.finish(revokeJobFailed, fmt.Errorf("failed to scan for leases: %v", err))
+		return
+	}
+	atomic.StoreInt64(&job.total, int64(len(existing)))
+
+	workers := revokeWorkers
+	if len(existing) < workers {
+		workers = len(existing)
+	}
+	leaseCh := make(chan string)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for leaseID := range leaseCh {
+				if err := m.revokeCommon(leaseID, job.force, false); err != nil {
+					job.recordFailure(leaseID, err)
+					continue
+				}
+				atomic.AddInt64(&job.revoked, 1)
+			}
+		}()
+	}
+
+	canceled := false
+DISPATCH:
+	for _, suffix := range existing {
+		if job.stopped() {
+			break
+		}
+		select {
+		case leaseCh <- job.prefix + suffix:
+		case <-stopCh:
+			canceled = true
+			break DISPATCH
+		}
+	}
+	close(leaseCh)
+	wg.Wait()
+
+	switch {
+	case canceled:
+		job.finish(revokeJobCanceled, fmt.Errorf("revocation of leases under '%s' interrupted", job.prefix))
+	case atomic.LoadInt64(&job.failed) > 0:
+		job.finish(revokeJobFailed, nil)
+	default:
+		job.finish(revokeJobCompleted, nil)
+	}
+}
@@ -197,6 +197,127 @@ func TestExpiration_Restore(t *testing.T) {
 	}
 }
 
+func TestExpiration_RestoreProgress(t *testing.T) {
+	exp := mockExpiration(t)
+	for _, path := range []string{"prod/aws/foo", "prod/aws/bar"} {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		}
+		if _, err := exp.Register(req, resp); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := exp.Stop(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A lease renewed before it is restored keeps its timer
+	renewed := &leaseEntry{
+		LeaseID:    "prod/aws/renewed",
+		ExpireTime: time.Now().Add(time.Hour),
+	}
+	exp.updatePending(renewed, 2*time.Hour)
+	exp.restoreEntry(renewed)
+	exp.pendingLock.Lock()
+	timer := exp.pending[renewed.LeaseID]
+	exp.pendingLock.Unlock()
+
+	if err := exp.Restore(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	restoring, restored, total := exp.RestoreProgress()
+	if restoring || restored != 2 || total != 2 {
+		t.Fatalf("bad: %v %d %d", restoring, restored, total)
+	}
+	exp.pendingLock.Lock()
+	pending := len(exp.pending)
+	if exp.pending[renewed.LeaseID] != timer {
+		t.Fatalf("renewed lease timer replaced")
+	}
+	exp.pendingLock.Unlock()
+	if pending != 3 {
+		t.Fatalf("bad: %d", pending)
+	}
+
+	// Stopping the manager stops the timers
+	exp.setLeaseCountPrefixes([]string{""})
+	if exp.leaseCount("") != 3 {
+		t.Fatalf("bad: %d", exp.leaseCount(""))
+	}
+	if err := exp.Stop(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if exp.leaseCount("") != 0 {
+		t.Fatalf("timers not stopped")
+	}
+}
+
+func TestExpiration_leaseCount(t *testing.T) {
+	exp := mockExpiration(t)
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp.router.Mount(noop, "prod/aws/", &MountEntry{UUID: meUUID}, view)
+
+	register := func(path string) string {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		}
+		leaseID, err := exp.Register(req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return leaseID
+	}
+	check := func(prefix string, expected int) {
+		if count := exp.leaseCount(prefix); count != expected {
+			t.Fatalf("prefix %q: expected %d leases, got %d", prefix, expected, count)
+		}
+	}
+
+	// The leases existing before a prefix is counted are scanned
+	awsLease := register("prod/aws/foo")
+	exp.setLeaseCountPrefixes([]string{"prod/", "prod/aws/"})
+	check("prod/", 1)
+	check("prod/aws/", 1)
+
+	// The counts follow the registrations and revocations
+	register("prod/aws/bar")
+	register("prod/db/foo")
+	check("prod/", 3)
+	check("prod/aws/", 2)
+	if err := exp.Revoke(awsLease); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	check("prod/", 2)
+	check("prod/aws/", 1)
+
+	exp.setLeaseCountPrefixes([]string{"prod/", "prod/db/"})
+	check("prod/", 2)
+	check("prod/db/", 1)
+	check("prod/aws/", 0)
+}
+
 func TestExpiration_Register(t *testing.T) {
 	exp := mockExpiration(t)
 	req := &logical.Request{
@@ -1144,6 +1265,144 @@ func TestExpiration_RevokeForce(t *testing.T) {
 	}
 }
 
+// waitRevokeJob waits for a revocation job to finish and returns its status
+func waitRevokeJob(t *testing.T, exp *ExpirationManager, jobID string) map[string]interface{} {
+	for i := 0; i < 100; i++ {
+		status := exp.RevokeJob(jobID)
+		if status == nil {
+			t.Fatalf("missing job %q", jobID)
+		}
+		if status["status"] != revokeJobRunning {
+			return status
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("job %q did not finish", jobID)
+	return nil
+}
+
+func TestExpiration_RevokePrefixAsync(t *testing.T) {
+	exp := mockExpiration(t)
+	noop := &NoopBackend{}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp.router.Mount(noop, "prod/aws/", &MountEntry{UUID: meUUID}, view)
+
+	var expect []string
+	for i := 0; i < 50; i++ {
+		path := fmt.Sprintf("prod/aws/sub/%d", i)
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		}
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		}
+		if _, err := exp.Register(req, resp); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		expect = append(expect, strings.TrimPrefix(path, "prod/aws/"))
+	}
+
+	jobID, err := exp.RevokePrefixAsync("prod/aws", false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if jobs := exp.RevokeJobs(); !reflect.DeepEqual(jobs, []string{jobID}) {
+		t.Fatalf("bad: %v", jobs)
+	}
+
+	status := waitRevokeJob(t, exp, jobID)
+	if status["status"] != revokeJobCompleted {
+		t.Fatalf("bad: %#v", status)
+	}
+	if status["prefix"] != "prod/aws/" || status["total"] != int64(50) || status["revoked"] != int64(50) || status["failed"] != int64(0) {
+		t.Fatalf("bad: %#v", status)
+	}
+	if status["error"] != "" || status["end_time"] == "" {
+		t.Fatalf("bad: %#v", status)
+	}
+
+	noop.Lock()
+	defer noop.Unlock()
+	sort.Strings(noop.Paths)
+	sort.Strings(expect)
+	if !reflect.DeepEqual(noop.Paths, expect) {
+		t.Fatalf("bad: %v", noop.Paths)
+	}
+
+	if exp.RevokeJob("unknown") != nil {
+		t.Fatal("expected no status for an unknown job")
+	}
+}
+
+func TestExpiration_RevokePrefixAsync_Failure(t *testing.T) {
+	core, _, _, root := TestCoreWithTokenStore(t)
+
+	core.logicalBackends["badrenew"] = badRenewFactory
+	me := &MountEntry{
+		Table: mountTableType,
+		Path:  "badrenew/",
+		Type:  "badrenew",
+	}
+	if err := core.mount(me); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "badrenew/creds",
+			ClientToken: root,
+		}
+		resp, err := core.HandleRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || resp.Secret == nil {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+
+	// The revocation stops at the first failure
+	jobID, err := core.expiration.RevokePrefixAsync("badrenew/creds", false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	status := waitRevokeJob(t, core.expiration, jobID)
+	if status["status"] != revokeJobFailed || status["revoked"] != int64(0) {
+		t.Fatalf("bad: %#v", status)
+	}
+	if !strings.Contains(status["error"].(string), "always errors") {
+		t.Fatalf("bad: %#v", status)
+	}
+	failures := status["failures"].(map[string]interface{})
+	if len(failures) == 0 || int64(len(failures)) != status["failed"] {
+		t.Fatalf("bad: %#v", status)
+	}
+
+	// The forced revocation removes the leases despite the failures
+	jobID, err = core.expiration.RevokePrefixAsync("badrenew/creds", true)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	status = waitRevokeJob(t, core.expiration, jobID)
+	if status["status"] != revokeJobCompleted || status["total"] != int64(3) || status["revoked"] != int64(3) {
+		t.Fatalf("bad: %#v", status)
+	}
+	if len(core.expiration.RevokeJobs()) != 2 {
+		t.Fatalf("bad: %v", core.expiration.RevokeJobs())
+	}
+}
+
 func badRenewFactory(conf *logical.BackendConfig) (logical.Backend, error) {
 	be := &framework.Backend{
 		Paths: []*framework.Path{
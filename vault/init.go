@@ -38,8 +38,13 @@ func (c *Core) Initialized() (bool, error) {
 		return false, nil
 	}
 
-	// Verify the seal configuration
-	sealConf, err := c.seal.BarrierConfig()
+	// Verify the seal configuration, which is still that of the previous
+	// seal during a seal migration
+	seal := c.seal
+	if migrationSeal := c.sealMigration(); migrationSeal != nil {
+		seal = migrationSeal
+	}
+	sealConf, err := seal.BarrierConfig()
 	if err != nil {
 		return false, err
 	}
@@ -256,6 +261,11 @@ func (c *Core) UnsealWithStoredKeys() error {
 		return nil
 	}
 
+	if c.sealMigration() != nil {
+		c.logger.Warn("core: seal migration required, not unsealing with stored keys")
+		return nil
+	}
+
 	sealed, err := c.Sealed()
 	if err != nil {
 		c.logger.Error("core: error checking sealed status in auto-unseal", "error", err)
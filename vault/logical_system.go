@@ -5,12 +5,15 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/mitchellh/mapstructure"
@@ -62,12 +65,18 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 				"replication/primary/secondary-token",
 				"replication/reindex",
 				"rotate",
+				"rotate/config",
 				"config/auditing/*",
+				"config/cors",
+				"internal/counters/config",
+				"mfa/*",
+				"quotas/*",
 			},
 
 			Unauthenticated: []string{
 				"wrapping/pubkey",
 				"replication/status",
+				"internal/ui/mounts",
 			},
 		},
 
@@ -193,6 +202,34 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["tune_max_lease_ttl"][0]),
 					},
+					"lockout_threshold": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: strings.TrimSpace(sysHelp["tune_lockout_threshold"][0]),
+					},
+					"lockout_duration": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: strings.TrimSpace(sysHelp["tune_lockout_duration"][0]),
+					},
+					"lockout_counter_reset": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: strings.TrimSpace(sysHelp["tune_lockout_counter_reset"][0]),
+					},
+					"lockout_disable": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Description: strings.TrimSpace(sysHelp["tune_lockout_disable"][0]),
+					},
+					"token_type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["tune_token_type"][0]),
+					},
+					"audit_non_hmac_request_keys": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["tune_audit_non_hmac_request_keys"][0]),
+					},
+					"audit_non_hmac_response_keys": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["tune_audit_non_hmac_response_keys"][0]),
+					},
 				},
 				Callbacks: map[logical.Operation]framework.OperationFunc{
 					logical.ReadOperation:   b.handleAuthTuneRead,
@@ -218,6 +255,14 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["tune_max_lease_ttl"][0]),
 					},
+					"audit_non_hmac_request_keys": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["tune_audit_non_hmac_request_keys"][0]),
+					},
+					"audit_non_hmac_response_keys": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["tune_audit_non_hmac_response_keys"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -254,6 +299,11 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 						Default:     false,
 						Description: strings.TrimSpace(sysHelp["mount_local"][0]),
 					},
+					"seal_wrap": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Default:     false,
+						Description: strings.TrimSpace(sysHelp["mount_seal_wrap"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -350,6 +400,11 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["revoke-force-path"][0]),
 					},
+					"sync": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Default:     true,
+						Description: strings.TrimSpace(sysHelp["revoke-sync"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -368,6 +423,11 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["revoke-prefix-path"][0]),
 					},
+					"sync": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Default:     true,
+						Description: strings.TrimSpace(sysHelp["revoke-sync"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -378,6 +438,35 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 				HelpDescription: strings.TrimSpace(sysHelp["revoke-prefix"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "revoke-jobs/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleRevokeJobsList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["revoke-jobs"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["revoke-jobs"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "revoke-jobs/(?P<job_id>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"job_id": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["revoke-job-id"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleRevokeJobRead,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["revoke-jobs"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["revoke-jobs"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "auth$",
 
@@ -458,485 +547,1767 @@ func NewSystemBackend(core *Core, config *logical.BackendConfig) (logical.Backen
 			},
 
 			&framework.Path{
-				Pattern:         "seal-status$",
-				HelpSynopsis:    strings.TrimSpace(sysHelp["seal-status"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["seal-status"][1]),
-			},
+				Pattern: "policies/password/?$",
 
-			&framework.Path{
-				Pattern:         "seal$",
-				HelpSynopsis:    strings.TrimSpace(sysHelp["seal"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["seal"][1]),
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handlePasswordPolicyList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy-list"][1]),
 			},
 
 			&framework.Path{
-				Pattern:         "unseal$",
-				HelpSynopsis:    strings.TrimSpace(sysHelp["unseal"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["unseal"][1]),
+				Pattern: "policies/password/(?P<name>[^/]+)/generate$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-name"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handlePasswordPolicyGenerate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy-generate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy-generate"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "audit-hash/(?P<path>.+)",
+				Pattern: "policies/password/(?P<name>[^/]+)$",
 
 				Fields: map[string]*framework.FieldSchema{
-					"path": &framework.FieldSchema{
+					"name": &framework.FieldSchema{
 						Type:        framework.TypeString,
-						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+						Description: strings.TrimSpace(sysHelp["password-policy-name"][0]),
 					},
-
-					"input": &framework.FieldSchema{
-						Type: framework.TypeString,
+					"policy": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["password-policy-policy"][0]),
 					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleAuditHash,
+					logical.ReadOperation:   b.handlePasswordPolicyRead,
+					logical.UpdateOperation: b.handlePasswordPolicySet,
+					logical.DeleteOperation: b.handlePasswordPolicyDelete,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-hash"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["audit-hash"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["password-policy"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["password-policy"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "audit$",
+				Pattern: "locked-users$",
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.ReadOperation: b.handleAuditTable,
+					logical.ReadOperation: b.handleLockedUsersRead,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-table"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["audit-table"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["locked-users"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["locked-users"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "audit/(?P<path>.+)",
+				Pattern: "locked-users/(?P<mount>.+)/unlock/(?P<username>[^/]+)$",
 
 				Fields: map[string]*framework.FieldSchema{
-					"path": &framework.FieldSchema{
+					"mount": &framework.FieldSchema{
 						Type:        framework.TypeString,
-						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+						Description: strings.TrimSpace(sysHelp["locked-users-mount"][0]),
 					},
-					"type": &framework.FieldSchema{
+					"username": &framework.FieldSchema{
 						Type:        framework.TypeString,
-						Description: strings.TrimSpace(sysHelp["audit_type"][0]),
+						Description: strings.TrimSpace(sysHelp["locked-users-username"][0]),
 					},
-					"description": &framework.FieldSchema{
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleLockedUserUnlock,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["locked-users-unlock"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["locked-users-unlock"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "mfa/method/totp/?$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.handleMFATOTPMethodList,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-totp-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-totp-list"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/admin-generate$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
 						Type:        framework.TypeString,
-						Description: strings.TrimSpace(sysHelp["audit_desc"][0]),
-					},
-					"options": &framework.FieldSchema{
-						Type:        framework.TypeMap,
-						Description: strings.TrimSpace(sysHelp["audit_opts"][0]),
+						Description: strings.TrimSpace(sysHelp["mfa-method-name"][0]),
 					},
-					"local": &framework.FieldSchema{
-						Type:        framework.TypeBool,
-						Default:     false,
-						Description: strings.TrimSpace(sysHelp["mount_local"][0]),
+					"user": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-user"][0]),
 					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleEnableAudit,
-					logical.DeleteOperation: b.handleDisableAudit,
+					logical.UpdateOperation: b.handleMFATOTPAdminGenerate,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["audit"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["audit"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-totp-admin-generate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-totp-admin-generate"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "raw/(?P<path>.+)",
+				Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/admin-destroy$",
 
 				Fields: map[string]*framework.FieldSchema{
-					"path": &framework.FieldSchema{
-						Type: framework.TypeString,
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-method-name"][0]),
 					},
-					"value": &framework.FieldSchema{
-						Type: framework.TypeString,
+					"user": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-user"][0]),
 					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.ReadOperation:   b.handleRawRead,
-					logical.UpdateOperation: b.handleRawWrite,
-					logical.DeleteOperation: b.handleRawDelete,
+					logical.UpdateOperation: b.handleMFATOTPAdminDestroy,
 				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-totp-admin-destroy"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-totp-admin-destroy"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "key-status$",
+				Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-method-name"][0]),
+					},
+					"issuer": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-totp-issuer"][0]),
+					},
+					"period": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Default:     int(defaultTOTPPeriod / time.Second),
+						Description: strings.TrimSpace(sysHelp["mfa-totp-period"][0]),
+					},
+					"digits": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Default:     defaultTOTPDigits,
+						Description: strings.TrimSpace(sysHelp["mfa-totp-digits"][0]),
+					},
+					"algorithm": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     defaultTOTPAlgorithm,
+						Description: strings.TrimSpace(sysHelp["mfa-totp-algorithm"][0]),
+					},
+					"skew": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Default:     defaultTOTPSkew,
+						Description: strings.TrimSpace(sysHelp["mfa-totp-skew"][0]),
+					},
+					"key_size": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Default:     defaultTOTPKeySize,
+						Description: strings.TrimSpace(sysHelp["mfa-totp-key-size"][0]),
+					},
+				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.ReadOperation: b.handleKeyStatus,
+					logical.ReadOperation:   b.handleMFATOTPMethodRead,
+					logical.UpdateOperation: b.handleMFATOTPMethodWrite,
+					logical.DeleteOperation: b.handleMFATOTPMethodDelete,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["key-status"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["key-status"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-totp"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-totp"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "rotate$",
+				Pattern: "mfa/login-enforcement/?$",
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleRotate,
+					logical.ListOperation: b.handleMFALoginEnforcementList,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["rotate"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["rotate"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-login-enforcement-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-login-enforcement-list"][1]),
 			},
 
-			/*
-				// Disabled for the moment as we don't support this externally
-				&framework.Path{
-					Pattern: "wrapping/pubkey$",
+			&framework.Path{
+				Pattern: "mfa/login-enforcement/" + framework.GenericNameRegex("name") + "$",
 
-					Callbacks: map[logical.Operation]framework.OperationFunc{
-						logical.ReadOperation: b.handleWrappingPubkey,
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-login-enforcement-name"][0]),
+					},
+					"mfa_methods": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-login-enforcement-methods"][0]),
+					},
+					"auth_mounts": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-login-enforcement-auth-mounts"][0]),
+					},
+					"users": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["mfa-login-enforcement-users"][0]),
 					},
-
-					HelpSynopsis:    strings.TrimSpace(sysHelp["wrappubkey"][0]),
-					HelpDescription: strings.TrimSpace(sysHelp["wrappubkey"][1]),
 				},
-			*/
-
-			&framework.Path{
-				Pattern: "wrapping/wrap$",
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleWrappingWrap,
+					logical.ReadOperation:   b.handleMFALoginEnforcementRead,
+					logical.UpdateOperation: b.handleMFALoginEnforcementWrite,
+					logical.DeleteOperation: b.handleMFALoginEnforcementDelete,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["wrap"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["wrap"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-login-enforcement"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-login-enforcement"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "wrapping/unwrap$",
+				Pattern: "control-group/authorize$",
 
 				Fields: map[string]*framework.FieldSchema{
-					"token": &framework.FieldSchema{
-						Type: framework.TypeString,
+					"accessor": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["control-group-accessor"][0]),
 					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleWrappingUnwrap,
+					logical.UpdateOperation: b.handleControlGroupAuthorize,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["unwrap"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["unwrap"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["control-group-authorize"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["control-group-authorize"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "wrapping/lookup$",
+				Pattern: "control-group/request$",
 
 				Fields: map[string]*framework.FieldSchema{
-					"token": &framework.FieldSchema{
-						Type: framework.TypeString,
+					"accessor": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["control-group-accessor"][0]),
 					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleWrappingLookup,
+					logical.UpdateOperation: b.handleControlGroupRequest,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["wraplookup"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["wraplookup"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["control-group-request"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["control-group-request"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "wrapping/rewrap$",
-
-				Fields: map[string]*framework.FieldSchema{
-					"token": &framework.FieldSchema{
-						Type: framework.TypeString,
-					},
-				},
+				Pattern: "quotas/rate-limit/?$",
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleWrappingRewrap,
+					logical.ListOperation: b.handleRateLimitQuotaList,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["rewrap"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["rewrap"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-rate-limit-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["quotas-rate-limit-list"][1]),
 			},
 
 			&framework.Path{
-				Pattern: "config/auditing/request-headers/(?P<header>.+)",
+				Pattern: "quotas/rate-limit/" + framework.GenericNameRegex("name") + "$",
 
 				Fields: map[string]*framework.FieldSchema{
-					"header": &framework.FieldSchema{
-						Type: framework.TypeString,
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["quotas-name"][0]),
 					},
-					"hmac": &framework.FieldSchema{
-						Type: framework.TypeBool,
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["quotas-path"][0]),
+					},
+					"rate": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["quotas-rate-limit-rate"][0]),
+					},
+					"burst": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: strings.TrimSpace(sysHelp["quotas-rate-limit-burst"][0]),
+					},
+					"interval": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: strings.TrimSpace(sysHelp["quotas-rate-limit-interval"][0]),
+					},
+					"block_interval": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: strings.TrimSpace(sysHelp["quotas-rate-limit-block-interval"][0]),
 					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.handleAuditedHeaderUpdate,
-					logical.DeleteOperation: b.handleAuditedHeaderDelete,
-					logical.ReadOperation:   b.handleAuditedHeaderRead,
+					logical.ReadOperation:   b.handleRateLimitQuotaRead,
+					logical.UpdateOperation: b.handleRateLimitQuotaWrite,
+					logical.DeleteOperation: b.handleRateLimitQuotaDelete,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["audited-headers-name"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["audited-headers-name"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-rate-limit"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["quotas-rate-limit"][1]),
 			},
+
 			&framework.Path{
-				Pattern: "config/auditing/request-headers$",
+				Pattern: "quotas/lease-count/?$",
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.ReadOperation: b.handleAuditedHeadersRead,
+					logical.ListOperation: b.handleLeaseCountQuotaList,
 				},
 
-				HelpSynopsis:    strings.TrimSpace(sysHelp["audited-headers"][0]),
-				HelpDescription: strings.TrimSpace(sysHelp["audited-headers"][1]),
+				HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-lease-count-list"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["quotas-lease-count-list"][1]),
 			},
-		},
-	}
 
-	b.Backend.Paths = append(b.Backend.Paths, replicationPaths(b)...)
+			&framework.Path{
+				Pattern: "quotas/lease-count/" + framework.GenericNameRegex("name") + "$",
 
-	b.Backend.Invalidate = b.invalidate
+				Fields: map[string]*framework.FieldSchema{
+					"name": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["quotas-name"][0]),
+					},
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["quotas-path"][0]),
+					},
+					"max_leases": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: strings.TrimSpace(sysHelp["quotas-lease-count-max-leases"][0]),
+					},
+				},
 
-	return b.Backend.Setup(config)
-}
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleLeaseCountQuotaRead,
+					logical.UpdateOperation: b.handleLeaseCountQuotaWrite,
+					logical.DeleteOperation: b.handleLeaseCountQuotaDelete,
+				},
 
-// SystemBackend implements logical.Backend and is used to interact with
-// the core of the system. This backend is hardcoded to exist at the "sys"
-// prefix. Conceptually it is similar to procfs on Linux.
-type SystemBackend struct {
-	Core    *Core
-	Backend *framework.Backend
-}
+				HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-lease-count"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["quotas-lease-count"][1]),
+			},
 
-func (b *SystemBackend) invalidate(key string) {
+			&framework.Path{
+				Pattern:         "seal-status$",
+				HelpSynopsis:    strings.TrimSpace(sysHelp["seal-status"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["seal-status"][1]),
+			},
+
+			&framework.Path{
+				Pattern:         "seal$",
+				HelpSynopsis:    strings.TrimSpace(sysHelp["seal"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["seal"][1]),
+			},
+
+			&framework.Path{
+				Pattern:         "unseal$",
+				HelpSynopsis:    strings.TrimSpace(sysHelp["unseal"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["unseal"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "audit-hash/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+					},
+
+					"input": &framework.FieldSchema{
+						Type: framework.TypeString,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleAuditHash,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-hash"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-hash"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "audit$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleAuditTable,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-table"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-table"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "audit/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+					},
+					"type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_type"][0]),
+					},
+					"description": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_desc"][0]),
+					},
+					"options": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["audit_opts"][0]),
+					},
+					"local": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Default:     false,
+						Description: strings.TrimSpace(sysHelp["mount_local"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleEnableAudit,
+					logical.DeleteOperation: b.handleDisableAudit,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "raw/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type: framework.TypeString,
+					},
+					"value": &framework.FieldSchema{
+						Type: framework.TypeString,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleRawRead,
+					logical.UpdateOperation: b.handleRawWrite,
+					logical.DeleteOperation: b.handleRawDelete,
+				},
+			},
+
+			&framework.Path{
+				Pattern: "key-status$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleKeyStatus,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["key-status"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["key-status"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "rotate$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleRotate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["rotate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["rotate"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "rotate/config$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"enabled": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Description: strings.TrimSpace(sysHelp["rotation-enabled"][0]),
+					},
+					"max_operations": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: strings.TrimSpace(sysHelp["rotation-max-operations"][0]),
+					},
+					"interval": &framework.FieldSchema{
+						Type:        framework.TypeDurationSecond,
+						Description: strings.TrimSpace(sysHelp["rotation-interval"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleRotateConfigRead,
+					logical.UpdateOperation: b.handleRotateConfigWrite,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["rotate-config"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["rotate-config"][1]),
+			},
+
+			/*
+				// Disabled for the moment as we don't support this externally
+				&framework.Path{
+					Pattern: "wrapping/pubkey$",
+
+					Callbacks: map[logical.Operation]framework.OperationFunc{
+						logical.ReadOperation: b.handleWrappingPubkey,
+					},
+
+					HelpSynopsis:    strings.TrimSpace(sysHelp["wrappubkey"][0]),
+					HelpDescription: strings.TrimSpace(sysHelp["wrappubkey"][1]),
+				},
+			*/
+
+			&framework.Path{
+				Pattern: "wrapping/wrap$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleWrappingWrap,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["wrap"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["wrap"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "wrapping/unwrap$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"token": &framework.FieldSchema{
+						Type: framework.TypeString,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleWrappingUnwrap,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["unwrap"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["unwrap"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "wrapping/lookup$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"token": &framework.FieldSchema{
+						Type: framework.TypeString,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleWrappingLookup,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["wraplookup"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["wraplookup"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "wrapping/rewrap$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"token": &framework.FieldSchema{
+						Type: framework.TypeString,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleWrappingRewrap,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["rewrap"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["rewrap"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "config/auditing/request-headers/(?P<header>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"header": &framework.FieldSchema{
+						Type: framework.TypeString,
+					},
+					"hmac": &framework.FieldSchema{
+						Type: framework.TypeBool,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.handleAuditedHeaderUpdate,
+					logical.DeleteOperation: b.handleAuditedHeaderDelete,
+					logical.ReadOperation:   b.handleAuditedHeaderRead,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audited-headers-name"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audited-headers-name"][1]),
+			},
+			&framework.Path{
+				Pattern: "config/cors$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"allowed_origins": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["cors-allowed-origins"][0]),
+					},
+					"allowed_headers": &framework.FieldSchema{
+						Type:        framework.TypeCommaStringSlice,
+						Description: strings.TrimSpace(sysHelp["cors-allowed-headers"][0]),
+					},
+					"allow_credentials": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Description: strings.TrimSpace(sysHelp["cors-allow-credentials"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleCORSRead,
+					logical.UpdateOperation: b.handleCORSUpdate,
+					logical.DeleteOperation: b.handleCORSDelete,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["config/cors"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["config/cors"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "internal/counters/activity$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"start_time": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["activity-start-time"][0]),
+						Query:       true,
+					},
+					"end_time": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["activity-end-time"][0]),
+						Query:       true,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleActivityRead,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["internal/counters/activity"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["internal/counters/activity"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "internal/counters/config$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"enabled": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Description: strings.TrimSpace(sysHelp["activity-enabled"][0]),
+					},
+					"retention_days": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: strings.TrimSpace(sysHelp["activity-retention-days"][0]),
+					},
+					"default_report_days": &framework.FieldSchema{
+						Type:        framework.TypeInt,
+						Description: strings.TrimSpace(sysHelp["activity-default-report-days"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.handleActivityConfigRead,
+					logical.UpdateOperation: b.handleActivityConfigUpdate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["internal/counters/config"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["internal/counters/config"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "internal/ui/mounts$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleUIMounts,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["internal/ui/mounts"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["internal/ui/mounts"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "metrics$",
+
+				Fields: map[string]*framework.FieldSchema{
+					"format": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["metrics-format"][0]),
+						Query:       true,
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleMetrics,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["metrics"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["metrics"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "config/auditing/request-headers$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleAuditedHeadersRead,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audited-headers"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audited-headers"][1]),
+			},
+		},
+	}
+
+	b.Backend.Paths = append(b.Backend.Paths, replicationPaths(b)...)
+
+	b.Backend.Invalidate = b.invalidate
+
+	return b.Backend.Setup(config)
+}
+
+// SystemBackend implements logical.Backend and is used to interact with
+// the core of the system. This backend is hardcoded to exist at the "sys"
+// prefix. Conceptually it is similar to procfs on Linux.
+type SystemBackend struct {
+	Core    *Core
+	Backend *framework.Backend
+}
+
+func (b *SystemBackend) invalidate(key string) {
 	if b.Core.logger.IsTrace() {
 		b.Core.logger.Trace("sys: invaliding key", "key", key)
 	}
-	switch {
-	case strings.HasPrefix(key, policySubPath):
-		b.Core.stateLock.RLock()
-		defer b.Core.stateLock.RUnlock()
-		if b.Core.policyStore != nil {
-			b.Core.policyStore.invalidate(strings.TrimPrefix(key, policySubPath))
+	switch {
+	case strings.HasPrefix(key, policySubPath):
+		b.Core.stateLock.RLock()
+		defer b.Core.stateLock.RUnlock()
+		if b.Core.policyStore != nil {
+			b.Core.policyStore.invalidate(strings.TrimPrefix(key, policySubPath))
+		}
+	}
+}
+
+// handleAuditedHeaderUpdate creates or overwrites a header entry
+func (b *SystemBackend) handleAuditedHeaderUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	header := d.Get("header").(string)
+	hmac := d.Get("hmac").(bool)
+	if header == "" {
+		return logical.ErrorResponse("missing header name"), nil
+	}
+
+	headerConfig := b.Core.AuditedHeadersConfig()
+	err := headerConfig.add(header, hmac)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// handleCORSRead handles the "config/cors" endpoint to read the CORS
+// configuration
+func (b *SystemBackend) handleCORSRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := b.Core.CORSConfig()
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"enabled": config.Enabled,
+		},
+	}
+	if config.Enabled {
+		resp.Data["allowed_origins"] = config.AllowedOrigins
+		resp.Data["allowed_headers"] = config.AllowedHeadersList()
+		resp.Data["allow_credentials"] = config.AllowCredentials
+	}
+	return resp, nil
+}
+
+// handleCORSUpdate handles the "config/cors" endpoint to enable CORS for the
+// given origins
+func (b *SystemBackend) handleCORSUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &CORSConfig{
+		Enabled:          true,
+		AllowedOrigins:   d.Get("allowed_origins").([]string),
+		AllowedHeaders:   d.Get("allowed_headers").([]string),
+		AllowCredentials: d.Get("allow_credentials").(bool),
+	}
+	if err := config.Validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.setCORSConfig(config); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleCORSDelete handles the "config/cors" endpoint to disable CORS
+func (b *SystemBackend) handleCORSDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.setCORSConfig(nil); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleUIMounts handles the "internal/ui/mounts" endpoint to list the
+// mounts the token of the request has capabilities under. The path does not
+// require a token, so that the tokens without access to sys/mounts can use
+// it, and the token is checked here instead.
+func (b *SystemBackend) handleUIMounts(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.ClientToken == "" {
+		return nil, logical.ErrPermissionDenied
+	}
+	acl, _, err := b.Core.fetchACLandTokenEntry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	mountInfo := func(entry *MountEntry) map[string]interface{} {
+		return map[string]interface{}{
+			"type":        entry.Type,
+			"description": entry.Description,
+			"options":     entry.Options,
+			"local":       entry.Local,
+			"seal_wrap":   entry.SealWrap,
+		}
+	}
+
+	secretMounts := make(map[string]interface{})
+	b.Core.mountsLock.RLock()
+	for _, entry := range b.Core.mounts.Entries {
+		if acl.AllowedUnderPrefix(entry.Path) {
+			secretMounts[entry.Path] = mountInfo(entry)
+		}
+	}
+	b.Core.mountsLock.RUnlock()
+
+	authMounts := make(map[string]interface{})
+	b.Core.authLock.RLock()
+	for _, entry := range b.Core.auth.Entries {
+		if acl.AllowedUnderPrefix(credentialRoutePrefix + entry.Path) {
+			authMounts[entry.Path] = mountInfo(entry)
+		}
+	}
+	b.Core.authLock.RUnlock()
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"secret": secretMounts,
+			"auth":   authMounts,
+		},
+	}, nil
+}
+
+// handleMetrics handles the "metrics" endpoint to return the telemetry of
+// the server
+func (b *SystemBackend) handleMetrics(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.Core.MetricsResponse(d.Get("format").(string))
+}
+
+// handleActivityRead handles the "internal/counters/activity" endpoint to
+// report the distinct clients of the auth mounts between two times
+func (b *SystemBackend) handleActivityRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	a := b.Core.activityLog
+	config := a.getConfig()
+
+	end := time.Now().UTC()
+	if raw := d.Get("end_time").(string); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid end_time %q: %v", raw, err)), logical.ErrInvalidRequest
+		}
+		end = t.UTC()
+	}
+	start := end.AddDate(0, 0, -config.DefaultReportDays)
+	if raw := d.Get("start_time").(string); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid start_time %q: %v", raw, err)), logical.ErrInvalidRequest
+		}
+		start = t.UTC()
+	}
+	if end.Before(start) {
+		return logical.ErrorResponse("end_time cannot be before start_time"), logical.ErrInvalidRequest
+	}
+
+	total, byMount, err := a.counts(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make([]interface{}, 0, len(byMount))
+	for _, m := range byMount {
+		mounts = append(mounts, map[string]interface{}{
+			"mount_path": m.mountPath,
+			"counts":     m.counts.data(),
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"start_time": start.Format(time.RFC3339),
+			"end_time":   end.Format(time.RFC3339),
+			"total":      total.data(),
+			"by_mount":   mounts,
+		},
+	}, nil
+}
+
+// handleActivityConfigRead handles the "internal/counters/config" endpoint to
+// read the activity log configuration
+func (b *SystemBackend) handleActivityConfigRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := b.Core.activityLog.getConfig()
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":             config.Enabled,
+			"retention_days":      config.RetentionDays,
+			"default_report_days": config.DefaultReportDays,
+		},
+	}, nil
+}
+
+// handleActivityConfigUpdate handles the "internal/counters/config" endpoint
+// to update the activity log configuration
+func (b *SystemBackend) handleActivityConfigUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := b.Core.activityLog.getConfig()
+	if enabledRaw, ok := d.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if retentionRaw, ok := d.GetOk("retention_days"); ok {
+		config.RetentionDays = retentionRaw.(int)
+	}
+	if reportRaw, ok := d.GetOk("default_report_days"); ok {
+		config.DefaultReportDays = reportRaw.(int)
+	}
+
+	switch {
+	case config.RetentionDays <= 0:
+		return logical.ErrorResponse("retention_days must be positive"), logical.ErrInvalidRequest
+	case config.DefaultReportDays <= 0:
+		return logical.ErrorResponse("default_report_days must be positive"), logical.ErrInvalidRequest
+	case config.DefaultReportDays > config.RetentionDays:
+		return logical.ErrorResponse("default_report_days cannot exceed retention_days"), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.activityLog.setConfig(&config); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleAudtedHeaderDelete deletes the header with the given name
+func (b *SystemBackend) handleAuditedHeaderDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	header := d.Get("header").(string)
+	if header == "" {
+		return logical.ErrorResponse("missing header name"), nil
+	}
+
+	headerConfig := b.Core.AuditedHeadersConfig()
+	err := headerConfig.remove(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// handleAuditedHeaderRead returns the header configuration for the given header name
+func (b *SystemBackend) handleAuditedHeaderRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	header := d.Get("header").(string)
+	if header == "" {
+		return logical.ErrorResponse("missing header name"), nil
+	}
+
+	headerConfig := b.Core.AuditedHeadersConfig()
+	settings, ok := headerConfig.Headers[header]
+	if !ok {
+		return logical.ErrorResponse("Could not find header in config"), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			header: settings,
+		},
+	}, nil
+}
+
+// handleAuditedHeadersRead returns the whole audited headers config
+func (b *SystemBackend) handleAuditedHeadersRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	headerConfig := b.Core.AuditedHeadersConfig()
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"headers": headerConfig.Headers,
+		},
+	}, nil
+}
+
+// handleCapabilitiesreturns the ACL capabilities of the token for a given path
+func (b *SystemBackend) handleCapabilities(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	token := d.Get("token").(string)
+	if token == "" {
+		token = req.ClientToken
+	}
+	capabilities, err := b.Core.Capabilities(token, d.Get("path").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"capabilities": capabilities,
+		},
+	}, nil
+}
+
+// handleCapabilitiesAccessor returns the ACL capabilities of the token associted
+// with the given accessor for a given path.
+func (b *SystemBackend) handleCapabilitiesAccessor(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	accessor := d.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), nil
+	}
+
+	aEntry, err := b.Core.tokenStore.lookupByAccessor(accessor)
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities, err := b.Core.Capabilities(aEntry.TokenID, d.Get("path").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"capabilities": capabilities,
+		},
+	}, nil
+}
+
+// handleRekeyRetrieve returns backed-up, PGP-encrypted unseal keys from a
+// rekey operation
+func (b *SystemBackend) handleRekeyRetrieve(
+	req *logical.Request,
+	data *framework.FieldData,
+	recovery bool) (*logical.Response, error) {
+	backup, err := b.Core.RekeyRetrieveBackup(recovery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up backed-up keys: %v", err)
+	}
+	if backup == nil {
+		return logical.ErrorResponse("no backed-up keys found"), nil
+	}
+
+	keysB64 := map[string][]string{}
+	for k, v := range backup.Keys {
+		for _, j := range v {
+			currB64Keys := keysB64[k]
+			if currB64Keys == nil {
+				currB64Keys = []string{}
+			}
+			key, err := hex.DecodeString(j)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding hex-encoded backup key: %v", err)
+			}
+			currB64Keys = append(currB64Keys, base64.StdEncoding.EncodeToString(key))
+			keysB64[k] = currB64Keys
+		}
+	}
+
+	// Format the status
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"nonce":       backup.Nonce,
+			"keys":        backup.Keys,
+			"keys_base64": keysB64,
+		},
+	}
+
+	return resp, nil
+}
+
+func (b *SystemBackend) handleRekeyRetrieveBarrier(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleRekeyRetrieve(req, data, false)
+}
+
+func (b *SystemBackend) handleRekeyRetrieveRecovery(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleRekeyRetrieve(req, data, true)
+}
+
+// handleRekeyDelete deletes backed-up, PGP-encrypted unseal keys from a rekey
+// operation
+func (b *SystemBackend) handleRekeyDelete(
+	req *logical.Request,
+	data *framework.FieldData,
+	recovery bool) (*logical.Response, error) {
+	err := b.Core.RekeyDeleteBackup(recovery)
+	if err != nil {
+		return nil, fmt.Errorf("error during deletion of backed-up keys: %v", err)
+	}
+
+	return nil, nil
+}
+
+func (b *SystemBackend) handleRekeyDeleteBarrier(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleRekeyDelete(req, data, false)
+}
+
+func (b *SystemBackend) handleRekeyDeleteRecovery(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleRekeyDelete(req, data, true)
+}
+
+// handleMountTable handles the "mounts" endpoint to provide the mount table
+func (b *SystemBackend) handleMountTable(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.mountsLock.RLock()
+	defer b.Core.mountsLock.RUnlock()
+
+	resp := &logical.Response{
+		Data: make(map[string]interface{}),
+	}
+
+	for _, entry := range b.Core.mounts.Entries {
+		info := map[string]interface{}{
+			"type":        entry.Type,
+			"description": entry.Description,
+			"config": map[string]interface{}{
+				"default_lease_ttl": int64(entry.Config.DefaultLeaseTTL.Seconds()),
+				"max_lease_ttl":     int64(entry.Config.MaxLeaseTTL.Seconds()),
+				"force_no_cache":    entry.Config.ForceNoCache,
+			},
+			"local":     entry.Local,
+			"seal_wrap": entry.SealWrap,
 		}
+
+		resp.Data[entry.Path] = info
 	}
+
+	return resp, nil
 }
 
-// handleAuditedHeaderUpdate creates or overwrites a header entry
-func (b *SystemBackend) handleAuditedHeaderUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	header := d.Get("header").(string)
-	hmac := d.Get("hmac").(bool)
-	if header == "" {
-		return logical.ErrorResponse("missing header name"), nil
+// handleMount is used to mount a new path
+func (b *SystemBackend) handleMount(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.clusterParamsLock.RLock()
+	repState := b.Core.replicationState
+	b.Core.clusterParamsLock.RUnlock()
+
+	local := data.Get("local").(bool)
+	if !local && repState == consts.ReplicationSecondary {
+		return logical.ErrorResponse("cannot add a non-local mount to a replication secondary"), nil
+	}
+
+	// Get all the options
+	path := data.Get("path").(string)
+	logicalType := data.Get("type").(string)
+	description := data.Get("description").(string)
+	sealWrap := data.Get("seal_wrap").(bool)
+
+	path = sanitizeMountPath(path)
+
+	var config MountConfig
+
+	var apiConfig struct {
+		DefaultLeaseTTL string `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"`
+		MaxLeaseTTL     string `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`
+		ForceNoCache    bool   `json:"force_no_cache" structs:"force_no_cache" mapstructure:"force_no_cache"`
+
+		AuditNonHMACRequestKeys  []string `json:"audit_non_hmac_request_keys" structs:"audit_non_hmac_request_keys" mapstructure:"audit_non_hmac_request_keys"`
+		AuditNonHMACResponseKeys []string `json:"audit_non_hmac_response_keys" structs:"audit_non_hmac_response_keys" mapstructure:"audit_non_hmac_response_keys"`
+	}
+	configMap := data.Get("config").(map[string]interface{})
+	if configMap != nil && len(configMap) != 0 {
+		err := mapstructure.Decode(configMap, &apiConfig)
+		if err != nil {
+			return logical.ErrorResponse(
+					"unable to convert given mount config information"),
+				logical.ErrInvalidRequest
+		}
+	}
+
+	switch apiConfig.DefaultLeaseTTL {
+	case "":
+	case "system":
+	default:
+		tmpDef, err := parseutil.ParseDurationSecond(apiConfig.DefaultLeaseTTL)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+					"unable to parse default TTL of %s: %s", apiConfig.DefaultLeaseTTL, err)),
+				logical.ErrInvalidRequest
+		}
+		config.DefaultLeaseTTL = tmpDef
+	}
+
+	switch apiConfig.MaxLeaseTTL {
+	case "":
+	case "system":
+	default:
+		tmpMax, err := parseutil.ParseDurationSecond(apiConfig.MaxLeaseTTL)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+					"unable to parse max TTL of %s: %s", apiConfig.MaxLeaseTTL, err)),
+				logical.ErrInvalidRequest
+		}
+		config.MaxLeaseTTL = tmpMax
+	}
+
+	if config.MaxLeaseTTL != 0 && config.DefaultLeaseTTL > config.MaxLeaseTTL {
+		return logical.ErrorResponse(
+				"given default lease TTL greater than given max lease TTL"),
+			logical.ErrInvalidRequest
+	}
+
+	if config.DefaultLeaseTTL > b.Core.maxLeaseTTL {
+		return logical.ErrorResponse(fmt.Sprintf(
+				"given default lease TTL greater than system max lease TTL of %d", int(b.Core.maxLeaseTTL.Seconds()))),
+			logical.ErrInvalidRequest
+	}
+
+	// Copy over the force no cache if set
+	if apiConfig.ForceNoCache {
+		config.ForceNoCache = true
+	}
+
+	config.AuditNonHMACRequestKeys = apiConfig.AuditNonHMACRequestKeys
+	config.AuditNonHMACResponseKeys = apiConfig.AuditNonHMACResponseKeys
+
+	if logicalType == "" {
+		return logical.ErrorResponse(
+				"backend type must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+
+	// Create the mount entry
+	me := &MountEntry{
+		Table:       mountTableType,
+		Path:        path,
+		Type:        logicalType,
+		Description: description,
+		Config:      config,
+		Local:       local,
+		SealWrap:    sealWrap,
+	}
+
+	// Attempt mount
+	if err := b.Core.mount(me); err != nil {
+		b.Backend.Logger().Error("sys: mount failed", "path", me.Path, "error", err)
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
+// used to intercept an HTTPCodedError so it goes back to callee
+func handleError(
+	err error) (*logical.Response, error) {
+	switch err.(type) {
+	case logical.HTTPCodedError:
+		return logical.ErrorResponse(err.Error()), err
+	default:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+}
+
+// handleUnmount is used to unmount a path
+func (b *SystemBackend) handleUnmount(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.clusterParamsLock.RLock()
+	repState := b.Core.replicationState
+	b.Core.clusterParamsLock.RUnlock()
+
+	suffix := strings.TrimPrefix(req.Path, "mounts/")
+	if len(suffix) == 0 {
+		return logical.ErrorResponse("path cannot be blank"), logical.ErrInvalidRequest
+	}
+
+	suffix = sanitizeMountPath(suffix)
+
+	entry := b.Core.router.MatchingMountEntry(suffix)
+	if entry != nil && !entry.Local && repState == consts.ReplicationSecondary {
+		return logical.ErrorResponse("cannot unmount a non-local mount on a replication secondary"), nil
+	}
+
+	// Attempt unmount
+	if existed, err := b.Core.unmount(suffix); existed && err != nil {
+		b.Backend.Logger().Error("sys: unmount failed", "path", suffix, "error", err)
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
+// handleRemount is used to remount a path
+func (b *SystemBackend) handleRemount(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.clusterParamsLock.RLock()
+	repState := b.Core.replicationState
+	b.Core.clusterParamsLock.RUnlock()
+
+	// Get the paths
+	fromPath := data.Get("from").(string)
+	toPath := data.Get("to").(string)
+	if fromPath == "" || toPath == "" {
+		return logical.ErrorResponse(
+				"both 'from' and 'to' path must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+
+	fromPath = sanitizeMountPath(fromPath)
+	toPath = sanitizeMountPath(toPath)
+
+	entry := b.Core.router.MatchingMountEntry(fromPath)
+	if entry != nil && !entry.Local && repState == consts.ReplicationSecondary {
+		return logical.ErrorResponse("cannot remount a non-local mount on a replication secondary"), nil
 	}
 
-	headerConfig := b.Core.AuditedHeadersConfig()
-	err := headerConfig.add(header, hmac)
-	if err != nil {
-		return nil, err
+	// Attempt remount
+	if err := b.Core.remount(fromPath, toPath); err != nil {
+		b.Backend.Logger().Error("sys: remount failed", "from_path", fromPath, "to_path", toPath, "error", err)
+		return handleError(err)
 	}
 
 	return nil, nil
 }
 
-// handleAudtedHeaderDelete deletes the header with the given name
-func (b *SystemBackend) handleAuditedHeaderDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	header := d.Get("header").(string)
-	if header == "" {
-		return logical.ErrorResponse("missing header name"), nil
+// handleAuthTuneRead is used to get config settings on a auth path
+func (b *SystemBackend) handleAuthTuneRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path == "" {
+		return logical.ErrorResponse(
+				"path must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+	resp, err := b.handleTuneReadCommon("auth/" + path)
+	if err != nil || resp == nil || resp.IsError() {
+		return resp, err
 	}
 
-	headerConfig := b.Core.AuditedHeadersConfig()
-	err := headerConfig.remove(header)
-	if err != nil {
-		return nil, err
+	mountEntry := b.Core.router.MatchingMountEntry("auth/" + sanitizeMountPath(path))
+	if lockoutSupported(mountEntry) {
+		config := userLockoutConfigFor(mountEntry)
+		resp.Data["lockout_threshold"] = config.Threshold
+		resp.Data["lockout_duration"] = int(config.Duration.Seconds())
+		resp.Data["lockout_counter_reset"] = int(config.CounterReset.Seconds())
+		resp.Data["lockout_disable"] = config.Disable
+	}
+	if mountEntry != nil && mountEntry.Type != "token" {
+		resp.Data["token_type"] = TokenTypeService
+		if mountEntry.Config.TokenType != "" {
+			resp.Data["token_type"] = mountEntry.Config.TokenType
+		}
 	}
+	return resp, nil
+}
 
-	return nil, nil
+// handleMountTuneRead is used to get config settings on a backend
+func (b *SystemBackend) handleMountTuneRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path == "" {
+		return logical.ErrorResponse(
+				"path must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+
+	// This call will read both logical backend's configuration as well as auth backends'.
+	// Retaining this behavior for backward compatibility. If this behavior is not desired,
+	// an error can be returned if path has a prefix of "auth/".
+	return b.handleTuneReadCommon(path)
 }
 
-// handleAuditedHeaderRead returns the header configuration for the given header name
-func (b *SystemBackend) handleAuditedHeaderRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	header := d.Get("header").(string)
-	if header == "" {
-		return logical.ErrorResponse("missing header name"), nil
+// handleTuneReadCommon returns the config settings of a path
+func (b *SystemBackend) handleTuneReadCommon(path string) (*logical.Response, error) {
+	path = sanitizeMountPath(path)
+
+	sysView := b.Core.router.MatchingSystemView(path)
+	if sysView == nil {
+		b.Backend.Logger().Error("sys: cannot fetch sysview", "path", path)
+		return handleError(fmt.Errorf("sys: cannot fetch sysview for path %s", path))
 	}
 
-	headerConfig := b.Core.AuditedHeadersConfig()
-	settings, ok := headerConfig.Headers[header]
-	if !ok {
-		return logical.ErrorResponse("Could not find header in config"), nil
+	mountEntry := b.Core.router.MatchingMountEntry(path)
+	if mountEntry == nil {
+		b.Backend.Logger().Error("sys: cannot fetch mount entry", "path", path)
+		return handleError(fmt.Errorf("sys: cannot fetch mount entry for path %s", path))
 	}
 
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
-			header: settings,
+			"default_lease_ttl": int(sysView.DefaultLeaseTTL().Seconds()),
+			"max_lease_ttl":     int(sysView.MaxLeaseTTL().Seconds()),
+			"force_no_cache":    mountEntry.Config.ForceNoCache,
 		},
-	}, nil
-}
-
-// handleAuditedHeadersRead returns the whole audited headers config
-func (b *SystemBackend) handleAuditedHeadersRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	headerConfig := b.Core.AuditedHeadersConfig()
+	}
+	if len(mountEntry.Config.AuditNonHMACRequestKeys) > 0 {
+		resp.Data["audit_non_hmac_request_keys"] = mountEntry.Config.AuditNonHMACRequestKeys
+	}
+	if len(mountEntry.Config.AuditNonHMACResponseKeys) > 0 {
+		resp.Data["audit_non_hmac_response_keys"] = mountEntry.Config.AuditNonHMACResponseKeys
+	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"headers": headerConfig.Headers,
-		},
-	}, nil
+	return resp, nil
 }
 
-// handleCapabilitiesreturns the ACL capabilities of the token for a given path
-func (b *SystemBackend) handleCapabilities(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	token := d.Get("token").(string)
-	if token == "" {
-		token = req.ClientToken
-	}
-	capabilities, err := b.Core.Capabilities(token, d.Get("path").(string))
-	if err != nil {
-		return nil, err
+// handleAuthTuneWrite is used to set config settings on an auth path
+func (b *SystemBackend) handleAuthTuneWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path == "" {
+		return logical.ErrorResponse("path must be specified as a string"),
+			logical.ErrInvalidRequest
 	}
+	return b.handleTuneWriteCommon("auth/"+path, data)
+}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"capabilities": capabilities,
-		},
-	}, nil
+// handleMountTuneWrite is used to set config settings on a backend
+func (b *SystemBackend) handleMountTuneWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path == "" {
+		return logical.ErrorResponse("path must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
+	// This call will write both logical backend's configuration as well as auth backends'.
+	// Retaining this behavior for backward compatibility. If this behavior is not desired,
+	// an error can be returned if path has a prefix of "auth/".
+	return b.handleTuneWriteCommon(path, data)
 }
 
-// handleCapabilitiesAccessor returns the ACL capabilities of the token associted
-// with the given accessor for a given path.
-func (b *SystemBackend) handleCapabilitiesAccessor(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	accessor := d.Get("accessor").(string)
-	if accessor == "" {
-		return logical.ErrorResponse("missing accessor"), nil
+// handleTuneWriteCommon is used to set config settings on a path
+func (b *SystemBackend) handleTuneWriteCommon(
+	path string, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.clusterParamsLock.RLock()
+	repState := b.Core.replicationState
+	b.Core.clusterParamsLock.RUnlock()
+
+	path = sanitizeMountPath(path)
+
+	// Prevent protected paths from being changed
+	for _, p := range untunableMounts {
+		if strings.HasPrefix(path, p) {
+			b.Backend.Logger().Error("sys: cannot tune this mount", "path", path)
+			return handleError(fmt.Errorf("sys: cannot tune '%s'", path))
+		}
 	}
 
-	aEntry, err := b.Core.tokenStore.lookupByAccessor(accessor)
-	if err != nil {
-		return nil, err
+	mountEntry := b.Core.router.MatchingMountEntry(path)
+	if mountEntry == nil {
+		b.Backend.Logger().Error("sys: tune failed: no mount entry found", "path", path)
+		return handleError(fmt.Errorf("sys: tune of path '%s' failed: no mount entry found", path))
+	}
+	if mountEntry != nil && !mountEntry.Local && repState == consts.ReplicationSecondary {
+		return logical.ErrorResponse("cannot tune a non-local mount on a replication secondary"), nil
 	}
 
-	capabilities, err := b.Core.Capabilities(aEntry.TokenID, d.Get("path").(string))
-	if err != nil {
-		return nil, err
+	var lock *sync.RWMutex
+	switch {
+	case strings.HasPrefix(path, "auth/"):
+		lock = &b.Core.authLock
+	default:
+		lock = &b.Core.mountsLock
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"capabilities": capabilities,
-		},
-	}, nil
-}
+	// The lock is held until the end of the tuning once taken
+	var locked bool
 
-// handleRekeyRetrieve returns backed-up, PGP-encrypted unseal keys from a
-// rekey operation
-func (b *SystemBackend) handleRekeyRetrieve(
-	req *logical.Request,
-	data *framework.FieldData,
-	recovery bool) (*logical.Response, error) {
-	backup, err := b.Core.RekeyRetrieveBackup(recovery)
-	if err != nil {
-		return nil, fmt.Errorf("unable to look up backed-up keys: %v", err)
+	// Timing configuration parameters
+	{
+		var newDefault, newMax *time.Duration
+		defTTL := data.Get("default_lease_ttl").(string)
+		switch defTTL {
+		case "":
+		case "system":
+			tmpDef := time.Duration(0)
+			newDefault = &tmpDef
+		default:
+			tmpDef, err := parseutil.ParseDurationSecond(defTTL)
+			if err != nil {
+				return handleError(err)
+			}
+			newDefault = &tmpDef
+		}
+
+		maxTTL := data.Get("max_lease_ttl").(string)
+		switch maxTTL {
+		case "":
+		case "system":
+			tmpMax := time.Duration(0)
+			newMax = &tmpMax
+		default:
+			tmpMax, err := parseutil.ParseDurationSecond(maxTTL)
+			if err != nil {
+				return handleError(err)
+			}
+			newMax = &tmpMax
+		}
+
+		if newDefault != nil || newMax != nil {
+			lock.Lock()
+			defer lock.Unlock()
+			locked = true
+
+			if err := b.tuneMountTTLs(path, mountEntry, newDefault, newMax); err != nil {
+				b.Backend.Logger().Error("sys: tuning failed", "path", path, "error", err)
+				return handleError(err)
+			}
+		}
 	}
-	if backup == nil {
-		return logical.ErrorResponse("no backed-up keys found"), nil
+
+	// User lockout configuration parameters
+	{
+		newConfig := &userLockoutConfig{
+			Threshold:    mountEntry.Config.LockoutThreshold,
+			Duration:     mountEntry.Config.LockoutDuration,
+			CounterReset: mountEntry.Config.LockoutCounterReset,
+			Disable:      mountEntry.Config.LockoutDisable,
+		}
+		var changed bool
+		if raw, ok := data.GetOk("lockout_threshold"); ok {
+			newConfig.Threshold = raw.(int)
+			changed = true
+		}
+		if raw, ok := data.GetOk("lockout_duration"); ok {
+			newConfig.Duration = time.Duration(raw.(int)) * time.Second
+			changed = true
+		}
+		if raw, ok := data.GetOk("lockout_counter_reset"); ok {
+			newConfig.CounterReset = time.Duration(raw.(int)) * time.Second
+			changed = true
+		}
+		if raw, ok := data.GetOk("lockout_disable"); ok {
+			newConfig.Disable = raw.(bool)
+			changed = true
+		}
+
+		if changed {
+			if !lockoutSupported(mountEntry) {
+				return logical.ErrorResponse(fmt.Sprintf("user lockout is not supported by %q backends", mountEntry.Type)), logical.ErrInvalidRequest
+			}
+			if newConfig.Threshold < 0 || newConfig.Duration < 0 || newConfig.CounterReset < 0 {
+				return logical.ErrorResponse("user lockout parameters cannot be negative"), logical.ErrInvalidRequest
+			}
+
+			if !locked {
+				lock.Lock()
+				defer lock.Unlock()
+			}
+
+			if err := b.tuneMountUserLockout(path, mountEntry, newConfig); err != nil {
+				b.Backend.Logger().Error("sys: tuning failed", "path", path, "error", err)
+				return handleError(err)
+			}
+			locked = true
+		}
 	}
 
-	keysB64 := map[string][]string{}
-	for k, v := range backup.Keys {
-		for _, j := range v {
-			currB64Keys := keysB64[k]
-			if currB64Keys == nil {
-				currB64Keys = []string{}
+	// Token type of the logins
+	if raw, ok := data.GetOk("token_type"); ok {
+		tokenType := raw.(string)
+		if !validTokenType(tokenType) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid token type %q", tokenType)), logical.ErrInvalidRequest
+		}
+		if mountEntry.Table != credentialTableType || mountEntry.Type == "token" {
+			return logical.ErrorResponse("token type can only be tuned on the auth mounts issuing tokens at login"), logical.ErrInvalidRequest
+		}
+
+		if !locked {
+			lock.Lock()
+			defer lock.Unlock()
+		}
+
+		if err := b.tuneMountTokenType(path, mountEntry, tokenType); err != nil {
+			b.Backend.Logger().Error("sys: tuning failed", "path", path, "error", err)
+			return handleError(err)
+		}
+		locked = true
+	}
+
+	// Audit configuration parameters
+	{
+		requestKeys := mountEntry.Config.AuditNonHMACRequestKeys
+		responseKeys := mountEntry.Config.AuditNonHMACResponseKeys
+		var changed bool
+		if raw, ok := data.GetOk("audit_non_hmac_request_keys"); ok {
+			requestKeys = raw.([]string)
+			changed = true
+		}
+		if raw, ok := data.GetOk("audit_non_hmac_response_keys"); ok {
+			responseKeys = raw.([]string)
+			changed = true
+		}
+
+		if changed {
+			if !locked {
+				lock.Lock()
+				defer lock.Unlock()
 			}
-			key, err := hex.DecodeString(j)
-			if err != nil {
-				return nil, fmt.Errorf("error decoding hex-encoded backup key: %v", err)
+
+			if err := b.tuneMountAuditKeys(path, mountEntry, requestKeys, responseKeys); err != nil {
+				b.Backend.Logger().Error("sys: tuning failed", "path", path, "error", err)
+				return handleError(err)
 			}
-			currB64Keys = append(currB64Keys, base64.StdEncoding.EncodeToString(key))
-			keysB64[k] = currB64Keys
 		}
 	}
 
-	// Format the status
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"nonce":       backup.Nonce,
-			"keys":        backup.Keys,
-			"keys_base64": keysB64,
-		},
+	return nil, nil
+}
+
+// handleRenew is used to renew a lease with a given LeaseID
+func (b *SystemBackend) handleRenew(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Get all the options
+	leaseID := data.Get("lease_id").(string)
+	if leaseID == "" {
+		leaseID = data.Get("url_lease_id").(string)
 	}
+	incrementRaw := data.Get("increment").(int)
 
-	return resp, nil
+	// Convert the increment
+	increment := time.Duration(incrementRaw) * time.Second
+
+	// Invoke the expiration manager directly
+	resp, err := b.Core.expiration.Renew(leaseID, increment)
+	if err != nil {
+		b.Backend.Logger().Error("sys: lease renewal failed", "lease_id", leaseID, "error", err)
+		return handleError(err)
+	}
+	return resp, err
 }
 
-func (b *SystemBackend) handleRekeyRetrieveBarrier(
+// handleRevoke is used to revoke a given LeaseID
+func (b *SystemBackend) handleRevoke(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRekeyRetrieve(req, data, false)
+	// Get all the options
+	leaseID := data.Get("lease_id").(string)
+
+	// Invoke the expiration manager directly
+	if err := b.Core.expiration.Revoke(leaseID); err != nil {
+		b.Backend.Logger().Error("sys: lease revocation failed", "lease_id", leaseID, "error", err)
+		return handleError(err)
+	}
+	return nil, nil
 }
 
-func (b *SystemBackend) handleRekeyRetrieveRecovery(
+// handleRevokePrefix is used to revoke a prefix with many LeaseIDs
+func (b *SystemBackend) handleRevokePrefix(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRekeyRetrieve(req, data, true)
+	return b.handleRevokePrefixCommon(req, data, false)
 }
 
-// handleRekeyDelete deletes backed-up, PGP-encrypted unseal keys from a rekey
-// operation
-func (b *SystemBackend) handleRekeyDelete(
-	req *logical.Request,
-	data *framework.FieldData,
-	recovery bool) (*logical.Response, error) {
-	err := b.Core.RekeyDeleteBackup(recovery)
-	if err != nil {
-		return nil, fmt.Errorf("error during deletion of backed-up keys: %v", err)
+// handleRevokeForce is used to revoke a prefix with many LeaseIDs, ignoring errors
+func (b *SystemBackend) handleRevokeForce(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleRevokePrefixCommon(req, data, true)
+}
+
+// handleRevokePrefixCommon is used to revoke a prefix with many LeaseIDs
+func (b *SystemBackend) handleRevokePrefixCommon(
+	req *logical.Request, data *framework.FieldData, force bool) (*logical.Response, error) {
+	// Get all the options
+	prefix := data.Get("prefix").(string)
+
+	// Revoke in the background, the job reporting the progress
+	if !data.Get("sync").(bool) {
+		jobID, err := b.Core.expiration.RevokePrefixAsync(prefix, force)
+		if err != nil {
+			b.Backend.Logger().Error("sys: failed to start revoke prefix job", "prefix", prefix, "error", err)
+			return handleError(err)
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"job_id": jobID,
+			},
+		}, nil
 	}
 
+	// Invoke the expiration manager directly
+	var err error
+	if force {
+		err = b.Core.expiration.RevokeForce(prefix)
+	} else {
+		err = b.Core.expiration.RevokePrefix(prefix)
+	}
+	if err != nil {
+		b.Backend.Logger().Error("sys: revoke prefix failed", "prefix", prefix, "error", err)
+		return handleError(err)
+	}
 	return nil, nil
 }
 
-func (b *SystemBackend) handleRekeyDeleteBarrier(
+// handleRevokeJobsList handles the "revoke-jobs" endpoint to list the
+// revocation jobs
+func (b *SystemBackend) handleRevokeJobsList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRekeyDelete(req, data, false)
+	return logical.ListResponse(b.Core.expiration.RevokeJobs()), nil
 }
 
-func (b *SystemBackend) handleRekeyDeleteRecovery(
+// handleRevokeJobRead handles the "revoke-jobs/<id>" endpoint to report the
+// progress of a revocation job
+func (b *SystemBackend) handleRevokeJobRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRekeyDelete(req, data, true)
+	status := b.Core.expiration.RevokeJob(data.Get("job_id").(string))
+	if status == nil {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: status,
+	}, nil
 }
 
-// handleMountTable handles the "mounts" endpoint to provide the mount table
-func (b *SystemBackend) handleMountTable(
+// handleAuthTable handles the "auth" endpoint to provide the auth table
+func (b *SystemBackend) handleAuthTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.mountsLock.RLock()
-	defer b.Core.mountsLock.RUnlock()
+	b.Core.authLock.RLock()
+	defer b.Core.authLock.RUnlock()
 
 	resp := &logical.Response{
 		Data: make(map[string]interface{}),
 	}
-
-	for _, entry := range b.Core.mounts.Entries {
+	for _, entry := range b.Core.auth.Entries {
 		info := map[string]interface{}{
 			"type":        entry.Type,
 			"description": entry.Description,
 			"config": map[string]interface{}{
 				"default_lease_ttl": int64(entry.Config.DefaultLeaseTTL.Seconds()),
 				"max_lease_ttl":     int64(entry.Config.MaxLeaseTTL.Seconds()),
-				"force_no_cache":    entry.Config.ForceNoCache,
 			},
 			"local": entry.Local,
 		}
-
 		resp.Data[entry.Path] = info
 	}
-
 	return resp, nil
 }
 
-// handleMount is used to mount a new path
-func (b *SystemBackend) handleMount(
+// handleEnableAuth is used to enable a new credential backend
+func (b *SystemBackend) handleEnableAuth(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	b.Core.clusterParamsLock.RLock()
 	repState := b.Core.replicationState
@@ -952,542 +2323,816 @@ func (b *SystemBackend) handleMount(
 	logicalType := data.Get("type").(string)
 	description := data.Get("description").(string)
 
-	path = sanitizeMountPath(path)
+	if logicalType == "" {
+		return logical.ErrorResponse(
+				"backend type must be specified as a string"),
+			logical.ErrInvalidRequest
+	}
 
-	var config MountConfig
+	path = sanitizeMountPath(path)
 
-	var apiConfig struct {
-		DefaultLeaseTTL string `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"`
-		MaxLeaseTTL     string `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`
-		ForceNoCache    bool   `json:"force_no_cache" structs:"force_no_cache" mapstructure:"force_no_cache"`
-	}
-	configMap := data.Get("config").(map[string]interface{})
-	if configMap != nil && len(configMap) != 0 {
-		err := mapstructure.Decode(configMap, &apiConfig)
-		if err != nil {
-			return logical.ErrorResponse(
-					"unable to convert given mount config information"),
-				logical.ErrInvalidRequest
-		}
+	// Create the mount entry
+	me := &MountEntry{
+		Table:       credentialTableType,
+		Path:        path,
+		Type:        logicalType,
+		Description: description,
+		Local:       local,
 	}
 
-	switch apiConfig.DefaultLeaseTTL {
-	case "":
-	case "system":
-	default:
-		tmpDef, err := parseutil.ParseDurationSecond(apiConfig.DefaultLeaseTTL)
-		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf(
-					"unable to parse default TTL of %s: %s", apiConfig.DefaultLeaseTTL, err)),
-				logical.ErrInvalidRequest
-		}
-		config.DefaultLeaseTTL = tmpDef
+	// Attempt enabling
+	if err := b.Core.enableCredential(me); err != nil {
+		b.Backend.Logger().Error("sys: enable auth mount failed", "path", me.Path, "error", err)
+		return handleError(err)
 	}
+	return nil, nil
+}
 
-	switch apiConfig.MaxLeaseTTL {
-	case "":
-	case "system":
-	default:
-		tmpMax, err := parseutil.ParseDurationSecond(apiConfig.MaxLeaseTTL)
-		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf(
-					"unable to parse max TTL of %s: %s", apiConfig.MaxLeaseTTL, err)),
-				logical.ErrInvalidRequest
-		}
-		config.MaxLeaseTTL = tmpMax
+// handleDisableAuth is used to disable a credential backend
+func (b *SystemBackend) handleDisableAuth(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	suffix := strings.TrimPrefix(req.Path, "auth/")
+	if len(suffix) == 0 {
+		return logical.ErrorResponse("path cannot be blank"), logical.ErrInvalidRequest
 	}
 
-	if config.MaxLeaseTTL != 0 && config.DefaultLeaseTTL > config.MaxLeaseTTL {
-		return logical.ErrorResponse(
-				"given default lease TTL greater than given max lease TTL"),
-			logical.ErrInvalidRequest
-	}
+	suffix = sanitizeMountPath(suffix)
 
-	if config.DefaultLeaseTTL > b.Core.maxLeaseTTL {
-		return logical.ErrorResponse(fmt.Sprintf(
-				"given default lease TTL greater than system max lease TTL of %d", int(b.Core.maxLeaseTTL.Seconds()))),
-			logical.ErrInvalidRequest
+	// Attempt disable
+	if existed, err := b.Core.disableCredential(suffix); existed && err != nil {
+		b.Backend.Logger().Error("sys: disable auth mount failed", "path", suffix, "error", err)
+		return handleError(err)
 	}
+	return nil, nil
+}
 
-	// Copy over the force no cache if set
-	if apiConfig.ForceNoCache {
-		config.ForceNoCache = true
-	}
+// handlePolicyList handles the "policy" endpoint to provide the enabled policies
+func (b *SystemBackend) handlePolicyList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Get all the configured policies
+	policies, err := b.Core.policyStore.ListPolicies()
 
-	if logicalType == "" {
-		return logical.ErrorResponse(
-				"backend type must be specified as a string"),
-			logical.ErrInvalidRequest
+	// Add the special "root" policy
+	policies = append(policies, "root")
+	resp := logical.ListResponse(policies)
+
+	// Backwords compatibility
+	resp.Data["policies"] = resp.Data["keys"]
+
+	return resp, err
+}
+
+// handlePolicyRead handles the "policy/<name>" endpoint to read a policy
+func (b *SystemBackend) handlePolicyRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	policy, err := b.Core.policyStore.GetPolicy(name)
+	if err != nil {
+		return handleError(err)
 	}
 
-	// Create the mount entry
-	me := &MountEntry{
-		Table:       mountTableType,
-		Path:        path,
-		Type:        logicalType,
-		Description: description,
-		Config:      config,
-		Local:       local,
+	if policy == nil {
+		return nil, nil
 	}
 
-	// Attempt mount
-	if err := b.Core.mount(me); err != nil {
-		b.Backend.Logger().Error("sys: mount failed", "path", me.Path, "error", err)
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":  name,
+			"rules": policy.Raw,
+		},
+	}, nil
+}
+
+// handlePolicySet handles the "policy/<name>" endpoint to set a policy
+func (b *SystemBackend) handlePolicySet(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	rules := data.Get("rules").(string)
+
+	// Validate the rules parse
+	parse, err := Parse(rules)
+	if err != nil {
 		return handleError(err)
 	}
 
+	// Override the name
+	parse.Name = strings.ToLower(name)
+
+	// Update the policy
+	if err := b.Core.policyStore.SetPolicy(parse); err != nil {
+		return handleError(err)
+	}
 	return nil, nil
 }
 
-// used to intercept an HTTPCodedError so it goes back to callee
-func handleError(
-	err error) (*logical.Response, error) {
-	switch err.(type) {
-	case logical.HTTPCodedError:
-		return logical.ErrorResponse(err.Error()), err
-	default:
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+// handlePolicyDelete handles the "policy/<name>" endpoint to delete a policy
+func (b *SystemBackend) handlePolicyDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	if err := b.Core.policyStore.DeletePolicy(name); err != nil {
+		return handleError(err)
 	}
+	return nil, nil
 }
 
-// handleUnmount is used to unmount a path
-func (b *SystemBackend) handleUnmount(
+// handlePasswordPolicyList handles the "policies/password" endpoint to list
+// the password policies
+func (b *SystemBackend) handlePasswordPolicyList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.clusterParamsLock.RLock()
-	repState := b.Core.replicationState
-	b.Core.clusterParamsLock.RUnlock()
-
-	suffix := strings.TrimPrefix(req.Path, "mounts/")
-	if len(suffix) == 0 {
-		return logical.ErrorResponse("path cannot be blank"), logical.ErrInvalidRequest
+	names, err := b.Core.passwordPolicyView().List("")
+	if err != nil {
+		return nil, err
 	}
 
-	suffix = sanitizeMountPath(suffix)
+	return logical.ListResponse(names), nil
+}
 
-	entry := b.Core.router.MatchingMountEntry(suffix)
-	if entry != nil && !entry.Local && repState == consts.ReplicationSecondary {
-		return logical.ErrorResponse("cannot unmount a non-local mount on a replication secondary"), nil
+// handlePasswordPolicyRead handles the "policies/password/<name>" endpoint to
+// read a password policy
+func (b *SystemBackend) handlePasswordPolicyRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	entry, err := b.Core.passwordPolicyView().Get(strings.ToLower(name))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
 	}
 
-	// Attempt unmount
-	if existed, err := b.Core.unmount(suffix); existed && err != nil {
-		b.Backend.Logger().Error("sys: unmount failed", "path", suffix, "error", err)
-		return handleError(err)
+	var policyEntry passwordPolicyEntry
+	if err := entry.DecodeJSON(&policyEntry); err != nil {
+		return nil, err
 	}
 
-	return nil, nil
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"policy": policyEntry.Policy,
+		},
+	}, nil
 }
 
-// handleRemount is used to remount a path
-func (b *SystemBackend) handleRemount(
+// handlePasswordPolicySet handles the "policies/password/<name>" endpoint to
+// set a password policy
+func (b *SystemBackend) handlePasswordPolicySet(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.clusterParamsLock.RLock()
-	repState := b.Core.replicationState
-	b.Core.clusterParamsLock.RUnlock()
-
-	// Get the paths
-	fromPath := data.Get("from").(string)
-	toPath := data.Get("to").(string)
-	if fromPath == "" || toPath == "" {
-		return logical.ErrorResponse(
-				"both 'from' and 'to' path must be specified as a string"),
-			logical.ErrInvalidRequest
+	name := data.Get("name").(string)
+	policy := data.Get("policy").(string)
+	if policy == "" {
+		return logical.ErrorResponse("missing policy"), logical.ErrInvalidRequest
 	}
 
-	fromPath = sanitizeMountPath(fromPath)
-	toPath = sanitizeMountPath(toPath)
-
-	entry := b.Core.router.MatchingMountEntry(fromPath)
-	if entry != nil && !entry.Local && repState == consts.ReplicationSecondary {
-		return logical.ErrorResponse("cannot remount a non-local mount on a replication secondary"), nil
+	// Accept base64-encoded policies, since they are often written from files
+	if decoded, err := base64.StdEncoding.DecodeString(policy); err == nil {
+		policy = string(decoded)
 	}
 
-	// Attempt remount
-	if err := b.Core.remount(fromPath, toPath); err != nil {
-		b.Backend.Logger().Error("sys: remount failed", "from_path", fromPath, "to_path", toPath, "error", err)
+	if err := b.Core.setPasswordPolicy(name, policy); err != nil {
 		return handleError(err)
 	}
-
 	return nil, nil
 }
 
-// handleAuthTuneRead is used to get config settings on a auth path
-func (b *SystemBackend) handleAuthTuneRead(
+// handlePasswordPolicyDelete handles the "policies/password/<name>" endpoint
+// to delete a password policy
+func (b *SystemBackend) handlePasswordPolicyDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	path := data.Get("path").(string)
-	if path == "" {
-		return logical.ErrorResponse(
-				"path must be specified as a string"),
-			logical.ErrInvalidRequest
-	}
-	return b.handleTuneReadCommon("auth/" + path)
-}
+	name := data.Get("name").(string)
 
-// handleMountTuneRead is used to get config settings on a backend
-func (b *SystemBackend) handleMountTuneRead(
-	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	path := data.Get("path").(string)
-	if path == "" {
-		return logical.ErrorResponse(
-				"path must be specified as a string"),
-			logical.ErrInvalidRequest
+	if err := b.Core.passwordPolicyView().Delete(strings.ToLower(name)); err != nil {
+		return handleError(err)
 	}
-
-	// This call will read both logical backend's configuration as well as auth backends'.
-	// Retaining this behavior for backward compatibility. If this behavior is not desired,
-	// an error can be returned if path has a prefix of "auth/".
-	return b.handleTuneReadCommon(path)
+	return nil, nil
 }
 
-// handleTuneReadCommon returns the config settings of a path
-func (b *SystemBackend) handleTuneReadCommon(path string) (*logical.Response, error) {
-	path = sanitizeMountPath(path)
+// handlePasswordPolicyGenerate handles the
+// "policies/password/<name>/generate" endpoint to generate a password from a
+// password policy
+func (b *SystemBackend) handlePasswordPolicyGenerate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
 
-	sysView := b.Core.router.MatchingSystemView(path)
-	if sysView == nil {
-		b.Backend.Logger().Error("sys: cannot fetch sysview", "path", path)
-		return handleError(fmt.Errorf("sys: cannot fetch sysview for path %s", path))
+	policy, err := b.Core.passwordPolicy(name)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return logical.ErrorResponse(fmt.Sprintf("password policy %q not found", name)), logical.ErrInvalidRequest
 	}
 
-	mountEntry := b.Core.router.MatchingMountEntry(path)
-	if mountEntry == nil {
-		b.Backend.Logger().Error("sys: cannot fetch mount entry", "path", path)
-		return handleError(fmt.Errorf("sys: cannot fetch mount entry for path %s", path))
+	password, err := policy.Generate(nil)
+	if err != nil {
+		return nil, err
 	}
 
-	resp := &logical.Response{
+	return &logical.Response{
 		Data: map[string]interface{}{
-			"default_lease_ttl": int(sysView.DefaultLeaseTTL().Seconds()),
-			"max_lease_ttl":     int(sysView.MaxLeaseTTL().Seconds()),
-			"force_no_cache":    mountEntry.Config.ForceNoCache,
+			"password": password,
 		},
+	}, nil
+}
+
+// handleLockedUsersRead handles the "locked-users" endpoint to list the users
+// locked out of the auth mounts
+func (b *SystemBackend) handleLockedUsersRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Core.authLock.RLock()
+	defer b.Core.authLock.RUnlock()
+
+	resp := &logical.Response{
+		Data: make(map[string]interface{}),
 	}
+	for _, entry := range b.Core.auth.Entries {
+		if !lockoutSupported(entry) {
+			continue
+		}
+		config := userLockoutConfigFor(entry)
 
+		users := make(map[string]interface{})
+		for name, failed := range b.Core.userLockouts.lockedUsers(entry) {
+			users[name] = map[string]interface{}{
+				"failed_login_attempts": failed.Count,
+				"locked_until":          failed.LastFailure.Add(config.Duration).Format(time.RFC3339),
+			}
+		}
+		if len(users) != 0 {
+			resp.Data[entry.Path] = users
+		}
+	}
 	return resp, nil
 }
 
-// handleAuthTuneWrite is used to set config settings on an auth path
-func (b *SystemBackend) handleAuthTuneWrite(
+// handleLockedUserUnlock handles the "locked-users/<mount>/unlock/<username>"
+// endpoint to lift the lockout of a user of an auth mount
+func (b *SystemBackend) handleLockedUserUnlock(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	path := data.Get("path").(string)
-	if path == "" {
-		return logical.ErrorResponse("path must be specified as a string"),
-			logical.ErrInvalidRequest
+	mount := sanitizeMountPath(strings.TrimPrefix(data.Get("mount").(string), "auth/"))
+	username := strings.ToLower(data.Get("username").(string))
+
+	b.Core.authLock.RLock()
+	defer b.Core.authLock.RUnlock()
+
+	var entry *MountEntry
+	for _, e := range b.Core.auth.Entries {
+		if e.Path == mount {
+			entry = e
+			break
+		}
 	}
-	return b.handleTuneWriteCommon("auth/"+path, data)
+	if entry == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no auth mount at %q", mount)), logical.ErrInvalidRequest
+	}
+	if !lockoutSupported(entry) {
+		return logical.ErrorResponse(fmt.Sprintf("user lockout is not supported by %q backends", entry.Type)), logical.ErrInvalidRequest
+	}
+
+	if b.Core.userLockouts.unlock(entry, username) && b.Core.logger.IsInfo() {
+		b.Core.logger.Info("core: unlocked user", "path", mount, "username", username)
+	}
+	return nil, nil
 }
 
-// handleMountTuneWrite is used to set config settings on a backend
-func (b *SystemBackend) handleMountTuneWrite(
+// handleMFATOTPMethodList handles the "mfa/method/totp" endpoint to list the
+// TOTP MFA methods
+func (b *SystemBackend) handleMFATOTPMethodList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	path := data.Get("path").(string)
-	if path == "" {
-		return logical.ErrorResponse("path must be specified as a string"),
-			logical.ErrInvalidRequest
+	names, err := b.Core.loginMFAView().List("method/totp/")
+	if err != nil {
+		return nil, err
 	}
-	// This call will write both logical backend's configuration as well as auth backends'.
-	// Retaining this behavior for backward compatibility. If this behavior is not desired,
-	// an error can be returned if path has a prefix of "auth/".
-	return b.handleTuneWriteCommon(path, data)
+
+	return logical.ListResponse(names), nil
 }
 
-// handleTuneWriteCommon is used to set config settings on a path
-func (b *SystemBackend) handleTuneWriteCommon(
-	path string, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.clusterParamsLock.RLock()
-	repState := b.Core.replicationState
-	b.Core.clusterParamsLock.RUnlock()
+// handleMFATOTPMethodRead handles the "mfa/method/totp/<name>" endpoint to
+// read a TOTP MFA method
+func (b *SystemBackend) handleMFATOTPMethodRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	method, err := b.Core.totpMethod(data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if method == nil {
+		return nil, nil
+	}
 
-	path = sanitizeMountPath(path)
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer":    method.Issuer,
+			"period":    int64(method.Period / time.Second),
+			"digits":    method.Digits,
+			"algorithm": method.Algorithm,
+			"skew":      method.Skew,
+			"key_size":  method.KeySize,
+		},
+	}, nil
+}
 
-	// Prevent protected paths from being changed
-	for _, p := range untunableMounts {
-		if strings.HasPrefix(path, p) {
-			b.Backend.Logger().Error("sys: cannot tune this mount", "path", path)
-			return handleError(fmt.Errorf("sys: cannot tune '%s'", path))
+// handleMFATOTPMethodWrite handles the "mfa/method/totp/<name>" endpoint to
+// create or update a TOTP MFA method
+func (b *SystemBackend) handleMFATOTPMethodWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := strings.ToLower(data.Get("name").(string))
+
+	method, err := b.Core.totpMethod(name)
+	if err != nil {
+		return nil, err
+	}
+	if method == nil {
+		method = &totpMethodEntry{
+			Period:    time.Duration(data.Get("period").(int)) * time.Second,
+			Digits:    data.Get("digits").(int),
+			Algorithm: data.Get("algorithm").(string),
+			Skew:      data.Get("skew").(int),
+			KeySize:   data.Get("key_size").(int),
 		}
 	}
 
-	mountEntry := b.Core.router.MatchingMountEntry(path)
-	if mountEntry == nil {
-		b.Backend.Logger().Error("sys: tune failed: no mount entry found", "path", path)
-		return handleError(fmt.Errorf("sys: tune of path '%s' failed: no mount entry found", path))
+	if issuerRaw, ok := data.GetOk("issuer"); ok {
+		method.Issuer = issuerRaw.(string)
 	}
-	if mountEntry != nil && !mountEntry.Local && repState == consts.ReplicationSecondary {
-		return logical.ErrorResponse("cannot tune a non-local mount on a replication secondary"), nil
+	if periodRaw, ok := data.GetOk("period"); ok {
+		method.Period = time.Duration(periodRaw.(int)) * time.Second
+	}
+	if digitsRaw, ok := data.GetOk("digits"); ok {
+		method.Digits = digitsRaw.(int)
+	}
+	if algorithmRaw, ok := data.GetOk("algorithm"); ok {
+		method.Algorithm = strings.ToUpper(algorithmRaw.(string))
+	}
+	if skewRaw, ok := data.GetOk("skew"); ok {
+		method.Skew = skewRaw.(int)
+	}
+	if keySizeRaw, ok := data.GetOk("key_size"); ok {
+		method.KeySize = keySizeRaw.(int)
 	}
 
-	var lock *sync.RWMutex
 	switch {
-	case strings.HasPrefix(path, "auth/"):
-		lock = &b.Core.authLock
-	default:
-		lock = &b.Core.mountsLock
+	case method.Issuer == "":
+		return logical.ErrorResponse("missing issuer"), logical.ErrInvalidRequest
+	case method.Period < time.Second:
+		return logical.ErrorResponse("period must be at least one second"), logical.ErrInvalidRequest
+	case method.Digits != 6 && method.Digits != 8:
+		return logical.ErrorResponse("digits must be 6 or 8"), logical.ErrInvalidRequest
+	case method.Algorithm != "SHA1" && method.Algorithm != "SHA256" && method.Algorithm != "SHA512":
+		return logical.ErrorResponse("algorithm must be SHA1, SHA256 or SHA512"), logical.ErrInvalidRequest
+	case method.Skew != 0 && method.Skew != 1:
+		return logical.ErrorResponse("skew must be 0 or 1"), logical.ErrInvalidRequest
+	case method.KeySize < 16:
+		return logical.ErrorResponse("key_size must be at least 16"), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON("method/totp/"+name, method)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Core.loginMFAView().Put(entry); err != nil {
+		return handleError(err)
 	}
+	return nil, nil
+}
 
-	// Timing configuration parameters
-	{
-		var newDefault, newMax *time.Duration
-		defTTL := data.Get("default_lease_ttl").(string)
-		switch defTTL {
-		case "":
-		case "system":
-			tmpDef := time.Duration(0)
-			newDefault = &tmpDef
-		default:
-			tmpDef, err := parseutil.ParseDurationSecond(defTTL)
-			if err != nil {
-				return handleError(err)
-			}
-			newDefault = &tmpDef
-		}
+// handleMFATOTPMethodDelete handles the "mfa/method/totp/<name>" endpoint to
+// delete a TOTP MFA method and the enrollments of its users
+func (b *SystemBackend) handleMFATOTPMethodDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := strings.ToLower(data.Get("name").(string))
+	view := b.Core.loginMFAView()
 
-		maxTTL := data.Get("max_lease_ttl").(string)
-		switch maxTTL {
-		case "":
-		case "system":
-			tmpMax := time.Duration(0)
-			newMax = &tmpMax
-		default:
-			tmpMax, err := parseutil.ParseDurationSecond(maxTTL)
-			if err != nil {
-				return handleError(err)
-			}
-			newMax = &tmpMax
+	// The methods required by enforcements cannot be deleted, or the logins
+	// they apply to would fail
+	enforcements, err := view.List("enforcement/")
+	if err != nil {
+		return nil, err
+	}
+	for _, enforcementName := range enforcements {
+		enforcement, err := b.Core.loginEnforcement(enforcementName)
+		if err != nil {
+			return nil, err
 		}
+		if enforcement != nil && strutil.StrListContains(enforcement.MFAMethods, name) {
+			return logical.ErrorResponse(fmt.Sprintf("MFA method %q is used by login enforcement %q", name, enforcementName)), logical.ErrInvalidRequest
+		}
+	}
 
-		if newDefault != nil || newMax != nil {
-			lock.Lock()
-			defer lock.Unlock()
-
-			if err := b.tuneMountTTLs(path, mountEntry, newDefault, newMax); err != nil {
-				b.Backend.Logger().Error("sys: tuning failed", "path", path, "error", err)
-				return handleError(err)
-			}
+	enrollments, err := view.List("enrollment/totp/" + name + "/")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range enrollments {
+		if err := view.Delete("enrollment/totp/" + name + "/" + key); err != nil {
+			return handleError(err)
 		}
 	}
 
+	if err := view.Delete("method/totp/" + name); err != nil {
+		return handleError(err)
+	}
 	return nil, nil
 }
 
-// handleRenew is used to renew a lease with a given LeaseID
-func (b *SystemBackend) handleRenew(
+// handleMFATOTPAdminGenerate handles the
+// "mfa/method/totp/<name>/admin-generate" endpoint to enroll a user in a
+// TOTP MFA method
+func (b *SystemBackend) handleMFATOTPAdminGenerate(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	// Get all the options
-	leaseID := data.Get("lease_id").(string)
-	if leaseID == "" {
-		leaseID = data.Get("url_lease_id").(string)
+	name := strings.ToLower(data.Get("name").(string))
+	user := strings.ToLower(data.Get("user").(string))
+	if user == "" {
+		return logical.ErrorResponse("missing user"), logical.ErrInvalidRequest
 	}
-	incrementRaw := data.Get("increment").(int)
 
-	// Convert the increment
-	increment := time.Duration(incrementRaw) * time.Second
+	method, err := b.Core.totpMethod(name)
+	if err != nil {
+		return nil, err
+	}
+	if method == nil {
+		return logical.ErrorResponse(fmt.Sprintf("MFA method %q not found", name)), logical.ErrInvalidRequest
+	}
 
-	// Invoke the expiration manager directly
-	resp, err := b.Core.expiration.Renew(leaseID, increment)
+	otpURL, err := b.Core.enrollTOTP(name, method, user)
 	if err != nil {
-		b.Backend.Logger().Error("sys: lease renewal failed", "lease_id", leaseID, "error", err)
-		return handleError(err)
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
-	return resp, err
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"url": otpURL,
+		},
+	}, nil
 }
 
-// handleRevoke is used to revoke a given LeaseID
-func (b *SystemBackend) handleRevoke(
+// handleMFATOTPAdminDestroy handles the
+// "mfa/method/totp/<name>/admin-destroy" endpoint to remove the enrollment
+// of a user in a TOTP MFA method
+func (b *SystemBackend) handleMFATOTPAdminDestroy(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	// Get all the options
-	leaseID := data.Get("lease_id").(string)
+	name := strings.ToLower(data.Get("name").(string))
+	user := strings.ToLower(data.Get("user").(string))
+	if user == "" {
+		return logical.ErrorResponse("missing user"), logical.ErrInvalidRequest
+	}
 
-	// Invoke the expiration manager directly
-	if err := b.Core.expiration.Revoke(leaseID); err != nil {
-		b.Backend.Logger().Error("sys: lease revocation failed", "lease_id", leaseID, "error", err)
+	if err := b.Core.loginMFAView().Delete(totpEnrollmentKey(name, user)); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
 }
 
-// handleRevokePrefix is used to revoke a prefix with many LeaseIDs
-func (b *SystemBackend) handleRevokePrefix(
+// handleMFALoginEnforcementList handles the "mfa/login-enforcement" endpoint
+// to list the login MFA enforcements
+func (b *SystemBackend) handleMFALoginEnforcementList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRevokePrefixCommon(req, data, false)
+	names, err := b.Core.loginMFAView().List("enforcement/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(names), nil
 }
 
-// handleRevokeForce is used to revoke a prefix with many LeaseIDs, ignoring errors
-func (b *SystemBackend) handleRevokeForce(
+// handleMFALoginEnforcementRead handles the "mfa/login-enforcement/<name>"
+// endpoint to read a login MFA enforcement
+func (b *SystemBackend) handleMFALoginEnforcementRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.handleRevokePrefixCommon(req, data, true)
+	enforcement, err := b.Core.loginEnforcement(data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if enforcement == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"mfa_methods": enforcement.MFAMethods,
+			"auth_mounts": enforcement.AuthMounts,
+			"users":       enforcement.Users,
+		},
+	}, nil
 }
 
-// handleRevokePrefixCommon is used to revoke a prefix with many LeaseIDs
-func (b *SystemBackend) handleRevokePrefixCommon(
-	req *logical.Request, data *framework.FieldData, force bool) (*logical.Response, error) {
-	// Get all the options
-	prefix := data.Get("prefix").(string)
+// handleMFALoginEnforcementWrite handles the "mfa/login-enforcement/<name>"
+// endpoint to create or update a login MFA enforcement
+func (b *SystemBackend) handleMFALoginEnforcementWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := strings.ToLower(data.Get("name").(string))
 
-	// Invoke the expiration manager directly
-	var err error
-	if force {
-		err = b.Core.expiration.RevokeForce(prefix)
-	} else {
-		err = b.Core.expiration.RevokePrefix(prefix)
+	enforcement, err := b.Core.loginEnforcement(name)
+	if err != nil {
+		return nil, err
+	}
+	if enforcement == nil {
+		enforcement = &loginEnforcementEntry{}
+	}
+
+	if methodsRaw, ok := data.GetOk("mfa_methods"); ok {
+		enforcement.MFAMethods = strutil.ParseDedupAndSortStrings(strings.ToLower(methodsRaw.(string)), ",")
+	}
+	if mountsRaw, ok := data.GetOk("auth_mounts"); ok {
+		enforcement.AuthMounts = nil
+		for _, mount := range strutil.ParseDedupAndSortStrings(mountsRaw.(string), ",") {
+			mount = sanitizeMountPath(strings.TrimPrefix(mount, credentialRoutePrefix))
+			if !strutil.StrListContains(enforcement.AuthMounts, mount) {
+				enforcement.AuthMounts = append(enforcement.AuthMounts, mount)
+			}
+		}
+	}
+	if usersRaw, ok := data.GetOk("users"); ok {
+		enforcement.Users = strutil.ParseDedupAndSortStrings(strings.ToLower(usersRaw.(string)), ",")
+	}
+
+	if len(enforcement.MFAMethods) == 0 {
+		return logical.ErrorResponse("missing mfa_methods"), logical.ErrInvalidRequest
+	}
+	if len(enforcement.AuthMounts) == 0 && len(enforcement.Users) == 0 {
+		return logical.ErrorResponse("at least one of auth_mounts or users must be set"), logical.ErrInvalidRequest
+	}
+	for _, methodName := range enforcement.MFAMethods {
+		method, err := b.Core.totpMethod(methodName)
+		if err != nil {
+			return nil, err
+		}
+		if method == nil {
+			return logical.ErrorResponse(fmt.Sprintf("MFA method %q not found", methodName)), logical.ErrInvalidRequest
+		}
 	}
+
+	entry, err := logical.StorageEntryJSON("enforcement/"+name, enforcement)
 	if err != nil {
-		b.Backend.Logger().Error("sys: revoke prefix failed", "prefix", prefix, "error", err)
+		return nil, err
+	}
+	if err := b.Core.loginMFAView().Put(entry); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
 }
 
-// handleAuthTable handles the "auth" endpoint to provide the auth table
-func (b *SystemBackend) handleAuthTable(
+// handleMFALoginEnforcementDelete handles the "mfa/login-enforcement/<name>"
+// endpoint to delete a login MFA enforcement
+func (b *SystemBackend) handleMFALoginEnforcementDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.authLock.RLock()
-	defer b.Core.authLock.RUnlock()
+	name := strings.ToLower(data.Get("name").(string))
 
-	resp := &logical.Response{
-		Data: make(map[string]interface{}),
-	}
-	for _, entry := range b.Core.auth.Entries {
-		info := map[string]interface{}{
-			"type":        entry.Type,
-			"description": entry.Description,
-			"config": map[string]interface{}{
-				"default_lease_ttl": int64(entry.Config.DefaultLeaseTTL.Seconds()),
-				"max_lease_ttl":     int64(entry.Config.MaxLeaseTTL.Seconds()),
-			},
-			"local": entry.Local,
-		}
-		resp.Data[entry.Path] = info
+	if err := b.Core.loginMFAView().Delete("enforcement/" + name); err != nil {
+		return handleError(err)
 	}
-	return resp, nil
+	return nil, nil
 }
 
-// handleEnableAuth is used to enable a new credential backend
-func (b *SystemBackend) handleEnableAuth(
+// handleControlGroupAuthorize handles the "control-group/authorize" endpoint
+// to authorize a request held by a control group
+func (b *SystemBackend) handleControlGroupAuthorize(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.clusterParamsLock.RLock()
-	repState := b.Core.replicationState
-	b.Core.clusterParamsLock.RUnlock()
+	accessor := data.Get("accessor").(string)
 
-	local := data.Get("local").(bool)
-	if !local && repState == consts.ReplicationSecondary {
-		return logical.ErrorResponse("cannot add a non-local mount to a replication secondary"), nil
+	entry, err := b.Core.controlGroupRequest(accessor)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return logical.ErrorResponse("control group request not found"), logical.ErrInvalidRequest
 	}
 
-	// Get all the options
-	path := data.Get("path").(string)
-	logicalType := data.Get("type").(string)
-	description := data.Get("description").(string)
+	te, err := b.Core.tokenStore.Lookup(req.ClientToken)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil {
+		return nil, logical.ErrPermissionDenied
+	}
 
-	if logicalType == "" {
-		return logical.ErrorResponse(
-				"backend type must be specified as a string"),
-			logical.ErrInvalidRequest
+	approver := false
+	for _, policy := range te.Policies {
+		if strutil.StrListContains(entry.ApproverPolicies, policy) {
+			approver = true
+			break
+		}
+	}
+	if !approver {
+		return logical.ErrorResponse("token is not an approver of the request"), logical.ErrPermissionDenied
 	}
 
-	path = sanitizeMountPath(path)
+	// Approvers are counted once per login identity
+	approverID, approverName, err := b.Core.loginIdentity(te)
+	if err != nil {
+		return nil, err
+	}
+	if approverID == "" {
+		return logical.ErrorResponse("approvers must use a token issued by a login or created from one"), logical.ErrPermissionDenied
+	}
+	if approverID == entry.RequesterID {
+		return logical.ErrorResponse("requesters cannot authorize their own requests"), logical.ErrPermissionDenied
+	}
 
-	// Create the mount entry
-	me := &MountEntry{
-		Table:       credentialTableType,
-		Path:        path,
-		Type:        logicalType,
-		Description: description,
-		Local:       local,
+	if !entry.authorizedBy(approverID) {
+		entry.Authorizations = append(entry.Authorizations, &controlGroupAuthorization{
+			Approver:   approverName,
+			ApproverID: approverID,
+			Time:       time.Now(),
+		})
+		if err := b.Core.setControlGroupRequest(accessor, entry); err != nil {
+			return handleError(err)
+		}
 	}
 
-	// Attempt enabling
-	if err := b.Core.enableCredential(me); err != nil {
-		b.Backend.Logger().Error("sys: enable auth mount failed", "path", me.Path, "error", err)
-		return handleError(err)
+	return &logical.Response{
+		Data: entry.status(),
+	}, nil
+}
+
+// handleControlGroupRequest handles the "control-group/request" endpoint to
+// read the state of a request held by a control group
+func (b *SystemBackend) handleControlGroupRequest(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entry, err := b.Core.controlGroupRequest(data.Get("accessor").(string))
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil
+	if entry == nil {
+		return logical.ErrorResponse("control group request not found"), logical.ErrInvalidRequest
+	}
+
+	return &logical.Response{
+		Data: entry.status(),
+	}, nil
 }
 
-// handleDisableAuth is used to disable a credential backend
-func (b *SystemBackend) handleDisableAuth(
+// handleRateLimitQuotaList handles the "quotas/rate-limit" endpoint to list
+// the rate limit quotas
+func (b *SystemBackend) handleRateLimitQuotaList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	suffix := strings.TrimPrefix(req.Path, "auth/")
-	if len(suffix) == 0 {
-		return logical.ErrorResponse("path cannot be blank"), logical.ErrInvalidRequest
+	names, err := b.Core.quotas.view.List(quotaTypeRateLimit + "/")
+	if err != nil {
+		return nil, err
 	}
 
-	suffix = sanitizeMountPath(suffix)
+	return logical.ListResponse(names), nil
+}
 
-	// Attempt disable
-	if existed, err := b.Core.disableCredential(suffix); existed && err != nil {
-		b.Backend.Logger().Error("sys: disable auth mount failed", "path", suffix, "error", err)
-		return handleError(err)
+// handleRateLimitQuotaRead handles the "quotas/rate-limit/<name>" endpoint to
+// read a rate limit quota
+func (b *SystemBackend) handleRateLimitQuotaRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	quota := b.Core.quotas.rateLimit(strings.ToLower(data.Get("name").(string)))
+	if quota == nil {
+		return nil, nil
 	}
-	return nil, nil
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"path":           quota.Path,
+			"rate":           quota.Rate,
+			"burst":          quota.Burst,
+			"interval":       int64(quota.Interval.Seconds()),
+			"block_interval": int64(quota.BlockInterval.Seconds()),
+		},
+	}, nil
 }
 
-// handlePolicyList handles the "policy" endpoint to provide the enabled policies
-func (b *SystemBackend) handlePolicyList(
+// handleRateLimitQuotaWrite handles the "quotas/rate-limit/<name>" endpoint to
+// create or update a rate limit quota
+func (b *SystemBackend) handleRateLimitQuotaWrite(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	// Get all the configured policies
-	policies, err := b.Core.policyStore.ListPolicies()
+	name := strings.ToLower(data.Get("name").(string))
 
-	// Add the special "root" policy
-	policies = append(policies, "root")
-	resp := logical.ListResponse(policies)
+	quota := &rateLimitQuotaEntry{
+		Interval: defaultRateLimitInterval,
+	}
+	if existing := b.Core.quotas.rateLimit(name); existing != nil {
+		*quota = *existing
+	}
 
-	// Backwords compatibility
-	resp.Data["policies"] = resp.Data["keys"]
+	if pathRaw, ok := data.GetOk("path"); ok {
+		path, err := b.Core.quotaPath(pathRaw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		quota.Path = path
+	}
+	if rateRaw, ok := data.GetOk("rate"); ok {
+		rate, err := strconv.ParseFloat(rateRaw.(string), 64)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid rate %q", rateRaw)), logical.ErrInvalidRequest
+		}
+		quota.Rate = rate
+	}
+	if burstRaw, ok := data.GetOk("burst"); ok {
+		quota.Burst = burstRaw.(int)
+	}
+	if intervalRaw, ok := data.GetOk("interval"); ok {
+		quota.Interval = time.Duration(intervalRaw.(int)) * time.Second
+	}
+	if blockRaw, ok := data.GetOk("block_interval"); ok {
+		quota.BlockInterval = time.Duration(blockRaw.(int)) * time.Second
+	}
 
-	return resp, err
+	switch {
+	case quota.Rate <= 0:
+		return logical.ErrorResponse("rate must be positive"), logical.ErrInvalidRequest
+	case quota.Burst < 0:
+		return logical.ErrorResponse("burst cannot be negative"), logical.ErrInvalidRequest
+	case quota.Interval <= 0:
+		return logical.ErrorResponse("interval must be positive"), logical.ErrInvalidRequest
+	case quota.BlockInterval < 0:
+		return logical.ErrorResponse("block_interval cannot be negative"), logical.ErrInvalidRequest
+	}
+
+	// Clients can burst to the rate by default
+	if quota.Burst == 0 {
+		quota.Burst = int(math.Ceil(quota.Rate))
+	}
+
+	if err := b.Core.quotas.setRateLimit(name, quota); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
 }
 
-// handlePolicyRead handles the "policy/<name>" endpoint to read a policy
-func (b *SystemBackend) handlePolicyRead(
+// handleRateLimitQuotaDelete handles the "quotas/rate-limit/<name>" endpoint
+// to delete a rate limit quota
+func (b *SystemBackend) handleRateLimitQuotaDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	name := data.Get("name").(string)
+	name := strings.ToLower(data.Get("name").(string))
 
-	policy, err := b.Core.policyStore.GetPolicy(name)
-	if err != nil {
+	if err := b.Core.quotas.delete(quotaTypeRateLimit, name); err != nil {
 		return handleError(err)
 	}
+	return nil, nil
+}
 
-	if policy == nil {
+// handleLeaseCountQuotaList handles the "quotas/lease-count" endpoint to list
+// the lease count quotas
+func (b *SystemBackend) handleLeaseCountQuotaList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	names, err := b.Core.quotas.view.List(quotaTypeLeaseCount + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(names), nil
+}
+
+// handleLeaseCountQuotaRead handles the "quotas/lease-count/<name>" endpoint
+// to read a lease count quota along with the current number of leases
+func (b *SystemBackend) handleLeaseCountQuotaRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	quota := b.Core.quotas.leaseCount(strings.ToLower(data.Get("name").(string)))
+	if quota == nil {
 		return nil, nil
 	}
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"name":  name,
-			"rules": policy.Raw,
+			"path":       quota.Path,
+			"max_leases": quota.MaxLeases,
+			"counter":    b.Core.expiration.leaseCount(quota.Path),
 		},
 	}, nil
 }
 
-// handlePolicySet handles the "policy/<name>" endpoint to set a policy
-func (b *SystemBackend) handlePolicySet(
+// handleLeaseCountQuotaWrite handles the "quotas/lease-count/<name>" endpoint
+// to create or update a lease count quota
+func (b *SystemBackend) handleLeaseCountQuotaWrite(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	name := data.Get("name").(string)
-	rules := data.Get("rules").(string)
+	name := strings.ToLower(data.Get("name").(string))
 
-	// Validate the rules parse
-	parse, err := Parse(rules)
-	if err != nil {
-		return handleError(err)
+	quota := &leaseCountQuotaEntry{}
+	if existing := b.Core.quotas.leaseCount(name); existing != nil {
+		*quota = *existing
 	}
 
-	// Override the name
-	parse.Name = strings.ToLower(name)
+	if pathRaw, ok := data.GetOk("path"); ok {
+		path, err := b.Core.quotaPath(pathRaw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		quota.Path = path
+	}
+	if maxRaw, ok := data.GetOk("max_leases"); ok {
+		quota.MaxLeases = maxRaw.(int)
+	}
 
-	// Update the policy
-	if err := b.Core.policyStore.SetPolicy(parse); err != nil {
+	if quota.MaxLeases <= 0 {
+		return logical.ErrorResponse("max_leases must be positive"), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.quotas.setLeaseCount(name, quota); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
 }
 
-// handlePolicyDelete handles the "policy/<name>" endpoint to delete a policy
-func (b *SystemBackend) handlePolicyDelete(
+// handleLeaseCountQuotaDelete handles the "quotas/lease-count/<name>"
+// endpoint to delete a lease count quota
+func (b *SystemBackend) handleLeaseCountQuotaDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	name := data.Get("name").(string)
+	name := strings.ToLower(data.Get("name").(string))
 
-	if err := b.Core.policyStore.DeletePolicy(name); err != nil {
+	if err := b.Core.quotas.delete(quotaTypeLeaseCount, name); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
@@ -1684,6 +3329,16 @@ func (b *SystemBackend) handleKeyStatus(
 			"install_time": info.InstallTime.Format(time.RFC3339Nano),
 		},
 	}
+
+	// Add the progress towards the next automatic rotation
+	_, encryptions, next, err := b.Core.keyRotationStatusInfo()
+	if err != nil {
+		return nil, err
+	}
+	resp.Data["encryptions"] = encryptions
+	if !next.IsZero() {
+		resp.Data["next_rotation_time"] = next.Format(time.RFC3339Nano)
+	}
 	return resp, nil
 }
 
@@ -1697,37 +3352,58 @@ func (b *SystemBackend) handleRotate(
 		return logical.ErrorResponse("cannot rotate on a replication secondary"), nil
 	}
 
-	// Rotate to the new term
-	newTerm, err := b.Core.barrier.Rotate()
+	if _, err := b.Core.rotateBarrierKey(); err != nil {
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
+// handleRotateConfigRead handles the "rotate/config" endpoint to read the
+// automatic rotation policy of the barrier key
+func (b *SystemBackend) handleRotateConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.Core.keyRotationConfig()
 	if err != nil {
-		b.Backend.Logger().Error("sys: failed to create new encryption key", "error", err)
 		return handleError(err)
 	}
-	b.Backend.Logger().Info("sys: installed new encryption key")
 
-	// In HA mode, we need to an upgrade path for the standby instances
-	if b.Core.ha != nil {
-		// Create the upgrade path to the new term
-		if err := b.Core.barrier.CreateUpgrade(newTerm); err != nil {
-			b.Backend.Logger().Error("sys: failed to create new upgrade", "term", newTerm, "error", err)
-		}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":        !config.Disabled,
+			"max_operations": config.MaxOperations,
+			"interval":       int64(config.Interval.Seconds()),
+		},
+	}, nil
+}
 
-		// Schedule the destroy of the upgrade path
-		time.AfterFunc(keyRotateGracePeriod, func() {
-			if err := b.Core.barrier.DestroyUpgrade(newTerm); err != nil {
-				b.Backend.Logger().Error("sys: failed to destroy upgrade", "term", newTerm, "error", err)
-			}
-		})
+// handleRotateConfigWrite handles the "rotate/config" endpoint to update the
+// automatic rotation policy of the barrier key
+func (b *SystemBackend) handleRotateConfigWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.Core.keyRotationConfig()
+	if err != nil {
+		return handleError(err)
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Disabled = !enabledRaw.(bool)
+	}
+	if maxRaw, ok := data.GetOk("max_operations"); ok {
+		if maxRaw.(int) < 0 {
+			return logical.ErrorResponse("max_operations cannot be negative"), logical.ErrInvalidRequest
+		}
+		config.MaxOperations = uint64(maxRaw.(int))
+	}
+	if intervalRaw, ok := data.GetOk("interval"); ok {
+		config.Interval = time.Duration(intervalRaw.(int)) * time.Second
+	}
+	if err := config.Validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
-	// Write to the canary path, which will force a synchronous truing during
-	// replication
-	if err := b.Core.barrier.Put(&Entry{
-		Key:   coreKeyringCanaryPath,
-		Value: []byte(fmt.Sprintf("new-rotation-term-%d", newTerm)),
-	}); err != nil {
-		b.Core.logger.Error("core: error saving keyring canary", "error", err)
-		return nil, fmt.Errorf("failed to save keyring canary: %v", err)
+	if err := b.Core.setKeyRotationConfig(config); err != nil {
+		return handleError(err)
 	}
 
 	return nil, nil
@@ -1778,6 +3454,12 @@ func (b *SystemBackend) handleWrappingUnwrap(
 		token = req.ClientToken
 	}
 
+	// Tokens holding requests for control groups replay them once
+	// authorized, rather than returning a wrapped response
+	if resp, ok, err := b.Core.unwrapControlGroupRequest(token); ok {
+		return resp, err
+	}
+
 	if thirdParty {
 		// Use the token to decrement the use count to avoid a second operation on the token.
 		_, err := b.Core.tokenStore.UseTokenByID(token)
@@ -1874,6 +3556,9 @@ func (b *SystemBackend) handleWrappingLookup(
 		// This was JSON marshaled so it's already a string in RFC3339 format
 		resp.Data["creation_time"] = cubbyResp.Data["creation_time"]
 	}
+	if creationPath, ok := cubbyResp.Data["creation_path"].(string); ok {
+		resp.Data["creation_path"] = creationPath
+	}
 
 	return resp, nil
 }
@@ -1933,6 +3618,10 @@ func (b *SystemBackend) handleWrappingRewrap(
 		return nil, fmt.Errorf("error reading creation_ttl value from wrapping information: %v", err)
 	}
 
+	// Keep the path of the original request, which is not stored by older
+	// versions
+	creationPath, _ := cubbyResp.Data["creation_path"].(string)
+
 	// Fetch the original response and return it as the data for the new response
 	cubbyReq = &logical.Request{
 		Operation:   logical.ReadOperation,
@@ -1965,7 +3654,8 @@ func (b *SystemBackend) handleWrappingRewrap(
 			"response": response,
 		},
 		WrapInfo: &logical.ResponseWrapInfo{
-			TTL: time.Duration(creationTTL),
+			TTL:          time.Duration(creationTTL),
+			CreationPath: creationPath,
 		},
 	}, nil
 }
@@ -2108,6 +3798,12 @@ and max_lease_ttl.`,
 and is unaffected by replication.`,
 	},
 
+	"mount_seal_wrap": {
+		`Whether the sensitive values stored by the backend, such as
+private keys, are also encrypted by the seal. This has no effect
+with the default Shamir seal.`,
+	},
+
 	"tune_default_lease_ttl": {
 		`The default lease TTL for this mount.`,
 	},
@@ -2129,13 +3825,244 @@ This path responds to the following HTTP methods.
 	"auth_tune": {
 		"Tune the configuration parameters for an auth path.",
 		`Read and write the 'default-lease-ttl' and 'max-lease-ttl' values of
-the auth path.`,
+the auth path, the type of the tokens issued at login, the keys of the data
+logged without hashing by the audit backends, and the user lockout
+configuration of the auth paths supporting it.`,
+	},
+
+	"tune_lockout_threshold": {
+		`The number of failed logins after which a user is locked out.`,
+	},
+
+	"tune_lockout_duration": {
+		`How long a user is locked out.`,
+	},
+
+	"tune_lockout_counter_reset": {
+		`How long after the last failed login the failed logins of a user are forgotten.`,
+	},
+
+	"tune_lockout_disable": {
+		`Whether to disable the user lockout.`,
+	},
+
+	"tune_token_type": {
+		`The type of the tokens issued at login, "service" or "batch".`,
+	},
+
+	"tune_audit_non_hmac_request_keys": {
+		`The comma-separated keys of the request data logged without hashing by the audit backends.`,
+	},
+
+	"tune_audit_non_hmac_response_keys": {
+		`The comma-separated keys of the response data logged without hashing by the audit backends.`,
+	},
+
+	"locked-users": {
+		"List the users locked out of the auth mounts.",
+		`
+Users of the auth mounts supporting it, such as userpass, are locked out
+after too many failed logins. This path lists them by auth mount, with their
+number of failed logins and the time their lockout ends.
+		`,
+	},
+
+	"locked-users-mount": {
+		`The path of the auth mount of the user.`,
+	},
+
+	"locked-users-username": {
+		`The name of the user.`,
+	},
+
+	"locked-users-unlock": {
+		"Lift the lockout of a user.",
+		`
+This path resets the failed logins of a user of an auth mount, so the user
+can log in again before the end of the lockout.
+		`,
+	},
+
+	"mfa-totp-list": {
+		"List the TOTP MFA methods.",
+		"",
+	},
+
+	"mfa-method-name": {
+		`The name of the MFA method.`,
+	},
+
+	"mfa-user": {
+		`The user, as the path of its auth mount followed by its name, such as "userpass/alice".`,
+	},
+
+	"mfa-totp": {
+		"Configure a TOTP MFA method.",
+		`
+TOTP MFA methods validate the time-based one-time passwords of RFC 6238,
+generated by the authenticator applications of the users enrolled in them.
+The logins requiring a method with login enforcements must provide a
+passcode in the X-Vault-MFA header, as "<method name>:<passcode>".
+		`,
+	},
+
+	"mfa-totp-issuer": {
+		`The name of the issuer of the TOTP keys, shown by the authenticators.`,
+	},
+
+	"mfa-totp-period": {
+		`The number of seconds a passcode is valid. Defaults to 30.`,
+	},
+
+	"mfa-totp-digits": {
+		`The number of digits of the passcodes, 6 or 8. Defaults to 6.`,
+	},
+
+	"mfa-totp-algorithm": {
+		`The hash algorithm of the passcodes, SHA1, SHA256 or SHA512. Defaults to SHA1.`,
+	},
+
+	"mfa-totp-skew": {
+		`The number of periods before and after the current one whose passcodes are accepted, 0 or 1. Defaults to 1.`,
+	},
+
+	"mfa-totp-key-size": {
+		`The size in bytes of the generated keys. Defaults to 20.`,
+	},
+
+	"mfa-totp-admin-generate": {
+		"Enroll a user in a TOTP MFA method.",
+		`
+This path generates the TOTP key of a user, and returns it as an otpauth URL
+to be loaded in the authenticator of the user. The user must not be enrolled
+in the method already.
+		`,
+	},
+
+	"mfa-totp-admin-destroy": {
+		"Remove the enrollment of a user in a TOTP MFA method.",
+		`
+This path deletes the TOTP key of a user. The logins of the user requiring
+the method fail until the user is enrolled again.
+		`,
+	},
+
+	"mfa-login-enforcement-list": {
+		"List the login MFA enforcements.",
+		"",
+	},
+
+	"mfa-login-enforcement-name": {
+		`The name of the login enforcement.`,
+	},
+
+	"mfa-login-enforcement-methods": {
+		`Comma separated list of the MFA methods required by the enforcement.`,
+	},
+
+	"mfa-login-enforcement-auth-mounts": {
+		`Comma separated list of the paths of the auth mounts whose logins require the MFA methods.`,
+	},
+
+	"mfa-login-enforcement-users": {
+		`Comma separated list of the users whose logins require the MFA methods, as the paths of their auth mounts followed by their names.`,
+	},
+
+	"mfa-login-enforcement": {
+		"Configure a login MFA enforcement.",
+		`
+Login enforcements require MFA methods for the logins on auth mounts, or for
+the logins of some users. The users which are not enrolled in a method
+required for their login cannot log in.
+		`,
+	},
+
+	"control-group-accessor": {
+		`The accessor of the token returned for the held request.`,
+	},
+
+	"control-group-authorize": {
+		"Authorize a request held by a control group.",
+		`
+Requests on paths whose policies set a control group are held until enough
+approvers authorize them. Approvers are tokens having one of the approver
+policies of the control group. They must be issued by a login to an auth
+backend, or created from such a token, and are counted once per user of an
+auth mount; requesters cannot authorize their own requests.
+		`,
+	},
+
+	"control-group-request": {
+		"Read the state of a request held by a control group.",
+		`
+This path returns the held request along with its authorizations. Once it is
+approved, the requester replays the request by unwrapping its token through
+sys/wrapping/unwrap.
+		`,
+	},
+
+	"quotas-name": {
+		`The name of the quota.`,
+	},
+
+	"quotas-path": {
+		`The path the quota applies to: a mount path, optionally followed by a path within the mount. If empty, the quota applies to every request.`,
+	},
+
+	"quotas-rate-limit-list": {
+		"List the rate limit quotas.",
+		"",
+	},
+
+	"quotas-rate-limit-rate": {
+		`The number of requests each client may make per interval.`,
+	},
+
+	"quotas-rate-limit-burst": {
+		`The number of requests each client may make at once. Defaults to the rate.`,
+	},
+
+	"quotas-rate-limit-interval": {
+		`The interval the rate applies to. Defaults to one second.`,
+	},
+
+	"quotas-rate-limit-block-interval": {
+		`If set, the clients exceeding the quota are rejected for this long.`,
+	},
+
+	"quotas-rate-limit": {
+		"Configure a rate limit quota.",
+		`
+Rate limit quotas limit the rate of the requests of each client to their path.
+The requests exceeding the quota are rejected with a 429 status code. When
+several quotas match a request, the quota with the longest path applies.
+		`,
+	},
+
+	"quotas-lease-count-list": {
+		"List the lease count quotas.",
+		"",
+	},
+
+	"quotas-lease-count-max-leases": {
+		`The maximum number of leases under the path.`,
+	},
+
+	"quotas-lease-count": {
+		"Configure a lease count quota.",
+		`
+Lease count quotas limit the number of the leases under their path. Once the
+limit is reached, the logins and the reads and writes under the path are
+rejected with a 429 status code until leases expire or are revoked. When
+several quotas match a request, the quota with the longest path applies.
+		`,
 	},
 
 	"mount_tune": {
 		"Tune backend configuration parameters for this mount.",
 		`Read and write the 'default-lease-ttl' and 'max-lease-ttl' values of
-the mount.`,
+the mount, and the keys of the data logged without hashing by the audit
+backends.`,
 	},
 
 	"renew": {
@@ -2202,6 +4129,30 @@ of external secrets. Access to this prefix should be tightly controlled.
 		"",
 	},
 
+	"revoke-sync": {
+		`Whether to wait for the leases to be revoked. If false, the revocation
+runs in the background and its job ID is returned. Defaults to true.`,
+		"",
+	},
+
+	"revoke-jobs": {
+		"Reports the progress of the revocations by prefix running in the background.",
+		`
+The revocations started on revoke-prefix and revoke-force with sync set to
+false run as jobs in the background. Listing this path returns the IDs of the
+jobs, and reading a job returns its status ("running", "completed", "failed"
+or "canceled"), the numbers of leases to revoke, revoked and failed, and the
+errors of up to 100 failed leases. The jobs are kept in memory on the active
+node: they are canceled when it seals or steps down, and the finished jobs are
+reported for 24 hours.
+		`,
+	},
+
+	"revoke-job-id": {
+		"The ID of the revocation job.",
+		"",
+	},
+
 	"auth-table": {
 		"List the currently enabled credential backends.",
 		`
@@ -2281,6 +4232,51 @@ or delete a policy.
 		"",
 	},
 
+	"password-policy-list": {
+		"List the configured password policies.",
+		"",
+	},
+
+	"password-policy": {
+		"Read, write and delete the password policies.",
+		`
+Password policies describe the passwords generated by the backends that
+support them, such as the length of the passwords and the characters they
+must contain. They are referenced by name in the configuration of those
+backends.
+
+This path responds to the following HTTP methods.
+
+    GET /<name>
+        Retrieve the definition of the named password policy.
+
+    PUT /<name>
+        Create or update the named password policy.
+
+    DELETE /<name>
+        Delete the named password policy.
+		`,
+	},
+
+	"password-policy-generate": {
+		"Generate a password from a password policy.",
+		`
+Generates a password satisfying the named password policy, for instance to
+check that the policy generates the expected passwords.
+		`,
+	},
+
+	"password-policy-name": {
+		`The name of the password policy.`,
+		"",
+	},
+
+	"password-policy-policy": {
+		`The definition of the password policy, given in HCL or JSON format,
+optionally base64-encoded.`,
+		"",
+	},
+
 	"audit-hash": {
 		"The hash of the given string via the given audit backend",
 		"",
@@ -2332,7 +4328,9 @@ Enable a new audit backend or disable an existing backend.
 	"key-status": {
 		"Provides information about the backend encryption key.",
 		`
-		Provides the current backend encryption key term and installation time.
+		Provides the current backend encryption key term and installation time,
+		along with the number of values it has encrypted and the estimated time
+		of its next automatic rotation.
 		`,
 	},
 
@@ -2345,6 +4343,124 @@ Enable a new audit backend or disable an existing backend.
 		`,
 	},
 
+	"config/cors": {
+		"Configures the cross-origin resource sharing of the API.",
+		`
+		Enables the browser applications of the allowed origins to call the
+		API directly. Writing the configuration enables CORS, and deleting it
+		disables CORS again.
+		`,
+	},
+
+	"internal/ui/mounts": {
+		"Lists the mounts the token can access.",
+		`
+		Returns the secret and auth mounts under which the policies of the token
+		grant capabilities on at least one path, with their type, description
+		and options. Unlike sys/mounts and sys/auth, it does not require a
+		capability on the endpoint itself.
+		`,
+	},
+
+	"metrics": {
+		"Export the metrics of the server.",
+		`
+		Returns the telemetry collected by the server. By default these are the
+		metrics of the current aggregation interval, in JSON. With the
+		"prometheus" format, they are the metrics accumulated since the start of
+		the server, in the Prometheus text exposition format.
+		`,
+	},
+
+	"metrics-format": {
+		`The format of the metrics, "json" or "prometheus". Defaults to "json".`,
+		"",
+	},
+
+	"internal/counters/activity": {
+		"Reports the distinct clients of the auth mounts.",
+		`
+		Returns the numbers of distinct tokens which made requests between the
+		start and end times, in total and for each auth mount. Each token counts
+		once for the auth mount it was created with. Tokens are not tied to
+		entities, so every client is counted as a non-entity token.
+		`,
+	},
+
+	"activity-start-time": {
+		"The start of the report, as an RFC3339 time. Defaults to the default report period before the end.",
+		"",
+	},
+
+	"activity-end-time": {
+		"The end of the report, as an RFC3339 time. Defaults to now.",
+		"",
+	},
+
+	"internal/counters/config": {
+		"Configures the counting of the distinct clients.",
+		`
+		The clients are counted per UTC day, and the days older than the
+		retention period are deleted. Disabling the counting keeps the
+		recorded days.
+		`,
+	},
+
+	"activity-enabled": {
+		"Whether the clients are counted. Defaults to true.",
+		"",
+	},
+
+	"activity-retention-days": {
+		"The number of days the counts are kept. Defaults to 365.",
+		"",
+	},
+
+	"activity-default-report-days": {
+		"The number of days reported when no start time is given. Defaults to 30.",
+		"",
+	},
+
+	"cors-allowed-origins": {
+		"The origins allowed to call the API, as a list or a comma separated string. '*' allows all origins.",
+		"",
+	},
+
+	"cors-allowed-headers": {
+		"The headers the browsers may send, in addition to the standard headers of the API.",
+		"",
+	},
+
+	"cors-allow-credentials": {
+		"Whether the browsers may send credentials such as cookies. Defaults to false.",
+		"",
+	},
+
+	"rotate-config": {
+		"Configures the automatic rotation of the backend encryption key.",
+		`
+		The backend encryption key is rotated automatically once it has
+		encrypted max_operations values, or once it is older than interval
+		if it is set. The progress towards the next rotation is reported by
+		the key-status endpoint.
+		`,
+	},
+
+	"rotation-enabled": {
+		"Whether the encryption key is rotated automatically. Defaults to true.",
+		"",
+	},
+
+	"rotation-max-operations": {
+		fmt.Sprintf("The number of encryptions after which the key is rotated, between %d and %d. Defaults to %d.", minKeyRotationOperations, maxKeyRotationOperations, maxKeyRotationOperations),
+		"",
+	},
+
+	"rotation-interval": {
+		"The age after which the key is rotated, at least 24h. Defaults to 0, which disables the rotation by age.",
+		"",
+	},
+
 	"rekey_backup": {
 		"Allows fetching or deleting the backup of the rotated unseal keys.",
 		"",
@@ -2370,7 +4486,9 @@ Enable a new audit backend or disable an existing backend.
 
 	"wrap": {
 		"Response-wraps an arbitrary JSON object.",
-		`Round trips the given input data into a response-wrapped token.`,
+		`Round trips the given input data into a response-wrapped token, so that
+data that is not stored in Vault can be handed off with response wrapping.
+The response only contains the wrapping token.`,
 	},
 
 	"wrappubkey": {
@@ -2387,7 +4505,7 @@ Enable a new audit backend or disable an existing backend.
 
 	"wraplookup": {
 		"Looks up the properties of a response-wrapped token.",
-		`Returns the creation TTL and creation time of a response-wrapped token.`,
+		`Returns the creation TTL, creation time and creation path of a response-wrapped token.`,
 	},
 
 	"rewrap": {
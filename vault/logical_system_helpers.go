@@ -83,3 +83,74 @@ func (b *SystemBackend) tuneMountTTLs(path string, me *MountEntry, newDefault, n
 
 	return nil
 }
+
+// tuneMountUserLockout is used to set the user lockout config on an auth mount
+func (b *SystemBackend) tuneMountUserLockout(path string, me *MountEntry, newConfig *userLockoutConfig) error {
+	meConfig := &me.Config
+	orig := *meConfig
+
+	meConfig.LockoutThreshold = newConfig.Threshold
+	meConfig.LockoutDuration = newConfig.Duration
+	meConfig.LockoutCounterReset = newConfig.CounterReset
+	meConfig.LockoutDisable = newConfig.Disable
+
+	// Update the auth table
+	if err := b.Core.persistAuth(b.Core.auth, me.Local); err != nil {
+		*meConfig = orig
+		return fmt.Errorf("failed to update mount table, rolling back user lockout changes")
+	}
+
+	if b.Core.logger.IsInfo() {
+		b.Core.logger.Info("core: mount tuning successful", "path", path)
+	}
+
+	return nil
+}
+
+// tuneMountAuditKeys is used to set the keys of the request and response data
+// logged without hashing by the audit backends
+func (b *SystemBackend) tuneMountAuditKeys(path string, me *MountEntry, requestKeys, responseKeys []string) error {
+	meConfig := &me.Config
+	origRequestKeys, origResponseKeys := meConfig.AuditNonHMACRequestKeys, meConfig.AuditNonHMACResponseKeys
+	meConfig.AuditNonHMACRequestKeys = requestKeys
+	meConfig.AuditNonHMACResponseKeys = responseKeys
+
+	// Update the mount table
+	var err error
+	switch {
+	case strings.HasPrefix(path, "auth/"):
+		err = b.Core.persistAuth(b.Core.auth, me.Local)
+	default:
+		err = b.Core.persistMounts(b.Core.mounts, me.Local)
+	}
+	if err != nil {
+		meConfig.AuditNonHMACRequestKeys = origRequestKeys
+		meConfig.AuditNonHMACResponseKeys = origResponseKeys
+		return fmt.Errorf("failed to update mount table, rolling back audit key changes")
+	}
+
+	if b.Core.logger.IsInfo() {
+		b.Core.logger.Info("core: mount tuning successful", "path", path)
+	}
+
+	return nil
+}
+
+// tuneMountTokenType is used to set the type of the tokens issued by the
+// logins to an auth mount
+func (b *SystemBackend) tuneMountTokenType(path string, me *MountEntry, tokenType string) error {
+	orig := me.Config.TokenType
+	me.Config.TokenType = tokenType
+
+	// Update the auth table
+	if err := b.Core.persistAuth(b.Core.auth, me.Local); err != nil {
+		me.Config.TokenType = orig
+		return fmt.Errorf("failed to update mount table, rolling back token type changes")
+	}
+
+	if b.Core.logger.IsInfo() {
+		b.Core.logger.Info("core: mount tuning successful", "path", path)
+	}
+
+	return nil
+}
@@ -7,8 +7,10 @@ import (
 	"testing"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
 )
@@ -24,7 +26,12 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"replication/primary/secondary-token",
 		"replication/reindex",
 		"rotate",
+		"rotate/config",
 		"config/auditing/*",
+		"config/cors",
+		"internal/counters/config",
+		"mfa/*",
+		"quotas/*",
 	}
 
 	b := testSystemBackend(t)
@@ -53,7 +60,8 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"max_lease_ttl":     resp.Data["secret/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"sys/": map[string]interface{}{
 			"type":        "system",
@@ -63,7 +71,8 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"max_lease_ttl":     resp.Data["sys/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
 				"force_no_cache":    false,
 			},
-			"local": false,
+			"local":     false,
+			"seal_wrap": false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -73,7 +82,8 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"max_lease_ttl":     resp.Data["cubbyhole/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
 				"force_no_cache":    false,
 			},
-			"local": true,
+			"local":     true,
+			"seal_wrap": false,
 		},
 	}
 	if !reflect.DeepEqual(resp.Data, exp) {
@@ -122,6 +132,160 @@ func TestSystemBackend_mount_force_no_cache(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_internalUIMounts(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	policy, _ := Parse(`
+name = "dev"
+path "secret/*" {
+	capabilities = ["read"]
+}
+`)
+	if err := c.policyStore.SetPolicy(policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ent := &TokenEntry{
+		ID:       "uimountstoken",
+		Path:     "auth/token/create",
+		Policies: []string{"dev"},
+	}
+	if err := c.tokenStore.create(ent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	mounts := func(token string) (map[string]interface{}, map[string]interface{}, error) {
+		resp, err := c.HandleRequest(&logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "sys/internal/ui/mounts",
+			ClientToken: token,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return resp.Data["secret"].(map[string]interface{}), resp.Data["auth"].(map[string]interface{}), nil
+	}
+
+	// The token only sees the mounts it can access, without a capability on
+	// the endpoint
+	secret, auth, err := mounts("uimountstoken")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(secret) != 1 || secret["secret/"] == nil || len(auth) != 0 {
+		t.Fatalf("bad: %#v %#v", secret, auth)
+	}
+	if info := secret["secret/"].(map[string]interface{}); info["type"] != "generic" {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	secret, auth, err = mounts(root)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if secret["secret/"] == nil || secret["sys/"] == nil || secret["cubbyhole/"] == nil || auth["token/"] == nil {
+		t.Fatalf("bad: %#v %#v", secret, auth)
+	}
+
+	for _, token := range []string{"", "invalid"} {
+		if _, _, err := mounts(token); err != logical.ErrPermissionDenied {
+			t.Fatalf("%q: err: %v", token, err)
+		}
+	}
+}
+
+func TestSystemBackend_metrics(t *testing.T) {
+	core, b, _ := testCoreSystemBackend(t)
+
+	// The test core does not collect metrics
+	req := logical.TestRequest(t, logical.ReadOperation, "metrics")
+	if _, err := b.HandleRequest(req); err != logical.ErrUnsupportedPath {
+		t.Fatalf("bad: %v", err)
+	}
+
+	prom := metricsutil.NewPrometheusSink(time.Hour)
+	prom.IncrCounter([]string{"vault", "test"}, 2)
+	core.metricsHelper = metricsutil.NewMetricsHelper(metrics.NewInmemSink(time.Second, time.Minute), prom)
+
+	req = logical.TestRequest(t, logical.ReadOperation, "metrics")
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := resp.Data["gauges"]; !ok {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "metrics")
+	req.Data["format"] = "prometheus"
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(resp.Data[logical.HTTPRawBody].([]byte)) != "# TYPE vault_test counter\nvault_test 2\n" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "metrics")
+	req.Data["format"] = "xml"
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestSystemBackend_tune_auditNonHMACKeys(t *testing.T) {
+	core, b, root := testCoreSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "mounts/secret/tune")
+	req.Data["audit_non_hmac_request_keys"] = "ttl, role"
+	req.Data["audit_non_hmac_response_keys"] = []string{"serial_number"}
+	resp, err := b.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("bad: %v %v", resp, err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "mounts/secret/tune")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["audit_non_hmac_request_keys"], []string{"ttl", "role"}) ||
+		!reflect.DeepEqual(resp.Data["audit_non_hmac_response_keys"], []string{"serial_number"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The keys are attached to the requests to the mount for the audit
+	// backends
+	noop := &NoopAudit{}
+	core.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
+		noop.Config = config
+		return noop, nil
+	}
+	if err := core.enableAudit(&MountEntry{Table: auditTableType, Path: "noop", Type: "noop"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.Data["ttl"] = "1h"
+	req.ClientToken = root
+	if _, err := core.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(noop.Req) != 1 || !reflect.DeepEqual(noop.Req[0].AuditNonHMACRequestKeys, []string{"ttl", "role"}) {
+		t.Fatalf("bad: %#v", noop.Req)
+	}
+
+	// An empty list clears the keys
+	req = logical.TestRequest(t, logical.UpdateOperation, "mounts/secret/tune")
+	req.Data["audit_non_hmac_request_keys"] = ""
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	mountEntry := core.router.MatchingMountEntry("secret/")
+	if len(mountEntry.Config.AuditNonHMACRequestKeys) != 0 ||
+		len(mountEntry.Config.AuditNonHMACResponseKeys) != 1 {
+		t.Fatalf("bad config %#v", mountEntry.Config)
+	}
+}
+
 func TestSystemBackend_mount_invalid(t *testing.T) {
 	b := testSystemBackend(t)
 
@@ -535,6 +699,87 @@ func TestSystemBackend_revokePrefix(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_revokePrefixAsync(t *testing.T) {
+	core, b, root := testCoreSystemBackend(t)
+
+	// Create a key with a lease
+	req := logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.Data["foo"] = "bar"
+	req.Data["lease"] = "1h"
+	req.ClientToken = root
+	resp, err := core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Read a key with a LeaseID
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+	req.ClientToken = root
+	resp, err = core.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	leaseID := resp.Secret.LeaseID
+
+	// Revoke in the background
+	req = logical.TestRequest(t, logical.UpdateOperation, "revoke-prefix/secret/")
+	req.Data["sync"] = false
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	jobID, ok := resp.Data["job_id"].(string)
+	if !ok || jobID == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The job is listed
+	req = logical.TestRequest(t, logical.ListOperation, "revoke-jobs")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{jobID}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Wait for the job to complete
+	for i := 0; ; i++ {
+		req = logical.TestRequest(t, logical.ReadOperation, "revoke-jobs/"+jobID)
+		resp, err = b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Data["status"] != revokeJobRunning {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("bad: %#v", resp)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if resp.Data["status"] != revokeJobCompleted || resp.Data["revoked"] != int64(1) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The lease is revoked
+	req = logical.TestRequest(t, logical.UpdateOperation, "renew/"+leaseID)
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Unknown jobs are not found
+	req = logical.TestRequest(t, logical.ReadOperation, "revoke-jobs/unknown")
+	resp, err = b.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("bad: %#v %v", resp, err)
+	}
+}
+
 func TestSystemBackend_revokePrefixAuth(t *testing.T) {
 	core, ts, _, _ := TestCoreWithTokenStore(t)
 	bc := &logical.BackendConfig{
@@ -780,6 +1025,100 @@ func TestSystemBackend_policyCRUD(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_passwordPolicyCRUD(t *testing.T) {
+	b := testSystemBackend(t)
+
+	// Invalid policies are rejected
+	req := logical.TestRequest(t, logical.UpdateOperation, "policies/password/foo")
+	req.Data["policy"] = `length = 2`
+	resp, err := b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected invalid request, got: %v %#v", err, resp)
+	}
+
+	// Create the policy
+	policy := `length = 16
+rule "charset" {
+  charset = "abc"
+}
+rule "charset" {
+  charset = "0123456789"
+  min-chars = 2
+}`
+	req = logical.TestRequest(t, logical.UpdateOperation, "policies/password/Foo")
+	req.Data["policy"] = policy
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %#v", err, resp)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Read the policy, and make sure that case has been normalized
+	req = logical.TestRequest(t, logical.ReadOperation, "policies/password/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	exp := map[string]interface{}{
+		"policy": policy,
+	}
+	if !reflect.DeepEqual(resp.Data, exp) {
+		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
+	}
+
+	// List the policies
+	req = logical.TestRequest(t, logical.ListOperation, "policies/password")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	exp = map[string]interface{}{
+		"keys": []string{"foo"},
+	}
+	if !reflect.DeepEqual(resp.Data, exp) {
+		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
+	}
+
+	// Generate a password
+	req = logical.TestRequest(t, logical.ReadOperation, "policies/password/foo/generate")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	password := resp.Data["password"].(string)
+	if len(password) != 16 || strings.Trim(password, "abc0123456789") != "" {
+		t.Fatalf("bad: password %q", password)
+	}
+	digits := 0
+	for _, r := range password {
+		if strings.ContainsRune("0123456789", r) {
+			digits++
+		}
+	}
+	if digits < 2 {
+		t.Fatalf("bad: password %q", password)
+	}
+
+	// Delete the policy
+	req = logical.TestRequest(t, logical.DeleteOperation, "policies/password/foo")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Passwords can no longer be generated from it
+	req = logical.TestRequest(t, logical.ReadOperation, "policies/password/foo/generate")
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected invalid request, got: %v %#v", err, resp)
+	}
+}
+
 func TestSystemBackend_enableAudit(t *testing.T) {
 	c, b, _ := testCoreSystemBackend(t)
 	c.auditBackends["noop"] = func(config *audit.BackendConfig) (audit.Backend, error) {
@@ -1039,6 +1378,8 @@ func TestSystemBackend_keyStatus(t *testing.T) {
 		"term": 1,
 	}
 	delete(resp.Data, "install_time")
+	delete(resp.Data, "encryptions")
+	delete(resp.Data, "next_rotation_time")
 	if !reflect.DeepEqual(resp.Data, exp) {
 		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
 	}
@@ -1066,6 +1407,8 @@ func TestSystemBackend_rotate(t *testing.T) {
 		"term": 2,
 	}
 	delete(resp.Data, "install_time")
+	delete(resp.Data, "encryptions")
+	delete(resp.Data, "next_rotation_time")
 	if !reflect.DeepEqual(resp.Data, exp) {
 		t.Fatalf("got: %#v expect: %#v", resp.Data, exp)
 	}
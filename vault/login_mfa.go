@@ -0,0 +1,367 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// loginMFASubPath is the sub-path used for the login MFA view. This is
+	// nested under the system view.
+	loginMFASubPath = "login_mfa/"
+
+	// loginMFAHeaderName is the header carrying the MFA credentials of the
+	// login requests, as "<method name>:<passcode>". It may be repeated for
+	// several methods.
+	loginMFAHeaderName = "X-Vault-MFA"
+
+	// The defaults of the TOTP methods
+	defaultTOTPPeriod    = 30 * time.Second
+	defaultTOTPDigits    = 6
+	defaultTOTPAlgorithm = "SHA1"
+	defaultTOTPSkew      = 1
+	defaultTOTPKeySize   = 20
+)
+
+// totpMethodEntry is the storage entry of a TOTP MFA method
+type totpMethodEntry struct {
+	Issuer    string        `json:"issuer"`
+	Period    time.Duration `json:"period"`
+	Digits    int           `json:"digits"`
+	Algorithm string        `json:"algorithm"`
+	Skew      int           `json:"skew"`
+	KeySize   int           `json:"key_size"`
+}
+
+// totpEnrollmentEntry is the storage entry of the secret of a user enrolled
+// in a TOTP MFA method
+type totpEnrollmentEntry struct {
+	Key []byte `json:"key"`
+}
+
+// loginEnforcementEntry is the storage entry of a login MFA enforcement. It
+// applies to the logins on its auth mounts, and to the logins of its users.
+type loginEnforcementEntry struct {
+	MFAMethods []string `json:"mfa_methods"`
+	AuthMounts []string `json:"auth_mounts"`
+	Users      []string `json:"users"`
+}
+
+// usedTOTPCodes tracks the TOTP codes used to log in, so that they cannot be
+// replayed while they are valid
+type usedTOTPCodes struct {
+	l sync.Mutex
+
+	// used maps the codes to the time they stop being valid
+	used map[string]time.Time
+}
+
+func newUsedTOTPCodes() *usedTOTPCodes {
+	return &usedTOTPCodes{
+		used: make(map[string]time.Time),
+	}
+}
+
+// use records the use of the code, and returns false if it was already used
+func (u *usedTOTPCodes) use(key string, expiration time.Time) bool {
+	now := time.Now()
+
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	for k, exp := range u.used {
+		if now.After(exp) {
+			delete(u.used, k)
+		}
+	}
+
+	if _, ok := u.used[key]; ok {
+		return false
+	}
+	u.used[key] = expiration
+	return true
+}
+
+// loginMFAView returns the view of the login MFA configuration
+func (c *Core) loginMFAView() *BarrierView {
+	return c.systemBarrierView.SubView(loginMFASubPath)
+}
+
+// loginMFAUser returns the identifier of a user of an auth mount in the
+// enrollments and the enforcements, "<mount path>/<name>"
+func loginMFAUser(mountPath, name string) string {
+	return strings.ToLower(strings.TrimPrefix(mountPath, credentialRoutePrefix) + name)
+}
+
+// totpEnrollmentKey returns the storage key of the enrollment of the user in
+// the TOTP method. The users are encoded since they contain slashes.
+func totpEnrollmentKey(method, user string) string {
+	return "enrollment/totp/" + method + "/" + base64.RawURLEncoding.EncodeToString([]byte(strings.ToLower(user)))
+}
+
+// totpMethod returns the named TOTP method, or nil if it doesn't exist
+func (c *Core) totpMethod(name string) (*totpMethodEntry, error) {
+	entry, err := c.loginMFAView().Get("method/totp/" + strings.ToLower(name))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var method totpMethodEntry
+	if err := entry.DecodeJSON(&method); err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// loginEnforcement returns the named login enforcement, or nil if it doesn't
+// exist
+func (c *Core) loginEnforcement(name string) (*loginEnforcementEntry, error) {
+	entry, err := c.loginMFAView().Get("enforcement/" + strings.ToLower(name))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var enforcement loginEnforcementEntry
+	if err := entry.DecodeJSON(&enforcement); err != nil {
+		return nil, err
+	}
+	return &enforcement, nil
+}
+
+// totpEnrollment returns the secret of the user enrolled in the TOTP method,
+// or nil if the user is not enrolled
+func (c *Core) totpEnrollment(method, user string) (*totpEnrollmentEntry, error) {
+	entry, err := c.loginMFAView().Get(totpEnrollmentKey(method, user))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var enrollment totpEnrollmentEntry
+	if err := entry.DecodeJSON(&enrollment); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// enrollTOTP generates and stores the secret of the user in the TOTP method.
+// It returns the otpauth URL to be loaded in the authenticator of the user.
+func (c *Core) enrollTOTP(name string, method *totpMethodEntry, user string) (string, error) {
+	existing, err := c.totpEnrollment(name, user)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "", fmt.Errorf("user %q is already enrolled in MFA method %q", user, name)
+	}
+
+	key := make([]byte, method.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	entry, err := logical.StorageEntryJSON(totpEnrollmentKey(name, user), &totpEnrollmentEntry{
+		Key: key,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := c.loginMFAView().Put(entry); err != nil {
+		return "", err
+	}
+
+	return method.url(user, key), nil
+}
+
+// url returns the otpauth URL of the secret of the user, understood by the
+// common authenticator applications
+func (m *totpMethodEntry) url(user string, key []byte) string {
+	v := url.Values{}
+	// The authenticator applications expect the secret without padding
+	v.Set("secret", strings.TrimRight(base32.StdEncoding.EncodeToString(key), "="))
+	v.Set("issuer", m.Issuer)
+	v.Set("algorithm", m.Algorithm)
+	v.Set("digits", strconv.Itoa(m.Digits))
+	v.Set("period", strconv.Itoa(int(m.Period/time.Second)))
+
+	// The users contain slashes, which are escaped in the label
+	return "otpauth://totp/" + url.PathEscape(m.Issuer+":"+user) + "?" + v.Encode()
+}
+
+// code returns the TOTP code of the key for the time step, as described in
+// RFC 6238
+func (m *totpMethodEntry) code(key []byte, counter uint64) (string, error) {
+	var h func() hash.Hash
+	switch m.Algorithm {
+	case "SHA1":
+		h = sha1.New
+	case "SHA256":
+		h = sha256.New
+	case "SHA512":
+		h = sha512.New
+	default:
+		return "", fmt.Errorf("unsupported TOTP algorithm %q", m.Algorithm)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < m.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", m.Digits, value%mod), nil
+}
+
+// validate returns the time step of the passcode if it is a valid code of the
+// key at the given time, within the allowed skew
+func (m *totpMethodEntry) validate(key []byte, passcode string, now time.Time) (uint64, bool, error) {
+	current := uint64(now.Unix()) / uint64(m.Period/time.Second)
+	for i := -m.Skew; i <= m.Skew; i++ {
+		counter := uint64(int64(current) + int64(i))
+		code, err := m.code(key, counter)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(code), []byte(passcode)) == 1 {
+			return counter, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// loginMFACredentials returns the passcodes of the login request by MFA
+// method
+func loginMFACredentials(headers map[string][]string) map[string]string {
+	creds := make(map[string]string)
+	for name, values := range headers {
+		if !strings.EqualFold(name, loginMFAHeaderName) {
+			continue
+		}
+		for _, value := range values {
+			for _, cred := range strings.Split(value, ",") {
+				parts := strings.SplitN(strings.TrimSpace(cred), ":", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				creds[strings.ToLower(parts[0])] = parts[1]
+			}
+		}
+	}
+	return creds
+}
+
+// loginMFAMethods returns the MFA methods required by the enforcements
+// applying to the login of the user of the auth mount
+func (c *Core) loginMFAMethods(mountPath, user string) ([]string, error) {
+	mount := strings.TrimPrefix(mountPath, credentialRoutePrefix)
+
+	names, err := c.loginMFAView().List("enforcement/")
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []string
+	for _, name := range names {
+		enforcement, err := c.loginEnforcement(name)
+		if err != nil {
+			return nil, err
+		}
+		if enforcement == nil {
+			continue
+		}
+		if !strutil.StrListContains(enforcement.AuthMounts, mount) &&
+			!strutil.StrListContains(enforcement.Users, user) {
+			continue
+		}
+		for _, method := range enforcement.MFAMethods {
+			if !strutil.StrListContains(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+	}
+	return methods, nil
+}
+
+// enforceLoginMFA checks the passcodes of the MFA methods required for the
+// login of the user of the auth mount. The users which are not enrolled in a
+// required method cannot log in.
+func (c *Core) enforceLoginMFA(mountPath, name string, creds map[string]string) error {
+	user := loginMFAUser(mountPath, name)
+
+	methods, err := c.loginMFAMethods(mountPath, user)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, methodName := range methods {
+		method, err := c.totpMethod(methodName)
+		if err != nil {
+			return err
+		}
+		if method == nil {
+			return fmt.Errorf("MFA method %q not found", methodName)
+		}
+
+		passcode, ok := creds[methodName]
+		if !ok {
+			return fmt.Errorf("MFA passcode for method %q required", methodName)
+		}
+
+		enrollment, err := c.totpEnrollment(methodName, user)
+		if err != nil {
+			return err
+		}
+		if enrollment == nil {
+			return fmt.Errorf("user not enrolled in MFA method %q", methodName)
+		}
+
+		counter, valid, err := method.validate(enrollment.Key, passcode, now)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return fmt.Errorf("invalid MFA passcode for method %q", methodName)
+		}
+
+		// The codes stop being valid once the time steps within the skew
+		// are over
+		expiration := time.Unix(int64(counter+uint64(method.Skew)+1)*int64(method.Period/time.Second), 0)
+		if !c.usedTOTPCodes.use(fmt.Sprintf("%s/%s/%d", methodName, user, counter), expiration) {
+			return fmt.Errorf("MFA passcode for method %q already used", methodName)
+		}
+	}
+	return nil
+}
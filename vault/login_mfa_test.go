@@ -0,0 +1,231 @@
+package vault
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTOTPMethod_code(t *testing.T) {
+	// The test vectors of RFC 6238
+	keys := map[string][]byte{
+		"SHA1":   []byte("12345678901234567890"),
+		"SHA256": []byte("12345678901234567890123456789012"),
+		"SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	cases := []struct {
+		time      int64
+		algorithm string
+		expected  string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{20000000000, "SHA1", "65353130"},
+	}
+	for _, c := range cases {
+		method := &totpMethodEntry{
+			Period:    30 * time.Second,
+			Digits:    8,
+			Algorithm: c.algorithm,
+		}
+		code, err := method.code(keys[c.algorithm], uint64(c.time/30))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != c.expected {
+			t.Fatalf("bad: %d %s: expected:%s actual:%s", c.time, c.algorithm, c.expected, code)
+		}
+	}
+}
+
+func TestLoginMFACredentials(t *testing.T) {
+	creds := loginMFACredentials(map[string][]string{
+		"X-Vault-Mfa":  []string{"totp:123456, Other:654321"},
+		"Content-Type": []string{"application/json"},
+	})
+	if len(creds) != 2 || creds["totp"] != "123456" || creds["other"] != "654321" {
+		t.Fatalf("bad: %#v", creds)
+	}
+}
+
+func TestRequestHandling_LoginMFA(t *testing.T) {
+	core, _, root := TestCoreUnsealed(t)
+
+	core.credentialBackends["userpass"] = credUserpass.Factory
+
+	requests := []*logical.Request{
+		&logical.Request{
+			Path: "sys/auth/userpass",
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		&logical.Request{
+			Path: "auth/userpass/users/test",
+			Data: map[string]interface{}{
+				"password": "foo",
+				"policies": "default",
+			},
+		},
+		&logical.Request{
+			Path: "auth/userpass/users/other",
+			Data: map[string]interface{}{
+				"password": "foo",
+				"policies": "default",
+			},
+		},
+		&logical.Request{
+			Path: "sys/mfa/method/totp/totp",
+			Data: map[string]interface{}{
+				"issuer": "Vault",
+			},
+		},
+		&logical.Request{
+			Path: "sys/mfa/login-enforcement/test",
+			Data: map[string]interface{}{
+				"mfa_methods": "totp",
+				"users":       "userpass/test",
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		req.Operation = logical.UpdateOperation
+		resp, err := core.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err:%v resp:%#v", req.Path, err, resp)
+		}
+	}
+
+	login := func(username, passcode string) (*logical.Response, error) {
+		req := &logical.Request{
+			Path:      "auth/userpass/login/" + username,
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"password": "foo",
+			},
+		}
+		if passcode != "" {
+			req.Headers = map[string][]string{
+				"X-Vault-Mfa": []string{"totp:" + passcode},
+			}
+		}
+		return core.HandleRequest(req)
+	}
+
+	// The users without enforcement log in without second factor
+	resp, err := login("other", "")
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// The users not enrolled in the required methods cannot log in
+	if _, err := login("test", "123456"); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "sys/mfa/method/totp/totp/admin-generate",
+		ClientToken: root,
+		Operation:   logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"user": "userpass/test",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	otpURL, err := url.Parse(resp.Data["url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otpURL.Scheme != "otpauth" || otpURL.Path != "/Vault:userpass/test" {
+		t.Fatalf("bad: %s", otpURL)
+	}
+	secret := otpURL.Query().Get("secret")
+	if strings.Contains(secret, "=") {
+		t.Fatalf("bad: padded secret: %s", secret)
+	}
+	key, err := base32.StdEncoding.DecodeString(secret + strings.Repeat("=", (8-len(secret)%8)%8))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method, err := core.totpMethod("totp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passcode, err := method.code(key, uint64(time.Now().Unix()/30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := login("test", ""); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	wrong := "000000"
+	if passcode == wrong {
+		wrong = "111111"
+	}
+	if _, err := login("test", wrong); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	resp, err = login("test", passcode)
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// The passcodes cannot be replayed
+	if _, err := login("test", passcode); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	// The methods used by enforcements cannot be deleted
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "sys/mfa/method/totp/totp",
+		ClientToken: root,
+		Operation:   logical.DeleteOperation,
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got err:%v resp:%#v", err, resp)
+	}
+
+	// Enforcements may apply to all the users of an auth mount
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "sys/mfa/login-enforcement/test",
+		ClientToken: root,
+		Operation:   logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"users":       "",
+			"auth_mounts": "auth/userpass",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if _, err := login("other", ""); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "sys/mfa/login-enforcement/test",
+		ClientToken: root,
+		Operation:   logical.ReadOperation,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if mounts := resp.Data["auth_mounts"].([]string); len(mounts) != 1 || mounts[0] != "userpass/" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
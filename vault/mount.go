@@ -12,6 +12,7 @@ import (
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
 )
 
 const (
@@ -139,6 +140,7 @@ type MountEntry struct {
 	Config      MountConfig       `json:"config"`            // Configuration related to this mount (but not backend-derived)
 	Options     map[string]string `json:"options"`           // Backend options
 	Local       bool              `json:"local"`             // Local mounts are not replicated or affected by replication
+	SealWrap    bool              `json:"seal_wrap"`         // Whether the sensitive storage of the backend is also encrypted by the seal
 	Tainted     bool              `json:"tainted,omitempty"` // Set as a Write-Ahead flag for unmount/remount
 }
 
@@ -147,6 +149,21 @@ type MountConfig struct {
 	DefaultLeaseTTL time.Duration `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"` // Override for global default
 	MaxLeaseTTL     time.Duration `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`             // Override for global default
 	ForceNoCache    bool          `json:"force_no_cache" structs:"force_no_cache" mapstructure:"force_no_cache"`          // Override for global default
+
+	// User lockout configuration of auth mounts, zero values are defaults
+	LockoutThreshold    int           `json:"lockout_threshold,omitempty" structs:"lockout_threshold" mapstructure:"lockout_threshold"`
+	LockoutDuration     time.Duration `json:"lockout_duration,omitempty" structs:"lockout_duration" mapstructure:"lockout_duration"`
+	LockoutCounterReset time.Duration `json:"lockout_counter_reset,omitempty" structs:"lockout_counter_reset" mapstructure:"lockout_counter_reset"`
+	LockoutDisable      bool          `json:"lockout_disable,omitempty" structs:"lockout_disable" mapstructure:"lockout_disable"`
+
+	// The type of the tokens issued by the logins to auth mounts, service
+	// tokens if unset
+	TokenType string `json:"token_type,omitempty" structs:"token_type" mapstructure:"token_type"`
+
+	// The keys of the request and response data logged without hashing by
+	// the audit backends
+	AuditNonHMACRequestKeys  []string `json:"audit_non_hmac_request_keys,omitempty" structs:"audit_non_hmac_request_keys" mapstructure:"audit_non_hmac_request_keys"`
+	AuditNonHMACResponseKeys []string `json:"audit_non_hmac_response_keys,omitempty" structs:"audit_non_hmac_response_keys" mapstructure:"audit_non_hmac_response_keys"`
 }
 
 // Returns a deep copy of the mount entry
@@ -164,6 +181,7 @@ func (e *MountEntry) Clone() *MountEntry {
 		Config:      e.Config,
 		Options:     optClone,
 		Local:       e.Local,
+		SealWrap:    e.SealWrap,
 		Tainted:     e.Tainted,
 	}
 }
@@ -216,6 +234,9 @@ func (c *Core) mount(entry *MountEntry) error {
 	if backend == nil {
 		return fmt.Errorf("nil backend of type %q returned from creation function", entry.Type)
 	}
+	if entry.SealWrap {
+		c.setupSealWrap(view, backend)
+	}
 
 	// Call initialize; this takes care of init tasks that must be run after
 	// the ignore paths are collected
@@ -574,6 +595,9 @@ func (c *Core) persistMounts(table *MountTable, localOnly bool) error {
 		}
 	}
 
+	// The tables are written together, so that they are consistent with
+	// each other if the physical backend supports transactions
+	var txns []TxnEntry
 	if !localOnly {
 		// Encode the mount table into JSON and compress it (lzw).
 		compressedBytes, err := jsonutil.EncodeJSONAndCompress(nonLocalMounts, nil)
@@ -582,17 +606,13 @@ func (c *Core) persistMounts(table *MountTable, localOnly bool) error {
 			return err
 		}
 
-		// Create an entry
-		entry := &Entry{
-			Key:   coreMountConfigPath,
-			Value: compressedBytes,
-		}
-
-		// Write to the physical backend
-		if err := c.barrier.Put(entry); err != nil {
-			c.logger.Error("core: failed to persist mount table", "error", err)
-			return err
-		}
+		txns = append(txns, TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &Entry{
+				Key:   coreMountConfigPath,
+				Value: compressedBytes,
+			},
+		})
 	}
 
 	// Repeat with local mounts
@@ -602,13 +622,17 @@ func (c *Core) persistMounts(table *MountTable, localOnly bool) error {
 		return err
 	}
 
-	entry := &Entry{
-		Key:   coreLocalMountConfigPath,
-		Value: compressedBytes,
-	}
+	txns = append(txns, TxnEntry{
+		Operation: physical.PutOperation,
+		Entry: &Entry{
+			Key:   coreLocalMountConfigPath,
+			Value: compressedBytes,
+		},
+	})
 
-	if err := c.barrier.Put(entry); err != nil {
-		c.logger.Error("core: failed to persist local mount table", "error", err)
+	// Write to the physical backend
+	if err := c.barrier.Transaction(txns); err != nil {
+		c.logger.Error("core: failed to persist mount table", "error", err)
 		return err
 	}
 
@@ -645,6 +669,9 @@ func (c *Core) setupMounts() error {
 		if backend == nil {
 			return fmt.Errorf("created mount entry of type %q is nil", entry.Type)
 		}
+		if entry.SealWrap {
+			c.setupSealWrap(view, backend)
+		}
 
 		if err := backend.Initialize(); err != nil {
 			return err
@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/helper/random"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// passwordPolicySubPath is the sub-path used for the password policies
+	// view. This is nested under the system view.
+	passwordPolicySubPath = "password_policy/"
+)
+
+// passwordPolicyEntry is the storage entry of a password policy
+type passwordPolicyEntry struct {
+	Policy string `json:"policy"`
+}
+
+// passwordPolicyView returns the view of the password policies
+func (c *Core) passwordPolicyView() *BarrierView {
+	return c.systemBarrierView.SubView(passwordPolicySubPath)
+}
+
+// passwordPolicy returns the named password policy, or nil if it doesn't
+// exist
+func (c *Core) passwordPolicy(name string) (*random.PasswordPolicy, error) {
+	entry, err := c.passwordPolicyView().Get(strings.ToLower(name))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var policyEntry passwordPolicyEntry
+	if err := entry.DecodeJSON(&policyEntry); err != nil {
+		return nil, err
+	}
+
+	return random.ParsePolicy(policyEntry.Policy)
+}
+
+// setPasswordPolicy validates and stores the named password policy
+func (c *Core) setPasswordPolicy(name, raw string) error {
+	if _, err := random.ParsePolicy(raw); err != nil {
+		return err
+	}
+
+	entry, err := logical.StorageEntryJSON(strings.ToLower(name), &passwordPolicyEntry{
+		Policy: raw,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.passwordPolicyView().Put(entry)
+}
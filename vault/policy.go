@@ -3,6 +3,7 @@ package vault
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -28,6 +29,10 @@ const (
 	OldReadPathPolicy  = "read"
 	OldWritePathPolicy = "write"
 	OldSudoPathPolicy  = "sudo"
+
+	// The parameters of the templated policy paths
+	templateDisplayName = "token.display_name"
+	templateMetaPrefix  = "token.meta."
 )
 
 const (
@@ -50,6 +55,10 @@ var (
 		ListCapability:   ListCapabilityInt,
 		SudoCapability:   SudoCapabilityInt,
 	}
+
+	// templateRegex matches the parameters of the templated policy paths,
+	// such as "{{token.display_name}}"
+	templateRegex = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
 )
 
 // Policy is used to represent the policy specified by
@@ -58,6 +67,9 @@ type Policy struct {
 	Name  string              `hcl:"name"`
 	Paths []*PathCapabilities `hcl:"-"`
 	Raw   string
+
+	// Templated is set if any of the paths is templated
+	Templated bool `hcl:"-"`
 }
 
 // PathCapabilities represents a policy for a path in the namespace.
@@ -68,12 +80,28 @@ type PathCapabilities struct {
 	Glob         bool
 	Capabilities []string
 
+	// Templated is set if the prefix contains parameters resolved against
+	// the token when the ACL is built
+	Templated bool `hcl:"-"`
+
+	// HasSegmentWildcards is set if the prefix has "+" segments, matching
+	// any single path segment
+	HasSegmentWildcards bool `hcl:"-"`
+
 	// These keys are used at the top level to make the HCL nicer; we store in
 	// the Permissions object though
 	MinWrappingTTLHCL    interface{}              `hcl:"min_wrapping_ttl"`
 	MaxWrappingTTLHCL    interface{}              `hcl:"max_wrapping_ttl"`
 	AllowedParametersHCL map[string][]interface{} `hcl:"allowed_parameters"`
 	DeniedParametersHCL  map[string][]interface{} `hcl:"denied_parameters"`
+	ControlGroupHCL      *ControlGroupHCL         `hcl:"control_group"`
+}
+
+// ControlGroupHCL is the control group of a path as written in the policy
+type ControlGroupHCL struct {
+	Approvals        int         `hcl:"approvals"`
+	ApproverPolicies []string    `hcl:"approver_policies"`
+	TTL              interface{} `hcl:"ttl"`
 }
 
 type Permissions struct {
@@ -82,6 +110,16 @@ type Permissions struct {
 	MaxWrappingTTL     time.Duration
 	AllowedParameters  map[string][]interface{}
 	DeniedParameters   map[string][]interface{}
+	ControlGroup       *ControlGroup
+}
+
+// ControlGroup holds the requests on a path until they are authorized by
+// Approvals tokens having one of the approver policies. The held requests
+// expire after TTL.
+type ControlGroup struct {
+	Approvals        int
+	ApproverPolicies []string
+	TTL              time.Duration
 }
 
 // Parse is used to parse the specified ACL rules into an
@@ -139,6 +177,7 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			"denied_parameters",
 			"min_wrapping_ttl",
 			"max_wrapping_ttl",
+			"control_group",
 		}
 		if err := checkHCLKeys(item.Val, valid); err != nil {
 			return multierror.Prefix(err, fmt.Sprintf("path %q:", key))
@@ -165,6 +204,17 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			pc.Glob = true
 		}
 
+		pc.HasSegmentWildcards = hasSegmentWildcards(pc.Prefix)
+
+		// Check the parameters of templated paths
+		for _, match := range templateRegex.FindAllStringSubmatch(pc.Prefix, -1) {
+			if !validTemplateParameter(match[1]) {
+				return fmt.Errorf("path %q: unsupported template parameter %q", key, match[1])
+			}
+			pc.Templated = true
+			result.Templated = true
+		}
+
 		// Map old-style policies into capabilities
 		if len(pc.Policy) > 0 {
 			switch pc.Policy {
@@ -229,6 +279,13 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			pc.Permissions.MaxWrappingTTL < pc.Permissions.MinWrappingTTL {
 			return errors.New("max_wrapping_ttl cannot be less than min_wrapping_ttl")
 		}
+		if pc.ControlGroupHCL != nil {
+			cg, err := parseControlGroup(pc.ControlGroupHCL)
+			if err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("path %q:", key))
+			}
+			pc.Permissions.ControlGroup = cg
+		}
 
 	PathFinished:
 		paths = append(paths, &pc)
@@ -238,6 +295,118 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 	return nil
 }
 
+// parseControlGroup validates the control group of a path
+func parseControlGroup(cgHCL *ControlGroupHCL) (*ControlGroup, error) {
+	cg := &ControlGroup{
+		Approvals: cgHCL.Approvals,
+		TTL:       defaultControlGroupTTL,
+	}
+	for _, policy := range cgHCL.ApproverPolicies {
+		cg.ApproverPolicies = append(cg.ApproverPolicies, strings.ToLower(strings.TrimSpace(policy)))
+	}
+	if cgHCL.TTL != nil {
+		dur, err := parseutil.ParseDurationSecond(cgHCL.TTL)
+		if err != nil {
+			return nil, errwrap.Wrapf("error parsing control group ttl: {{err}}", err)
+		}
+		cg.TTL = dur
+	}
+
+	switch {
+	case cg.Approvals < 1:
+		return nil, errors.New("control group approvals must be at least 1")
+	case len(cg.ApproverPolicies) == 0:
+		return nil, errors.New("control group approver_policies cannot be empty")
+	case cg.TTL <= 0:
+		return nil, errors.New("control group ttl must be positive")
+	}
+	return cg, nil
+}
+
+// hasSegmentWildcards returns whether the path has "+" segments
+func hasSegmentWildcards(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "+" {
+			return true
+		}
+	}
+	return false
+}
+
+// validTemplateParameter returns whether the parameter of a templated path
+// is supported
+func validTemplateParameter(param string) bool {
+	switch {
+	case param == templateDisplayName:
+		return true
+	case strings.HasPrefix(param, templateMetaPrefix):
+		return len(param) > len(templateMetaPrefix)
+	default:
+		return false
+	}
+}
+
+// templateParameter returns the value of the parameter for the token, or an
+// empty string if the token does not have it
+func templateParameter(param string, te *TokenEntry) string {
+	switch {
+	case param == templateDisplayName:
+		return te.DisplayName
+	case strings.HasPrefix(param, templateMetaPrefix):
+		return te.Meta[strings.TrimPrefix(param, templateMetaPrefix)]
+	default:
+		return ""
+	}
+}
+
+// render returns the policy with its templated paths resolved against the
+// login token entry of the request, as returned by Core.loginTokenEntry. The
+// paths whose parameters the entry does not have are left out, as are all the
+// templated paths when there is no entry. The paths are copied, so that the
+// cached policy is left untouched.
+func (p *Policy) render(te *TokenEntry) *Policy {
+	if !p.Templated {
+		return p
+	}
+
+	rendered := &Policy{
+		Name:  p.Name,
+		Raw:   p.Raw,
+		Paths: make([]*PathCapabilities, 0, len(p.Paths)),
+	}
+	for _, pc := range p.Paths {
+		if !pc.Templated {
+			rendered.Paths = append(rendered.Paths, pc)
+			continue
+		}
+		if te == nil {
+			continue
+		}
+
+		// Values holding "/" or "+" are left out too, so that they cannot
+		// add segments to the path or widen it
+		missing := false
+		prefix := templateRegex.ReplaceAllStringFunc(pc.Prefix, func(match string) string {
+			value := templateParameter(templateRegex.FindStringSubmatch(match)[1], te)
+			if value == "" || value == "+" || strings.Contains(value, "/") {
+				missing = true
+			}
+			return value
+		})
+		if missing {
+			continue
+		}
+
+		copied := *pc
+		copied.Prefix = prefix
+		copied.Templated = false
+		permissions := *pc.Permissions
+		copied.Permissions = &permissions
+		rendered.Paths = append(rendered.Paths, &copied)
+	}
+	return rendered
+}
+
 func checkHCLKeys(node ast.Node, valid []string) error {
 	var list *ast.ObjectList
 	switch n := node.(type) {
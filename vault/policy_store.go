@@ -87,6 +87,11 @@ path "sys/wrapping/lookup" {
 path "sys/wrapping/unwrap" {
     capabilities = ["update"]
 }
+
+# Allow a token to check the state of a request held by a control group
+path "sys/control-group/request" {
+    capabilities = ["update"]
+}
 `
 )
 
@@ -332,6 +337,12 @@ func (ps *PolicyStore) DeletePolicy(name string) error {
 // ACL is used to return an ACL which is built using the
 // named policies.
 func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
+	return ps.TokenACL(nil, names...)
+}
+
+// TokenACL is used to return an ACL which is built using the named policies,
+// with their templated paths resolved against the login token entry
+func (ps *PolicyStore) TokenACL(te *TokenEntry, names ...string) (*ACL, error) {
 	// Fetch the policies
 	var policy []*Policy
 	for _, name := range names {
@@ -339,6 +350,9 @@ func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to get policy '%s': %v", name, err)
 		}
+		if p != nil {
+			p = p.render(te)
+		}
 		policy = append(policy, p)
 	}
 
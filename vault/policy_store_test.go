@@ -216,3 +216,41 @@ func TestPolicyStore_v1Upgrade(t *testing.T) {
 		t.Fatalf("should enable glob")
 	}
 }
+
+func TestPolicyStore_TokenACL(t *testing.T) {
+	ps := mockPolicyStore(t)
+
+	policy, _ := Parse(`
+name = "users"
+path "secret/{{token.meta.username}}/*" {
+	capabilities = ["read"]
+}
+`)
+	if err := ps.SetPolicy(policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	te := &TokenEntry{
+		Path: "auth/userpass/login/alice",
+		Meta: map[string]string{"username": "alice"},
+	}
+	acl, err := ps.TokenACL(te, "users")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if caps := acl.Capabilities("secret/alice/foo"); !reflect.DeepEqual(caps, []string{ReadCapability}) {
+		t.Fatalf("bad capabilities: %v", caps)
+	}
+	if caps := acl.Capabilities("secret/bob/foo"); !reflect.DeepEqual(caps, []string{DenyCapability}) {
+		t.Fatalf("bad capabilities: %v", caps)
+	}
+
+	// The template is not resolved without a token
+	acl, err = ps.ACL("users")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if caps := acl.Capabilities("secret/alice/foo"); !reflect.DeepEqual(caps, []string{DenyCapability}) {
+		t.Fatalf("bad capabilities: %v", caps)
+	}
+}
@@ -312,3 +312,134 @@ path "/" {
 		t.Errorf("bad error: %s", err)
 	}
 }
+
+func TestPolicy_ParseBadTemplate(t *testing.T) {
+	_, err := Parse(strings.TrimSpace(`
+path "secret/{{identity.entity.name}}/*" {
+	capabilities = ["read"]
+}
+`))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !strings.Contains(err.Error(), `unsupported template parameter "identity.entity.name"`) {
+		t.Errorf("bad error: %s", err)
+	}
+}
+
+func TestPolicy_ParseControlGroup(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "pki/root/sign-intermediate" {
+	capabilities = ["update"]
+	control_group {
+		approvals = 2
+		approver_policies = ["Security", "ops"]
+		ttl = "1h"
+	}
+}
+path "secret/foo" {
+	capabilities = ["read"]
+	control_group {
+		approvals = 1
+		approver_policies = ["security"]
+	}
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := &ControlGroup{
+		Approvals:        2,
+		ApproverPolicies: []string{"security", "ops"},
+		TTL:              time.Hour,
+	}
+	if !reflect.DeepEqual(p.Paths[0].Permissions.ControlGroup, expected) {
+		t.Fatalf("bad: %#v", p.Paths[0].Permissions.ControlGroup)
+	}
+	if p.Paths[1].Permissions.ControlGroup.TTL != defaultControlGroupTTL {
+		t.Fatalf("bad: %#v", p.Paths[1].Permissions.ControlGroup)
+	}
+
+	for _, bad := range []string{
+		`control_group { approver_policies = ["security"] }`,
+		`control_group { approvals = 1 }`,
+		`control_group {
+			approvals = 1
+			approver_policies = ["security"]
+			ttl = "-1h"
+		}`,
+	} {
+		_, err := Parse(`path "secret/foo" {
+	capabilities = ["read"]
+	` + bad + `
+}`)
+		if err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestPolicy_Render(t *testing.T) {
+	p, err := Parse(strings.TrimSpace(`
+path "secret/{{token.meta.username}}/*" {
+	capabilities = ["read"]
+}
+path "secret/{{ token.display_name }}" {
+	capabilities = ["read"]
+}
+path "secret/shared" {
+	capabilities = ["read"]
+}
+`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !p.Templated || !p.Paths[0].Templated || p.Paths[2].Templated {
+		t.Fatalf("bad templated paths: %#v", p.Paths)
+	}
+
+	te := &TokenEntry{
+		Path:        "auth/userpass/login/alice",
+		DisplayName: "userpass-alice",
+		Meta:        map[string]string{"username": "alice"},
+	}
+	rendered := p.render(te)
+	var prefixes []string
+	for _, pc := range rendered.Paths {
+		prefixes = append(prefixes, pc.Prefix)
+	}
+	expected := []string{"secret/alice/", "secret/userpass-alice", "secret/shared"}
+	if !reflect.DeepEqual(prefixes, expected) {
+		t.Fatalf("bad prefixes: %v", prefixes)
+	}
+	if p.Paths[0].Prefix != "secret/{{token.meta.username}}/" {
+		t.Fatalf("cached policy modified: %s", p.Paths[0].Prefix)
+	}
+	if rendered.Paths[0].Permissions == p.Paths[0].Permissions {
+		t.Fatalf("permissions not copied")
+	}
+
+	// Paths with missing parameters are left out
+	te.Meta = nil
+	if rendered := p.render(te); len(rendered.Paths) != 2 {
+		t.Fatalf("bad paths: %#v", rendered.Paths)
+	}
+
+	// So are the values that would add segments to the path or widen it
+	for _, value := range []string{"alice/bob", "../bob", "+"} {
+		te.Meta = map[string]string{"username": value}
+		te.DisplayName = value
+		if rendered := p.render(te); len(rendered.Paths) != 1 || rendered.Paths[0].Prefix != "secret/shared" {
+			t.Fatalf("value %q: bad paths: %#v", value, rendered.Paths)
+		}
+	}
+	te.DisplayName = "userpass-alice"
+
+	// Without a login token entry, none of the templated paths is kept
+	rendered = p.render(nil)
+	if len(rendered.Paths) != 1 || rendered.Paths[0].Prefix != "secret/shared" {
+		t.Fatalf("bad paths: %#v", rendered.Paths)
+	}
+}
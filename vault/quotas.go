@@ -0,0 +1,366 @@
+package vault
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// quotasSubPath is the sub-path used for the quotas view. This is nested
+	// under the system view.
+	quotasSubPath = "quotas/"
+
+	// The types of the quotas, which are also the prefixes of their storage
+	// entries
+	quotaTypeRateLimit  = "rate-limit"
+	quotaTypeLeaseCount = "lease-count"
+
+	// defaultRateLimitInterval is the interval of the rate limit quotas
+	// which do not set one
+	defaultRateLimitInterval = time.Second
+
+	// rateLimitPurgeInterval is how often the idle clients of the rate limit
+	// quotas are forgotten
+	rateLimitPurgeInterval = time.Minute
+)
+
+// quotaExemptPaths are the prefixes of the paths which are not subject to
+// the quotas, so that misconfigured quotas can always be fixed
+var quotaExemptPaths = []string{
+	"sys/quotas/",
+}
+
+// rateLimitQuotaEntry is the storage entry of a rate limit quota. Each
+// client may make Rate requests per Interval to the paths of the quota, and
+// up to Burst requests at once. Clients exceeding the quota are rejected for
+// BlockInterval if it is set.
+type rateLimitQuotaEntry struct {
+	Path          string        `json:"path"`
+	Rate          float64       `json:"rate"`
+	Burst         int           `json:"burst"`
+	Interval      time.Duration `json:"interval"`
+	BlockInterval time.Duration `json:"block_interval"`
+}
+
+// leaseCountQuotaEntry is the storage entry of a lease count quota. The
+// requests which may create leases under its path are rejected once there
+// are MaxLeases leases.
+type leaseCountQuotaEntry struct {
+	Path      string `json:"path"`
+	MaxLeases int    `json:"max_leases"`
+}
+
+// rateLimitBucket tracks the requests of a client against a rate limit quota
+type rateLimitBucket struct {
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// rateLimitQuota is a rate limit quota along with the requests of its
+// clients
+type rateLimitQuota struct {
+	*rateLimitQuotaEntry
+
+	l         sync.Mutex
+	clients   map[string]*rateLimitBucket
+	lastPurge time.Time
+}
+
+func newRateLimitQuota(entry *rateLimitQuotaEntry) *rateLimitQuota {
+	return &rateLimitQuota{
+		rateLimitQuotaEntry: entry,
+		clients:             make(map[string]*rateLimitBucket),
+		lastPurge:           time.Now(),
+	}
+}
+
+// allow records a request of the client, and returns whether it is within
+// the quota
+func (q *rateLimitQuota) allow(client string, now time.Time) bool {
+	q.l.Lock()
+	defer q.l.Unlock()
+
+	// The buckets of the clients which are not blocked and would be full
+	// again are the same as new ones
+	refill := time.Duration(float64(q.Burst) / q.Rate * float64(q.Interval))
+	if now.Sub(q.lastPurge) >= rateLimitPurgeInterval {
+		for c, b := range q.clients {
+			if now.After(b.blockedUntil) && now.Sub(b.last) >= refill {
+				delete(q.clients, c)
+			}
+		}
+		q.lastPurge = now
+	}
+
+	b, ok := q.clients[client]
+	if !ok {
+		b = &rateLimitBucket{
+			tokens: float64(q.Burst),
+			last:   now,
+		}
+		q.clients[client] = b
+	}
+	if now.Before(b.blockedUntil) {
+		return false
+	}
+
+	elapsed := now.Sub(b.last)
+	b.tokens = math.Min(float64(q.Burst), b.tokens+q.Rate*float64(elapsed)/float64(q.Interval))
+	b.last = now
+
+	if b.tokens < 1 {
+		if q.BlockInterval > 0 {
+			b.blockedUntil = now.Add(q.BlockInterval)
+		}
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// quotaManager holds the quotas, which are loaded in memory on unseal
+type quotaManager struct {
+	l sync.RWMutex
+
+	view        *BarrierView
+	rateLimits  map[string]*rateLimitQuota
+	leaseCounts map[string]*leaseCountQuotaEntry
+
+	// expiration counts the pending leases under the paths of the lease
+	// count quotas
+	expiration *ExpirationManager
+}
+
+// setupQuotas is invoked after we've loaded the mount table to load the
+// quotas
+func (c *Core) setupQuotas() error {
+	m := &quotaManager{
+		view:        c.systemBarrierView.SubView(quotasSubPath),
+		rateLimits:  make(map[string]*rateLimitQuota),
+		leaseCounts: make(map[string]*leaseCountQuotaEntry),
+		expiration:  c.expiration,
+	}
+
+	names, err := m.view.List(quotaTypeRateLimit + "/")
+	if err != nil {
+		return fmt.Errorf("failed to list rate limit quotas: %v", err)
+	}
+	for _, name := range names {
+		var entry rateLimitQuotaEntry
+		if err := m.load(quotaTypeRateLimit, name, &entry); err != nil {
+			return err
+		}
+		m.rateLimits[name] = newRateLimitQuota(&entry)
+	}
+
+	names, err = m.view.List(quotaTypeLeaseCount + "/")
+	if err != nil {
+		return fmt.Errorf("failed to list lease count quotas: %v", err)
+	}
+	for _, name := range names {
+		var entry leaseCountQuotaEntry
+		if err := m.load(quotaTypeLeaseCount, name, &entry); err != nil {
+			return err
+		}
+		m.leaseCounts[name] = &entry
+	}
+	m.updateLeaseCountPrefixes()
+
+	c.quotas = m
+	return nil
+}
+
+// teardownQuotas is used to forget the quotas before sealing the Vault
+func (c *Core) teardownQuotas() error {
+	c.quotas = nil
+	return nil
+}
+
+// load decodes the stored quota of the type into out
+func (m *quotaManager) load(quotaType, name string, out interface{}) error {
+	raw, err := m.view.Get(quotaType + "/" + name)
+	if err != nil {
+		return fmt.Errorf("failed to read %s quota %q: %v", quotaType, name, err)
+	}
+	if raw == nil {
+		return fmt.Errorf("%s quota %q not found", quotaType, name)
+	}
+	if err := raw.DecodeJSON(out); err != nil {
+		return fmt.Errorf("failed to decode %s quota %q: %v", quotaType, name, err)
+	}
+	return nil
+}
+
+// setRateLimit persists the named rate limit quota. The requests counted
+// against its previous configuration are forgotten.
+func (m *quotaManager) setRateLimit(name string, entry *rateLimitQuotaEntry) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	se, err := logical.StorageEntryJSON(quotaTypeRateLimit+"/"+name, entry)
+	if err != nil {
+		return err
+	}
+	if err := m.view.Put(se); err != nil {
+		return err
+	}
+	m.rateLimits[name] = newRateLimitQuota(entry)
+	return nil
+}
+
+// setLeaseCount persists the named lease count quota
+func (m *quotaManager) setLeaseCount(name string, entry *leaseCountQuotaEntry) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	se, err := logical.StorageEntryJSON(quotaTypeLeaseCount+"/"+name, entry)
+	if err != nil {
+		return err
+	}
+	if err := m.view.Put(se); err != nil {
+		return err
+	}
+	m.leaseCounts[name] = entry
+	m.updateLeaseCountPrefixes()
+	return nil
+}
+
+// delete removes the named quota of the type
+func (m *quotaManager) delete(quotaType, name string) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if err := m.view.Delete(quotaType + "/" + name); err != nil {
+		return err
+	}
+	switch quotaType {
+	case quotaTypeRateLimit:
+		delete(m.rateLimits, name)
+	case quotaTypeLeaseCount:
+		delete(m.leaseCounts, name)
+		m.updateLeaseCountPrefixes()
+	}
+	return nil
+}
+
+// updateLeaseCountPrefixes has the expiration manager count the pending
+// leases under the paths of the lease count quotas. The caller must hold the
+// lock.
+func (m *quotaManager) updateLeaseCountPrefixes() {
+	if m.expiration == nil {
+		return
+	}
+	prefixes := make([]string, 0, len(m.leaseCounts))
+	for _, q := range m.leaseCounts {
+		prefixes = append(prefixes, q.Path)
+	}
+	m.expiration.setLeaseCountPrefixes(prefixes)
+}
+
+// rateLimit returns the named rate limit quota, or nil if it doesn't exist
+func (m *quotaManager) rateLimit(name string) *rateLimitQuotaEntry {
+	m.l.RLock()
+	defer m.l.RUnlock()
+
+	if q, ok := m.rateLimits[name]; ok {
+		return q.rateLimitQuotaEntry
+	}
+	return nil
+}
+
+// leaseCount returns the named lease count quota, or nil if it doesn't exist
+func (m *quotaManager) leaseCount(name string) *leaseCountQuotaEntry {
+	m.l.RLock()
+	defer m.l.RUnlock()
+
+	return m.leaseCounts[name]
+}
+
+// matchingRateLimit returns the rate limit quota with the longest path
+// matching the request path, or nil if there is none
+func (m *quotaManager) matchingRateLimit(path string) *rateLimitQuota {
+	m.l.RLock()
+	defer m.l.RUnlock()
+
+	var match *rateLimitQuota
+	for _, q := range m.rateLimits {
+		if strings.HasPrefix(path, q.Path) && (match == nil || len(q.Path) > len(match.Path)) {
+			match = q
+		}
+	}
+	return match
+}
+
+// matchingLeaseCount returns the lease count quota with the longest path
+// matching the request path, or nil if there is none
+func (m *quotaManager) matchingLeaseCount(path string) *leaseCountQuotaEntry {
+	m.l.RLock()
+	defer m.l.RUnlock()
+
+	var match *leaseCountQuotaEntry
+	for _, q := range m.leaseCounts {
+		if strings.HasPrefix(path, q.Path) && (match == nil || len(q.Path) > len(match.Path)) {
+			match = q
+		}
+	}
+	return match
+}
+
+// quotaPath validates the path of a quota, which is empty for the quotas
+// applying to every request, or a mount path optionally followed by a path
+// within the mount
+func (c *Core) quotaPath(path string) (string, error) {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return "", nil
+	}
+	if !strings.HasSuffix(path, "/") && c.router.MatchingMount(path+"/") == path+"/" {
+		path += "/"
+	}
+	if c.router.MatchingMount(path) == "" {
+		return "", fmt.Errorf("no mount matches path %q", path)
+	}
+	return path, nil
+}
+
+// applyQuotas checks the request against the rate limit and lease count
+// quotas matching its path
+func (c *Core) applyQuotas(req *logical.Request) error {
+	if c.quotas == nil {
+		return nil
+	}
+	for _, prefix := range quotaExemptPaths {
+		if strings.HasPrefix(req.Path, prefix) {
+			return nil
+		}
+	}
+
+	if q := c.quotas.matchingRateLimit(req.Path); q != nil {
+		var client string
+		if req.Connection != nil {
+			client = req.Connection.RemoteAddr
+		}
+		if !q.allow(client, time.Now()) {
+			return logical.ErrRateLimitQuotaExceeded
+		}
+	}
+
+	// Only the logins and the reads and writes may create leases
+	switch req.Operation {
+	case logical.ReadOperation, logical.UpdateOperation, logical.CreateOperation:
+	default:
+		return nil
+	}
+	if q := c.quotas.matchingLeaseCount(req.Path); q != nil && c.expiration != nil {
+		if c.expiration.leaseCount(q.Path) >= q.MaxLeases {
+			return logical.ErrLeaseCountQuotaExceeded
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestRateLimitQuota_Allow(t *testing.T) {
+	q := newRateLimitQuota(&rateLimitQuotaEntry{
+		Rate:          2,
+		Burst:         2,
+		Interval:      time.Second,
+		BlockInterval: 10 * time.Second,
+	})
+	now := time.Now()
+
+	if !q.allow("a", now) || !q.allow("a", now) {
+		t.Fatalf("burst rejected")
+	}
+	if q.allow("a", now) {
+		t.Fatalf("request past the burst allowed")
+	}
+
+	// Clients are limited separately
+	if !q.allow("b", now) {
+		t.Fatalf("other client rejected")
+	}
+
+	// The blocked client is rejected even once its bucket refills
+	if q.allow("a", now.Add(5*time.Second)) {
+		t.Fatalf("blocked client allowed")
+	}
+	if !q.allow("a", now.Add(11*time.Second)) {
+		t.Fatalf("client rejected after the block interval")
+	}
+
+	// Without a block interval the bucket refills at the rate
+	q = newRateLimitQuota(&rateLimitQuotaEntry{
+		Rate:     2,
+		Burst:    1,
+		Interval: time.Second,
+	})
+	if !q.allow("a", now) || q.allow("a", now) {
+		t.Fatalf("bad burst")
+	}
+	if !q.allow("a", now.Add(500*time.Millisecond)) {
+		t.Fatalf("client rejected after refill")
+	}
+}
+
+func TestCore_RateLimitQuota(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/test")
+	req.ClientToken = root
+	req.Data["path"] = "nope"
+	req.Data["rate"] = 1
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatalf("expected error for unknown mount")
+	}
+
+	req.Data["path"] = "secret"
+	req.Data["burst"] = 2
+	req.Data["block_interval"] = "1h"
+	if resp, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req.Operation = logical.ReadOperation
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["path"] != "secret/" || resp.Data["rate"] != float64(1) || resp.Data["burst"] != 2 ||
+		resp.Data["interval"] != int64(1) || resp.Data["block_interval"] != int64(3600) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	read := func(path string) error {
+		_, err := c.HandleRequest(&logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        path,
+			ClientToken: root,
+			Connection:  &logical.Connection{RemoteAddr: "127.0.0.1"},
+		})
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := read("secret/foo"); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := read("secret/foo"); err == nil || !errwrap.Contains(err, logical.ErrRateLimitQuotaExceeded.Error()) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+
+	// Other paths are not limited
+	if err := read("cubbyhole/foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The quota can be removed while it is exceeded
+	req.Operation = logical.DeleteOperation
+	if resp, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if err := read("secret/foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_LeaseCountQuota(t *testing.T) {
+	noop := &NoopBackend{
+		Response: &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: time.Hour,
+				},
+			},
+		},
+	}
+	c, _, root := TestCoreUnsealed(t)
+	c.logicalBackends["noop"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/foo")
+	req.Data["type"] = "noop"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/lease-count/test")
+	req.ClientToken = root
+	req.Data["path"] = "foo/"
+	req.Data["max_leases"] = 2
+	if resp, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	read := func() (*logical.Response, error) {
+		return c.HandleRequest(&logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "foo/test",
+			ClientToken: root,
+		})
+	}
+	var leaseID string
+	for i := 0; i < 2; i++ {
+		resp, err := read()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		leaseID = resp.Secret.LeaseID
+	}
+	if _, err := read(); err == nil || !errwrap.Contains(err, logical.ErrLeaseCountQuotaExceeded.Error()) {
+		t.Fatalf("expected lease count error, got %v", err)
+	}
+
+	req.Operation = logical.ReadOperation
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["path"] != "foo/" || resp.Data["max_leases"] != 2 || resp.Data["counter"] != 2 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Revoking a lease makes room for a new one
+	if err := c.expiration.Revoke(leaseID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := read(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The quotas are reloaded on unseal
+	if err := c.setupQuotas(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if q := c.quotas.leaseCount("test"); q == nil || q.MaxLeases != 2 {
+		t.Fatalf("bad: %#v", q)
+	}
+}
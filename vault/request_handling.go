@@ -37,6 +37,17 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 		return logical.ErrorResponse("cannot write to a path ending in '/'"), nil
 	}
 
+	// Reject the requests exceeding the quotas before they reach a backend
+	if err := c.applyQuotas(req); err != nil {
+		return nil, err
+	}
+
+	// Attach the audit configuration of the mount handling the request
+	if entry := c.router.MatchingMountEntry(req.Path); entry != nil {
+		req.AuditNonHMACRequestKeys = entry.Config.AuditNonHMACRequestKeys
+		req.AuditNonHMACResponseKeys = entry.Config.AuditNonHMACResponseKeys
+	}
+
 	var auth *logical.Auth
 	if c.router.LoginPath(req.Path) {
 		resp, auth, err = c.handleLoginRequest(req)
@@ -57,12 +68,14 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 	// We are wrapping if there is anything to wrap (not a nil response) and a
 	// TTL was specified for the token. Errors on a call should be returned to
 	// the caller, so wrapping is turned off if an error is hit and the error
-	// is logged to the audit log.
+	// is logged to the audit log. The responses of the requests held by
+	// control groups already carry their token.
 	wrapping := resp != nil &&
 		err == nil &&
 		!resp.IsError() &&
 		resp.WrapInfo != nil &&
-		resp.WrapInfo.TTL != 0
+		resp.WrapInfo.TTL != 0 &&
+		resp.WrapInfo.Token == ""
 
 	if wrapping {
 		cubbyResp, cubbyErr := c.wrapInCubbyhole(req, resp)
@@ -115,7 +128,7 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 	defer metrics.MeasureSince([]string{"core", "handle_request"}, time.Now())
 
 	// Validate the token
-	auth, te, ctErr := c.checkToken(req)
+	auth, te, controlGroup, ctErr := c.checkToken(req)
 	// We run this logic first because we want to decrement the use count even in the case of an error
 	if te != nil {
 		// Attempt to use the token (decrement NumUses)
@@ -173,8 +186,22 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 		return logical.ErrorResponse(ctErr.Error()), nil, retErr
 	}
 
-	// Attach the display name
+	// Count the token as an active client of its auth mount
+	c.recordToken(te)
+
+	// Attach the display name, and the identity of the user the token was
+	// issued to at login
 	req.DisplayName = auth.DisplayName
+	loginEntry, err := c.loginTokenEntry(te)
+	if err != nil {
+		c.logger.Error("core: failed to look up login token", "error", err)
+		retErr = multierror.Append(retErr, ErrInternalError)
+		return nil, auth, retErr
+	}
+	if loginEntry != nil {
+		req.LoginDisplayName = loginEntry.DisplayName
+		req.ClientTokenMeta = loginEntry.Meta
+	}
 
 	// Create an audit trail of the request
 	if err := c.auditBroker.LogRequest(auth, req, c.auditedHeaders, nil); err != nil {
@@ -183,19 +210,41 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 		return nil, auth, retErr
 	}
 
+	// Batch tokens have no cubbyhole, since it could not be destroyed with
+	// them
+	if te.Type == TokenTypeBatch && strings.HasPrefix(req.Path, "cubbyhole/") {
+		retErr = multierror.Append(retErr, logical.ErrInvalidRequest)
+		return logical.ErrorResponse("batch tokens have no cubbyhole"), auth, retErr
+	}
+
+	// Requests on paths under control groups are held until authorized,
+	// then replayed on behalf of their requester
+	if controlGroup != nil && req.ControlGroupAccessor == "" {
+		resp, err := c.holdControlGroupRequest(req, te, controlGroup)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+			return resp, auth, retErr
+		}
+		return resp, auth, nil
+	}
+
 	// Route the request
 	resp, routeErr := c.router.Route(req)
 	if resp != nil {
 		// If wrapping is used, use the shortest between the request and response
 		var wrapTTL time.Duration
-		var wrapFormat string
+		var wrapFormat, creationPath string
 
-		// Ensure no wrap info information is set other than, possibly, the TTL
+		// Ensure no wrap info information is set other than, possibly, the
+		// TTL and the creation path kept by rewrapping
 		if resp.WrapInfo != nil {
 			if resp.WrapInfo.TTL > 0 {
 				wrapTTL = resp.WrapInfo.TTL
 			}
 			wrapFormat = resp.WrapInfo.Format
+			if req.Path == "sys/wrapping/rewrap" {
+				creationPath = resp.WrapInfo.CreationPath
+			}
 			resp.WrapInfo = nil
 		}
 
@@ -217,8 +266,9 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 
 		if wrapTTL > 0 {
 			resp.WrapInfo = &logical.ResponseWrapInfo{
-				TTL:    wrapTTL,
-				Format: wrapFormat,
+				TTL:          wrapTTL,
+				Format:       wrapFormat,
+				CreationPath: creationPath,
 			}
 		}
 	}
@@ -245,6 +295,13 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 			resp.Secret.TTL = maxTTL
 		}
 
+		// The leases of batch tokens cannot outlive them
+		if te.Type == TokenTypeBatch {
+			if remaining := te.expireTime().Sub(time.Now()); resp.Secret.TTL > remaining {
+				resp.Secret.TTL = remaining
+			}
+		}
+
 		// Generic mounts should return the TTL but not register
 		// for a lease as this provides a massive slowdown
 		registerLease := true
@@ -291,10 +348,13 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 			return nil, nil, retErr
 		}
 
-		if err := c.expiration.RegisterAuth(te.Path, resp.Auth); err != nil {
-			c.logger.Error("core: failed to register token lease", "request_path", req.Path, "error", err)
-			retErr = multierror.Append(retErr, ErrInternalError)
-			return nil, auth, retErr
+		// Batch tokens have no lease, they expire at the end of their TTL
+		if te.Type != TokenTypeBatch {
+			if err := c.expiration.RegisterAuth(te.Path, resp.Auth); err != nil {
+				c.logger.Error("core: failed to register token lease", "request_path", req.Path, "error", err)
+				retErr = multierror.Append(retErr, ErrInternalError)
+				return nil, auth, retErr
+			}
 		}
 	}
 
@@ -330,8 +390,23 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 		return nil, nil, ErrInternalError
 	}
 
+	// Reject the logins of users locked out after too many failures
+	mountEntry := c.router.MatchingMountEntry(req.Path)
+	lockoutUsername := c.lockoutUsername(mountEntry, req.Path)
+	if lockoutUsername != "" && c.userLockouts.locked(mountEntry, lockoutUsername) {
+		c.logger.Warn("core: login attempt of locked out user", "request_path", req.Path)
+		return nil, nil, logical.ErrPermissionDenied
+	}
+
+	// The MFA credentials are read before routing, since the headers are
+	// not passed to the backends
+	mfaCreds := loginMFACredentials(req.Headers)
+
 	// Route the request
 	resp, routeErr := c.router.Route(req)
+	if lockoutUsername != "" && resp != nil && resp.IsError() {
+		c.userLockouts.failed(mountEntry, lockoutUsername)
+	}
 	if resp != nil {
 		// If wrapping is used, use the shortest between the request and response
 		var wrapTTL time.Duration
@@ -383,6 +458,19 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 			return logical.ErrorResponse("authentication backends cannot create root tokens"), nil, logical.ErrInvalidRequest
 		}
 
+		// Require the second factors of the MFA enforcements applying to
+		// the login
+		if err := c.enforceLoginMFA(c.router.MatchingMount(req.Path), auth.DisplayName, mfaCreds); err != nil {
+			c.logger.Warn("core: login MFA failed", "request_path", req.Path, "error", err)
+			if lockoutUsername != "" {
+				c.userLockouts.failed(mountEntry, lockoutUsername)
+			}
+			return logical.ErrorResponse(err.Error()), nil, logical.ErrPermissionDenied
+		}
+		if lockoutUsername != "" {
+			c.userLockouts.succeeded(mountEntry, lockoutUsername)
+		}
+
 		// Determine the source of the login
 		source := c.router.MatchingMount(req.Path)
 		source = strings.TrimPrefix(source, credentialRoutePrefix)
@@ -427,9 +515,18 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 			}
 		}
 
-		if err := c.tokenStore.create(&te); err != nil {
-			c.logger.Error("core: failed to create token", "error", err)
-			return nil, auth, ErrInternalError
+		// The auth mounts tuned to issue batch tokens do not persist them
+		if mountEntry != nil && mountEntry.Config.TokenType == TokenTypeBatch {
+			if err := c.tokenStore.createBatch(&te); err != nil {
+				c.logger.Error("core: failed to create batch token", "error", err)
+				return logical.ErrorResponse(err.Error()), nil, logical.ErrInvalidRequest
+			}
+			auth.Renewable = false
+		} else {
+			if err := c.tokenStore.create(&te); err != nil {
+				c.logger.Error("core: failed to create token", "error", err)
+				return nil, auth, ErrInternalError
+			}
 		}
 
 		// Populate the client token and accessor
@@ -437,10 +534,12 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 		auth.Accessor = te.Accessor
 		auth.Policies = te.Policies
 
-		// Register with the expiration manager
-		if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
-			c.logger.Error("core: failed to register token lease", "request_path", req.Path, "error", err)
-			return nil, auth, ErrInternalError
+		// Register with the expiration manager, batch tokens have no lease
+		if te.Type != TokenTypeBatch {
+			if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
+				c.logger.Error("core: failed to register token lease", "request_path", req.Path, "error", err)
+				return nil, auth, ErrInternalError
+			}
 		}
 
 		// Attach the display name, might be used by audit backends
@@ -449,3 +548,28 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, *log
 
 	return resp, auth, routeErr
 }
+
+// loginTokenEntry returns the entry of the token, or of the closest token it
+// was created from, that was issued by a credential backend at login. Tokens
+// created through the token store are skipped, since their display name and
+// metadata are chosen by their creator. Nil is returned if there is none.
+//
+// The entry is the identity of the user behind the request for the templated
+// policies, the control groups and the signing of SSH certificates.
+func (c *Core) loginTokenEntry(te *TokenEntry) (*TokenEntry, error) {
+	for te != nil {
+		if !strings.HasPrefix(te.Path, "auth/token/") &&
+			strings.HasPrefix(c.router.MatchingMount(te.Path), credentialRoutePrefix) {
+			return te, nil
+		}
+		if te.Parent == "" {
+			break
+		}
+		parent, err := c.tokenStore.Lookup(te.Parent)
+		if err != nil {
+			return nil, err
+		}
+		te = parent
+	}
+	return nil, nil
+}
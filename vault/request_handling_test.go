@@ -1,12 +1,17 @@
 package vault
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
 	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/builtin/logical/ssh"
 	"github.com/hashicorp/vault/logical"
+	cryptossh "golang.org/x/crypto/ssh"
 )
 
 func TestRequestHandling_Wrapping(t *testing.T) {
@@ -140,3 +145,406 @@ func TestRequestHandling_LoginWrapping(t *testing.T) {
 		t.Fatalf("bad: %#v", resp)
 	}
 }
+
+func TestRequestHandling_UserLockout(t *testing.T) {
+	core, _, root := TestCoreUnsealed(t)
+
+	core.credentialBackends["userpass"] = credUserpass.Factory
+
+	requests := []*logical.Request{
+		&logical.Request{
+			Path: "sys/auth/userpass",
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		&logical.Request{
+			Path: "sys/auth/userpass/tune",
+			Data: map[string]interface{}{
+				"lockout_threshold": 3,
+				"lockout_duration":  "1h",
+			},
+		},
+		&logical.Request{
+			Path: "auth/userpass/users/test",
+			Data: map[string]interface{}{
+				"password": "foo",
+				"policies": "default",
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		req.Operation = logical.UpdateOperation
+		resp, err := core.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err:%v resp:%#v", req.Path, err, resp)
+		}
+	}
+
+	login := func(password string) (*logical.Response, error) {
+		return core.HandleRequest(&logical.Request{
+			Path:      "auth/userpass/login/test",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"password": password,
+			},
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, _ := login("bar")
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+
+	// The user is locked out, even with the right password
+	if _, err := login("foo"); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	resp, err := core.HandleRequest(&logical.Request{
+		Path:        "sys/locked-users",
+		ClientToken: root,
+		Operation:   logical.ReadOperation,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	users, ok := resp.Data["userpass/"].(map[string]interface{})
+	if !ok || users["test"] == nil {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if users["test"].(map[string]interface{})["failed_login_attempts"] != 3 {
+		t.Fatalf("bad: %#v", users["test"])
+	}
+
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "sys/locked-users/userpass/unlock/test",
+		ClientToken: root,
+		Operation:   logical.UpdateOperation,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = login("foo")
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// The failed logins are reset by a successful login
+	for i := 0; i < 2; i++ {
+		login("bar")
+	}
+	resp, err = login("foo")
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	for i := 0; i < 2; i++ {
+		login("bar")
+	}
+	resp, err = login("foo")
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// The lockout can be disabled
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "sys/auth/userpass/tune",
+		ClientToken: root,
+		Operation:   logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"lockout_disable": true,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	for i := 0; i < 5; i++ {
+		login("bar")
+	}
+	resp, err = login("foo")
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+}
+
+func TestRequestHandling_LoginIdentity(t *testing.T) {
+	core, _, root := TestCoreUnsealed(t)
+
+	core.credentialBackends["userpass"] = credUserpass.Factory
+	core.logicalBackends["ssh"] = ssh.Factory
+
+	requests := []*logical.Request{
+		&logical.Request{
+			Path: "sys/auth/userpass",
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		&logical.Request{
+			Path: "auth/userpass/users/tuber",
+			Data: map[string]interface{}{
+				"password": "foo",
+				"policies": "signer",
+			},
+		},
+		&logical.Request{
+			Path: "sys/policy/signer",
+			Data: map[string]interface{}{
+				"rules": `
+path "ssh/sign/*" {
+	capabilities = ["update"]
+}
+path "auth/token/create" {
+	capabilities = ["update"]
+}`,
+			},
+		},
+		&logical.Request{
+			Path: "sys/mounts/ssh",
+			Data: map[string]interface{}{
+				"type": "ssh",
+			},
+		},
+		&logical.Request{
+			Path: "ssh/config/ca",
+			Data: map[string]interface{}{
+				"generate_signing_key": true,
+			},
+		},
+		&logical.Request{
+			Path: "ssh/roles/templated",
+			Data: map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"allowed_users":           "{{identity.metadata.username}}",
+				"allowed_users_template":  true,
+			},
+		},
+		&logical.Request{
+			Path: "ssh/roles/default",
+			Data: map[string]interface{}{
+				"key_type":                "ca",
+				"allow_user_certificates": true,
+				"allowed_users":           "*",
+				"default_user":            "{{identity.metadata.username}}",
+				"default_user_template":   true,
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		req.Operation = logical.UpdateOperation
+		resp, err := core.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err:%v resp:%#v", req.Path, err, resp)
+		}
+	}
+
+	resp, err := core.HandleRequest(&logical.Request{
+		Path:      "auth/userpass/login/tuber",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"password": "foo",
+		},
+	})
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	loginToken := resp.Auth.ClientToken
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := cryptossh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(token, role, principal string) *logical.Response {
+		resp, err := core.HandleRequest(&logical.Request{
+			Path:        "ssh/sign/" + role,
+			ClientToken: token,
+			Operation:   logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"public_key":       string(cryptossh.MarshalAuthorizedKey(publicKey)),
+				"valid_principals": principal,
+			},
+		})
+		if err != nil && err != logical.ErrInvalidRequest {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp
+	}
+	createToken := func(token string, data map[string]interface{}) string {
+		resp, err := core.HandleRequest(&logical.Request{
+			Path:        "auth/token/create",
+			ClientToken: token,
+			Operation:   logical.UpdateOperation,
+			Data:        data,
+		})
+		if err != nil || resp == nil || resp.Auth == nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp.Auth.ClientToken
+	}
+
+	if resp := sign(loginToken, "templated", "tuber"); resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// A child token created through the token store cannot choose the
+	// identity it is signed for, it keeps the identity of the login
+	childToken := createToken(loginToken, map[string]interface{}{
+		"meta": map[string]interface{}{
+			"username": "root",
+		},
+	})
+	if resp := sign(childToken, "templated", "root"); resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got %#v", resp)
+	}
+	if resp := sign(childToken, "templated", "tuber"); resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The default user is resolved against the login as well
+	resp = sign(childToken, "default", "")
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	signedKey, _, _, _, err := cryptossh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principals := signedKey.(*cryptossh.Certificate).ValidPrincipals; len(principals) != 1 || principals[0] != "tuber" {
+		t.Fatalf("bad: principals: %#v", principals)
+	}
+
+	// A token that does not descend from a login has no identity at all
+	orphanToken := createToken(root, map[string]interface{}{
+		"policies": []string{"signer"},
+		"meta": map[string]interface{}{
+			"username": "root",
+		},
+	})
+	if resp := sign(orphanToken, "templated", "root"); resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got %#v", resp)
+	}
+	if resp := sign(orphanToken, "default", ""); resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got %#v", resp)
+	}
+}
+
+func TestRequestHandling_TemplatedPolicy(t *testing.T) {
+	core, _, root := TestCoreUnsealed(t)
+
+	core.credentialBackends["userpass"] = credUserpass.Factory
+
+	requests := []*logical.Request{
+		&logical.Request{
+			Path: "sys/auth/userpass",
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		&logical.Request{
+			Path: "auth/userpass/users/tuber",
+			Data: map[string]interface{}{
+				"password": "foo",
+				"policies": "users",
+			},
+		},
+		&logical.Request{
+			Path: "sys/policy/users",
+			Data: map[string]interface{}{
+				"rules": `
+path "secret/{{token.meta.username}}/*" {
+	capabilities = ["create", "update"]
+}
+path "auth/token/create" {
+	capabilities = ["update"]
+}`,
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		req.Operation = logical.UpdateOperation
+		resp, err := core.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err:%v resp:%#v", req.Path, err, resp)
+		}
+	}
+
+	resp, err := core.HandleRequest(&logical.Request{
+		Path:      "auth/userpass/login/tuber",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"password": "foo",
+		},
+	})
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	loginToken := resp.Auth.ClientToken
+
+	write := func(token, path string) error {
+		_, err := core.HandleRequest(&logical.Request{
+			Path:        path,
+			ClientToken: token,
+			Operation:   logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"foo": "bar",
+			},
+		})
+		return err
+	}
+	createToken := func(token string, data map[string]interface{}) string {
+		resp, err := core.HandleRequest(&logical.Request{
+			Path:        "auth/token/create",
+			ClientToken: token,
+			Operation:   logical.UpdateOperation,
+			Data:        data,
+		})
+		if err != nil || resp == nil || resp.Auth == nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp.Auth.ClientToken
+	}
+
+	if err := write(loginToken, "secret/tuber/foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := write(loginToken, "secret/root/foo"); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	// A child token created through the token store resolves the templates
+	// against the login it descends from, whatever its own metadata
+	childToken := createToken(loginToken, map[string]interface{}{
+		"meta": map[string]interface{}{
+			"username": "root",
+		},
+	})
+	if err := write(childToken, "secret/tuber/foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := write(childToken, "secret/root/foo"); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	// A token that does not descend from a login resolves none of them
+	orphanToken := createToken(root, map[string]interface{}{
+		"policies": []string{"users"},
+		"meta": map[string]interface{}{
+			"username": "tuber",
+		},
+	})
+	if err := write(orphanToken, "secret/tuber/foo"); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -14,6 +15,10 @@ import (
 
 // Router is used to do prefix based routing of a request to a logical backend
 type Router struct {
+	// inFlight is the number of requests being handled by the backends. It
+	// is first in the struct to be 64-bit aligned for the atomic operations.
+	inFlight int64
+
 	l              sync.RWMutex
 	root           *radix.Tree
 	tokenStoreSalt *salt.Salt
@@ -35,6 +40,9 @@ func NewRouter() *Router {
 
 // routeEntry is used to represent a mount point in the router
 type routeEntry struct {
+	// inFlight is the number of requests being handled by the backend
+	inFlight int64
+
 	tainted     bool
 	backend     logical.Backend
 	mountEntry  *MountEntry
@@ -239,10 +247,22 @@ func (r *Router) routeCommon(req *logical.Request, existenceCheck bool) (*logica
 	if !ok {
 		return logical.ErrorResponse(fmt.Sprintf("no handler for route '%s'", req.Path)), false, false, logical.ErrUnsupportedPath
 	}
-	defer metrics.MeasureSince([]string{"route", string(req.Operation),
-		strings.Replace(mount, "/", "-", -1)}, time.Now())
+	mountName := strings.Replace(mount, "/", "-", -1)
+	defer metrics.MeasureSince([]string{"route", string(req.Operation), mountName}, time.Now())
 	re := raw.(*routeEntry)
 
+	// Count the requests of the mount and the ones in flight. The existence
+	// checks are part of the requests they precede.
+	if !existenceCheck {
+		metrics.IncrCounter([]string{"route", "requests", string(req.Operation), mountName}, 1)
+		metrics.SetGauge([]string{"route", "in_flight"}, float32(atomic.AddInt64(&r.inFlight, 1)))
+		metrics.SetGauge([]string{"route", "in_flight", mountName}, float32(atomic.AddInt64(&re.inFlight, 1)))
+		defer func() {
+			metrics.SetGauge([]string{"route", "in_flight"}, float32(atomic.AddInt64(&r.inFlight, -1)))
+			metrics.SetGauge([]string{"route", "in_flight", mountName}, float32(atomic.AddInt64(&re.inFlight, -1)))
+		}()
+	}
+
 	// If the path is tainted, we reject any operation except for
 	// Rollback and Revoke
 	if re.tainted {
@@ -317,6 +337,9 @@ func (r *Router) routeCommon(req *logical.Request, existenceCheck bool) (*logica
 		return nil, ok, exists, err
 	} else {
 		resp, err := re.backend.HandleRequest(req)
+		if err != nil || resp.IsError() {
+			metrics.IncrCounter([]string{"route", "errors", string(req.Operation), mountName}, 1)
+		}
 		return resp, false, false, err
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/logical"
 )
@@ -131,6 +132,64 @@ func TestRouter_Mount(t *testing.T) {
 	}
 }
 
+func TestRouter_Metrics(t *testing.T) {
+	conf := metrics.DefaultConfig("vault")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+	inm := metrics.NewInmemSink(time.Minute, time.Minute)
+	metrics.NewGlobal(conf, inm)
+	defer metrics.NewGlobal(conf, &metrics.BlackholeSink{})
+
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &NoopBackend{}
+	if err := r.Mount(n, "transit/", &MountEntry{Path: "transit/", UUID: meUUID}, view); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "transit/encrypt/foo",
+	}
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	n.Response = logical.ErrorResponse("bad input")
+	if _, err := r.Route(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, err := r.RouteExistenceCheck(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	data := inm.Data()
+	intv := data[len(data)-1]
+	intv.RLock()
+	defer intv.RUnlock()
+
+	if c := intv.Counters["vault.route.requests.update.transit-"]; c == nil || c.Sum != 2 {
+		t.Fatalf("bad: %#v", c)
+	}
+	if c := intv.Counters["vault.route.errors.update.transit-"]; c == nil || c.Sum != 1 {
+		t.Fatalf("bad: %#v", c)
+	}
+	if s := intv.Samples["vault.route.update.transit-"]; s == nil || s.Count != 3 {
+		t.Fatalf("bad: %#v", s)
+	}
+	if g, ok := intv.Gauges["vault.route.in_flight.transit-"]; !ok || g != 0 {
+		t.Fatalf("bad: %v", g)
+	}
+	if g, ok := intv.Gauges["vault.route.in_flight"]; !ok || g != 0 {
+		t.Fatalf("bad: %v", g)
+	}
+}
+
 func TestRouter_Unmount(t *testing.T) {
 	r := NewRouter()
 	_, barrier, _ := mockBarrier(t)
@@ -24,7 +24,8 @@ const (
 	// configuration. It is inside the barrier.
 	recoverySealConfigPath = "core/recovery-seal-config"
 
-	// recoveryKeyPath is the path to the recovery key
+	// recoveryKeyPath is the path to the recovery key. Seals storing it
+	// outside the barrier encrypt it first.
 	recoveryKeyPath = "core/recovery-key"
 )
 
@@ -0,0 +1,177 @@
+package awskms
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/awsutil"
+	"github.com/hashicorp/vault/helper/entropy"
+	"github.com/hashicorp/vault/vault/seal"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// AWSKMSSeal is an auto-unseal seal which encrypts the barrier key shares
+// with an AWS KMS key
+type AWSKMSSeal struct {
+	keyID  string
+	client *awsutil.KMS
+	logger log.Logger
+
+	l            sync.RWMutex
+	currentKeyID string
+}
+
+var _ seal.Access = (*AWSKMSSeal)(nil)
+var _ entropy.Sourcer = (*AWSKMSSeal)(nil)
+
+// NewSeal creates an AWS KMS seal from the seal stanza of the server
+// configuration. The key may be given as a key ID, key ARN or alias;
+// credentials are sourced from the configuration, the environment, AWS
+// credential files or by IAM role.
+func NewSeal(conf map[string]string, logger log.Logger) (*AWSKMSSeal, error) {
+	keyID := os.Getenv("VAULT_AWSKMS_SEAL_KEY_ID")
+	if keyID == "" {
+		keyID = conf["kms_key_id"]
+		if keyID == "" {
+			return nil, fmt.Errorf("'kms_key_id' must be set")
+		}
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = conf["region"]
+		if region == "" {
+			region = "us-east-1"
+		}
+	}
+
+	credsConfig := &awsutil.CredentialsConfig{
+		AccessKey:    conf["access_key"],
+		SecretKey:    conf["secret_key"],
+		SessionToken: conf["session_token"],
+		Region:       region,
+		HTTPClient:   cleanhttp.DefaultClient(),
+	}
+	creds, err := credsConfig.GenerateCredentialChain()
+	if err != nil {
+		return nil, err
+	}
+
+	awsConfig := &aws.Config{
+		Credentials: creds,
+		Region:      aws.String(region),
+		HTTPClient:  cleanhttp.DefaultClient(),
+	}
+	if endpoint := conf["endpoint"]; endpoint != "" {
+		awsConfig.Endpoint = aws.String(endpoint)
+	}
+
+	return &AWSKMSSeal{
+		keyID:  keyID,
+		client: awsutil.NewKMS(session.New(awsConfig)),
+		logger: logger,
+	}, nil
+}
+
+// SealType returns the type of the seal
+func (k *AWSKMSSeal) SealType() string {
+	return seal.AWSKMS
+}
+
+// KeyID returns the ARN of the KMS key currently used to encrypt values,
+// which changes when the alias of the seal is moved to another key
+func (k *AWSKMSSeal) KeyID() string {
+	k.l.RLock()
+	defer k.l.RUnlock()
+	return k.currentKeyID
+}
+
+// Init checks that the KMS key is usable and resolves its current ARN
+func (k *AWSKMSSeal) Init() error {
+	arn, err := k.client.DescribeKey(k.keyID)
+	if err != nil {
+		return errwrap.Wrapf("error fetching AWS KMS seal key information: {{err}}", err)
+	}
+
+	k.setCurrentKeyID(arn)
+	return nil
+}
+
+// Finalize is a no-op for the AWS KMS seal
+func (k *AWSKMSSeal) Finalize() error {
+	return nil
+}
+
+// Encrypt encrypts the plaintext with a data key, which is encrypted by the
+// KMS key
+func (k *AWSKMSSeal) Encrypt(plaintext []byte) (*seal.EncryptedBlobInfo, error) {
+	env, err := seal.EnvelopeEncrypt(plaintext)
+	if err != nil {
+		return nil, errwrap.Wrapf("error wrapping data: {{err}}", err)
+	}
+
+	wrappedKey, keyID, err := k.client.Encrypt(k.keyID, env.Key)
+	if err != nil {
+		return nil, errwrap.Wrapf("error encrypting data key with AWS KMS: {{err}}", err)
+	}
+	k.setCurrentKeyID(keyID)
+
+	return &seal.EncryptedBlobInfo{
+		Ciphertext: env.Ciphertext,
+		IV:         env.IV,
+		KeyInfo: &seal.KeyInfo{
+			KeyID:      keyID,
+			WrappedKey: wrappedKey,
+		},
+	}, nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt, with whichever KMS key
+// encrypted its data key
+func (k *AWSKMSSeal) Decrypt(in *seal.EncryptedBlobInfo) ([]byte, error) {
+	if in == nil || in.KeyInfo == nil {
+		return nil, fmt.Errorf("missing key information")
+	}
+
+	key, err := k.client.Decrypt(in.KeyInfo.WrappedKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("error decrypting data key with AWS KMS: {{err}}", err)
+	}
+
+	return seal.EnvelopeDecrypt(&seal.EnvelopeInfo{
+		Ciphertext: in.Ciphertext,
+		Key:        key,
+		IV:         in.IV,
+	})
+}
+
+// GetRandom returns random bytes generated by KMS, to augment the entropy
+// of the host
+func (k *AWSKMSSeal) GetRandom(bytes int) ([]byte, error) {
+	random, err := k.client.GenerateRandom(bytes)
+	if err != nil {
+		return nil, errwrap.Wrapf("error generating random bytes with AWS KMS: {{err}}", err)
+	}
+	return random, nil
+}
+
+func (k *AWSKMSSeal) setCurrentKeyID(keyID string) {
+	if keyID == "" {
+		return
+	}
+
+	k.l.Lock()
+	defer k.l.Unlock()
+	if k.currentKeyID != keyID && k.currentKeyID != "" && k.logger != nil {
+		k.logger.Info("seal/awskms: seal key changed", "previous_key_id", k.currentKeyID, "key_id", keyID)
+	}
+	k.currentKeyID = keyID
+}
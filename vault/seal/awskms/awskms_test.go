@@ -0,0 +1,106 @@
+package awskms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAWSKMSSeal(t *testing.T) {
+	keyARN := "arn:aws:kms:us-east-1:123456789012:key/1"
+
+	// The fake service prefixes the ciphertexts with the key which encrypted
+	// them
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Fatal(err)
+		}
+		blob := func(field string) []byte {
+			b, _ := base64.StdEncoding.DecodeString(input[field].(string))
+			return b
+		}
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.DescribeKey":
+			if input["KeyId"] != "alias/vault" {
+				t.Fatalf("bad key id: %v", input["KeyId"])
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyMetadata": map[string]string{"Arn": keyARN, "KeyId": "1"},
+			})
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId":          keyARN,
+				"CiphertextBlob": append([]byte(keyARN+"|"), blob("Plaintext")...),
+			})
+		case "TrentService.Decrypt":
+			ciphertext := blob("CiphertextBlob")
+			i := bytes.IndexByte(ciphertext, '|')
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId":     string(ciphertext[:i]),
+				"Plaintext": ciphertext[i+1:],
+			})
+		case "TrentService.GenerateRandom":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Plaintext": bytes.Repeat([]byte{1}, int(input["NumberOfBytes"].(float64))),
+			})
+		default:
+			t.Fatalf("unexpected target: %q", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewSeal(map[string]string{
+		"kms_key_id": "alias/vault",
+		"access_key": "id",
+		"secret_key": "secret",
+		"endpoint":   server.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := s.Init(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s.KeyID() != keyARN {
+		t.Fatalf("bad key id: %q", s.KeyID())
+	}
+
+	blobInfo, err := s.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if blobInfo.KeyInfo.KeyID != keyARN || bytes.Contains(blobInfo.Ciphertext, []byte("foo")) {
+		t.Fatalf("bad: %#v", blobInfo)
+	}
+
+	// Once the alias is moved to another key, values encrypted by the
+	// previous key are still decrypted
+	keyARN = "arn:aws:kms:us-east-1:123456789012:key/2"
+	pt, err := s.Decrypt(blobInfo)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(pt) != "foo" {
+		t.Fatalf("bad: %q", pt)
+	}
+
+	if _, err := s.Encrypt([]byte("bar")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s.KeyID() != keyARN {
+		t.Fatalf("bad key id: %q", s.KeyID())
+	}
+
+	random, err := s.GetRandom(16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(random, bytes.Repeat([]byte{1}, 16)) {
+		t.Fatalf("bad: %v", random)
+	}
+}
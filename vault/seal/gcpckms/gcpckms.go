@@ -0,0 +1,278 @@
+package gcpckms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/entropy"
+	"github.com/hashicorp/vault/vault/seal"
+	log "github.com/mgutz/logxi/v1"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// cloudKMSScope is the OAuth scope needed to use Cloud KMS keys
+	cloudKMSScope = "https://www.googleapis.com/auth/cloudkms"
+
+	// defaultEndpoint is the endpoint of the Cloud KMS REST API
+	defaultEndpoint = "https://cloudkms.googleapis.com/v1/"
+)
+
+// GCPCKMSSeal is an auto-unseal seal which encrypts the barrier key shares
+// with a Google Cloud KMS crypto key
+type GCPCKMSSeal struct {
+	keyName  string
+	endpoint string
+	client   *http.Client
+	logger   log.Logger
+
+	l            sync.RWMutex
+	currentKeyID string
+}
+
+var _ seal.Access = (*GCPCKMSSeal)(nil)
+var _ entropy.Sourcer = (*GCPCKMSSeal)(nil)
+
+// NewSeal creates a Google Cloud KMS seal from the seal stanza of the server
+// configuration. Credentials are sourced from a service account file, or
+// from the application default credentials.
+func NewSeal(conf map[string]string, logger log.Logger) (*GCPCKMSSeal, error) {
+	get := func(env, key string) (string, error) {
+		value := os.Getenv(env)
+		if value == "" {
+			value = conf[key]
+		}
+		if value == "" {
+			return "", fmt.Errorf("env var %s or configuration parameter '%s' must be set", env, key)
+		}
+		return value, nil
+	}
+
+	project, err := get("GOOGLE_PROJECT", "project")
+	if err != nil {
+		return nil, err
+	}
+	region, err := get("GOOGLE_REGION", "region")
+	if err != nil {
+		return nil, err
+	}
+	keyRing, err := get("VAULT_GCPCKMS_SEAL_KEY_RING", "key_ring")
+	if err != nil {
+		return nil, err
+	}
+	cryptoKey, err := get("VAULT_GCPCKMS_SEAL_CRYPTO_KEY", "crypto_key")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, cleanhttp.DefaultClient())
+
+	var client *http.Client
+	credentialsFile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if credentialsFile == "" {
+		credentialsFile = conf["credentials"]
+	}
+	if credentialsFile != "" {
+		data, err := ioutil.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, errwrap.Wrapf("error reading credentials file: {{err}}", err)
+		}
+		jwtConfig, err := google.JWTConfigFromJSON(data, cloudKMSScope)
+		if err != nil {
+			return nil, errwrap.Wrapf("error parsing credentials file: {{err}}", err)
+		}
+		client = jwtConfig.Client(ctx)
+	} else {
+		client, err = google.DefaultClient(ctx, cloudKMSScope)
+		if err != nil {
+			return nil, errwrap.Wrapf("error finding default credentials: {{err}}", err)
+		}
+	}
+
+	return &GCPCKMSSeal{
+		keyName:  fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s", project, region, keyRing, cryptoKey),
+		endpoint: defaultEndpoint,
+		client:   client,
+		logger:   logger,
+	}, nil
+}
+
+// SealType returns the type of the seal
+func (g *GCPCKMSSeal) SealType() string {
+	return seal.GCPCKMS
+}
+
+// KeyID returns the name of the primary version of the crypto key, which
+// changes when the crypto key is rotated
+func (g *GCPCKMSSeal) KeyID() string {
+	g.l.RLock()
+	defer g.l.RUnlock()
+	return g.currentKeyID
+}
+
+// Init checks that the crypto key is usable and resolves its primary
+// version
+func (g *GCPCKMSSeal) Init() error {
+	var out struct {
+		Purpose string `json:"purpose"`
+		Primary *struct {
+			Name  string `json:"name"`
+			State string `json:"state"`
+		} `json:"primary"`
+	}
+	if err := g.do("GET", g.keyName, nil, &out); err != nil {
+		return errwrap.Wrapf("error fetching GCP Cloud KMS seal key information: {{err}}", err)
+	}
+	if out.Purpose != "ENCRYPT_DECRYPT" {
+		return fmt.Errorf("crypto key %q cannot be used for encryption", g.keyName)
+	}
+	if out.Primary == nil || out.Primary.State != "ENABLED" {
+		return fmt.Errorf("crypto key %q has no enabled primary version", g.keyName)
+	}
+
+	g.setCurrentKeyID(out.Primary.Name)
+	return nil
+}
+
+// Finalize is a no-op for the GCP Cloud KMS seal
+func (g *GCPCKMSSeal) Finalize() error {
+	return nil
+}
+
+// Encrypt encrypts the plaintext with a data key, which is encrypted by the
+// primary version of the crypto key
+func (g *GCPCKMSSeal) Encrypt(plaintext []byte) (*seal.EncryptedBlobInfo, error) {
+	env, err := seal.EnvelopeEncrypt(plaintext)
+	if err != nil {
+		return nil, errwrap.Wrapf("error wrapping data: {{err}}", err)
+	}
+
+	var out struct {
+		Name       string `json:"name"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	in := map[string]interface{}{
+		"plaintext": env.Key,
+	}
+	if err := g.do("POST", g.keyName+":encrypt", in, &out); err != nil {
+		return nil, errwrap.Wrapf("error encrypting data key with GCP Cloud KMS: {{err}}", err)
+	}
+	g.setCurrentKeyID(out.Name)
+
+	return &seal.EncryptedBlobInfo{
+		Ciphertext: env.Ciphertext,
+		IV:         env.IV,
+		KeyInfo: &seal.KeyInfo{
+			KeyID:      out.Name,
+			WrappedKey: out.Ciphertext,
+		},
+	}, nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt. The version of the crypto
+// key which encrypted its data key is identified by the ciphertext, so
+// values encrypted before a rotation can still be decrypted.
+func (g *GCPCKMSSeal) Decrypt(in *seal.EncryptedBlobInfo) ([]byte, error) {
+	if in == nil || in.KeyInfo == nil {
+		return nil, fmt.Errorf("missing key information")
+	}
+
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	body := map[string]interface{}{
+		"ciphertext": in.KeyInfo.WrappedKey,
+	}
+	if err := g.do("POST", g.keyName+":decrypt", body, &out); err != nil {
+		return nil, errwrap.Wrapf("error decrypting data key with GCP Cloud KMS: {{err}}", err)
+	}
+
+	return seal.EnvelopeDecrypt(&seal.EnvelopeInfo{
+		Ciphertext: in.Ciphertext,
+		Key:        out.Plaintext,
+		IV:         in.IV,
+	})
+}
+
+// GetRandom returns random bytes generated by the HSMs of Cloud KMS in the
+// location of the crypto key, to augment the entropy of the host
+func (g *GCPCKMSSeal) GetRandom(size int) ([]byte, error) {
+	// The key name is projects/*/locations/*/keyRings/*/cryptoKeys/*
+	parts := strings.SplitN(g.keyName, "/", 5)
+	if len(parts) < 5 {
+		return nil, fmt.Errorf("invalid crypto key name %q", g.keyName)
+	}
+	location := strings.Join(parts[:4], "/")
+
+	var out struct {
+		Data []byte `json:"data"`
+	}
+	in := map[string]interface{}{
+		"lengthBytes":     size,
+		"protectionLevel": "HSM",
+	}
+	if err := g.do("POST", location+":generateRandomBytes", in, &out); err != nil {
+		return nil, errwrap.Wrapf("error generating random bytes with GCP Cloud KMS: {{err}}", err)
+	}
+	return out.Data, nil
+}
+
+// do calls the Cloud KMS REST API. Byte slices are encoded as base64 in the
+// requests and responses, as the API expects.
+func (g *GCPCKMSSeal) do(method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(g.endpoint, "/")+"/"+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error.Message == "" {
+			return fmt.Errorf("unexpected response code %d", resp.StatusCode)
+		}
+		return fmt.Errorf("%s (code %d)", apiErr.Error.Message, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (g *GCPCKMSSeal) setCurrentKeyID(keyID string) {
+	if keyID == "" {
+		return
+	}
+
+	g.l.Lock()
+	defer g.l.Unlock()
+	if g.currentKeyID != keyID && g.currentKeyID != "" && g.logger != nil {
+		g.logger.Info("seal/gcpckms: seal key changed", "previous_key_id", g.currentKeyID, "key_id", keyID)
+	}
+	g.currentKeyID = keyID
+}
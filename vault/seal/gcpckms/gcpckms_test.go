@@ -0,0 +1,117 @@
+package gcpckms
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGCPCKMSSeal(t *testing.T) {
+	const keyName = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+	primary := keyName + "/cryptoKeyVersions/1"
+
+	// The fake service prefixes the ciphertexts with the version which
+	// encrypted them
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/projects/p/locations/global:generateRandomBytes" {
+			var in struct {
+				LengthBytes     int    `json:"lengthBytes"`
+				ProtectionLevel string `json:"protectionLevel"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				t.Fatal(err)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": bytes.Repeat([]byte{1}, in.LengthBytes),
+			})
+			return
+		}
+
+		var in map[string][]byte
+		if r.Method == "POST" {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		switch r.URL.Path {
+		case "/" + keyName:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"purpose": "ENCRYPT_DECRYPT",
+				"primary": map[string]string{
+					"name":  primary,
+					"state": "ENABLED",
+				},
+			})
+		case "/" + keyName + ":encrypt":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":       primary,
+				"ciphertext": append([]byte(primary+":"), in["plaintext"]...),
+			})
+		case "/" + keyName + ":decrypt":
+			i := bytes.IndexByte(in["ciphertext"], ':')
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"plaintext": in["ciphertext"][i+1:],
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]string{"message": "not found"},
+			})
+		}
+	}))
+	defer server.Close()
+
+	s := &GCPCKMSSeal{
+		keyName:  keyName,
+		endpoint: server.URL,
+		client:   http.DefaultClient,
+	}
+	if err := s.Init(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s.KeyID() != primary {
+		t.Fatalf("bad key id: %q", s.KeyID())
+	}
+
+	blobInfo, err := s.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if blobInfo.KeyInfo.KeyID != primary || bytes.Contains(blobInfo.Ciphertext, []byte("foo")) {
+		t.Fatalf("bad: %#v", blobInfo)
+	}
+
+	// Values encrypted by a previous version are still decrypted
+	primary = keyName + "/cryptoKeyVersions/2"
+	pt, err := s.Decrypt(blobInfo)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(pt) != "foo" {
+		t.Fatalf("bad: %q", pt)
+	}
+
+	if err := s.Init(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s.KeyID() != primary {
+		t.Fatalf("bad key id: %q", s.KeyID())
+	}
+
+	random, err := s.GetRandom(16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(random, bytes.Repeat([]byte{1}, 16)) {
+		t.Fatalf("bad: %v", random)
+	}
+
+	s.keyName = "nope"
+	if err := s.Init(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
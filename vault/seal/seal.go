@@ -0,0 +1,111 @@
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-uuid"
+)
+
+const (
+	// The types of the seals, as used in the seal stanza of the server
+	// configuration and as the barrier type of the seal configuration
+	Shamir  = "shamir"
+	AWSKMS  = "awskms"
+	GCPCKMS = "gcpckms"
+)
+
+// Access is the interface to the key management service of an auto-unseal
+// seal. Values are encrypted by the current key of the service; values
+// encrypted by previous keys can still be decrypted after a key rotation.
+type Access interface {
+	// SealType returns the type of the seal
+	SealType() string
+
+	// KeyID returns the ID of the key currently used to encrypt values
+	KeyID() string
+
+	// Init is called once the seal is configured, before it is used
+	Init() error
+
+	// Finalize is called when the server shuts down
+	Finalize() error
+
+	Encrypt([]byte) (*EncryptedBlobInfo, error)
+	Decrypt(*EncryptedBlobInfo) ([]byte, error)
+}
+
+// EncryptedBlobInfo is a value encrypted by a seal
+type EncryptedBlobInfo struct {
+	Ciphertext []byte   `json:"ciphertext"`
+	IV         []byte   `json:"iv"`
+	KeyInfo    *KeyInfo `json:"key_info"`
+}
+
+// KeyInfo describes the key used to encrypt a value. Values are encrypted by
+// a data key, which is itself encrypted by the key management service.
+type KeyInfo struct {
+	// KeyID is the ID of the key of the key management service which
+	// encrypted the data key
+	KeyID string `json:"key_id"`
+
+	// WrappedKey is the encrypted data key
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// EnvelopeInfo is the result of the envelope encryption of a value
+type EnvelopeInfo struct {
+	Ciphertext []byte
+	Key        []byte
+	IV         []byte
+}
+
+// EnvelopeEncrypt encrypts the plaintext with a new AES-256-GCM data key.
+// The returned key must be encrypted by the key management service, so that
+// values larger than the service allows can be encrypted.
+func EnvelopeEncrypt(plaintext []byte) (*EnvelopeInfo, error) {
+	key, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := uuid.GenerateRandomBytes(12)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnvelopeInfo{
+		Ciphertext: aead.Seal(nil, iv, plaintext, nil),
+		Key:        key,
+		IV:         iv,
+	}, nil
+}
+
+// EnvelopeDecrypt decrypts the result of EnvelopeEncrypt with the decrypted
+// data key
+func EnvelopeDecrypt(data *EnvelopeInfo) ([]byte, error) {
+	aead, err := newAEAD(data.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, data.IV, data.Ciphertext, nil)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to decrypt envelope: {{err}}", err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
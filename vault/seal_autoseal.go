@@ -0,0 +1,331 @@
+package vault
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+const (
+	// storedBarrierKeysPath is the path used to store the barrier key shares
+	// of an auto-unseal seal, encrypted by the seal
+	storedBarrierKeysPath = "core/hsm/barrier-unseal-keys"
+
+	// recoverySealConfigPlaintextPath is the path to the recovery key seal
+	// configuration of an auto-unseal seal. It is stored in plaintext as it
+	// must be readable with the Vault sealed, to generate root tokens.
+	recoverySealConfigPlaintextPath = "core/recovery-config"
+)
+
+// autoSeal is a seal whose barrier key shares are stored encrypted by a key
+// management service, so that the Vault is unsealed automatically on start.
+// Recovery keys are used instead of unseal keys for the operations which
+// require a quorum, such as generating a root token.
+type autoSeal struct {
+	seal.Access
+
+	barrierConfig  *SealConfig
+	recoveryConfig *SealConfig
+	core           *Core
+}
+
+// NewAutoSeal creates a seal storing the barrier key shares encrypted by the
+// given key management service
+func NewAutoSeal(access seal.Access) Seal {
+	return &autoSeal{
+		Access: access,
+	}
+}
+
+func (d *autoSeal) checkCore() error {
+	if d.core == nil {
+		return fmt.Errorf("seal does not have a core set")
+	}
+	return nil
+}
+
+func (d *autoSeal) SetCore(core *Core) {
+	d.core = core
+}
+
+func (d *autoSeal) Init() error {
+	return d.Access.Init()
+}
+
+func (d *autoSeal) Finalize() error {
+	return d.Access.Finalize()
+}
+
+func (d *autoSeal) BarrierType() string {
+	return d.SealType()
+}
+
+func (d *autoSeal) StoredKeysSupported() bool {
+	return true
+}
+
+func (d *autoSeal) RecoveryKeySupported() bool {
+	return true
+}
+
+func (d *autoSeal) RecoveryType() string {
+	return seal.Shamir
+}
+
+// SetStoredKeys encrypts the barrier key shares with the seal and stores
+// them
+func (d *autoSeal) SetStoredKeys(keys [][]byte) error {
+	if keys == nil {
+		return fmt.Errorf("keys were nil")
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys provided")
+	}
+
+	buf, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to encode keys for storage: %v", err)
+	}
+
+	return d.putEncrypted(storedBarrierKeysPath, buf)
+}
+
+// GetStoredKeys decrypts the stored barrier key shares
+func (d *autoSeal) GetStoredKeys() ([][]byte, error) {
+	pt, err := d.getEncrypted(storedBarrierKeysPath)
+	if err != nil {
+		return nil, err
+	}
+	if pt == nil {
+		return nil, nil
+	}
+
+	var keys [][]byte
+	if err := json.Unmarshal(pt, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode stored keys: %v", err)
+	}
+	return keys, nil
+}
+
+func (d *autoSeal) BarrierConfig() (*SealConfig, error) {
+	if d.barrierConfig != nil {
+		return d.barrierConfig.Clone(), nil
+	}
+
+	conf, err := d.readConfig(barrierSealConfigPath, d.BarrierType())
+	if err != nil || conf == nil {
+		return nil, err
+	}
+
+	d.barrierConfig = conf
+	return d.barrierConfig.Clone(), nil
+}
+
+func (d *autoSeal) SetBarrierConfig(config *SealConfig) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	// Provide a way to wipe out the cached value (also prevents actually
+	// saving a nil config)
+	if config == nil {
+		d.barrierConfig = nil
+		return nil
+	}
+
+	config.Type = d.BarrierType()
+	if err := d.writeConfig(barrierSealConfigPath, config); err != nil {
+		return err
+	}
+
+	d.barrierConfig = config.Clone()
+	return nil
+}
+
+func (d *autoSeal) RecoveryConfig() (*SealConfig, error) {
+	if d.recoveryConfig != nil {
+		return d.recoveryConfig.Clone(), nil
+	}
+
+	conf, err := d.readConfig(recoverySealConfigPlaintextPath, d.RecoveryType())
+	if err != nil || conf == nil {
+		return nil, err
+	}
+
+	d.recoveryConfig = conf
+	return d.recoveryConfig.Clone(), nil
+}
+
+func (d *autoSeal) SetRecoveryConfig(config *SealConfig) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	// Provide a way to wipe out the cached value
+	if config == nil {
+		d.recoveryConfig = nil
+		return nil
+	}
+
+	config.Type = d.RecoveryType()
+	if err := d.writeConfig(recoverySealConfigPlaintextPath, config); err != nil {
+		return err
+	}
+
+	d.recoveryConfig = config.Clone()
+	return nil
+}
+
+// SetRecoveryKey encrypts the recovery key with the seal and stores it
+func (d *autoSeal) SetRecoveryKey(key []byte) error {
+	if key == nil {
+		return fmt.Errorf("recovery key to store is nil")
+	}
+
+	return d.putEncrypted(recoveryKeyPath, key)
+}
+
+// VerifyRecoveryKey checks the given recovery key against the stored one
+func (d *autoSeal) VerifyRecoveryKey(key []byte) error {
+	if key == nil {
+		return fmt.Errorf("recovery key to verify is nil")
+	}
+
+	pt, err := d.getEncrypted(recoveryKeyPath)
+	if err != nil {
+		return err
+	}
+	if pt == nil {
+		return fmt.Errorf("no recovery key found")
+	}
+
+	if subtle.ConstantTimeCompare(key, pt) != 1 {
+		return fmt.Errorf("recovery key does not match submitted values")
+	}
+	return nil
+}
+
+// readConfig reads the seal configuration stored in plaintext at the path,
+// and checks that it is of the given type
+func (d *autoSeal) readConfig(path, sealType string) (*SealConfig, error) {
+	if err := d.checkCore(); err != nil {
+		return nil, err
+	}
+
+	pe, err := d.core.physical.Get(path)
+	if err != nil {
+		d.core.logger.Error("core: failed to read seal configuration", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to check seal configuration: %v", err)
+	}
+
+	// If the seal configuration is missing, we are not initialized
+	if pe == nil {
+		d.core.logger.Info("core: seal configuration missing, not initialized", "path", path)
+		return nil, nil
+	}
+
+	var conf SealConfig
+	if err := jsonutil.DecodeJSON(pe.Value, &conf); err != nil {
+		d.core.logger.Error("core: failed to decode seal configuration", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to decode seal configuration: %v", err)
+	}
+
+	if conf.Type != sealType {
+		d.core.logger.Error("core: seal type does not match loaded type", "seal_type", conf.Type, "loaded_seal_type", sealType)
+		return nil, fmt.Errorf("seal type of %s does not match loaded type of %s", conf.Type, sealType)
+	}
+
+	// Check for a valid seal configuration
+	if err := conf.Validate(); err != nil {
+		d.core.logger.Error("core: invalid seal configuration", "error", err)
+		return nil, fmt.Errorf("seal validation failed: %v", err)
+	}
+
+	return &conf, nil
+}
+
+// writeConfig stores the seal configuration in plaintext at the path
+func (d *autoSeal) writeConfig(path string, config *SealConfig) error {
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode seal configuration: %v", err)
+	}
+
+	pe := &physical.Entry{
+		Key:   path,
+		Value: buf,
+	}
+	if err := d.core.physical.Put(pe); err != nil {
+		d.core.logger.Error("core: failed to write seal configuration", "path", path, "error", err)
+		return fmt.Errorf("failed to write seal configuration: %v", err)
+	}
+	return nil
+}
+
+// putEncrypted encrypts the value with the seal and stores it at the path
+func (d *autoSeal) putEncrypted(path string, value []byte) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	blobInfo, err := d.Encrypt(value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt value with seal: %v", err)
+	}
+	buf, err := json.Marshal(blobInfo)
+	if err != nil {
+		return fmt.Errorf("failed to encode encrypted value: %v", err)
+	}
+
+	pe := &physical.Entry{
+		Key:   path,
+		Value: buf,
+	}
+	if err := d.core.physical.Put(pe); err != nil {
+		d.core.logger.Error("core: failed to write sealed value", "path", path, "error", err)
+		return fmt.Errorf("failed to write sealed value: %v", err)
+	}
+	return nil
+}
+
+// getEncrypted reads the value stored at the path and decrypts it with the
+// seal, or returns nil if there is none. Values encrypted by a previous key
+// of the seal are encrypted again with its current key, so that the
+// previous key may be retired once the Vault is unsealed.
+func (d *autoSeal) getEncrypted(path string) ([]byte, error) {
+	if err := d.checkCore(); err != nil {
+		return nil, err
+	}
+
+	pe, err := d.core.physical.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sealed value: %v", err)
+	}
+	if pe == nil {
+		return nil, nil
+	}
+
+	var blobInfo seal.EncryptedBlobInfo
+	if err := jsonutil.DecodeJSON(pe.Value, &blobInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode sealed value: %v", err)
+	}
+
+	pt, err := d.Decrypt(&blobInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value with seal: %v", err)
+	}
+
+	if keyID := d.KeyID(); keyID != "" && blobInfo.KeyInfo != nil && blobInfo.KeyInfo.KeyID != keyID {
+		if err := d.putEncrypted(path, pt); err != nil {
+			d.core.logger.Warn("core: failed to re-encrypt value with current seal key", "path", path, "error", err)
+		} else if d.core.logger.IsInfo() {
+			d.core.logger.Info("core: re-encrypted value with current seal key", "path", path, "previous_key_id", blobInfo.KeyInfo.KeyID, "key_id", keyID)
+		}
+	}
+
+	return pt, nil
+}
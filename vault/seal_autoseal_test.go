@@ -0,0 +1,160 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/shamir"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// testSealAccess is a key management service keeping its keys in memory,
+// which encrypts the data keys by XOR with its current key
+type testSealAccess struct {
+	keys       map[string][]byte
+	currentKey string
+}
+
+func newTestSealAccess() *testSealAccess {
+	return &testSealAccess{
+		keys:       map[string][]byte{"key1": bytes.Repeat([]byte{1}, 32)},
+		currentKey: "key1",
+	}
+}
+
+func (a *testSealAccess) SealType() string { return "test" }
+func (a *testSealAccess) KeyID() string    { return a.currentKey }
+func (a *testSealAccess) Init() error      { return nil }
+func (a *testSealAccess) Finalize() error  { return nil }
+
+func (a *testSealAccess) Encrypt(plaintext []byte) (*seal.EncryptedBlobInfo, error) {
+	env, err := seal.EnvelopeEncrypt(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &seal.EncryptedBlobInfo{
+		Ciphertext: env.Ciphertext,
+		IV:         env.IV,
+		KeyInfo: &seal.KeyInfo{
+			KeyID:      a.currentKey,
+			WrappedKey: xorBytes(env.Key, a.keys[a.currentKey]),
+		},
+	}, nil
+}
+
+func (a *testSealAccess) Decrypt(in *seal.EncryptedBlobInfo) ([]byte, error) {
+	key, ok := a.keys[in.KeyInfo.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", in.KeyInfo.KeyID)
+	}
+	return seal.EnvelopeDecrypt(&seal.EnvelopeInfo{
+		Ciphertext: in.Ciphertext,
+		Key:        xorBytes(in.KeyInfo.WrappedKey, key),
+		IV:         in.IV,
+	})
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func TestAutoSeal(t *testing.T) {
+	access := newTestSealAccess()
+	c := TestCoreWithSeal(t, NewAutoSeal(access))
+
+	result, err := c.Initialize(&InitParams{
+		BarrierConfig: &SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+			StoredShares:    1,
+		},
+		RecoveryConfig: &SealConfig{
+			SecretShares:    5,
+			SecretThreshold: 3,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(result.SecretShares) != 0 || len(result.RecoveryShares) != 5 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// The Vault is unsealed with the stored keys
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+
+	conf, err := c.seal.BarrierConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf.Type != "test" {
+		t.Fatalf("bad: %#v", conf)
+	}
+	conf, err = c.seal.RecoveryConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf.Type != "shamir" || conf.SecretShares != 5 || conf.SecretThreshold != 3 {
+		t.Fatalf("bad: %#v", conf)
+	}
+
+	recoveryKey, err := shamir.Combine(result.RecoveryShares[:3])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.seal.VerifyRecoveryKey(recoveryKey); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.seal.VerifyRecoveryKey([]byte("nope")); err == nil {
+		t.Fatalf("expected error verifying bad recovery key")
+	}
+
+	// After a rotation of the seal key, the stored keys are still decrypted
+	// and then encrypted again with the new key
+	if err := c.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	access.keys["key2"] = bytes.Repeat([]byte{2}, 32)
+	access.currentKey = "key2"
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+
+	pe, err := c.physical.Get(storedBarrierKeysPath)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var blobInfo seal.EncryptedBlobInfo
+	if err := jsonutil.DecodeJSON(pe.Value, &blobInfo); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if blobInfo.KeyInfo.KeyID != "key2" {
+		t.Fatalf("stored keys not encrypted with new key: %#v", blobInfo.KeyInfo)
+	}
+
+	// The previous key can be retired
+	delete(access.keys, "key1")
+	if err := c.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatalf("should not be sealed")
+	}
+}
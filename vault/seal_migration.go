@@ -0,0 +1,252 @@
+package vault
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// checkSealMigration starts a seal migration if the Vault was sealed by the
+// unwrap seal rather than the configured seal
+func (c *Core) checkSealMigration(unwrapSeal Seal) error {
+	if unwrapSeal == nil {
+		return nil
+	}
+
+	pe, err := c.physical.Get(barrierSealConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to check seal configuration: %v", err)
+	}
+	if pe == nil {
+		return nil
+	}
+
+	var conf SealConfig
+	if err := jsonutil.DecodeJSON(pe.Value, &conf); err != nil {
+		return fmt.Errorf("failed to decode seal configuration: %v", err)
+	}
+	if conf.Type == "" {
+		conf.Type = seal.Shamir
+	}
+
+	if conf.Type == c.seal.BarrierType() || conf.Type != unwrapSeal.BarrierType() {
+		return nil
+	}
+
+	unwrapSeal.SetCore(c)
+	c.migrationLock.Lock()
+	c.migrationSeal = unwrapSeal
+	c.migrationLock.Unlock()
+
+	if c.logger.IsWarn() {
+		c.logger.Warn("core: seal migration required, unseal with the migrate option", "from", conf.Type, "to", c.seal.BarrierType())
+	}
+	return nil
+}
+
+// sealMigration returns the seal the Vault is being migrated from, or nil
+// if there is no seal migration in progress
+func (c *Core) sealMigration() Seal {
+	c.migrationLock.RLock()
+	defer c.migrationLock.RUnlock()
+	return c.migrationSeal
+}
+
+// SealMigrationInProgress returns whether the Vault must be unsealed with
+// the migrate option
+func (c *Core) SealMigrationInProgress() bool {
+	return c.sealMigration() != nil
+}
+
+// SealMigrationKeyConfig returns the configuration of the keys used to
+// migrate the seal: the unseal keys when migrating from Shamir keys, or the
+// recovery keys when migrating from an auto-unseal seal
+func (c *Core) SealMigrationKeyConfig() (*SealConfig, error) {
+	migrationSeal := c.sealMigration()
+	if migrationSeal == nil {
+		return nil, ErrNoSealMigration
+	}
+
+	if migrationSeal.RecoveryKeySupported() {
+		return migrationSeal.RecoveryConfig()
+	}
+	return migrationSeal.BarrierConfig()
+}
+
+// UnsealMigrate is used to provide one of the key parts of the seal the
+// Vault is being migrated from. Once enough parts are provided, the master
+// key is stored by the new seal and the Vault is unsealed.
+//
+// When migrating from Shamir keys to an auto-unseal seal, the unseal keys
+// become the recovery keys. When migrating from an auto-unseal seal to
+// Shamir keys, the recovery keys become the unseal keys.
+//
+// They key given as a parameter will automatically be zerod after
+// this method is done with it. If you want to keep the key around, a copy
+// should be made.
+func (c *Core) UnsealMigrate(key []byte) (bool, error) {
+	defer metrics.MeasureSince([]string{"core", "unseal-migrate"}, time.Now())
+
+	if err := c.checkKeyLength(key); err != nil {
+		return false, err
+	}
+
+	config, err := c.SealMigrationKeyConfig()
+	if err != nil {
+		return false, err
+	}
+	if config == nil {
+		return false, ErrNotInit
+	}
+
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	// Check if already unsealed
+	if !c.sealed {
+		return true, nil
+	}
+
+	combinedKey, err := c.unsealPart(config, key)
+	if err != nil {
+		return false, err
+	}
+	if combinedKey == nil {
+		return false, nil
+	}
+
+	masterKey, err := c.migrateSeal(combinedKey)
+	if err != nil {
+		return false, err
+	}
+	return c.unsealInternal(masterKey)
+}
+
+// migrateSeal moves the master key from the previous seal to the configured
+// one, given the key combined from the key parts of the previous seal, and
+// returns the master key. This must be called with the state write lock
+// held.
+func (c *Core) migrateSeal(key []byte) ([]byte, error) {
+	migrationSeal := c.sealMigration()
+	if migrationSeal == nil {
+		return nil, ErrNoSealMigration
+	}
+
+	var masterKey []byte
+	var err error
+	if migrationSeal.RecoveryKeySupported() {
+		masterKey, err = c.migrateFromAutoSeal(migrationSeal, key)
+	} else {
+		masterKey, err = c.migrateFromShamirSeal(migrationSeal, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.migrationLock.Lock()
+	c.migrationSeal = nil
+	c.migrationLock.Unlock()
+
+	if c.logger.IsInfo() {
+		c.logger.Info("core: seal migration complete", "from", migrationSeal.BarrierType(), "to", c.seal.BarrierType())
+	}
+	return masterKey, nil
+}
+
+// migrateFromShamirSeal stores the master key, combined from the unseal
+// keys, with the auto-unseal seal. The master key also becomes the recovery
+// key, so that the unseal keys can be used as recovery keys.
+func (c *Core) migrateFromShamirSeal(migrationSeal Seal, masterKey []byte) ([]byte, error) {
+	if !c.seal.StoredKeysSupported() || !c.seal.RecoveryKeySupported() {
+		return nil, fmt.Errorf("seal of type %s cannot be migrated to from Shamir keys", c.seal.BarrierType())
+	}
+
+	oldConfig, err := migrationSeal.BarrierConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the master key before replacing the seal configuration
+	if err := c.barrier.Unseal(masterKey); err != nil {
+		return nil, err
+	}
+	if err := c.barrier.Seal(); err != nil {
+		return nil, err
+	}
+
+	if err := c.seal.SetBarrierConfig(&SealConfig{
+		SecretShares:    1,
+		SecretThreshold: 1,
+		StoredShares:    1,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store barrier configuration: %v", err)
+	}
+	if err := c.seal.SetStoredKeys([][]byte{masterKey}); err != nil {
+		return nil, fmt.Errorf("failed to store master key: %v", err)
+	}
+	if err := c.seal.SetRecoveryConfig(&SealConfig{
+		SecretShares:    oldConfig.SecretShares,
+		SecretThreshold: oldConfig.SecretThreshold,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store recovery configuration: %v", err)
+	}
+	if err := c.seal.SetRecoveryKey(masterKey); err != nil {
+		return nil, fmt.Errorf("failed to store recovery key: %v", err)
+	}
+
+	return masterKey, nil
+}
+
+// migrateFromAutoSeal rekeys the barrier with the recovery key, so that the
+// recovery keys can be used as unseal keys, and removes the keys stored by
+// the auto-unseal seal.
+func (c *Core) migrateFromAutoSeal(migrationSeal Seal, recoveryKey []byte) ([]byte, error) {
+	if c.seal.StoredKeysSupported() {
+		return nil, fmt.Errorf("seal of type %s cannot be migrated to from seal of type %s", c.seal.BarrierType(), migrationSeal.BarrierType())
+	}
+
+	if err := migrationSeal.VerifyRecoveryKey(recoveryKey); err != nil {
+		return nil, err
+	}
+	recoveryConfig, err := migrationSeal.RecoveryConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := migrationSeal.GetStoredKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stored keys: %v", err)
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("expected a single stored key, found %d", len(keys))
+	}
+
+	if err := c.barrier.Unseal(keys[0]); err != nil {
+		return nil, err
+	}
+	if err := c.barrier.Rekey(recoveryKey); err != nil {
+		c.barrier.Seal()
+		return nil, fmt.Errorf("failed to rekey barrier: %v", err)
+	}
+	if err := c.barrier.Seal(); err != nil {
+		return nil, err
+	}
+
+	if err := c.seal.SetBarrierConfig(&SealConfig{
+		SecretShares:    recoveryConfig.SecretShares,
+		SecretThreshold: recoveryConfig.SecretThreshold,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store barrier configuration: %v", err)
+	}
+
+	for _, path := range []string{storedBarrierKeysPath, recoverySealConfigPlaintextPath, recoveryKeyPath} {
+		if err := c.physical.Delete(path); err != nil {
+			c.logger.Warn("core: failed to remove entry of previous seal", "path", path, "error", err)
+		}
+	}
+
+	return recoveryKey, nil
+}
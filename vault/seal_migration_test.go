@@ -0,0 +1,132 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/shamir"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func TestCore_SealMigration(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	phys := physical.NewInmem(logger)
+	access := newTestSealAccess()
+
+	newCore := func(seal, unwrapSeal Seal) *Core {
+		conf := testCoreConfig(t, phys, logger)
+		conf.Seal = seal
+		conf.UnwrapSeal = unwrapSeal
+		c, err := NewCore(conf)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return c
+	}
+	unseal := func(c *Core, keys [][]byte, migrate bool) {
+		for _, key := range keys {
+			var err error
+			if migrate {
+				_, err = c.UnsealMigrate(TestKeyCopy(key))
+			} else {
+				_, err = TestCoreUnseal(c, TestKeyCopy(key))
+			}
+			if err != nil {
+				t.Fatalf("unseal err: %v", err)
+			}
+		}
+		if sealed, _ := c.Sealed(); sealed {
+			t.Fatalf("should not be sealed")
+		}
+	}
+	checkSecret := func(c *Core, root string) {
+		req := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+		req.ClientToken = root
+		resp, err := c.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || resp.Data["bar"] != "baz" {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+
+	// A Vault sealed by Shamir keys
+	c := newCore(nil, nil)
+	keys, root := TestCoreInit(t, c)
+	unseal(c, keys, false)
+	req := logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.ClientToken = root
+	req.Data["bar"] = "baz"
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Migrating to an auto-unseal seal requires the unseal keys
+	c = newCore(NewAutoSeal(access), &DefaultSeal{})
+	if !c.SealMigrationInProgress() {
+		t.Fatalf("expected seal migration")
+	}
+	if conf, err := c.SealMigrationKeyConfig(); err != nil || conf.SecretThreshold != 3 {
+		t.Fatalf("bad: %#v %v", conf, err)
+	}
+	if _, err := TestCoreUnseal(c, TestKeyCopy(keys[0])); err != ErrSealMigrationRequired {
+		t.Fatalf("expected migration required error, got %v", err)
+	}
+	unseal(c, keys, true)
+	if c.SealMigrationInProgress() {
+		t.Fatalf("seal migration not complete")
+	}
+	checkSecret(c, root)
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The Vault is then unsealed on start, and the unseal keys are its
+	// recovery keys
+	c = newCore(NewAutoSeal(access), &DefaultSeal{})
+	if sealed, _ := c.Sealed(); sealed || c.SealMigrationInProgress() {
+		t.Fatalf("should be unsealed without migration")
+	}
+	checkSecret(c, root)
+	recoveryKey, err := shamir.Combine(keys)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.seal.VerifyRecoveryKey(recoveryKey); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Migrating back to Shamir keys requires the recovery keys
+	c = newCore(nil, NewAutoSeal(access))
+	if !c.SealMigrationInProgress() {
+		t.Fatalf("expected seal migration")
+	}
+	if _, err := c.UnsealMigrate(TestKeyCopy(keys[0][:5])); err == nil {
+		t.Fatalf("expected error for short key")
+	}
+	unseal(c, keys, true)
+	checkSecret(c, root)
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pe, err := phys.Get(storedBarrierKeysPath); err != nil || pe != nil {
+		t.Fatalf("stored keys not removed: %#v %v", pe, err)
+	}
+
+	// The recovery keys are now the unseal keys
+	c = newCore(nil, nil)
+	if _, err := c.UnsealMigrate(TestKeyCopy(keys[0])); err != ErrNoSealMigration {
+		t.Fatalf("expected no migration error, got %v", err)
+	}
+	unseal(c, keys, false)
+	checkSecret(c, root)
+}
@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// sealWrappedPrefix marks the values which are encrypted by the seal, in
+// addition to the barrier
+var sealWrappedPrefix = []byte("vault-seal-wrapped:")
+
+// sealWrapper encrypts the values stored under the sensitive paths of a
+// mount with the seal, before they are encrypted by the barrier
+type sealWrapper struct {
+	// access is the key management service of the seal, or nil with the
+	// Shamir seal, in which case values are only encrypted by the barrier
+	access seal.Access
+
+	// unwrapAccess is the key management service of the seal the Vault is
+	// being migrated from, if any, which can still decrypt the values
+	// wrapped before the migration
+	unwrapAccess seal.Access
+
+	// paths are the storage prefixes whose values are wrapped, including the
+	// prefix of the mount
+	paths []string
+}
+
+// setupSealWrap enables the seal wrapping of the storage paths the backend
+// marks as sensitive in the view of its mount
+func (c *Core) setupSealWrap(view *BarrierView, backend logical.Backend) {
+	special := backend.SpecialPaths()
+	if special == nil || len(special.SealWrapStorage) == 0 {
+		return
+	}
+
+	w := &sealWrapper{}
+	if s, ok := c.seal.(*autoSeal); ok {
+		w.access = s.Access
+	}
+	if s, ok := c.migrationSeal.(*autoSeal); ok {
+		w.unwrapAccess = s.Access
+	}
+	if w.access == nil && c.logger.IsDebug() {
+		c.logger.Debug("core: seal wrapping requires an auto-unseal seal, values are only encrypted by the barrier", "prefix", view.prefix)
+	}
+	for _, path := range special.SealWrapStorage {
+		w.paths = append(w.paths, view.expandKey(path))
+	}
+	view.sealWrap = w
+}
+
+// matches returns whether the value of the key, including the prefix of the
+// mount, is wrapped
+func (w *sealWrapper) matches(key string) bool {
+	for _, path := range w.paths {
+		if strings.HasPrefix(key, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap encrypts the value of the key with the seal if it is sensitive
+func (w *sealWrapper) wrap(key string, value []byte) ([]byte, error) {
+	if w.access == nil || !w.matches(key) {
+		return value, nil
+	}
+
+	blobInfo, err := w.access.Encrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt value with seal: %v", err)
+	}
+	buf, err := json.Marshal(blobInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode seal wrapped value: %v", err)
+	}
+	return append(append([]byte{}, sealWrappedPrefix...), buf...), nil
+}
+
+// unwrap decrypts the value if it was wrapped by the seal. Values stored
+// before seal wrapping was enabled are returned as is.
+func (w *sealWrapper) unwrap(value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, sealWrappedPrefix) {
+		return value, nil
+	}
+
+	var blobInfo seal.EncryptedBlobInfo
+	if err := jsonutil.DecodeJSON(value[len(sealWrappedPrefix):], &blobInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode seal wrapped value: %v", err)
+	}
+
+	lastErr := fmt.Errorf("no auto-unseal seal is configured")
+	for _, access := range []seal.Access{w.access, w.unwrapAccess} {
+		if access == nil {
+			continue
+		}
+		pt, err := access.Decrypt(&blobInfo)
+		if err == nil {
+			return pt, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("failed to decrypt seal wrapped value: %v", lastErr)
+}
@@ -0,0 +1,117 @@
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// sealWrapBackend is a noop backend marking the keys/ storage prefix as
+// sensitive
+type sealWrapBackend struct {
+	*NoopBackend
+}
+
+func (b *sealWrapBackend) SpecialPaths() *logical.Paths {
+	return &logical.Paths{
+		SealWrapStorage: []string{"keys/"},
+	}
+}
+
+func TestCore_SealWrap(t *testing.T) {
+	c := TestCoreWithSeal(t, NewAutoSeal(newTestSealAccess()))
+	result, err := c.Initialize(&InitParams{
+		BarrierConfig: &SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+			StoredShares:    1,
+		},
+		RecoveryConfig: &SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.logicalBackends["sealwrap"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &sealWrapBackend{NoopBackend: &NoopBackend{}}, nil
+	}
+
+	for _, sealWrap := range []bool{true, false} {
+		path := "plain"
+		if sealWrap {
+			path = "wrapped"
+		}
+		req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/"+path)
+		req.ClientToken = result.RootToken
+		req.Data["type"] = "sealwrap"
+		req.Data["seal_wrap"] = sealWrap
+		if resp, err := c.HandleRequest(req); err != nil || resp != nil {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+
+		view := c.router.MatchingStorageView(path + "/")
+		for _, key := range []string{"keys/foo", "other"} {
+			if err := view.Put(&logical.StorageEntry{Key: key, Value: []byte("secret")}); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			// Only the sensitive values of the seal wrapped mount are
+			// wrapped by the seal
+			raw, err := c.barrier.Get(view.expandKey(key))
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			wrapped := bytes.HasPrefix(raw.Value, sealWrappedPrefix)
+			if wrapped != (sealWrap && key == "keys/foo") {
+				t.Fatalf("bad value for %q in %q: %q", key, path, raw.Value)
+			}
+
+			entry, err := view.Get(key)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if entry == nil || string(entry.Value) != "secret" {
+				t.Fatalf("bad entry for %q in %q: %#v", key, path, entry)
+			}
+		}
+	}
+
+	// The option is persisted in the mount table
+	if entry := c.router.MatchingMountEntry("wrapped/"); entry == nil || !entry.SealWrap {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestCore_SealWrap_Shamir(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	c.logicalBackends["sealwrap"] = func(*logical.BackendConfig) (logical.Backend, error) {
+		return &sealWrapBackend{NoopBackend: &NoopBackend{}}, nil
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/wrapped")
+	req.ClientToken = root
+	req.Data["type"] = "sealwrap"
+	req.Data["seal_wrap"] = true
+	if resp, err := c.HandleRequest(req); err != nil || resp != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// Values are only encrypted by the barrier with the Shamir seal
+	view := c.router.MatchingStorageView("wrapped/")
+	if err := view.Put(&logical.StorageEntry{Key: "keys/foo", Value: []byte("secret")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err := c.barrier.Get(view.expandKey("keys/foo"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(raw.Value) != "secret" {
+		t.Fatalf("bad: %q", raw.Value)
+	}
+}
@@ -0,0 +1,185 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// The types of the tokens. Service tokens are persisted in the token
+	// store; batch tokens carry their encrypted entry and are not persisted.
+	TokenTypeService = "service"
+	TokenTypeBatch   = "batch"
+
+	// tokenTypeDefault is the token type of the roles issuing the type
+	// requested at creation
+	tokenTypeDefault = "default"
+
+	// batchTokenPrefix is the prefix of the IDs of the batch tokens
+	batchTokenPrefix = "b."
+
+	// batchKeyPath is the path of the key encrypting the batch tokens
+	batchKeyPath = "batch-key"
+)
+
+// isBatchToken returns whether the token ID is a batch token
+func isBatchToken(id string) bool {
+	return strings.HasPrefix(id, batchTokenPrefix)
+}
+
+// validTokenType returns whether the token type is supported
+func validTokenType(tokenType string) bool {
+	return tokenType == TokenTypeService || tokenType == TokenTypeBatch
+}
+
+// batchCipher returns the cipher of the batch tokens, creating their key if
+// needed
+func (ts *TokenStore) batchCipher() (cipher.AEAD, error) {
+	ts.batchLock.Lock()
+	defer ts.batchLock.Unlock()
+
+	if ts.batchGCM != nil {
+		return ts.batchGCM, nil
+	}
+
+	raw, err := ts.view.Get(batchKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch token key: %v", err)
+	}
+
+	var key []byte
+	if raw != nil {
+		key = raw.Value
+	} else {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate batch token key: %v", err)
+		}
+		if err := ts.view.Put(&logical.StorageEntry{Key: batchKeyPath, Value: key}); err != nil {
+			return nil, fmt.Errorf("failed to persist batch token key: %v", err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	ts.batchGCM = gcm
+	return gcm, nil
+}
+
+// createBatch is used to create a new batch token. The entry is encrypted
+// into the ID of the token rather than stored, so batch tokens have no
+// accessor, cannot be renewed or revoked, and expire at the end of their
+// TTL.
+func (ts *TokenStore) createBatch(entry *TokenEntry) error {
+	defer metrics.MeasureSince([]string{"token", "create-batch"}, time.Now())
+
+	switch {
+	case entry.ID != "":
+		return fmt.Errorf("batch tokens cannot have a custom ID")
+	case entry.NumUses != 0:
+		return fmt.Errorf("batch tokens cannot have a limited number of uses")
+	case entry.Period != 0:
+		return fmt.Errorf("batch tokens cannot be periodic")
+	case entry.TTL == 0:
+		return fmt.Errorf("batch tokens must have a TTL")
+	case strutil.StrListContains(entry.Policies, "root"):
+		return fmt.Errorf("batch tokens cannot be root tokens")
+	}
+
+	if entry.Parent != "" {
+		parent, err := ts.Lookup(entry.Parent)
+		if err != nil {
+			return fmt.Errorf("failed to lookup parent: %v", err)
+		}
+		if parent == nil {
+			return fmt.Errorf("parent token not found")
+		}
+	}
+
+	entry.Type = TokenTypeBatch
+	entry.Policies = policyutil.SanitizePolicies(entry.Policies, policyutil.DoNotAddDefaultPolicy)
+
+	enc, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %v", err)
+	}
+
+	gcm, err := ts.batchCipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	entry.ID = batchTokenPrefix + base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, enc, nil))
+	return nil
+}
+
+// lookupBatch is used to decrypt the entry of a batch token. It returns nil
+// if the token is invalid, expired, or its parent was revoked.
+func (ts *TokenStore) lookupBatch(id string) (*TokenEntry, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(id, batchTokenPrefix))
+	if err != nil {
+		return nil, nil
+	}
+
+	gcm, err := ts.batchCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, nil
+	}
+	enc, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, nil
+	}
+
+	entry := new(TokenEntry)
+	if err := jsonutil.DecodeJSON(enc, entry); err != nil {
+		return nil, fmt.Errorf("failed to decode entry: %v", err)
+	}
+	entry.ID = id
+
+	if time.Now().After(entry.expireTime()) {
+		return nil, nil
+	}
+
+	// Batch tokens are revoked with their parents
+	if entry.Parent != "" {
+		parent, err := ts.Lookup(entry.Parent)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, nil
+		}
+	}
+
+	return entry, nil
+}
+
+// expireTime returns the time a batch token expires
+func (te *TokenEntry) expireTime() time.Time {
+	return time.Unix(te.CreationTime, 0).Add(te.TTL)
+}
@@ -0,0 +1,209 @@
+package vault
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTokenStore_Batch(t *testing.T) {
+	_, ts, _, root := TestCoreWithTokenStore(t)
+	testMakeToken(t, ts, root, "parent", "", []string{"foo"})
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "create")
+	req.ClientToken = "parent"
+	req.Data["type"] = "batch"
+	req.Data["ttl"] = "1h"
+	req.Data["num_uses"] = "1"
+	resp, err := ts.HandleRequest(req)
+	if err != logical.ErrInvalidRequest || !strings.Contains(resp.Data["error"].(string), "number of uses") {
+		t.Fatalf("expected error, got err:%v resp:%#v", err, resp)
+	}
+
+	delete(req.Data, "num_uses")
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	id := resp.Auth.ClientToken
+	if !isBatchToken(id) || resp.Auth.Accessor != "" || resp.Auth.Renewable {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+
+	// The token is not persisted
+	out, err := ts.lookupSalted(ts.SaltID(id), true)
+	if err != nil || out != nil {
+		t.Fatalf("batch token stored: err:%v entry:%#v", err, out)
+	}
+
+	out, err = ts.Lookup(id)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || out.ID != id || out.Type != TokenTypeBatch || out.Parent != "parent" ||
+		out.TTL != time.Hour || !reflect.DeepEqual(out.Policies, []string{"default", "foo"}) {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "lookup")
+	req.ClientToken = root
+	req.Data["token"] = id
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if resp.Data["type"] != TokenTypeBatch || resp.Data["renewable"] != false || resp.Data["ttl"].(int64) <= 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Batch tokens cannot be renewed, revoked, or create child tokens
+	req = logical.TestRequest(t, logical.UpdateOperation, "renew-self")
+	req.ClientToken = id
+	if resp, err := ts.HandleRequest(req); err == nil {
+		t.Fatalf("expected error renewing, got %#v", resp)
+	}
+	if err := ts.RevokeTree(id); err == nil {
+		t.Fatalf("expected error revoking")
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "create")
+	req.ClientToken = id
+	if resp, err := ts.HandleRequest(req); err == nil {
+		t.Fatalf("expected error creating child token, got %#v", resp)
+	}
+
+	// Tampered tokens are invalid
+	tampered := id[:len(id)-2] + "AA"
+	if tampered == id {
+		tampered = id[:len(id)-2] + "BB"
+	}
+	if out, err := ts.Lookup(tampered); err != nil || out != nil {
+		t.Fatalf("tampered token valid: err:%v entry:%#v", err, out)
+	}
+
+	// Batch tokens are revoked with their parents
+	if err := ts.RevokeTree("parent"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out, err := ts.Lookup(id); err != nil || out != nil {
+		t.Fatalf("batch token valid after parent revocation: err:%v entry:%#v", err, out)
+	}
+}
+
+func TestTokenStore_Batch_Expired(t *testing.T) {
+	_, ts, _, _ := TestCoreWithTokenStore(t)
+
+	te := &TokenEntry{
+		Policies:     []string{"foo"},
+		Path:         "auth/token/create",
+		CreationTime: time.Now().Add(-2 * time.Hour).Unix(),
+		TTL:          time.Hour,
+	}
+	if err := ts.createBatch(te); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out, err := ts.Lookup(te.ID); err != nil || out != nil {
+		t.Fatalf("expired token valid: err:%v entry:%#v", err, out)
+	}
+
+	// Batch tokens cannot be root tokens
+	te = &TokenEntry{
+		Policies:     []string{"root"},
+		CreationTime: time.Now().Unix(),
+		TTL:          time.Hour,
+	}
+	if err := ts.createBatch(te); err == nil {
+		t.Fatalf("expected error creating root batch token")
+	}
+}
+
+func TestRequestHandling_BatchLogin(t *testing.T) {
+	core, _, root := TestCoreUnsealed(t)
+
+	core.credentialBackends["userpass"] = credUserpass.Factory
+
+	requests := []*logical.Request{
+		&logical.Request{
+			Path: "sys/auth/userpass",
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		&logical.Request{
+			Path: "sys/auth/userpass/tune",
+			Data: map[string]interface{}{
+				"token_type": "batch",
+			},
+		},
+		&logical.Request{
+			Path: "auth/userpass/users/test",
+			Data: map[string]interface{}{
+				"password": "foo",
+				"policies": "default",
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		req.Operation = logical.UpdateOperation
+		resp, err := core.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err:%v resp:%#v", req.Path, err, resp)
+		}
+	}
+
+	resp, err := core.HandleRequest(&logical.Request{
+		Path:        "sys/auth/userpass/tune",
+		ClientToken: root,
+		Operation:   logical.ReadOperation,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["token_type"] != TokenTypeBatch {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:      "auth/userpass/login/test",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"password": "foo",
+		},
+	})
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	token := resp.Auth.ClientToken
+	if !isBatchToken(token) || resp.Auth.Renewable {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "auth/token/lookup-self",
+		ClientToken: token,
+		Operation:   logical.ReadOperation,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["type"] != TokenTypeBatch || resp.Data["display_name"] != "userpass-test" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Batch tokens have no cubbyhole
+	resp, err = core.HandleRequest(&logical.Request{
+		Path:        "cubbyhole/foo",
+		ClientToken: token,
+		Operation:   logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"foo": "bar",
+		},
+	})
+	if err == nil {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+}
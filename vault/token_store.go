@@ -1,15 +1,18 @@
 package vault
 
 import (
+	"crypto/cipher"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/cidrutil"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/locksutil"
@@ -90,6 +93,10 @@ type TokenStore struct {
 	tokenLocks []*locksutil.LockEntry
 
 	cubbyholeDestroyer func(*TokenStore, string) error
+
+	// batchGCM is the cipher of the batch tokens, loaded on first use
+	batchLock sync.Mutex
+	batchGCM  cipher.AEAD
 }
 
 // NewTokenStore is used to construct a token store that is
@@ -126,6 +133,7 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 				lookupPrefix,
 				accessorPrefix,
 				parentPrefix,
+				batchKeyPath,
 				"salt",
 			},
 		},
@@ -202,6 +210,18 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 						Default:     true,
 						Description: tokenRenewableHelp,
 					},
+
+					"token_bound_cidrs": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "",
+						Description: tokenBoundCIDRsHelp,
+					},
+
+					"token_type": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     tokenTypeDefault,
+						Description: tokenTypeHelp,
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -530,6 +550,13 @@ type TokenEntry struct {
 	// backends are subject to those renewal rules.
 	Period time.Duration `json:"period" mapstructure:"period" structs:"period"`
 
+	// The type of the token, service or batch. Entries stored before the
+	// batch tokens were introduced have no type and are service tokens.
+	Type string `json:"type,omitempty" mapstructure:"type" structs:"type"`
+
+	// If set, the token can only be used from these CIDR blocks
+	BoundCIDRs []string `json:"bound_cidrs,omitempty" mapstructure:"bound_cidrs" structs:"bound_cidrs"`
+
 	// These are the deprecated fields
 	DisplayNameDeprecated    string        `json:"DisplayName" mapstructure:"DisplayName" structs:"DisplayName"`
 	NumUsesDeprecated        int           `json:"NumUses" mapstructure:"NumUses" structs:"NumUses"`
@@ -566,6 +593,14 @@ type tsRoleEntry struct {
 	// If set, the token entry will have an explicit maximum TTL set, rather
 	// than deferring to role/mount values
 	ExplicitMaxTTL time.Duration `json:"explicit_max_ttl" mapstructure:"explicit_max_ttl" structs:"explicit_max_ttl"`
+
+	// If set, tokens created using this role can only be used from these
+	// CIDR blocks
+	BoundCIDRs []string `json:"bound_cidrs" mapstructure:"bound_cidrs" structs:"bound_cidrs"`
+
+	// The type of the tokens created using this role; if "default", the
+	// type requested at creation is used
+	TokenType string `json:"token_type" mapstructure:"token_type" structs:"token_type"`
 }
 
 type accessorEntry struct {
@@ -804,6 +839,10 @@ func (ts *TokenStore) Lookup(id string) (*TokenEntry, error) {
 		return nil, fmt.Errorf("cannot lookup blank token")
 	}
 
+	if isBatchToken(id) {
+		return ts.lookupBatch(id)
+	}
+
 	lock := locksutil.LockForKey(ts.tokenLocks, id)
 	lock.RLock()
 	defer lock.RUnlock()
@@ -890,6 +929,9 @@ func (ts *TokenStore) Revoke(id string) error {
 	if id == "" {
 		return fmt.Errorf("cannot revoke blank token")
 	}
+	if isBatchToken(id) {
+		return fmt.Errorf("batch tokens cannot be revoked")
+	}
 
 	return ts.revokeSalted(ts.SaltID(id))
 }
@@ -1012,6 +1054,9 @@ func (ts *TokenStore) RevokeTree(id string) error {
 	if id == "" {
 		return fmt.Errorf("cannot revoke blank token")
 	}
+	if isBatchToken(id) {
+		return fmt.Errorf("batch tokens cannot be revoked")
+	}
 
 	// Get the salted ID
 	saltedId := ts.SaltID(id)
@@ -1324,6 +1369,13 @@ func (ts *TokenStore) handleCreateCommon(
 			logical.ErrInvalidRequest
 	}
 
+	// Batch tokens are not persisted, so there is no revocation tree for
+	// their children
+	if parent.Type == TokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot generate child tokens"),
+			logical.ErrInvalidRequest
+	}
+
 	// Check if the client token has sudo/root privileges for the requested path
 	isSudo := ts.System().SudoPrivilege(req.MountPoint+req.Path, req.ClientToken)
 
@@ -1341,6 +1393,7 @@ func (ts *TokenStore) handleCreateCommon(
 		DisplayName     string `mapstructure:"display_name"`
 		NumUses         int    `mapstructure:"num_uses"`
 		Period          string
+		Type            string
 	}
 	if err := mapstructure.WeakDecode(req.Data, &data); err != nil {
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -1353,6 +1406,22 @@ func (ts *TokenStore) handleCreateCommon(
 			logical.ErrInvalidRequest
 	}
 
+	// Roles may force the type of their tokens
+	if role != nil && role.TokenType != "" && role.TokenType != tokenTypeDefault {
+		if data.Type != "" && data.Type != role.TokenType {
+			return logical.ErrorResponse(fmt.Sprintf("role only issues %s tokens", role.TokenType)),
+				logical.ErrInvalidRequest
+		}
+		data.Type = role.TokenType
+	}
+	if data.Type == "" {
+		data.Type = TokenTypeService
+	}
+	if !validTokenType(data.Type) {
+		return logical.ErrorResponse(fmt.Sprintf("invalid token type %q", data.Type)),
+			logical.ErrInvalidRequest
+	}
+
 	// Setup the token entry
 	te := TokenEntry{
 		Parent: req.ClientToken,
@@ -1372,6 +1441,9 @@ func (ts *TokenStore) handleCreateCommon(
 	if data.Renewable != nil {
 		renewable = *data.Renewable
 	}
+	if data.Type == TokenTypeBatch {
+		renewable = false
+	}
 
 	// If the role is not nil, we add the role name as part of the token's
 	// path. This makes it much easier to later revoke tokens that were issued
@@ -1390,6 +1462,8 @@ func (ts *TokenStore) handleCreateCommon(
 		if role.PathSuffix != "" {
 			te.Path = fmt.Sprintf("%s/%s", te.Path, role.PathSuffix)
 		}
+
+		te.BoundCIDRs = role.BoundCIDRs
 	}
 
 	// Attach the given display name if any
@@ -1406,6 +1480,10 @@ func (ts *TokenStore) handleCreateCommon(
 			return logical.ErrorResponse("root or sudo privileges required to specify token id"),
 				logical.ErrInvalidRequest
 		}
+		if isBatchToken(data.ID) {
+			return logical.ErrorResponse(fmt.Sprintf("token id cannot begin with %q", batchTokenPrefix)),
+				logical.ErrInvalidRequest
+		}
 		te.ID = data.ID
 	}
 
@@ -1452,9 +1530,15 @@ func (ts *TokenStore) handleCreateCommon(
 			sanitizedRolePolicies = policyutil.SanitizePolicies(role.AllowedPolicies, localAddDefault)
 
 			if len(finalPolicies) == 0 {
-				finalPolicies = sanitizedRolePolicies
+				// Glob patterns only allow the policies requested
+				// explicitly
+				for _, policy := range sanitizedRolePolicies {
+					if !strings.Contains(policy, "*") {
+						finalPolicies = append(finalPolicies, policy)
+					}
+				}
 			} else {
-				if !strutil.StrListSubset(sanitizedRolePolicies, finalPolicies) {
+				if !globbedPoliciesSubset(sanitizedRolePolicies, finalPolicies) {
 					return logical.ErrorResponse(fmt.Sprintf("token policies (%v) must be subset of the role's allowed policies (%v)", finalPolicies, sanitizedRolePolicies)), logical.ErrInvalidRequest
 				}
 			}
@@ -1683,8 +1767,15 @@ func (ts *TokenStore) handleCreateCommon(
 	}
 
 	// Create the token
-	if err := ts.create(&te); err != nil {
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	switch data.Type {
+	case TokenTypeBatch:
+		if err := ts.createBatch(&te); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	default:
+		if err := ts.create(&te); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
 	}
 
 	// Generate the response
@@ -1832,8 +1923,13 @@ func (ts *TokenStore) handleLookup(
 	defer lock.RUnlock()
 
 	// Lookup the token
-	saltedId := ts.SaltID(id)
-	out, err := ts.lookupSalted(saltedId, true)
+	var out *TokenEntry
+	var err error
+	if isBatchToken(id) {
+		out, err = ts.lookupBatch(id)
+	} else {
+		out, err = ts.lookupSalted(ts.SaltID(id), true)
+	}
 
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -1859,6 +1955,7 @@ func (ts *TokenStore) handleLookup(
 			"creation_ttl":     int64(out.TTL.Seconds()),
 			"ttl":              int64(0),
 			"explicit_max_ttl": int64(out.ExplicitMaxTTL.Seconds()),
+			"type":             TokenTypeService,
 		},
 	}
 
@@ -1872,6 +1969,20 @@ func (ts *TokenStore) handleLookup(
 	if out.Period != 0 {
 		resp.Data["period"] = int64(out.Period.Seconds())
 	}
+	if len(out.BoundCIDRs) > 0 {
+		resp.Data["bound_cidrs"] = out.BoundCIDRs
+	}
+
+	// Batch tokens have no lease, they expire at the end of their TTL
+	if out.Type == TokenTypeBatch {
+		resp.Data["type"] = TokenTypeBatch
+		resp.Data["ttl"] = int64(out.expireTime().Sub(time.Now().Round(time.Second)).Seconds())
+		resp.Data["renewable"] = false
+		if urltoken {
+			resp.AddWarning(`Using a token in the path is unsafe as the token can be logged in many places. Please use POST or PUT with the token passed in via the "token" parameter.`)
+		}
+		return resp, nil
+	}
 
 	// Fetch the last renewal time
 	leaseTimes, err := ts.expiration.FetchLeaseTimesByToken(out.Path, out.ID)
@@ -1933,6 +2044,9 @@ func (ts *TokenStore) handleRenew(
 	if te == nil {
 		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
 	}
+	if te.Type == TokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot be renewed"), logical.ErrInvalidRequest
+	}
 
 	// Renew the token and its children
 	resp, err := ts.expiration.RenewToken(req, te.Path, te.ID, increment)
@@ -2092,9 +2206,17 @@ func (ts *TokenStore) tokenStoreRoleRead(
 			"orphan":              role.Orphan,
 			"path_suffix":         role.PathSuffix,
 			"renewable":           role.Renewable,
+			"token_bound_cidrs":   role.BoundCIDRs,
+			"token_type":          role.TokenType,
 		},
 	}
 
+	// Roles stored before the token types were introduced issue the type
+	// requested at creation
+	if role.TokenType == "" {
+		resp.Data["token_type"] = tokenTypeDefault
+	}
+
 	return resp, nil
 }
 
@@ -2206,6 +2328,33 @@ func (ts *TokenStore) tokenStoreRoleCreateUpdate(
 		entry.DisallowedPolicies = strutil.ParseDedupAndSortStrings(data.Get("disallowed_policies").(string), ",")
 	}
 
+	boundCIDRsStr, ok := data.GetOk("token_bound_cidrs")
+	if ok {
+		entry.BoundCIDRs = strutil.ParseDedupAndSortStrings(boundCIDRsStr.(string), ",")
+	} else if req.Operation == logical.CreateOperation {
+		entry.BoundCIDRs = strutil.ParseDedupAndSortStrings(data.Get("token_bound_cidrs").(string), ",")
+	}
+	if len(entry.BoundCIDRs) > 0 {
+		valid, err := cidrutil.ValidateCIDRListSlice(entry.BoundCIDRs)
+		if err != nil || !valid {
+			return logical.ErrorResponse(fmt.Sprintf("invalid CIDR blocks in token_bound_cidrs: %v", entry.BoundCIDRs)), nil
+		}
+	}
+
+	tokenTypeStr, ok := data.GetOk("token_type")
+	if ok {
+		entry.TokenType = tokenTypeStr.(string)
+	} else if req.Operation == logical.CreateOperation {
+		entry.TokenType = data.Get("token_type").(string)
+	}
+	switch {
+	case entry.TokenType == "", entry.TokenType == tokenTypeDefault:
+	case !validTokenType(entry.TokenType):
+		return logical.ErrorResponse(fmt.Sprintf("invalid token type %q", entry.TokenType)), nil
+	case entry.TokenType == TokenTypeBatch && entry.Period != 0:
+		return logical.ErrorResponse("batch tokens cannot be periodic"), nil
+	}
+
 	// Store it
 	jsonEntry, err := logical.StorageEntryJSON(fmt.Sprintf("%s%s", rolesPrefix, name), entry)
 	if err != nil {
@@ -2274,6 +2423,12 @@ no effect on the token being renewed.`
 	tokenRenewableHelp = `Tokens created via this role will be
 renewable or not according to this value.
 Defaults to "true".`
+	tokenBoundCIDRsHelp = `If set, tokens created via this role can only be
+used from these CIDR blocks. The parameter is a comma-delimited string of
+CIDR blocks.`
+	tokenTypeHelp = `The type of the tokens created via this role: "service",
+"batch", or "default" to use the type requested at creation. Defaults to
+"default".`
 	tokenListAccessorsHelp = `List token accessors, which can then be
 be used to iterate and discover their properities
 or revoke them. Because this can be used to
@@ -2281,3 +2436,21 @@ cause a denial of service, this endpoint
 requires 'sudo' capability in addition to
 'list'.`
 )
+
+// globbedPoliciesSubset returns whether each of the policies is matched by
+// one of the allowed policies, which may be glob patterns
+func globbedPoliciesSubset(allowed, policies []string) bool {
+	for _, policy := range policies {
+		matched := false
+		for _, pattern := range allowed {
+			if strutil.GlobbedStringsMatch(pattern, policy) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
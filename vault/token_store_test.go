@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/logical"
 )
@@ -1358,6 +1359,7 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"creation_ttl":     int64(0),
 		"ttl":              int64(0),
 		"explicit_max_ttl": int64(0),
+		"type":             "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1396,6 +1398,7 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"creation_ttl":     int64(3600),
 		"ttl":              int64(3600),
 		"explicit_max_ttl": int64(0),
+		"type":             "service",
 		"renewable":        true,
 	}
 
@@ -1438,6 +1441,7 @@ func TestTokenStore_HandleRequest_Lookup(t *testing.T) {
 		"creation_ttl":     int64(3600),
 		"ttl":              int64(3600),
 		"explicit_max_ttl": int64(0),
+		"type":             "service",
 		"renewable":        true,
 	}
 
@@ -1509,6 +1513,7 @@ func TestTokenStore_HandleRequest_LookupSelf(t *testing.T) {
 		"creation_ttl":     int64(0),
 		"ttl":              int64(0),
 		"explicit_max_ttl": int64(0),
+		"type":             "service",
 	}
 
 	if resp.Data["creation_time"].(int64) == 0 {
@@ -1666,6 +1671,8 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 		"path_suffix":         "happenin",
 		"explicit_max_ttl":    int64(0),
 		"renewable":           true,
+		"token_bound_cidrs":   []string{},
+		"token_type":          "default",
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -1710,6 +1717,8 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 		"path_suffix":         "happenin",
 		"explicit_max_ttl":    int64(0),
 		"renewable":           false,
+		"token_bound_cidrs":   []string{},
+		"token_type":          "default",
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -1747,6 +1756,8 @@ func TestTokenStore_RoleCRUD(t *testing.T) {
 		"path_suffix":         "happenin",
 		"period":              int64(0),
 		"renewable":           false,
+		"token_bound_cidrs":   []string{},
+		"token_type":          "default",
 	}
 
 	if !reflect.DeepEqual(expected, resp.Data) {
@@ -2053,6 +2064,160 @@ func TestTokenStore_RoleAllowedPolicies(t *testing.T) {
 	}
 }
 
+func TestTokenStore_RoleAllowedPoliciesGlob(t *testing.T) {
+	_, ts, _, root := TestCoreWithTokenStore(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "roles/test")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"allowed_policies": "test1,app-*",
+	}
+	resp, err := ts.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req.Path = "create/test"
+	req.Data = map[string]interface{}{
+		"policies": []string{"app-foo", "app-bar"},
+	}
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Auth.Policies, []string{"app-bar", "app-foo", "default"}) {
+		t.Fatalf("bad: %#v", resp.Auth.Policies)
+	}
+
+	req.Data["policies"] = []string{"app-foo", "other"}
+	resp, err = ts.HandleRequest(req)
+	if err == nil {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+
+	// Without requested policies, only the non-glob policies are assigned
+	delete(req.Data, "policies")
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Auth.Policies, []string{"default", "test1"}) {
+		t.Fatalf("bad: %#v", resp.Auth.Policies)
+	}
+}
+
+func TestTokenStore_RoleBoundCIDRs(t *testing.T) {
+	core, ts, _, root := TestCoreWithTokenStore(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "roles/test")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"token_bound_cidrs": "foo",
+	}
+	resp, err := ts.HandleRequest(req)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response, got err:%v resp:%#v", err, resp)
+	}
+
+	req.Data["token_bound_cidrs"] = "127.0.0.1/32,10.0.0.0/8"
+	resp, err = ts.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req.Operation = logical.ReadOperation
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["token_bound_cidrs"], []string{"10.0.0.0/8", "127.0.0.1/32"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "create/test")
+	req.ClientToken = root
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	token := resp.Auth.ClientToken
+
+	lookup := func(remoteAddr string) error {
+		req := &logical.Request{
+			Path:        "auth/token/lookup-self",
+			ClientToken: token,
+			Operation:   logical.ReadOperation,
+		}
+		if remoteAddr != "" {
+			req.Connection = &logical.Connection{RemoteAddr: remoteAddr}
+		}
+		_, err := core.HandleRequest(req)
+		return err
+	}
+
+	if err := lookup("127.0.0.1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := lookup("10.1.2.3"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := lookup("192.168.0.1"); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if err := lookup(""); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+}
+
+func TestTokenStore_RoleTokenType(t *testing.T) {
+	_, ts, _, root := TestCoreWithTokenStore(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "roles/test")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"token_type": "foo",
+	}
+	resp, err := ts.HandleRequest(req)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response, got err:%v resp:%#v", err, resp)
+	}
+
+	req.Data = map[string]interface{}{
+		"token_type": "batch",
+		"period":     "1h",
+	}
+	resp, err = ts.HandleRequest(req)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response, got err:%v resp:%#v", err, resp)
+	}
+
+	req.Data = map[string]interface{}{
+		"token_type":       "batch",
+		"allowed_policies": "test1",
+	}
+	resp, err = ts.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "create/test")
+	req.ClientToken = root
+	req.Data["ttl"] = "1h"
+	resp, err = ts.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v %v", err, resp)
+	}
+	if !isBatchToken(resp.Auth.ClientToken) {
+		t.Fatalf("bad: %#v", resp.Auth)
+	}
+
+	req.Data["type"] = "service"
+	resp, err = ts.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected error, got err:%v resp:%#v", err, resp)
+	}
+}
+
 func TestTokenStore_RoleOrphan(t *testing.T) {
 	_, ts, _, root := TestCoreWithTokenStore(t)
 
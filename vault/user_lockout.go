@@ -0,0 +1,193 @@
+package vault
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The defaults of the user lockout configuration of the auth mounts
+	defaultLockoutThreshold    = 5
+	defaultLockoutDuration     = 15 * time.Minute
+	defaultLockoutCounterReset = 15 * time.Minute
+)
+
+// lockoutSupportedBackends are the types of the credential backends whose
+// users are locked out after too many failed logins. The username is the
+// last segment of their login paths.
+var lockoutSupportedBackends = []string{
+	"userpass",
+}
+
+// userLockoutConfig is the effective user lockout configuration of a mount
+type userLockoutConfig struct {
+	Threshold    int
+	Duration     time.Duration
+	CounterReset time.Duration
+	Disable      bool
+}
+
+// failedLogins tracks the failed logins of a user
+type failedLogins struct {
+	Count       int
+	LastFailure time.Time
+}
+
+// userLockouts tracks the failed logins of the users of the auth mounts. It
+// is kept in memory, so the lockouts are lifted when the active node changes.
+type userLockouts struct {
+	l sync.Mutex
+
+	// users maps the UUIDs of the mounts to the failed logins of their users
+	users map[string]map[string]*failedLogins
+}
+
+func newUserLockouts() *userLockouts {
+	return &userLockouts{
+		users: make(map[string]map[string]*failedLogins),
+	}
+}
+
+// lockoutSupported returns whether the users of the mount can be locked out
+func lockoutSupported(entry *MountEntry) bool {
+	if entry == nil || entry.Table != credentialTableType {
+		return false
+	}
+	for _, t := range lockoutSupportedBackends {
+		if entry.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// userLockoutConfigFor returns the user lockout configuration of the mount,
+// with the defaults of the unset values
+func userLockoutConfigFor(entry *MountEntry) *userLockoutConfig {
+	config := &userLockoutConfig{
+		Threshold:    entry.Config.LockoutThreshold,
+		Duration:     entry.Config.LockoutDuration,
+		CounterReset: entry.Config.LockoutCounterReset,
+		Disable:      entry.Config.LockoutDisable,
+	}
+	if config.Threshold == 0 {
+		config.Threshold = defaultLockoutThreshold
+	}
+	if config.Duration == 0 {
+		config.Duration = defaultLockoutDuration
+	}
+	if config.CounterReset == 0 {
+		config.CounterReset = defaultLockoutCounterReset
+	}
+	return config
+}
+
+// lockoutUsername returns the username of the login request to the mount, or
+// an empty string if its users cannot be locked out
+func (c *Core) lockoutUsername(entry *MountEntry, path string) string {
+	if !lockoutSupported(entry) || userLockoutConfigFor(entry).Disable {
+		return ""
+	}
+	path = strings.TrimPrefix(path, c.router.MatchingMount(path))
+	if !strings.HasPrefix(path, "login/") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(path, "login/"))
+}
+
+// locked returns whether the user of the mount is locked out
+func (u *userLockouts) locked(entry *MountEntry, username string) bool {
+	config := userLockoutConfigFor(entry)
+
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	failed, ok := u.users[entry.UUID][username]
+	return ok && failed.locked(config, time.Now())
+}
+
+// failed records a failed login of the user of the mount
+func (u *userLockouts) failed(entry *MountEntry, username string) {
+	config := userLockoutConfigFor(entry)
+	now := time.Now()
+
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	users, ok := u.users[entry.UUID]
+	if !ok {
+		users = make(map[string]*failedLogins)
+		u.users[entry.UUID] = users
+	}
+
+	// Forget the users whose counters were reset, so that logins with
+	// unknown usernames do not pile up
+	for name, failed := range users {
+		if failed.expired(config, now) {
+			delete(users, name)
+		}
+	}
+
+	failed, ok := users[username]
+	if !ok {
+		failed = &failedLogins{}
+		users[username] = failed
+	}
+	failed.Count++
+	failed.LastFailure = now
+}
+
+// succeeded resets the failed logins of the user of the mount
+func (u *userLockouts) succeeded(entry *MountEntry, username string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	delete(u.users[entry.UUID], username)
+}
+
+// lockedUsers returns the users of the mount which are locked out
+func (u *userLockouts) lockedUsers(entry *MountEntry) map[string]*failedLogins {
+	config := userLockoutConfigFor(entry)
+	now := time.Now()
+
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	locked := make(map[string]*failedLogins)
+	for name, failed := range u.users[entry.UUID] {
+		if failed.locked(config, now) {
+			copied := *failed
+			locked[name] = &copied
+		}
+	}
+	return locked
+}
+
+// unlock lifts the lockout of the user of the mount, and returns whether it
+// was locked out
+func (u *userLockouts) unlock(entry *MountEntry, username string) bool {
+	config := userLockoutConfigFor(entry)
+
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	failed, ok := u.users[entry.UUID][username]
+	if !ok {
+		return false
+	}
+	delete(u.users[entry.UUID], username)
+	return failed.locked(config, time.Now())
+}
+
+// locked returns whether the failed logins lock the user out
+func (f *failedLogins) locked(config *userLockoutConfig, now time.Time) bool {
+	return f.Count >= config.Threshold && now.Before(f.LastFailure.Add(config.Duration))
+}
+
+// expired returns whether the failed logins are forgotten: the counter was
+// reset, and the user is not locked out
+func (f *failedLogins) expired(config *userLockoutConfig, now time.Time) bool {
+	return !f.locked(config, now) &&
+		(f.Count >= config.Threshold || !now.Before(f.LastFailure.Add(config.CounterReset)))
+}
@@ -115,6 +115,9 @@ func (c *Core) wrapInCubbyhole(req *logical.Request, resp *logical.Response) (*l
 
 	resp.WrapInfo.Token = te.ID
 	resp.WrapInfo.CreationTime = creationTime
+	if resp.WrapInfo.CreationPath == "" {
+		resp.WrapInfo.CreationPath = req.Path
+	}
 
 	// This will only be non-nil if this response contains a token, so in that
 	// case put the accessor in the wrap info.
@@ -199,6 +202,7 @@ func (c *Core) wrapInCubbyhole(req *logical.Request, resp *logical.Response) (*l
 	cubbyReq.Data = map[string]interface{}{
 		"creation_ttl":  resp.WrapInfo.TTL,
 		"creation_time": creationTime,
+		"creation_path": resp.WrapInfo.CreationPath,
 	}
 	cubbyResp, err = c.router.Route(cubbyReq)
 	if err != nil {